@@ -0,0 +1,105 @@
+package v1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplicationBlueprintSpec is a catalog entry, a named and versioned set of
+// charts the tenants instantiate with parameters.
+type ApplicationBlueprintSpec struct {
+	// Version is the current version, rolled out to the instances not
+	// pinning one.
+	Version string `json:"version"`
+	// Versions are the versions of the blueprint.
+	Versions []BlueprintVersion `json:"versions"`
+	// AppType is the app type of the tenants whose size selects the sizes
+	// of the versions, the first app type of a tenant by default.
+	AppType ApplicationType `json:"appType,omitempty"`
+}
+
+type BlueprintVersion struct {
+	Version string `json:"version"`
+	// Applications are the charts of the version, installed in the order
+	// of their dependencies.
+	Applications []BlueprintApplication `json:"applications"`
+	// Schema is the JSON schema of the parameters, an OpenAPI v3 schema
+	// like the ones of the CRDs.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Schema *apiextensionsv1.JSON `json:"schema,omitempty"`
+	// Defaults are the default parameters.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Defaults *apiextensionsv1.JSON `json:"defaults,omitempty"`
+	// Sizes override the schema and the defaults for the tenants of a
+	// size, by size name.
+	Sizes map[string]BlueprintSize `json:"sizes,omitempty"`
+}
+
+type BlueprintApplication struct {
+	AppSpec `json:",inline"`
+	// Parameters sets the values of the chart at the dotted paths, the
+	// keys, to the parameters they name, like replicaCount: replicas.
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+type BlueprintSize struct {
+	// Schema replaces the schema of the version.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Schema *apiextensionsv1.JSON `json:"schema,omitempty"`
+	// Defaults are merged over the defaults of the version.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Defaults *apiextensionsv1.JSON `json:"defaults,omitempty"`
+}
+
+// BlueprintRef instantiates a blueprint.
+type BlueprintRef struct {
+	Name string `json:"name"`
+	// Version pins a version of the blueprint, its current version is
+	// followed without any.
+	Version string `json:"version,omitempty"`
+	// Parameters are merged over the defaults and validated against the
+	// schema of the version.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Parameters *apiextensionsv1.JSON `json:"parameters,omitempty"`
+}
+
+type BlueprintStatus struct {
+	// Version is the version of the blueprint deployed.
+	Version string `json:"version,omitempty"`
+	// Message explains why the blueprint cannot be instantiated.
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// ApplicationBlueprint is the Schema for the applicationblueprints API
+type ApplicationBlueprint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ApplicationBlueprintSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ApplicationBlueprintList contains a list of ApplicationBlueprint
+type ApplicationBlueprintList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApplicationBlueprint `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ApplicationBlueprint{}, &ApplicationBlueprintList{})
+}
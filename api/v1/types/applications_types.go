@@ -1,6 +1,7 @@
 package v1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -13,6 +14,15 @@ const (
 	DeployedA     ApplicationPhase = "Deployed"
 	InstallingA   ApplicationPhase = "Installing"
 	FailedA       ApplicationPhase = "Failed"
+	// RolledBackA is a chart rolled back to its previous revision after a
+	// failed upgrade.
+	RolledBackA ApplicationPhase = "RolledBack"
+	// WaitingA is an app waiting for its dependencies to be ready before
+	// it is installed.
+	WaitingA ApplicationPhase = "Waiting"
+	// BlockedA is an app not installed because a dependency failed, or its
+	// dependencies are invalid.
+	BlockedA ApplicationPhase = "Blocked"
 )
 
 type ApplicationType string
@@ -21,27 +31,288 @@ type ApplicationType string
 type ApplicationSpec struct {
 	Dataplane    string    `json:"dataplane"`
 	Tenant       string    `json:"tenant"`
-	Applications []AppSpec `json:"applications"`
+	Applications []AppSpec `json:"applications,omitempty"`
+	// Blueprint instantiates the applications of an ApplicationBlueprint
+	// instead of Applications.
+	Blueprint *BlueprintRef `json:"blueprint,omitempty"`
+	// Upgrade configures the upgrades of the charts.
+	Upgrade ChartUpgradePolicy `json:"upgrade,omitempty"`
+}
+
+type ChartUpgradePolicy struct {
+	// DisableRollback leaves a failed upgrade in place instead of rolling
+	// the release back to its previous revision.
+	DisableRollback bool `json:"disableRollback,omitempty"`
 }
 
 type AppSpec struct {
-	Name      string    `json:"name"`
-	Namespace string    `json:"namespace,omitempty"`
-	Spec      ChartSpec `json:"spec"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Spec is the chart of the app, unless it has a Source.
+	// +optional
+	Spec ChartSpec `json:"spec,omitempty"`
+	// Source deploys manifests instead of a chart.
+	Source *ManifestSource `json:"source,omitempty"`
+	// RollbackRevision is the revision of the release the spec was rolled
+	// back to. The release is rolled back to it instead of upgraded while
+	// the spec matches the one of the revision.
+	RollbackRevision int `json:"rollbackRevision,omitempty"`
+	// DependsOn names the apps of the Applications installed and ready
+	// before this one is installed. They are uninstalled after it.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Readiness are the checks the app passes once installed before its
+	// dependents are installed. Without any, it is ready once deployed.
+	Readiness []ReadinessCheck `json:"readiness,omitempty"`
+}
+
+// ChartName is the name the app is reported by in the maps of the status of
+// its Applications.
+func (a AppSpec) ChartName() string {
+	return a.Name + "-" + a.Namespace
+}
+
+// ManifestSource are manifests applied with server-side apply, the
+// namespaced objects into the namespace of the tenant. The objects removed
+// from them are pruned. One of Inline, ConfigMap and Git is set.
+type ManifestSource struct {
+	// Inline are YAML manifests separated by ---.
+	Inline string `json:"inline,omitempty"`
+	// ConfigMap names a ConfigMap of the namespace of the object, its keys
+	// being files of manifests.
+	ConfigMap string `json:"configMap,omitempty"`
+	// Git is a directory of a Git repository.
+	Git *GitManifestSource `json:"git,omitempty"`
+	// Kustomize builds the files of the ConfigMap or of the Git directory,
+	// holding a kustomization.yaml, with kustomize.
+	Kustomize bool `json:"kustomize,omitempty"`
+	// Interval is the time between two applies of the same source, 5m by
+	// default. The changes of the spec are applied right away.
+	Interval *metav1.Duration `json:"interval,omitempty"`
 }
 
+type GitManifestSource struct {
+	// URL is the url of the repository, like
+	// https://github.com/example/addons.git. The local repositories, like
+	// file:///srv/addons.git, are only checked out by the GitSources the
+	// operator allows to.
+	URL string `json:"url"`
+	// Ref is a branch, a tag or a commit, the default branch without any.
+	Ref string `json:"ref,omitempty"`
+	// Path is the directory of the manifests, the root of the repository
+	// without any.
+	Path string `json:"path,omitempty"`
+	// SecretName names a Secret of the namespace of the object holding the
+	// username and password keys, a token being the password.
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// ReadinessCheck checks an installed app is ready, one of Deployment, Job
+// and HTTP is set.
+type ReadinessCheck struct {
+	// Deployment names a deployment ready once available.
+	Deployment string `json:"deployment,omitempty"`
+	// Job names a job ready once completed.
+	Job string `json:"job,omitempty"`
+	// HTTP probes a service from inside the cluster.
+	HTTP *HTTPReadinessCheck `json:"http,omitempty"`
+	// Namespace is the namespace of the deployment, job or service. It can
+	// only be the namespace of the tenant the charts are installed in, the
+	// default.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// HTTPReadinessCheck sends a GET request to a service through the proxy of
+// the api server, ready once it answers with a 2xx status.
+type HTTPReadinessCheck struct {
+	Service string `json:"service"`
+	// Port is the name or the number of the port of the service.
+	Port string `json:"port"`
+	Path string `json:"path,omitempty"`
+	// +kubebuilder:validation:Enum=http;https
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// ChartSpec is a helm chart and its values. The values are merged in this
+// order, the later ones overriding the earlier ones: the valuesFrom
+// references in their order, valuesObject, then the --set style values.
 type ChartSpec struct {
-	ChartName string   `json:"chartName"`
-	RepoName  string   `json:"repoName"`
-	RepoUrl   string   `json:"repoUrl"`
-	Version   string   `json:"version"`
-	Values    []string `json:"values,omitempty"`
+	// ChartName is the name of the chart in its repo, or its full oci
+	// reference, like oci://registry.example.com/charts/app, without any.
+	ChartName string `json:"chartName"`
+	RepoName  string `json:"repoName,omitempty"`
+	// RepoUrl is the url of a chart repository, or of an oci registry like
+	// oci://123456789012.dkr.ecr.us-east-1.amazonaws.com/charts.
+	RepoUrl string `json:"repoUrl,omitempty"`
+	Version string `json:"version"`
+	// Values are --set style expressions, like image.tag=1.2.0.
+	Values []string `json:"values,omitempty"`
+	// ValuesObject are structured values, like the content of a values
+	// file.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	ValuesObject *apiextensionsv1.JSON `json:"valuesObject,omitempty"`
+	// ValuesFrom reads values from the Secrets and ConfigMaps of the
+	// namespace of the object, so secrets are kept out of the spec.
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+	// Auth are the credentials of a private repo or registry.
+	Auth *ChartAuth `json:"auth,omitempty"`
+	// InsecureSkipTLSVerify disables the verification of the certificate
+	// of the repo or registry.
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+// ChartAuth are the credentials of a chart repo or registry. Both can be
+// set, to log in ECR with a CA bundle of the Secret.
+type ChartAuth struct {
+	// SecretName names a Secret of the namespace of the object holding the
+	// username and password keys, and optionally a ca.crt CA bundle and a
+	// tls.crt and tls.key client certificate.
+	SecretName string `json:"secretName,omitempty"`
+	// ECR logs in the ECR registry of the chart with a token of the AWS
+	// credentials of the dataplane, refreshed before it expires. The
+	// registry is of the account of the dataplane, or of an account the
+	// operator allows.
+	ECR bool `json:"ecr,omitempty"`
+}
+
+type ValuesKind string
+
+const (
+	SecretValues    ValuesKind = "Secret"
+	ConfigMapValues ValuesKind = "ConfigMap"
+)
+
+// ValuesReference reads values from a key of a Secret or ConfigMap.
+type ValuesReference struct {
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind ValuesKind `json:"kind"`
+	Name string     `json:"name"`
+	// ValuesKey is the key holding the values, values.yaml by default.
+	ValuesKey string `json:"valuesKey,omitempty"`
+	// TargetPath sets the content of the key as the string value at a
+	// dotted path, like auth.password, instead of merging it as a values
+	// file.
+	TargetPath string `json:"targetPath,omitempty"`
+	// Optional ignores a missing object or key.
+	Optional bool `json:"optional,omitempty"`
 }
 
 type ApplicationStatus struct {
 	Phase                  ApplicationPhase            `json:"phase,omitempty"`
 	ApplicationCurrentSpec ApplicationSpec             `json:"applicationCurrentSpec,omitempty"`
 	AppStatus              map[string]ApplicationPhase `json:"appStatus,omitempty"`
+	// Releases reports the helm release of each chart.
+	Releases map[string]ReleaseStatus `json:"releases,omitempty"`
+	// Reasons explains the apps Waiting or Blocked, and the deployed apps
+	// not ready yet.
+	Reasons map[string]string `json:"reasons,omitempty"`
+	// Blueprint reports the instance of the blueprint of the spec.
+	Blueprint *BlueprintStatus `json:"blueprint,omitempty"`
+	// Manifests reports the objects applied for the apps with a source, by
+	// chart name like the releases.
+	Manifests map[string]ManifestStatus `json:"manifests,omitempty"`
+	// Health reports the health of the resources of the deployed apps on
+	// the dataplane, by chart name like the releases.
+	Health map[string]AppHealth `json:"health,omitempty"`
+}
+
+type HealthStatus string
+
+const (
+	HealthyH HealthStatus = "Healthy"
+	// ProgressingH resources are rolling out, their replicas not all ready
+	// yet, or their jobs still running.
+	ProgressingH HealthStatus = "Progressing"
+	// DegradedH resources are missing, have crashing pods, failed jobs or
+	// claims pending for long.
+	DegradedH HealthStatus = "Degraded"
+	// UnknownH resources could not be inspected.
+	UnknownH HealthStatus = "Unknown"
+)
+
+type AppHealth struct {
+	// Status is the worst health of the resources.
+	Status HealthStatus `json:"status"`
+	// Message explains the resources not healthy.
+	Message       string      `json:"message,omitempty"`
+	LastCheckTime metav1.Time `json:"lastCheckTime"`
+	// Resources are the workloads, jobs and claims of the app.
+	Resources []ResourceHealth `json:"resources,omitempty"`
+}
+
+type ResourceHealth struct {
+	Kind      string       `json:"kind"`
+	Namespace string       `json:"namespace,omitempty"`
+	Name      string       `json:"name"`
+	Health    HealthStatus `json:"health"`
+	Message   string       `json:"message,omitempty"`
+}
+
+type ManifestStatus struct {
+	// Revision is the commit of a Git source, the hash of the manifests of
+	// the others.
+	Revision string `json:"revision,omitempty"`
+	// SourceHash is the hash of the source applied.
+	SourceHash string `json:"sourceHash,omitempty"`
+	// AppliedAt is the time of the last apply.
+	AppliedAt *metav1.Time `json:"appliedAt,omitempty"`
+	// Resources are the objects applied, pruned once removed from the
+	// manifests.
+	Resources []ResourceStatus `json:"resources,omitempty"`
+}
+
+type ResourcePhase string
+
+const (
+	AppliedRS ResourcePhase = "Applied"
+	FailedRS  ResourcePhase = "Failed"
+)
+
+type ResourceStatus struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Name       string        `json:"name"`
+	Phase      ResourcePhase `json:"phase"`
+	// Message explains why the object failed to apply.
+	Message string `json:"message,omitempty"`
+}
+
+type ReleaseStatus struct {
+	// Revision is the deployed revision of the release.
+	Revision int `json:"revision,omitempty"`
+	// SpecHash is the hash of the chart, version and values of Revision,
+	// and of the versions of the Secrets and ConfigMaps the values are read
+	// from. The release is upgraded when the spec hashes differently.
+	SpecHash string `json:"specHash,omitempty"`
+	// FailedSpecHash is the spec hash of the last failed upgrade, it is not
+	// retried until the spec changes.
+	FailedSpecHash string `json:"failedSpecHash,omitempty"`
+	// History are the latest revisions of the release, newest first.
+	History []ReleaseRevision `json:"history,omitempty"`
+	// Specs are the specs of the revisions of History by spec hash, each
+	// recorded once. The specs larger than MaxRecordedSpecSize are not
+	// recorded, their revisions can not be restored.
+	Specs map[string]ChartSpec `json:"specs,omitempty"`
+}
+
+// MaxRecordedSpecSize bounds the size in JSON of the specs a ReleaseStatus
+// records.
+const MaxRecordedSpecSize = 16 << 10
+
+type ReleaseRevision struct {
+	Revision int `json:"revision"`
+	// Status is the helm status of the revision, like deployed, superseded
+	// or failed.
+	Status       string      `json:"status,omitempty"`
+	ChartVersion string      `json:"chartVersion,omitempty"`
+	Description  string      `json:"description,omitempty"`
+	DeployedAt   metav1.Time `json:"deployedAt,omitempty"`
+	// SpecHash is the hash of the spec the revision was deployed from, see
+	// ReleaseStatus.Specs. It is unknown for the revisions not deployed by
+	// baaz.
+	SpecHash string `json:"specHash,omitempty"`
 }
 
 //+kubebuilder:object:root=true
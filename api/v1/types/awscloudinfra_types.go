@@ -21,6 +21,88 @@ type EksConfig struct {
 	SubnetIds        []string `json:"subnetIds,omitempty"`
 	SecurityGroupIds []string `json:"securityGroupIds,omitempty"`
 	Version          string   `json:"version,omitempty"`
+	// Addons are the eks managed addons of the cluster, ie coredns, kube-proxy,
+	// eks-pod-identity-agent or aws-efs-csi-driver. Addons removed from the
+	// list are deleted from the cluster, except vpc-cni, coredns and kube-proxy
+	// which are kept. Defaults to vpc-cni v1.15.0-eksbuild.2 and aws-ebs-csi-driver.
+	Addons []EksAddon `json:"addons,omitempty"`
+	// Upgrade configures how version upgrades roll out to the nodegroups.
+	Upgrade UpgradePolicy `json:"upgrade,omitempty"`
+	// Provisioner launches the tenant nodes: one managed nodegroup per
+	// machine pool scaled by cluster autoscaler, or karpenter NodePools.
+	// It is set when the dataplane is created. Defaults to nodegroup.
+	// +kubebuilder:validation:Enum=nodegroup;karpenter
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="provisioner is immutable"
+	Provisioner NodeProvisioner `json:"provisioner,omitempty"`
+	// Karpenter configures karpenter when it is the provisioner.
+	Karpenter KarpenterConfig `json:"karpenter,omitempty"`
+}
+
+type NodeProvisioner string
+
+const (
+	NodegroupProvisioner NodeProvisioner = "nodegroup"
+	KarpenterProvisioner NodeProvisioner = "karpenter"
+)
+
+type KarpenterConfig struct {
+	// Version of the karpenter chart, defaults to 1.0.6.
+	Version string `json:"version,omitempty"`
+}
+
+// UpgradePolicy drives the upgrade started when Version changes. Upgrades
+// move one minor version at a time: control plane, addons, system nodegroup
+// then tenant nodegroups.
+type UpgradePolicy struct {
+	// SizeOrder lists the tenant sizes, the keys of TenantsInfra
+	// TenantSizes, whose nodegroups are upgraded first, in order. The
+	// nodegroups of the other sizes follow by name.
+	SizeOrder []string `json:"sizeOrder,omitempty"`
+	// MaxUnavailable is the number of nodes of a nodegroup replaced at once,
+	// defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	MaxUnavailable int32 `json:"maxUnavailable,omitempty"`
+	// MaxParallelNodegroups is the number of tenant nodegroups upgraded at
+	// once, defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	MaxParallelNodegroups int32 `json:"maxParallelNodegroups,omitempty"`
+}
+
+// AddonVersionLatestCompatible tracks the latest addon version compatible
+// with the kubernetes version of the cluster.
+const AddonVersionLatestCompatible = "latest-compatible"
+
+type ResolveConflicts string
+
+const (
+	ResolveConflictsNone      ResolveConflicts = "NONE"
+	ResolveConflictsOverwrite ResolveConflicts = "OVERWRITE"
+	ResolveConflictsPreserve  ResolveConflicts = "PRESERVE"
+)
+
+type EksAddon struct {
+	Name string `json:"name"`
+	// Version is an addon version like v1.15.0-eksbuild.2 or latest-compatible.
+	// When empty the default version for the cluster is installed and only
+	// upgraded when a cluster upgrade needs it.
+	Version string `json:"version,omitempty"`
+	// ConfigurationValues is the json configuration of the addon.
+	ConfigurationValues string `json:"configurationValues,omitempty"`
+	// ServiceAccountRole creates an iam role assumed by the addon service account.
+	ServiceAccountRole *AddonServiceAccountRole `json:"serviceAccountRole,omitempty"`
+	// ResolveConflicts tells eks how to handle fields changed outside of eks,
+	// defaults to OVERWRITE.
+	// +kubebuilder:validation:Enum=NONE;OVERWRITE;PRESERVE
+	ResolveConflicts ResolveConflicts `json:"resolveConflicts,omitempty"`
+}
+
+type AddonServiceAccountRole struct {
+	// Namespace of the service account, defaults to kube-system.
+	Namespace      string `json:"namespace,omitempty"`
+	ServiceAccount string `json:"serviceAccount"`
+	// PolicyArns are attached to the role, policies attached outside of the
+	// list are detached.
+	PolicyArns []string `json:"policyArns"`
 }
 
 type AwsCloudInfraConfigStatus struct {
@@ -40,3 +122,18 @@ type EksStatus struct {
 	ClusterId       string `json:"clusterId,omitempty"`
 	OIDCProviderArn string `json:"OIDCProviderArn,omitempty"`
 }
+
+// KarpenterStatus reports the karpenter installation of the dataplane.
+type KarpenterStatus struct {
+	Phase ApplicationPhase `json:"phase,omitempty"`
+	// Version is the installed version of the karpenter chart.
+	Version string `json:"version,omitempty"`
+	// ControllerRoleArn is assumed by the karpenter service account.
+	ControllerRoleArn string `json:"controllerRoleArn,omitempty"`
+	// NodeRole and InstanceProfile are used by the nodes karpenter launches.
+	NodeRole        string `json:"nodeRole,omitempty"`
+	InstanceProfile string `json:"instanceProfile,omitempty"`
+	// InterruptionQueue receives the spot interruption and instance health
+	// events of the nodes.
+	InterruptionQueue string `json:"interruptionQueue,omitempty"`
+}
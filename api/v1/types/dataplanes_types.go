@@ -52,20 +52,85 @@ type DataPlaneStatus struct {
 	//    druid-ng2: ACTIVE
 	//    druid-ng3: DELETING
 	NodegroupStatus map[string]string `json:"nodegroupStatus,omitempty"`
-	// AddonStatus holds a map of addon name & their current status
-	// Example:
-	// addonStatus:
-	//    aws-ebs-csi-driver: CREATING
-	//    coredns:            READY
+	// AddonStatus holds the status of the addons recorded by earlier
+	// versions of baaz, replaced by Addons. Its entries are dropped as their
+	// addons are reconciled.
 	AddonStatus map[string]string `json:"addonStatus,omitempty"`
+	// Addons holds a map of addon name & their current status
+	// Example:
+	// addons:
+	//    aws-ebs-csi-driver:
+	//      status: CREATING
+	//    coredns:
+	//      status: ACTIVE
+	//      version: v1.10.1-eksbuild.7
+	Addons map[string]EksAddonStatus `json:"addons,omitempty"`
 	// AppStatus holds a map of app helm chart name and thier current status
 	// Example:
 	// appStatus:
 	// 	  nginx: Deployed
 	//    druid: Installing
-	AppStatus                  map[string]ApplicationPhase `json:"appStatus,omitempty"`
-	ClusterAutoScalerStatus    ApplicationPhase            `json:"clusterAutoScalerStatus,omitempty"`
-	ClusterAutoScalerPolicyArn string                      `json:"clusterAutoScalerPolicyArn,omitempty"`
+	AppStatus map[string]ApplicationPhase `json:"appStatus,omitempty"`
+	// AppSpecHashes are the spec hashes the apps were last deployed with,
+	// an app is upgraded when its hash changes.
+	AppSpecHashes              map[string]string `json:"appSpecHashes,omitempty"`
+	ClusterAutoScalerStatus    ApplicationPhase  `json:"clusterAutoScalerStatus,omitempty"`
+	ClusterAutoScalerPolicyArn string            `json:"clusterAutoScalerPolicyArn,omitempty"`
+	// Upgrade tracks the version upgrade in progress.
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+	// Karpenter is set when karpenter is the provisioner.
+	Karpenter *KarpenterStatus `json:"karpenter,omitempty"`
+	// AdmissionWebhook is the webhook placing the pods of tenants on their
+	// machine pools.
+	AdmissionWebhook *AdmissionWebhookStatus `json:"admissionWebhook,omitempty"`
+}
+
+type AdmissionWebhookStatus struct {
+	Phase ApplicationPhase `json:"phase,omitempty"`
+	// Image is the baaz image the webhook runs.
+	Image string `json:"image,omitempty"`
+}
+
+type UpgradeStep string
+
+const (
+	UpgradeStepPreflight        UpgradeStep = "Preflight"
+	UpgradeStepControlPlane     UpgradeStep = "ControlPlane"
+	UpgradeStepAddons           UpgradeStep = "Addons"
+	UpgradeStepSystemNodegroup  UpgradeStep = "SystemNodegroup"
+	UpgradeStepTenantNodegroups UpgradeStep = "TenantNodegroups"
+)
+
+const (
+	// UpgradePausedAnnotation set to "true" stops the upgrade after the
+	// running step, removing it resumes the upgrade.
+	UpgradePausedAnnotation = "baaz.dev/upgrade-paused"
+	// UpgradeSkipPreflightAnnotation set to "true" starts the next step even
+	// when the pre-flight checks fail.
+	UpgradeSkipPreflightAnnotation = "baaz.dev/upgrade-skip-preflight"
+)
+
+type UpgradeStatus struct {
+	// FromVersion is the cluster version the upgrade started from.
+	FromVersion   string `json:"fromVersion,omitempty"`
+	TargetVersion string `json:"targetVersion,omitempty"`
+	// StepVersion is the minor version rolled out by the current step.
+	StepVersion string      `json:"stepVersion,omitempty"`
+	Step        UpgradeStep `json:"step,omitempty"`
+	// PreflightFailures lists the checks blocking the upgrade to StepVersion.
+	PreflightFailures []string `json:"preflightFailures,omitempty"`
+	// Nodegroups holds the nodegroups being upgraded to StepVersion and
+	// their eks status, with the pods left to drain from their outdated nodes.
+	Nodegroups map[string]string `json:"nodegroups,omitempty"`
+	StartedAt  metav1.Time       `json:"startedAt,omitempty"`
+}
+
+type EksAddonStatus struct {
+	// Status of the addon, one of the eks addon status ie CREATING, ACTIVE or DEGRADED.
+	Status  string `json:"status,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Health lists the issues eks reports for the addon.
+	Health []string `json:"health,omitempty"`
 }
 
 type DataPlaneConditionType string
@@ -77,6 +142,12 @@ const (
 	NodeGroupCreated            DataPlaneConditionType = "NodeGroupCreated"
 	VersionUpgradeInitiated     DataPlaneConditionType = "VersionUpgradeInitiated"
 	VersionUpgradeSuccessful    DataPlaneConditionType = "VersionUpgradeSuccessful"
+	UpgradePreflightPassed      DataPlaneConditionType = "UpgradePreflightPassed"
+	ControlPlaneUpgraded        DataPlaneConditionType = "ControlPlaneUpgraded"
+	AddonsUpgraded              DataPlaneConditionType = "AddonsUpgraded"
+	SystemNodegroupUpgraded     DataPlaneConditionType = "SystemNodegroupUpgraded"
+	TenantNodegroupsUpgraded    DataPlaneConditionType = "TenantNodegroupsUpgraded"
+	UpgradePaused               DataPlaneConditionType = "UpgradePaused"
 )
 
 // DataPlaneCondition describes the state of a deployment at a certain point.
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type GitSourcePhase string
+
+const (
+	SyncedGS GitSourcePhase = "Synced"
+	// FailedGS sources failed to be pulled or rendered, nothing was
+	// applied, or some entities failed to apply.
+	FailedGS GitSourcePhase = "Failed"
+)
+
+// GitSourceSpec defines the desired state of GitSource
+type GitSourceSpec struct {
+	// Git is the directory of the repository declaring the customers,
+	// dataplanes, tenantsinfra, tenants and applications, in the YAML of
+	// bz create -f. Its Secret is read from the namespace of the GitSource.
+	Git GitManifestSource `json:"git"`
+	// Interval is the time between two pulls of the repository, 1m by
+	// default.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Prune deletes the entities removed from the repository. Without it
+	// they are left in place and reported as orphaned.
+	Prune bool `json:"prune,omitempty"`
+}
+
+// GitSourceStatus defines the observed state of GitSource
+type GitSourceStatus struct {
+	Phase GitSourcePhase `json:"phase,omitempty"`
+	// Revision is the commit synced last.
+	Revision           string       `json:"revision,omitempty"`
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+	LastSyncTime       *metav1.Time `json:"lastSyncTime,omitempty"`
+	Message            string       `json:"message,omitempty"`
+	// Resources are the objects applied for the entities of the repository.
+	Resources []ResourceStatus `json:"resources,omitempty"`
+	// Orphaned are the objects removed from the repository, left in place
+	// without Prune.
+	Orphaned []string `json:"orphaned,omitempty"`
+	// History is the diff applied for the last commits synced, the latest
+	// last.
+	History []GitSyncRecord `json:"history,omitempty"`
+}
+
+// GitSyncRecord is the diff applied for a commit, the objects being named
+// like Kind namespace/name.
+type GitSyncRecord struct {
+	Revision string      `json:"revision"`
+	SyncedAt metav1.Time `json:"syncedAt"`
+	Created  []string    `json:"created,omitempty"`
+	Updated  []string    `json:"updated,omitempty"`
+	Pruned   []string    `json:"pruned,omitempty"`
+	Failed   []string    `json:"failed,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Revision",type="string",JSONPath=".status.revision"
+//+kubebuilder:printcolumn:name="Prune",type="boolean",JSONPath=".spec.prune"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// GitSource is the Schema for the gitsources API
+type GitSource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GitSourceSpec   `json:"spec,omitempty"`
+	Status GitSourceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GitSourceList contains a list of GitSource
+type GitSourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GitSource `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GitSource{}, &GitSourceList{})
+}
@@ -1,5 +1,7 @@
 package v1
 
+import corev1 "k8s.io/api/core/v1"
+
 func (e *DataPlanes) AddCondition(newCon DataPlaneCondition) []DataPlaneCondition {
 	for i, c := range e.Status.Conditions {
 		if c.Type == newCon.Type {
@@ -19,3 +21,17 @@ func (e *DataPlanes) AddCondition(newCon DataPlaneCondition) []DataPlaneConditio
 	e.Status.Conditions = append(e.Status.Conditions, newCon)
 	return e.Status.Conditions
 }
+
+func (e *DataPlanes) HasCondition(conType DataPlaneConditionType) bool {
+	for _, c := range e.Status.Conditions {
+		if c.Type == conType && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesKarpenter tells if karpenter launches the tenant nodes of the dataplane.
+func (e *DataPlanes) UsesKarpenter() bool {
+	return e.Spec.CloudInfra.Eks.Provisioner == KarpenterProvisioner
+}
@@ -1,5 +1,9 @@
 package v1
 
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
 type HTTPApplication struct {
 	ApplicationName string   `json:"name"`
 	TenantName      string   `json:"tenant_name,omitempty"`
@@ -9,4 +13,36 @@ type HTTPApplication struct {
 	RepoURL         string   `json:"repo_url"`
 	Version         string   `json:"version"`
 	Values          []string `json:"values,omitempty"`
+	// ValuesObject are structured values, like the content of a values
+	// file.
+	ValuesObject *apiextensionsv1.JSON `json:"values_object,omitempty"`
+	// ValuesFrom references the Secrets and ConfigMaps of the customer
+	// namespace holding values.
+	ValuesFrom []ValuesReference `json:"values_from,omitempty"`
+	// Auth are the credentials of a private repo or registry.
+	Auth                  *ChartAuth `json:"auth,omitempty"`
+	InsecureSkipTLSVerify bool       `json:"insecure_skip_tls_verify,omitempty"`
+	// DependsOn names the applications installed and ready before this one.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Readiness are the checks the application passes once installed.
+	Readiness []ReadinessCheck `json:"readiness,omitempty"`
+	// Source deploys manifests instead of the chart.
+	Source *ManifestSource `json:"source,omitempty"`
+}
+
+// HTTPBlueprintApplication instantiates an application blueprint for a
+// tenant.
+type HTTPBlueprintApplication struct {
+	BlueprintName string `json:"name"`
+	// Version pins a version of the blueprint, its current version is
+	// followed without any.
+	Version    string                `json:"version,omitempty"`
+	Parameters *apiextensionsv1.JSON `json:"parameters,omitempty"`
+}
+
+// HTTPApplicationRollback rolls an application back to a revision of its
+// release.
+type HTTPApplicationRollback struct {
+	ApplicationName string `json:"name"`
+	Revision        int    `json:"revision"`
 }
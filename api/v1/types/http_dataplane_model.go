@@ -43,4 +43,8 @@ type EKSConfig struct {
 	SubnetIds        []string `json:"subnet_ids"`
 	SecurityGroupIds []string `json:"security_group_ids"`
 	Version          string   `json:"version"`
+	// Addons are the eks managed addons, see EksConfig.Addons.
+	Addons []EksAddon `json:"addons,omitempty"`
+	// Provisioner is nodegroup or karpenter, see EksConfig.Provisioner.
+	Provisioner NodeProvisioner `json:"provisioner,omitempty"`
 }
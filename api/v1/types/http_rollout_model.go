@@ -0,0 +1,22 @@
+package v1
+
+// HTTPRollout upgrades the applications it selects in waves.
+type HTTPRollout struct {
+	Name string `json:"name"`
+	// Labels, Customers, Dataplanes and SaaSTypes select the applications,
+	// the ones left empty select any.
+	Labels     map[string]string `json:"labels,omitempty"`
+	Customers  []string          `json:"customers,omitempty"`
+	Dataplanes []string          `json:"dataplanes,omitempty"`
+	SaaSTypes  []SaaSTypes       `json:"saas_types,omitempty"`
+	// App is the app upgraded to the chart version Version, Blueprint the
+	// blueprint whose instances are moved to its version Version instead.
+	App               string `json:"app,omitempty"`
+	Blueprint         string `json:"blueprint,omitempty"`
+	Version           string `json:"version"`
+	CanaryPercent     int    `json:"canary_percent,omitempty"`
+	BatchSize         int    `json:"batch_size,omitempty"`
+	MaxFailurePercent int    `json:"max_failure_percent,omitempty"`
+	// ProgressDeadline is a duration, like 15m.
+	ProgressDeadline string `json:"progress_deadline,omitempty"`
+}
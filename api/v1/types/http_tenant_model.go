@@ -20,11 +20,23 @@ const (
 )
 
 type NetworkSecurity struct {
-	InterNamespaceTraffic NetworkRules `json:"inter_namespace_traffic"`
-	AllowedNamespaces     []string     `json:"allowed_namespaces"`
+	InterNamespaceTraffic       NetworkRules `json:"inter_namespace_traffic"`
+	AllowedNamespaces           []string     `json:"allowed_namespaces"`
+	IngressControllerNamespaces []string     `json:"ingress_controller_namespaces,omitempty"`
+	MonitoringNamespaces        []string     `json:"monitoring_namespaces,omitempty"`
+	Egress                      *HTTPEgress  `json:"egress,omitempty"`
+}
+
+type HTTPEgress struct {
+	DefaultDeny  bool     `json:"default_deny"`
+	DenyDNS      bool     `json:"deny_dns,omitempty"`
+	AllowedCIDRs []string `json:"allowed_cidrs,omitempty"`
+	AllowedFQDNs []string `json:"allowed_fqdns,omitempty"`
 }
 
 type HTTPTenant struct {
 	Application     HTTPTenantApplication `json:"application"`
 	NetworkSecurity NetworkSecurity       `json:"network_security,omitempty"`
+	Quota           *QuotaSpec            `json:"quota,omitempty"`
+	Identity        *TenantIdentity       `json:"identity,omitempty"`
 }
@@ -2,4 +2,5 @@ package v1
 
 type HTTPTenantSizes struct {
 	MachineSpec []MachineSpec `json:"machine_pool"`
+	Quota       *QuotaSpec    `json:"quota,omitempty"`
 }
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type RolloutPhase string
+
+const (
+	PendingR     RolloutPhase = "Pending"
+	ProgressingR RolloutPhase = "Progressing"
+	// PausedR rollouts update no more Applications, paused by their spec
+	// or by too many failures.
+	PausedR    RolloutPhase = "Paused"
+	CompletedR RolloutPhase = "Completed"
+)
+
+type RolloutTargetPhase string
+
+const (
+	// PendingRT Applications are not updated yet.
+	PendingRT  RolloutTargetPhase = "Pending"
+	UpdatingRT RolloutTargetPhase = "Updating"
+	HealthyRT  RolloutTargetPhase = "Healthy"
+	FailedRT   RolloutTargetPhase = "Failed"
+)
+
+// RolloutSpec upgrades the Applications it selects in waves, each one once
+// the Applications of the previous waves are healthy or failed.
+type RolloutSpec struct {
+	Selector RolloutSelector `json:"selector"`
+	Target   RolloutTarget   `json:"target"`
+	Strategy RolloutStrategy `json:"strategy,omitempty"`
+	// Paused stops updating Applications, the ones updated are left as
+	// they are.
+	Paused bool `json:"paused,omitempty"`
+}
+
+// RolloutSelector selects the Applications matching all of its fields, the
+// fields left empty match any Application. The Applications managed by a
+// GitSource are never selected, their versions are the ones of their
+// repository.
+type RolloutSelector struct {
+	Labels     *metav1.LabelSelector `json:"labels,omitempty"`
+	Customers  []string              `json:"customers,omitempty"`
+	Dataplanes []string              `json:"dataplanes,omitempty"`
+	// SaaSTypes match the saas type of the customers.
+	SaaSTypes []SaaSTypes `json:"saasTypes,omitempty"`
+}
+
+type RolloutTarget struct {
+	// App is the app of the Applications upgraded to the chart version
+	// Version. Applications without it are not selected.
+	App string `json:"app,omitempty"`
+	// Blueprint pins the instances of the blueprint to its version Version
+	// instead. Applications instantiating another one are not selected.
+	Blueprint string `json:"blueprint,omitempty"`
+	Version   string `json:"version"`
+}
+
+type RolloutStrategy struct {
+	// CanaryPercent is the percentage of the Applications updated in the
+	// first wave, rounded up. No canary wave is made without any.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	CanaryPercent int `json:"canaryPercent,omitempty"`
+	// BatchSize is the number of Applications updated in each wave after
+	// the canary, all of them by default.
+	// +kubebuilder:validation:Minimum=0
+	BatchSize int `json:"batchSize,omitempty"`
+	// MaxFailurePercent is the percentage of the updated Applications
+	// allowed to fail, the rollout pauses over it. Any failure pauses it
+	// by default.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	MaxFailurePercent int `json:"maxFailurePercent,omitempty"`
+	// ProgressDeadline is the time an updated Application has to become
+	// healthy before it counts as failed, 10m by default.
+	ProgressDeadline *metav1.Duration `json:"progressDeadline,omitempty"`
+}
+
+type RolloutStatus struct {
+	Phase RolloutPhase `json:"phase,omitempty"`
+	// Wave is the wave in progress, the canary being wave 0.
+	Wave  int `json:"wave,omitempty"`
+	Waves int `json:"waves,omitempty"`
+	// Total, Updated, Healthy and Failed count the Applications selected,
+	// updated, healthy and failed.
+	Total   int `json:"total,omitempty"`
+	Updated int `json:"updated,omitempty"`
+	Healthy int `json:"healthy,omitempty"`
+	Failed  int `json:"failed,omitempty"`
+	// Message explains why the rollout is paused.
+	Message string `json:"message,omitempty"`
+	// Targets reports the progress of each Application, by customer and
+	// tenant.
+	Targets []RolloutTargetStatus `json:"targets,omitempty"`
+}
+
+type RolloutTargetStatus struct {
+	Customer    string `json:"customer"`
+	Application string `json:"application"`
+	Tenant      string `json:"tenant,omitempty"`
+	// Wave is set when the rollout starts, the Applications selected later
+	// are in the waves after.
+	Wave  int                `json:"wave"`
+	Phase RolloutTargetPhase `json:"phase"`
+	// UpdatedAt is when the Application was updated, or found updated.
+	UpdatedAt *metav1.Time `json:"updatedAt,omitempty"`
+	// Message explains why the Application failed.
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Wave",type="integer",JSONPath=".status.wave"
+//+kubebuilder:printcolumn:name="Healthy",type="integer",JSONPath=".status.healthy"
+//+kubebuilder:printcolumn:name="Total",type="integer",JSONPath=".status.total"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Rollout is the Schema for the rollouts API
+type Rollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RolloutSpec   `json:"spec,omitempty"`
+	Status RolloutStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RolloutList contains a list of Rollout
+type RolloutList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Rollout `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Rollout{}, &RolloutList{})
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -23,6 +24,51 @@ type TenantsSpec struct {
 	TenantConfig []TenantApplicationConfig `json:"config"`
 	// Isolation
 	Isolation IsolationConfig `json:"isolation,omitempty"`
+	// Migration configures the move of the tenant between sizes.
+	Migration MigrationPolicy `json:"migration,omitempty"`
+	// Quota limits the resources of the namespace of the tenant. Its
+	// entries override the quota of the sizes of the tenant.
+	Quota *QuotaSpec `json:"quota,omitempty"`
+	// Identity grants the service account of the tenant an iam role, assumed
+	// by its pods through IRSA.
+	Identity *TenantIdentity `json:"identity,omitempty"`
+}
+
+// TenantIdentity declares the aws permissions of the pods of a tenant.
+type TenantIdentity struct {
+	// IAMPolicy is an iam policy document, in JSON, inlined in the role. It
+	// may only allow the actions and resources the operator allows tenants.
+	IAMPolicy string `json:"iamPolicy,omitempty"`
+	// PolicyArns are the managed policies attached to the role.
+	PolicyArns []string `json:"policyArns,omitempty"`
+}
+
+// QuotaSpec limits the resources of the namespace of a tenant.
+type QuotaSpec struct {
+	// Hard limits the compute, storage and object counts of the namespace,
+	// like requests.cpu, limits.memory, requests.storage, pods or
+	// persistentvolumeclaims.
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+	// PriorityClasses limits the pods of each priority class.
+	PriorityClasses map[string]corev1.ResourceList `json:"priorityClasses,omitempty"`
+	// Containers sets the default and maximum resources of the containers.
+	Containers *ContainerLimits `json:"containers,omitempty"`
+}
+
+type ContainerLimits struct {
+	// Default are the limits of the containers without any.
+	Default corev1.ResourceList `json:"default,omitempty"`
+	// DefaultRequest are the requests of the containers without any.
+	DefaultRequest corev1.ResourceList `json:"defaultRequest,omitempty"`
+	Max            corev1.ResourceList `json:"max,omitempty"`
+}
+
+type MigrationPolicy struct {
+	// RetireUnusedSize removes the size a tenant moved out of from the
+	// TenantsInfra, draining and deleting its machine pools, when no tenant
+	// runs on it anymore. The size is kept by default, and in the
+	// TenantsInfra managed by a GitSource.
+	RetireUnusedSize bool `json:"retireUnusedSize,omitempty"`
 }
 
 type IsolationConfig struct {
@@ -35,8 +81,46 @@ type MachineConfig struct {
 }
 
 type NetworkConfig struct {
-	Enabled           bool     `json:"enabled,omitempty"`
-	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+	// Enabled denies the traffic from other namespaces, like
+	// InterNamespaceTraffic Deny.
+	Enabled bool `json:"enabled,omitempty"`
+	// InterNamespaceTraffic allows or denies the traffic from the other
+	// namespaces of the dataplane to the tenant. The pods of the tenant, of
+	// AllowedNamespaces, IngressControllerNamespaces and
+	// MonitoringNamespaces are always allowed.
+	// +kubebuilder:validation:Enum=Allow;Deny
+	InterNamespaceTraffic NetworkRules `json:"interNamespaceTraffic,omitempty"`
+	AllowedNamespaces     []string     `json:"allowedNamespaces,omitempty"`
+	// IngressControllerNamespaces run the ingress controllers exposing the
+	// tenant.
+	IngressControllerNamespaces []string `json:"ingressControllerNamespaces,omitempty"`
+	// MonitoringNamespaces run the monitoring scraping the tenant.
+	MonitoringNamespaces []string `json:"monitoringNamespaces,omitempty"`
+	// Egress controls the traffic leaving the pods of the tenant.
+	Egress *EgressConfig `json:"egress,omitempty"`
+}
+
+// DeniesInterNamespaceTraffic tells if the traffic from other namespaces is
+// denied.
+func (n NetworkConfig) DeniesInterNamespaceTraffic() bool {
+	if n.InterNamespaceTraffic != "" {
+		return n.InterNamespaceTraffic == Deny
+	}
+	return n.Enabled
+}
+
+type EgressConfig struct {
+	// DefaultDeny denies the egress of the pods of the tenant but to the
+	// tenant itself, dns and the allowed destinations.
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+	// DenyDNS also denies the queries to the dns of the cluster.
+	DenyDNS bool `json:"denyDNS,omitempty"`
+	// AllowedCIDRs are the ip blocks the tenant may reach.
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+	// AllowedFQDNs are the hosts the tenant may reach. Network policies
+	// only match addresses, the hosts are resolved by baaz on every
+	// reconcile.
+	AllowedFQDNs []string `json:"allowedFQDNs,omitempty"`
 }
 
 type TenantApplicationConfig struct {
@@ -48,6 +132,64 @@ type TenantApplicationConfig struct {
 type TenantsStatus struct {
 	Phase           TenantPhase       `json:"phase,omitempty"`
 	NodegroupStatus map[string]string `json:"machinePoolStatus,omitempty"`
+	// Sizes is the size each app type of the tenant runs on.
+	Sizes map[ApplicationType]string `json:"sizes,omitempty"`
+	// Migrations reports the moves of app types to the size of the spec.
+	Migrations map[ApplicationType]SizeMigration `json:"migrations,omitempty"`
+	// Quota reports the usage of the namespace against its quota.
+	Quota *QuotaStatus `json:"quota,omitempty"`
+	// Identity reports the service account of the tenant and its role.
+	Identity *IdentityStatus `json:"identity,omitempty"`
+	// FQDNAddresses are the addresses the allowed FQDNs resolved to. They
+	// are kept when a lookup fails and merged with the new ones, as DNS
+	// answers rotate.
+	FQDNAddresses map[string][]string `json:"fqdnAddresses,omitempty"`
+}
+
+type IdentityStatus struct {
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	RoleArn        string `json:"roleArn,omitempty"`
+}
+
+type QuotaUsage struct {
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+	Used corev1.ResourceList `json:"used,omitempty"`
+}
+
+type QuotaStatus struct {
+	QuotaUsage `json:",inline"`
+	// PriorityClasses reports the usage of the pods of each priority class.
+	PriorityClasses map[string]QuotaUsage `json:"priorityClasses,omitempty"`
+}
+
+type MigrationPhase string
+
+const (
+	// MigrationProvisioning waits for the machine pools of the new size.
+	MigrationProvisioning MigrationPhase = "Provisioning"
+	// MigrationMoving moves the workloads and their pods to the new size.
+	MigrationMoving MigrationPhase = "Moving"
+	// MigrationRetiring removes the size moved out of when it is unused.
+	MigrationRetiring MigrationPhase = "Retiring"
+	MigrationFailed   MigrationPhase = "Failed"
+)
+
+// SizeMigration moves an app type from a size to another. Setting the size
+// of the spec back to From while the pods are moving rolls the migration
+// back.
+type SizeMigration struct {
+	From  string         `json:"from"`
+	To    string         `json:"to"`
+	Phase MigrationPhase `json:"phase"`
+	// Rollback is set while moving back to the size of the app type before
+	// the migration.
+	Rollback bool `json:"rollback,omitempty"`
+	// PendingPods counts the pods of the tenant left on the old size.
+	PendingPods int `json:"pendingPods,omitempty"`
+	// BlockedPods lists the pods a PodDisruptionBudget keeps on the old size.
+	BlockedPods []string    `json:"blockedPods,omitempty"`
+	Message     string      `json:"message,omitempty"`
+	StartedAt   metav1.Time `json:"startedAt,omitempty"`
 }
 
 //+kubebuilder:object:root=true
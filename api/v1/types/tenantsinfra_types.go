@@ -5,12 +5,30 @@ import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 type TenantsInfraSpec struct {
 	Dataplane   string                 `json:"dataplane"`
 	TenantSizes map[string]TenantSizes `json:"tenantSizes"`
+	// Drain configures the drain of the nodes of removed machine pools.
+	Drain DrainPolicy `json:"drain,omitempty"`
+}
+
+type DrainPolicy struct {
+	// Timeout bounds the drain of a node, pods still running after it are
+	// terminated with the nodegroup. Defaults to 10m.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// GracePeriodSeconds overrides the termination grace period of the
+	// evicted pods.
+	// +kubebuilder:validation:Minimum:=0
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
 }
 
 type TenantSizes struct {
+	// +listType=map
+	// +listMapKey=name
 	MachineSpec []MachineSpec `json:"machinePool"`
+	// Quota is the quota of the tenants running on the size, unless they
+	// set their own.
+	Quota *QuotaSpec `json:"quota,omitempty"`
 }
 
+// +kubebuilder:validation:XValidation:rule="has(self.amiFamily) == has(oldSelf.amiFamily)",message="amiFamily is immutable"
 type MachineSpec struct {
 	Name       string            `json:"name"`
 	NodeLabels map[string]string `json:"labels"`
@@ -25,17 +43,189 @@ type MachineSpec struct {
 	// +kubebuilder:validation:Enum:=low-priority;default-priority
 	// +kubebuilder:default=default-priority
 	Type MachineType `json:"type"`
+	// AmiFamily selects the image of the nodes, Custom runs AmiID. Defaults
+	// to the eks default image. It can not change once the pool is created.
+	// +kubebuilder:validation:Enum:=AL2;AL2023;Bottlerocket;Custom
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="amiFamily is immutable"
+	AmiFamily AmiFamily `json:"amiFamily,omitempty"`
+	// AmiID is the image of the Custom ami family. Its user data must
+	// bootstrap the node, eks does not add its own bootstrap.
+	AmiID string `json:"amiId,omitempty"`
+	// Disk configures the root volume of the nodes, the data volume on
+	// Bottlerocket.
+	Disk *DiskSpec `json:"disk,omitempty"`
+	// Metadata configures the instance metadata service of the nodes.
+	Metadata *MetadataSpec `json:"metadata,omitempty"`
+	// SecurityGroupIds are attached to the nodes with the cluster security
+	// group.
+	SecurityGroupIds []string `json:"securityGroupIds,omitempty"`
+	// UserData is run at boot after the eks bootstrap: a shell script or a
+	// mime multipart document, toml settings on Bottlerocket.
+	UserData string `json:"userData,omitempty"`
+	// SSHKeyName is the ec2 key pair allowed to ssh into the nodes, port 22
+	// is opened through SecurityGroupIds.
+	SSHKeyName string `json:"sshKeyName,omitempty"`
+}
+
+type AmiFamily string
+
+const (
+	AmiFamilyAL2          AmiFamily = "AL2"
+	AmiFamilyAL2023       AmiFamily = "AL2023"
+	AmiFamilyBottlerocket AmiFamily = "Bottlerocket"
+	AmiFamilyCustom       AmiFamily = "Custom"
+)
+
+type DiskSpec struct {
+	// SizeGiB defaults to 20.
+	// +kubebuilder:validation:Minimum:=1
+	SizeGiB int32 `json:"sizeGiB,omitempty"`
+	// Type defaults to gp3.
+	// +kubebuilder:validation:Enum:=gp2;gp3;io1;io2
+	Type string `json:"type,omitempty"`
+	// Iops of gp3, io1 and io2 volumes.
+	Iops int32 `json:"iops,omitempty"`
+	// Throughput of gp3 volumes in MiB/s.
+	Throughput int32 `json:"throughput,omitempty"`
+	// KmsKeyID encrypts the volume with a customer managed key, volumes
+	// are encrypted with the aws managed key otherwise.
+	KmsKeyID string `json:"kmsKeyId,omitempty"`
+}
+
+type MetadataSpec struct {
+	// HttpTokens is required to enforce IMDSv2, defaults to required.
+	// +kubebuilder:validation:Enum:=required;optional
+	HttpTokens string `json:"httpTokens,omitempty"`
+	// HopLimit defaults to 2 so pods reach the metadata service.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=64
+	HopLimit int32 `json:"hopLimit,omitempty"`
+}
+
+const (
+	// DefaultDiskSizeGiB, DefaultDiskType, DefaultHttpTokens and
+	// DefaultHopLimit are the disk and instance metadata defaults of the
+	// nodes, launched by nodegroups or by karpenter.
+	DefaultDiskSizeGiB = 20
+	DefaultDiskType    = "gp3"
+	DefaultHttpTokens  = "required"
+	DefaultHopLimit    = 2
+)
+
+// DiskWithDefaults returns the disk of the nodes with the defaults set.
+func (m MachineSpec) DiskWithDefaults() DiskSpec {
+	disk := DiskSpec{}
+	if m.Disk != nil {
+		disk = *m.Disk
+	}
+	if disk.SizeGiB == 0 {
+		disk.SizeGiB = DefaultDiskSizeGiB
+	}
+	if disk.Type == "" {
+		disk.Type = DefaultDiskType
+	}
+	return disk
+}
+
+// DiskDeviceName is the device of the disk. Bottlerocket keeps the
+// containers on its data volume, the root volume only holds the os.
+func (m MachineSpec) DiskDeviceName() string {
+	if m.AmiFamily == AmiFamilyBottlerocket {
+		return "/dev/xvdb"
+	}
+	return "/dev/xvda"
+}
+
+// MetadataWithDefaults returns the instance metadata settings of the nodes
+// with the defaults set: IMDSv2 with a hop limit of 2.
+func (m MachineSpec) MetadataWithDefaults() MetadataSpec {
+	metadata := MetadataSpec{}
+	if m.Metadata != nil {
+		metadata = *m.Metadata
+	}
+	if metadata.HttpTokens == "" {
+		metadata.HttpTokens = DefaultHttpTokens
+	}
+	if metadata.HopLimit == 0 {
+		metadata.HopLimit = DefaultHopLimit
+	}
+	return metadata
+}
+
+// UsesLaunchTemplate tells if the nodes need a launch template, the ami
+// family alone is set on the nodegroup.
+func (m MachineSpec) UsesLaunchTemplate() bool {
+	return m.AmiFamily == AmiFamilyCustom ||
+		m.Disk != nil ||
+		m.Metadata != nil ||
+		len(m.SecurityGroupIds) > 0 ||
+		m.UserData != "" ||
+		m.SSHKeyName != ""
 }
 
 // TenantsStatus defines the observed state of Tenants
 type TenantsInfraStatus struct {
 	Phase           TenantPhase                `json:"phase,omitempty"`
 	NodegroupStatus map[string]NodegroupStatus `json:"machinePoolStatus,omitempty"`
+	// NodePoolStatus reports the karpenter NodePool of each machine pool
+	// when karpenter is the provisioner of the dataplane.
+	NodePoolStatus map[string]NodePoolStatus `json:"nodePoolStatus,omitempty"`
+	// Replacements reports the nodegroups replaced by a nodegroup of the
+	// new instance type of their machine pool, keyed by the replaced
+	// nodegroup.
+	Replacements map[string]NodegroupReplacement `json:"replacements,omitempty"`
+}
+
+type ReplacementPhase string
+
+const (
+	// ReplacementProvisioning waits for the nodes of the new nodegroup to
+	// be ready.
+	ReplacementProvisioning ReplacementPhase = "Provisioning"
+	ReplacementDraining     ReplacementPhase = "Draining"
+	ReplacementDeleting     ReplacementPhase = "Deleting"
+)
+
+type NodegroupReplacement struct {
+	// To is the nodegroup replacing the nodegroup.
+	To        string           `json:"to"`
+	Phase     ReplacementPhase `json:"phase"`
+	StartedAt metav1.Time      `json:"startedAt,omitempty"`
+}
+
+type NodePoolStatus struct {
+	Ready bool `json:"ready"`
+	// Nodes is the number of nodes launched for the NodePool.
+	Nodes int64 `json:"nodes,omitempty"`
+	// Message explains why the NodePool is not ready.
+	Message string `json:"message,omitempty"`
 }
 
 type NodegroupStatus struct {
 	Status string `json:"status,omitempty"`
 	Subnet string `json:"subnet,omitempty"`
+	// Nodes reports the drain of each node while the nodegroup is removed.
+	Nodes map[string]NodeDrainStatus `json:"nodes,omitempty"`
+	// MachinePool is the machine pool run by the nodegroup, as
+	// tenantSize/machinePool.
+	MachinePool string `json:"machinePool,omitempty"`
+}
+
+type NodeDrainPhase string
+
+const (
+	NodeDraining NodeDrainPhase = "Draining"
+	NodeDrained  NodeDrainPhase = "Drained"
+	NodeTimedOut NodeDrainPhase = "TimedOut"
+)
+
+type NodeDrainStatus struct {
+	Phase NodeDrainPhase `json:"phase,omitempty"`
+	// PendingPods counts the pods left on the node.
+	PendingPods int `json:"pendingPods,omitempty"`
+	// BlockedPods lists the pods a PodDisruptionBudget keeps on the node.
+	BlockedPods []string    `json:"blockedPods,omitempty"`
+	StartedAt   metav1.Time `json:"startedAt,omitempty"`
 }
 
 //+kubebuilder:object:root=true
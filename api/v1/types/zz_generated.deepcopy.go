@@ -6,6 +6,10 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	resource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -24,10 +28,83 @@ func (in *AWSAuthSecretRef) DeepCopy() *AWSAuthSecretRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddonServiceAccountRole) DeepCopyInto(out *AddonServiceAccountRole) {
+	*out = *in
+	if in.PolicyArns != nil {
+		in, out := &in.PolicyArns, &out.PolicyArns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddonServiceAccountRole.
+func (in *AddonServiceAccountRole) DeepCopy() *AddonServiceAccountRole {
+	if in == nil {
+		return nil
+	}
+	out := new(AddonServiceAccountRole)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionWebhookStatus) DeepCopyInto(out *AdmissionWebhookStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionWebhookStatus.
+func (in *AdmissionWebhookStatus) DeepCopy() *AdmissionWebhookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionWebhookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppHealth) DeepCopyInto(out *AppHealth) {
+	*out = *in
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceHealth, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppHealth.
+func (in *AppHealth) DeepCopy() *AppHealth {
+	if in == nil {
+		return nil
+	}
+	out := new(AppHealth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppSpec) DeepCopyInto(out *AppSpec) {
 	*out = *in
 	in.Spec.DeepCopyInto(&out.Spec)
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(ManifestSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = make([]ReadinessCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppSpec.
@@ -40,6 +117,86 @@ func (in *AppSpec) DeepCopy() *AppSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationBlueprint) DeepCopyInto(out *ApplicationBlueprint) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationBlueprint.
+func (in *ApplicationBlueprint) DeepCopy() *ApplicationBlueprint {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationBlueprint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ApplicationBlueprint) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationBlueprintList) DeepCopyInto(out *ApplicationBlueprintList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ApplicationBlueprint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationBlueprintList.
+func (in *ApplicationBlueprintList) DeepCopy() *ApplicationBlueprintList {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationBlueprintList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ApplicationBlueprintList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationBlueprintSpec) DeepCopyInto(out *ApplicationBlueprintSpec) {
+	*out = *in
+	if in.Versions != nil {
+		in, out := &in.Versions, &out.Versions
+		*out = make([]BlueprintVersion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationBlueprintSpec.
+func (in *ApplicationBlueprintSpec) DeepCopy() *ApplicationBlueprintSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationBlueprintSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationSpec) DeepCopyInto(out *ApplicationSpec) {
 	*out = *in
@@ -50,6 +207,12 @@ func (in *ApplicationSpec) DeepCopyInto(out *ApplicationSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Blueprint != nil {
+		in, out := &in.Blueprint, &out.Blueprint
+		*out = new(BlueprintRef)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Upgrade = in.Upgrade
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationSpec.
@@ -73,6 +236,39 @@ func (in *ApplicationStatus) DeepCopyInto(out *ApplicationStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.Releases != nil {
+		in, out := &in.Releases, &out.Releases
+		*out = make(map[string]ReleaseStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Reasons != nil {
+		in, out := &in.Reasons, &out.Reasons
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Blueprint != nil {
+		in, out := &in.Blueprint, &out.Blueprint
+		*out = new(BlueprintStatus)
+		**out = **in
+	}
+	if in.Manifests != nil {
+		in, out := &in.Manifests, &out.Manifests
+		*out = make(map[string]ManifestStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Health != nil {
+		in, out := &in.Health, &out.Health
+		*out = make(map[string]AppHealth, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationStatus.
@@ -207,6 +403,143 @@ func (in *AwsCloudInfraConfigStatus) DeepCopy() *AwsCloudInfraConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlueprintApplication) DeepCopyInto(out *BlueprintApplication) {
+	*out = *in
+	in.AppSpec.DeepCopyInto(&out.AppSpec)
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlueprintApplication.
+func (in *BlueprintApplication) DeepCopy() *BlueprintApplication {
+	if in == nil {
+		return nil
+	}
+	out := new(BlueprintApplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlueprintRef) DeepCopyInto(out *BlueprintRef) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlueprintRef.
+func (in *BlueprintRef) DeepCopy() *BlueprintRef {
+	if in == nil {
+		return nil
+	}
+	out := new(BlueprintRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlueprintSize) DeepCopyInto(out *BlueprintSize) {
+	*out = *in
+	if in.Schema != nil {
+		in, out := &in.Schema, &out.Schema
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Defaults != nil {
+		in, out := &in.Defaults, &out.Defaults
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlueprintSize.
+func (in *BlueprintSize) DeepCopy() *BlueprintSize {
+	if in == nil {
+		return nil
+	}
+	out := new(BlueprintSize)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlueprintStatus) DeepCopyInto(out *BlueprintStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlueprintStatus.
+func (in *BlueprintStatus) DeepCopy() *BlueprintStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BlueprintStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlueprintVersion) DeepCopyInto(out *BlueprintVersion) {
+	*out = *in
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]BlueprintApplication, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Schema != nil {
+		in, out := &in.Schema, &out.Schema
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Defaults != nil {
+		in, out := &in.Defaults, &out.Defaults
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sizes != nil {
+		in, out := &in.Sizes, &out.Sizes
+		*out = make(map[string]BlueprintSize, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlueprintVersion.
+func (in *BlueprintVersion) DeepCopy() *BlueprintVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(BlueprintVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChartAuth) DeepCopyInto(out *ChartAuth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartAuth.
+func (in *ChartAuth) DeepCopy() *ChartAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(ChartAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ChartSpec) DeepCopyInto(out *ChartSpec) {
 	*out = *in
@@ -215,6 +548,21 @@ func (in *ChartSpec) DeepCopyInto(out *ChartSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ValuesObject != nil {
+		in, out := &in.ValuesObject, &out.ValuesObject
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ValuesFrom != nil {
+		in, out := &in.ValuesFrom, &out.ValuesFrom
+		*out = make([]ValuesReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(ChartAuth)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartSpec.
@@ -227,6 +575,21 @@ func (in *ChartSpec) DeepCopy() *ChartSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChartUpgradePolicy) DeepCopyInto(out *ChartUpgradePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartUpgradePolicy.
+func (in *ChartUpgradePolicy) DeepCopy() *ChartUpgradePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ChartUpgradePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudAuth) DeepCopyInto(out *CloudAuth) {
 	*out = *in
@@ -275,6 +638,42 @@ func (in *CloudInfraStatus) DeepCopy() *CloudInfraStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerLimits) DeepCopyInto(out *ContainerLimits) {
+	*out = *in
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.DefaultRequest != nil {
+		in, out := &in.DefaultRequest, &out.DefaultRequest
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerLimits.
+func (in *ContainerLimits) DeepCopy() *ContainerLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Customer) DeepCopyInto(out *Customer) {
 	*out = *in
@@ -386,6 +785,13 @@ func (in *DataPlaneStatus) DeepCopyInto(out *DataPlaneStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.Addons != nil {
+		in, out := &in.Addons, &out.Addons
+		*out = make(map[string]EksAddonStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	if in.AppStatus != nil {
 		in, out := &in.AppStatus, &out.AppStatus
 		*out = make(map[string]ApplicationPhase, len(*in))
@@ -393,10 +799,32 @@ func (in *DataPlaneStatus) DeepCopyInto(out *DataPlaneStatus) {
 			(*out)[key] = val
 		}
 	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataPlaneStatus.
-func (in *DataPlaneStatus) DeepCopy() *DataPlaneStatus {
+	if in.AppSpecHashes != nil {
+		in, out := &in.AppSpecHashes, &out.AppSpecHashes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(UpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Karpenter != nil {
+		in, out := &in.Karpenter, &out.Karpenter
+		*out = new(KarpenterStatus)
+		**out = **in
+	}
+	if in.AdmissionWebhook != nil {
+		in, out := &in.AdmissionWebhook, &out.AdmissionWebhook
+		*out = new(AdmissionWebhookStatus)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataPlaneStatus.
+func (in *DataPlaneStatus) DeepCopy() *DataPlaneStatus {
 	if in == nil {
 		return nil
 	}
@@ -433,297 +861,1372 @@ func (in *DataPlanes) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DataPlanesList) DeepCopyInto(out *DataPlanesList) {
+func (in *DataPlanesList) DeepCopyInto(out *DataPlanesList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DataPlanes, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataPlanesList.
+func (in *DataPlanesList) DeepCopy() *DataPlanesList {
+	if in == nil {
+		return nil
+	}
+	out := new(DataPlanesList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DataPlanesList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskSpec) DeepCopyInto(out *DiskSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskSpec.
+func (in *DiskSpec) DeepCopy() *DiskSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainPolicy) DeepCopyInto(out *DrainPolicy) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainPolicy.
+func (in *DrainPolicy) DeepCopy() *DrainPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EKSConfig) DeepCopyInto(out *EKSConfig) {
+	*out = *in
+	if in.SubnetIds != nil {
+		in, out := &in.SubnetIds, &out.SubnetIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityGroupIds != nil {
+		in, out := &in.SecurityGroupIds, &out.SecurityGroupIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Addons != nil {
+		in, out := &in.Addons, &out.Addons
+		*out = make([]EksAddon, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EKSConfig.
+func (in *EKSConfig) DeepCopy() *EKSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EKSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressConfig) DeepCopyInto(out *EgressConfig) {
+	*out = *in
+	if in.AllowedCIDRs != nil {
+		in, out := &in.AllowedCIDRs, &out.AllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedFQDNs != nil {
+		in, out := &in.AllowedFQDNs, &out.AllowedFQDNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressConfig.
+func (in *EgressConfig) DeepCopy() *EgressConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EksAddon) DeepCopyInto(out *EksAddon) {
+	*out = *in
+	if in.ServiceAccountRole != nil {
+		in, out := &in.ServiceAccountRole, &out.ServiceAccountRole
+		*out = new(AddonServiceAccountRole)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EksAddon.
+func (in *EksAddon) DeepCopy() *EksAddon {
+	if in == nil {
+		return nil
+	}
+	out := new(EksAddon)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EksAddonStatus) DeepCopyInto(out *EksAddonStatus) {
+	*out = *in
+	if in.Health != nil {
+		in, out := &in.Health, &out.Health
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EksAddonStatus.
+func (in *EksAddonStatus) DeepCopy() *EksAddonStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EksAddonStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EksConfig) DeepCopyInto(out *EksConfig) {
+	*out = *in
+	if in.SubnetIds != nil {
+		in, out := &in.SubnetIds, &out.SubnetIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityGroupIds != nil {
+		in, out := &in.SecurityGroupIds, &out.SecurityGroupIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Addons != nil {
+		in, out := &in.Addons, &out.Addons
+		*out = make([]EksAddon, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
+	out.Karpenter = in.Karpenter
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EksConfig.
+func (in *EksConfig) DeepCopy() *EksConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EksConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EksStatus) DeepCopyInto(out *EksStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EksStatus.
+func (in *EksStatus) DeepCopy() *EksStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EksStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitManifestSource) DeepCopyInto(out *GitManifestSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitManifestSource.
+func (in *GitManifestSource) DeepCopy() *GitManifestSource {
+	if in == nil {
+		return nil
+	}
+	out := new(GitManifestSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSource) DeepCopyInto(out *GitSource) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSource.
+func (in *GitSource) DeepCopy() *GitSource {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GitSource) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSourceList) DeepCopyInto(out *GitSourceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GitSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSourceList.
+func (in *GitSourceList) DeepCopy() *GitSourceList {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSourceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GitSourceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSourceSpec) DeepCopyInto(out *GitSourceSpec) {
+	*out = *in
+	out.Git = in.Git
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSourceSpec.
+func (in *GitSourceSpec) DeepCopy() *GitSourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSourceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSourceStatus) DeepCopyInto(out *GitSourceStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Orphaned != nil {
+		in, out := &in.Orphaned, &out.Orphaned
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]GitSyncRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSourceStatus.
+func (in *GitSourceStatus) DeepCopy() *GitSourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSyncRecord) DeepCopyInto(out *GitSyncRecord) {
+	*out = *in
+	in.SyncedAt.DeepCopyInto(&out.SyncedAt)
+	if in.Created != nil {
+		in, out := &in.Created, &out.Created
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Updated != nil {
+		in, out := &in.Updated, &out.Updated
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pruned != nil {
+		in, out := &in.Pruned, &out.Pruned
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Failed != nil {
+		in, out := &in.Failed, &out.Failed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSyncRecord.
+func (in *GitSyncRecord) DeepCopy() *GitSyncRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSyncRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPApplication) DeepCopyInto(out *HTTPApplication) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ValuesObject != nil {
+		in, out := &in.ValuesObject, &out.ValuesObject
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ValuesFrom != nil {
+		in, out := &in.ValuesFrom, &out.ValuesFrom
+		*out = make([]ValuesReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(ChartAuth)
+		**out = **in
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = make([]ReadinessCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(ManifestSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPApplication.
+func (in *HTTPApplication) DeepCopy() *HTTPApplication {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPApplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPApplicationRollback) DeepCopyInto(out *HTTPApplicationRollback) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPApplicationRollback.
+func (in *HTTPApplicationRollback) DeepCopy() *HTTPApplicationRollback {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPApplicationRollback)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPBlueprintApplication) DeepCopyInto(out *HTTPBlueprintApplication) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPBlueprintApplication.
+func (in *HTTPBlueprintApplication) DeepCopy() *HTTPBlueprintApplication {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPBlueprintApplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPEgress) DeepCopyInto(out *HTTPEgress) {
+	*out = *in
+	if in.AllowedCIDRs != nil {
+		in, out := &in.AllowedCIDRs, &out.AllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedFQDNs != nil {
+		in, out := &in.AllowedFQDNs, &out.AllowedFQDNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPEgress.
+func (in *HTTPEgress) DeepCopy() *HTTPEgress {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPEgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPReadinessCheck) DeepCopyInto(out *HTTPReadinessCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPReadinessCheck.
+func (in *HTTPReadinessCheck) DeepCopy() *HTTPReadinessCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPReadinessCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPRollout) DeepCopyInto(out *HTTPRollout) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Customers != nil {
+		in, out := &in.Customers, &out.Customers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Dataplanes != nil {
+		in, out := &in.Dataplanes, &out.Dataplanes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SaaSTypes != nil {
+		in, out := &in.SaaSTypes, &out.SaaSTypes
+		*out = make([]SaaSTypes, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPRollout.
+func (in *HTTPRollout) DeepCopy() *HTTPRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPTenant) DeepCopyInto(out *HTTPTenant) {
+	*out = *in
+	out.Application = in.Application
+	in.NetworkSecurity.DeepCopyInto(&out.NetworkSecurity)
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(QuotaSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Identity != nil {
+		in, out := &in.Identity, &out.Identity
+		*out = new(TenantIdentity)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPTenant.
+func (in *HTTPTenant) DeepCopy() *HTTPTenant {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPTenant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPTenantApplication) DeepCopyInto(out *HTTPTenantApplication) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPTenantApplication.
+func (in *HTTPTenantApplication) DeepCopy() *HTTPTenantApplication {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPTenantApplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPTenantSizes) DeepCopyInto(out *HTTPTenantSizes) {
+	*out = *in
+	if in.MachineSpec != nil {
+		in, out := &in.MachineSpec, &out.MachineSpec
+		*out = make([]MachineSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(QuotaSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPTenantSizes.
+func (in *HTTPTenantSizes) DeepCopy() *HTTPTenantSizes {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPTenantSizes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityStatus) DeepCopyInto(out *IdentityStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityStatus.
+func (in *IdentityStatus) DeepCopy() *IdentityStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IsolationConfig) DeepCopyInto(out *IsolationConfig) {
+	*out = *in
+	out.Machine = in.Machine
+	in.Network.DeepCopyInto(&out.Network)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IsolationConfig.
+func (in *IsolationConfig) DeepCopy() *IsolationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IsolationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KarpenterConfig) DeepCopyInto(out *KarpenterConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KarpenterConfig.
+func (in *KarpenterConfig) DeepCopy() *KarpenterConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KarpenterConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KarpenterStatus) DeepCopyInto(out *KarpenterStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KarpenterStatus.
+func (in *KarpenterStatus) DeepCopy() *KarpenterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KarpenterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubernetesConfig) DeepCopyInto(out *KubernetesConfig) {
+	*out = *in
+	in.EKS.DeepCopyInto(&out.EKS)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesConfig.
+func (in *KubernetesConfig) DeepCopy() *KubernetesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubernetesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfig) DeepCopyInto(out *MachineConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfig.
+func (in *MachineConfig) DeepCopy() *MachineConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineSpec) DeepCopyInto(out *MachineSpec) {
+	*out = *in
+	if in.NodeLabels != nil {
+		in, out := &in.NodeLabels, &out.NodeLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Disk != nil {
+		in, out := &in.Disk, &out.Disk
+		*out = new(DiskSpec)
+		**out = **in
+	}
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = new(MetadataSpec)
+		**out = **in
+	}
+	if in.SecurityGroupIds != nil {
+		in, out := &in.SecurityGroupIds, &out.SecurityGroupIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineSpec.
+func (in *MachineSpec) DeepCopy() *MachineSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManifestSource) DeepCopyInto(out *ManifestSource) {
+	*out = *in
+	if in.Git != nil {
+		in, out := &in.Git, &out.Git
+		*out = new(GitManifestSource)
+		**out = **in
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManifestSource.
+func (in *ManifestSource) DeepCopy() *ManifestSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ManifestSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManifestStatus) DeepCopyInto(out *ManifestStatus) {
+	*out = *in
+	if in.AppliedAt != nil {
+		in, out := &in.AppliedAt, &out.AppliedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManifestStatus.
+func (in *ManifestStatus) DeepCopy() *ManifestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManifestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataSpec) DeepCopyInto(out *MetadataSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataSpec.
+func (in *MetadataSpec) DeepCopy() *MetadataSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationPolicy) DeepCopyInto(out *MigrationPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationPolicy.
+func (in *MigrationPolicy) DeepCopy() *MigrationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkConfig) DeepCopyInto(out *NetworkConfig) {
+	*out = *in
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IngressControllerNamespaces != nil {
+		in, out := &in.IngressControllerNamespaces, &out.IngressControllerNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MonitoringNamespaces != nil {
+		in, out := &in.MonitoringNamespaces, &out.MonitoringNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Egress != nil {
+		in, out := &in.Egress, &out.Egress
+		*out = new(EgressConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkConfig.
+func (in *NetworkConfig) DeepCopy() *NetworkConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkSecurity) DeepCopyInto(out *NetworkSecurity) {
+	*out = *in
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IngressControllerNamespaces != nil {
+		in, out := &in.IngressControllerNamespaces, &out.IngressControllerNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MonitoringNamespaces != nil {
+		in, out := &in.MonitoringNamespaces, &out.MonitoringNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Egress != nil {
+		in, out := &in.Egress, &out.Egress
+		*out = new(HTTPEgress)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSecurity.
+func (in *NetworkSecurity) DeepCopy() *NetworkSecurity {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkSecurity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeDrainStatus) DeepCopyInto(out *NodeDrainStatus) {
+	*out = *in
+	if in.BlockedPods != nil {
+		in, out := &in.BlockedPods, &out.BlockedPods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeDrainStatus.
+func (in *NodeDrainStatus) DeepCopy() *NodeDrainStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeDrainStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePoolStatus) DeepCopyInto(out *NodePoolStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePoolStatus.
+func (in *NodePoolStatus) DeepCopy() *NodePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodegroupReplacement) DeepCopyInto(out *NodegroupReplacement) {
+	*out = *in
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodegroupReplacement.
+func (in *NodegroupReplacement) DeepCopy() *NodegroupReplacement {
+	if in == nil {
+		return nil
+	}
+	out := new(NodegroupReplacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodegroupStatus) DeepCopyInto(out *NodegroupStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make(map[string]NodeDrainStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodegroupStatus.
+func (in *NodegroupStatus) DeepCopy() *NodegroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodegroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaSpec) DeepCopyInto(out *QuotaSpec) {
+	*out = *in
+	if in.Hard != nil {
+		in, out := &in.Hard, &out.Hard
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.PriorityClasses != nil {
+		in, out := &in.PriorityClasses, &out.PriorityClasses
+		*out = make(map[string]corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			var outVal map[corev1.ResourceName]resource.Quantity
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(corev1.ResourceList, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val.DeepCopy()
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = new(ContainerLimits)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaSpec.
+func (in *QuotaSpec) DeepCopy() *QuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(QuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaStatus) DeepCopyInto(out *QuotaStatus) {
+	*out = *in
+	in.QuotaUsage.DeepCopyInto(&out.QuotaUsage)
+	if in.PriorityClasses != nil {
+		in, out := &in.PriorityClasses, &out.PriorityClasses
+		*out = make(map[string]QuotaUsage, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaStatus.
+func (in *QuotaStatus) DeepCopy() *QuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(QuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaUsage) DeepCopyInto(out *QuotaUsage) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]DataPlanes, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
+	if in.Hard != nil {
+		in, out := &in.Hard, &out.Hard
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Used != nil {
+		in, out := &in.Used, &out.Used
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataPlanesList.
-func (in *DataPlanesList) DeepCopy() *DataPlanesList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaUsage.
+func (in *QuotaUsage) DeepCopy() *QuotaUsage {
 	if in == nil {
 		return nil
 	}
-	out := new(DataPlanesList)
+	out := new(QuotaUsage)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *DataPlanesList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EKSConfig) DeepCopyInto(out *EKSConfig) {
+func (in *ReadinessCheck) DeepCopyInto(out *ReadinessCheck) {
 	*out = *in
-	if in.SubnetIds != nil {
-		in, out := &in.SubnetIds, &out.SubnetIds
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.SecurityGroupIds != nil {
-		in, out := &in.SecurityGroupIds, &out.SecurityGroupIds
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(HTTPReadinessCheck)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EKSConfig.
-func (in *EKSConfig) DeepCopy() *EKSConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadinessCheck.
+func (in *ReadinessCheck) DeepCopy() *ReadinessCheck {
 	if in == nil {
 		return nil
 	}
-	out := new(EKSConfig)
+	out := new(ReadinessCheck)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EksConfig) DeepCopyInto(out *EksConfig) {
+func (in *ReleaseRevision) DeepCopyInto(out *ReleaseRevision) {
 	*out = *in
-	if in.SubnetIds != nil {
-		in, out := &in.SubnetIds, &out.SubnetIds
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.SecurityGroupIds != nil {
-		in, out := &in.SecurityGroupIds, &out.SecurityGroupIds
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
+	in.DeployedAt.DeepCopyInto(&out.DeployedAt)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EksConfig.
-func (in *EksConfig) DeepCopy() *EksConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseRevision.
+func (in *ReleaseRevision) DeepCopy() *ReleaseRevision {
 	if in == nil {
 		return nil
 	}
-	out := new(EksConfig)
+	out := new(ReleaseRevision)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EksStatus) DeepCopyInto(out *EksStatus) {
+func (in *ReleaseStatus) DeepCopyInto(out *ReleaseStatus) {
 	*out = *in
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]ReleaseRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Specs != nil {
+		in, out := &in.Specs, &out.Specs
+		*out = make(map[string]ChartSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EksStatus.
-func (in *EksStatus) DeepCopy() *EksStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseStatus.
+func (in *ReleaseStatus) DeepCopy() *ReleaseStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(EksStatus)
+	out := new(ReleaseStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HTTPApplication) DeepCopyInto(out *HTTPApplication) {
+func (in *ResourceHealth) DeepCopyInto(out *ResourceHealth) {
 	*out = *in
-	if in.Values != nil {
-		in, out := &in.Values, &out.Values
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPApplication.
-func (in *HTTPApplication) DeepCopy() *HTTPApplication {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceHealth.
+func (in *ResourceHealth) DeepCopy() *ResourceHealth {
 	if in == nil {
 		return nil
 	}
-	out := new(HTTPApplication)
+	out := new(ResourceHealth)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HTTPTenant) DeepCopyInto(out *HTTPTenant) {
+func (in *ResourceStatus) DeepCopyInto(out *ResourceStatus) {
 	*out = *in
-	out.Application = in.Application
-	in.NetworkSecurity.DeepCopyInto(&out.NetworkSecurity)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPTenant.
-func (in *HTTPTenant) DeepCopy() *HTTPTenant {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceStatus.
+func (in *ResourceStatus) DeepCopy() *ResourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HTTPTenant)
+	out := new(ResourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HTTPTenantApplication) DeepCopyInto(out *HTTPTenantApplication) {
+func (in *Rollout) DeepCopyInto(out *Rollout) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPTenantApplication.
-func (in *HTTPTenantApplication) DeepCopy() *HTTPTenantApplication {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Rollout.
+func (in *Rollout) DeepCopy() *Rollout {
 	if in == nil {
 		return nil
 	}
-	out := new(HTTPTenantApplication)
+	out := new(Rollout)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Rollout) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HTTPTenantSizes) DeepCopyInto(out *HTTPTenantSizes) {
+func (in *RolloutList) DeepCopyInto(out *RolloutList) {
 	*out = *in
-	if in.MachineSpec != nil {
-		in, out := &in.MachineSpec, &out.MachineSpec
-		*out = make([]MachineSpec, len(*in))
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Rollout, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPTenantSizes.
-func (in *HTTPTenantSizes) DeepCopy() *HTTPTenantSizes {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutList.
+func (in *RolloutList) DeepCopy() *RolloutList {
 	if in == nil {
 		return nil
 	}
-	out := new(HTTPTenantSizes)
+	out := new(RolloutList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RolloutList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IsolationConfig) DeepCopyInto(out *IsolationConfig) {
+func (in *RolloutSelector) DeepCopyInto(out *RolloutSelector) {
 	*out = *in
-	out.Machine = in.Machine
-	in.Network.DeepCopyInto(&out.Network)
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Customers != nil {
+		in, out := &in.Customers, &out.Customers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Dataplanes != nil {
+		in, out := &in.Dataplanes, &out.Dataplanes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SaaSTypes != nil {
+		in, out := &in.SaaSTypes, &out.SaaSTypes
+		*out = make([]SaaSTypes, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IsolationConfig.
-func (in *IsolationConfig) DeepCopy() *IsolationConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutSelector.
+func (in *RolloutSelector) DeepCopy() *RolloutSelector {
 	if in == nil {
 		return nil
 	}
-	out := new(IsolationConfig)
+	out := new(RolloutSelector)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KubernetesConfig) DeepCopyInto(out *KubernetesConfig) {
+func (in *RolloutSpec) DeepCopyInto(out *RolloutSpec) {
 	*out = *in
-	in.EKS.DeepCopyInto(&out.EKS)
+	in.Selector.DeepCopyInto(&out.Selector)
+	out.Target = in.Target
+	in.Strategy.DeepCopyInto(&out.Strategy)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesConfig.
-func (in *KubernetesConfig) DeepCopy() *KubernetesConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutSpec.
+func (in *RolloutSpec) DeepCopy() *RolloutSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(KubernetesConfig)
+	out := new(RolloutSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MachineConfig) DeepCopyInto(out *MachineConfig) {
+func (in *RolloutStatus) DeepCopyInto(out *RolloutStatus) {
 	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]RolloutTargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfig.
-func (in *MachineConfig) DeepCopy() *MachineConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStatus.
+func (in *RolloutStatus) DeepCopy() *RolloutStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(MachineConfig)
+	out := new(RolloutStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MachineSpec) DeepCopyInto(out *MachineSpec) {
+func (in *RolloutStrategy) DeepCopyInto(out *RolloutStrategy) {
 	*out = *in
-	if in.NodeLabels != nil {
-		in, out := &in.NodeLabels, &out.NodeLabels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.ProgressDeadline != nil {
+		in, out := &in.ProgressDeadline, &out.ProgressDeadline
+		*out = new(metav1.Duration)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineSpec.
-func (in *MachineSpec) DeepCopy() *MachineSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategy.
+func (in *RolloutStrategy) DeepCopy() *RolloutStrategy {
 	if in == nil {
 		return nil
 	}
-	out := new(MachineSpec)
+	out := new(RolloutStrategy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkConfig) DeepCopyInto(out *NetworkConfig) {
+func (in *RolloutTarget) DeepCopyInto(out *RolloutTarget) {
 	*out = *in
-	if in.AllowedNamespaces != nil {
-		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkConfig.
-func (in *NetworkConfig) DeepCopy() *NetworkConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutTarget.
+func (in *RolloutTarget) DeepCopy() *RolloutTarget {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkConfig)
+	out := new(RolloutTarget)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkSecurity) DeepCopyInto(out *NetworkSecurity) {
+func (in *RolloutTargetStatus) DeepCopyInto(out *RolloutTargetStatus) {
 	*out = *in
-	if in.AllowedNamespaces != nil {
-		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.UpdatedAt != nil {
+		in, out := &in.UpdatedAt, &out.UpdatedAt
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSecurity.
-func (in *NetworkSecurity) DeepCopy() *NetworkSecurity {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutTargetStatus.
+func (in *RolloutTargetStatus) DeepCopy() *RolloutTargetStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkSecurity)
+	out := new(RolloutTargetStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NodegroupStatus) DeepCopyInto(out *NodegroupStatus) {
+func (in *SizeMigration) DeepCopyInto(out *SizeMigration) {
 	*out = *in
+	if in.BlockedPods != nil {
+		in, out := &in.BlockedPods, &out.BlockedPods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodegroupStatus.
-func (in *NodegroupStatus) DeepCopy() *NodegroupStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SizeMigration.
+func (in *SizeMigration) DeepCopy() *SizeMigration {
 	if in == nil {
 		return nil
 	}
-	out := new(NodegroupStatus)
+	out := new(SizeMigration)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -743,6 +2246,26 @@ func (in *TenantApplicationConfig) DeepCopy() *TenantApplicationConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantIdentity) DeepCopyInto(out *TenantIdentity) {
+	*out = *in
+	if in.PolicyArns != nil {
+		in, out := &in.PolicyArns, &out.PolicyArns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantIdentity.
+func (in *TenantIdentity) DeepCopy() *TenantIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TenantSizes) DeepCopyInto(out *TenantSizes) {
 	*out = *in
@@ -753,6 +2276,11 @@ func (in *TenantSizes) DeepCopyInto(out *TenantSizes) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(QuotaSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantSizes.
@@ -861,6 +2389,7 @@ func (in *TenantsInfraSpec) DeepCopyInto(out *TenantsInfraSpec) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	in.Drain.DeepCopyInto(&out.Drain)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantsInfraSpec.
@@ -879,10 +2408,24 @@ func (in *TenantsInfraStatus) DeepCopyInto(out *TenantsInfraStatus) {
 	if in.NodegroupStatus != nil {
 		in, out := &in.NodegroupStatus, &out.NodegroupStatus
 		*out = make(map[string]NodegroupStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.NodePoolStatus != nil {
+		in, out := &in.NodePoolStatus, &out.NodePoolStatus
+		*out = make(map[string]NodePoolStatus, len(*in))
 		for key, val := range *in {
 			(*out)[key] = val
 		}
 	}
+	if in.Replacements != nil {
+		in, out := &in.Replacements, &out.Replacements
+		*out = make(map[string]NodegroupReplacement, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantsInfraStatus.
@@ -936,6 +2479,17 @@ func (in *TenantsSpec) DeepCopyInto(out *TenantsSpec) {
 		copy(*out, *in)
 	}
 	in.Isolation.DeepCopyInto(&out.Isolation)
+	out.Migration = in.Migration
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(QuotaSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Identity != nil {
+		in, out := &in.Identity, &out.Identity
+		*out = new(TenantIdentity)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantsSpec.
@@ -958,6 +2512,45 @@ func (in *TenantsStatus) DeepCopyInto(out *TenantsStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.Sizes != nil {
+		in, out := &in.Sizes, &out.Sizes
+		*out = make(map[ApplicationType]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Migrations != nil {
+		in, out := &in.Migrations, &out.Migrations
+		*out = make(map[ApplicationType]SizeMigration, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(QuotaStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Identity != nil {
+		in, out := &in.Identity, &out.Identity
+		*out = new(IdentityStatus)
+		**out = **in
+	}
+	if in.FQDNAddresses != nil {
+		in, out := &in.FQDNAddresses, &out.FQDNAddresses
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantsStatus.
@@ -969,3 +2562,66 @@ func (in *TenantsStatus) DeepCopy() *TenantsStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradePolicy) DeepCopyInto(out *UpgradePolicy) {
+	*out = *in
+	if in.SizeOrder != nil {
+		in, out := &in.SizeOrder, &out.SizeOrder
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradePolicy.
+func (in *UpgradePolicy) DeepCopy() *UpgradePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeStatus) DeepCopyInto(out *UpgradeStatus) {
+	*out = *in
+	if in.PreflightFailures != nil {
+		in, out := &in.PreflightFailures, &out.PreflightFailures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Nodegroups != nil {
+		in, out := &in.Nodegroups, &out.Nodegroups
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeStatus.
+func (in *UpgradeStatus) DeepCopy() *UpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesReference) DeepCopyInto(out *ValuesReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesReference.
+func (in *ValuesReference) DeepCopy() *ValuesReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesReference)
+	in.DeepCopyInto(out)
+	return out
+}
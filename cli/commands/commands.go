@@ -16,6 +16,12 @@ var commonValidArgs = []string{
 	"tenantsinfra",
 	"events",
 	"event",
+	"usage",
+	"kubeconfig",
+	"blueprints",
+	"blueprint",
+	"rollouts",
+	"rollout",
 }
 
 var (
@@ -23,10 +29,17 @@ var (
 	dataplane_name               string
 	entity_name                  string
 	duration                     string
+	from                         string
+	to                           string
+	expiration                   string
 	customer_name                string
 	tenant_name                  string
 	tenantsinfra_name            string
+	rollout_name                 string
+	pause                        bool
+	resume                       bool
 	application_name             string
+	revision                     int
 	private_mode                 bool
 	kubernetes_config_server_url string
 	namespace                    string
@@ -2,8 +2,10 @@ package commands
 
 import (
 	"bz/pkg/applications"
+	"bz/pkg/blueprints"
 	"bz/pkg/customers"
 	"bz/pkg/dataplanes"
+	"bz/pkg/rollouts"
 	"bz/pkg/tenants"
 	"bz/pkg/tenantsinfra"
 	"fmt"
@@ -64,6 +66,21 @@ var (
 					return err
 				}
 				fmt.Println(resp)
+			case "blueprint", "blueprints":
+				if tenant_name == "" || customer_name == "" {
+					return fmt.Errorf("tenant and Customer name is required")
+				}
+				resp, err := blueprints.CreateBlueprintApplication(file, customer_name, tenant_name)
+				if err != nil {
+					return err
+				}
+				fmt.Println(resp)
+			case "rollout", "rollouts":
+				resp, err := rollouts.CreateRollout(file)
+				if err != nil {
+					return err
+				}
+				fmt.Println(resp)
 			default:
 				return NotValidArgs(commonValidArgs)
 			}
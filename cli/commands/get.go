@@ -1,11 +1,15 @@
 package commands
 
 import (
+	"bz/pkg/blueprints"
 	"bz/pkg/customers"
 	"bz/pkg/dataplanes"
 	"bz/pkg/events"
+	"bz/pkg/kubeconfig"
+	"bz/pkg/rollouts"
 	"bz/pkg/tenants"
 	"bz/pkg/tenantsinfra"
+	"bz/pkg/usage"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -14,7 +18,7 @@ import (
 // getCmd represents the get command
 var getCmd = &cobra.Command{
 	Use:       "get",
-	Short:     "bz get - list entities [Customers, Dataplane, Tenants, Applications] in baaz control plane",
+	Short:     "bz get - list entities [Customers, Dataplane, Tenants, Applications, Usage, Kubeconfig] in baaz control plane",
 	ValidArgs: commonValidArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Handle different entity types
@@ -47,6 +51,24 @@ var getCmd = &cobra.Command{
 				return tenants.GetTenant(customer_name, tenant_name)
 			}
 			return tenants.ListTenants(customer_name)
+		case "usage":
+			// Ensure customer name is provided
+			if customer_name == "" {
+				return fmt.Errorf("customer cannot be nil")
+			}
+			return usage.GetUsage(customer_name, from, to)
+		case "blueprints", "blueprint":
+			return blueprints.GetBlueprints()
+		case "rollouts", "rollout":
+			if rollout_name != "" {
+				return rollouts.GetRollout(rollout_name)
+			}
+			return rollouts.ListRollouts()
+		case "kubeconfig":
+			if customer_name == "" || tenant_name == "" {
+				return fmt.Errorf("customer and tenant cannot be nil")
+			}
+			return kubeconfig.GetTenantKubeConfig(customer_name, tenant_name, expiration)
 		default:
 			// Handle invalid arguments
 			return NotValidArgs(commonValidArgs)
@@ -65,4 +87,8 @@ func init() {
 	getCmd.Flags().StringVarP(&entity_name, "entity", "", "", "entity name")
 	getCmd.Flags().StringVarP(&duration, "duration", "", "", "duration to get events")
 	getCmd.Flags().StringVarP(&tenantsinfra_name, "tenantinfra_name", "", "", "tenantinfra name")
+	getCmd.Flags().StringVarP(&from, "from", "", "", "start of usage period, date (2024-05-01) or RFC3339")
+	getCmd.Flags().StringVarP(&to, "to", "", "", "end of usage period, date (2024-05-31) or RFC3339")
+	getCmd.Flags().StringVarP(&rollout_name, "rollout", "", "", "rollout name")
+	getCmd.Flags().StringVarP(&expiration, "expiration", "", "", "lifetime of the kubeconfig token, 10m to 24h (default 1h)")
 }
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"bz/pkg/applications"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackCmd = &cobra.Command{
+		Use:   "rollback",
+		Short: "bz rollback - roll back an application to a revision of its release",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch args[0] {
+			case "application", "applications":
+				if customer_name == "" {
+					return fmt.Errorf("customer name cannot be nil")
+				}
+				if application_name == "" {
+					return fmt.Errorf("application name cannot be nil")
+				}
+				if revision <= 0 {
+					return fmt.Errorf("revision must be positive, use --revision to specify it")
+				}
+				resp, err := applications.RollbackApplication(customer_name, application_name, args[1], revision)
+				if err != nil {
+					return err
+				}
+				fmt.Println(resp)
+			default:
+				return NotValidArgs([]string{"application", "applications"})
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().StringVarP(&customer_name, "customer", "", "", "customer name")
+	rollbackCmd.Flags().StringVarP(&application_name, "application", "", "", "application name")
+	rollbackCmd.Flags().IntVarP(&revision, "revision", "", 0, "revision of the release to roll back to")
+}
@@ -2,7 +2,9 @@ package commands
 
 import (
 	"bz/pkg/applications"
+	"bz/pkg/blueprints"
 	"bz/pkg/dataplanes"
+	"bz/pkg/rollouts"
 	"bz/pkg/tenants"
 	"bz/pkg/tenantsinfra"
 	"fmt"
@@ -68,7 +70,31 @@ var (
 				if file == "" {
 					return fmt.Errorf("file path cannot be nil, use --file or -f flag to specify the file path")
 				}
-				resp, err := applications.CreateApplication(file, customer_name, application_name)
+				resp, err := applications.UpdateApplication(file, customer_name, application_name)
+				if err != nil {
+					return err
+				}
+				fmt.Println(resp)
+			case "blueprint", "blueprints":
+				if customer_name == "" || application_name == "" {
+					return fmt.Errorf("customer and application name cannot be nil")
+				}
+				if file == "" {
+					return fmt.Errorf("file path cannot be nil, use --file or -f flag to specify the file path")
+				}
+				resp, err := blueprints.UpdateBlueprintApplication(file, customer_name, application_name)
+				if err != nil {
+					return err
+				}
+				fmt.Println(resp)
+			case "rollout", "rollouts":
+				if rollout_name == "" {
+					return fmt.Errorf("rollout name cannot be nil")
+				}
+				if pause == resume {
+					return fmt.Errorf("one of --pause or --resume is required")
+				}
+				resp, err := rollouts.PauseRollout(rollout_name, pause)
 				if err != nil {
 					return err
 				}
@@ -89,4 +115,7 @@ func init() {
 	updateCmd.Flags().StringVarP(&tenant_name, "tenant", "", "", "tenant name")
 	updateCmd.Flags().StringVarP(&tenantsinfra_name, "tenantinfra", "", "", "tenane infra name")
 	updateCmd.Flags().StringVarP(&application_name, "application", "", "", "application name")
+	updateCmd.Flags().StringVarP(&rollout_name, "rollout", "", "", "rollout name")
+	updateCmd.Flags().BoolVarP(&pause, "pause", "", false, "pause the rollout")
+	updateCmd.Flags().BoolVarP(&resume, "resume", "", false, "resume the rollout")
 }
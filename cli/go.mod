@@ -5,10 +5,8 @@ go 1.21
 toolchain go1.21.5
 
 require (
-	github.com/gofrs/flock v0.8.1
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/parseablehq/parseable-sdk-go v0.0.0-20240310064233-64d4876365b5
-	github.com/pkg/errors v0.9.1
 	github.com/spf13/cobra v1.8.0
 	github.com/spf13/viper v1.17.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -107,6 +105,7 @@ require (
 	github.com/opencontainers/image-spec v1.1.0-rc5 // indirect
 	github.com/pelletier/go-toml/v2 v2.1.0 // indirect
 	github.com/peterbourgon/diskv v2.0.1+incompatible // indirect
+	github.com/pkg/errors v0.9.1 // indirect
 	github.com/prometheus/client_golang v1.16.0 // indirect
 	github.com/prometheus/client_model v0.4.0 // indirect
 	github.com/prometheus/common v0.44.0 // indirect
@@ -20,6 +20,52 @@ type Applications struct {
 		RepoURL   string   `yaml:"repoUrl" json:"repo_url"`
 		Version   string   `yaml:"version" json:"version"`
 		Values    []string `yaml:"values" json:"values"`
+		// ValuesObject are the values of a values file.
+		ValuesObject map[string]interface{} `yaml:"valuesObject" json:"values_object,omitempty"`
+		ValuesFrom   []struct {
+			Kind       string `yaml:"kind" json:"kind"`
+			Name       string `yaml:"name" json:"name"`
+			ValuesKey  string `yaml:"valuesKey" json:"valuesKey,omitempty"`
+			TargetPath string `yaml:"targetPath" json:"targetPath,omitempty"`
+			Optional   bool   `yaml:"optional" json:"optional,omitempty"`
+		} `yaml:"valuesFrom" json:"values_from,omitempty"`
+		// Auth names the Secret of the credentials of a private repo or
+		// registry, or logs in ECR.
+		Auth *struct {
+			SecretName string `yaml:"secretName" json:"secretName,omitempty"`
+			ECR        bool   `yaml:"ecr" json:"ecr,omitempty"`
+		} `yaml:"auth" json:"auth,omitempty"`
+		InsecureSkipTLSVerify bool `yaml:"insecureSkipTLSVerify" json:"insecure_skip_tls_verify,omitempty"`
+		// DependsOn names the applications installed and ready before
+		// this one.
+		DependsOn []string `yaml:"dependsOn" json:"depends_on,omitempty"`
+		// Readiness checks a deployment is available, a job completed or
+		// a service answers once the application is installed.
+		Readiness []struct {
+			Deployment string `yaml:"deployment" json:"deployment,omitempty"`
+			Job        string `yaml:"job" json:"job,omitempty"`
+			HTTP       *struct {
+				Service string `yaml:"service" json:"service"`
+				Port    string `yaml:"port" json:"port"`
+				Path    string `yaml:"path" json:"path,omitempty"`
+				Scheme  string `yaml:"scheme" json:"scheme,omitempty"`
+			} `yaml:"http" json:"http,omitempty"`
+			Namespace string `yaml:"namespace" json:"namespace,omitempty"`
+		} `yaml:"readiness" json:"readiness,omitempty"`
+		// Source deploys inline manifests, the manifests of a ConfigMap
+		// or of a Git directory instead of the chart.
+		Source *struct {
+			Inline    string `yaml:"inline" json:"inline,omitempty"`
+			ConfigMap string `yaml:"configMap" json:"configMap,omitempty"`
+			Git       *struct {
+				URL        string `yaml:"url" json:"url"`
+				Ref        string `yaml:"ref" json:"ref,omitempty"`
+				Path       string `yaml:"path" json:"path,omitempty"`
+				SecretName string `yaml:"secretName" json:"secretName,omitempty"`
+			} `yaml:"git" json:"git,omitempty"`
+			Kustomize bool   `yaml:"kustomize" json:"kustomize,omitempty"`
+			Interval  string `yaml:"interval" json:"interval,omitempty"`
+		} `yaml:"source" json:"source,omitempty"`
 	} `yaml:"application" json:"application"`
 }
 
@@ -126,3 +172,39 @@ func UpdateApplication(filePath, customerName, applicationName string) (string,
 
 	return "", nil
 }
+
+// RollbackApplication rolls the app of an application back to a revision of
+// its release.
+func RollbackApplication(customerName, applicationName, app string, revision int) (string, error) {
+	rollbackByte, err := json.Marshal(map[string]interface{}{
+		"name":     app,
+		"revision": revision,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.Post(
+		makeUpdateApplicationUrl(customerName, applicationName)+"/rollback",
+		"application/json",
+		bytes.NewBuffer(rollbackByte),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode > 299 {
+		return "", fmt.Errorf("%s", string(respBody))
+	}
+
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return "Application Rollback Initated Successfully", nil
+	}
+
+	return "", nil
+}
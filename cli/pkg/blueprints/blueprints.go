@@ -0,0 +1,154 @@
+package blueprints
+
+import (
+	"bytes"
+	"bz/pkg/common"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// Instance instantiates a blueprint with its parameters.
+type Instance struct {
+	Name string `yaml:"name" json:"name"`
+	// Version pins a version of the blueprint, its current version is
+	// followed without any.
+	Version    string                 `yaml:"version" json:"version,omitempty"`
+	Parameters map[string]interface{} `yaml:"parameters" json:"parameters,omitempty"`
+}
+
+type blueprint struct {
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Versions []string `json:"versions"`
+	AppType  string   `json:"app_type"`
+}
+
+func makeListBlueprintUrl() string {
+	return common.GetBzUrl() + common.BaazPath + common.BlueprintPath
+}
+
+func makeCreateBlueprintUrl(customerName, tenantName string) string {
+	return common.GetBzUrl() + common.BaazPath + common.CustomerPath + "/" + customerName + common.TenantPath + "/" + tenantName + common.BlueprintPath
+}
+
+func makeUpdateBlueprintUrl(customerName, applicationName string) string {
+	return common.GetBzUrl() + common.BaazPath + common.CustomerPath + "/" + customerName + common.Application + "/" + applicationName + common.BlueprintPath
+}
+
+func GetBlueprints() error {
+	resp, err := http.Get(makeListBlueprintUrl())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("%s", string(body))
+	}
+	if err != nil {
+		return err
+	}
+
+	var blueprints []blueprint
+	if err := json.Unmarshal(body, &blueprints); err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{
+		"Blueprint_Name",
+		"Current_Version",
+		"Versions",
+		"App_Type",
+	})
+	for _, bp := range blueprints {
+		table.SetRowLine(true)
+		table.Append([]string{bp.Name, bp.Version, strings.Join(bp.Versions, ","), bp.AppType})
+		table.SetAlignment(1)
+	}
+	table.Render()
+	return nil
+}
+
+// CreateBlueprintApplication instantiates the blueprint of a yaml file for
+// a tenant.
+func CreateBlueprintApplication(filePath, customerName, tenantName string) (string, error) {
+	instanceByte, err := readInstance(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.Post(
+		makeCreateBlueprintUrl(customerName, tenantName),
+		"application/json",
+		bytes.NewBuffer(instanceByte),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode > 299 {
+		return "", fmt.Errorf("%s", string(respBody))
+	}
+	if err != nil {
+		return "", err
+	}
+	return "Blueprint Application Creation Initated Successfully", nil
+}
+
+// UpdateBlueprintApplication moves a blueprint instance to the version and
+// parameters of a yaml file.
+func UpdateBlueprintApplication(filePath, customerName, applicationName string) (string, error) {
+	instanceByte, err := readInstance(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPut,
+		makeUpdateBlueprintUrl(customerName, applicationName),
+		bytes.NewBuffer(instanceByte),
+	)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode > 299 {
+		return "", fmt.Errorf("%s", string(respBody))
+	}
+	if err != nil {
+		return "", err
+	}
+	return "Blueprint Application Update Initated Successfully", nil
+}
+
+func readInstance(filePath string) ([]byte, error) {
+	yamlByte, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var instance Instance
+	if err := yaml.Unmarshal(yamlByte, &instance); err != nil {
+		return nil, err
+	}
+	return json.Marshal(instance)
+}
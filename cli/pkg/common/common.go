@@ -15,6 +15,9 @@ var (
 	TenantSizesPath = "/sizes"
 	KubeConfigPath  = "/config"
 	Application     = "/application"
+	UsagePath       = "/usage"
+	BlueprintPath   = "/blueprint"
+	RolloutPath     = "/rollout"
 )
 
 func GetBzUrl() string {
@@ -34,6 +34,8 @@ type Dataplane struct {
 				SubnetIds        []string `yaml:"subnetIds" json:"subnet_ids"`
 				SecurityGroupIds []string `yaml:"securityGroupIds" json:"security_group_ids"`
 				Version          string   `yaml:"version" json:"version"`
+				// Provisioner is nodegroup or karpenter.
+				Provisioner string `yaml:"provisioner,omitempty" json:"provisioner,omitempty"`
 			} `yaml:"eks"`
 		} `yaml:"kubernetesConfig" json:"kubernetes_config"`
 		ApplicationConfig []struct {
@@ -1,30 +1,19 @@
 package helm
 
 import (
-	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path/filepath"
-	"strings"
-	"sync"
 	"time"
 
 	"k8s.io/klog/v2"
 
-	"github.com/gofrs/flock"
-	"github.com/pkg/errors"
-	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/getter"
-	"helm.sh/helm/v3/pkg/repo"
 )
 
-var settings *cli.EnvSettings
-
 type HelmAct interface {
 	Apply() error
 	Uninstall() error
@@ -131,8 +120,6 @@ func (h *Helm) Apply() error {
 		return err
 	}
 
-	_ = h.RepoUpdate()
-
 	chartRequested, err := loader.Load(cp)
 	if err != nil {
 		return err
@@ -168,100 +155,3 @@ func (h *Helm) Apply() error {
 	return nil
 }
 
-// ref: https://github.com/PrasadG193/helm-clientgo-example/tree/master
-// RepoUpdate updates charts for all helm repos
-func (helm *Helm) RepoUpdate() error {
-	settings = cli.New()
-
-	repoFile := settings.RepositoryConfig
-
-	f, err := repo.LoadFile(repoFile)
-	if os.IsNotExist(errors.Cause(err)) || len(f.Repositories) == 0 {
-		klog.Error(errors.New("no repositories found. You must add one before updating"))
-		return err
-	}
-	var repos []*repo.ChartRepository
-	for _, cfg := range f.Repositories {
-		r, err := repo.NewChartRepository(cfg, getter.All(settings))
-		if err != nil {
-			return err
-		}
-		repos = append(repos, r)
-	}
-
-	var wg sync.WaitGroup
-	for _, re := range repos {
-		wg.Add(1)
-		go func(re *repo.ChartRepository) {
-			defer wg.Done()
-			if _, err := re.DownloadIndexFile(); err != nil {
-				klog.Errorf("...Unable to get an update from the %q chart repository (%s):\n\t%s\n", re.Config.Name, re.Config.URL, err)
-				return
-			}
-		}(re)
-	}
-	wg.Wait()
-
-	return nil
-}
-
-// Ref: https://github.com/PrasadG193/helm-clientgo-example/tree/master
-// RepoAdd adds repo with given name and url
-func repoAdd(name, url string) {
-	settings := cli.New()
-
-	repoFile := settings.RepositoryConfig
-
-	//Ensure the file directory exists as it is required for file locking
-	err := os.MkdirAll(filepath.Dir(repoFile), os.ModePerm)
-	if err != nil && !os.IsExist(err) {
-		klog.Error(err)
-	}
-
-	// Acquire a file lock for process synchronization
-	fileLock := flock.New(strings.Replace(repoFile, filepath.Ext(repoFile), ".lock", 1))
-	lockCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	locked, err := fileLock.TryLockContext(lockCtx, time.Second)
-	if err == nil && locked {
-		defer fileLock.Unlock()
-	}
-	if err != nil {
-		klog.Error(err)
-	}
-
-	b, err := ioutil.ReadFile(repoFile)
-	if err != nil && !os.IsNotExist(err) {
-		klog.Error(err)
-	}
-
-	var f repo.File
-	if err := yaml.Unmarshal(b, &f); err != nil {
-		klog.Error(err)
-	}
-
-	if f.Has(name) {
-		return
-	}
-
-	c := repo.Entry{
-		Name: name,
-		URL:  url,
-	}
-
-	r, err := repo.NewChartRepository(&c, getter.All(settings))
-	if err != nil {
-		klog.Error(err)
-	}
-
-	if _, err := r.DownloadIndexFile(); err != nil {
-		err := errors.Wrapf(err, "looks like %q is not a valid chart repository or cannot be reached", url)
-		klog.Error(err)
-	}
-
-	f.Update(&c)
-
-	if err := f.WriteFile(repoFile, 0644); err != nil {
-		klog.Error(err)
-	}
-}
@@ -4,10 +4,14 @@ import (
 	"bz/pkg/common"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
 
+	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -71,6 +75,12 @@ func GetCustomerKubeConfig(customerName string) (*KubeConfig, error) {
 		return nil, err
 	}
 
+	return makeKubeConfig(resp["customer"], resp), nil
+}
+
+// makeKubeConfig returns the kubeconfig of the cluster, user and namespace
+// of a config served by baaz, named name.
+func makeKubeConfig(name string, resp map[string]string) *KubeConfig {
 	newKubeConfig := KubeConfig{
 		APIVersion:  "v1",
 		Kind:        "Config",
@@ -90,7 +100,7 @@ func GetCustomerKubeConfig(customerName string) (*KubeConfig, error) {
 					CertificateAuthorityData: resp["cluster_ca"],
 					Server:                   resp["cluster_server"],
 				},
-				Name: resp["customer"] + "-cluster",
+				Name: name + "-cluster",
 			},
 		},
 		Users: []struct {
@@ -102,7 +112,7 @@ func GetCustomerKubeConfig(customerName string) (*KubeConfig, error) {
 			} `json:"user" yaml:"user"`
 		}{
 			{
-				Name: resp["customer"],
+				Name: name,
 				User: struct {
 					AsUserExtra   struct{}    `json:"as-user-extra" yaml:"as-user-extra"`
 					ClientKeyData interface{} `json:"client-key-data" yaml:"client-key-data"`
@@ -126,18 +136,17 @@ func GetCustomerKubeConfig(customerName string) (*KubeConfig, error) {
 					Namespace string `json:"namespace" yaml:"namespace"`
 					User      string `json:"user" yaml:"user"`
 				}{
-					Cluster:   resp["customer"] + "-cluster",
+					Cluster:   name + "-cluster",
 					Namespace: resp["namespace"],
-					User:      resp["customer"],
+					User:      name,
 				},
-				Name: resp["customer"],
+				Name: name,
 			},
 		},
-		CurrentContext: resp["customer"],
+		CurrentContext: name,
 	}
 
-	return &newKubeConfig, nil
-
+	return &newKubeConfig
 }
 
 func WriteKubeConfig2Cm(customerName string, config *KubeConfig, cs *kubernetes.Clientset) error {
@@ -166,3 +175,44 @@ func WriteKubeConfig2Cm(customerName string, config *KubeConfig, cs *kubernetes.
 	return nil
 
 }
+
+func makeGetTenantKubeConfigPath(customerName, tenantName, expiration string) string {
+	path := common.GetBzUrl() + common.BaazPath + common.CustomerPath + "/" + customerName +
+		common.TenantPath + "/" + tenantName + common.KubeConfigPath
+	if expiration != "" {
+		path = path + "?" + url.Values{"expiration": {expiration}}.Encode()
+	}
+	return path
+}
+
+// GetTenantKubeConfig prints a kubeconfig limited to the namespace of a
+// tenant, its token expires after expiration (1h by default).
+func GetTenantKubeConfig(customerName, tenantName, expiration string) error {
+	response, err := http.Get(makeGetTenantKubeConfigPath(customerName, tenantName, expiration))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode > 299 {
+		return fmt.Errorf("response failed with status code: %d and body: %s", response.StatusCode, body)
+	}
+
+	var resp map[string]string
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(makeKubeConfig(resp["tenant"], resp))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "token expires at %s\n", resp["expires_at"])
+	_, err = os.Stdout.Write(out)
+	return err
+}
@@ -0,0 +1,221 @@
+package rollouts
+
+import (
+	"bytes"
+	"bz/pkg/common"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// Rollout upgrades the applications it selects in waves.
+type Rollout struct {
+	Name              string            `yaml:"name" json:"name"`
+	Labels            map[string]string `yaml:"labels" json:"labels,omitempty"`
+	Customers         []string          `yaml:"customers" json:"customers,omitempty"`
+	Dataplanes        []string          `yaml:"dataplanes" json:"dataplanes,omitempty"`
+	SaaSTypes         []string          `yaml:"saasTypes" json:"saas_types,omitempty"`
+	App               string            `yaml:"app" json:"app,omitempty"`
+	Blueprint         string            `yaml:"blueprint" json:"blueprint,omitempty"`
+	Version           string            `yaml:"version" json:"version"`
+	CanaryPercent     int               `yaml:"canaryPercent" json:"canary_percent,omitempty"`
+	BatchSize         int               `yaml:"batchSize" json:"batch_size,omitempty"`
+	MaxFailurePercent int               `yaml:"maxFailurePercent" json:"max_failure_percent,omitempty"`
+	ProgressDeadline  string            `yaml:"progressDeadline" json:"progress_deadline,omitempty"`
+}
+
+type rollout struct {
+	Name      string `json:"name"`
+	App       string `json:"app"`
+	Blueprint string `json:"blueprint"`
+	Version   string `json:"version"`
+	Paused    bool   `json:"paused"`
+	Status    struct {
+		Phase   string `json:"phase"`
+		Wave    int    `json:"wave"`
+		Waves   int    `json:"waves"`
+		Total   int    `json:"total"`
+		Updated int    `json:"updated"`
+		Healthy int    `json:"healthy"`
+		Failed  int    `json:"failed"`
+		Message string `json:"message"`
+		Targets []struct {
+			Customer    string `json:"customer"`
+			Application string `json:"application"`
+			Tenant      string `json:"tenant"`
+			Wave        int    `json:"wave"`
+			Phase       string `json:"phase"`
+			Message     string `json:"message"`
+		} `json:"targets"`
+	} `json:"status"`
+}
+
+func makeRolloutUrl() string {
+	return common.GetBzUrl() + common.BaazPath + common.RolloutPath
+}
+
+func CreateRollout(filePath string) (string, error) {
+	yamlByte, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	var r Rollout
+	if err := yaml.Unmarshal(yamlByte, &r); err != nil {
+		return "", err
+	}
+	rolloutByte, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.Post(makeRolloutUrl(), "application/json", bytes.NewBuffer(rolloutByte))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode > 299 {
+		return "", fmt.Errorf("%s", string(respBody))
+	}
+	if err != nil {
+		return "", err
+	}
+	return "Rollout Creation Initated Successfully", nil
+}
+
+// ListRollouts prints the progress of the rollouts.
+func ListRollouts() error {
+	body, err := get(makeRolloutUrl())
+	if err != nil {
+		return err
+	}
+
+	var rollouts []rollout
+	if err := json.Unmarshal(body, &rollouts); err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{
+		"Rollout_Name",
+		"Target",
+		"Version",
+		"Status",
+		"Wave",
+		"Healthy",
+		"Failed",
+		"Total",
+		"Message",
+	})
+	for _, r := range rollouts {
+		target := r.App
+		if r.Blueprint != "" {
+			target = "blueprint/" + r.Blueprint
+		}
+		table.SetRowLine(true)
+		table.Append([]string{
+			r.Name,
+			target,
+			r.Version,
+			r.Status.Phase,
+			strconv.Itoa(r.Status.Wave+1) + "/" + strconv.Itoa(r.Status.Waves),
+			strconv.Itoa(r.Status.Healthy),
+			strconv.Itoa(r.Status.Failed),
+			strconv.Itoa(r.Status.Total),
+			r.Status.Message,
+		})
+		table.SetAlignment(1)
+	}
+	table.Render()
+	return nil
+}
+
+// GetRollout prints the progress of a rollout for each tenant.
+func GetRollout(rolloutName string) error {
+	body, err := get(makeRolloutUrl() + "/" + rolloutName)
+	if err != nil {
+		return err
+	}
+
+	var r rollout
+	if err := json.Unmarshal(body, &r); err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{
+		"Customer_Name",
+		"Tenant_Name",
+		"Application_Name",
+		"Wave",
+		"Status",
+		"Message",
+	})
+	for _, target := range r.Status.Targets {
+		table.SetRowLine(true)
+		table.Append([]string{
+			target.Customer,
+			target.Tenant,
+			target.Application,
+			strconv.Itoa(target.Wave),
+			target.Phase,
+			target.Message,
+		})
+		table.SetAlignment(1)
+	}
+	table.Render()
+	return nil
+}
+
+// PauseRollout pauses or resumes a rollout.
+func PauseRollout(rolloutName string, paused bool) (string, error) {
+	action := "/resume"
+	if paused {
+		action = "/pause"
+	}
+
+	req, err := http.NewRequest(http.MethodPut, makeRolloutUrl()+"/"+rolloutName+action, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode > 299 {
+		return "", fmt.Errorf("%s", string(respBody))
+	}
+	if err != nil {
+		return "", err
+	}
+	if paused {
+		return "Rollout Paused Successfully", nil
+	}
+	return "Rollout Resumed Successfully", nil
+}
+
+func get(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s", string(body))
+	}
+	return body, err
+}
@@ -3,6 +3,7 @@ package tenants
 import (
 	"bytes"
 	"bz/pkg/common"
+	"bz/pkg/tenantsinfra"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,13 +17,26 @@ import (
 type Tenants struct {
 	Tenants struct {
 		NetworkSecurity struct {
-			InterNamespaceTraffic string   `yaml:"interNamespaceTraffic" json:"inter_namespace_traffic"`
-			AllowedNamespaces     []string `yaml:"allowedNamespaces" json:"allowed_namespaces"`
+			InterNamespaceTraffic       string   `yaml:"interNamespaceTraffic" json:"inter_namespace_traffic"`
+			AllowedNamespaces           []string `yaml:"allowedNamespaces" json:"allowed_namespaces"`
+			IngressControllerNamespaces []string `yaml:"ingressControllerNamespaces" json:"ingress_controller_namespaces,omitempty"`
+			MonitoringNamespaces        []string `yaml:"monitoringNamespaces" json:"monitoring_namespaces,omitempty"`
+			Egress                      *struct {
+				DefaultDeny  bool     `yaml:"defaultDeny" json:"default_deny"`
+				DenyDNS      bool     `yaml:"denyDNS" json:"deny_dns,omitempty"`
+				AllowedCIDRs []string `yaml:"allowedCIDRs" json:"allowed_cidrs,omitempty"`
+				AllowedFQDNs []string `yaml:"allowedFQDNs" json:"allowed_fqdns,omitempty"`
+			} `yaml:"egress" json:"egress,omitempty"`
 		} `yaml:"networkSecurity" json:"network_security"`
 		Application struct {
 			Name    string `yaml:"name" json:"name"`
 			AppSize string `yaml:"appSize" json:"app_size"`
 		} `yaml:"application" json:"application"`
+		Quota    *tenantsinfra.TiQuota `yaml:"quota" json:"quota,omitempty"`
+		Identity *struct {
+			IAMPolicy  string   `yaml:"iamPolicy" json:"iamPolicy,omitempty"`
+			PolicyArns []string `yaml:"policyArns" json:"policyArns,omitempty"`
+		} `yaml:"identity" json:"identity,omitempty"`
 	} `yaml:"tenants" json:"tenants"`
 }
 
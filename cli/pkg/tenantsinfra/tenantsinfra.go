@@ -55,7 +55,40 @@ type TiMachine struct {
 			App  string `yaml:"app" json:"app"`
 			Size string `yaml:"size" json:"size"`
 		} `yaml:"labels" json:"labels"`
+		AmiFamily        string   `yaml:"amiFamily" json:"amiFamily,omitempty"`
+		AmiID            string   `yaml:"amiId" json:"amiId,omitempty"`
+		Disk             *TiDisk  `yaml:"disk" json:"disk,omitempty"`
+		Metadata         *TiIMDS  `yaml:"metadata" json:"metadata,omitempty"`
+		SecurityGroupIds []string `yaml:"securityGroupIds" json:"securityGroupIds,omitempty"`
+		UserData         string   `yaml:"userData" json:"userData,omitempty"`
+		SSHKeyName       string   `yaml:"sshKeyName" json:"sshKeyName,omitempty"`
 	} `yaml:"machinePool" json:"machine_pool"`
+	Quota *TiQuota `yaml:"quota" json:"quota,omitempty"`
+}
+
+// TiQuota is the quota of the tenants of a size, quantities are kubernetes
+// quantities like 500m or 4Gi.
+type TiQuota struct {
+	Hard            map[string]string            `yaml:"hard" json:"hard,omitempty"`
+	PriorityClasses map[string]map[string]string `yaml:"priorityClasses" json:"priorityClasses,omitempty"`
+	Containers      *struct {
+		Default        map[string]string `yaml:"default" json:"default,omitempty"`
+		DefaultRequest map[string]string `yaml:"defaultRequest" json:"defaultRequest,omitempty"`
+		Max            map[string]string `yaml:"max" json:"max,omitempty"`
+	} `yaml:"containers" json:"containers,omitempty"`
+}
+
+type TiDisk struct {
+	SizeGiB    int    `yaml:"sizeGiB" json:"sizeGiB,omitempty"`
+	Type       string `yaml:"type" json:"type,omitempty"`
+	Iops       int    `yaml:"iops" json:"iops,omitempty"`
+	Throughput int    `yaml:"throughput" json:"throughput,omitempty"`
+	KmsKeyID   string `yaml:"kmsKeyId" json:"kmsKeyId,omitempty"`
+}
+
+type TiIMDS struct {
+	HttpTokens string `yaml:"httpTokens" json:"httpTokens,omitempty"`
+	HopLimit   int    `yaml:"hopLimit" json:"hopLimit,omitempty"`
 }
 
 type Ti struct {
@@ -0,0 +1,106 @@
+package usage
+
+import (
+	"bz/pkg/common"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+type TenantUsage struct {
+	Tenant         string  `json:"tenant"`
+	Dataplane      string  `json:"dataplane"`
+	Billing        string  `json:"billing"`
+	NodeHours      float64 `json:"node_hours"`
+	CPUCoreHours   float64 `json:"cpu_core_hours"`
+	MemoryGiBHours float64 `json:"memory_gib_hours"`
+	Cost           float64 `json:"cost"`
+}
+
+type Usage struct {
+	Customer string        `json:"customer"`
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	Currency string        `json:"currency"`
+	Tenants  []TenantUsage `json:"tenants"`
+}
+
+func makeGetUsagePath(customerName, from, to string) string {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	path := common.GetBzUrl() + common.BaazPath + common.CustomerPath + "/" + customerName + common.UsagePath
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+	return path
+}
+
+func GetUsage(customerName, from, to string) error {
+	resp, err := http.Get(
+		makeGetUsagePath(customerName, from, to),
+	)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("%s", string(body))
+	}
+
+	var usage Usage
+	err = json.Unmarshal(body, &usage)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Usage of customer %s from %s to %s\n", usage.Customer, usage.From, usage.To)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{
+		"Tenant_Name",
+		"Dataplane_Name",
+		"Billing",
+		"Node_Hours",
+		"CPU_Core_Hours",
+		"Memory_GiB_Hours",
+		"Cost_" + usage.Currency,
+	},
+	)
+
+	var total float64
+	for _, tu := range usage.Tenants {
+		row := []string{
+			tu.Tenant,
+			tu.Dataplane,
+			tu.Billing,
+			fmt.Sprintf("%.2f", tu.NodeHours),
+			fmt.Sprintf("%.2f", tu.CPUCoreHours),
+			fmt.Sprintf("%.2f", tu.MemoryGiBHours),
+			fmt.Sprintf("%.4f", tu.Cost),
+		}
+		total += tu.Cost
+		table.SetRowLine(true)
+		table.Append(row)
+		table.SetAlignment(1)
+	}
+	table.SetFooter([]string{"", "", "", "", "", "Total", fmt.Sprintf("%.4f", total)})
+
+	table.Render()
+	return nil
+}
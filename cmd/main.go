@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -21,13 +23,24 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	datainfraiov1 "github.com/baazhq/baaz/api/v1/types"
 	"github.com/baazhq/baaz/internal/app_controller"
 	dataplane_controller "github.com/baazhq/baaz/internal/dataplane_controller"
+	gitsource_controller "github.com/baazhq/baaz/internal/gitsource_controller"
+	metering_controller "github.com/baazhq/baaz/internal/metering_controller"
+	rollout_controller "github.com/baazhq/baaz/internal/rollout_controller"
 	tenant_controller "github.com/baazhq/baaz/internal/tenant_controller"
 	tenantinfra_controller "github.com/baazhq/baaz/internal/tenantinfra_controller"
+	"github.com/baazhq/baaz/pkg/admission"
+	"github.com/baazhq/baaz/pkg/git"
+	"github.com/baazhq/baaz/pkg/helm"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metrics"
+	"github.com/baazhq/baaz/pkg/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -79,9 +92,13 @@ func main() {
 	var enableLeaderElection bool
 	var enablePrivateSaaS bool
 	var customerName string
+	var admissionWebhook bool
+	var localRepositories bool
 
 	flag.BoolVar(&enablePrivateSaaS, "private_mode", false, "Enable private mode runs BaaZ controllers in a private saas mode.")
 	flag.StringVar(&customerName, "customer_name", "", "Customer name for private saas")
+	flag.BoolVar(&admissionWebhook, admission.Flag, false, "Run the admission webhook placing the pods of tenants, in a dataplane.")
+	flag.BoolVar(&localRepositories, git.LocalFlag, false, "Let the git sources check out the local repositories of the file system of baaz.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager. "+"Enabling this will ensure there is only one active controller manager.")
 
 	opts := zap.Options{
@@ -89,19 +106,41 @@ func main() {
 	}
 
 	opts.BindFlags(flag.CommandLine)
+	logging.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	if opts.Level == nil {
+		// let the -log-level-<subsystem> flags decide what is logged
+		opts.Level = logging.ZapLevel()
+	}
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if admissionWebhook {
+		runAdmissionWebhook()
+		return
+	}
+
 	saasInit := newSaaSinitalizer(enablePrivateSaaS)
 
+	shutdownTracing, err := tracing.Setup(context.Background(), "baaz")
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+	// exit flushes the spans of the run first, os.Exit skips the defers.
+	exit := func(code int) {
+		_ = shutdownTracing(context.Background())
+		os.Exit(code)
+	}
+
 	if !enablePrivateSaaS {
 		go func() {
 			router := khota.NewRouter()
 			setupLog.Info(fmt.Sprintf("started baaz http server on :%s", saasInit.HttpServerPort))
 			if err := http.ListenAndServe(saasInit.HttpServerPort, handlers.CORS(handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", "Access-Control-Allow-Origin"}), handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "DELETE", "OPTIONS"}), handlers.AllowedOrigins([]string{"*"}))(router)); err != nil {
 				setupLog.Error(err, "unable to start http server")
-				os.Exit(1)
+				exit(1)
 			}
 		}()
 	}
@@ -118,7 +157,7 @@ func main() {
 			resp, err := createStream.CreateStream()
 			if err != nil && resp == 400 {
 				setupLog.Error(err, "create stream failed for parseable")
-				os.Exit(1)
+				exit(1)
 			}
 		}
 	}
@@ -134,43 +173,116 @@ func main() {
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+		exit(1)
 	}
 
-	if err = (dataplane_controller.NewDataplaneReconciler(mgr, enablePrivateSaaS, customerName)).SetupWithManager(mgr); err != nil {
+	// the indexes of the chart repos are shared by the releases of both controllers
+	repoCache := helm.NewRepoCache(filepath.Join(os.TempDir(), "baaz-helm-cache"), helm.DefaultIndexTTL)
+
+	if err = (dataplane_controller.NewDataplaneReconciler(mgr, enablePrivateSaaS, customerName, repoCache)).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Dataplane")
-		os.Exit(1)
+		exit(1)
 	}
 
-	if err = (app_controller.NewApplicationReconciler(mgr, enablePrivateSaaS, customerName)).SetupWithManager(mgr); err != nil {
+	if err = (app_controller.NewApplicationReconciler(mgr, enablePrivateSaaS, customerName, repoCache)).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Application")
-		os.Exit(1)
+		exit(1)
 	}
 
 	if err = (tenantinfra_controller.NewTenantsInfraReconciler(mgr, enablePrivateSaaS, customerName)).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "TenantInfra")
-		os.Exit(1)
+		exit(1)
 	}
 
 	if err = (tenant_controller.NewTenantsReconciler(mgr, enablePrivateSaaS, customerName)).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Tenant")
-		os.Exit(1)
+		exit(1)
+	}
+
+	if !enablePrivateSaaS {
+		// rollouts select the Applications of every customer
+		if err = (rollout_controller.NewRolloutReconciler(mgr)).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Rollout")
+			exit(1)
+		}
+		// git sources declare the customers of the whole control plane
+		if localRepositories {
+			git.InstallLocal()
+		}
+		if err = (gitsource_controller.NewGitSourceReconciler(mgr, localRepositories)).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GitSource")
+			exit(1)
+		}
+	}
+
+	if err := ctrlmetrics.Registry.Register(metrics.NewPhaseCollector(mgr.GetClient())); err != nil {
+		setupLog.Error(err, "unable to register baaz metrics")
+		exit(1)
+	}
+
+	if !enablePrivateSaaS {
+		usageSampler, err := metering_controller.NewUsageSampler(mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to create usage sampler")
+			exit(1)
+		}
+		if err := mgr.Add(usageSampler); err != nil {
+			setupLog.Error(err, "unable to add usage sampler")
+			exit(1)
+		}
 	}
 
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
+		exit(1)
 	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+		exit(1)
 	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
+		exit(1)
+	}
+}
+
+// runAdmissionWebhook serves the webhook placing the pods of tenants, in the
+// dataplanes baaz installs it into.
+func runAdmissionWebhook() {
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		HealthProbeBindAddress: admission.HealthProbeAddress,
+		Metrics: server.Options{
+			BindAddress: "0",
+		},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    admission.Port,
+			CertDir: admission.CertDir,
+		}),
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	admission.SetupWithManager(mgr)
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", mgr.GetWebhookServer().StartedChecker()); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting admission webhook")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running admission webhook")
 		os.Exit(1)
 	}
 }
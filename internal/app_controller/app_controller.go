@@ -8,14 +8,18 @@ import (
 	v1 "github.com/baazhq/baaz/api/v1/types"
 	"github.com/baazhq/baaz/internal/predicates"
 	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/helm"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/tracing"
 	"github.com/baazhq/baaz/pkg/utils"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
@@ -34,23 +38,29 @@ type ApplicationReconciler struct {
 	Recorder      record.EventRecorder
 	CustomerName  string
 	EnablePrivate bool
+	// RepoCache caches the indexes of the chart repos across releases
+	RepoCache *helm.RepoCache
 }
 
-func NewApplicationReconciler(mgr ctrl.Manager, enablePrivate bool, customerName string) *ApplicationReconciler {
-	initLogger := ctrl.Log.WithName("controllers").WithName("application")
+func NewApplicationReconciler(mgr ctrl.Manager, enablePrivate bool, customerName string, repoCache *helm.RepoCache) *ApplicationReconciler {
+	initLogger := logging.Logger(logging.Application)
 	return &ApplicationReconciler{
 		Client:        mgr.GetClient(),
 		Log:           initLogger,
 		Scheme:        mgr.GetScheme(),
-		ReconcileWait: lookupReconcileTime(),
+		ReconcileWait: lookupReconcileTime(initLogger),
 		Predicates:    predicates.GetPredicates(enablePrivate, customerName, mgr.GetClient()),
 		Recorder:      mgr.GetEventRecorderFor("applications-controller"),
+		RepoCache:     repoCache,
 	}
 }
 
-// +kubebuilder:rbac:groups=baaz.dev,resources=applications,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=baaz.dev,resources=applications/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=baaz.dev,resources=applications/finalizers,verbs=update
+//+kubebuilder:rbac:groups=baaz.dev,resources=applications,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=baaz.dev,resources=applications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=baaz.dev,resources=applications/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=baaz.dev,resources=applicationblueprints,verbs=get;list;watch
+
 func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
 	applicationObj := &v1.Applications{}
@@ -59,6 +69,15 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
+	ctx, span := tracing.StartReconcile(ctx, "ApplicationReconciler", applicationObj)
+	defer span.End()
+
+	ctx, log := logging.WithValues(ctx,
+		logging.KeyCustomer, applicationObj.Namespace,
+		logging.KeyDataplane, applicationObj.Spec.Dataplane,
+		logging.KeyApplication, applicationObj.Name,
+	)
+
 	dpObj := &v1.DataPlanesList{}
 	err = r.List(ctx, dpObj, &client.ListOptions{})
 	if err != nil {
@@ -97,7 +116,8 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	if err := r.do(ctx, applicationObj, &dataplane); err != nil {
-		klog.Errorf("failed to reconcile application: reason: %s", err.Error())
+		span.RecordError(err)
+		logging.Error(log, err, "failed to reconcile application")
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 	} else {
 		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
@@ -105,22 +125,27 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. The instances
+// of a blueprint are reconciled when it changes.
 func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1.Applications{}, blueprintIndex, indexBlueprint); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1.Applications{}).
-		WithEventFilter(r.Predicates).
+		For(&v1.Applications{}, builder.WithPredicates(r.Predicates)).
+		Watches(&v1.ApplicationBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.blueprintApplications)).
+		WithLogConstructor(logging.RequestLogger(r.Log)).
 		Complete(r)
 }
 
-func lookupReconcileTime() time.Duration {
+func lookupReconcileTime(log logr.Logger) time.Duration {
 	val, exists := os.LookupEnv("RECONCILE_WAIT")
 	if !exists {
 		return time.Second * 10
 	} else {
 		v, err := time.ParseDuration(val)
 		if err != nil {
-			klog.Error(err, err.Error())
+			log.Error(err, err.Error())
 			// Exit Program if not valid
 			os.Exit(1)
 		}
@@ -145,7 +170,11 @@ func (r *ApplicationReconciler) reconcileDelete(ctx context.Context, app *v1.App
 		return ctrl.Result{RequeueAfter: time.Second * 10}, err
 	}
 
-	applications := NewApplication(ctx, app, dataplane, r.Client, eksClientSet)
+	applications := NewApplication(ctx, app, dataplane, r.Client, eksClientSet, r.RepoCache)
+	if app.Spec.Blueprint != nil {
+		// the apps of the blueprint deployed, it may have changed since
+		applications.Apps = app.Status.ApplicationCurrentSpec.Applications
+	}
 
 	if err := applications.UninstallApplications(); err != nil {
 		return ctrl.Result{RequeueAfter: time.Second * 10}, err
@@ -153,7 +182,7 @@ func (r *ApplicationReconciler) reconcileDelete(ctx context.Context, app *v1.App
 
 	// remove our finalizer from the list and update it.
 	controllerutil.RemoveFinalizer(app, applicationFinalizer)
-	klog.Infof("Uninstalled Application [%s]", app.GetName())
+	ctrl.LoggerFrom(ctx).Info("uninstalled application")
 	if err := r.Client.Update(ctx, app.DeepCopyObject().(*v1.Applications)); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -0,0 +1,137 @@
+package app_controller
+
+import (
+	"context"
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/blueprint"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// renderBlueprint instantiates the blueprint of app for the size of its
+// tenant. The blueprint is read on every reconcile, so a new current version
+// is rolled out to the instances following it.
+func (r *ApplicationReconciler) renderBlueprint(ctx context.Context, app *v1.Applications) (*blueprint.Instance, error) {
+	bp := &v1.ApplicationBlueprint{}
+	if err := r.Get(ctx, client.ObjectKey{Name: app.Spec.Blueprint.Name}, bp); err != nil {
+		return nil, err
+	}
+
+	size := ""
+	tenant := &v1.Tenants{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: app.Spec.Tenant}, tenant); err == nil {
+		size = blueprint.TenantSize(bp, tenant)
+	} else if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	return blueprint.Render(bp, *app.Spec.Blueprint, size)
+}
+
+// blueprintIndex indexes the Applications by the name of their blueprint.
+const blueprintIndex = "spec.blueprint.name"
+
+func indexBlueprint(obj client.Object) []string {
+	app := obj.(*v1.Applications)
+	if app.Spec.Blueprint == nil {
+		return nil
+	}
+	return []string{app.Spec.Blueprint.Name}
+}
+
+// blueprintApplications enqueues the instances of a blueprint, so they roll
+// out its new versions.
+func (r *ApplicationReconciler) blueprintApplications(ctx context.Context, bp client.Object) []reconcile.Request {
+	apps := &v1.ApplicationsList{}
+	if err := r.List(ctx, apps, client.MatchingFields{blueprintIndex: bp.GetName()}); err != nil {
+		r.Log.Error(err, "failed to list the instances of blueprint", "blueprint", bp.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if !r.Predicates.Update(event.UpdateEvent{ObjectOld: app, ObjectNew: app}) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+	}
+	return requests
+}
+
+// removedApps returns the apps deployed from a previous version of the
+// blueprint that apps, the apps of its instance, no longer have.
+func removedApps(app *v1.Applications, apps []v1.AppSpec) []v1.AppSpec {
+	kept := make(map[string]bool, len(apps))
+	for _, spec := range apps {
+		kept[spec.ChartName()] = true
+	}
+	var removed []v1.AppSpec
+	for _, deployed := range app.Status.ApplicationCurrentSpec.Applications {
+		if !kept[deployed.ChartName()] {
+			removed = append(removed, deployed)
+		}
+	}
+	return removed
+}
+
+// uninstallRemoved uninstalls the apps removed from the blueprint, in the
+// reverse order of their dependencies, and forgets them once uninstalled.
+func (a *Application) uninstallRemoved(removed []v1.AppSpec, restConfig *rest.Config) error {
+	waves, err := installOrder(removed)
+	if err != nil {
+		// the apps they depended on may be kept, they are uninstalled together
+		waves = [][]v1.AppSpec{removed}
+	}
+	var errs []error
+	for i := len(waves) - 1; i >= 0; i-- {
+		if err := a.uninstallWave(waves[i], restConfig); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	_, _, err = utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
+		in := obj.(*v1.Applications)
+		forgotten := make(map[string]bool, len(removed))
+		for _, app := range removed {
+			chartName := app.ChartName()
+			forgotten[chartName] = true
+			delete(in.Status.AppStatus, chartName)
+			delete(in.Status.Reasons, chartName)
+			delete(in.Status.Releases, chartName)
+			delete(in.Status.Manifests, chartName)
+			delete(in.Status.Health, chartName)
+		}
+		var deployed []v1.AppSpec
+		for _, app := range in.Status.ApplicationCurrentSpec.Applications {
+			if !forgotten[app.ChartName()] {
+				deployed = append(deployed, app)
+			}
+		}
+		in.Status.ApplicationCurrentSpec.Applications = deployed
+		return in
+	})
+	return err
+}
+
+// patchBlueprint records the instance of the blueprint of app, when it
+// changed.
+func (r *ApplicationReconciler) patchBlueprint(ctx context.Context, app *v1.Applications, status v1.BlueprintStatus) error {
+	if app.Status.Blueprint != nil && *app.Status.Blueprint == status {
+		return nil
+	}
+	_, _, err := utils.PatchStatus(ctx, r.Client, app, func(obj client.Object) client.Object {
+		in := obj.(*v1.Applications)
+		in.Status.Blueprint = &status
+		return in
+	})
+	return err
+}
@@ -0,0 +1,86 @@
+package app_controller
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// installOrder returns the apps in waves, each app depending only on the
+// apps of the earlier waves. The apps of a wave keep their order in the
+// spec. It fails on unknown or duplicate apps and on dependency cycles.
+func installOrder(apps []v1.AppSpec) ([][]v1.AppSpec, error) {
+	pending := make(map[string]v1.AppSpec, len(apps))
+	for _, app := range apps {
+		if _, ok := pending[app.Name]; ok {
+			return nil, fmt.Errorf("app %s is declared twice", app.Name)
+		}
+		pending[app.Name] = app
+	}
+	for _, app := range apps {
+		for _, dep := range app.DependsOn {
+			if _, ok := pending[dep]; !ok {
+				return nil, fmt.Errorf("app %s depends on unknown app %s", app.Name, dep)
+			}
+		}
+	}
+
+	var waves [][]v1.AppSpec
+	installed := make(map[string]bool, len(apps))
+	for len(pending) > 0 {
+		var wave []v1.AppSpec
+		for _, app := range apps {
+			if _, ok := pending[app.Name]; ok && dependenciesIn(app, installed) {
+				wave = append(wave, app)
+			}
+		}
+		if len(wave) == 0 {
+			var cycle []string
+			for _, app := range apps {
+				if _, ok := pending[app.Name]; ok {
+					cycle = append(cycle, app.Name)
+				}
+			}
+			return nil, fmt.Errorf("apps %s depend on each other", strings.Join(cycle, ", "))
+		}
+		for _, app := range wave {
+			installed[app.Name] = true
+			delete(pending, app.Name)
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
+func dependenciesIn(app v1.AppSpec, installed map[string]bool) bool {
+	for _, dep := range app.DependsOn {
+		if !installed[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyPhase returns the phase of an app not installed yet whose
+// dependencies are not all ready, Blocked when one failed or is blocked
+// itself and Waiting otherwise, with its reason. It is empty when the app
+// can be installed.
+func dependencyPhase(app v1.AppSpec, phases map[string]v1.ApplicationPhase, ready map[string]bool) (v1.ApplicationPhase, string) {
+	var waiting []string
+	for _, dep := range app.DependsOn {
+		switch phases[dep] {
+		case v1.FailedA:
+			return v1.BlockedA, fmt.Sprintf("dependency %s failed", dep)
+		case v1.BlockedA:
+			return v1.BlockedA, fmt.Sprintf("dependency %s is blocked", dep)
+		}
+		if !ready[dep] {
+			waiting = append(waiting, dep)
+		}
+	}
+	if len(waiting) > 0 {
+		return v1.WaitingA, fmt.Sprintf("waiting for %s to be ready", strings.Join(waiting, ", "))
+	}
+	return "", ""
+}
@@ -0,0 +1,68 @@
+package app_controller
+
+import (
+	"testing"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func names(waves [][]v1.AppSpec) [][]string {
+	var out [][]string
+	for _, wave := range waves {
+		var appNames []string
+		for _, app := range wave {
+			appNames = append(appNames, app.Name)
+		}
+		out = append(out, appNames)
+	}
+	return out
+}
+
+func TestInstallOrder(t *testing.T) {
+	waves, err := installOrder([]v1.AppSpec{
+		{Name: "app", DependsOn: []string{"db", "operator"}},
+		{Name: "db"},
+		{Name: "dashboards", DependsOn: []string{"app"}},
+		{Name: "operator"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := names(waves)
+	if len(got) != 3 || len(got[0]) != 2 || got[0][0] != "db" || got[0][1] != "operator" ||
+		got[1][0] != "app" || got[2][0] != "dashboards" {
+		t.Fatalf("unexpected waves %v", got)
+	}
+
+	for _, apps := range [][]v1.AppSpec{
+		{{Name: "app", DependsOn: []string{"db"}}},
+		{{Name: "app"}, {Name: "app"}},
+		{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c"}},
+	} {
+		if _, err := installOrder(apps); err == nil {
+			t.Fatalf("expected %v to be rejected", apps)
+		}
+	}
+}
+
+func TestDependencyPhase(t *testing.T) {
+	app := v1.AppSpec{Name: "app", DependsOn: []string{"db", "operator"}}
+
+	phase, reason := dependencyPhase(app,
+		map[string]v1.ApplicationPhase{"db": v1.DeployedA, "operator": v1.InstallingA},
+		map[string]bool{"db": true})
+	if phase != v1.WaitingA || reason != "waiting for operator to be ready" {
+		t.Fatalf("expected the app to wait for the operator, got %s %q", phase, reason)
+	}
+
+	phase, _ = dependencyPhase(app,
+		map[string]v1.ApplicationPhase{"db": v1.FailedA, "operator": v1.DeployedA},
+		map[string]bool{"operator": true})
+	if phase != v1.BlockedA {
+		t.Fatalf("expected a failed dependency to block the app, got %s", phase)
+	}
+
+	if phase, _ := dependencyPhase(app, nil, map[string]bool{"db": true, "operator": true}); phase != "" {
+		t.Fatalf("expected the app to be installed, got %s", phase)
+	}
+}
@@ -3,14 +3,17 @@ package app_controller
 import (
 	"context"
 	"errors"
-	"fmt"
+	"time"
 
 	v1 "github.com/baazhq/baaz/api/v1/types"
 	"github.com/baazhq/baaz/pkg/aws/eks"
 	"github.com/baazhq/baaz/pkg/helm"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metrics"
 	"github.com/baazhq/baaz/pkg/utils"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/klog/v2"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -26,6 +29,9 @@ type Application struct {
 	K8sClientSet *kubernetes.Clientset
 	Client       client.Client
 	EksIC        eks.Eks
+	RepoCache    *helm.RepoCache
+	// Apps are the apps deployed, the apps of the spec or of its blueprint
+	Apps []v1.AppSpec
 }
 
 func NewApplication(
@@ -34,6 +40,7 @@ func NewApplication(
 	dp *v1.DataPlanes,
 	client client.Client,
 	k8sClientSet *kubernetes.Clientset,
+	repoCache *helm.RepoCache,
 ) *Application {
 	return &Application{
 		Context:      ctx,
@@ -42,111 +49,301 @@ func NewApplication(
 		EksIC:        eks.NewEks(ctx, dp),
 		Client:       client,
 		K8sClientSet: k8sClientSet,
+		RepoCache:    repoCache,
+		Apps:         app.Spec.Applications,
 	}
 }
 
-func getChartName(app v1.AppSpec) string {
-	return fmt.Sprintf("%s-%s", app.Name, app.Namespace)
+// currentSpec returns the spec the apps are deployed from, with the apps
+// of its blueprint.
+func (a *Application) currentSpec() v1.ApplicationSpec {
+	spec := *a.App.Spec.DeepCopy()
+	spec.Applications = a.Apps
+	return spec
+}
+
+// ecrToken logs in the ECR registries of the charts with the AWS
+// credentials of the dataplane.
+func (a *Application) ecrToken(registry string) (string, string, error) {
+	credentials, err := a.EksIC.GetRegistryCredentials(a.Context, registry)
+	if err != nil {
+		return "", "", err
+	}
+	return credentials.Username, credentials.Password, nil
 }
 
 type InstallChart struct {
 	Name string
 	Err  error
+	// AppName, Chart, Spec and SpecHash record the release of the chart
+	// once installed
+	AppName  string
+	Chart    helm.HelmAct
+	Spec     v1.ChartSpec
+	SpecHash string
 }
 
-// Deployer is responsible for deploying apps
+// Deployer is responsible for deploying apps. The apps are installed in the
+// order of their dependencies, each one once its dependencies are ready, and
+// the apps of a wave in parallel.
 func (a *Application) ReconcileApplicationDeployer() error {
 
-	ch := make(chan InstallChart, len(a.App.Spec.Applications))
-	count := 0
+	restConfig, err := a.EksIC.GetRestConfig()
+	if err != nil {
+		return err
+	}
 
-	for _, app := range a.App.Spec.Applications {
+	waves, err := installOrder(a.Apps)
+	if err != nil {
+		logging.Error(ctrl.LoggerFrom(a.Context), err, "invalid app dependencies")
+		// nothing more is installed until the dependencies are fixed
+		for _, app := range a.Apps {
+			chartName := app.ChartName()
+			if installed(a.App.Status.AppStatus[chartName]) {
+				continue
+			}
+			if err := a.patchApp(chartName, v1.BlockedA, err.Error()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
-		restConfig, err := a.EksIC.GetRestConfig()
-		if err != nil {
+	phases := make(map[string]v1.ApplicationPhase, len(a.Apps))
+	ready := make(map[string]bool, len(a.Apps))
+	for _, wave := range waves {
+		if err := a.deployWave(wave, restConfig, phases, ready); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		helm := helm.NewHelm(app.Name, a.App.Spec.Tenant, app.Spec.ChartName, app.Spec.RepoName,
-			app.Spec.RepoUrl, app.Spec.Version, restConfig, app.Spec.Values)
+// deployWave installs or upgrades the apps of a wave, recording their
+// phases and whether they are ready for the next waves.
+func (a *Application) deployWave(
+	wave []v1.AppSpec,
+	restConfig *rest.Config,
+	phases map[string]v1.ApplicationPhase,
+	ready map[string]bool,
+) error {
 
-		result, exists := helm.List(restConfig)
-		if exists {
-			for _, current := range a.App.Status.ApplicationCurrentSpec.Applications {
-				if current.Spec.Version != app.Spec.Version {
-					klog.Infof("Initating upgrade for application [%s], current version [%s], desired version [%s]", app.Spec.ChartName, current.Spec.Version, app.Spec.Version)
-					err = helm.Upgrade(restConfig)
-					if err != nil {
-						return err
-					}
-					if _, _, err := utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
-						in := obj.(*v1.Applications)
-						in.Status.Phase = v1.ApplicationPhase(result)
-						in.Status.ApplicationCurrentSpec = a.App.Spec
-						return in
-					}); err != nil {
+	ch := make(chan InstallChart, len(wave))
+	count := 0
+	// the manifests of the latest releases of the charts, by app name
+	releaseManifests := make(map[string]string, len(wave))
+
+	for _, app := range wave {
+
+		chartName := app.ChartName()
+		if app.Source != nil {
+			if _, applied := a.App.Status.Manifests[chartName]; !applied {
+				if phase, reason := dependencyPhase(app, phases, ready); phase != "" {
+					phases[app.Name] = phase
+					if err := a.patchApp(chartName, phase, reason); err != nil {
 						return err
 					}
+					continue
 				}
 			}
+			phase, err := a.reconcileManifests(app, restConfig)
+			if err != nil {
+				return err
+			}
+			phases[app.Name] = phase
+			continue
 		}
 
-		if !exists {
-			klog.Infof("installing chart: %s", app.Name)
+		// the values are resolved on every reconcile, so the changes of the
+		// referenced secrets are deployed
+		baseValues, versions, err := helm.ResolveValues(a.Context, a.Client, a.App.Namespace, app.Spec)
+		if err != nil {
+			return err
+		}
+		specHash, err := helm.SpecHash(app.Spec, versions)
+		if err != nil {
+			return err
+		}
 
-			count += 1
-			go func(ch chan InstallChart, app v1.AppSpec) {
-				c := InstallChart{
-					Name: getChartName(app),
-					Err:  nil,
-				}
-				if err := helm.Apply(restConfig); err != nil {
-					c.Err = err
-				}
-				ch <- c
-			}(ch, app)
+		credentials, err := helm.ResolveCredentials(a.Context, a.Client, a.App.Namespace, app.Spec, a.ecrToken)
+		if err != nil {
+			return err
+		}
 
-			_, _, err = utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
-				in := obj.(*v1.Applications)
-				if in.Status.AppStatus == nil {
-					in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
-				}
-				in.Status.AppStatus[getChartName(app)] = v1.InstallingA
-				in.Status.ApplicationCurrentSpec = a.App.Spec
-				return in
-			})
+		helm := helm.NewChartHelm(a.Context, a.RepoCache, app.Name, a.App.Spec.Tenant, app.Spec, restConfig, baseValues, credentials)
+
+		// unlike List, the history holds the failed releases too
+		releases, err := helm.History(restConfig)
+		if err != nil {
+			return err
+		}
+		if len(releases) > 0 {
+			releaseManifests[app.Name] = releases[0].Manifest
+			phase, err := a.reconcileRelease(app, helm, restConfig, specHash, releases)
 			if err != nil {
 				return err
 			}
+			phases[app.Name] = phase
+			continue
+		}
+
+		// the dependencies gate the install only, the installed apps are
+		// upgraded in their order without waiting
+		if phase, reason := dependencyPhase(app, phases, ready); phase != "" {
+			phases[app.Name] = phase
+			if err := a.patchApp(chartName, phase, reason); err != nil {
+				return err
+			}
+			continue
 		}
 
+		ctrl.LoggerFrom(a.Context).Info("installing chart", logging.KeyChart, app.Name)
+
+		count += 1
+		go func(ch chan InstallChart, app v1.AppSpec) {
+			c := InstallChart{
+				Name:     chartName,
+				Err:      nil,
+				AppName:  app.Name,
+				Chart:    helm,
+				Spec:     app.Spec,
+				SpecHash: specHash,
+			}
+			start := time.Now()
+			if err := helm.Apply(restConfig); err != nil {
+				c.Err = err
+			} else {
+				metrics.ObserveProvisioning(metrics.ResourceHelmRelease, string(a.DataPlanes.Spec.CloudInfra.CloudType), start)
+			}
+			ch <- c
+		}(ch, app)
+
+		_, _, err = utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
+			in := obj.(*v1.Applications)
+			if in.Status.AppStatus == nil {
+				in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
+			}
+			in.Status.AppStatus[chartName] = v1.InstallingA
+			setReason(in, chartName, "")
+			in.Status.ApplicationCurrentSpec = a.currentSpec()
+			return in
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	for i := 0; i < count; i += 1 {
 		chartCh := <-ch
 		var latestState v1.ApplicationPhase
+		failedSpecHash := ""
 		if chartCh.Err != nil {
-			klog.Errorf("installing chart %s failed, reason: %s", chartCh.Name, chartCh.Err.Error())
+			ctrl.LoggerFrom(a.Context).Error(chartCh.Err, "installing chart failed", logging.KeyChart, chartCh.Name)
 			latestState = v1.FailedA
+			failedSpecHash = chartCh.SpecHash
 		} else {
 			latestState = v1.DeployedA
 		}
+		phases[chartCh.AppName] = latestState
 
-		_, _, err := utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
+		releases, err := chartCh.Chart.History(restConfig)
+		if err != nil {
+			return err
+		}
+		if len(releases) > 0 {
+			releaseManifests[chartCh.AppName] = releases[0].Manifest
+		}
+		history := releaseHistory(releases, nil, 0, chartCh.SpecHash)
+		release := releaseStatus(history, recordSpecs(history, nil, chartCh.Spec, chartCh.SpecHash), failedSpecHash)
+
+		_, _, err = utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
 			in := obj.(*v1.Applications)
 			if in.Status.AppStatus == nil {
 				in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
 			}
+			if in.Status.Releases == nil {
+				in.Status.Releases = make(map[string]v1.ReleaseStatus)
+			}
 			in.Status.AppStatus[chartCh.Name] = latestState
+			in.Status.Releases[chartCh.Name] = release
 			return in
 		})
 		if err != nil {
 			return err
 		}
 	}
+
+	for _, app := range wave {
+		phase := phases[app.Name]
+		if phase != v1.DeployedA && phase != v1.RolledBackA && phase != v1.FailedA {
+			continue
+		}
+		// the resources of a failed release are inspected too, they may
+		// run the previous revision
+		if err := a.reconcileHealth(app, releaseManifests[app.Name]); err != nil {
+			return err
+		}
+		if phase == v1.FailedA {
+			continue
+		}
+		reason := checkReadiness(a.Context, a.K8sClientSet, a.App.Spec.Tenant, app.Readiness)
+		ready[app.Name] = reason == ""
+		if err := a.patchReason(app.ChartName(), reason); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// installed tells whether an app in phase was installed, or its install
+// was attempted.
+func installed(phase v1.ApplicationPhase) bool {
+	return phase != "" && phase != v1.PendingA && phase != v1.WaitingA && phase != v1.BlockedA
+}
+
+// patchApp records the phase of an app and its reason, when they changed.
+func (a *Application) patchApp(chartName string, phase v1.ApplicationPhase, reason string) error {
+	if a.App.Status.AppStatus[chartName] == phase && a.App.Status.Reasons[chartName] == reason {
+		return nil
+	}
+	_, _, err := utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
+		in := obj.(*v1.Applications)
+		if in.Status.AppStatus == nil {
+			in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
+		}
+		in.Status.AppStatus[chartName] = phase
+		setReason(in, chartName, reason)
+		return in
+	})
+	return err
+}
+
+// patchReason records why an app is not ready, when it changed.
+func (a *Application) patchReason(chartName, reason string) error {
+	if a.App.Status.Reasons[chartName] == reason {
+		return nil
+	}
+	_, _, err := utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
+		in := obj.(*v1.Applications)
+		setReason(in, chartName, reason)
+		return in
+	})
+	return err
+}
+
+func setReason(app *v1.Applications, chartName, reason string) {
+	if reason == "" {
+		delete(app.Status.Reasons, chartName)
+		return
+	}
+	if app.Status.Reasons == nil {
+		app.Status.Reasons = make(map[string]string)
+	}
+	app.Status.Reasons[chartName] = reason
+}
+
+// UninstallApplications uninstalls the apps in the reverse order of their
+// dependencies, the dependents before the apps they depend on.
 func (a *Application) UninstallApplications() error {
 
 	restConfig, err := a.EksIC.GetRestConfig()
@@ -154,9 +351,6 @@ func (a *Application) UninstallApplications() error {
 		return err
 	}
 
-	count := 0
-	ch := make(chan ChartCh, len(a.App.Spec.Applications))
-
 	if _, _, err := utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
 		in := obj.(*v1.Applications)
 		in.Status.Phase = v1.ApplicationPhase(v1.UninstallingA)
@@ -165,23 +359,45 @@ func (a *Application) UninstallApplications() error {
 		return err
 	}
 
-	for _, app := range a.App.Spec.Applications {
+	waves, err := installOrder(a.Apps)
+	if err != nil {
+		// without a valid order the apps are uninstalled together
+		waves = [][]v1.AppSpec{a.Apps}
+	}
+	for i := len(waves) - 1; i >= 0; i-- {
+		if err := a.uninstallWave(waves[i], restConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		helm := helm.NewHelm(app.Name, a.App.Spec.Tenant, app.Spec.ChartName, app.Spec.RepoName,
-			app.Spec.RepoUrl, app.Spec.Version, restConfig, app.Spec.Values)
+// uninstallWave uninstalls the apps of a wave in parallel.
+func (a *Application) uninstallWave(wave []v1.AppSpec, restConfig *rest.Config) error {
 
-		restConfig, err := a.EksIC.GetRestConfig()
-		if err != nil {
-			return err
+	count := 0
+	ch := make(chan ChartCh, len(wave))
+	var errs []error
+
+	for _, app := range wave {
+
+		if app.Source != nil {
+			if err := a.uninstallManifests(app, restConfig); err != nil {
+				errs = append(errs, err)
+			}
+			continue
 		}
 
+		helm := helm.NewHelm(a.Context, a.RepoCache, app.Name, a.App.Spec.Tenant, app.Spec.ChartName, app.Spec.RepoName,
+			app.Spec.RepoUrl, app.Spec.Version, restConfig, app.Spec.Values)
+
 		_, exists := helm.List(restConfig)
 
 		if exists {
 			count += 1
 			go func(ch chan ChartCh, app v1.AppSpec) {
 				c := ChartCh{
-					Name: app.Name,
+					Name: app.ChartName(),
 				}
 				if err := helm.Uninstall(restConfig); err != nil {
 					c.Err = err
@@ -191,13 +407,11 @@ func (a *Application) UninstallApplications() error {
 		}
 	}
 
-	var errs []error
-
 	for i := 0; i < count; i += 1 {
 		chartCh := <-ch
 		var latestState v1.ApplicationPhase
 		if chartCh.Err != nil {
-			klog.Errorf("uninstalling chart %s failed, reason: %s", chartCh.Name, chartCh.Err.Error())
+			ctrl.LoggerFrom(a.Context).Error(chartCh.Err, "uninstalling chart failed", logging.KeyChart, chartCh.Name)
 			errs = append(errs, chartCh.Err)
 			latestState = v1.FailedA
 		} else {
@@ -0,0 +1,82 @@
+package app_controller
+
+import (
+	"fmt"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/health"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// healthInterval is the time between two inspections of the resources of
+// an app, the apps being reconciled every second.
+const healthInterval = 30 * time.Second
+
+// reconcileHealth inspects the resources of an installed app on the
+// dataplane once healthInterval elapsed, and records their health.
+// manifest is the manifest of the latest release of a chart. The resources
+// failing to be read or inspected are reported Unknown, the deploy goes on.
+func (a *Application) reconcileHealth(app v1.AppSpec, manifest string) error {
+	chartName := app.ChartName()
+	previous, checked := a.App.Status.Health[chartName]
+	if checked && time.Since(previous.LastCheckTime.Time) < healthInterval {
+		return nil
+	}
+
+	now := time.Now()
+	report, err := a.inspectHealth(app, manifest, now)
+	if err != nil {
+		ctrl.LoggerFrom(a.Context).Error(err, "failed to check app health", logging.KeyChart, app.Name)
+		report = health.Unknown(err, now)
+	}
+	if report.Status == "" {
+		return nil
+	}
+	if report.Status != previous.Status {
+		ctrl.LoggerFrom(a.Context).Info("app health changed", logging.KeyChart, app.Name,
+			"from", previous.Status, "to", report.Status, "message", report.Message)
+	}
+
+	_, _, err = utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
+		in := obj.(*v1.Applications)
+		if in.Status.Health == nil {
+			in.Status.Health = make(map[string]v1.AppHealth)
+		}
+		in.Status.Health[chartName] = report
+		return in
+	})
+	return err
+}
+
+// inspectHealth returns the health of the resources of app, none before
+// they are recorded.
+func (a *Application) inspectHealth(app v1.AppSpec, manifest string, now time.Time) (v1.AppHealth, error) {
+	var objects []health.Object
+	if app.Source != nil {
+		applied, ok := a.App.Status.Manifests[app.ChartName()]
+		if !ok {
+			return v1.AppHealth{}, nil
+		}
+		objects = health.AppliedObjects(applied.Resources)
+	} else {
+		if manifest == "" {
+			// the install failed before the release was recorded
+			return v1.AppHealth{}, nil
+		}
+		var err error
+		if objects, err = health.ManifestObjects(manifest, a.App.Spec.Tenant); err != nil {
+			return v1.AppHealth{}, fmt.Errorf("reading the manifest: %w", err)
+		}
+	}
+
+	resources, err := health.Inspect(a.Context, a.K8sClientSet, objects, now)
+	if err != nil {
+		return v1.AppHealth{}, fmt.Errorf("inspecting the resources: %w", err)
+	}
+	return health.Report(resources, now), nil
+}
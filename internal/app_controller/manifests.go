@@ -0,0 +1,137 @@
+package app_controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/manifests"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+const defaultManifestInterval = 5 * time.Minute
+
+// reconcileManifests applies the manifests of an app with a source, when
+// its source changed or once its interval elapsed, and returns the phase
+// of the app.
+func (a *Application) reconcileManifests(app v1.AppSpec, restConfig *rest.Config) (v1.ApplicationPhase, error) {
+	chartName := app.ChartName()
+	previous, applied := a.App.Status.Manifests[chartName]
+
+	sourceHash, err := manifests.SourceHash(*app.Source)
+	if err != nil {
+		return "", err
+	}
+	interval := defaultManifestInterval
+	if app.Source.Interval != nil {
+		interval = app.Source.Interval.Duration
+	}
+	if applied && previous.SourceHash == sourceHash && previous.AppliedAt != nil &&
+		time.Since(previous.AppliedAt.Time) < interval {
+		return a.App.Status.AppStatus[chartName], nil
+	}
+
+	status := v1.ManifestStatus{
+		Revision:   previous.Revision,
+		SourceHash: sourceHash,
+		AppliedAt:  &metav1.Time{Time: time.Now()},
+		Resources:  previous.Resources,
+	}
+
+	rendered, err := manifests.Render(a.Context, a.Client, a.App.Namespace, *app.Source)
+	if err != nil {
+		// the objects applied are left in place until the source is fixed
+		ctrl.LoggerFrom(a.Context).Error(err, "rendering manifests failed", logging.KeyChart, app.Name)
+		return v1.FailedA, a.patchManifests(chartName, v1.FailedA, "rendering manifests: "+err.Error(), status)
+	}
+
+	applier, err := manifests.NewApplier(restConfig)
+	if err != nil {
+		return "", err
+	}
+
+	ctrl.LoggerFrom(a.Context).Info("applying manifests", logging.KeyChart, app.Name, "revision", rendered.Revision)
+	status.Revision = rendered.Revision
+	labels := a.manifestLabels(app)
+	status.Resources = applier.Apply(a.Context, a.App.Spec.Tenant, labels, rendered.Objects)
+	status.Resources = append(status.Resources, applier.Prune(a.Context, labels, manifests.Stale(previous.Resources, status.Resources))...)
+
+	phase, reason := v1.DeployedA, failedResources(status.Resources)
+	if reason != "" {
+		phase = v1.FailedA
+	}
+	return phase, a.patchManifests(chartName, phase, reason, status)
+}
+
+// uninstallManifests prunes the objects applied for an app with a source.
+func (a *Application) uninstallManifests(app v1.AppSpec, restConfig *rest.Config) error {
+	chartName := app.ChartName()
+	status, applied := a.App.Status.Manifests[chartName]
+	if !applied {
+		return nil
+	}
+
+	applier, err := manifests.NewApplier(restConfig)
+	if err != nil {
+		return err
+	}
+	status.Resources = applier.Prune(a.Context, a.manifestLabels(app), status.Resources)
+
+	phase, reason := v1.Uninstalled, failedResources(status.Resources)
+	if reason != "" {
+		phase = v1.FailedA
+	}
+	if err := a.patchManifests(chartName, phase, reason, status); err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("uninstalling %s: %s", chartName, reason)
+	}
+	return nil
+}
+
+// manifestLabels are the labels of the objects applied for app, they are
+// only pruned with them.
+func (a *Application) manifestLabels(app v1.AppSpec) map[string]string {
+	return map[string]string{
+		manifests.ApplicationLabel: a.App.Name,
+		manifests.AppLabel:         app.Name,
+	}
+}
+
+func (a *Application) patchManifests(chartName string, phase v1.ApplicationPhase, reason string, status v1.ManifestStatus) error {
+	_, _, err := utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
+		in := obj.(*v1.Applications)
+		if in.Status.AppStatus == nil {
+			in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
+		}
+		if in.Status.Manifests == nil {
+			in.Status.Manifests = make(map[string]v1.ManifestStatus)
+		}
+		in.Status.AppStatus[chartName] = phase
+		in.Status.Manifests[chartName] = status
+		setReason(in, chartName, reason)
+		in.Status.ApplicationCurrentSpec = a.currentSpec()
+		return in
+	})
+	return err
+}
+
+// failedResources explains the objects failing to apply or to be pruned,
+// empty when there are none.
+func failedResources(resources []v1.ResourceStatus) string {
+	var failed []string
+	for _, resource := range resources {
+		if resource.Phase == v1.FailedRS {
+			failed = append(failed, fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, resource.Message))
+		}
+	}
+	return strings.Join(failed, "; ")
+}
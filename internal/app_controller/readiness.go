@@ -0,0 +1,122 @@
+package app_controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// maxErrorLength bounds the errors of the checks reported in the reasons of
+// an app.
+const maxErrorLength = 128
+
+// checkReadiness runs the readiness checks of an installed app against its
+// cluster in namespace, the namespace of the tenant. The checks of other
+// namespaces are not run, the tenant can not probe the objects of others.
+// It returns why the app is not ready, empty when it is.
+func checkReadiness(ctx context.Context, clientset kubernetes.Interface, namespace string, checks []v1.ReadinessCheck) string {
+	var reasons []string
+	for _, check := range checks {
+		if check.Namespace != "" && check.Namespace != namespace {
+			reasons = append(reasons, fmt.Sprintf("check of namespace %s is outside of the namespace of the tenant", check.Namespace))
+			continue
+		}
+		if reason := runCheck(ctx, clientset, namespace, check); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func runCheck(ctx context.Context, clientset kubernetes.Interface, namespace string, check v1.ReadinessCheck) string {
+	switch {
+	case check.Deployment != "":
+		deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, check.Deployment, metav1.GetOptions{})
+		if err != nil {
+			return notReady("deployment", check.Deployment, err)
+		}
+		if deployment.Status.ObservedGeneration < deployment.Generation ||
+			!hasCondition(deploymentConditions(deployment), string(appsv1.DeploymentAvailable)) {
+			return fmt.Sprintf("deployment %s is not available", check.Deployment)
+		}
+	case check.Job != "":
+		job, err := clientset.BatchV1().Jobs(namespace).Get(ctx, check.Job, metav1.GetOptions{})
+		if err != nil {
+			return notReady("job", check.Job, err)
+		}
+		conditions := jobConditions(job)
+		if hasCondition(conditions, string(batchv1.JobFailed)) {
+			return fmt.Sprintf("job %s failed", check.Job)
+		}
+		if !hasCondition(conditions, string(batchv1.JobComplete)) {
+			return fmt.Sprintf("job %s has not completed", check.Job)
+		}
+	case check.HTTP != nil:
+		probe := check.HTTP
+		if _, err := clientset.CoreV1().Services(namespace).
+			ProxyGet(probe.Scheme, probe.Service, probe.Port, probe.Path, nil).
+			DoRaw(ctx); err != nil {
+			return notReady("service", probe.Service, err)
+		}
+	}
+	return ""
+}
+
+// notReady reports the error of a check. The errors of the api server only
+// report their code, as the proxied ones carry the response of the service,
+// and the others are cut to a line of maxErrorLength.
+func notReady(kind, name string, err error) string {
+	if apierrors.IsNotFound(err) {
+		return fmt.Sprintf("%s %s not found", kind, name)
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return fmt.Sprintf("%s %s: %s (%d)", kind, name, status.Status().Reason, status.Status().Code)
+	}
+	message := strings.Join(strings.Fields(err.Error()), " ")
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength] + "..."
+	}
+	return fmt.Sprintf("%s %s: %s", kind, name, message)
+}
+
+// deploymentConditions and jobConditions return the types of the true
+// conditions of their object.
+func deploymentConditions(deployment *appsv1.Deployment) []string {
+	var conditions []string
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Status == corev1.ConditionTrue {
+			conditions = append(conditions, string(condition.Type))
+		}
+	}
+	return conditions
+}
+
+func jobConditions(job *batchv1.Job) []string {
+	var conditions []string
+	for _, condition := range job.Status.Conditions {
+		if condition.Status == corev1.ConditionTrue {
+			conditions = append(conditions, string(condition.Type))
+		}
+	}
+	return conditions
+}
+
+func hasCondition(conditions []string, conditionType string) bool {
+	for _, condition := range conditions {
+		if condition == conditionType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package app_controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// proxyResponse answers the proxied requests of a fake clientset.
+type proxyResponse struct{ err error }
+
+func (r proxyResponse) DoRaw(context.Context) ([]byte, error) { return nil, r.err }
+
+func (r proxyResponse) Stream(context.Context) (io.ReadCloser, error) { return nil, r.err }
+
+func TestCheckReadiness(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "tenant", Generation: 2},
+			Status: appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue},
+			}},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "tenant"},
+		},
+	)
+	var serviceDown error
+	clientset.PrependProxyReactor("services", func(k8stesting.Action) (bool, restclient.ResponseWrapper, error) {
+		return true, proxyResponse{serviceDown}, nil
+	})
+
+	ctx := context.Background()
+	checks := []v1.ReadinessCheck{
+		{Deployment: "db"},
+		{HTTP: &v1.HTTPReadinessCheck{Service: "db", Port: "8080", Path: "/healthz"}},
+	}
+	if reason := checkReadiness(ctx, clientset, "tenant", checks); reason != "" {
+		t.Fatalf("expected the app to be ready, got %q", reason)
+	}
+
+	serviceDown = errors.New("connection refused")
+	if reason := checkReadiness(ctx, clientset, "tenant", checks); !strings.Contains(reason, "service db") {
+		t.Fatalf("expected the probe to fail, got %q", reason)
+	}
+
+	if reason := checkReadiness(ctx, clientset, "tenant", []v1.ReadinessCheck{{Job: "migrate"}}); reason != "job migrate has not completed" {
+		t.Fatalf("expected the job to be running, got %q", reason)
+	}
+	if reason := checkReadiness(ctx, clientset, "tenant", []v1.ReadinessCheck{{Deployment: "db", Namespace: "kube-system"}}); !strings.Contains(reason, "outside of the namespace of the tenant") {
+		t.Fatalf("expected the checks of other namespaces to be refused, got %q", reason)
+	}
+	if reason := checkReadiness(ctx, clientset, "tenant", []v1.ReadinessCheck{{Deployment: "db", Namespace: "tenant"}}); reason != "" {
+		t.Fatalf("expected the checks of the namespace of the tenant to run, got %q", reason)
+	}
+
+	serviceDown = apierrors.NewServiceUnavailable("secret: s3cr3t\nhost: db.internal")
+	if reason := checkReadiness(ctx, clientset, "tenant", checks); reason != "service db: ServiceUnavailable (503)" {
+		t.Fatalf("expected the response of the service not to be reported, got %q", reason)
+	}
+	serviceDown = errors.New(strings.Repeat("refused\n", 100))
+	if reason := checkReadiness(ctx, clientset, "tenant", checks); len(reason) > maxErrorLength+len("service db: ...") || strings.Contains(reason, "\n") {
+		t.Fatalf("expected the error to be cut to a line, got %q", reason)
+	}
+}
@@ -2,6 +2,7 @@ package app_controller
 
 import (
 	"context"
+	"errors"
 
 	v1 "github.com/baazhq/baaz/api/v1/types"
 	"github.com/baazhq/baaz/pkg/aws/eks"
@@ -19,11 +20,37 @@ func (r *ApplicationReconciler) do(ctx context.Context, app *v1.Applications, dp
 		return err
 	}
 
-	applications := NewApplication(ctx, app, dp, r.Client, eksClientSet)
+	applications := NewApplication(ctx, app, dp, r.Client, eksClientSet, r.RepoCache)
+
+	if app.Spec.Blueprint == nil {
+		return applications.ReconcileApplicationDeployer()
+	}
+
+	status := v1.BlueprintStatus{}
+	if app.Status.Blueprint != nil {
+		status.Version = app.Status.Blueprint.Version
+	}
+	instance, err := r.renderBlueprint(ctx, app)
+	if err != nil {
+		// the deployed version is left in place until the instance is fixed
+		status.Message = err.Error()
+		return errors.Join(err, r.patchBlueprint(ctx, app, status))
+	}
+	applications.Apps = instance.Applications
+
+	// the apps a new version of the blueprint removed are uninstalled first
+	if removed := removedApps(app, instance.Applications); len(removed) > 0 {
+		restConfig, err := applications.EksIC.GetRestConfig()
+		if err != nil {
+			return err
+		}
+		if err := applications.uninstallRemoved(removed, restConfig); err != nil {
+			return err
+		}
+	}
 
 	if err := applications.ReconcileApplicationDeployer(); err != nil {
 		return err
 	}
-
-	return nil
+	return r.patchBlueprint(ctx, app, v1.BlueprintStatus{Version: instance.Version})
 }
@@ -0,0 +1,207 @@
+package app_controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/helm"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// reconcileRelease upgrades the release of a chart when its spec hashes
+// differently from the deployed revision, or rolls it back to the revision
+// the spec was rolled back to. A failed upgrade is rolled back to the
+// previous revision unless disabled, and not retried until the spec changes.
+// releases are the revisions of the release, the newest first. It returns
+// the phase of the chart.
+func (a *Application) reconcileRelease(
+	app v1.AppSpec,
+	chart helm.HelmAct,
+	restConfig *rest.Config,
+	specHash string,
+	releases []*release.Release,
+) (v1.ApplicationPhase, error) {
+	log := ctrl.LoggerFrom(a.Context).WithValues(logging.KeyChart, app.Spec.ChartName)
+	chartName := app.ChartName()
+	status := a.App.Status.Releases[chartName]
+	since := releases[0].Version
+
+	if len(status.History) == 0 && currentVersion(a.App.Status.ApplicationCurrentSpec, app) == app.Spec.Version {
+		// the releases deployed before their spec was hashed are adopted
+		// as they are, unless their version changed
+		history := releaseHistory(releases, nil, since-1, specHash)
+		specs := recordSpecs(history, nil, app.Spec, specHash)
+		return v1.DeployedA, a.patchRelease(chartName, releaseStatus(history, specs, ""), v1.DeployedA)
+	}
+	if status.SpecHash == specHash || status.FailedSpecHash == specHash {
+		return a.App.Status.AppStatus[chartName], nil
+	}
+
+	var err error
+	if target := findRevision(status.History, app.RollbackRevision); target != nil && target.SpecHash == specHash {
+		log.Info("rolling back chart", "revision", target.Revision)
+		err = chart.Rollback(restConfig, target.Revision)
+	} else {
+		log.Info("upgrading chart", "from", currentVersion(a.App.Status.ApplicationCurrentSpec, app), "to", app.Spec.Version)
+		err = chart.Upgrade(restConfig)
+	}
+
+	phase, failedSpecHash := v1.DeployedA, ""
+	if err != nil {
+		logging.Error(log, err, "upgrading chart failed")
+		phase, failedSpecHash = v1.FailedA, specHash
+		if !a.App.Spec.Upgrade.DisableRollback {
+			if err := chart.Rollback(restConfig, 0); err != nil {
+				logging.Error(log, err, "rolling back chart failed")
+			} else {
+				phase = v1.RolledBackA
+			}
+		}
+	}
+
+	releases, err = chart.History(restConfig)
+	if err != nil {
+		return "", err
+	}
+	history := releaseHistory(releases, status.History, since, specHash)
+	specs := recordSpecs(history, status.Specs, app.Spec, specHash)
+	return phase, a.patchRelease(chartName, releaseStatus(history, specs, failedSpecHash), phase)
+}
+
+// patchRelease records the release and the phase of a chart, and the spec
+// the applications were deployed from.
+func (a *Application) patchRelease(chartName string, status v1.ReleaseStatus, phase v1.ApplicationPhase) error {
+	_, _, err := utils.PatchStatus(a.Context, a.Client, a.App, func(obj client.Object) client.Object {
+		in := obj.(*v1.Applications)
+		if in.Status.AppStatus == nil {
+			in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
+		}
+		if in.Status.Releases == nil {
+			in.Status.Releases = make(map[string]v1.ReleaseStatus)
+		}
+		in.Status.Phase = phase
+		in.Status.AppStatus[chartName] = phase
+		in.Status.Releases[chartName] = status
+		in.Status.ApplicationCurrentSpec = a.currentSpec()
+		return in
+	})
+	return err
+}
+
+// currentVersion returns the version app was last deployed with, its spec
+// version when it was not.
+func currentVersion(current v1.ApplicationSpec, app v1.AppSpec) string {
+	for _, deployed := range current.Applications {
+		if deployed.Name == app.Name {
+			return deployed.Spec.Version
+		}
+	}
+	return app.Spec.Version
+}
+
+func findRevision(history []v1.ReleaseRevision, revision int) *v1.ReleaseRevision {
+	for i := range history {
+		if revision > 0 && history[i].Revision == revision {
+			return &history[i]
+		}
+	}
+	return nil
+}
+
+// releaseHistory returns the revisions of the release, the newest first,
+// with the spec hashes recorded for them. The revisions newer than since
+// were deployed from specHash, or from the spec of the revision they rolled
+// back to.
+func releaseHistory(
+	releases []*release.Release,
+	recorded []v1.ReleaseRevision,
+	since int,
+	specHash string,
+) []v1.ReleaseRevision {
+	known := make(map[int]v1.ReleaseRevision, len(recorded))
+	for _, revision := range recorded {
+		known[revision.Revision] = revision
+	}
+
+	history := make([]v1.ReleaseRevision, len(releases))
+	// oldest first, so the rollbacks find the spec of their target
+	for i := len(releases) - 1; i >= 0; i-- {
+		rel := releases[i]
+		revision := v1.ReleaseRevision{Revision: rel.Version}
+		if rel.Info != nil {
+			revision.Status = rel.Info.Status.String()
+			revision.Description = rel.Info.Description
+			revision.DeployedAt = metav1.NewTime(rel.Info.LastDeployed.Time)
+		}
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			revision.ChartVersion = rel.Chart.Metadata.Version
+		}
+
+		var target int
+		switch previous, ok := known[rel.Version]; {
+		case ok:
+			revision.SpecHash = previous.SpecHash
+		case rel.Version <= since:
+		case rel.Info != nil && sscanRollback(rel.Info.Description, &target):
+			revision.SpecHash = known[target].SpecHash
+		default:
+			revision.SpecHash = specHash
+		}
+		known[rel.Version] = revision
+		history[i] = revision
+	}
+	return history
+}
+
+// recordSpecs returns the specs of the revisions of history, the ones
+// recorded and spec, of specHash, unless larger than MaxRecordedSpecSize.
+func recordSpecs(history []v1.ReleaseRevision, recorded map[string]v1.ChartSpec, spec v1.ChartSpec, specHash string) map[string]v1.ChartSpec {
+	var specs map[string]v1.ChartSpec
+	for _, revision := range history {
+		if revision.SpecHash == "" {
+			continue
+		}
+		known, ok := recorded[revision.SpecHash]
+		if !ok && revision.SpecHash == specHash {
+			if data, err := json.Marshal(spec); err == nil && len(data) <= v1.MaxRecordedSpecSize {
+				known, ok = *spec.DeepCopy(), true
+			}
+		}
+		if !ok {
+			continue
+		}
+		if specs == nil {
+			specs = make(map[string]v1.ChartSpec)
+		}
+		specs[revision.SpecHash] = known
+	}
+	return specs
+}
+
+// sscanRollback reads the revision a rollback revision restored from its
+// description, set by helm.
+func sscanRollback(description string, target *int) bool {
+	_, err := fmt.Sscanf(description, "Rollback to %d", target)
+	return err == nil
+}
+
+// releaseStatus returns the status of a release from its history, the
+// newest revision first.
+func releaseStatus(history []v1.ReleaseRevision, specs map[string]v1.ChartSpec, failedSpecHash string) v1.ReleaseStatus {
+	status := v1.ReleaseStatus{History: history, Specs: specs, FailedSpecHash: failedSpecHash}
+	for _, revision := range history {
+		if revision.Status == release.StatusDeployed.String() {
+			status.Revision, status.SpecHash = revision.Revision, revision.SpecHash
+			break
+		}
+	}
+	return status
+}
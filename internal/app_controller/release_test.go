@@ -0,0 +1,52 @@
+package app_controller
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func makeRelease(version int, status release.Status, description string) *release.Release {
+	return &release.Release{Version: version, Info: &release.Info{Status: status, Description: description}}
+}
+
+func TestReleaseHistory(t *testing.T) {
+	recorded := releaseHistory([]*release.Release{
+		makeRelease(2, release.StatusDeployed, "Upgrade complete"),
+		makeRelease(1, release.StatusSuperseded, "Install complete"),
+	}, nil, 1, "v2")
+	if recorded[0].SpecHash != "v2" || recorded[1].SpecHash != "" {
+		t.Fatalf("expected only the revisions after since to be attributed, got %+v", recorded)
+	}
+
+	history := releaseHistory([]*release.Release{
+		makeRelease(4, release.StatusDeployed, "Rollback to 2"),
+		makeRelease(3, release.StatusFailed, "Upgrade failed"),
+		makeRelease(2, release.StatusSuperseded, "Upgrade complete"),
+		makeRelease(1, release.StatusSuperseded, "Install complete"),
+	}, recorded, 2, "v3")
+
+	if history[1].SpecHash != "v3" {
+		t.Fatalf("expected the failed upgrade to be attributed to the spec, got %+v", history[1])
+	}
+	if history[0].SpecHash != "v2" {
+		t.Fatalf("expected the rollback to carry the spec of its target, got %+v", history[0])
+	}
+
+	specs := recordSpecs(history, map[string]v1.ChartSpec{"v2": {Version: "1.1.0"}, "v1": {Version: "1.0.0"}}, v1.ChartSpec{Version: "1.2.0"}, "v3")
+	if len(specs) != 2 || specs["v2"].Version != "1.1.0" || specs["v3"].Version != "1.2.0" {
+		t.Fatalf("expected the specs of the revisions of the history, once each, got %+v", specs)
+	}
+	large := v1.ChartSpec{Version: "1.3.0", Values: []string{strings.Repeat("x", v1.MaxRecordedSpecSize)}}
+	if specs := recordSpecs(history, nil, large, "v3"); len(specs) != 0 {
+		t.Fatalf("expected a spec larger than the limit not to be recorded, got %d specs", len(specs))
+	}
+
+	status := releaseStatus(history, specs, "v3")
+	if status.Revision != 4 || status.SpecHash != "v2" || status.FailedSpecHash != "v3" {
+		t.Fatalf("unexpected release status %+v", status)
+	}
+}
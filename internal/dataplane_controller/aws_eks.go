@@ -19,15 +19,18 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	v1 "github.com/baazhq/baaz/api/v1/types"
 	"github.com/baazhq/baaz/pkg/aws/eks"
 	"github.com/baazhq/baaz/pkg/aws/network"
 	"github.com/baazhq/baaz/pkg/helm"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metrics"
 	"github.com/baazhq/baaz/pkg/store"
 	"github.com/baazhq/baaz/pkg/utils"
 )
@@ -35,8 +38,18 @@ import (
 const (
 	awsEbsCsiDriver string = "aws-ebs-csi-driver"
 	vpcCni          string = "vpc-cni"
+	coreDNS         string = "coredns"
+	kubeProxy       string = "kube-proxy"
+
+	// vpcCniVersion is the vpc-cni version installed by default, listing
+	// vpc-cni with latest-compatible tracks the latest one instead.
+	vpcCniVersion string = "v1.15.0-eksbuild.2"
 )
 
+// coreAddons are never deleted by baaz, the cluster networking breaks
+// without them. They stay when they are removed from the spec.
+var coreAddons = []string{vpcCni, coreDNS, kubeProxy}
+
 func (r *DataPlaneReconciler) reconcileAwsEnvironment(ctx context.Context, dp *v1.DataPlanes) error {
 
 	eksClient := eks.NewEks(ctx, dp)
@@ -46,12 +59,13 @@ func (r *DataPlaneReconciler) reconcileAwsEnvironment(ctx context.Context, dp *v
 	}
 
 	awsEnv := &awsEnv{
-		ctx:     ctx,
-		dp:      dp,
-		eksIC:   eksClient,
-		client:  r.Client,
-		store:   r.NgStore,
-		network: network,
+		ctx:       ctx,
+		dp:        dp,
+		eksIC:     eksClient,
+		client:    r.Client,
+		store:     r.NgStore,
+		network:   network,
+		repoCache: r.RepoCache,
 	}
 
 	if err := awsEnv.reconcileNetwork(ctx); err != nil {
@@ -62,10 +76,18 @@ func (r *DataPlaneReconciler) reconcileAwsEnvironment(ctx context.Context, dp *v
 		return fmt.Errorf("error in reconciling aws eks cluster: %s", err.Error())
 	}
 
-	if err := awsEnv.reconcileClusterAutoscaler(); err != nil {
+	if dp.UsesKarpenter() {
+		if err := awsEnv.reconcileKarpenter(); err != nil {
+			return fmt.Errorf("error in reconciling karpenter: %s", err.Error())
+		}
+	} else if err := awsEnv.reconcileClusterAutoscaler(); err != nil {
 		return fmt.Errorf("error in reconciling cluster autoscaler: %s", err.Error())
 	}
 
+	if err := awsEnv.reconcileAdmissionWebhook(); err != nil {
+		return fmt.Errorf("error in reconciling admission webhook: %s", err.Error())
+	}
+
 	// bootstrap dataplane with apps
 	if err := awsEnv.reconcileAwsApplications(); err != nil {
 		return fmt.Errorf("error in reconciling applications: %s", err.Error())
@@ -81,6 +103,13 @@ type awsEnv struct {
 	client  client.Client
 	store   store.Store
 	network network.Network
+	// repoCache caches the indexes of the chart repos across releases
+	repoCache *helm.RepoCache
+}
+
+// log returns the reconcile logger carrying the customer and dataplane.
+func (ae *awsEnv) log() logr.Logger {
+	return ctrl.LoggerFrom(ae.ctx)
 }
 
 var (
@@ -125,7 +154,7 @@ F0519 09:29:10.091232       1 aws_cloud_provider.go:460] Failed to create AWS Ma
 */
 
 func (ae *awsEnv) reconcileClusterAutoscaler() error {
-	klog.Info("reconciling cluster autoscaler")
+	ae.log().V(1).Info("reconciling cluster autoscaler")
 
 	if ae.dp.Status.NodegroupStatus[ae.dp.Spec.CloudInfra.Eks.Name+"-system"] != string(types.NodegroupStatusActive) {
 		return nil
@@ -186,6 +215,8 @@ func (ae *awsEnv) reconcileClusterAutoscaler() error {
 
 	if ae.dp.Status.ClusterAutoScalerStatus != v1.DeployedA {
 		helm := helm.NewHelm(
+			ae.ctx,
+			ae.repoCache,
 			"cas",
 			"kube-system",
 			"cluster-autoscaler",
@@ -222,7 +253,7 @@ func (ae *awsEnv) reconcileClusterAutoscaler() error {
 			chartCh := <-ch
 			var latestState v1.ApplicationPhase
 			if chartCh.Err != nil {
-				klog.Errorf("installing chart %s failed, reason: %s", chartCh.Name, chartCh.Err.Error())
+				ae.log().Error(chartCh.Err, "deploying chart failed", logging.KeyChart, chartCh.Name)
 				latestState = v1.FailedA
 			} else {
 				latestState = v1.DeployedA
@@ -247,19 +278,17 @@ func (ae *awsEnv) reconcileAwsEks() error {
 	if err != nil {
 		var ngNotFound *types.ResourceNotFoundException
 		if errors.As(err, &ngNotFound) {
-			klog.Infof("Creating EKS Control plane: %s for Environment: %s/%s", ae.dp.Spec.CloudInfra.Eks.Name, ae.dp.Namespace, ae.dp.Name)
-			klog.Info("Updating Environment status to creating")
+			ae.log().Info("creating eks control plane", logging.KeyCluster, ae.dp.Spec.CloudInfra.Eks.Name)
 
 			clusterRoleOutput, err := ae.eksIC.CreateClusterIamRole()
 			if err != nil {
 				return fmt.Errorf("failed to create cluster iam role: %s", err.Error())
 			}
 
-			klog.Infof("Cluster Role [%s] Created", *clusterRoleOutput.Role.RoleName)
+			ae.log().Info("created cluster iam role", "role", *clusterRoleOutput.Role.RoleName)
 
 			createEksResult := ae.eksIC.CreateEks()
 			if createEksResult.Success {
-				fmt.Println(createEksResult.Success)
 				if _, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
 					in := obj.(*v1.DataPlanes)
 					in.Status.Phase = v1.CreatingD
@@ -273,57 +302,40 @@ func (ae *awsEnv) reconcileAwsEks() error {
 					})
 					return in
 				}); err != nil {
-					fmt.Println(err)
 					return err
 				}
 
-				klog.Info("Successfully initiated kubernetes control plane")
+				ae.log().Info("initiated eks control plane creation", logging.KeyCluster, ae.dp.Spec.CloudInfra.Eks.Name)
 				return nil
 
 			} else {
-				klog.Errorf(createEksResult.Result)
+				ae.log().Error(errors.New(createEksResult.Result), "failed to create eks control plane", logging.KeyCluster, ae.dp.Spec.CloudInfra.Eks.Name)
 			}
 
 		} else {
-			klog.Errorf("Dataplane Describe Fail [%s]", err.Error())
+			logging.Error(ae.log(), err, "failed to describe eks cluster", logging.KeyCluster, ae.dp.Spec.CloudInfra.Eks.Name)
 		}
 
 	}
 	if eksDescribeClusterOutput != nil {
 		if eksDescribeClusterOutput.Cluster.Status == types.ClusterStatusActive {
-			// checking for version upgrade
-			statusVersion := ae.dp.Status.Version
-			specVersion := ae.dp.Spec.CloudInfra.Eks.Version
-			if statusVersion != "" && statusVersion != specVersion && *eksDescribeClusterOutput.Cluster.Version != specVersion {
-				klog.Info("Updating Kubernetes version to: ", ae.dp.Spec.CloudInfra.Eks.Version)
-				if _, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
-					in := obj.(*v1.DataPlanes)
-					in.Status.Phase = v1.UpdatingD
-					in.Status.Conditions = in.AddCondition(v1.DataPlaneCondition{
-						Type:               v1.VersionUpgradeInitiated,
-						Status:             corev1.ConditionTrue,
-						LastUpdateTime:     metav1.Time{Time: time.Now()},
-						LastTransitionTime: metav1.Time{Time: time.Now()},
-						Reason:             string(eks.EksControlPlaneUpgradedReason),
-						Message:            string(eks.EksControlPlaneUpgradedIntiatedMsg),
-					})
-					return in
-				}); err != nil {
-					return err
-				}
-				result := ae.eksIC.UpdateEks()
-				if !result.Success {
-					return errors.New(result.Result)
-				}
-				klog.Info("Successfully initiated version update")
+			if err := ae.reconcileUpgrade(eksDescribeClusterOutput.Cluster); err != nil {
+				return err
 			}
 
-			klog.Info("Sync Cluster status and version")
+			ae.log().V(1).Info("syncing eks cluster status and version")
+
+			if !ae.dp.HasCondition(v1.ControlPlaneCreated) && eksDescribeClusterOutput.Cluster.CreatedAt != nil {
+				metrics.ObserveProvisioning(metrics.ResourceEks, string(ae.dp.Spec.CloudInfra.CloudType), *eksDescribeClusterOutput.Cluster.CreatedAt)
+			}
 
 			if _, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
 				in := obj.(*v1.DataPlanes)
 				in.Status.Phase = v1.ActiveD
-				in.Status.Version = in.Spec.CloudInfra.Eks.Version
+				if in.Status.Upgrade != nil {
+					in.Status.Phase = v1.UpdatingD
+				}
+				in.Status.Version = aws.StringValue(eksDescribeClusterOutput.Cluster.Version)
 				in.Status.Conditions = in.AddCondition(v1.DataPlaneCondition{
 					Type:               v1.ControlPlaneCreated,
 					Status:             corev1.ConditionTrue,
@@ -338,7 +350,7 @@ func (ae *awsEnv) reconcileAwsEks() error {
 			}
 
 		} else if eksDescribeClusterOutput.Cluster.Status == types.ClusterStatusCreating {
-			klog.Infof("EKS Cluster Control Plane [%s] in creating state", ae.dp.Spec.CloudInfra.Eks.Name)
+			ae.log().Info("eks control plane is creating", logging.KeyCluster, ae.dp.Spec.CloudInfra.Eks.Name)
 			if _, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
 				in := obj.(*v1.DataPlanes)
 				in.Status.Phase = v1.ActiveD
@@ -356,9 +368,9 @@ func (ae *awsEnv) reconcileAwsEks() error {
 				return err
 			}
 		} else if eksDescribeClusterOutput.Cluster.Status == types.ClusterStatusUpdating {
-			klog.Infof("EKS Cluster Control Plane [%s] in updated state", ae.dp.Spec.CloudInfra.Eks.Name)
+			ae.log().Info("eks control plane is updating", logging.KeyCluster, ae.dp.Spec.CloudInfra.Eks.Name)
 		} else if eksDescribeClusterOutput.Cluster.Status == types.ClusterStatusDeleting {
-			klog.Infof("EKS Cluster Control Plane [%s] in deleting state", ae.dp.Spec.CloudInfra.Eks.Name)
+			ae.log().Info("eks control plane is deleting", logging.KeyCluster, ae.dp.Spec.CloudInfra.Eks.Name)
 		}
 
 	}
@@ -385,7 +397,7 @@ func (ae *awsEnv) reconcileAwsEks() error {
 			return err
 		}
 
-		if err := ae.ReconcileDefaultAddons(); err != nil {
+		if err := ae.reconcileAddons(aws.StringValue(eksDescribeClusterOutput.Cluster.Version)); err != nil {
 			return err
 		}
 
@@ -739,7 +751,7 @@ func (ae *awsEnv) reconcileNetwork(ctx context.Context) error {
 
 		subnet, err := ae.network.CreateSubnet(ctx, subnetInput)
 		if err != nil {
-			fmt.Println(err.Error())
+			logging.Error(ctrl.LoggerFrom(ctx), err, "failed to create subnet", "subnet", subnetName)
 			continue
 		}
 
@@ -904,16 +916,30 @@ func getChartName(app v1.AppSpec) string {
 	return app.Spec.ChartName
 }
 
+// ecrToken logs in the ECR registries of the charts with the AWS
+// credentials of the dataplane.
+func (ae *awsEnv) ecrToken(registry string) (string, string, error) {
+	credentials, err := ae.eksIC.GetRegistryCredentials(ae.ctx, registry)
+	if err != nil {
+		return "", "", err
+	}
+	return credentials.Username, credentials.Password, nil
+}
+
 type ChartCh struct {
 	Name string
 	Err  error
+	// SpecHash is the spec hash the chart was deployed with
+	SpecHash string
 }
 
 // bootstrap applications for aws eks dataplanes
 // check if system nodepool is active
 // once its active install applications
+// the deployed applications are upgraded when their spec hash changes, like
+// when a Secret or ConfigMap of their values does
 func (ae *awsEnv) reconcileAwsApplications() error {
-	klog.Info("reconciling dataplane applications")
+	ae.log().V(1).Info("reconciling dataplane applications")
 
 	if ae.dp.Status.NodegroupStatus[ae.dp.Spec.CloudInfra.Eks.Name+"-system"] != string(types.NodegroupStatusActive) {
 		return nil
@@ -926,7 +952,18 @@ func (ae *awsEnv) reconcileAwsApplications() error {
 
 		chartStatus := ae.dp.Status.AppStatus[getChartName(app)]
 
-		if chartStatus == v1.DeployedA {
+		// the values are resolved on every reconcile, so the changes of the
+		// referenced secrets are deployed
+		baseValues, versions, err := helm.ResolveValues(ae.ctx, ae.client, ae.dp.Namespace, app.Spec)
+		if err != nil {
+			return err
+		}
+		specHash, err := helm.SpecHash(app.Spec, versions)
+		if err != nil {
+			return err
+		}
+		deployedHash := ae.dp.Status.AppSpecHashes[getChartName(app)]
+		if chartStatus == v1.DeployedA && deployedHash == specHash {
 			continue
 		}
 
@@ -935,45 +972,52 @@ func (ae *awsEnv) reconcileAwsApplications() error {
 			return err
 		}
 
-		helm := helm.NewHelm(
-			app.Name,
-			app.Namespace,
-			app.Spec.ChartName,
-			app.Spec.RepoName,
-			app.Spec.RepoUrl,
-			app.Spec.Version,
-			restConfig,
-			app.Spec.Values,
-		)
+		credentials, err := helm.ResolveCredentials(ae.ctx, ae.client, ae.dp.Namespace, app.Spec, ae.ecrToken)
+		if err != nil {
+			return err
+		}
 
-		_, exists := helm.List(restConfig)
+		helm := helm.NewChartHelm(ae.ctx, ae.repoCache, app.Name, app.Namespace, app.Spec, restConfig, baseValues, credentials)
 
-		if !exists {
-			klog.Infof("installing chart: %s", app.Name)
+		_, exists := helm.List(restConfig)
+		// a failed release is retried once its spec changes
+		if exists && chartStatus == v1.FailedA && deployedHash == specHash {
+			continue
+		}
 
-			count += 1
-			go func(ch chan ChartCh, app v1.AppSpec) {
-				c := ChartCh{
-					Name: getChartName(app),
-					Err:  nil,
-				}
-				if err := helm.Apply(restConfig); err != nil {
-					c.Err = err
-				}
-				ch <- c
-			}(ch, app)
+		count += 1
+		apply := helm.Apply
+		if exists {
+			ae.log().Info("upgrading chart", logging.KeyChart, app.Name)
+			apply = helm.Upgrade
+		} else {
+			ae.log().Info("installing chart", logging.KeyChart, app.Name)
+		}
+		go func(ch chan ChartCh, app v1.AppSpec) {
+			c := ChartCh{
+				Name:     getChartName(app),
+				Err:      nil,
+				SpecHash: specHash,
+			}
+			start := time.Now()
+			if err := apply(restConfig); err != nil {
+				c.Err = err
+			} else if !exists {
+				metrics.ObserveProvisioning(metrics.ResourceHelmRelease, string(ae.dp.Spec.CloudInfra.CloudType), start)
+			}
+			ch <- c
+		}(ch, app)
 
-			_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
-				in := obj.(*v1.DataPlanes)
-				if in.Status.AppStatus == nil {
-					in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
-				}
-				in.Status.AppStatus[getChartName(app)] = v1.InstallingA
-				return in
-			})
-			if err != nil {
-				return err
+		_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
+			in := obj.(*v1.DataPlanes)
+			if in.Status.AppStatus == nil {
+				in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
 			}
+			in.Status.AppStatus[getChartName(app)] = v1.InstallingA
+			return in
+		})
+		if err != nil {
+			return err
 		}
 	}
 
@@ -981,7 +1025,7 @@ func (ae *awsEnv) reconcileAwsApplications() error {
 		chartCh := <-ch
 		var latestState v1.ApplicationPhase
 		if chartCh.Err != nil {
-			klog.Errorf("installing chart %s failed, reason: %s", chartCh.Name, chartCh.Err.Error())
+			ae.log().Error(chartCh.Err, "deploying chart failed", logging.KeyChart, chartCh.Name)
 			latestState = v1.FailedA
 		} else {
 			latestState = v1.DeployedA
@@ -993,6 +1037,10 @@ func (ae *awsEnv) reconcileAwsApplications() error {
 				in.Status.AppStatus = make(map[string]v1.ApplicationPhase)
 			}
 			in.Status.AppStatus[chartCh.Name] = latestState
+			if in.Status.AppSpecHashes == nil {
+				in.Status.AppSpecHashes = make(map[string]string)
+			}
+			in.Status.AppSpecHashes[chartCh.Name] = chartCh.SpecHash
 			return in
 		})
 		if err != nil {
@@ -1047,18 +1095,18 @@ func (ae *awsEnv) reconcileOIDCProvider(clusterOutput *awseks.DescribeClusterOut
 }
 
 func (ae *awsEnv) reconcilePhase() error {
-	klog.Info("Calculating Environment Status")
+	ae.log().V(1).Info("calculating dataplane phase")
 
 	for node, status := range ae.dp.Status.NodegroupStatus {
 		if status != string(types.NodegroupStatusActive) {
-			klog.Infof("Node %s not active yet", node)
+			ae.log().V(1).Info("nodegroup not active yet", logging.KeyNodegroup, node)
 			return nil
 		}
 	}
 
-	for addon, status := range ae.dp.Status.AddonStatus {
-		if status != string(types.AddonStatusActive) {
-			klog.Infof("Addon %s not active yet", addon)
+	for addon, status := range ae.dp.Status.Addons {
+		if status.Status != string(types.AddonStatusActive) {
+			ae.log().V(1).Info("addon not active yet", logging.KeyAddon, addon)
 			return nil
 		}
 	}
@@ -1066,6 +1114,9 @@ func (ae *awsEnv) reconcilePhase() error {
 	if _, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
 		in := obj.(*v1.DataPlanes)
 		in.Status.Phase = v1.ActiveD
+		if in.Status.Upgrade != nil {
+			in.Status.Phase = v1.UpdatingD
+		}
 		return in
 	}); err != nil {
 		return err
@@ -1127,7 +1178,7 @@ func (ae *awsEnv) reconcileSystemNodeGroup() error {
 			}
 
 			if createSystemNodeGroupResult != nil && createSystemNodeGroupResult.Nodegroup != nil {
-				klog.Infof("Initated NodeGroup Launch [%s]", *createSystemNodeGroupResult.Nodegroup.ClusterName)
+				ae.log().Info("initiated nodegroup launch", logging.KeyNodegroup, *createSystemNodeGroupResult.Nodegroup.NodegroupName)
 				if err := ae.wrapNgPatchStatus(*createSystemNodeGroupResult.Nodegroup.NodegroupName, string(createSystemNodeGroupResult.Nodegroup.Status)); err != nil {
 					return err
 				}
@@ -1136,6 +1187,12 @@ func (ae *awsEnv) reconcileSystemNodeGroup() error {
 	}
 
 	if describeNodeGroupOutput != nil && describeNodeGroupOutput.Nodegroup != nil {
+		ng := describeNodeGroupOutput.Nodegroup
+		if ng.Status == types.NodegroupStatusActive &&
+			ae.dp.Status.NodegroupStatus[*ng.NodegroupName] != string(types.NodegroupStatusActive) &&
+			ng.CreatedAt != nil {
+			metrics.ObserveProvisioning(metrics.ResourceNodegroup, string(ae.dp.Spec.CloudInfra.CloudType), *ng.CreatedAt)
+		}
 		if err := ae.wrapNgPatchStatus(*describeNodeGroupOutput.Nodegroup.NodegroupName, string(describeNodeGroupOutput.Nodegroup.Status)); err != nil {
 			return err
 		}
@@ -1157,97 +1214,3 @@ func (ae *awsEnv) wrapNgPatchStatus(name, status string) error {
 	})
 	return err
 }
-
-func (ae *awsEnv) wrapAddonPatchStatus(addonName, status string) error {
-	// update status with current addon status
-	_, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
-		in := obj.(*v1.DataPlanes)
-		if in.Status.AddonStatus == nil {
-			in.Status.AddonStatus = make(map[string]string)
-		}
-		in.Status.AddonStatus[addonName] = status
-		return in
-	})
-	return err
-}
-
-func (ae *awsEnv) ReconcileDefaultAddons() error {
-	oidcProvider := ae.dp.Status.CloudInfraStatus.AwsCloudInfraConfigStatus.EksStatus.OIDCProviderArn
-	if oidcProvider == "" {
-		klog.Info("ebs-csi-driver creation: waiting for oidcProvider to be created")
-		return nil
-	}
-	clusterName := ae.dp.Spec.CloudInfra.Eks.Name
-	ebsAddon, err := ae.eksIC.DescribeAddon(awsEbsCsiDriver)
-	if err != nil {
-		var notFoundErr *types.ResourceNotFoundException
-		if errors.As(err, &notFoundErr) {
-			klog.Info("Creating aws-ebs-csi-driver addon")
-
-			role, err := ae.eksIC.CreateEbsCSIRole(ae.ctx)
-			if err != nil {
-				return err
-			}
-
-			_, cErr := ae.eksIC.CreateAddon(ae.ctx, &awseks.CreateAddonInput{
-				AddonName:             aws.String(awsEbsCsiDriver),
-				ClusterName:           aws.String(clusterName),
-				ResolveConflicts:      types.ResolveConflictsOverwrite,
-				ServiceAccountRoleArn: role.Role.Arn,
-			})
-			if cErr != nil {
-				return cErr
-			}
-			klog.Info("aws-ebs-csi-driver addon creation is initiated")
-		} else {
-			return err
-		}
-		return nil
-	}
-	if ebsAddon != nil && ebsAddon.Addon != nil {
-		addonRes := ebsAddon.Addon
-		klog.Info("aws-ebs-csi-driver addon status: ", addonRes.Status)
-		if err := ae.wrapAddonPatchStatus(*addonRes.AddonName, string(addonRes.Status)); err != nil {
-			return err
-		}
-	}
-
-	vpcCniAddon, err := ae.eksIC.DescribeAddon(vpcCni)
-	if err != nil {
-		var notFoundErr *types.ResourceNotFoundException
-		if errors.As(err, &notFoundErr) {
-			klog.Info("Creating vpc cni addon")
-			_, arn, err := ae.eksIC.CreateVpcCniRole(ae.ctx)
-			if err != nil {
-				return err
-			}
-
-			v := `{"enableNetworkPolicy": "true"}`
-
-			_, cErr := ae.eksIC.CreateAddon(ae.ctx, &awseks.CreateAddonInput{
-				AddonName:             aws.String(vpcCni),
-				ClusterName:           aws.String(clusterName),
-				ResolveConflicts:      types.ResolveConflictsOverwrite,
-				ServiceAccountRoleArn: aws.String(arn),
-				AddonVersion:          aws.String("v1.15.0-eksbuild.2"),
-				ConfigurationValues:   aws.String(v),
-			})
-			if cErr != nil {
-				return cErr
-			}
-			klog.Info("vpc cni addon creation is initiated")
-		} else {
-			return err
-		}
-		return nil
-	}
-	if vpcCniAddon != nil && vpcCniAddon.Addon != nil {
-		addonRes := vpcCniAddon.Addon
-		klog.Info("vpc cni addon status: ", addonRes.Status)
-		if err := ae.wrapAddonPatchStatus(*addonRes.AddonName, string(addonRes.Status)); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metrics"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// defaultAddons are installed when the dataplane does not list its addons.
+func defaultAddons() []v1.EksAddon {
+	return []v1.EksAddon{
+		{
+			Name:                vpcCni,
+			Version:             vpcCniVersion,
+			ConfigurationValues: `{"enableNetworkPolicy": "true"}`,
+			ServiceAccountRole: &v1.AddonServiceAccountRole{
+				ServiceAccount: "aws-node",
+				PolicyArns:     []string{eks.VPCCNIPolicyARN},
+			},
+		},
+		{
+			Name: awsEbsCsiDriver,
+			ServiceAccountRole: &v1.AddonServiceAccountRole{
+				ServiceAccount: "ebs-csi-controller-sa",
+				PolicyArns:     []string{eks.EBSCSIPolicyARN},
+			},
+		},
+	}
+}
+
+func (ae *awsEnv) desiredAddons() []v1.EksAddon {
+	if len(ae.dp.Spec.CloudInfra.Eks.Addons) == 0 {
+		return defaultAddons()
+	}
+	return ae.dp.Spec.CloudInfra.Eks.Addons
+}
+
+// reconcileAddons installs, updates and removes the eks addons to match the
+// dataplane spec. k8sVersion is the current version of the cluster.
+func (ae *awsEnv) reconcileAddons(k8sVersion string) error {
+	oidcProvider := ae.dp.Status.CloudInfraStatus.AwsCloudInfraConfigStatus.EksStatus.OIDCProviderArn
+
+	desired := map[string]bool{}
+	for _, addon := range ae.desiredAddons() {
+		desired[addon.Name] = true
+		if addon.ServiceAccountRole != nil && oidcProvider == "" {
+			ae.log().V(1).Info("waiting for oidc provider to be created", logging.KeyAddon, addon.Name)
+			continue
+		}
+		if err := ae.reconcileAddon(addon, k8sVersion); err != nil {
+			return err
+		}
+	}
+
+	var removed []string
+	recorded := make(map[string]bool, len(ae.dp.Status.Addons)+len(ae.dp.Status.AddonStatus))
+	for name := range ae.dp.Status.Addons {
+		recorded[name] = true
+	}
+	for name := range ae.dp.Status.AddonStatus {
+		recorded[name] = true
+	}
+	for name := range recorded {
+		if desired[name] {
+			continue
+		}
+		if slices.Contains(coreAddons, name) {
+			ae.log().V(1).Info("keeping core addon removed from the spec", logging.KeyAddon, name)
+			continue
+		}
+		removed = append(removed, name)
+	}
+	for _, name := range removed {
+		if err := ae.removeAddon(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ae *awsEnv) reconcileAddon(addon v1.EksAddon, k8sVersion string) error {
+	log := ae.log().WithValues(logging.KeyAddon, addon.Name)
+
+	describeOutput, err := ae.eksIC.DescribeAddon(addon.Name)
+	if err != nil {
+		var notFoundErr *types.ResourceNotFoundException
+		if !errors.As(err, &notFoundErr) {
+			return err
+		}
+
+		roleArn, err := ae.addonRoleArn(addon)
+		if err != nil {
+			return err
+		}
+		version, err := ae.eksIC.ResolveAddonVersion(ae.ctx, addon.Name, addon.Version, k8sVersion)
+		if err != nil {
+			return err
+		}
+
+		log.Info("creating addon", "version", version)
+		input := &awseks.CreateAddonInput{
+			AddonName:        aws.String(addon.Name),
+			ClusterName:      aws.String(ae.dp.Spec.CloudInfra.Eks.Name),
+			ResolveConflicts: addonResolveConflicts(addon),
+		}
+		if version != "" {
+			input.AddonVersion = aws.String(version)
+		}
+		if addon.ConfigurationValues != "" {
+			input.ConfigurationValues = aws.String(addon.ConfigurationValues)
+		}
+		if roleArn != "" {
+			input.ServiceAccountRoleArn = aws.String(roleArn)
+		}
+		if _, err := ae.eksIC.CreateAddon(ae.ctx, input); err != nil {
+			return err
+		}
+		log.Info("initiated addon creation")
+		return ae.wrapAddonPatchStatus(addon.Name, v1.EksAddonStatus{
+			Status:  string(types.AddonStatusCreating),
+			Version: version,
+		})
+	}
+	if describeOutput == nil || describeOutput.Addon == nil {
+		return nil
+	}
+
+	current := describeOutput.Addon
+	log.V(1).Info("addon status", "status", current.Status, "version", aws.ToString(current.AddonVersion))
+	ae.observeAddonProvisioning(current)
+	if err := ae.wrapAddonPatchStatus(addon.Name, makeAddonStatus(current)); err != nil {
+		return err
+	}
+
+	// eks rejects updates while the addon is changing
+	switch current.Status {
+	case types.AddonStatusActive, types.AddonStatusDegraded, types.AddonStatusUpdateFailed:
+	default:
+		return nil
+	}
+
+	roleArn, err := ae.addonRoleArn(addon)
+	if err != nil {
+		return err
+	}
+	version := aws.ToString(current.AddonVersion)
+	switch {
+	case addon.Version != "":
+		version, err = ae.eksIC.ResolveAddonVersion(ae.ctx, addon.Name, addon.Version, k8sVersion)
+		if err != nil {
+			return err
+		}
+	case ae.dp.Status.Upgrade != nil:
+		// addons without version only move when the cluster outgrows them
+		compatible, defaultVersion, err := ae.eksIC.CompatibleAddonVersions(ae.ctx, addon.Name, k8sVersion)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(compatible, version) && defaultVersion != "" {
+			version = defaultVersion
+		}
+	}
+
+	if version == aws.ToString(current.AddonVersion) &&
+		addon.ConfigurationValues == aws.ToString(current.ConfigurationValues) &&
+		(roleArn == "" || roleArn == aws.ToString(current.ServiceAccountRoleArn)) {
+		return nil
+	}
+
+	log.Info("updating addon", "from", aws.ToString(current.AddonVersion), "to", version)
+	input := &awseks.UpdateAddonInput{
+		AddonName:           aws.String(addon.Name),
+		ClusterName:         aws.String(ae.dp.Spec.CloudInfra.Eks.Name),
+		AddonVersion:        aws.String(version),
+		ConfigurationValues: aws.String(addon.ConfigurationValues),
+		ResolveConflicts:    addonResolveConflicts(addon),
+	}
+	if roleArn != "" {
+		input.ServiceAccountRoleArn = aws.String(roleArn)
+	}
+	if _, err := ae.eksIC.UpdateAddon(ae.ctx, input); err != nil {
+		return err
+	}
+	log.Info("initiated addon update")
+
+	status := makeAddonStatus(current)
+	status.Status = string(types.AddonStatusUpdating)
+	return ae.wrapAddonPatchStatus(addon.Name, status)
+}
+
+// removeAddon deletes an addon no longer listed in the spec and drops it from
+// the status once eks has deleted it. The addon iam role is kept.
+func (ae *awsEnv) removeAddon(name string) error {
+	log := ae.log().WithValues(logging.KeyAddon, name)
+
+	describeOutput, err := ae.eksIC.DescribeAddon(name)
+	if err != nil {
+		var notFoundErr *types.ResourceNotFoundException
+		if !errors.As(err, &notFoundErr) {
+			return err
+		}
+		log.Info("addon deleted")
+		_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
+			in := obj.(*v1.DataPlanes)
+			delete(in.Status.Addons, name)
+			delete(in.Status.AddonStatus, name)
+			return in
+		})
+		return err
+	}
+	if describeOutput == nil || describeOutput.Addon == nil {
+		return nil
+	}
+
+	status := makeAddonStatus(describeOutput.Addon)
+	if describeOutput.Addon.Status != types.AddonStatusDeleting {
+		log.Info("deleting addon")
+		if _, err := ae.eksIC.DeleteAddon(ae.ctx, &awseks.DeleteAddonInput{
+			AddonName:   aws.String(name),
+			ClusterName: aws.String(ae.dp.Spec.CloudInfra.Eks.Name),
+		}); err != nil {
+			return err
+		}
+		status.Status = string(types.AddonStatusDeleting)
+	}
+	return ae.wrapAddonPatchStatus(name, status)
+}
+
+// addonRoleArn returns the arn of the addon service account role, creating
+// the role and syncing its policies. It is empty when the addon has no role.
+func (ae *awsEnv) addonRoleArn(addon v1.EksAddon) (string, error) {
+	if addon.ServiceAccountRole == nil {
+		return "", nil
+	}
+	return ae.eksIC.EnsureAddonRole(ae.ctx, addon.Name, addon.ServiceAccountRole)
+}
+
+func addonResolveConflicts(addon v1.EksAddon) types.ResolveConflicts {
+	if addon.ResolveConflicts == "" {
+		return types.ResolveConflictsOverwrite
+	}
+	return types.ResolveConflicts(addon.ResolveConflicts)
+}
+
+func makeAddonStatus(addon *types.Addon) v1.EksAddonStatus {
+	status := v1.EksAddonStatus{
+		Status:  string(addon.Status),
+		Version: aws.ToString(addon.AddonVersion),
+	}
+	if addon.Health != nil {
+		for _, issue := range addon.Health.Issues {
+			status.Health = append(status.Health, string(issue.Code)+": "+aws.ToString(issue.Message))
+		}
+	}
+	return status
+}
+
+func (ae *awsEnv) wrapAddonPatchStatus(addonName string, status v1.EksAddonStatus) error {
+	// update status with current addon status
+	_, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
+		in := obj.(*v1.DataPlanes)
+		if in.Status.Addons == nil {
+			in.Status.Addons = make(map[string]v1.EksAddonStatus)
+		}
+		in.Status.Addons[addonName] = status
+		// the addon is no longer reported in the status of earlier versions
+		delete(in.Status.AddonStatus, addonName)
+		return in
+	})
+	return err
+}
+
+// observeAddonProvisioning records the provisioning duration of an addon
+// the first time it is seen active.
+func (ae *awsEnv) observeAddonProvisioning(addon *types.Addon) {
+	if addon.Status == types.AddonStatusActive &&
+		ae.dp.Status.Addons[*addon.AddonName].Status != string(types.AddonStatusActive) &&
+		addon.CreatedAt != nil {
+		metrics.ObserveProvisioning(metrics.ResourceAddon, string(ae.dp.Spec.CloudInfra.CloudType), *addon.CreatedAt)
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/admission"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// admissionFieldOwner owns the fields of the objects of the webhook applied
+// to the dataplane.
+const admissionFieldOwner = "baaz"
+
+// reconcileAdmissionWebhook installs the webhook placing the pods of tenants
+// on their machine pools on the system nodegroup, running the image of baaz
+// set by BAAZ_IMAGE. It is reinstalled when the image changes.
+func (ae *awsEnv) reconcileAdmissionWebhook() error {
+	ae.log().V(1).Info("reconciling admission webhook")
+
+	if ae.dp.Status.NodegroupStatus[ae.dp.Spec.CloudInfra.Eks.Name+"-system"] != string(types.NodegroupStatusActive) {
+		return nil
+	}
+	image := os.Getenv("BAAZ_IMAGE")
+	if image == "" {
+		ae.log().V(1).Info("no BAAZ_IMAGE set, skipping admission webhook")
+		return nil
+	}
+
+	status := v1.AdmissionWebhookStatus{}
+	if ae.dp.Status.AdmissionWebhook != nil {
+		status = *ae.dp.Status.AdmissionWebhook
+	}
+	if status.Phase == v1.DeployedA && status.Image == image {
+		return nil
+	}
+
+	restConfig, err := ae.eksIC.GetRestConfig()
+	if err != nil {
+		return err
+	}
+	c, err := client.New(restConfig, client.Options{})
+	if err != nil {
+		return err
+	}
+
+	// the certificates outlive the reinstalls, the api server keeps
+	// trusting the webhook while its pod rolls
+	secret := &corev1.Secret{}
+	err = c.Get(ae.ctx, client.ObjectKey{Namespace: admission.Namespace, Name: admission.SecretName}, secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	certs := admission.CertificatesFrom(secret)
+	if certs == nil {
+		if certs, err = admission.NewCertificates(); err != nil {
+			return err
+		}
+	}
+
+	ae.log().Info("installing admission webhook", "image", image)
+	status.Phase = v1.DeployedA
+	status.Image = image
+	for _, obj := range admission.Manifests(image, certs) {
+		err := c.Patch(ae.ctx, obj, client.Apply, client.FieldOwner(admissionFieldOwner), client.ForceOwnership)
+		if err != nil {
+			logging.Error(ae.log(), err, "applying admission webhook failed",
+				"kind", obj.GetObjectKind().GroupVersionKind().Kind, "name", obj.GetName())
+			status.Phase = v1.FailedA
+			break
+		}
+	}
+	return ae.patchAdmissionWebhookStatus(status)
+}
+
+func (ae *awsEnv) patchAdmissionWebhookStatus(status v1.AdmissionWebhookStatus) error {
+	_, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
+		in := obj.(*v1.DataPlanes)
+		in.Status.AdmissionWebhook = &status
+		return in
+	})
+	return err
+}
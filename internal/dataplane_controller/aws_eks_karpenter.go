@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/helm"
+	"github.com/baazhq/baaz/pkg/karpenter"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// karpenterTeardownTimeout bounds the wait for karpenter to terminate its
+// nodes when the dataplane is deleted.
+const karpenterTeardownTimeout = 15 * time.Minute
+
+func (ae *awsEnv) karpenterVersion() string {
+	if version := ae.dp.Spec.CloudInfra.Eks.Karpenter.Version; version != "" {
+		return version
+	}
+	return karpenter.DefaultVersion
+}
+
+// reconcileKarpenter creates the iam roles, instance profile and
+// interruption queue of karpenter, then installs or upgrades its chart on
+// the system nodegroup.
+func (ae *awsEnv) reconcileKarpenter() error {
+	ae.log().V(1).Info("reconciling karpenter")
+
+	if ae.dp.Status.NodegroupStatus[ae.dp.Spec.CloudInfra.Eks.Name+"-system"] != string(types.NodegroupStatusActive) {
+		return nil
+	}
+	if ae.dp.Status.CloudInfraStatus.EksStatus.OIDCProviderArn == "" {
+		ae.log().V(1).Info("waiting for oidc provider to be created")
+		return nil
+	}
+
+	status := v1.KarpenterStatus{}
+	if ae.dp.Status.Karpenter != nil {
+		status = *ae.dp.Status.Karpenter
+	}
+
+	if status.ControllerRoleArn == "" {
+		nodeRole, err := ae.eksIC.EnsureKarpenterNodeRole(ae.ctx)
+		if err != nil {
+			return err
+		}
+		if err := ae.eksIC.MapNodeRole(ae.ctx, nodeRole.RoleArn); err != nil {
+			return err
+		}
+		queueName, queueArn, err := ae.eksIC.EnsureInterruptionQueue(ae.ctx)
+		if err != nil {
+			return err
+		}
+		roleArn, err := ae.eksIC.EnsureKarpenterControllerRole(ae.ctx, nodeRole.RoleArn, queueArn)
+		if err != nil {
+			return err
+		}
+
+		ae.log().Info("created karpenter iam roles and interruption queue", "role", roleArn, "queue", queueName)
+		status.ControllerRoleArn = roleArn
+		status.NodeRole = nodeRole.RoleName
+		status.InstanceProfile = nodeRole.InstanceProfile
+		status.InterruptionQueue = queueName
+		if err := ae.patchKarpenterStatus(status); err != nil {
+			return err
+		}
+	}
+
+	version := ae.karpenterVersion()
+	if status.Phase == v1.DeployedA && status.Version == version {
+		return nil
+	}
+
+	restConfig, err := ae.eksIC.GetRestConfig()
+	if err != nil {
+		return err
+	}
+
+	chart := helm.NewHelm(
+		ae.ctx,
+		ae.repoCache,
+		"karpenter",
+		eks.KarpenterNamespace,
+		karpenter.ChartName,
+		"karpenter",
+		karpenter.ChartRepoURL,
+		version,
+		restConfig,
+		[]string{
+			"settings.clusterName=" + ae.dp.Spec.CloudInfra.Eks.Name,
+			"settings.interruptionQueue=" + status.InterruptionQueue,
+			"serviceAccount.name=" + eks.KarpenterServiceAccount,
+			`serviceAccount.annotations.eks\.amazonaws\.com/role-arn=` + status.ControllerRoleArn,
+			"nodeSelector.nodeType=system",
+			// the replicas of karpenter are spread over nodes and the
+			// system nodegroup may run a single node
+			"replicas=1",
+		},
+	)
+
+	// unlike List, the history holds the failed releases too, which are
+	// upgraded in place since a new install would reuse their name
+	releases, err := chart.History(restConfig)
+	if err != nil {
+		return err
+	}
+	var latest *release.Release
+	if len(releases) > 0 {
+		latest = releases[0]
+		if latest.Info.Status.IsPending() {
+			ae.log().Info("waiting for karpenter release", "status", latest.Info.Status)
+			return nil
+		}
+		if latest.Info.Status == release.StatusDeployed && latest.Chart.Metadata.Version == version {
+			status.Phase = v1.DeployedA
+			status.Version = version
+			return ae.patchKarpenterStatus(status)
+		}
+	}
+
+	status.Phase = v1.InstallingA
+	if err := ae.patchKarpenterStatus(status); err != nil {
+		return err
+	}
+
+	switch revision := deployedRevision(releases, version); {
+	case latest == nil:
+		ae.log().Info("installing karpenter", "version", version)
+		err = chart.Apply(restConfig)
+	case revision > 0:
+		ae.log().Info("rolling karpenter back", "version", version, "revision", revision)
+		err = chart.Rollback(restConfig, revision)
+	default:
+		ae.log().Info("upgrading karpenter", "from", latest.Chart.Metadata.Version, "to", version)
+		err = chart.Upgrade(restConfig)
+	}
+	if err != nil {
+		logging.Error(ae.log(), err, "installing chart failed", logging.KeyChart, karpenter.ChartName)
+		status.Phase = v1.FailedA
+	} else {
+		status.Phase = v1.DeployedA
+		status.Version = version
+	}
+	return ae.patchKarpenterStatus(status)
+}
+
+// deployedRevision returns the revision of the latest release of the chart
+// at version that was deployed before a newer one, or 0 when there is none
+// or the newest release is that version.
+func deployedRevision(releases []*release.Release, version string) int {
+	if len(releases) == 0 || releases[0].Chart.Metadata.Version == version {
+		return 0
+	}
+	for _, r := range releases[1:] {
+		if r.Chart.Metadata.Version == version && r.Info.Status == release.StatusSuperseded {
+			return r.Version
+		}
+	}
+	return 0
+}
+
+// teardownKarpenter deletes the NodePools of the cluster so karpenter
+// terminates its nodes, then uninstalls karpenter and deletes its
+// interruption queue. It tells when the teardown is over. The nodes left
+// after karpenterTeardownTimeout are not waited for.
+func (ae *awsEnv) teardownKarpenter() bool {
+	if ae.dp.Status.Karpenter == nil {
+		return true
+	}
+	log := ae.log().WithValues(logging.KeyChart, karpenter.ChartName)
+
+	restConfig, err := ae.eksIC.GetRestConfig()
+	if err != nil {
+		logging.Error(log, err, "skipping karpenter teardown")
+		return true
+	}
+
+	if time.Since(ae.dp.DeletionTimestamp.Time) < karpenterTeardownTimeout {
+		remaining, err := ae.deleteNodePools(restConfig)
+		if err != nil {
+			logging.Error(log, err, "failed to delete karpenter nodepools")
+		} else if remaining > 0 {
+			log.Info("waiting for karpenter to terminate its nodes", "nodeClaims", remaining)
+			return false
+		}
+	} else {
+		log.Info("karpenter teardown timed out, remaining nodes are not waited for")
+	}
+
+	chart := helm.NewHelm(ae.ctx, ae.repoCache, "karpenter", eks.KarpenterNamespace, karpenter.ChartName,
+		"karpenter", karpenter.ChartRepoURL, ae.karpenterVersion(), restConfig, nil)
+	// the failed releases are uninstalled too, List only returns the
+	// deployed ones
+	releases, err := chart.History(restConfig)
+	if err != nil {
+		logging.Error(log, err, "failed to read karpenter release")
+	}
+	if len(releases) > 0 {
+		log.Info("uninstalling karpenter")
+		if err := chart.Uninstall(restConfig); err != nil {
+			logging.Error(log, err, "failed to uninstall karpenter")
+		}
+	}
+
+	if err := ae.eksIC.DeleteInterruptionQueue(ae.ctx); err != nil {
+		logging.Error(log, err, "failed to delete karpenter interruption queue")
+		return false
+	}
+
+	_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
+		in := obj.(*v1.DataPlanes)
+		in.Status.Karpenter = nil
+		return in
+	})
+	if err != nil {
+		logging.Error(log, err, "failed to update karpenter status")
+		return false
+	}
+	return true
+}
+
+// deleteNodePools deletes every NodePool of the cluster and returns the
+// number of NodeClaims karpenter still has to terminate.
+func (ae *awsEnv) deleteNodePools(restConfig *rest.Config) (int, error) {
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	nodePools, err := dynamicClient.Resource(karpenter.NodePoolGVR).List(ae.ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	for _, nodePool := range nodePools.Items {
+		if nodePool.GetDeletionTimestamp() != nil {
+			continue
+		}
+		ae.log().Info("deleting karpenter nodepool", "nodePool", nodePool.GetName())
+		if err := dynamicClient.Resource(karpenter.NodePoolGVR).Delete(ae.ctx, nodePool.GetName(), metav1.DeleteOptions{}); err != nil {
+			return 0, err
+		}
+	}
+
+	nodeClaims, err := dynamicClient.Resource(karpenter.NodeClaimGVR).List(ae.ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return len(nodeClaims.Items), nil
+}
+
+func (ae *awsEnv) patchKarpenterStatus(status v1.KarpenterStatus) error {
+	_, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
+		in := obj.(*v1.DataPlanes)
+		in.Status.Karpenter = &status
+		return in
+	})
+	return err
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func karpenterRelease(revision int, version string, status release.Status) *release.Release {
+	return &release.Release{
+		Version: revision,
+		Chart:   &chart.Chart{Metadata: &chart.Metadata{Version: version}},
+		Info:    &release.Info{Status: status},
+	}
+}
+
+func TestDeployedRevision(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		releases []*release.Release
+		expected int
+	}{
+		{"not installed", "1.0.6", nil, 0},
+		{"failed install", "1.0.6", []*release.Release{
+			karpenterRelease(1, "1.0.6", release.StatusFailed),
+		}, 0},
+		{"failed upgrade retried", "1.1.0", []*release.Release{
+			karpenterRelease(2, "1.1.0", release.StatusFailed),
+			karpenterRelease(1, "1.0.6", release.StatusSuperseded),
+		}, 0},
+		{"failed upgrade reverted", "1.0.6", []*release.Release{
+			karpenterRelease(3, "1.1.0", release.StatusFailed),
+			karpenterRelease(2, "1.0.6", release.StatusSuperseded),
+			karpenterRelease(1, "1.0.6", release.StatusSuperseded),
+		}, 2},
+		{"never deployed", "1.0.6", []*release.Release{
+			karpenterRelease(2, "1.1.0", release.StatusFailed),
+			karpenterRelease(1, "1.0.6", release.StatusFailed),
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deployedRevision(tt.releases, tt.version); got != tt.expected {
+				t.Fatalf("got %d, expected %d", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,504 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go/aws"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/drain"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/upgrade"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+const (
+	nodegroupUpgraded = "Upgraded"
+	nodegroupPending  = "Pending"
+)
+
+// stepConditions are reset at the start of every minor version step.
+var stepConditions = []v1.DataPlaneConditionType{
+	v1.UpgradePreflightPassed,
+	v1.ControlPlaneUpgraded,
+	v1.AddonsUpgraded,
+	v1.SystemNodegroupUpgraded,
+	v1.TenantNodegroupsUpgraded,
+}
+
+func makeCondition(conType v1.DataPlaneConditionType, status corev1.ConditionStatus, reason eks.DataPlaneControllerReason, msg string) v1.DataPlaneCondition {
+	return v1.DataPlaneCondition{
+		Type:               conType,
+		Status:             status,
+		LastUpdateTime:     metav1.Time{Time: time.Now()},
+		LastTransitionTime: metav1.Time{Time: time.Now()},
+		Reason:             string(reason),
+		Message:            msg,
+	}
+}
+
+// versionReached tells if version is at or past target.
+func versionReached(version, target string) bool {
+	v, err := upgrade.ParseVersion(version)
+	if err != nil {
+		return false
+	}
+	t, err := upgrade.ParseVersion(target)
+	if err != nil {
+		return false
+	}
+	return v.Compare(t) >= 0
+}
+
+// reconcileUpgrade moves the cluster to the version of the spec one minor
+// version at a time. Each step runs the pre-flight checks, upgrades the
+// control plane, waits for the addons, then upgrades the system nodegroup
+// and the tenant nodegroups.
+func (ae *awsEnv) reconcileUpgrade(cluster *types.Cluster) error {
+	current := aws.StringValue(cluster.Version)
+	target := ae.dp.Spec.CloudInfra.Eks.Version
+
+	if ae.dp.Status.Upgrade == nil {
+		if target == "" || target == current {
+			return nil
+		}
+		ae.log().Info("starting eks upgrade", "from", current, "to", target)
+		state := &v1.UpgradeStatus{
+			FromVersion:   current,
+			TargetVersion: target,
+			StartedAt:     metav1.Time{Time: time.Now()},
+		}
+		return ae.startUpgradeStep(state, current)
+	}
+
+	state := ae.dp.Status.Upgrade.DeepCopy()
+	if state.TargetVersion != target {
+		// the running step completes before moving to the new target
+		ae.log().Info("eks upgrade target changed", "from", state.TargetVersion, "to", target)
+		state.TargetVersion = target
+		if err := ae.patchUpgrade(state); err != nil {
+			return err
+		}
+	}
+
+	if ae.dp.GetAnnotations()[v1.UpgradePausedAnnotation] == "true" {
+		if ae.dp.HasCondition(v1.UpgradePaused) {
+			return nil
+		}
+		ae.log().Info("eks upgrade paused", "step", state.Step, "version", state.StepVersion)
+		return ae.patchUpgrade(state, makeCondition(v1.UpgradePaused, corev1.ConditionTrue, eks.EksUpgradePausedReason,
+			fmt.Sprintf("upgrade to %s paused at step %s", state.StepVersion, state.Step)))
+	}
+	if ae.dp.HasCondition(v1.UpgradePaused) {
+		ae.log().Info("eks upgrade resumed", "step", state.Step, "version", state.StepVersion)
+		if err := ae.patchUpgrade(state, makeCondition(v1.UpgradePaused, corev1.ConditionFalse, eks.EksUpgradeResumedReason,
+			fmt.Sprintf("upgrade to %s resumed at step %s", state.StepVersion, state.Step))); err != nil {
+			return err
+		}
+	}
+
+	switch state.Step {
+	case v1.UpgradeStepPreflight:
+		return ae.upgradePreflight(state, current)
+	case v1.UpgradeStepControlPlane:
+		return ae.upgradeControlPlane(state, current)
+	case v1.UpgradeStepAddons:
+		return ae.upgradeAddons(state)
+	case v1.UpgradeStepSystemNodegroup:
+		return ae.upgradeSystemNodegroup(state)
+	case v1.UpgradeStepTenantNodegroups:
+		return ae.upgradeTenantNodegroups(state, current)
+	}
+	return nil
+}
+
+// startUpgradeStep starts the pre-flight checks of the next minor version
+// after current, or completes the upgrade once the target is reached.
+func (ae *awsEnv) startUpgradeStep(state *v1.UpgradeStatus, current string) error {
+	if versionReached(current, state.TargetVersion) {
+		ae.log().Info("eks upgrade completed", "from", state.FromVersion, "to", current)
+		return ae.patchUpgrade(nil,
+			makeCondition(v1.VersionUpgradeSuccessful, corev1.ConditionTrue, eks.EksUpgradeCompletedReason,
+				fmt.Sprintf("upgraded from %s to %s", state.FromVersion, current)))
+	}
+
+	state.Step = v1.UpgradeStepPreflight
+	state.Nodegroups = nil
+	state.PreflightFailures = nil
+	step, err := upgrade.NextStep(current, state.TargetVersion)
+	if err != nil {
+		state.StepVersion = ""
+		state.PreflightFailures = []string{err.Error()}
+	} else {
+		state.StepVersion = step
+	}
+
+	msg := fmt.Sprintf("upgrading from %s to %s", current, state.StepVersion)
+	conditions := []v1.DataPlaneCondition{
+		makeCondition(v1.VersionUpgradeInitiated, corev1.ConditionTrue, eks.EksUpgradeInProgressReason,
+			fmt.Sprintf("upgrading from %s to %s", state.FromVersion, state.TargetVersion)),
+		makeCondition(v1.VersionUpgradeSuccessful, corev1.ConditionFalse, eks.EksUpgradeInProgressReason, msg),
+	}
+	for _, conType := range stepConditions {
+		conditions = append(conditions, makeCondition(conType, corev1.ConditionFalse, eks.EksUpgradeInProgressReason, msg))
+	}
+	return ae.patchUpgrade(state, conditions...)
+}
+
+func (ae *awsEnv) upgradePreflight(state *v1.UpgradeStatus, current string) error {
+	if state.StepVersion == "" {
+		// the target is not reachable, wait for the spec to change
+		return ae.startUpgradeStep(state, current)
+	}
+	if versionReached(current, state.StepVersion) {
+		// the control plane was upgraded outside of baaz
+		state.Step = v1.UpgradeStepControlPlane
+		return ae.upgradeControlPlane(state, current)
+	}
+
+	failures, err := ae.preflightChecks(current, state.StepVersion)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 && ae.dp.GetAnnotations()[v1.UpgradeSkipPreflightAnnotation] != "true" {
+		ae.log().Info("eks upgrade blocked by pre-flight checks", "version", state.StepVersion, "failures", failures)
+		state.PreflightFailures = failures
+		return ae.patchUpgrade(state, makeCondition(v1.UpgradePreflightPassed, corev1.ConditionFalse,
+			eks.EksUpgradePreflightFailedReason, strings.Join(failures, "; ")))
+	}
+
+	result := ae.eksIC.UpdateEks(state.StepVersion)
+	if !result.Success {
+		return fmt.Errorf("failed to upgrade eks control plane to %s: %s", state.StepVersion, result.Result)
+	}
+	ae.log().Info("initiated eks control plane upgrade", "from", current, "to", state.StepVersion)
+
+	state.Step = v1.UpgradeStepControlPlane
+	state.PreflightFailures = failures
+	return ae.patchUpgrade(state, makeCondition(v1.UpgradePreflightPassed, corev1.ConditionTrue,
+		eks.EksUpgradePreflightPassedReason, fmt.Sprintf("ready to upgrade to %s", state.StepVersion)))
+}
+
+// preflightChecks returns the reasons the control plane cannot move from
+// current to step: nodegroups too old for the new control plane, removed
+// apis still in use and pinned addon versions not supporting step.
+func (ae *awsEnv) preflightChecks(current, step string) ([]string, error) {
+	nodegroups := []string{ae.dp.Spec.CloudInfra.Eks.Name + "-system"}
+	tenantNodegroups, _, err := ae.tenantNodegroups()
+	if err != nil {
+		return nil, err
+	}
+	nodegroups = append(nodegroups, tenantNodegroups...)
+
+	versions := make(map[string]string, len(nodegroups))
+	for _, name := range nodegroups {
+		out, found, err := ae.eksIC.DescribeNodegroup(name)
+		if err != nil {
+			return nil, err
+		}
+		if found && out.Nodegroup != nil {
+			versions[name] = aws.StringValue(out.Nodegroup.Version)
+		}
+	}
+	failures := upgrade.CheckSkew(step, versions)
+
+	clientset, err := ae.eksIC.GetEksClientSet()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := clientset.Discovery().RESTClient().Get().AbsPath("/metrics").DoRaw(ae.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apiserver metrics: %w", err)
+	}
+	removed, err := upgrade.RemovedAPIs(bytes.NewReader(raw), step)
+	if err != nil {
+		return nil, err
+	}
+	for _, api := range removed {
+		failures = append(failures, api.String())
+	}
+
+	for _, addon := range ae.desiredAddons() {
+		compatible, _, err := ae.eksIC.CompatibleAddonVersions(ae.ctx, addon.Name, step)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(compatible) == 0:
+			failures = append(failures, fmt.Sprintf("addon %s has no version for kubernetes %s", addon.Name, step))
+		case addon.Version != "" && addon.Version != v1.AddonVersionLatestCompatible && !slices.Contains(compatible, addon.Version):
+			failures = append(failures, fmt.Sprintf("addon %s version %s does not support kubernetes %s", addon.Name, addon.Version, step))
+		}
+	}
+	return failures, nil
+}
+
+func (ae *awsEnv) upgradeControlPlane(state *v1.UpgradeStatus, current string) error {
+	if !versionReached(current, state.StepVersion) {
+		// the cluster is active again on the old version, the update failed
+		ae.log().Info("retrying eks control plane upgrade", "from", current, "to", state.StepVersion)
+		result := ae.eksIC.UpdateEks(state.StepVersion)
+		if !result.Success {
+			return fmt.Errorf("failed to upgrade eks control plane to %s: %s", state.StepVersion, result.Result)
+		}
+		return nil
+	}
+
+	ae.log().Info("eks control plane upgraded", "version", current)
+	state.Step = v1.UpgradeStepAddons
+	return ae.patchUpgrade(state, makeCondition(v1.ControlPlaneUpgraded, corev1.ConditionTrue,
+		eks.EksUpgradeStepCompletedReason, fmt.Sprintf("control plane upgraded to %s", current)))
+}
+
+// upgradeAddons waits for reconcileAddons to move every addon to a version
+// supporting the new control plane.
+func (ae *awsEnv) upgradeAddons(state *v1.UpgradeStatus) error {
+	for _, addon := range ae.desiredAddons() {
+		status := ae.dp.Status.Addons[addon.Name]
+		if status.Status != string(types.AddonStatusActive) {
+			ae.log().V(1).Info("waiting for addon", logging.KeyAddon, addon.Name, "status", status.Status)
+			return nil
+		}
+		compatible, _, err := ae.eksIC.CompatibleAddonVersions(ae.ctx, addon.Name, state.StepVersion)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(compatible, status.Version) {
+			ae.log().V(1).Info("waiting for addon upgrade", logging.KeyAddon, addon.Name, "version", status.Version)
+			return nil
+		}
+	}
+
+	ae.log().Info("eks addons upgraded", "version", state.StepVersion)
+	state.Step = v1.UpgradeStepSystemNodegroup
+	return ae.patchUpgrade(state, makeCondition(v1.AddonsUpgraded, corev1.ConditionTrue,
+		eks.EksUpgradeStepCompletedReason, fmt.Sprintf("addons support %s", state.StepVersion)))
+}
+
+func (ae *awsEnv) upgradeSystemNodegroup(state *v1.UpgradeStatus) error {
+	name := ae.dp.Spec.CloudInfra.Eks.Name + "-system"
+	ng, done, err := ae.nodegroupUpgraded(name, state.StepVersion)
+	if err != nil {
+		return err
+	}
+	if !done {
+		switch ng.Status {
+		case types.NodegroupStatusActive:
+			return ae.startNodegroupUpgrade(ng, state.StepVersion)
+		case types.NodegroupStatusUpdating:
+			_, err := ae.drainOutdatedNodes(name, state.StepVersion)
+			return err
+		}
+		return nil
+	}
+
+	ae.log().Info("system nodegroup upgraded", logging.KeyNodegroup, name, "version", state.StepVersion)
+	state.Step = v1.UpgradeStepTenantNodegroups
+	return ae.patchUpgrade(state, makeCondition(v1.SystemNodegroupUpgraded, corev1.ConditionTrue,
+		eks.EksUpgradeStepCompletedReason, fmt.Sprintf("system nodegroup upgraded to %s", state.StepVersion)))
+}
+
+// upgradeTenantNodegroups upgrades the tenant nodegroups in the order of the
+// upgrade policy, at most MaxParallelNodegroups at once.
+func (ae *awsEnv) upgradeTenantNodegroups(state *v1.UpgradeStatus, current string) error {
+	policy := ae.dp.Spec.CloudInfra.Eks.Upgrade
+	parallel := int(policy.MaxParallelNodegroups)
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	nodegroups, sizeOf, err := ae.tenantNodegroups()
+	if err != nil {
+		return err
+	}
+
+	progress := make(map[string]string, len(nodegroups))
+	inFlight := 0
+	allDone := true
+	for _, name := range upgrade.OrderNodegroups(nodegroups, sizeOf, policy.SizeOrder) {
+		ng, done, err := ae.nodegroupUpgraded(name, state.StepVersion)
+		if err != nil {
+			return err
+		}
+		if done {
+			progress[name] = nodegroupUpgraded
+			continue
+		}
+		allDone = false
+		if ng.Status == types.NodegroupStatusUpdating {
+			if progress[name], err = ae.drainOutdatedNodes(name, state.StepVersion); err != nil {
+				return err
+			}
+			inFlight++
+			continue
+		}
+		if ng.Status != types.NodegroupStatusActive {
+			progress[name] = string(ng.Status)
+			inFlight++
+			continue
+		}
+		if inFlight >= parallel {
+			progress[name] = nodegroupPending
+			continue
+		}
+		if err := ae.startNodegroupUpgrade(ng, state.StepVersion); err != nil {
+			return err
+		}
+		progress[name] = string(types.NodegroupStatusUpdating)
+		inFlight++
+	}
+
+	state.Nodegroups = progress
+	if !allDone {
+		return ae.patchUpgrade(state)
+	}
+
+	ae.log().Info("tenant nodegroups upgraded", "version", state.StepVersion)
+	if err := ae.patchUpgrade(state, makeCondition(v1.TenantNodegroupsUpgraded, corev1.ConditionTrue,
+		eks.EksUpgradeStepCompletedReason, fmt.Sprintf("tenant nodegroups upgraded to %s", state.StepVersion))); err != nil {
+		return err
+	}
+	return ae.startUpgradeStep(state, current)
+}
+
+// nodegroupUpgraded describes a nodegroup and tells if it runs version.
+// Missing nodegroups have nothing to upgrade, nor have the nodegroups of
+// custom amis whose image follows the amiId of their machine pool.
+func (ae *awsEnv) nodegroupUpgraded(name, version string) (*types.Nodegroup, bool, error) {
+	out, found, err := ae.eksIC.DescribeNodegroup(name)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found || out.Nodegroup == nil {
+		return nil, true, nil
+	}
+	if out.Nodegroup.AmiType == types.AMITypesCustom {
+		return out.Nodegroup, true, nil
+	}
+	return out.Nodegroup, versionReached(aws.StringValue(out.Nodegroup.Version), version), nil
+}
+
+// startNodegroupUpgrade sets the max unavailable nodes of the upgrade policy
+// on the nodegroup, then starts its upgrade on a later reconcile.
+func (ae *awsEnv) startNodegroupUpgrade(ng *types.Nodegroup, version string) error {
+	maxUnavailable := ae.dp.Spec.CloudInfra.Eks.Upgrade.MaxUnavailable
+	if maxUnavailable < 1 {
+		maxUnavailable = 1
+	}
+
+	if ng.UpdateConfig == nil || aws.Int32Value(ng.UpdateConfig.MaxUnavailable) != maxUnavailable {
+		ae.log().Info("setting nodegroup max unavailable", logging.KeyNodegroup, aws.StringValue(ng.NodegroupName), "maxUnavailable", maxUnavailable)
+		_, err := ae.eksIC.UpdateNodegroup(&awseks.UpdateNodegroupConfigInput{
+			ClusterName:   ng.ClusterName,
+			NodegroupName: ng.NodegroupName,
+			UpdateConfig: &types.NodegroupUpdateConfig{
+				MaxUnavailable: aws.Int32(maxUnavailable),
+			},
+		})
+		return err
+	}
+
+	ae.log().Info("upgrading nodegroup", logging.KeyNodegroup, aws.StringValue(ng.NodegroupName), "from", aws.StringValue(ng.Version), "to", version)
+	_, err := ae.eksIC.UpdateNodegroupVersion(&awseks.UpdateNodegroupVersionInput{
+		ClusterName:   ng.ClusterName,
+		NodegroupName: ng.NodegroupName,
+		Version:       aws.String(version),
+	})
+	return err
+}
+
+// drainOutdatedNodes runs a drain pass on the nodes of an upgrading nodegroup
+// that eks cordoned for replacement and that still run an older kubelet, so
+// their pods are evicted honoring PodDisruptionBudgets before eks terminates
+// them. It returns the progress of the nodegroup.
+func (ae *awsEnv) drainOutdatedNodes(name, version string) (string, error) {
+	log := ae.log().WithValues(logging.KeyNodegroup, name)
+
+	clientset, err := ae.eksIC.GetEksClientSet()
+	if err != nil {
+		return "", err
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(ae.ctx, metav1.ListOptions{
+		LabelSelector: drain.NodegroupLabel + "=" + name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	drainer := drain.New(ae.ctx, clientset, drain.Options{})
+	pending, blocked := 0, 0
+	for _, node := range nodes.Items {
+		if !node.Spec.Unschedulable || versionReached(node.Status.NodeInfo.KubeletVersion, version) {
+			continue
+		}
+		result, err := drainer.DrainNode(ae.ctx, node.Name)
+		if err != nil {
+			return "", err
+		}
+		if !result.Drained() {
+			log.V(1).Info("node draining", logging.KeyNode, node.Name, "pending", result.Pending, "blocked", result.Blocked)
+		}
+		pending += result.Pending
+		blocked += len(result.Blocked)
+	}
+
+	progress := string(types.NodegroupStatusUpdating)
+	if pending > 0 {
+		progress = fmt.Sprintf("%s: %d pods draining, %d blocked", progress, pending, blocked)
+	}
+	return progress, nil
+}
+
+// tenantNodegroups lists the nodegroups of the tenants infra of the
+// dataplane and the tenant size owning each of them.
+func (ae *awsEnv) tenantNodegroups() ([]string, map[string]string, error) {
+	tenantsInfraList := &v1.TenantsInfraList{}
+	if err := ae.client.List(ae.ctx, tenantsInfraList, client.InNamespace(ae.dp.Namespace)); err != nil {
+		return nil, nil, err
+	}
+
+	var nodegroups []string
+	sizeOf := map[string]string{}
+	for _, tenantsInfra := range tenantsInfraList.Items {
+		if tenantsInfra.Spec.Dataplane != ae.dp.Name {
+			continue
+		}
+		for size, sizes := range tenantsInfra.Spec.TenantSizes {
+			for _, machineSpec := range sizes.MachineSpec {
+				nodegroup, dedicated := eks.MakeTenantNodegroupNames(size, machineSpec)
+				sizeOf[nodegroup] = size
+				sizeOf[dedicated] = size
+			}
+		}
+		for nodegroup := range tenantsInfra.Status.NodegroupStatus {
+			nodegroups = append(nodegroups, nodegroup)
+		}
+	}
+	return nodegroups, sizeOf, nil
+}
+
+// patchUpgrade stores state, nil once the upgrade is over, with conditions.
+func (ae *awsEnv) patchUpgrade(state *v1.UpgradeStatus, conditions ...v1.DataPlaneCondition) error {
+	_, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
+		in := obj.(*v1.DataPlanes)
+		in.Status.Upgrade = state
+		if state != nil {
+			in.Status.Phase = v1.UpdatingD
+		}
+		for _, condition := range conditions {
+			in.Status.Conditions = in.AddCondition(condition)
+		}
+		return in
+	})
+	return err
+}
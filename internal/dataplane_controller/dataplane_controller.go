@@ -13,7 +13,6 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
-	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -24,7 +23,9 @@ import (
 	"github.com/baazhq/baaz/pkg/aws/eks"
 	"github.com/baazhq/baaz/pkg/aws/network"
 	"github.com/baazhq/baaz/pkg/helm"
+	"github.com/baazhq/baaz/pkg/logging"
 	"github.com/baazhq/baaz/pkg/store"
+	"github.com/baazhq/baaz/pkg/tracing"
 	"github.com/baazhq/baaz/pkg/utils"
 )
 
@@ -45,10 +46,12 @@ type DataPlaneReconciler struct {
 	CustomerName    string
 	EnablePrivate   bool
 	InClusterClient client.Client
+	// RepoCache caches the indexes of the chart repos across releases
+	RepoCache *helm.RepoCache
 }
 
-func NewDataplaneReconciler(mgr ctrl.Manager, enablePrivate bool, customerName string) *DataPlaneReconciler {
-	initLogger := ctrl.Log.WithName("controllers").WithName("dataplane")
+func NewDataplaneReconciler(mgr ctrl.Manager, enablePrivate bool, customerName string, repoCache *helm.RepoCache) *DataPlaneReconciler {
+	initLogger := logging.Logger(logging.Dataplane)
 	inClusterClient, err := getInClusterClient()
 	if err != nil {
 		panic(err)
@@ -63,6 +66,7 @@ func NewDataplaneReconciler(mgr ctrl.Manager, enablePrivate bool, customerName s
 		Predicates:      predicates.GetPredicates(enablePrivate, customerName, mgr.GetClient()),
 		NgStore:         store.NewInternalStore(),
 		InClusterClient: inClusterClient,
+		RepoCache:       repoCache,
 	}
 }
 
@@ -114,11 +118,19 @@ func (r *DataPlaneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	ctx, span := tracing.StartReconcile(ctx, "DataPlaneReconciler", desiredObj)
+	defer span.End()
+
+	ctx, log := logging.WithValues(ctx,
+		logging.KeyCustomer, desiredObj.Namespace,
+		logging.KeyDataplane, desiredObj.Name,
+	)
+
 	if err := r.initCloudAuth(ctx, desiredObj); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	klog.Infof("Reconciling Dataplane: %s/%s", desiredObj.Namespace, desiredObj.Name)
+	log.V(1).Info("reconciling dataplane")
 	networkMgr, err := network.NewProvisioner(ctx, desiredObj.Spec.CloudInfra.Region)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -127,12 +139,13 @@ func (r *DataPlaneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if desiredObj.DeletionTimestamp != nil {
 		// object is going to be deleted
 		awsEnv := awsEnv{
-			ctx:     ctx,
-			dp:      desiredObj,
-			eksIC:   eks.NewEks(ctx, desiredObj),
-			client:  r.Client,
-			store:   r.NgStore,
-			network: networkMgr,
+			ctx:       ctx,
+			dp:        desiredObj,
+			eksIC:     eks.NewEks(ctx, desiredObj),
+			client:    r.Client,
+			store:     r.NgStore,
+			network:   networkMgr,
+			repoCache: r.RepoCache,
 		}
 
 		return r.reconcileDelete(&awsEnv)
@@ -154,7 +167,8 @@ func (r *DataPlaneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}); upErr != nil {
 			return ctrl.Result{}, upErr
 		}
-		klog.Errorf("failed to reconcile dataplane: reason: %s", err.Error())
+		span.RecordError(err)
+		logging.Error(log, err, "failed to reconcile dataplane")
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 	} else {
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
@@ -180,6 +194,8 @@ func (r *DataPlaneReconciler) uninstallCharts(ae *awsEnv) error {
 			}
 
 			helm := helm.NewHelm(
+				ae.ctx,
+				ae.repoCache,
 				app.Name,
 				app.Namespace,
 				app.Spec.ChartName,
@@ -194,7 +210,7 @@ func (r *DataPlaneReconciler) uninstallCharts(ae *awsEnv) error {
 
 			if exists {
 				count += 1
-				klog.Infof("uninstalling chart: %s", app.Name)
+				ae.log().Info("uninstalling chart", logging.KeyChart, app.Name)
 				go func(ch chan ChartCh, app v1.AppSpec) {
 					c := ChartCh{
 						Name: app.Name,
@@ -224,7 +240,7 @@ func (r *DataPlaneReconciler) uninstallCharts(ae *awsEnv) error {
 		chartCh := <-ch
 		var latestState v1.ApplicationPhase
 		if chartCh.Err != nil {
-			klog.Errorf("uninstalling chart %s failed, reason: %s", chartCh.Name, chartCh.Err.Error())
+			ae.log().Error(chartCh.Err, "uninstalling chart failed", logging.KeyChart, chartCh.Name)
 			errs = append(errs, chartCh.Err)
 			latestState = v1.FailedA
 		} else {
@@ -262,12 +278,21 @@ func (r *DataPlaneReconciler) reconcileDelete(ae *awsEnv) (ctrl.Result, error) {
 		return ctrl.Result{}, err
 	}
 
+	// karpenter runs on the system nodegroup and terminates its own nodes
+	if !ae.teardownKarpenter() {
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+
 	systemNodeGroupName := ae.dp.Spec.CloudInfra.Eks.Name + "-system"
 
 	_, found, _ := ae.eksIC.DescribeNodegroup(systemNodeGroupName)
 	if found {
 
 		if ae.dp.Status.NodegroupStatus[systemNodeGroupName] != "DELETING" {
+			// the system nodegroup is the last one, its pods have nowhere to
+			// be evicted to and their disruption budgets would hold the
+			// drain until it times out, so it is deleted without one. The
+			// tenant nodegroups are drained with their tenantsinfra.
 			_, _ = ae.eksIC.DeleteNodeGroup(systemNodeGroupName)
 			// update status with current nodegroup status
 			_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.dp, func(obj client.Object) client.Object {
@@ -283,7 +308,7 @@ func (r *DataPlaneReconciler) reconcileDelete(ae *awsEnv) (ctrl.Result, error) {
 			}
 
 		}
-		klog.Infof("waiting for nodegroup %s to be deleted", systemNodeGroupName)
+		ae.log().Info("waiting for nodegroup to be deleted", logging.KeyNodegroup, systemNodeGroupName)
 		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 	}
 
@@ -296,19 +321,19 @@ func (r *DataPlaneReconciler) reconcileDelete(ae *awsEnv) (ctrl.Result, error) {
 	}
 
 	if _, err := ae.eksIC.DeleteEKS(); err != nil {
-		klog.Infof("waiting for EKS to be deleted, current state: %s", err.Error())
+		ae.log().Info("waiting for eks to be deleted", "state", err.Error())
 		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 	}
 
 	if ae.dp.Spec.CloudInfra.ProvisionNetwork {
 		if err := deleteNetworkComponent(ae); err != nil {
-			klog.Infof("waiting for network components to be deleted, current state: %s", err.Error())
+			ae.log().Info("waiting for network components to be deleted", "state", err.Error())
 			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 		}
 	}
 
 	// remove our finalizer from the list and update it.
-	klog.Infof("Deleted Dataplane [%s]", ae.dp.GetName())
+	ae.log().Info("deleted dataplane")
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		delDP := &v1.DataPlanes{}
 		if err := ae.client.Get(ae.ctx, client.ObjectKeyFromObject(ae.dp), delDP); err != nil {
@@ -419,6 +444,7 @@ func (r *DataPlaneReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1.DataPlanes{}).
 		WithEventFilter(r.Predicates).
+		WithLogConstructor(logging.RequestLogger(r.Log)).
 		Complete(r)
 }
 
@@ -0,0 +1,75 @@
+package gitsource_controller
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/gitops"
+)
+
+// maxHistory is the number of commits whose diff is kept.
+const maxHistory = 10
+
+type change int
+
+const (
+	unchanged change = iota
+	created
+	updated
+)
+
+// diff tells how applying object changes live, the object of the cluster,
+// nil when there is none. source is the SourceLabel value of the GitSource.
+func diff(object, live *unstructured.Unstructured, source string) change {
+	switch {
+	case live == nil:
+		return created
+	case live.GetAnnotations()[gitops.HashAnnotation] != object.GetAnnotations()[gitops.HashAnnotation],
+		// the objects created by khota are taken over
+		live.GetLabels()[gitops.SourceLabel] != source:
+		return updated
+	}
+	return unchanged
+}
+
+// entityName names an object in the history, like Kind namespace/name.
+func entityName(kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + " " + name
+	}
+	return kind + " " + namespace + "/" + name
+}
+
+func names(resources []v1.ResourceStatus) []string {
+	var names []string
+	for _, resource := range resources {
+		names = append(names, entityName(resource.Kind, resource.Namespace, resource.Name))
+	}
+	return names
+}
+
+// pruned returns the names of the stale objects but the ones failing to be
+// pruned.
+func pruned(stale, failed []v1.ResourceStatus) []string {
+	failing := make(map[string]bool, len(failed))
+	for _, name := range names(failed) {
+		failing[name] = true
+	}
+	var pruned []string
+	for _, name := range names(stale) {
+		if !failing[name] {
+			pruned = append(pruned, name)
+		}
+	}
+	return pruned
+}
+
+// appendHistory appends record to history, keeping the last maxHistory
+// records.
+func appendHistory(history []v1.GitSyncRecord, record v1.GitSyncRecord) []v1.GitSyncRecord {
+	history = append(history, record)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return history
+}
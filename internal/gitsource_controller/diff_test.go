@@ -0,0 +1,70 @@
+package gitsource_controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/gitops"
+)
+
+func object(hash, source string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAnnotations(map[string]string{gitops.HashAnnotation: hash})
+	if source != "" {
+		u.SetLabels(map[string]string{gitops.SourceLabel: source})
+	}
+	return u
+}
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		live *unstructured.Unstructured
+		want change
+	}{
+		{nil, created},
+		{object("a", "ops.fleet"), unchanged},
+		{object("b", "ops.fleet"), updated},
+		// created by khota or another source
+		{object("a", ""), updated},
+		{object("a", "ops.other"), updated},
+		{object("a", "dev.fleet"), updated},
+	} {
+		if got := diff(object("a", "ops.fleet"), tc.live, "ops.fleet"); got != tc.want {
+			t.Fatalf("diff with %v = %d, expected %d", tc.live, got, tc.want)
+		}
+	}
+}
+
+func TestPruned(t *testing.T) {
+	stale := []v1.ResourceStatus{
+		{Kind: "Namespace", Name: "foo"},
+		{Kind: "Tenants", Namespace: "foo", Name: "analytics"},
+		{Kind: "Applications", Namespace: "foo", Name: "foo-analytics-apps"},
+	}
+	failed := []v1.ResourceStatus{{Kind: "Tenants", Namespace: "foo", Name: "analytics", Phase: v1.FailedRS}}
+
+	want := []string{"Namespace foo", "Applications foo/foo-analytics-apps"}
+	if got := pruned(stale, failed); !reflect.DeepEqual(got, want) {
+		t.Fatalf("pruned = %v, expected %v", got, want)
+	}
+	if got := names(failed); !reflect.DeepEqual(got, []string{"Tenants foo/analytics"}) {
+		t.Fatalf("names = %v", got)
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	var history []v1.GitSyncRecord
+	for i := 0; i < maxHistory+3; i++ {
+		history = appendHistory(history, v1.GitSyncRecord{Revision: fmt.Sprint(i)})
+	}
+	if len(history) != maxHistory {
+		t.Fatalf("kept %d records, expected %d", len(history), maxHistory)
+	}
+	if first, last := history[0].Revision, history[maxHistory-1].Revision; first != "3" || last != "12" {
+		t.Fatalf("kept records %s to %s, expected 3 to 12", first, last)
+	}
+}
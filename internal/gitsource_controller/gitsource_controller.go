@@ -0,0 +1,92 @@
+package gitsource_controller
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/tracing"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultInterval = time.Minute
+
+// GitSourceReconciler reconciles a GitSource object
+type GitSourceReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// RestConfig applies the entities, of every kind, with server-side apply
+	RestConfig *rest.Config
+	Recorder   record.EventRecorder
+	// AllowLocal lets the sources check out the local repositories, the
+	// operator allowing them
+	AllowLocal bool
+}
+
+func NewGitSourceReconciler(mgr ctrl.Manager, allowLocal bool) *GitSourceReconciler {
+	return &GitSourceReconciler{
+		Client:     mgr.GetClient(),
+		Log:        logging.Logger(logging.GitSource),
+		Scheme:     mgr.GetScheme(),
+		RestConfig: mgr.GetConfig(),
+		Recorder:   mgr.GetEventRecorderFor("gitsource-controller"),
+		AllowLocal: allowLocal,
+	}
+}
+
+//+kubebuilder:rbac:groups=baaz.dev,resources=gitsources,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=baaz.dev,resources=gitsources/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=baaz.dev,resources=dataplanes;tenantsinfras;tenants;applications,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=namespaces;serviceaccounts;secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete;bind;escalate
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *GitSourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	source := &v1.GitSource{}
+	if err := r.Get(ctx, req.NamespacedName, source); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	ctx, span := tracing.StartReconcile(ctx, "GitSourceReconciler", source)
+	defer span.End()
+
+	ctx, log := logging.WithValues(ctx, logging.KeyGitSource, source.Name)
+
+	if source.DeletionTimestamp != nil {
+		// the entities are left in place
+		return ctrl.Result{}, nil
+	}
+
+	interval := defaultInterval
+	if source.Spec.Interval != nil {
+		interval = source.Spec.Interval.Duration
+	}
+	// the changes of the spec are synced right away
+	if last := source.Status.LastSyncTime; last != nil && source.Status.ObservedGeneration == source.Generation {
+		if wait := interval - time.Since(last.Time); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	if err := r.do(ctx, source); err != nil {
+		span.RecordError(err)
+		logging.Error(log, err, "failed to reconcile git source")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GitSourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.GitSource{}).
+		WithLogConstructor(logging.RequestLogger(r.Log)).
+		Complete(r)
+}
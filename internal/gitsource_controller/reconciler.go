@@ -0,0 +1,188 @@
+package gitsource_controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/git"
+	"github.com/baazhq/baaz/pkg/gitops"
+	"github.com/baazhq/baaz/pkg/helm"
+	"github.com/baazhq/baaz/pkg/manifests"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// do pulls the repository of source, applies the entities it declares and
+// prunes the ones removed, and records the diff of the commit.
+func (r *GitSourceReconciler) do(ctx context.Context, source *v1.GitSource) error {
+	log := ctrl.LoggerFrom(ctx)
+	now := metav1.Now()
+
+	objects, revision, err := r.render(ctx, source)
+	if err != nil {
+		// nothing is applied nor pruned until the repository is fixed
+		log.Error(err, "rendering git source failed")
+		r.Recorder.Event(source, corev1.EventTypeWarning, string(v1.FailedGS), err.Error())
+		return r.patchStatus(ctx, source, func(status *v1.GitSourceStatus) {
+			status.Phase = v1.FailedGS
+			status.Message = err.Error()
+			status.LastSyncTime = &now
+			status.ObservedGeneration = source.Generation
+		})
+	}
+
+	applier, err := manifests.NewApplier(r.RestConfig)
+	if err != nil {
+		return err
+	}
+	applier.ClusterKinds, applier.OwnNamespaces = gitops.ClusterKinds, true
+	labels := map[string]string{gitops.SourceLabel: gitops.SourceLabelValue(source.Namespace, source.Name)}
+
+	changes := make(map[string]change, len(objects))
+	for _, object := range objects {
+		live, err := applier.Live(ctx, object)
+		if err != nil {
+			// the apply reports the object
+			continue
+		}
+		changes[entityName(object.GetKind(), object.GetNamespace(), object.GetName())] = diff(object, live, labels[gitops.SourceLabel])
+	}
+	resources := applier.Apply(ctx, "", labels, objects)
+
+	record := v1.GitSyncRecord{Revision: revision, SyncedAt: now}
+	for _, resource := range resources {
+		name := entityName(resource.Kind, resource.Namespace, resource.Name)
+		switch {
+		case resource.Phase == v1.FailedRS:
+			record.Failed = append(record.Failed, name)
+		case changes[name] == created:
+			record.Created = append(record.Created, name)
+		case changes[name] == updated:
+			record.Updated = append(record.Updated, name)
+		}
+	}
+
+	stale := manifests.Stale(source.Status.Resources, resources)
+	var orphaned []string
+	message := ""
+	switch {
+	case len(stale) > 0 && source.Spec.Prune && len(objects) == 0:
+		// an emptied repository is more likely a mistake than a decommission
+		message = "the repository declares no entity, nothing is pruned"
+		fallthrough
+	case !source.Spec.Prune:
+		for _, resource := range stale {
+			orphaned = append(orphaned, entityName(resource.Kind, resource.Namespace, resource.Name))
+			resources = append(resources, resource)
+		}
+	default:
+		failed := applier.Prune(ctx, labels, stale)
+		record.Pruned, record.Failed = pruned(stale, failed), append(record.Failed, names(failed)...)
+		resources = append(resources, failed...)
+	}
+
+	phase := v1.SyncedGS
+	if len(record.Failed) > 0 {
+		phase = v1.FailedGS
+		message = fmt.Sprintf("%d objects failed: %s", len(record.Failed), failures(resources))
+	}
+
+	changed := revision != source.Status.Revision || len(record.Created)+len(record.Updated)+len(record.Pruned)+len(record.Failed) > 0
+	if changed {
+		log.Info("synced git source", "revision", revision,
+			"created", len(record.Created), "updated", len(record.Updated),
+			"pruned", len(record.Pruned), "failed", len(record.Failed))
+		eventType := corev1.EventTypeNormal
+		if phase == v1.FailedGS {
+			eventType = corev1.EventTypeWarning
+		}
+		r.Recorder.Eventf(source, eventType, string(phase), "%s: %d created, %d updated, %d pruned, %d failed",
+			shortRevision(revision), len(record.Created), len(record.Updated), len(record.Pruned), len(record.Failed))
+	}
+
+	return r.patchStatus(ctx, source, func(status *v1.GitSourceStatus) {
+		status.Phase = phase
+		status.Revision = revision
+		status.Message = message
+		status.LastSyncTime = &now
+		status.ObservedGeneration = source.Generation
+		status.Resources = resources
+		status.Orphaned = orphaned
+		if changed {
+			status.History = appendHistory(status.History, record)
+		}
+	})
+}
+
+// render checks the repository of source out and renders its entities. It
+// returns their objects and the commit.
+func (r *GitSourceReconciler) render(ctx context.Context, source *v1.GitSource) ([]*unstructured.Unstructured, string, error) {
+	// the objects are labelled with the namespace and name of source
+	if errs := validation.IsValidLabelValue(gitops.SourceLabelValue(source.Namespace, source.Name)); len(errs) > 0 {
+		return nil, "", fmt.Errorf("namespace and name are too long to label the objects: %s", strings.Join(errs, ", "))
+	}
+
+	var auth *git.Auth
+	if source.Spec.Git.SecretName != "" {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: source.Namespace, Name: source.Spec.Git.SecretName}, secret); err != nil {
+			return nil, "", err
+		}
+		auth = &git.Auth{
+			Username: string(secret.Data[helm.UsernameKey]),
+			Password: string(secret.Data[helm.PasswordKey]),
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "baaz-gitops-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(dir)
+
+	commit, err := git.Checkout(ctx, source.Spec.Git.URL, source.Spec.Git.Ref, auth, dir, r.AllowLocal)
+	if err != nil {
+		return nil, "", err
+	}
+	objects, err := gitops.Render(filepath.Join(dir, filepath.Clean("/"+source.Spec.Git.Path)))
+	if err != nil {
+		return nil, commit, fmt.Errorf("%s: %w", shortRevision(commit), err)
+	}
+	return objects, commit, nil
+}
+
+func (r *GitSourceReconciler) patchStatus(ctx context.Context, source *v1.GitSource, update func(*v1.GitSourceStatus)) error {
+	_, _, err := utils.PatchStatus(ctx, r.Client, source, func(obj client.Object) client.Object {
+		in := obj.(*v1.GitSource)
+		update(&in.Status)
+		return in
+	})
+	return err
+}
+
+func failures(resources []v1.ResourceStatus) string {
+	var failed []string
+	for _, resource := range resources {
+		if resource.Phase == v1.FailedRS {
+			failed = append(failed, entityName(resource.Kind, resource.Namespace, resource.Name)+": "+resource.Message)
+		}
+	}
+	return strings.Join(failed, "; ")
+}
+
+func shortRevision(revision string) string {
+	if len(revision) > 7 {
+		return revision[:7]
+	}
+	return revision
+}
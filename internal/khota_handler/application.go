@@ -3,24 +3,55 @@ package khota_handler
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 
 	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/gitops"
+	"github.com/baazhq/baaz/pkg/health"
+	"github.com/baazhq/baaz/pkg/helm"
 )
 
 var applicationGVK = schema.GroupVersionResource{
-	Group:    "datainfra.io",
+	Group:    "baaz.dev",
 	Version:  "v1",
 	Resource: "applications",
 }
 
+// podEventsLimit is the number of events reported by pod.
+const podEventsLimit = 10
+
+type applicationResp struct {
+	Name      string              `json:"name"`
+	Tenant    string              `json:"tenant"`
+	Dataplane string              `json:"dataplane"`
+	Phase     v1.ApplicationPhase `json:"phase"`
+	Apps      []appResp           `json:"apps"`
+}
+
+type appResp struct {
+	Name      string              `json:"name"`
+	Namespace string              `json:"namespace,omitempty"`
+	Phase     v1.ApplicationPhase `json:"phase,omitempty"`
+	Reason    string              `json:"reason,omitempty"`
+	Health    v1.HealthStatus     `json:"health,omitempty"`
+	Message   string              `json:"message,omitempty"`
+	Resources []health.Resource   `json:"resources,omitempty"`
+}
+
 func CreateApplication(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 
@@ -32,14 +63,14 @@ func CreateApplication(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 	var applications []v1.HTTPApplication
@@ -47,7 +78,14 @@ func CreateApplication(w http.ResponseWriter, req *http.Request) {
 	if err := json.Unmarshal(body, &applications); err != nil {
 		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
+		return
+	}
+
+	if err := validateValues(applications); err != nil {
+		res := NewResponse(ApplicationInvalidValues, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
 		return
 	}
 
@@ -57,26 +95,19 @@ func CreateApplication(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(CustomerNamespaceGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	dataplaneName := customer.GetLabels()["dataplane"]
-	var labels map[string]string
-	if customer.GetLabels()["saas_type"] == string(v1.PrivateSaaS) {
-		labels = map[string]string{
-			"customer_" + customerName: customerName,
-			v1.PrivateObjectLabelKey:   "true",
-			"tenant":                   tenantName,
-		}
-	}
+	labels := applicationLabels(customer, tenantName)
 	appDeploy := makeApplicationConfig(applications, dataplaneName, tenantName, applicationName, labels)
 
-	_, err = dc.Resource(applicationGVK).Namespace(customerName).Create(context.TODO(), appDeploy, metav1.CreateOptions{})
+	_, err = dc.Resource(applicationGVK).Namespace(customerName).Create(context.TODO(), withTraceContext(req, appDeploy), metav1.CreateOptions{})
 	if err != nil {
 		res := NewResponse(ApplicationCreateFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		sendEventParseable(applicationsEventStream, ApplicationCreationFailEvent, appDeploy.GetLabels(), map[string]string{"application_name": appDeploy.GetName()})
 		return
 	}
@@ -87,6 +118,19 @@ func CreateApplication(w http.ResponseWriter, req *http.Request) {
 
 }
 
+// applicationLabels returns the labels of the applications of a tenant of
+// customer, the private saas customers only have any.
+func applicationLabels(customer *corev1.Namespace, tenantName string) map[string]string {
+	if customer.GetLabels()["saas_type"] != string(v1.PrivateSaaS) {
+		return nil
+	}
+	return map[string]string{
+		"customer_" + customer.Name: customer.Name,
+		v1.PrivateObjectLabelKey:    "true",
+		"tenant":                    tenantName,
+	}
+}
+
 func GetApplicationStatus(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 
@@ -99,7 +143,7 @@ func GetApplicationStatus(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ApplicationGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -109,6 +153,133 @@ func GetApplicationStatus(w http.ResponseWriter, req *http.Request) {
 
 }
 
+// GetApplication returns an application with the health of its apps, and
+// the tree of their resources on the dataplane with the recent events of
+// their pods.
+func GetApplication(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+
+	customerName := vars["customer_name"]
+	applicationName := vars["application_name"]
+
+	_, dc := getKubeClientset()
+
+	applicationObj, err := dc.Resource(applicationGVK).Namespace(customerName).Get(req.Context(), applicationName, metav1.GetOptions{})
+	if err != nil {
+		code := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			code = http.StatusNotFound
+		}
+		res := NewResponse(ApplicationGetFail, internal_error, err, code)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	application := &v1.Applications{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(applicationObj.Object, application); err != nil {
+		res := NewResponse(ApplicationGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	restConfig, err := dataplaneRestConfig(req.Context(), application.Spec.Dataplane)
+	var clientset kubernetes.Interface
+	if err == nil {
+		clientset, err = kubernetes.NewForConfig(restConfig)
+	}
+	if err != nil {
+		res := NewResponse(ApplicationHealthFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	resp := applicationResp{
+		Name:      application.Name,
+		Tenant:    application.Spec.Tenant,
+		Dataplane: application.Spec.Dataplane,
+		Phase:     application.Status.Phase,
+	}
+	// the apps deployed, the ones of the blueprint of the spec included
+	apps := application.Status.ApplicationCurrentSpec.Applications
+	if len(apps) == 0 {
+		apps = application.Spec.Applications
+	}
+	for _, app := range apps {
+		chartName := app.ChartName()
+		appResp := appResp{
+			Name:      app.Name,
+			Namespace: app.Namespace,
+			Phase:     application.Status.AppStatus[chartName],
+			Reason:    application.Status.Reasons[chartName],
+		}
+
+		objects, err := appObjects(req.Context(), application, app, restConfig)
+		if err != nil {
+			res := NewResponse(ApplicationHealthFail, internal_error, err, http.StatusInternalServerError)
+			res.SetResponse(&w)
+			res.LogResponse(req)
+			return
+		}
+		if len(objects) > 0 {
+			now := time.Now()
+			resources, err := health.Inspect(req.Context(), clientset, objects, now)
+			if err == nil {
+				err = health.WithEvents(req.Context(), clientset, resources, podEventsLimit)
+			}
+			if err != nil {
+				res := NewResponse(ApplicationHealthFail, internal_error, err, http.StatusInternalServerError)
+				res.SetResponse(&w)
+				res.LogResponse(req)
+				return
+			}
+			report := health.Report(resources, now)
+			appResp.Health, appResp.Message, appResp.Resources = report.Status, report.Message, resources
+		}
+		resp.Apps = append(resp.Apps, appResp)
+	}
+
+	bytes, _ := json.Marshal(resp)
+	sendJsonResponse(bytes, http.StatusOK, &w)
+}
+
+// appObjects returns the resources of app to inspect. The ones recorded by
+// the last check are inspected again, their pods may have changed since.
+// The apps not checked yet, or whose check failed, fall back to the objects
+// applied for their source or to the manifest of their release.
+func appObjects(ctx context.Context, application *v1.Applications, app v1.AppSpec, restConfig *rest.Config) ([]health.Object, error) {
+	chartName := app.ChartName()
+	if checked := application.Status.Health[chartName]; len(checked.Resources) > 0 {
+		var objects []health.Object
+		for _, resource := range checked.Resources {
+			objects = append(objects, health.Object{Kind: resource.Kind, Namespace: resource.Namespace, Name: resource.Name})
+		}
+		return objects, nil
+	}
+	if app.Source != nil {
+		return health.AppliedObjects(application.Status.Manifests[chartName].Resources), nil
+	}
+
+	spec := app.Spec
+	releases, err := helm.NewHelm(ctx, nil, app.Name, application.Spec.Tenant,
+		spec.ChartName, spec.RepoName, spec.RepoUrl, spec.Version, restConfig, nil).History(restConfig)
+	if err != nil || len(releases) == 0 {
+		return nil, err
+	}
+	return health.ManifestObjects(releases[0].Manifest, application.Spec.Tenant)
+}
+
+// dataplaneRestConfig returns the rest config of the cluster of a dataplane.
+func dataplaneRestConfig(ctx context.Context, dataplaneName string) (*rest.Config, error) {
+	_, dc := getKubeClientset()
+	dataplane, err := getDataPlane(ctx, dc, dataplaneName)
+	if err != nil {
+		return nil, err
+	}
+	return eks.NewEks(ctx, dataplane).GetRestConfig()
+}
+
 func DeleteApplicationStatus(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 
@@ -121,7 +292,7 @@ func DeleteApplicationStatus(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ApplicationGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -140,14 +311,14 @@ func UpdateApplication(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 	var applications []v1.HTTPApplication
@@ -155,7 +326,14 @@ func UpdateApplication(w http.ResponseWriter, req *http.Request) {
 	if err := json.Unmarshal(body, &applications); err != nil {
 		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
+		return
+	}
+
+	if err := validateValues(applications); err != nil {
+		res := NewResponse(ApplicationInvalidValues, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
 		return
 	}
 
@@ -165,14 +343,14 @@ func UpdateApplication(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ApplicationUpdateFail, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 	ob := &v1.Applications{}
 	if errCon := runtime.DefaultUnstructuredConverter.FromUnstructured(existingObj.Object, ob); errCon != nil {
 		res := NewResponse(ApplicationUpdateFail, req_error, errCon, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -183,7 +361,13 @@ func UpdateApplication(w http.ResponseWriter, req *http.Request) {
 
 	for idx, app := range ob.Spec.Applications {
 		if inputApp, found := inputAppMap[app.Name]; found {
-			ob.Spec.Applications[idx].Spec.Version = inputApp.Version
+			if inputApp.Source != nil {
+				ob.Spec.Applications[idx].Source = inputApp.Source
+			} else {
+				ob.Spec.Applications[idx].Spec = updateChartSpec(app.Spec, inputApp)
+			}
+			// an update supersedes a rollback
+			ob.Spec.Applications[idx].RollbackRevision = 0
 		}
 	}
 
@@ -191,18 +375,118 @@ func UpdateApplication(w http.ResponseWriter, req *http.Request) {
 	if errCon != nil {
 		res := NewResponse(ApplicationUpdateFail, req_error, errCon, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
-	_, uperr := dc.Resource(applicationGVK).Namespace(customerName).Update(context.TODO(), &unstructured.Unstructured{Object: upObj}, metav1.UpdateOptions{})
+	_, uperr := dc.Resource(applicationGVK).Namespace(customerName).Update(context.TODO(), withTraceContext(req, &unstructured.Unstructured{Object: upObj}), metav1.UpdateOptions{})
 	if uperr != nil {
 		res := NewResponse(ApplicationUpdateFail, req_error, uperr, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 	res := NewResponse(ApplicationUpdateSuccess, success, nil, http.StatusOK)
 	res.SetResponse(&w)
-	res.LogResponse()
+	res.LogResponse(req)
+}
+
+// RollbackApplication rolls an application back to a revision of its
+// release, by restoring the spec the revision was deployed from.
+func RollbackApplication(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+
+	customerName := vars["customer_name"]
+	applicationName := vars["application_name"]
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, 1048576))
+	if err != nil {
+		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	if err := req.Body.Close(); err != nil {
+		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	var rollback v1.HTTPApplicationRollback
+
+	if err := json.Unmarshal(body, &rollback); err != nil {
+		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	_, dc := getKubeClientset()
+
+	existingObj, err := dc.Resource(applicationGVK).Namespace(customerName).Get(context.TODO(), applicationName, metav1.GetOptions{})
+	if err != nil {
+		res := NewResponse(ApplicationRollbackFail, req_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	ob := &v1.Applications{}
+	if errCon := runtime.DefaultUnstructuredConverter.FromUnstructured(existingObj.Object, ob); errCon != nil {
+		res := NewResponse(ApplicationRollbackFail, req_error, errCon, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	// the GitSource would apply the spec of git over the rollback
+	if source := gitops.SourceOf(ob.GetLabels()); source != "" {
+		err := fmt.Errorf("applications %s are managed by GitSource %s, roll back in git", ob.Name, source)
+		res := NewResponse(ApplicationGitManaged, req_error, err, http.StatusConflict)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	found := false
+	for idx, app := range ob.Spec.Applications {
+		if app.Name != rollback.ApplicationName {
+			continue
+		}
+		// only the revisions recorded with their spec can be restored
+		release := ob.Status.Releases[app.ChartName()]
+		for _, revision := range release.History {
+			spec, ok := release.Specs[revision.SpecHash]
+			if revision.Revision == rollback.Revision && revision.SpecHash != "" && ok {
+				ob.Spec.Applications[idx].Spec = spec
+				ob.Spec.Applications[idx].RollbackRevision = revision.Revision
+				found = true
+			}
+		}
+	}
+	if !found {
+		res := NewResponse(ApplicationRevisionNotFound, req_error, nil, http.StatusNotFound)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	upObj, errCon := runtime.DefaultUnstructuredConverter.ToUnstructured(ob)
+	if errCon != nil {
+		res := NewResponse(ApplicationRollbackFail, req_error, errCon, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	_, uperr := dc.Resource(applicationGVK).Namespace(customerName).Update(context.TODO(), withTraceContext(req, &unstructured.Unstructured{Object: upObj}), metav1.UpdateOptions{})
+	if uperr != nil {
+		res := NewResponse(ApplicationRollbackFail, req_error, uperr, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	res := NewResponse(ApplicationRollbackSuccess, success, nil, http.StatusOK)
+	res.SetResponse(&w)
+	res.LogResponse(req)
 }
@@ -0,0 +1,248 @@
+package khota_handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/blueprint"
+)
+
+var blueprintGVK = schema.GroupVersionResource{
+	Group:    "baaz.dev",
+	Version:  "v1",
+	Resource: "applicationblueprints",
+}
+
+// ListBlueprints lists the application blueprints of the catalog.
+func ListBlueprints(w http.ResponseWriter, req *http.Request) {
+	_, dc := getKubeClientset()
+
+	blueprints, err := dc.Resource(blueprintGVK).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		res := NewResponse(BlueprintListFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	type blueprintResp struct {
+		Name     string   `json:"name"`
+		Version  string   `json:"version"`
+		Versions []string `json:"versions"`
+		AppType  string   `json:"app_type,omitempty"`
+	}
+
+	var blueprintsResp []blueprintResp
+	for _, item := range blueprints.Items {
+		bp := &v1.ApplicationBlueprint{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, bp); err != nil {
+			continue
+		}
+		resp := blueprintResp{
+			Name:    bp.Name,
+			Version: bp.Spec.Version,
+			AppType: string(bp.Spec.AppType),
+		}
+		for _, version := range bp.Spec.Versions {
+			resp.Versions = append(resp.Versions, version.Version)
+		}
+		blueprintsResp = append(blueprintsResp, resp)
+	}
+
+	bytes, _ := json.Marshal(blueprintsResp)
+	sendJsonResponse(bytes, http.StatusOK, &w)
+}
+
+// CreateBlueprintApplication instantiates a blueprint for a tenant, after
+// validating the parameters against the schema of its version.
+func CreateBlueprintApplication(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+
+	customerName := vars["customer_name"]
+	tenantName := vars["tenant_name"]
+
+	instance, ok := readBlueprintApplication(w, req)
+	if !ok {
+		return
+	}
+	applicationName := customerName + "-" + tenantName + "-" + instance.BlueprintName
+
+	kc, dc := getKubeClientset()
+
+	customer, err := kc.CoreV1().Namespaces().Get(context.TODO(), customerName, metav1.GetOptions{})
+	if err != nil {
+		res := NewResponse(CustomerNamespaceGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	ref := v1.BlueprintRef{Name: instance.BlueprintName, Version: instance.Version, Parameters: instance.Parameters}
+	if !validateBlueprintApplication(w, req, dc, customerName, tenantName, ref) {
+		return
+	}
+
+	labels := applicationLabels(customer, tenantName)
+	appDeploy := makeBlueprintApplicationConfig(ref, customer.GetLabels()["dataplane"], tenantName, applicationName, labels)
+
+	_, err = dc.Resource(applicationGVK).Namespace(customerName).Create(context.TODO(), withTraceContext(req, appDeploy), metav1.CreateOptions{})
+	if err != nil {
+		res := NewResponse(ApplicationCreateFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		sendEventParseable(applicationsEventStream, ApplicationCreationFailEvent, appDeploy.GetLabels(), map[string]string{"application_name": appDeploy.GetName()})
+		return
+	}
+
+	res := NewResponse(ApplicationCreateIntiated, success, nil, http.StatusOK)
+	sendEventParseable(applicationsEventStream, ApplicationCreationSuccessEvent, appDeploy.GetLabels(), map[string]string{"application_name": appDeploy.GetName()})
+	res.SetResponse(&w)
+}
+
+// UpdateBlueprintApplication moves a blueprint instance to another version
+// or parameters.
+func UpdateBlueprintApplication(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+
+	customerName := vars["customer_name"]
+	applicationName := vars["application_name"]
+
+	instance, ok := readBlueprintApplication(w, req)
+	if !ok {
+		return
+	}
+
+	_, dc := getKubeClientset()
+
+	existingObj, err := dc.Resource(applicationGVK).Namespace(customerName).Get(context.TODO(), applicationName, metav1.GetOptions{})
+	if err != nil {
+		res := NewResponse(ApplicationUpdateFail, req_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	ob := &v1.Applications{}
+	if errCon := runtime.DefaultUnstructuredConverter.FromUnstructured(existingObj.Object, ob); errCon != nil {
+		res := NewResponse(ApplicationUpdateFail, req_error, errCon, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	if ob.Spec.Blueprint == nil {
+		res := NewResponse(BlueprintNotInstantiated, req_error, nil, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	ob.Spec.Blueprint.Version = instance.Version
+	if instance.Parameters != nil {
+		ob.Spec.Blueprint.Parameters = instance.Parameters
+	}
+	if !validateBlueprintApplication(w, req, dc, customerName, ob.Spec.Tenant, *ob.Spec.Blueprint) {
+		return
+	}
+
+	upObj, errCon := runtime.DefaultUnstructuredConverter.ToUnstructured(ob)
+	if errCon != nil {
+		res := NewResponse(ApplicationUpdateFail, req_error, errCon, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	_, uperr := dc.Resource(applicationGVK).Namespace(customerName).Update(context.TODO(), withTraceContext(req, &unstructured.Unstructured{Object: upObj}), metav1.UpdateOptions{})
+	if uperr != nil {
+		res := NewResponse(ApplicationUpdateFail, req_error, uperr, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	res := NewResponse(ApplicationUpdateSuccess, success, nil, http.StatusOK)
+	res.SetResponse(&w)
+	res.LogResponse(req)
+}
+
+func readBlueprintApplication(w http.ResponseWriter, req *http.Request) (v1.HTTPBlueprintApplication, bool) {
+	var instance v1.HTTPBlueprintApplication
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, 1048576))
+	if err != nil {
+		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return instance, false
+	}
+
+	if err := req.Body.Close(); err != nil {
+		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return instance, false
+	}
+
+	if err := json.Unmarshal(body, &instance); err != nil {
+		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return instance, false
+	}
+	return instance, true
+}
+
+// validateBlueprintApplication renders ref for the size of the tenant, and
+// answers the request when it cannot be.
+func validateBlueprintApplication(
+	w http.ResponseWriter,
+	req *http.Request,
+	dc dynamic.Interface,
+	customerName, tenantName string,
+	ref v1.BlueprintRef,
+) bool {
+	bpObj, err := dc.Resource(blueprintGVK).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		res := NewResponse(BlueprintGetFail, req_error, err, status)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return false
+	}
+	bp := &v1.ApplicationBlueprint{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(bpObj.Object, bp); err != nil {
+		res := NewResponse(BlueprintGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return false
+	}
+
+	size := ""
+	tenantObj, err := dc.Resource(tenantGVK).Namespace(customerName).Get(context.TODO(), tenantName, metav1.GetOptions{})
+	if err == nil {
+		tenant := &v1.Tenants{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(tenantObj.Object, tenant); err == nil {
+			size = blueprint.TenantSize(bp, tenant)
+		}
+	}
+
+	if _, err := blueprint.Render(bp, ref, size); err != nil {
+		res := NewResponse(BlueprintInvalidParameters, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return false
+	}
+	return true
+}
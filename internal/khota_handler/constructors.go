@@ -2,10 +2,15 @@ package khota_handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 
 	v1 "github.com/baazhq/baaz/api/v1/types"
@@ -50,16 +55,30 @@ func makeAwsEksConfig(dataPlaneName string, dataplane v1.DataPlane, labels map[s
 		allApplications = append(allApplications, map[string]interface{}{
 			"name":      app.ApplicationName,
 			"namespace": app.Namespace,
-			"spec": map[string]interface{}{
-				"chartName": app.ChartName,
-				"repoName":  app.RepoName,
-				"repoUrl":   app.RepoURL,
-				"version":   app.Version,
-				"values":    app.Values,
-			},
+			"spec":      makeChartSpec(app),
 		})
 	}
 
+	eksConfig := map[string]interface{}{
+		"name":             dataPlaneName,
+		"subnetIds":        dataplane.KubeConfig.EKS.SubnetIds,
+		"securityGroupIds": dataplane.KubeConfig.EKS.SecurityGroupIds,
+		"version":          dataplane.KubeConfig.EKS.Version,
+	}
+	var addons []interface{}
+	for i := range dataplane.KubeConfig.EKS.Addons {
+		addon, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&dataplane.KubeConfig.EKS.Addons[i])
+		if err == nil {
+			addons = append(addons, addon)
+		}
+	}
+	if len(addons) > 0 {
+		eksConfig["addons"] = addons
+	}
+	if dataplane.KubeConfig.EKS.Provisioner != "" {
+		eksConfig["provisioner"] = string(dataplane.KubeConfig.EKS.Provisioner)
+	}
+
 	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "baaz.dev/v1",
@@ -79,12 +98,7 @@ func makeAwsEksConfig(dataPlaneName string, dataplane v1.DataPlane, labels map[s
 					},
 					"provisionNetwork": dataplane.ProvisionNetwork,
 					"vpcCidr":          dataplane.VpcCidr,
-					"eks": map[string]interface{}{
-						"name":             dataPlaneName,
-						"subnetIds":        dataplane.KubeConfig.EKS.SubnetIds,
-						"securityGroupIds": dataplane.KubeConfig.EKS.SecurityGroupIds,
-						"version":          dataplane.KubeConfig.EKS.Version,
-					},
+					"eks":              eksConfig,
 				},
 				"applications": allApplications,
 			},
@@ -97,19 +111,10 @@ func makeTenantConfig(
 	tenant v1.HTTPTenant,
 	dataplaneName string,
 	labels map[string]string) *unstructured.Unstructured {
-	var networkSecurityEnabled bool
-	var allowedNamespaces []string
-
-	if tenant.NetworkSecurity.InterNamespaceTraffic == v1.Deny {
-		networkSecurityEnabled = true
-		if tenant.NetworkSecurity.AllowedNamespaces != nil {
-			allowedNamespaces = tenant.NetworkSecurity.AllowedNamespaces
-		}
-	} else if tenant.NetworkSecurity.InterNamespaceTraffic == v1.Allow {
-		networkSecurityEnabled = false
-	}
+	network := networkConfig(tenant.NetworkSecurity)
+	networkSpec, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(&network)
 
-	return &unstructured.Unstructured{
+	tenantConfig := &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "baaz.dev/v1",
 			"kind":       "Tenants",
@@ -123,10 +128,7 @@ func makeTenantConfig(
 					"machine": map[string]interface{}{
 						"enabled": false,
 					},
-					"network": map[string]interface{}{
-						"enabled":           networkSecurityEnabled,
-						"allowedNamespaces": allowedNamespaces,
-					},
+					"network": networkSpec,
 				},
 				"config": []map[string]interface{}{
 					{
@@ -137,22 +139,67 @@ func makeTenantConfig(
 			},
 		},
 	}
+	if tenant.Quota != nil {
+		quota, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(tenant.Quota)
+		tenantConfig.Object["spec"].(map[string]interface{})["quota"] = quota
+	}
+	if tenant.Identity != nil {
+		identity, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(tenant.Identity)
+		tenantConfig.Object["spec"].(map[string]interface{})["identity"] = identity
+	}
+	return tenantConfig
+}
+
+// networkConfig returns the network isolation of a tenant created or
+// updated through the api.
+func networkConfig(security v1.NetworkSecurity) v1.NetworkConfig {
+	network := v1.NetworkConfig{
+		Enabled:                     security.InterNamespaceTraffic == v1.Deny,
+		InterNamespaceTraffic:       security.InterNamespaceTraffic,
+		AllowedNamespaces:           security.AllowedNamespaces,
+		IngressControllerNamespaces: security.IngressControllerNamespaces,
+		MonitoringNamespaces:        security.MonitoringNamespaces,
+	}
+	if security.Egress != nil {
+		network.Egress = &v1.EgressConfig{
+			DefaultDeny:  security.Egress.DefaultDeny,
+			DenyDNS:      security.Egress.DenyDNS,
+			AllowedCIDRs: security.Egress.AllowedCIDRs,
+			AllowedFQDNs: security.Egress.AllowedFQDNs,
+		}
+	}
+	return network
 }
 
 func makeApplicationConfig(apps []v1.HTTPApplication, dataplaneName, tenantName, appCRName string, labels map[string]string) *unstructured.Unstructured {
 
 	var allApplications []map[string]interface{}
 	for _, app := range apps {
-		allApplications = append(allApplications, map[string]interface{}{
+		application := map[string]interface{}{
 			"name": app.ApplicationName,
-			"spec": map[string]interface{}{
-				"chartName": app.ChartName,
-				"repoName":  app.RepoName,
-				"repoUrl":   app.RepoURL,
-				"version":   app.Version,
-				"values":    app.Values,
-			},
-		})
+		}
+		if app.Source != nil {
+			source, err := runtime.DefaultUnstructuredConverter.ToUnstructured(app.Source)
+			if err == nil {
+				application["source"] = source
+			}
+		} else {
+			application["spec"] = makeChartSpec(app)
+		}
+		if len(app.DependsOn) > 0 {
+			application["dependsOn"] = app.DependsOn
+		}
+		var readiness []interface{}
+		for i := range app.Readiness {
+			check, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&app.Readiness[i])
+			if err == nil {
+				readiness = append(readiness, check)
+			}
+		}
+		if len(readiness) > 0 {
+			application["readiness"] = readiness
+		}
+		allApplications = append(allApplications, application)
 	}
 
 	return &unstructured.Unstructured{
@@ -172,6 +219,75 @@ func makeApplicationConfig(apps []v1.HTTPApplication, dataplaneName, tenantName,
 	}
 }
 
+func makeBlueprintApplicationConfig(ref v1.BlueprintRef, dataplaneName, tenantName, appCRName string, labels map[string]string) *unstructured.Unstructured {
+	blueprint, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(&ref)
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "baaz.dev/v1",
+			"kind":       "Applications",
+			"metadata": map[string]interface{}{
+				"name":   appCRName,
+				"labels": labels,
+			},
+			"spec": map[string]interface{}{
+				"dataplane": dataplaneName,
+				"tenant":    tenantName,
+				"blueprint": blueprint,
+			},
+		},
+	}
+}
+
+func makeRollout(rollout v1.HTTPRollout) (*unstructured.Unstructured, error) {
+	switch {
+	case rollout.Name == "":
+		return nil, errors.New("rollout name is required")
+	case rollout.Version == "":
+		return nil, errors.New("rollout version is required")
+	case (rollout.App == "") == (rollout.Blueprint == ""):
+		return nil, errors.New("one of app or blueprint is required")
+	}
+
+	spec := v1.RolloutSpec{
+		Selector: v1.RolloutSelector{
+			Customers:  rollout.Customers,
+			Dataplanes: rollout.Dataplanes,
+			SaaSTypes:  rollout.SaaSTypes,
+		},
+		Target: v1.RolloutTarget{
+			App:       rollout.App,
+			Blueprint: rollout.Blueprint,
+			Version:   rollout.Version,
+		},
+		Strategy: v1.RolloutStrategy{
+			CanaryPercent:     rollout.CanaryPercent,
+			BatchSize:         rollout.BatchSize,
+			MaxFailurePercent: rollout.MaxFailurePercent,
+		},
+	}
+	if len(rollout.Labels) > 0 {
+		spec.Selector.Labels = &metav1.LabelSelector{MatchLabels: rollout.Labels}
+	}
+	if rollout.ProgressDeadline != "" {
+		deadline, err := time.ParseDuration(rollout.ProgressDeadline)
+		if err != nil {
+			return nil, fmt.Errorf("invalid progress deadline: %w", err)
+		}
+		spec.Strategy.ProgressDeadline = &metav1.Duration{Duration: deadline}
+	}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&v1.Rollout{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "baaz.dev/v1", Kind: "Rollout"},
+		ObjectMeta: metav1.ObjectMeta{Name: rollout.Name},
+		Spec:       spec,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
 // type TenantsInfraSpec struct {
 // 	Dataplane   string                 `json:"dataplane"`
 // 	TenantSizes map[string]TenantSizes `json:"tenantSizes"`
@@ -185,9 +301,13 @@ func makeTenantsInfra(dataplaneName string, tenantSizes map[string]v1.HTTPTenant
 
 	allTenantSizes := make(map[string]interface{})
 	for tName, tenantSize := range tenantSizes {
-		allTenantSizes[tName] = map[string][]v1.MachineSpec{
+		size := map[string]interface{}{
 			"machinePool": tenantSize.MachineSpec,
 		}
+		if tenantSize.Quota != nil {
+			size["quota"] = tenantSize.Quota
+		}
+		allTenantSizes[tName] = size
 	}
 
 	return &unstructured.Unstructured{
@@ -238,3 +358,83 @@ func createSaToken(
 
 	return nil
 }
+
+// validateValues checks that the valuesObject of each application is a JSON
+// object, makeChartSpec would drop it otherwise.
+func validateValues(apps []v1.HTTPApplication) error {
+	for _, app := range apps {
+		if app.ValuesObject == nil {
+			continue
+		}
+		var valuesObject map[string]interface{}
+		if err := json.Unmarshal(app.ValuesObject.Raw, &valuesObject); err != nil {
+			return fmt.Errorf("valuesObject of application %s: %w", app.ApplicationName, err)
+		}
+	}
+	return nil
+}
+
+// makeChartSpec returns the chart spec of an application created through
+// the api, its valuesObject checked by validateValues.
+func makeChartSpec(app v1.HTTPApplication) map[string]interface{} {
+	spec := map[string]interface{}{
+		"chartName": app.ChartName,
+		"repoName":  app.RepoName,
+		"repoUrl":   app.RepoURL,
+		"version":   app.Version,
+		"values":    app.Values,
+	}
+	if app.ValuesObject != nil {
+		var valuesObject map[string]interface{}
+		if err := json.Unmarshal(app.ValuesObject.Raw, &valuesObject); err == nil {
+			spec["valuesObject"] = valuesObject
+		}
+	}
+	var valuesFrom []interface{}
+	for i := range app.ValuesFrom {
+		ref, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&app.ValuesFrom[i])
+		if err == nil {
+			valuesFrom = append(valuesFrom, ref)
+		}
+	}
+	if len(valuesFrom) > 0 {
+		spec["valuesFrom"] = valuesFrom
+	}
+	if app.Auth != nil {
+		if auth, err := runtime.DefaultUnstructuredConverter.ToUnstructured(app.Auth); err == nil {
+			spec["auth"] = auth
+		}
+	}
+	if app.InsecureSkipTLSVerify {
+		spec["insecureSkipTLSVerify"] = true
+	}
+	return spec
+}
+
+// updateChartSpec returns spec with the fields app sets, the version always.
+func updateChartSpec(spec v1.ChartSpec, app v1.HTTPApplication) v1.ChartSpec {
+	spec.Version = app.Version
+	if app.ChartName != "" {
+		spec.ChartName = app.ChartName
+	}
+	if app.RepoName != "" {
+		spec.RepoName = app.RepoName
+	}
+	if app.RepoURL != "" {
+		spec.RepoUrl = app.RepoURL
+	}
+	if app.Values != nil {
+		spec.Values = app.Values
+	}
+	if app.ValuesObject != nil {
+		spec.ValuesObject = app.ValuesObject
+	}
+	if app.ValuesFrom != nil {
+		spec.ValuesFrom = app.ValuesFrom
+	}
+	if app.Auth != nil {
+		spec.Auth = app.Auth
+	}
+	spec.InsecureSkipTLSVerify = app.InsecureSkipTLSVerify
+	return spec
+}
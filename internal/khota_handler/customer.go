@@ -37,7 +37,7 @@ func ListCustomer(w http.ResponseWriter, req *http.Request) {
 		LabelSelector: "controlplane=baaz",
 	})
 	if err != nil {
-		handleError(w, err, CustomerNamespaceListEmpty, http.StatusInternalServerError)
+		handleError(w, req, err, CustomerNamespaceListEmpty, http.StatusInternalServerError)
 		return
 	}
 
@@ -46,7 +46,7 @@ func ListCustomer(w http.ResponseWriter, req *http.Request) {
 	for _, ns := range nsList.Items {
 		ns, err := client.CoreV1().Namespaces().Get(context.TODO(), ns.Name, metav1.GetOptions{})
 		if err != nil {
-			handleError(w, err, CustomerNamespaceGetFail, http.StatusInternalServerError)
+			handleError(w, req, err, CustomerNamespaceGetFail, http.StatusInternalServerError)
 			return
 		}
 
@@ -65,7 +65,7 @@ func ListCustomer(w http.ResponseWriter, req *http.Request) {
 
 	resp, err := json.Marshal(customerListResponse)
 	if err != nil {
-		handleError(w, err, JsonMarshallError, http.StatusInternalServerError)
+		handleError(w, req, err, JsonMarshallError, http.StatusInternalServerError)
 		return
 	}
 
@@ -79,14 +79,14 @@ func CreateCustomer(w http.ResponseWriter, req *http.Request) {
 
 	body, err := io.ReadAll(io.LimitReader(req.Body, 1048576))
 	if err != nil {
-		handleError(w, err, ServerReqSizeExceed, http.StatusBadRequest)
+		handleError(w, req, err, ServerReqSizeExceed, http.StatusBadRequest)
 		return
 	}
 	defer req.Body.Close()
 
 	var customer v1.Customer
 	if err := json.Unmarshal(body, &customer); err != nil {
-		handleError(w, err, ServerUnmarshallError, http.StatusInternalServerError)
+		handleError(w, req, err, ServerUnmarshallError, http.StatusInternalServerError)
 		return
 	}
 
@@ -112,7 +112,7 @@ func CreateCustomer(w http.ResponseWriter, req *http.Request) {
 			},
 		}, metav1.CreateOptions{})
 		if err != nil {
-			handleError(w, err, CustomerNamespaceCreateFail, http.StatusInternalServerError)
+			handleError(w, req, err, CustomerNamespaceCreateFail, http.StatusInternalServerError)
 			return
 		}
 
@@ -131,16 +131,16 @@ func CreateCustomer(w http.ResponseWriter, req *http.Request) {
 			},
 		)
 		if err := helmBuild.Apply(); err != nil {
-			handleError(w, err, CustomerNamespaceCreateFail, http.StatusInternalServerError)
+			handleError(w, req, err, CustomerNamespaceCreateFail, http.StatusInternalServerError)
 			return
 		}
 
-		handleSuccess(w, CustomerNamespaceSuccess, http.StatusOK)
+		handleSuccess(w, req, CustomerNamespaceSuccess, http.StatusOK)
 		sendEventParseable(customersEventStream, customerCreateSuccessEvent, allLabels, map[string]string{"customer_name": customerName})
 		return
 	}
 
-	handleError(w, err, CustomerNamespaceExists, http.StatusConflict)
+	handleError(w, req, err, CustomerNamespaceExists, http.StatusConflict)
 }
 
 // UpdateCustomer handles updating a customer
@@ -150,14 +150,14 @@ func UpdateCustomer(w http.ResponseWriter, req *http.Request) {
 
 	body, err := io.ReadAll(io.LimitReader(req.Body, 1048576))
 	if err != nil {
-		handleError(w, err, ServerReqSizeExceed, http.StatusBadRequest)
+		handleError(w, req, err, ServerReqSizeExceed, http.StatusBadRequest)
 		return
 	}
 	defer req.Body.Close()
 
 	var customer v1.Customer
 	if err := json.Unmarshal(body, &customer); err != nil {
-		handleError(w, err, ServerUnmarshallError, http.StatusInternalServerError)
+		handleError(w, req, err, ServerUnmarshallError, http.StatusInternalServerError)
 		return
 	}
 
@@ -165,7 +165,7 @@ func UpdateCustomer(w http.ResponseWriter, req *http.Request) {
 	ns, err := client.CoreV1().Namespaces().Get(context.TODO(), customerName, metav1.GetOptions{})
 
 	if apierrors.IsNotFound(err) {
-		handleError(w, err, CustomerNamespaceDoesNotExists, http.StatusNotFound)
+		handleError(w, req, err, CustomerNamespaceDoesNotExists, http.StatusNotFound)
 		return
 	}
 
@@ -173,11 +173,11 @@ func UpdateCustomer(w http.ResponseWriter, req *http.Request) {
 		ns.Labels[key] = val
 	}
 	if _, err := client.CoreV1().Namespaces().Update(context.TODO(), ns, metav1.UpdateOptions{}); err != nil {
-		handleError(w, err, CustomerNamespaceUpdateFail, http.StatusInternalServerError)
+		handleError(w, req, err, CustomerNamespaceUpdateFail, http.StatusInternalServerError)
 		return
 	}
 
-	handleSuccess(w, CustomerNamespaceUpdateSuccess, http.StatusOK)
+	handleSuccess(w, req, CustomerNamespaceUpdateSuccess, http.StatusOK)
 }
 
 // DeleteCustomer handles deleting a customer
@@ -189,28 +189,28 @@ func DeleteCustomer(w http.ResponseWriter, req *http.Request) {
 	err := client.CoreV1().Namespaces().Delete(context.TODO(), customerName, metav1.DeleteOptions{})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			handleError(w, err, CustomerNamespaceDoesNotExists, http.StatusNotFound)
+			handleError(w, req, err, CustomerNamespaceDoesNotExists, http.StatusNotFound)
 		} else {
-			handleError(w, err, CustomerNamespaceDeleteFail, http.StatusInternalServerError)
+			handleError(w, req, err, CustomerNamespaceDeleteFail, http.StatusInternalServerError)
 		}
 		return
 	}
 
-	handleSuccess(w, CustomerNamespaceDeleteSuccess, http.StatusOK)
+	handleSuccess(w, req, CustomerNamespaceDeleteSuccess, http.StatusOK)
 }
 
 // handleError logs and handles errors
-func handleError(w http.ResponseWriter, err error, msg CustomMsg, code int) {
+func handleError(w http.ResponseWriter, req *http.Request, err error, msg CustomMsg, code int) {
 	res := NewResponse(msg, internal_error, err, code)
 	res.SetResponse(&w)
-	res.LogResponse()
+	res.LogResponse(req)
 }
 
 // handleSuccess logs and handles success responses
-func handleSuccess(w http.ResponseWriter, msg CustomMsg, code int) {
+func handleSuccess(w http.ResponseWriter, req *http.Request, msg CustomMsg, code int) {
 	res := NewResponse(msg, success, nil, code)
 	res.SetResponse(&w)
-	res.LogResponse()
+	res.LogResponse(req)
 }
 
 // setLabelPrefix adds a prefix to all labels
@@ -42,14 +42,14 @@ func AddRemoveDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -63,7 +63,7 @@ func AddRemoveDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -71,7 +71,7 @@ func AddRemoveDataPlane(w http.ResponseWriter, req *http.Request) {
 	if getErr != nil {
 		res := NewResponse(CustomerNamespaceGetFail, internal_error, getErr, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -98,7 +98,7 @@ func AddRemoveDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(DataPlaneGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -118,7 +118,7 @@ func AddRemoveDataPlane(w http.ResponseWriter, req *http.Request) {
 		if err != nil {
 			res := NewResponse(DataplanePatchFail, internal_error, patchErr, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 			return
 		}
 
@@ -126,13 +126,13 @@ func AddRemoveDataPlane(w http.ResponseWriter, req *http.Request) {
 		if updateErr != nil {
 			res := NewResponse(CustomerNamespaceUpdateFail, internal_error, getErr, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 			return
 		}
 
 		res := NewResponse(DataplaneAddedSuccess, success, nil, http.StatusOK)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	} else if a.Action == "remove" {
 
@@ -151,7 +151,7 @@ func AddRemoveDataPlane(w http.ResponseWriter, req *http.Request) {
 		if err != nil {
 			res := NewResponse(DataplanePatchFail, internal_error, patchErr, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 			return
 		}
 
@@ -159,13 +159,13 @@ func AddRemoveDataPlane(w http.ResponseWriter, req *http.Request) {
 		if updateErr != nil {
 			res := NewResponse(CustomerNamespaceUpdateFail, internal_error, getErr, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 			return
 		}
 
 		res := NewResponse(DataplaneRemoveSuccess, success, nil, http.StatusOK)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 
 	}
@@ -177,14 +177,14 @@ func CreateDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -193,7 +193,14 @@ func CreateDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err := json.Unmarshal(body, &dp); err != nil {
 		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
+		return
+	}
+
+	if err := validateValues(dp.ApplicationConfig); err != nil {
+		res := NewResponse(ApplicationInvalidValues, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
 		return
 	}
 
@@ -204,13 +211,17 @@ func CreateDataPlane(w http.ResponseWriter, req *http.Request) {
 
 	for _, app := range dp.ApplicationConfig {
 		appConfig = append(appConfig, v1.HTTPApplication{
-			ApplicationName: app.ApplicationName,
-			Namespace:       app.Namespace,
-			ChartName:       app.ChartName,
-			RepoName:        app.RepoName,
-			RepoURL:         app.RepoURL,
-			Version:         app.Version,
-			Values:          app.Values,
+			ApplicationName:       app.ApplicationName,
+			Namespace:             app.Namespace,
+			ChartName:             app.ChartName,
+			RepoName:              app.RepoName,
+			RepoURL:               app.RepoURL,
+			Version:               app.Version,
+			Values:                app.Values,
+			ValuesObject:          app.ValuesObject,
+			ValuesFrom:            app.ValuesFrom,
+			Auth:                  app.Auth,
+			InsecureSkipTLSVerify: app.InsecureSkipTLSVerify,
 		})
 	}
 
@@ -232,6 +243,8 @@ func CreateDataPlane(w http.ResponseWriter, req *http.Request) {
 				SubnetIds:        dp.KubeConfig.EKS.SubnetIds,
 				SecurityGroupIds: dp.KubeConfig.EKS.SecurityGroupIds,
 				Version:          dp.KubeConfig.EKS.Version,
+				Addons:           dp.KubeConfig.EKS.Addons,
+				Provisioner:      dp.KubeConfig.EKS.Provisioner,
 			},
 		},
 		ApplicationConfig: appConfig,
@@ -243,7 +256,7 @@ func CreateDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(DataPlaneCreateFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -254,7 +267,7 @@ func CreateDataPlane(w http.ResponseWriter, req *http.Request) {
 		if err != nil {
 			res := NewResponse(DataPlaneCreateFail, internal_error, err, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 			return
 		}
 	}
@@ -287,7 +300,7 @@ func CreateDataPlane(w http.ResponseWriter, req *http.Request) {
 		if retryErr != nil {
 			res := NewResponse(DataPlaneCreateFail, internal_error, retryErr, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 			return
 		}
 		labels = mergeMaps(labels, map[string]string{
@@ -308,18 +321,18 @@ func CreateDataPlane(w http.ResponseWriter, req *http.Request) {
 
 	dpDeploy := makeAwsEksConfig(dpName, dataplane, labels)
 
-	_, err = dc.Resource(dpGVK).Namespace(dpNamespace).Create(context.TODO(), dpDeploy, metav1.CreateOptions{})
+	_, err = dc.Resource(dpGVK).Namespace(dpNamespace).Create(context.TODO(), withTraceContext(req, dpDeploy), metav1.CreateOptions{})
 	if err != nil {
 		res := NewResponse(DataPlaneCreateFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		sendEventParseable(dataplanesEventStream, dataplaneInitiationFailEvent, nil, map[string]string{"dataplane_name": dpName})
 		return
 	}
 
 	sendEventParseable(dataplanesEventStream, dataplaneInitiationSuccessEvent, labels, map[string]string{"dataplane_name": dpName})
 	res := NewResponse(DataPlaneCreateIntiated, success, nil, http.StatusOK)
-	res.LogResponse()
+	res.LogResponse(req)
 	res.SetResponse(&w)
 
 }
@@ -330,14 +343,14 @@ func UpdateDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -346,7 +359,14 @@ func UpdateDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err := json.Unmarshal(body, &dp); err != nil {
 		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
+		return
+	}
+
+	if err := validateValues(dp.ApplicationConfig); err != nil {
+		res := NewResponse(ApplicationInvalidValues, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
 		return
 	}
 
@@ -359,13 +379,17 @@ func UpdateDataPlane(w http.ResponseWriter, req *http.Request) {
 
 	for _, app := range dp.ApplicationConfig {
 		appConfig = append(appConfig, v1.HTTPApplication{
-			ApplicationName: app.ApplicationName,
-			Namespace:       app.Namespace,
-			ChartName:       app.ChartName,
-			RepoName:        app.RepoName,
-			RepoURL:         app.RepoURL,
-			Version:         app.Version,
-			Values:          app.Values,
+			ApplicationName:       app.ApplicationName,
+			Namespace:             app.Namespace,
+			ChartName:             app.ChartName,
+			RepoName:              app.RepoName,
+			RepoURL:               app.RepoURL,
+			Version:               app.Version,
+			Values:                app.Values,
+			ValuesObject:          app.ValuesObject,
+			ValuesFrom:            app.ValuesFrom,
+			Auth:                  app.Auth,
+			InsecureSkipTLSVerify: app.InsecureSkipTLSVerify,
 		})
 	}
 
@@ -386,6 +410,7 @@ func UpdateDataPlane(w http.ResponseWriter, req *http.Request) {
 				SubnetIds:        dp.KubeConfig.EKS.SubnetIds,
 				SecurityGroupIds: dp.KubeConfig.EKS.SecurityGroupIds,
 				Version:          dp.KubeConfig.EKS.Version,
+				Addons:           dp.KubeConfig.EKS.Addons,
 			},
 		},
 		ApplicationConfig: appConfig,
@@ -405,7 +430,7 @@ func UpdateDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(DataplaneUpdateFail, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -413,32 +438,35 @@ func UpdateDataPlane(w http.ResponseWriter, req *http.Request) {
 	if errCon := runtime.DefaultUnstructuredConverter.FromUnstructured(existingObj.Object, ob); errCon != nil {
 		res := NewResponse(DataplaneUpdateFail, req_error, errCon, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 
 	}
 
-	// for now only k8s is updatable by user
+	// for now only k8s version and addons are updatable by user
 	ob.Spec.CloudInfra.Eks.Version = dataplane.KubeConfig.EKS.Version
+	if dataplane.KubeConfig.EKS.Addons != nil {
+		ob.Spec.CloudInfra.Eks.Addons = dataplane.KubeConfig.EKS.Addons
+	}
 
 	upObj, errCon := runtime.DefaultUnstructuredConverter.ToUnstructured(ob)
 	if errCon != nil {
 		res := NewResponse(DataplaneUpdateFail, req_error, errCon, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
-	_, uperr := dc.Resource(dpGVK).Namespace(dpNamespace).Update(context.TODO(), &unstructured.Unstructured{Object: upObj}, metav1.UpdateOptions{})
+	_, uperr := dc.Resource(dpGVK).Namespace(dpNamespace).Update(context.TODO(), withTraceContext(req, &unstructured.Unstructured{Object: upObj}), metav1.UpdateOptions{})
 	if uperr != nil {
 		res := NewResponse(DataplaneUpdateFail, req_error, uperr, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 	res := NewResponse(DataplaneUpdateFail, success, nil, http.StatusOK)
 	res.SetResponse(&w)
-	res.LogResponse()
+	res.LogResponse(req)
 	sendEventParseable(dataplanesEventStream, dataplaneInitiationSuccessEvent, labels, map[string]string{"dataplane_name": dpName})
 }
 
@@ -460,7 +488,7 @@ func GetDataPlaneStatus(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(DataPlaneGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -481,7 +509,7 @@ func GetDataPlaneStatus(w http.ResponseWriter, req *http.Request) {
 			if err != nil {
 				res := NewResponse(DataPlaneGetFail, string(JsonMarshallError), err, http.StatusInternalServerError)
 				res.SetResponse(&w)
-				res.LogResponse()
+				res.LogResponse(req)
 				return
 			}
 			sendJsonResponse(dpResp, http.StatusOK, &w)
@@ -498,7 +526,7 @@ func ListDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(DataPlaneGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -543,7 +571,7 @@ func DeleteDataPlane(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(DataPlaneGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -553,14 +581,14 @@ func DeleteDataPlane(w http.ResponseWriter, req *http.Request) {
 			if exists {
 				res := NewResponse(DataplaneDeletionFailedCustomerExists, internal_error, err, http.StatusInternalServerError)
 				res.SetResponse(&w)
-				res.LogResponse()
+				res.LogResponse(req)
 				return
 			}
 			err = dc.Resource(dpGVK).Namespace(dpObj.GetNamespace()).Delete(context.TODO(), dpObj.GetName(), metav1.DeleteOptions{})
 			if err != nil {
 				res := NewResponse(DataplaneDeletionFailed, internal_error, err, http.StatusInternalServerError)
 				res.SetResponse(&w)
-				res.LogResponse()
+				res.LogResponse(req)
 				return
 			}
 			res := NewResponse("", string(DataplaneDeletionInitiated), nil, http.StatusOK)
@@ -2,7 +2,8 @@ package khota_handler
 
 import (
 	"github.com/parseablehq/parseable-sdk-go/parseable"
-	klog "k8s.io/klog/v2"
+
+	"github.com/baazhq/baaz/pkg/logging"
 )
 
 type eventStreams string
@@ -133,7 +134,7 @@ func sendEventParseable(stream eventStreams, eventType eventType, labels, tags m
 		)
 		_, err := stream.InsertLogs()
 		if err != nil {
-			klog.Error(err)
+			logging.Logger(logging.API).Error(err, "failed to send event to parseable", "stream", customersEventStream)
 		}
 	case dataplanesEventStream:
 		stream := parseable.NewStreamBuilder(
@@ -144,7 +145,7 @@ func sendEventParseable(stream eventStreams, eventType eventType, labels, tags m
 		)
 		_, err := stream.InsertLogs()
 		if err != nil {
-			klog.Error(err)
+			logging.Logger(logging.API).Error(err, "failed to send event to parseable", "stream", dataplanesEventStream)
 		}
 	case tenantsInfraEventStream:
 		stream := parseable.NewStreamBuilder(
@@ -155,7 +156,7 @@ func sendEventParseable(stream eventStreams, eventType eventType, labels, tags m
 		)
 		_, err := stream.InsertLogs()
 		if err != nil {
-			klog.Error(err)
+			logging.Logger(logging.API).Error(err, "failed to send event to parseable", "stream", tenantsInfraEventStream)
 		}
 	case tenantsEventStream:
 		stream := parseable.NewStreamBuilder(
@@ -166,7 +167,7 @@ func sendEventParseable(stream eventStreams, eventType eventType, labels, tags m
 		)
 		_, err := stream.InsertLogs()
 		if err != nil {
-			klog.Error(err)
+			logging.Logger(logging.API).Error(err, "failed to send event to parseable", "stream", tenantsEventStream)
 		}
 	case applicationsEventStream:
 		stream := parseable.NewStreamBuilder(
@@ -177,7 +178,7 @@ func sendEventParseable(stream eventStreams, eventType eventType, labels, tags m
 		)
 		_, err := stream.InsertLogs()
 		if err != nil {
-			klog.Error(err)
+			logging.Logger(logging.API).Error(err, "failed to send event to parseable", "stream", applicationsEventStream)
 		}
 	}
 
@@ -71,13 +71,44 @@ const (
 
 // Application
 const (
-	ApplicationCreateFail     CustomMsg = "Application creation fail"
-	ApplicationCreateIntiated CustomMsg = "Application creation initiated"
-	ApplicationGetFail        CustomMsg = "Application get fail"
-	ApplicationDeleteFail     CustomMsg = "Application delete fail"
-	ApplicationDeleteIntiated CustomMsg = "Application delete initiated"
-	ApplicationUpdateSuccess  CustomMsg = "Application update success"
-	ApplicationUpdateFail     CustomMsg = "Application update failed"
+	ApplicationCreateFail       CustomMsg = "Application creation fail"
+	ApplicationCreateIntiated   CustomMsg = "Application creation initiated"
+	ApplicationGetFail          CustomMsg = "Application get fail"
+	ApplicationDeleteFail       CustomMsg = "Application delete fail"
+	ApplicationDeleteIntiated   CustomMsg = "Application delete initiated"
+	ApplicationUpdateSuccess    CustomMsg = "Application update success"
+	ApplicationUpdateFail       CustomMsg = "Application update failed"
+	ApplicationRollbackSuccess  CustomMsg = "Application rollback initiated"
+	ApplicationRollbackFail     CustomMsg = "Application rollback failed"
+	ApplicationRevisionNotFound CustomMsg = "Application revision not found"
+	ApplicationHealthFail       CustomMsg = "Application health inspection failed"
+	ApplicationInvalidValues    CustomMsg = "Application valuesObject is not a JSON object"
+	ApplicationGitManaged       CustomMsg = "Application managed by a GitSource"
+)
+
+// Blueprint
+const (
+	BlueprintGetFail           CustomMsg = "Blueprint get fail"
+	BlueprintListFail          CustomMsg = "Blueprint list fail"
+	BlueprintInvalidParameters CustomMsg = "Blueprint parameters invalid"
+	BlueprintNotInstantiated   CustomMsg = "Application is not a blueprint instance"
+)
+
+// Rollout
+const (
+	RolloutInvalid         CustomMsg = "Rollout invalid"
+	RolloutCreateFail      CustomMsg = "Rollout creation fail"
+	RolloutCreateInitiated CustomMsg = "Rollout creation initiated"
+	RolloutGetFail         CustomMsg = "Rollout get fail"
+	RolloutListFail        CustomMsg = "Rollout list fail"
+	RolloutUpdateFail      CustomMsg = "Rollout update failed"
+	RolloutUpdateSuccess   CustomMsg = "Rollout update success"
+)
+
+// Usage
+const (
+	UsageGetFail      CustomMsg = "Usage get fail"
+	UsageInvalidRange CustomMsg = "Usage invalid time range"
 )
 
 // Json
@@ -105,4 +136,8 @@ const (
 // config
 const (
 	ConfigGetFail string = "Config get failed for customer"
+
+	TenantConfigGetFail           CustomMsg = "Config get failed for tenant"
+	TenantIdentityNotReady        CustomMsg = "Tenant service account is not ready"
+	TenantConfigInvalidExpiration CustomMsg = "Invalid expiration, expected a duration between 10m and 24h"
 )
@@ -4,21 +4,34 @@ import (
 	"context"
 	b64 "encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/mux"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
 )
 
 type KubeConfig struct {
 	CurrentContext string `json:"current_context"`
 	Customer       string `json:"customer"`
+	Tenant         string `json:"tenant,omitempty"`
 	Namespace      string `json:"namespace"`
 	ClusterCA      string `json:"cluster_ca"`
 	ClusterServer  string `json:"cluster_server"`
 	UserTokenValue string `json:"user_token_value"`
+	// ExpiresAt is when the token expires, RFC3339. The token of a
+	// customer does not expire.
+	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
 func NewKubeConfig(
@@ -51,11 +64,139 @@ func GetKubeConfig(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(CustomMsg(ConfigGetFail), internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
+		return
+	}
+
+	bytes, _ := json.Marshal(config)
+	sendJsonResponse(bytes, http.StatusOK, &w)
+
+}
+
+const (
+	defaultTokenExpiration = time.Hour
+	// the api server rejects tokens expiring in less than 10 minutes
+	minTokenExpiration = 10 * time.Minute
+	maxTokenExpiration = 24 * time.Hour
+)
+
+// GetTenantKubeConfig returns a kubeconfig of the dataplane of a tenant,
+// authenticated with a short-lived token of the service account of the
+// tenant, so it only reaches the namespace of the tenant.
+func GetTenantKubeConfig(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	customerName := vars["customer_name"]
+	tenantName := vars["tenant_name"]
+
+	expiration := defaultTokenExpiration
+	if value := req.URL.Query().Get("expiration"); value != "" {
+		var err error
+		expiration, err = time.ParseDuration(value)
+		if err != nil || expiration < minTokenExpiration || expiration > maxTokenExpiration {
+			res := NewResponse(TenantConfigInvalidExpiration, req_error, err, http.StatusBadRequest)
+			res.SetResponse(&w)
+			res.LogResponse(req)
+			return
+		}
+	}
+
+	_, dc := getKubeClientset()
+
+	tenantObj, err := dc.Resource(tenantGVK).Namespace(customerName).Get(req.Context(), tenantName, metav1.GetOptions{})
+	if err != nil {
+		code := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			code = http.StatusNotFound
+		}
+		res := NewResponse(TenantGetFail, internal_error, err, code)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	tenant := &v1.Tenants{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(tenantObj.Object, tenant); err != nil {
+		res := NewResponse(TenantGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	if tenant.Status.Identity == nil || tenant.Status.Identity.ServiceAccount == "" {
+		res := NewResponse(TenantIdentityNotReady, req_error, nil, http.StatusConflict)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	dataplane, err := getDataPlane(req.Context(), dc, tenant.Spec.DataplaneName)
+	if err != nil {
+		res := NewResponse(TenantConfigGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	restConfig, err := eks.NewEks(req.Context(), dataplane).GetRestConfig()
+	if err != nil {
+		res := NewResponse(TenantConfigGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		res := NewResponse(TenantConfigGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
 		return
 	}
 
+	token, err := clientset.CoreV1().ServiceAccounts(tenant.Name).CreateToken(
+		req.Context(),
+		tenant.Status.Identity.ServiceAccount,
+		&authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				ExpirationSeconds: Ptr(int64(expiration.Seconds())),
+			},
+		},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		res := NewResponse(TenantConfigGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	config := &KubeConfig{
+		CurrentContext: tenantName + "-context",
+		Customer:       customerName,
+		Tenant:         tenantName,
+		Namespace:      tenant.Name,
+		ClusterCA:      b64.StdEncoding.EncodeToString(restConfig.CAData),
+		ClusterServer:  restConfig.Host,
+		UserTokenValue: token.Status.Token,
+		ExpiresAt:      token.Status.ExpirationTimestamp.UTC().Format(time.RFC3339),
+	}
+
 	bytes, _ := json.Marshal(config)
 	sendJsonResponse(bytes, http.StatusOK, &w)
+}
 
+// getDataPlane returns the dataplane named name, whatever its namespace.
+func getDataPlane(ctx context.Context, dc dynamic.Interface, name string) (*v1.DataPlanes, error) {
+	dpList, err := dc.Resource(dpGVK).Namespace("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, dp := range dpList.Items {
+		if dp.GetName() != name {
+			continue
+		}
+		dataplane := &v1.DataPlanes{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(dp.Object, dataplane); err != nil {
+			return nil, err
+		}
+		return dataplane, nil
+	}
+	return nil, fmt.Errorf("dataplane %s not found", name)
 }
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 
-	klog "k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/baazhq/baaz/pkg/logging"
 )
 
 type Response struct {
@@ -17,13 +19,16 @@ type Response struct {
 func NewResponse(msg CustomMsg, status string, err error, statusCode int) *Response {
 	return &Response{Msg: msg, Status: status, StatusCode: statusCode, Err: err}
 }
-func (res *Response) LogResponse() {
+
+// LogResponse logs res on the request logger, which carries the route,
+// request ID and the objects named in the path.
+func (res *Response) LogResponse(req *http.Request) {
+	log := ctrl.LoggerFrom(req.Context())
 	if res.Err != nil {
-		klog.Errorf("ErrMsg: [%s], Status: [%s], Error: [%s], statusCode [%d]", res.Msg, res.Status, res.Err.Error(), res.StatusCode)
+		logging.Error(log, res.Err, string(res.Msg), "status", res.Status, logging.KeyStatusCode, res.StatusCode)
 	} else {
-		klog.Infof("Msg: [%s], Status: [%s], Error: [%s], statusCode [%d]", res.Msg, res.Status, "", res.StatusCode)
+		log.Info(string(res.Msg), "status", res.Status, logging.KeyStatusCode, res.StatusCode)
 	}
-
 }
 func (res *Response) SetResponse(w *http.ResponseWriter) {
 	(*w).Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -33,7 +38,7 @@ func (res *Response) SetResponse(w *http.ResponseWriter) {
 func (res *Response) SetMsgResponse(w *http.ResponseWriter) {
 	jsonData, err := json.Marshal(res.Msg)
 	if err != nil {
-		klog.Errorf("ErrMsg: Unable to parse response Message json [%s], Error: [%s]", res.Msg, err.Error())
+		logging.Logger(logging.API).Error(err, "unable to marshal response message", "msg", res.Msg)
 		res.StatusCode = http.StatusInternalServerError
 	}
 	(*w).Header().Set("Content-Type", "application/json; charset=UTF-8")
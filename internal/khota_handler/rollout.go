@@ -0,0 +1,176 @@
+package khota_handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+var rolloutGVK = schema.GroupVersionResource{
+	Group:    "baaz.dev",
+	Version:  "v1",
+	Resource: "rollouts",
+}
+
+type rolloutResp struct {
+	Name      string           `json:"name"`
+	App       string           `json:"app,omitempty"`
+	Blueprint string           `json:"blueprint,omitempty"`
+	Version   string           `json:"version"`
+	Paused    bool             `json:"paused"`
+	Status    v1.RolloutStatus `json:"status"`
+}
+
+// CreateRollout starts rolling a version out to the applications selected.
+func CreateRollout(w http.ResponseWriter, req *http.Request) {
+	var rollout v1.HTTPRollout
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, 1048576))
+	if err != nil {
+		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	if err := req.Body.Close(); err != nil {
+		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	if err := json.Unmarshal(body, &rollout); err != nil {
+		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	rolloutObj, err := makeRollout(rollout)
+	if err != nil {
+		res := NewResponse(RolloutInvalid, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	_, dc := getKubeClientset()
+
+	_, err = dc.Resource(rolloutGVK).Create(context.TODO(), withTraceContext(req, rolloutObj), metav1.CreateOptions{})
+	if err != nil {
+		res := NewResponse(RolloutCreateFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	res := NewResponse(RolloutCreateInitiated, success, nil, http.StatusOK)
+	res.SetResponse(&w)
+}
+
+// ListRollouts lists the rollouts with their progress.
+func ListRollouts(w http.ResponseWriter, req *http.Request) {
+	_, dc := getKubeClientset()
+
+	rollouts, err := dc.Resource(rolloutGVK).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		res := NewResponse(RolloutListFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	var rolloutsResp []rolloutResp
+	for _, item := range rollouts.Items {
+		rollout := &v1.Rollout{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, rollout); err != nil {
+			continue
+		}
+		resp := makeRolloutResp(rollout)
+		// the progress of each application is reported by GetRollout
+		resp.Status.Targets = nil
+		rolloutsResp = append(rolloutsResp, resp)
+	}
+
+	bytes, _ := json.Marshal(rolloutsResp)
+	sendJsonResponse(bytes, http.StatusOK, &w)
+}
+
+// GetRollout reports the progress of a rollout for each application.
+func GetRollout(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	rolloutName := vars["rollout_name"]
+
+	_, dc := getKubeClientset()
+
+	rolloutObj, err := dc.Resource(rolloutGVK).Get(context.TODO(), rolloutName, metav1.GetOptions{})
+	if err != nil {
+		res := NewResponse(RolloutGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	rollout := &v1.Rollout{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rolloutObj.Object, rollout); err != nil {
+		res := NewResponse(RolloutGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	bytes, _ := json.Marshal(makeRolloutResp(rollout))
+	sendJsonResponse(bytes, http.StatusOK, &w)
+}
+
+// PauseRollout stops a rollout from updating more applications.
+func PauseRollout(w http.ResponseWriter, req *http.Request) {
+	patchRolloutPaused(w, req, true)
+}
+
+// ResumeRollout resumes a paused rollout. A rollout paused by failures stays
+// paused until they are fixed, or its failure threshold raised.
+func ResumeRollout(w http.ResponseWriter, req *http.Request) {
+	patchRolloutPaused(w, req, false)
+}
+
+func patchRolloutPaused(w http.ResponseWriter, req *http.Request, paused bool) {
+	vars := mux.Vars(req)
+	rolloutName := vars["rollout_name"]
+
+	_, dc := getKubeClientset()
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"paused":%t}}`, paused))
+	_, err := dc.Resource(rolloutGVK).Patch(context.TODO(), rolloutName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		res := NewResponse(RolloutUpdateFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	res := NewResponse(RolloutUpdateSuccess, success, nil, http.StatusOK)
+	res.SetResponse(&w)
+	res.LogResponse(req)
+}
+
+func makeRolloutResp(rollout *v1.Rollout) rolloutResp {
+	return rolloutResp{
+		Name:      rollout.Name,
+		App:       rollout.Spec.Target.App,
+		Blueprint: rollout.Spec.Target.Blueprint,
+		Version:   rollout.Spec.Target.Version,
+		Paused:    rollout.Spec.Paused,
+		Status:    rollout.Status,
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metrics"
+	"github.com/baazhq/baaz/pkg/tracing"
 )
 
 // Route object
@@ -24,7 +28,9 @@ func NewRouter() *mux.Router {
 	for _, route := range routes {
 
 		var handler http.Handler
-		handler = route.HandlerFunc
+		handler = logging.InstrumentHandler(route.Name, route.HandlerFunc)
+		handler = tracing.InstrumentHandler(route.Name, route.Pattern, handler)
+		handler = metrics.InstrumentHandler(route.Name, handler)
 
 		router.
 			Methods(route.Method).
@@ -224,12 +230,101 @@ var routes = Routes{
 		"/api/v1/customer/{customer_name}/dataplane/{dataplane_name}/application/{application_name}",
 		DeleteApplicationStatus,
 	},
+	Route{
+		"GET APPLICATION",
+		"GET",
+		"/api/v1/customer/{customer_name}/application/{application_name}",
+		GetApplication,
+	},
 	Route{
 		"UPDATE APPLICATION",
 		"PUT",
 		"/api/v1/customer/{customer_name}/application/{application_name}",
 		UpdateApplication,
 	},
+	Route{
+		"ROLLBACK APPLICATION",
+		"POST",
+		"/api/v1/customer/{customer_name}/application/{application_name}/rollback",
+		RollbackApplication,
+	},
+	// -------------------------------------- BLUEPRINT ROUTES ---------------------------------------//
+	Route{
+		"LIST BLUEPRINTS",
+		"GET",
+		"/api/v1/blueprint",
+		ListBlueprints,
+	},
+	Route{
+		"CREATE BLUEPRINT APPLICATION",
+		"POST",
+		"/api/v1/customer/{customer_name}/tenant/{tenant_name}/blueprint",
+		CreateBlueprintApplication,
+	},
+	Route{
+		"UPDATE BLUEPRINT APPLICATION",
+		"PUT",
+		"/api/v1/customer/{customer_name}/application/{application_name}/blueprint",
+		UpdateBlueprintApplication,
+	},
+	// -------------------------------------- ROLLOUT ROUTES ---------------------------------------//
+	// Request:
+	// {
+	// 	"name": "product-2-0-0",
+	// 	"saas_types": ["shared"],
+	// 	"app": "product",
+	// 	"version": "2.0.0",
+	// 	"canary_percent": 5,
+	// 	"batch_size": 20,
+	// 	"max_failure_percent": 10
+	// }
+	Route{
+		"CREATE ROLLOUT",
+		"POST",
+		"/api/v1/rollout",
+		CreateRollout,
+	},
+	Route{
+		"LIST ROLLOUTS",
+		"GET",
+		"/api/v1/rollout",
+		ListRollouts,
+	},
+	Route{
+		"GET ROLLOUT",
+		"GET",
+		"/api/v1/rollout/{rollout_name}",
+		GetRollout,
+	},
+	Route{
+		"PAUSE ROLLOUT",
+		"PUT",
+		"/api/v1/rollout/{rollout_name}/pause",
+		PauseRollout,
+	},
+	Route{
+		"RESUME ROLLOUT",
+		"PUT",
+		"/api/v1/rollout/{rollout_name}/resume",
+		ResumeRollout,
+	},
+	// -------------------------------------- USAGE ROUTES ---------------------------------------//
+	// Query params from & to accept a date (2024-05-01) or a RFC3339 timestamp,
+	// defaults to the current month.
+	Route{
+		"GET CUSTOMER USAGE",
+		"GET",
+		"/api/v1/customer/{customer_name}/usage",
+		GetUsage,
+	},
+	// Get a short-lived kubeconfig scoped to the namespace of a tenant. Query
+	// param expiration accepts a duration (30m), defaults to 1h.
+	Route{
+		"GET KUBECONFIG FOR TENANT",
+		"GET",
+		"/api/v1/customer/{customer_name}/tenant/{tenant_name}/config",
+		GetTenantKubeConfig,
+	},
 	// Get Kubeconfig for a Private SaaS customer
 	Route{
 		"GET KUBECONFIG FOR PRIVATE SAAS CUSTOMER",
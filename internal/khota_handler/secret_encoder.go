@@ -4,9 +4,11 @@ package khota_handler
 
 import (
 	"crypto/rand"
-	uuid "github.com/hashicorp/go-uuid"
 	"io"
-	klog "k8s.io/klog/v2"
+
+	uuid "github.com/hashicorp/go-uuid"
+
+	"github.com/baazhq/baaz/pkg/logging"
 )
 
 const (
@@ -18,8 +20,9 @@ const (
 // Resulting entropy is ~5.95 bits/character.
 func Base62Random(length int) (string, error) {
 	info, err := RandomWithReader(length, rand.Reader)
-	klog.Info(info)
-	klog.Errorf("ErrMsg: Base62 encoder not able to generate UUID: [%s]", err)
+	if err != nil {
+		logging.Logger(logging.API).Error(err, "base62 encoder not able to generate uuid")
+	}
 	return info, err
 }
 
@@ -35,7 +38,7 @@ func RandomWithReader(length int, reader io.Reader) (string, error) {
 	for {
 		buf, err := uuid.GenerateRandomBytesWithReader(batchSize, reader)
 		if err != nil {
-			klog.Errorf("ErrMsg: Base62 encoder not able to generate UUID: [%s]", err)
+			logging.Logger(logging.API).Error(err, "base62 encoder not able to generate uuid")
 		}
 
 		for _, b := range buf {
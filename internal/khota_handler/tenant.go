@@ -33,14 +33,14 @@ func CreateTenant(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -49,7 +49,7 @@ func CreateTenant(w http.ResponseWriter, req *http.Request) {
 	if err := json.Unmarshal(body, &tenant); err != nil {
 		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -58,10 +58,9 @@ func CreateTenant(w http.ResponseWriter, req *http.Request) {
 			Name: tenant.Application.Name,
 			Size: tenant.Application.Size,
 		},
-		NetworkSecurity: v1.NetworkSecurity{
-			InterNamespaceTraffic: tenant.NetworkSecurity.InterNamespaceTraffic,
-			AllowedNamespaces:     tenant.NetworkSecurity.AllowedNamespaces,
-		},
+		NetworkSecurity: tenant.NetworkSecurity,
+		Quota:           tenant.Quota,
+		Identity:        tenant.Identity,
 	}
 
 	kc, dc := getKubeClientset()
@@ -70,7 +69,7 @@ func CreateTenant(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(CustomerNamespaceGetFail, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -85,7 +84,7 @@ func CreateTenant(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(DataPlaneListFail, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -108,14 +107,14 @@ func CreateTenant(w http.ResponseWriter, req *http.Request) {
 			if phase != string(v1.ActiveD) {
 				res := NewResponse(TenantInfraCreateFailDataplaneNotActive, req_error, nil, http.StatusInternalServerError)
 				res.SetResponse(&w)
-				res.LogResponse()
+				res.LogResponse(req)
 				return
 			}
 
 			if !checkValueInMap(customerName, dp.GetLabels()) {
 				res := NewResponse(CustomerNotExistInDataplane, req_error, nil, http.StatusInternalServerError)
 				res.SetResponse(&w)
-				res.LogResponse()
+				res.LogResponse(req)
 				return
 			}
 
@@ -143,16 +142,16 @@ func CreateTenant(w http.ResponseWriter, req *http.Request) {
 	if patchErr != nil {
 		res := NewResponse(DataplanePatchFail, internal_error, patchErr, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 	tenantDeploy := makeTenantConfig(tenantName, tenantNew, customer.GetLabels()["dataplane"], tenantLabels)
 
-	_, err = dc.Resource(tenantGVK).Namespace(customerName).Create(context.TODO(), tenantDeploy, metav1.CreateOptions{})
+	_, err = dc.Resource(tenantGVK).Namespace(customerName).Create(context.TODO(), withTraceContext(req, tenantDeploy), metav1.CreateOptions{})
 	if err != nil {
 		res := NewResponse(TenantCreateFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		sendEventParseable(tenantsEventStream, tenantsCreationFailEvent, tenantLabels, map[string]string{"tenant_name": tenantName})
 		return
 	}
@@ -169,6 +168,13 @@ type tenantListResp struct {
 	DataplaneName string `json:"dataplane"`
 	Application   string `json:"application"`
 	Size          string `json:"size"`
+	// Migration is the phase of the move of the tenant to its size.
+	Migration string `json:"migration,omitempty"`
+}
+
+func migrationPhase(tenant *unstructured.Unstructured) string {
+	phase, _, _ := unstructured.NestedString(tenant.Object, "status", "migrations", tenant.GetLabels()["application"], "phase")
+	return phase
 }
 
 func GetAllTenantInCustomer(w http.ResponseWriter, req *http.Request) {
@@ -182,7 +188,7 @@ func GetAllTenantInCustomer(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(TenantListFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -194,6 +200,7 @@ func GetAllTenantInCustomer(w http.ResponseWriter, req *http.Request) {
 			DataplaneName: tenant.GetLabels()["dataplane"],
 			Size:          tenant.GetLabels()["size"],
 			Application:   tenant.GetLabels()["application"],
+			Migration:     migrationPhase(&tenant),
 		}
 		tenantResp = append(tenantResp, newTenantResp)
 	}
@@ -214,7 +221,7 @@ func DeleteTenant(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(CustomerNamespaceGetFail, req_error, err, http.StatusNotFound)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -223,11 +230,11 @@ func DeleteTenant(w http.ResponseWriter, req *http.Request) {
 		if apierrors.IsNotFound(err) {
 			res := NewResponse(TenantDeleteFail, req_error, err, http.StatusNotFound)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 		} else {
 			res := NewResponse(TenantDeleteFail, req_error, err, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 		}
 		return
 	}
@@ -245,14 +252,14 @@ func UpdateTenant(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -261,7 +268,7 @@ func UpdateTenant(w http.ResponseWriter, req *http.Request) {
 	if err := json.Unmarshal(body, &tenant); err != nil {
 		res := NewResponse(ServerUnmarshallError, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -271,7 +278,7 @@ func UpdateTenant(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(TenantUpdateFail, resource_not_found, err, http.StatusNotFound)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -279,7 +286,7 @@ func UpdateTenant(w http.ResponseWriter, req *http.Request) {
 	if errCon := runtime.DefaultUnstructuredConverter.FromUnstructured(ob.Object, curTenant); errCon != nil {
 		res := NewResponse(TenantUpdateFail, req_error, errCon, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 
 	}
@@ -291,20 +298,33 @@ func UpdateTenant(w http.ResponseWriter, req *http.Request) {
 
 		}
 	}
+	if updatedTenant.Labels["application"] == tenant.Application.Name {
+		updatedTenant.Labels["size"] = tenant.Application.Size
+	}
+	// the network isolation is only replaced when the update carries one
+	if tenant.NetworkSecurity.InterNamespaceTraffic != "" || tenant.NetworkSecurity.Egress != nil {
+		updatedTenant.Spec.Isolation.Network = networkConfig(tenant.NetworkSecurity)
+	}
+	if tenant.Quota != nil {
+		updatedTenant.Spec.Quota = tenant.Quota
+	}
+	if tenant.Identity != nil {
+		updatedTenant.Spec.Identity = tenant.Identity
+	}
 
 	tenantUns, err := runtime.DefaultUnstructuredConverter.ToUnstructured(updatedTenant)
 	if err != nil {
 		res := NewResponse(TenantUpdateFail, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
-	_, err = dc.Resource(tenantGVK).Namespace(customerName).Update(context.TODO(), &unstructured.Unstructured{Object: tenantUns}, metav1.UpdateOptions{})
+	_, err = dc.Resource(tenantGVK).Namespace(customerName).Update(context.TODO(), withTraceContext(req, &unstructured.Unstructured{Object: tenantUns}), metav1.UpdateOptions{})
 	if err != nil {
 		res := NewResponse(TenantCreateFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -325,11 +345,11 @@ func GetTenantInCustomer(w http.ResponseWriter, req *http.Request) {
 		if apierrors.IsNotFound(err) {
 			res := NewResponse(TenantGetFail, internal_error, err, http.StatusNotFound)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 		} else {
 			res := NewResponse(TenantGetFail, internal_error, err, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 		}
 		return
 	}
@@ -340,13 +360,14 @@ func GetTenantInCustomer(w http.ResponseWriter, req *http.Request) {
 		DataplaneName: tenant.GetLabels()["dataplane"],
 		Size:          tenant.GetLabels()["size"],
 		Application:   tenant.GetLabels()["application"],
+		Migration:     migrationPhase(tenant),
 	}
 
 	bytes, err := json.Marshal(tenantResp)
 	if err != nil {
 		res := NewResponse(TenantGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 	}
 	sendJsonResponse(bytes, http.StatusOK, &w)
 }
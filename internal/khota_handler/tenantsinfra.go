@@ -32,14 +32,14 @@ func CreateTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -48,7 +48,7 @@ func CreateTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err := json.Unmarshal(body, &tenantsInfra); err != nil {
 		res := NewResponse(JsonMarshallError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -58,7 +58,7 @@ func CreateTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(DataPlaneListFail, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -86,7 +86,7 @@ func CreateTenantInfra(w http.ResponseWriter, req *http.Request) {
 			if phase != string(v1.ActiveD) {
 				res := NewResponse(TenantInfraCreateFailDataplaneNotActive, req_error, nil, http.StatusInternalServerError)
 				res.SetResponse(&w)
-				res.LogResponse()
+				res.LogResponse(req)
 				return
 			}
 		}
@@ -94,18 +94,18 @@ func CreateTenantInfra(w http.ResponseWriter, req *http.Request) {
 
 	infra := makeTenantsInfra(dataplaneName, tenantsInfra, labels)
 
-	_, err = dc.Resource(tenantInfraGVK).Namespace(namespace).Create(context.TODO(), infra, metav1.CreateOptions{})
+	_, err = dc.Resource(tenantInfraGVK).Namespace(namespace).Create(context.TODO(), withTraceContext(req, infra), metav1.CreateOptions{})
 	if err != nil {
 		res := NewResponse(TenantsInfraCreateFail, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		sendEventParseable(tenantsInfraEventStream, tenantsInfraInitiationFailEvent, labels, map[string]string{"tenant_name": infra.GetName()})
 		return
 	}
 
 	res := NewResponse(TenantsInfraCreateInitiated, success, nil, http.StatusOK)
 	res.SetResponse(&w)
-	res.LogResponse()
+	res.LogResponse(req)
 	sendEventParseable(tenantsInfraEventStream, tenantsInfraInitiationSuccessEvent, labels, map[string]string{"tenant_name": infra.GetName()})
 
 }
@@ -121,7 +121,7 @@ func ListTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(TenantsInfraGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -172,7 +172,7 @@ func DeleteTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(TenantsInfraListFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -182,12 +182,12 @@ func DeleteTenantInfra(w http.ResponseWriter, req *http.Request) {
 			if err != nil {
 				res := NewResponse(TenantsInfraDeleteFail, internal_error, err, http.StatusInternalServerError)
 				res.SetResponse(&w)
-				res.LogResponse()
+				res.LogResponse(req)
 				return
 			}
 			res := NewResponse(TenantsInfraDeleteInitiated, success, nil, http.StatusOK)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 			sendEventParseable(tenantsInfraEventStream, tenantsInfraInitiationSuccessEvent, tf.GetLabels(), map[string]string{"tenant_name": tf.GetName()})
 		}
 	}
@@ -204,14 +204,14 @@ func UpdateTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(ServerReqSizeExceed, req_error, err, http.StatusBadRequest)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
 	if err := req.Body.Close(); err != nil {
 		res := NewResponse(ServerBodyCloseError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -220,7 +220,7 @@ func UpdateTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err := json.Unmarshal(body, &tenantsInfra); err != nil {
 		res := NewResponse(JsonMarshallError, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -230,7 +230,7 @@ func UpdateTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(TenantInfraUpdateFail, req_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -251,7 +251,7 @@ func UpdateTenantInfra(w http.ResponseWriter, req *http.Request) {
 			if phase != string(v1.ActiveD) {
 				res := NewResponse(TenantInfraUpdateFailDataplaneNotActive, req_error, nil, http.StatusInternalServerError)
 				res.SetResponse(&w)
-				res.LogResponse()
+				res.LogResponse(req)
 				return
 			}
 		}
@@ -262,11 +262,11 @@ func UpdateTenantInfra(w http.ResponseWriter, req *http.Request) {
 		if apierrors.IsNotFound(err) {
 			res := NewResponse(TenantInfraUpdateFail, internal_error, err, http.StatusNotFound)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 		} else {
 			res := NewResponse(TenantInfraUpdateFail, internal_error, err, http.StatusInternalServerError)
 			res.SetResponse(&w)
-			res.LogResponse()
+			res.LogResponse(req)
 		}
 		return
 	}
@@ -274,7 +274,7 @@ func UpdateTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if errCon := runtime.DefaultUnstructuredConverter.FromUnstructured(existingObj.Object, ob); errCon != nil {
 		res := NewResponse(TenantInfraUpdateFail, req_error, errCon, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
@@ -288,20 +288,20 @@ func UpdateTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if errCon != nil {
 		res := NewResponse(TenantInfraUpdateFail, req_error, errCon, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
-	_, uperr := dc.Resource(tenantInfraGVK).Namespace(namespace).Update(context.TODO(), &unstructured.Unstructured{Object: upObj}, metav1.UpdateOptions{})
+	_, uperr := dc.Resource(tenantInfraGVK).Namespace(namespace).Update(context.TODO(), withTraceContext(req, &unstructured.Unstructured{Object: upObj}), metav1.UpdateOptions{})
 	if uperr != nil {
 		res := NewResponse(TenantInfraUpdateFail, req_error, uperr, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 	res := NewResponse(TenantInfraUpdateSuccess, success, nil, http.StatusOK)
 	res.SetResponse(&w)
-	res.LogResponse()
+	res.LogResponse(req)
 }
 
 func GetTenantInfra(w http.ResponseWriter, req *http.Request) {
@@ -316,7 +316,7 @@ func GetTenantInfra(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		res := NewResponse(TenantsInfraGetFail, internal_error, err, http.StatusInternalServerError)
 		res.SetResponse(&w)
-		res.LogResponse()
+		res.LogResponse(req)
 		return
 	}
 
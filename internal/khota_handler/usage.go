@@ -0,0 +1,112 @@
+package khota_handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/baazhq/baaz/pkg/metering"
+)
+
+type usageResp struct {
+	Customer string                 `json:"customer"`
+	From     time.Time              `json:"from"`
+	To       time.Time              `json:"to"`
+	Currency string                 `json:"currency"`
+	Tenants  []metering.TenantUsage `json:"tenants"`
+}
+
+// GetUsage returns the metered usage and cost of every tenant of a customer
+// between the from and to query params. It defaults to the current month.
+func GetUsage(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	customerName := vars["customer_name"]
+
+	now := time.Now().UTC()
+	from, err := parseUsageTime(req.URL.Query().Get("from"), time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		res := NewResponse(UsageInvalidRange, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	to, err := parseUsageTime(req.URL.Query().Get("to"), now)
+	if err != nil {
+		res := NewResponse(UsageInvalidRange, req_error, err, http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	if !from.Before(to) {
+		res := NewResponse(UsageInvalidRange, req_error, fmt.Errorf("from %s is not before to %s", from, to), http.StatusBadRequest)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	priceTable, err := metering.LoadPriceTable(os.Getenv("BAAZ_PRICE_TABLE"))
+	if err != nil {
+		res := NewResponse(UsageGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	kc, _ := getKubeClientset()
+
+	cmList, err := kc.CoreV1().ConfigMaps(customerName).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: metering.UsageLabelKey + "=true",
+	})
+	if err != nil {
+		res := NewResponse(UsageGetFail, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+
+	var records []map[string]metering.TenantRecord
+	for i := range cmList.Items {
+		dayRecords, err := metering.DecodeConfigMap(&cmList.Items[i])
+		if err != nil {
+			res := NewResponse(UsageGetFail, internal_error, err, http.StatusInternalServerError)
+			res.SetResponse(&w)
+			res.LogResponse(req)
+			return
+		}
+		records = append(records, dayRecords)
+	}
+
+	resp := usageResp{
+		Customer: customerName,
+		From:     from,
+		To:       to,
+		Currency: priceTable.Currency,
+		Tenants:  metering.Summarize(records, from, to),
+	}
+
+	bytes, err := json.Marshal(resp)
+	if err != nil {
+		res := NewResponse(JsonMarshallError, internal_error, err, http.StatusInternalServerError)
+		res.SetResponse(&w)
+		res.LogResponse(req)
+		return
+	}
+	sendJsonResponse(bytes, http.StatusOK, &w)
+}
+
+// parseUsageTime accepts a date (2006-01-02) or a RFC3339 timestamp.
+func parseUsageTime(value string, defaultTime time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultTime, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -5,17 +5,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
 	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/tracing"
 )
 
 func getKubeClientset() (*kubernetes.Clientset, dynamic.Interface) {
@@ -180,3 +183,10 @@ func JsonWrap(data string) ([]byte, error) {
 	}
 	return bytes, nil
 }
+
+// withTraceContext stores the trace context of req in the annotations of
+// obj, so reconciles of obj continue the trace of the request.
+func withTraceContext(req *http.Request, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	tracing.InjectObject(req.Context(), obj)
+	return obj
+}
@@ -0,0 +1,374 @@
+package metering_controller
+
+import (
+	"context"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/karpenter"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metering"
+)
+
+const (
+	nodegroupLabelKey = "eks.amazonaws.com/nodegroup"
+	dataplaneTypeKey  = "dataplane_type"
+	gib               = 1024 * 1024 * 1024
+)
+
+// UsageSampler periodically samples node hours and pod requests of every
+// tenant and stores them as hourly usage records in the customer namespace.
+type UsageSampler struct {
+	client.Client
+	Interval time.Duration
+	// Retention is the time the usage configmaps are kept, the older ones
+	// are pruned.
+	Retention  time.Duration
+	PriceTable metering.PriceTable
+}
+
+func NewUsageSampler(mgr ctrl.Manager) (*UsageSampler, error) {
+	priceTable, err := metering.LoadPriceTable(os.Getenv("BAAZ_PRICE_TABLE"))
+	if err != nil {
+		return nil, err
+	}
+
+	interval := 5 * time.Minute
+	if val, exists := os.LookupEnv("METERING_INTERVAL"); exists {
+		interval, err = time.ParseDuration(val)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	retention := 90 * 24 * time.Hour
+	if val, exists := os.LookupEnv("METERING_RETENTION"); exists {
+		retention, err = time.ParseDuration(val)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &UsageSampler{
+		Client:     mgr.GetClient(),
+		Interval:   interval,
+		Retention:  retention,
+		PriceTable: priceTable,
+	}, nil
+}
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+
+// Start implements manager.Runnable
+func (s *UsageSampler) Start(ctx context.Context) error {
+	ctx = ctrl.LoggerInto(ctx, logging.Logger(logging.Metering))
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case t := <-ticker.C:
+			if err := s.sample(ctx, t); err != nil {
+				ctrl.LoggerFrom(ctx).Error(err, "failed to sample usage")
+			}
+			if err := s.prune(ctx, t); err != nil {
+				ctrl.LoggerFrom(ctx).Error(err, "failed to prune usage")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only one replica records usage.
+func (s *UsageSampler) NeedLeaderElection() bool {
+	return true
+}
+
+// machine describes the nodes of a tenant nodegroup.
+type machine struct {
+	size         string
+	instanceType string
+	machineType  v1.MachineType
+}
+
+// tenantSample is the usage of a tenant measured in a single sample.
+type tenantSample struct {
+	dataplane string
+	billing   metering.BillingMode
+	usage     metering.Usage
+}
+
+func (s *UsageSampler) sample(ctx context.Context, t time.Time) error {
+	dpList := &v1.DataPlanesList{}
+	if err := s.List(ctx, dpList); err != nil {
+		return err
+	}
+	tiList := &v1.TenantsInfraList{}
+	if err := s.List(ctx, tiList); err != nil {
+		return err
+	}
+	tenantList := &v1.TenantsList{}
+	if err := s.List(ctx, tenantList); err != nil {
+		return err
+	}
+
+	// samples[customer namespace][tenant]
+	samples := make(map[string]map[string]tenantSample)
+
+	for i := range dpList.Items {
+		dp := &dpList.Items[i]
+		if dp.Status.Phase != v1.ActiveD {
+			continue
+		}
+
+		machines := make(map[string]machine)
+		for _, ti := range tiList.Items {
+			if ti.Spec.Dataplane != dp.Name {
+				continue
+			}
+			for size, tenantSize := range ti.Spec.TenantSizes {
+				for _, ms := range tenantSize.MachineSpec {
+					ng, dedicated := eks.MakeTenantNodegroupNames(size, ms)
+					if dp.UsesKarpenter() {
+						ng = eks.MakeTenantMachinePoolName(size, ms)
+					}
+					machines[ng] = machine{size: size, instanceType: ms.Size, machineType: ms.Type}
+					// the dedicated nodegroup of a low priority machine is on-demand
+					machines[dedicated] = machine{size: size, instanceType: ms.Size, machineType: v1.MachineTypeDefaultPriority}
+				}
+			}
+		}
+
+		var tenants []v1.Tenants
+		tenantsPerSize := make(map[string]int)
+		for _, tenant := range tenantList.Items {
+			if tenant.Spec.DataplaneName != dp.Name {
+				continue
+			}
+			tenants = append(tenants, tenant)
+			for _, size := range tenantSizes(&tenant) {
+				tenantsPerSize[size]++
+			}
+		}
+		if len(tenants) == 0 {
+			continue
+		}
+
+		dpCtx, log := logging.WithValues(ctx, logging.KeyCustomer, dp.Namespace, logging.KeyDataplane, dp.Name)
+		nodes, pods, err := listNodesAndPods(dpCtx, dp)
+		if err != nil {
+			// one unreachable dataplane must not stop metering of the others
+			logging.Error(log, err, "failed to sample usage of dataplane")
+			continue
+		}
+
+		nodesByName := make(map[string]*corev1.Node)
+		nodesPerNodegroup := make(map[string]int)
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			nodesByName[node.Name] = node
+			nodesPerNodegroup[machinePoolOf(node)]++
+		}
+
+		shared := dp.GetLabels()[dataplaneTypeKey] == string(v1.SharedSaaS)
+		mode := metering.BillingNodeHours
+		if shared {
+			mode = metering.BillingRequests
+		}
+		hours := s.Interval.Hours()
+
+		for _, tenant := range tenants {
+			sizes := tenantSizes(&tenant)
+			usage := metering.Usage{}
+
+			for _, pod := range pods.Items {
+				if pod.Namespace != tenant.Name {
+					continue
+				}
+				node, found := nodesByName[pod.Spec.NodeName]
+				if !found {
+					continue
+				}
+				cpu, memory := podRequests(&pod)
+				usage.CPUCoreHours += cpu * hours
+				usage.MemoryGiBHours += memory / gib * hours
+
+				if !shared {
+					continue
+				}
+				m, found := machines[machinePoolOf(node)]
+				if !found {
+					continue
+				}
+				// karpenter falls back to on-demand nodes in low priority pools
+				if node.Labels[karpenter.CapacityTypeLabel] == karpenter.CapacityTypeOnDemand {
+					m.machineType = v1.MachineTypeDefaultPriority
+				}
+				price, err := s.PriceTable.HourlyPrice(m.instanceType, m.machineType)
+				if err != nil {
+					log.Info("usage of tenant not priced", logging.KeyTenant, tenant.Name, "reason", err.Error())
+					continue
+				}
+				share := metering.SharedShare(
+					node.Status.Allocatable.Cpu().AsApproximateFloat64(),
+					node.Status.Allocatable.Memory().AsApproximateFloat64(),
+					cpu, memory,
+				)
+				usage.Cost += price * share * hours
+			}
+
+			if !shared {
+				for ng, m := range machines {
+					if !contains(sizes, m.size) || nodesPerNodegroup[ng] == 0 {
+						continue
+					}
+					// tenants of the same size share the nodegroup
+					nodeHours := float64(nodesPerNodegroup[ng]) * hours / float64(tenantsPerSize[m.size])
+					usage.NodeHours += nodeHours
+					price, err := s.PriceTable.HourlyPrice(m.instanceType, m.machineType)
+					if err != nil {
+						log.Info("usage of tenant not priced", logging.KeyTenant, tenant.Name, "reason", err.Error())
+						continue
+					}
+					usage.Cost += price * nodeHours
+				}
+			}
+
+			if samples[tenant.Namespace] == nil {
+				samples[tenant.Namespace] = make(map[string]tenantSample)
+			}
+			samples[tenant.Namespace][tenant.Name] = tenantSample{
+				dataplane: dp.Name,
+				billing:   mode,
+				usage:     usage,
+			}
+		}
+	}
+
+	for customer, tenantSamples := range samples {
+		// a customer failing to store its usage does not drop the others
+		if err := s.store(ctx, customer, t, tenantSamples); err != nil {
+			ctrl.LoggerFrom(ctx).Error(err, "failed to store usage", logging.KeyCustomer, customer)
+		}
+	}
+	return nil
+}
+
+// prune deletes the usage configmaps of the days past the retention.
+func (s *UsageSampler) prune(ctx context.Context, t time.Time) error {
+	cms := &corev1.ConfigMapList{}
+	if err := s.List(ctx, cms, client.MatchingLabels{metering.UsageLabelKey: "true"}); err != nil {
+		return err
+	}
+	oldest := t.UTC().Add(-s.Retention).Truncate(24 * time.Hour)
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		day, ok := metering.ConfigMapDay(cm.Name)
+		if !ok || !day.Before(oldest) {
+			continue
+		}
+		ctrl.LoggerFrom(ctx).Info("pruning usage", logging.KeyCustomer, cm.Namespace, "day", day.Format(time.DateOnly))
+		if err := s.Delete(ctx, cm); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store adds the sampled usage to the usage configmap of the day in the
+// customer namespace.
+func (s *UsageSampler) store(ctx context.Context, customer string, t time.Time, tenantSamples map[string]tenantSample) error {
+	cm := &corev1.ConfigMap{}
+	err := s.Get(ctx, client.ObjectKey{Namespace: customer, Name: metering.ConfigMapName(t)}, cm)
+	notFound := apierrors.IsNotFound(err)
+	if err != nil && !notFound {
+		return err
+	}
+	if notFound {
+		cm = metering.MakeConfigMap(customer, t)
+	}
+
+	records, err := metering.DecodeConfigMap(cm)
+	if err != nil {
+		return err
+	}
+
+	for tenant, sample := range tenantSamples {
+		tr := records[tenant]
+		tr.Dataplane = sample.dataplane
+		tr.Billing = sample.billing
+		tr.AddSample(t, sample.usage)
+		records[tenant] = tr
+	}
+
+	if err := metering.EncodeConfigMap(cm, records); err != nil {
+		return err
+	}
+
+	if notFound {
+		return s.Create(ctx, cm)
+	}
+	return s.Update(ctx, cm)
+}
+
+func listNodesAndPods(ctx context.Context, dp *v1.DataPlanes) (*corev1.NodeList, *corev1.PodList, error) {
+	// the aws credentials are set by the dataplane controller
+	clientset, err := eks.NewEks(ctx, dp).GetEksClientSet()
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	pods, err := clientset.CoreV1().Pods(corev1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return nodes, pods, nil
+}
+
+// machinePoolOf returns the nodegroup or karpenter NodePool of a node, both
+// named after the machine pool.
+func machinePoolOf(node *corev1.Node) string {
+	if nodePool, found := node.Labels[karpenter.NodePoolLabel]; found {
+		return nodePool
+	}
+	return node.Labels[nodegroupLabelKey]
+}
+
+func tenantSizes(tenant *v1.Tenants) []string {
+	var sizes []string
+	for _, config := range tenant.Spec.TenantConfig {
+		sizes = append(sizes, config.Size)
+	}
+	return sizes
+}
+
+func podRequests(pod *corev1.Pod) (cpu float64, memory float64) {
+	for _, c := range pod.Spec.Containers {
+		cpu += c.Resources.Requests.Cpu().AsApproximateFloat64()
+		memory += c.Resources.Requests.Memory().AsApproximateFloat64()
+	}
+	return cpu, memory
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 
 	core "k8s.io/api/core/v1"
-	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/baazhq/baaz/pkg/logging"
 )
 
 // GenericSaaSPredicates to be passed to manager
@@ -35,7 +36,7 @@ func IgnorePrivateSaaSCustomer(obj client.Object, c client.Client) bool {
 	ns := &core.Namespace{}
 
 	if err := c.Get(context.TODO(), client.ObjectKey{Name: obj.GetNamespace()}, ns); err != nil {
-		klog.Error(err)
+		logging.Logger(logging.Predicates).Error(err, "failed to get customer namespace", logging.KeyCustomer, obj.GetNamespace())
 		return false
 	}
 
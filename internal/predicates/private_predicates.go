@@ -1,12 +1,11 @@
 package predicates
 
 import (
-	"fmt"
-
-	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/baazhq/baaz/pkg/logging"
 )
 
 // PrivateSaaSPredicates to be passed to manager
@@ -33,8 +32,8 @@ func (p PrivateSaaSPredicates) Delete(e event.DeleteEvent) bool {
 func IgnoreGenericSaaSCustomer(obj client.Object, customerName string) bool {
 
 	if obj.GetNamespace() != customerName {
-		msg := fmt.Sprintf("baaz controllers will only renconcile %s", customerName)
-		klog.Info(msg)
+		logging.Logger(logging.Predicates).V(1).Info("ignoring object of another customer",
+			logging.KeyCustomer, obj.GetNamespace(), "privateCustomer", customerName)
 		return false
 	}
 
@@ -0,0 +1,253 @@
+package rollout_controller
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// defaultProgressDeadline is the time an updated Application has to become
+// healthy, without a progress deadline.
+const defaultProgressDeadline = 10 * time.Minute
+
+// progress returns the status of rollout over apps, the Applications it
+// selects ordered by customer and name, and the apps of the wave in
+// progress to update now.
+func progress(rollout *v1.Rollout, apps []v1.Applications, now time.Time) (v1.RolloutStatus, []*v1.Applications) {
+	spec := rollout.Spec
+	deadline := defaultProgressDeadline
+	if spec.Strategy.ProgressDeadline != nil {
+		deadline = spec.Strategy.ProgressDeadline.Duration
+	}
+	updatedAt := make(map[string]*metav1.Time, len(rollout.Status.Targets))
+	for _, target := range rollout.Status.Targets {
+		updatedAt[target.Customer+"/"+target.Application] = target.UpdatedAt
+	}
+
+	status := v1.RolloutStatus{Total: len(apps)}
+	if len(apps) == 0 {
+		status.Phase, status.Message = v1.PendingR, "no applications selected"
+		return status, nil
+	}
+
+	appWaves := targetWaves(rollout, apps)
+	status.Wave = -1
+	for i := range apps {
+		app := &apps[i]
+		target := v1.RolloutTargetStatus{
+			Customer:    app.Namespace,
+			Application: app.Name,
+			Tenant:      app.Spec.Tenant,
+			Wave:        appWaves[i],
+			Phase:       v1.PendingRT,
+		}
+		if updated(app, spec.Target) {
+			target.UpdatedAt = updatedAt[app.Namespace+"/"+app.Name]
+			if target.UpdatedAt == nil {
+				target.UpdatedAt = &metav1.Time{Time: now}
+			}
+			target.Phase, target.Message = health(app, spec.Target)
+			if target.Phase == v1.UpdatingRT && now.Sub(target.UpdatedAt.Time) > deadline {
+				target.Phase, target.Message = v1.FailedRT, fmt.Sprintf("not healthy %s after its update", deadline)
+			}
+		}
+		if target.Wave >= status.Waves {
+			status.Waves = target.Wave + 1
+		}
+		if (target.Phase == v1.PendingRT || target.Phase == v1.UpdatingRT) && (status.Wave < 0 || target.Wave < status.Wave) {
+			status.Wave = target.Wave
+		}
+		status.Targets = append(status.Targets, target)
+	}
+	count(&status)
+
+	switch {
+	case status.Wave < 0:
+		status.Phase, status.Wave = v1.CompletedR, status.Waves-1
+		return status, nil
+	case spec.Paused:
+		status.Phase, status.Message = v1.PausedR, "paused"
+		return status, nil
+	case status.Failed > 0 && status.Failed*100 > spec.Strategy.MaxFailurePercent*status.Updated:
+		status.Phase = v1.PausedR
+		status.Message = fmt.Sprintf("%d of %d updated applications failed, over %d%%",
+			status.Failed, status.Updated, spec.Strategy.MaxFailurePercent)
+		return status, nil
+	}
+
+	var updates []*v1.Applications
+	for i := range status.Targets {
+		target := &status.Targets[i]
+		if target.Wave == status.Wave && target.Phase == v1.PendingRT {
+			target.Phase, target.UpdatedAt = v1.UpdatingRT, &metav1.Time{Time: now}
+			updates = append(updates, &apps[i])
+		}
+	}
+	count(&status)
+	status.Phase = v1.ProgressingR
+	return status, updates
+}
+
+// targetWaves returns the wave of each of apps. The waves are set when the
+// rollout starts and kept in its status, so the Applications selected or
+// removed later do not move the others between waves. The ones selected
+// later are rolled out in batches after the waves of the rollout.
+func targetWaves(rollout *v1.Rollout, apps []v1.Applications) []int {
+	if len(rollout.Status.Targets) == 0 {
+		return waves(len(apps), rollout.Spec.Strategy)
+	}
+	recorded := make(map[string]int, len(rollout.Status.Targets))
+	next := 0
+	for _, target := range rollout.Status.Targets {
+		recorded[target.Customer+"/"+target.Application] = target.Wave
+		if target.Wave >= next {
+			next = target.Wave + 1
+		}
+	}
+
+	appWaves := make([]int, len(apps))
+	var added []int
+	for i, app := range apps {
+		wave, ok := recorded[app.Namespace+"/"+app.Name]
+		if !ok {
+			added = append(added, i)
+		}
+		appWaves[i] = wave
+	}
+	batches := waves(len(added), v1.RolloutStrategy{BatchSize: rollout.Spec.Strategy.BatchSize})
+	for j, i := range added {
+		appWaves[i] = next + batches[j]
+	}
+	return appWaves
+}
+
+// waves returns the wave of each of n Applications, the canary wave first.
+func waves(n int, strategy v1.RolloutStrategy) []int {
+	canary := 0
+	if strategy.CanaryPercent > 0 {
+		canary = (n*strategy.CanaryPercent + 99) / 100
+	}
+	batch := strategy.BatchSize
+	if batch <= 0 {
+		batch = n
+	}
+
+	appWaves := make([]int, n)
+	for i := range appWaves {
+		switch {
+		case i < canary:
+			appWaves[i] = 0
+		case canary > 0:
+			appWaves[i] = 1 + (i-canary)/batch
+		default:
+			appWaves[i] = i / batch
+		}
+	}
+	return appWaves
+}
+
+func count(status *v1.RolloutStatus) {
+	status.Updated, status.Healthy, status.Failed = 0, 0, 0
+	for _, target := range status.Targets {
+		if target.Phase != v1.PendingRT {
+			status.Updated++
+		}
+		switch target.Phase {
+		case v1.HealthyRT:
+			status.Healthy++
+		case v1.FailedRT:
+			status.Failed++
+		}
+	}
+}
+
+// selected tells whether app can be moved to the version of target.
+func selected(app *v1.Applications, target v1.RolloutTarget) bool {
+	if target.Blueprint != "" {
+		return app.Spec.Blueprint != nil && app.Spec.Blueprint.Name == target.Blueprint
+	}
+	for _, spec := range app.Spec.Applications {
+		if spec.Name == target.App {
+			return true
+		}
+	}
+	return false
+}
+
+// updated tells whether the spec of app has the version of target.
+func updated(app *v1.Applications, target v1.RolloutTarget) bool {
+	if target.Blueprint != "" {
+		return app.Spec.Blueprint.Version == target.Version
+	}
+	for _, spec := range app.Spec.Applications {
+		if spec.Name == target.App {
+			return spec.Spec.Version == target.Version
+		}
+	}
+	return false
+}
+
+// setVersion moves the spec of app to the version of target.
+func setVersion(app *v1.Applications, target v1.RolloutTarget) {
+	if target.Blueprint != "" {
+		app.Spec.Blueprint.Version = target.Version
+		return
+	}
+	for i := range app.Spec.Applications {
+		if app.Spec.Applications[i].Name == target.App {
+			app.Spec.Applications[i].Spec.Version = target.Version
+		}
+	}
+}
+
+// health returns the phase of app, updated to the version of target, from
+// the status the application controller reports.
+func health(app *v1.Applications, target v1.RolloutTarget) (v1.RolloutTargetPhase, string) {
+	if target.Blueprint != "" {
+		blueprint := app.Status.Blueprint
+		if blueprint != nil && blueprint.Message != "" {
+			return v1.FailedRT, blueprint.Message
+		}
+		if blueprint == nil || blueprint.Version != target.Version {
+			return v1.UpdatingRT, ""
+		}
+		phase := v1.HealthyRT
+		for _, spec := range app.Status.ApplicationCurrentSpec.Applications {
+			switch chartPhase, message := chartHealth(app, spec); chartPhase {
+			case v1.FailedRT:
+				return chartPhase, message
+			case v1.UpdatingRT:
+				phase = chartPhase
+			}
+		}
+		return phase, ""
+	}
+
+	for _, spec := range app.Status.ApplicationCurrentSpec.Applications {
+		if spec.Name == target.App && spec.Spec.Version == target.Version {
+			return chartHealth(app, spec)
+		}
+	}
+	return v1.UpdatingRT, ""
+}
+
+// chartHealth returns the phase of the app spec of app, deployed.
+func chartHealth(app *v1.Applications, spec v1.AppSpec) (v1.RolloutTargetPhase, string) {
+	// the apps are reported by chart name in the status of the Applications
+	chartName := spec.ChartName()
+	switch phase := app.Status.AppStatus[chartName]; phase {
+	case v1.FailedA, v1.RolledBackA, v1.BlockedA:
+		if reason := app.Status.Reasons[chartName]; reason != "" {
+			return v1.FailedRT, fmt.Sprintf("app %s is %s: %s", spec.Name, phase, reason)
+		}
+		return v1.FailedRT, fmt.Sprintf("app %s is %s", spec.Name, phase)
+	case v1.DeployedA:
+		if app.Status.Reasons[chartName] == "" {
+			return v1.HealthyRT, ""
+		}
+	}
+	return v1.UpdatingRT, ""
+}
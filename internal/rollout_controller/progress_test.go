@@ -0,0 +1,169 @@
+package rollout_controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func application(name, version string) v1.Applications {
+	return v1.Applications{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "customer", Name: name},
+		Spec: v1.ApplicationSpec{
+			Tenant: name,
+			Applications: []v1.AppSpec{
+				{Name: "product", Namespace: "product", Spec: v1.ChartSpec{Version: version}},
+			},
+		},
+	}
+}
+
+// deploy reports app deployed at version in phase.
+func deploy(app *v1.Applications, version string, phase v1.ApplicationPhase) {
+	app.Status.ApplicationCurrentSpec = *app.Spec.DeepCopy()
+	app.Status.ApplicationCurrentSpec.Applications[0].Spec.Version = version
+	app.Status.AppStatus = map[string]v1.ApplicationPhase{"product-product": phase}
+}
+
+func TestWaves(t *testing.T) {
+	for _, tc := range []struct {
+		n        int
+		strategy v1.RolloutStrategy
+		want     []int
+	}{
+		{5, v1.RolloutStrategy{}, []int{0, 0, 0, 0, 0}},
+		{5, v1.RolloutStrategy{CanaryPercent: 10, BatchSize: 2}, []int{0, 1, 1, 2, 2}},
+		{5, v1.RolloutStrategy{CanaryPercent: 50}, []int{0, 0, 0, 1, 1}},
+		{5, v1.RolloutStrategy{BatchSize: 2}, []int{0, 0, 1, 1, 2}},
+	} {
+		if got := waves(tc.n, tc.strategy); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("waves(%d, %+v) = %v, expected %v", tc.n, tc.strategy, got, tc.want)
+		}
+	}
+}
+
+func TestTargetWaves(t *testing.T) {
+	rollout := &v1.Rollout{Spec: v1.RolloutSpec{Strategy: v1.RolloutStrategy{CanaryPercent: 25, BatchSize: 2}}}
+	var apps []v1.Applications
+	for _, name := range []string{"tenant-1", "tenant-3", "tenant-5", "tenant-7"} {
+		apps = append(apps, application(name, "1.0.0"))
+	}
+	if got, want := targetWaves(rollout, apps), []int{0, 1, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, expected %v", got, want)
+	}
+
+	status, _ := progress(rollout, apps, time.Now())
+	rollout.Status = status
+	// tenant-3 is removed and tenants are added before and after the others
+	apps = nil
+	for _, name := range []string{"tenant-0", "tenant-1", "tenant-2", "tenant-5", "tenant-6", "tenant-7"} {
+		apps = append(apps, application(name, "1.0.0"))
+	}
+	if got, want := targetWaves(rollout, apps), []int{3, 0, 3, 1, 4, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, expected the recorded waves kept, %v", got, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	now := time.Now()
+	rollout := &v1.Rollout{Spec: v1.RolloutSpec{
+		Target:   v1.RolloutTarget{App: "product", Version: "2.0.0"},
+		Strategy: v1.RolloutStrategy{CanaryPercent: 25, BatchSize: 2, MaxFailurePercent: 20},
+	}}
+	var apps []v1.Applications
+	for i := 0; i < 4; i++ {
+		app := application(fmt.Sprintf("tenant-%d", i), "1.0.0")
+		deploy(&app, "1.0.0", v1.DeployedA)
+		apps = append(apps, app)
+	}
+
+	status, updates := progress(rollout, apps, now)
+	if status.Phase != v1.ProgressingR || status.Wave != 0 || status.Waves != 3 || len(updates) != 1 || updates[0].Name != "tenant-0" {
+		t.Fatalf("expected the canary to be updated first, got %+v", status)
+	}
+	setVersion(updates[0], rollout.Spec.Target)
+	rollout.Status = status
+
+	// the canary is not healthy yet
+	status, updates = progress(rollout, apps, now.Add(time.Minute))
+	if status.Wave != 0 || len(updates) != 0 || status.Targets[0].Phase != v1.UpdatingRT || !status.Targets[0].UpdatedAt.Time.Equal(now) {
+		t.Fatalf("expected the rollout to wait for the canary, got %+v", status)
+	}
+
+	deploy(&apps[0], "2.0.0", v1.DeployedA)
+	status, updates = progress(rollout, apps, now.Add(time.Minute))
+	if status.Wave != 1 || len(updates) != 2 || updates[0].Name != "tenant-1" || updates[1].Name != "tenant-2" {
+		t.Fatalf("expected the next wave to be updated once the canary is healthy, got %+v", status)
+	}
+	for _, app := range updates {
+		setVersion(app, rollout.Spec.Target)
+	}
+	rollout.Status = status
+
+	deploy(&apps[1], "2.0.0", v1.RolledBackA)
+	status, updates = progress(rollout, apps, now.Add(2*time.Minute))
+	if status.Phase != v1.PausedR || len(updates) != 0 || status.Failed != 1 || status.Targets[1].Phase != v1.FailedRT {
+		t.Fatalf("expected the rollout to pause on the failure, got %+v", status)
+	}
+
+	// tenant-2 misses its deadline, the rollout stays paused once tenant-1
+	// is fixed
+	deploy(&apps[1], "2.0.0", v1.DeployedA)
+	status, _ = progress(rollout, apps, now.Add(20*time.Minute))
+	if status.Phase != v1.PausedR || status.Targets[2].Phase != v1.FailedRT {
+		t.Fatalf("expected tenant-2 to fail past its deadline, got %+v", status)
+	}
+
+	rollout.Spec.Strategy.MaxFailurePercent = 50
+	status, updates = progress(rollout, apps, now.Add(20*time.Minute))
+	if status.Phase != v1.ProgressingR || status.Wave != 2 || len(updates) != 1 || updates[0].Name != "tenant-3" {
+		t.Fatalf("expected the rollout to resume under its failure threshold, got %+v", status)
+	}
+	setVersion(updates[0], rollout.Spec.Target)
+	rollout.Status = status
+
+	deploy(&apps[2], "2.0.0", v1.DeployedA)
+	deploy(&apps[3], "2.0.0", v1.DeployedA)
+	status, _ = progress(rollout, apps, now.Add(21*time.Minute))
+	if status.Phase != v1.CompletedR || status.Healthy != 4 || status.Updated != 4 {
+		t.Fatalf("expected the rollout to complete, got %+v", status)
+	}
+
+	rollout.Spec.Paused = true
+	rollout.Spec.Target.Version = "3.0.0"
+	if status, updates = progress(rollout, apps, now); status.Phase != v1.PausedR || len(updates) != 0 {
+		t.Fatalf("expected a paused rollout to update nothing, got %+v", status)
+	}
+}
+
+func TestBlueprintHealth(t *testing.T) {
+	target := v1.RolloutTarget{Blueprint: "analytics", Version: "1.1.0"}
+	app := application("tenant", "")
+	app.Spec.Blueprint = &v1.BlueprintRef{Name: "analytics", Version: "1.0.0"}
+	if !selected(&app, target) || updated(&app, target) {
+		t.Fatal("expected the instance to be selected and not updated")
+	}
+	setVersion(&app, target)
+	if !updated(&app, target) {
+		t.Fatal("expected the instance to be updated")
+	}
+
+	app.Status.Blueprint = &v1.BlueprintStatus{Version: "1.1.0", Message: "invalid parameters"}
+	if phase, _ := health(&app, target); phase != v1.FailedRT {
+		t.Fatalf("expected an instance failing to render to fail, got %s", phase)
+	}
+	app.Status.Blueprint.Message = ""
+	deploy(&app, "", v1.InstallingA)
+	if phase, _ := health(&app, target); phase != v1.UpdatingRT {
+		t.Fatalf("expected an instance installing to be updating, got %s", phase)
+	}
+	deploy(&app, "", v1.DeployedA)
+	if phase, _ := health(&app, target); phase != v1.HealthyRT {
+		t.Fatalf("expected a deployed instance to be healthy, got %s", phase)
+	}
+}
@@ -0,0 +1,113 @@
+package rollout_controller
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/gitops"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// do updates the Applications of the wave in progress of rollout, and
+// records its progress. It returns the phase of the rollout.
+func (r *RolloutReconciler) do(ctx context.Context, rollout *v1.Rollout) (v1.RolloutPhase, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	apps, err := r.selectApplications(ctx, rollout.Spec)
+	if err != nil {
+		return "", err
+	}
+
+	status, updates := progress(rollout, apps, time.Now())
+	for _, app := range updates {
+		log.Info("updating application",
+			logging.KeyCustomer, app.Namespace,
+			logging.KeyApplication, app.Name,
+			"wave", status.Wave,
+			"version", rollout.Spec.Target.Version,
+		)
+		patch := client.MergeFromWithOptions(app.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		setVersion(app, rollout.Spec.Target)
+		if err := r.Patch(ctx, app, patch); err != nil {
+			return "", err
+		}
+	}
+
+	if status.Phase != rollout.Status.Phase {
+		log.Info("rollout "+string(status.Phase), "message", status.Message)
+		switch status.Phase {
+		case v1.PausedR:
+			r.Recorder.Event(rollout, corev1.EventTypeWarning, string(status.Phase), status.Message)
+		case v1.CompletedR:
+			r.Recorder.Eventf(rollout, corev1.EventTypeNormal, string(status.Phase),
+				"%d of %d applications healthy", status.Healthy, status.Total)
+		}
+	}
+
+	_, _, err = utils.PatchStatus(ctx, r.Client, rollout, func(obj client.Object) client.Object {
+		in := obj.(*v1.Rollout)
+		in.Status = status
+		return in
+	})
+	return status.Phase, err
+}
+
+// selectApplications returns the Applications selected by spec, ordered by
+// customer and name.
+func (r *RolloutReconciler) selectApplications(ctx context.Context, spec v1.RolloutSpec) ([]v1.Applications, error) {
+	selector := spec.Selector
+
+	opts := []client.ListOption{}
+	if selector.Labels != nil {
+		labels, err := metav1.LabelSelectorAsSelector(selector.Labels)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: labels})
+	}
+	list := &v1.ApplicationsList{}
+	if err := r.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+
+	saasTypes := map[string]v1.SaaSTypes{}
+	if len(selector.SaaSTypes) > 0 {
+		customers := &corev1.NamespaceList{}
+		if err := r.List(ctx, customers); err != nil {
+			return nil, err
+		}
+		for _, customer := range customers.Items {
+			saasTypes[customer.Name] = v1.SaaSTypes(customer.Labels["saas_type"])
+		}
+	}
+
+	var apps []v1.Applications
+	for _, app := range list.Items {
+		switch {
+		case app.DeletionTimestamp != nil,
+			gitops.SourceOf(app.Labels) != "",
+			!selected(&app, spec.Target),
+			len(selector.Customers) > 0 && !slices.Contains(selector.Customers, app.Namespace),
+			len(selector.Dataplanes) > 0 && !slices.Contains(selector.Dataplanes, app.Spec.Dataplane),
+			len(selector.SaaSTypes) > 0 && !slices.Contains(selector.SaaSTypes, saasTypes[app.Namespace]):
+			continue
+		}
+		apps = append(apps, app)
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		if apps[i].Namespace != apps[j].Namespace {
+			return apps[i].Namespace < apps[j].Namespace
+		}
+		return apps[i].Name < apps[j].Name
+	})
+	return apps, nil
+}
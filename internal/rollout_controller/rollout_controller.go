@@ -0,0 +1,79 @@
+package rollout_controller
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/tracing"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RolloutReconciler reconciles a Rollout object
+type RolloutReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// reconcile time duration of the rollouts in progress, the health of
+	// their Applications is not watched
+	ReconcileWait time.Duration
+	Recorder      record.EventRecorder
+}
+
+func NewRolloutReconciler(mgr ctrl.Manager) *RolloutReconciler {
+	return &RolloutReconciler{
+		Client:        mgr.GetClient(),
+		Log:           logging.Logger(logging.Rollout),
+		Scheme:        mgr.GetScheme(),
+		ReconcileWait: 10 * time.Second,
+		Recorder:      mgr.GetEventRecorderFor("rollout-controller"),
+	}
+}
+
+//+kubebuilder:rbac:groups=baaz.dev,resources=rollouts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=baaz.dev,resources=rollouts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=baaz.dev,resources=applications,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *RolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	rollout := &v1.Rollout{}
+	if err := r.Get(ctx, req.NamespacedName, rollout); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	ctx, span := tracing.StartReconcile(ctx, "RolloutReconciler", rollout)
+	defer span.End()
+
+	ctx, log := logging.WithValues(ctx, logging.KeyRollout, rollout.Name)
+
+	if rollout.DeletionTimestamp != nil {
+		// the updated Applications are left as they are
+		return ctrl.Result{}, nil
+	}
+
+	phase, err := r.do(ctx, rollout)
+	if err != nil {
+		span.RecordError(err)
+		logging.Error(log, err, "failed to reconcile rollout")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+	if phase == v1.CompletedR {
+		// the Applications selected later are rolled out too
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+	return ctrl.Result{RequeueAfter: r.ReconcileWait}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.Rollout{}).
+		WithLogConstructor(logging.RequestLogger(r.Log)).
+		Complete(r)
+}
@@ -2,20 +2,26 @@ package tenant_controller
 
 import (
 	"context"
+	"net"
+	"sort"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"k8s.io/client-go/kubernetes"
 
 	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/admission"
 	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/placement"
 	"github.com/baazhq/baaz/pkg/resources"
 	"github.com/baazhq/baaz/pkg/store"
 	"github.com/baazhq/baaz/pkg/utils"
-	"k8s.io/klog/v2"
 
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -26,6 +32,10 @@ const (
 	system nodeGroupType = "system"
 )
 
+// maxFQDNAddresses bounds the addresses an allowed FQDN keeps in the network
+// policy of the tenant.
+const maxFQDNAddresses = 16
+
 type awsEnv struct {
 	ctx    context.Context
 	dp     *v1.DataPlanes
@@ -36,14 +46,14 @@ type awsEnv struct {
 }
 
 func (ae *awsEnv) ReconcileTenants() error {
-	klog.Info("Reconciling tenants")
+	ctrl.LoggerFrom(ae.ctx).V(1).Info("reconciling tenant namespace, network policy, quota and identity")
 
 	clientset, err := ae.eksIC.GetEksClientSet()
 	if err != nil {
 		return err
 	}
 
-	if err := ae.createNamespace(clientset); err != nil {
+	if err := ae.reconcileNamespace(clientset); err != nil {
 		return err
 	}
 
@@ -51,7 +61,15 @@ func (ae *awsEnv) ReconcileTenants() error {
 		return err
 	}
 
-	return nil
+	if err := ae.reconcileQuota(clientset); err != nil {
+		return err
+	}
+
+	if err := ae.reconcileIdentity(clientset); err != nil {
+		return err
+	}
+
+	return ae.reconcileExpansions(clientset)
 }
 
 func (ae *awsEnv) patchStatus(name, status string) error {
@@ -68,43 +86,222 @@ func (ae *awsEnv) patchStatus(name, status string) error {
 	return err
 }
 
-func (ae *awsEnv) createNamespace(clientset *kubernetes.Clientset) error {
+// reconcileNamespace creates the namespace of the tenant and records on it
+// where its pods are scheduled, for the admission webhook of the dataplane.
+func (ae *awsEnv) reconcileNamespace(clientset *kubernetes.Clientset) error {
+	scheduling, err := ae.scheduling()
+	if err != nil {
+		return err
+	}
 
-	_, err := clientset.CoreV1().Namespaces().Get(ae.ctx, ae.tenant.Name, metav1.GetOptions{})
+	namespace, err := clientset.CoreV1().Namespaces().Get(ae.ctx, ae.tenant.Name, metav1.GetOptions{})
 	if apierrors.IsNotFound(err) {
-		ns, err := clientset.CoreV1().Namespaces().Create(ae.ctx, &corev1.Namespace{
+		namespace = &corev1.Namespace{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: ae.tenant.Name,
 			},
-		}, metav1.CreateOptions{})
+		}
+		if _, err := scheduling.Annotate(namespace); err != nil {
+			return err
+		}
+		ns, err := clientset.CoreV1().Namespaces().Create(ae.ctx, namespace, metav1.CreateOptions{})
 		if err != nil {
 			return err
 		}
-		klog.Infof("Namespace [%s] created for dataplane [%s]", ns.Name, ae.dp.Name)
+		ctrl.LoggerFrom(ae.ctx).Info("created tenant namespace", "namespace", ns.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
+	changed, err := scheduling.Annotate(namespace)
+	if err != nil || !changed {
+		return err
+	}
+	if _, err := clientset.CoreV1().Namespaces().Update(ae.ctx, namespace, metav1.UpdateOptions{}); err != nil {
+		return err
 	}
+	ctrl.LoggerFrom(ae.ctx).Info("updated tenant scheduling", "namespace", namespace.Name)
 	return nil
+}
+
+// scheduling places the pods of each app type of the tenant on the machine
+// pools of the size it runs on, or of the size it moves to once the
+// migration moves its workloads. The pods may target the machine pools of
+// every size of the tenant.
+func (ae *awsEnv) scheduling() (*admission.Scheduling, error) {
+	tenantsInfra, err := ae.tenantsInfra()
+	if err != nil {
+		return nil, err
+	}
+	usesKarpenter := ae.dp.UsesKarpenter()
+	placementOf := func(size string) placement.Placement {
+		return placement.ForSize(size, tenantsInfra.Spec.TenantSizes[size], usesKarpenter)
+	}
+
+	scheduling := &admission.Scheduling{
+		Tenant:          ae.tenant.Name,
+		Placements:      make(map[v1.ApplicationType]placement.Placement),
+		PriorityClasses: make(map[v1.ApplicationType]string),
+		Isolated:        ae.tenant.Spec.Isolation.Machine.Enabled,
+	}
+	for i, config := range ae.tenant.Spec.TenantConfig {
+		if i == 0 {
+			scheduling.DefaultAppType = config.AppType
+		}
 
+		size := ae.tenant.Status.Sizes[config.AppType]
+		if size == "" {
+			size = config.Size
+		}
+		scheduling.Allow(placementOf(config.Size))
+		if migration, ok := ae.tenant.Status.Migrations[config.AppType]; ok {
+			if migration.Phase == v1.MigrationMoving || migration.Phase == v1.MigrationRetiring {
+				size = migration.To
+			}
+			scheduling.Allow(placementOf(migration.From))
+			scheduling.Allow(placementOf(migration.To))
+		}
+
+		p := placementOf(size)
+		scheduling.Allow(p)
+		if len(p.Names) == 0 {
+			continue
+		}
+		scheduling.Placements[config.AppType] = p
+		scheduling.PriorityClasses[config.AppType] = admission.PriorityClassFor(tenantsInfra.Spec.TenantSizes[size])
+	}
+	return scheduling, nil
 }
 
+// createOrUpdateNetworkPolicy makes the network policy of the tenant match
+// its isolation config. The policy is rendered on every reconcile, so the
+// changes of the spec, the edits of the policy in the dataplane and the new
+// addresses of the allowed FQDNs are applied. It is deleted when the tenant
+// restricts no traffic.
 func (ae *awsEnv) createOrUpdateNetworkPolicy(clientset *kubernetes.Clientset) error {
-
+	log := ctrl.LoggerFrom(ae.ctx)
+	config := ae.tenant.Spec.Isolation.Network
+	networkPolicies := clientset.NetworkingV1().NetworkPolicies(ae.tenant.Name)
 	networkPolicyName := ae.tenant.Name + "-network-policy"
-	_, err := clientset.NetworkingV1().NetworkPolicies(ae.tenant.Name).Get(ae.ctx, networkPolicyName, metav1.GetOptions{})
+
+	var fqdnAddresses []string
+	if config.Egress != nil && config.Egress.DefaultDeny {
+		resolved := ae.resolveFQDNs(config.Egress.AllowedFQDNs, ae.tenant.Status.FQDNAddresses)
+		if !equality.Semantic.DeepEqual(resolved, ae.tenant.Status.FQDNAddresses) {
+			if _, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.tenant, func(obj client.Object) client.Object {
+				in := obj.(*v1.Tenants)
+				in.Status.FQDNAddresses = resolved
+				return in
+			}); err != nil {
+				return err
+			}
+		}
+		fqdnAddresses = flattenAddresses(resolved)
+	}
+	desired := resources.MakeNetworkPolicy(
+		networkPolicyName,
+		ae.tenant.Name,
+		config,
+		fqdnAddresses,
+		resources.MakeOwnerRef(ae.tenant.APIVersion, ae.tenant.Kind, ae.tenant.Name, ae.tenant.UID),
+	)
+
+	current, err := networkPolicies.Get(ae.ctx, networkPolicyName, metav1.GetOptions{})
 	if apierrors.IsNotFound(err) {
-		ns, err := clientset.NetworkingV1().NetworkPolicies(ae.tenant.Name).Create(ae.ctx,
-			resources.MakeNetworkPolicy(
-				networkPolicyName,
-				ae.tenant.Name,
-				ae.tenant.Spec.Isolation.Network.AllowedNamespaces,
-				resources.MakeOwnerRef(ae.tenant.APIVersion, ae.tenant.Kind, ae.tenant.Name, ae.tenant.UID),
-			), metav1.CreateOptions{},
-		)
-		if err != nil {
+		if desired == nil {
+			return nil
+		}
+		if _, err := networkPolicies.Create(ae.ctx, desired, metav1.CreateOptions{}); err != nil {
 			return err
 		}
-		klog.Infof("Network Policy [%s] created for tenant [%s]", ns.Name, ae.tenant.Name)
+		log.Info("created tenant network policy", "namespace", ae.tenant.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if desired == nil {
+		if err := networkPolicies.Delete(ae.ctx, networkPolicyName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		log.Info("deleted tenant network policy, no traffic is restricted", "namespace", ae.tenant.Name)
+		return nil
+	}
+	if equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+		return nil
 	}
 
+	current.Spec = desired.Spec
+	if _, err := networkPolicies.Update(ae.ctx, current, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	log.Info("updated tenant network policy", "namespace", ae.tenant.Name)
 	return nil
 }
+
+// resolveFQDNs returns the addresses of each host, as single address cidrs.
+// The new addresses of a host are merged with the ones it is known to
+// resolve to, up to maxFQDNAddresses, so the connections to the addresses a
+// rotating DNS answer leaves out are not cut. A host which does not resolve
+// keeps its known addresses, the ones which never did are skipped until
+// they do.
+func (ae *awsEnv) resolveFQDNs(hosts []string, known map[string][]string) map[string][]string {
+	resolved := make(map[string][]string)
+	for _, host := range hosts {
+		ips, err := net.DefaultResolver.LookupIPAddr(ae.ctx, host)
+		if err != nil {
+			logging.Error(ctrl.LoggerFrom(ae.ctx), err, "failed to resolve allowed fqdn, keeping its known addresses", "fqdn", host)
+			if addresses := known[host]; len(addresses) > 0 {
+				resolved[host] = addresses
+			}
+			continue
+		}
+		var addresses []string
+		for _, ip := range ips {
+			address := ip.IP.String() + "/32"
+			if ip.IP.To4() == nil {
+				address = ip.IP.String() + "/128"
+			}
+			addresses = append(addresses, address)
+		}
+		resolved[host] = mergeAddresses(addresses, known[host])
+	}
+	if len(resolved) == 0 {
+		return nil
+	}
+	return resolved
+}
+
+// mergeAddresses returns the sorted addresses resolved and the known ones,
+// the resolved addresses win when there are more than maxFQDNAddresses.
+func mergeAddresses(resolved, known []string) []string {
+	seen := make(map[string]bool)
+	var addresses []string
+	for _, address := range append(append([]string{}, resolved...), known...) {
+		if !seen[address] && len(addresses) < maxFQDNAddresses {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// flattenAddresses returns the sorted addresses of all the hosts.
+func flattenAddresses(resolved map[string][]string) []string {
+	seen := make(map[string]bool)
+	var addresses []string
+	for _, hostAddresses := range resolved {
+		for _, address := range hostAddresses {
+			if !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
@@ -0,0 +1,147 @@
+package tenant_controller
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/resources"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// reconcileIdentity makes the service account of the tenant, its role on
+// the namespace of the tenant and its iam role match the spec, and reports
+// them.
+func (ae *awsEnv) reconcileIdentity(clientset kubernetes.Interface) error {
+	serviceAccountName := resources.TenantServiceAccountName(ae.tenant.Name)
+
+	roleArn, err := ae.reconcileTenantRole(serviceAccountName)
+	if err != nil {
+		return err
+	}
+	if err := ae.applyServiceAccount(clientset, roleArn); err != nil {
+		return err
+	}
+	if err := ae.applyRBAC(clientset); err != nil {
+		return err
+	}
+
+	status := &v1.IdentityStatus{ServiceAccount: serviceAccountName, RoleArn: roleArn}
+	if equality.Semantic.DeepEqual(ae.tenant.Status.Identity, status) {
+		return nil
+	}
+	_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.tenant, func(obj client.Object) client.Object {
+		in := obj.(*v1.Tenants)
+		in.Status.Identity = status
+		return in
+	})
+	return err
+}
+
+// reconcileTenantRole ensures the iam role of the tenant when it declares an
+// identity and deletes the role it had otherwise. It returns the role arn,
+// empty without any.
+func (ae *awsEnv) reconcileTenantRole(serviceAccountName string) (string, error) {
+	identity := ae.tenant.Spec.Identity
+	if identity == nil || identity.IAMPolicy == "" && len(identity.PolicyArns) == 0 {
+		if ae.tenant.Status.Identity == nil || ae.tenant.Status.Identity.RoleArn == "" {
+			return "", nil
+		}
+		if err := ae.eksIC.DeleteTenantRole(ae.ctx, ae.tenant.Name); err != nil {
+			return "", err
+		}
+		ctrl.LoggerFrom(ae.ctx).Info("deleted tenant iam role", "role", ae.tenant.Status.Identity.RoleArn)
+		return "", nil
+	}
+	return ae.eksIC.EnsureTenantRole(ae.ctx, ae.tenant.Name, serviceAccountName, identity)
+}
+
+// applyServiceAccount creates the service account of the tenant or updates
+// the role it assumes.
+func (ae *awsEnv) applyServiceAccount(clientset kubernetes.Interface, roleArn string) error {
+	log := ctrl.LoggerFrom(ae.ctx)
+	serviceAccounts := clientset.CoreV1().ServiceAccounts(ae.tenant.Name)
+	ownerRef := resources.MakeOwnerRef(ae.tenant.APIVersion, ae.tenant.Kind, ae.tenant.Name, ae.tenant.UID)
+	desired := resources.MakeTenantServiceAccount(ae.tenant.Name, ae.tenant.Name, roleArn, ownerRef)
+
+	current, err := serviceAccounts.Get(ae.ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := serviceAccounts.Create(ae.ctx, desired, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		log.Info("created tenant service account", "serviceAccount", desired.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if current.Annotations[resources.RoleArnAnnotation] == roleArn {
+		return nil
+	}
+	if roleArn == "" {
+		delete(current.Annotations, resources.RoleArnAnnotation)
+	} else {
+		if current.Annotations == nil {
+			current.Annotations = make(map[string]string)
+		}
+		current.Annotations[resources.RoleArnAnnotation] = roleArn
+	}
+	if _, err := serviceAccounts.Update(ae.ctx, current, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	log.Info("updated tenant service account role", "serviceAccount", desired.Name, "role", roleArn)
+	return nil
+}
+
+// applyRBAC creates the role of the tenant on its namespace and its binding
+// to the service account of the tenant, and reverts their edits.
+func (ae *awsEnv) applyRBAC(clientset kubernetes.Interface) error {
+	log := ctrl.LoggerFrom(ae.ctx)
+	ownerRef := resources.MakeOwnerRef(ae.tenant.APIVersion, ae.tenant.Kind, ae.tenant.Name, ae.tenant.UID)
+
+	roles := clientset.RbacV1().Roles(ae.tenant.Name)
+	role := resources.MakeTenantRole(ae.tenant.Name, ae.tenant.Name, ownerRef)
+	current, err := roles.Get(ae.ctx, role.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		if _, err := roles.Create(ae.ctx, role, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		log.Info("created tenant role", "role", role.Name)
+	case err != nil:
+		return err
+	case !equality.Semantic.DeepEqual(current.Rules, role.Rules):
+		current.Rules = role.Rules
+		if _, err := roles.Update(ae.ctx, current, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		log.Info("updated tenant role", "role", role.Name)
+	}
+
+	roleBindings := clientset.RbacV1().RoleBindings(ae.tenant.Name)
+	binding := resources.MakeTenantRoleBinding(ae.tenant.Name, ae.tenant.Name, ownerRef)
+	currentBinding, err := roleBindings.Get(ae.ctx, binding.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		if _, err := roleBindings.Create(ae.ctx, binding, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		log.Info("created tenant role binding", "roleBinding", binding.Name)
+	case err != nil:
+		return err
+	case !equality.Semantic.DeepEqual(currentBinding.Subjects, binding.Subjects):
+		// the role a binding refers to is immutable, only its subjects are
+		// reverted
+		currentBinding.Subjects = binding.Subjects
+		if _, err := roleBindings.Update(ae.ctx, currentBinding, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		log.Info("updated tenant role binding", "roleBinding", binding.Name)
+	}
+	return nil
+}
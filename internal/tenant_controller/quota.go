@@ -0,0 +1,170 @@
+package tenant_controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/resources"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+// reconcileQuota makes the ResourceQuotas and LimitRange of the namespace of
+// the tenant match the quota of the tenant and of its sizes, and reports the
+// usage of the namespace against them.
+func (ae *awsEnv) reconcileQuota(clientset kubernetes.Interface) error {
+	quota, err := ae.quota()
+	if err != nil {
+		return err
+	}
+	namespace := ae.tenant.Name
+	ownerRef := resources.MakeOwnerRef(ae.tenant.APIVersion, ae.tenant.Kind, ae.tenant.Name, ae.tenant.UID)
+
+	var desired []*corev1.ResourceQuota
+	var limitRange *corev1.LimitRange
+	if quota != nil {
+		desired = resources.MakeResourceQuotas(ae.tenant.Name, namespace, quota, ownerRef)
+		limitRange = resources.MakeLimitRange(ae.tenant.Name, namespace, quota, ownerRef)
+	}
+
+	status, err := ae.applyResourceQuotas(clientset, desired)
+	if err != nil {
+		return err
+	}
+	if err := ae.applyLimitRange(clientset, limitRange); err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(ae.tenant.Status.Quota, status) {
+		return nil
+	}
+	_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.tenant, func(obj client.Object) client.Object {
+		in := obj.(*v1.Tenants)
+		in.Status.Quota = status
+		return in
+	})
+	return err
+}
+
+// quota merges the quota of the tenant with the ones of the sizes its app
+// types run on.
+func (ae *awsEnv) quota() (*v1.QuotaSpec, error) {
+	tenantsInfra, err := ae.tenantsInfra()
+	if err != nil {
+		return nil, err
+	}
+	var sizes []*v1.QuotaSpec
+	for _, config := range ae.tenant.Spec.TenantConfig {
+		size := ae.tenant.Status.Sizes[config.AppType]
+		if size == "" {
+			size = config.Size
+		}
+		sizes = append(sizes, tenantsInfra.Spec.TenantSizes[size].Quota)
+	}
+	return resources.MergeQuota(sizes, ae.tenant.Spec.Quota), nil
+}
+
+// applyResourceQuotas creates or updates the desired ResourceQuotas and
+// deletes the other ones baaz manages in the namespace. It returns the
+// usage the current quotas report, nil without any.
+func (ae *awsEnv) applyResourceQuotas(clientset kubernetes.Interface, desired []*corev1.ResourceQuota) (*v1.QuotaStatus, error) {
+	log := ctrl.LoggerFrom(ae.ctx)
+	resourceQuotas := clientset.CoreV1().ResourceQuotas(ae.tenant.Name)
+
+	current, err := resourceQuotas.List(ae.ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{resources.QuotaLabel: ae.tenant.Name}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]*corev1.ResourceQuota, len(current.Items))
+	for i := range current.Items {
+		existing[current.Items[i].Name] = &current.Items[i]
+	}
+
+	var status *v1.QuotaStatus
+	for _, resourceQuota := range desired {
+		applied, ok := existing[resourceQuota.Name]
+		delete(existing, resourceQuota.Name)
+		switch {
+		case !ok:
+			if applied, err = resourceQuotas.Create(ae.ctx, resourceQuota, metav1.CreateOptions{}); err != nil {
+				return nil, err
+			}
+			log.Info("created tenant resource quota", "resourceQuota", resourceQuota.Name)
+		case !equality.Semantic.DeepEqual(applied.Spec, resourceQuota.Spec):
+			applied.Spec = resourceQuota.Spec
+			if applied, err = resourceQuotas.Update(ae.ctx, applied, metav1.UpdateOptions{}); err != nil {
+				return nil, err
+			}
+			log.Info("updated tenant resource quota", "resourceQuota", resourceQuota.Name)
+		}
+
+		if status == nil {
+			status = &v1.QuotaStatus{}
+		}
+		usage := v1.QuotaUsage{Hard: applied.Status.Hard, Used: applied.Status.Used}
+		if applied.Spec.ScopeSelector == nil {
+			status.QuotaUsage = usage
+			continue
+		}
+		if status.PriorityClasses == nil {
+			status.PriorityClasses = make(map[string]v1.QuotaUsage)
+		}
+		status.PriorityClasses[applied.Spec.ScopeSelector.MatchExpressions[0].Values[0]] = usage
+	}
+
+	for name := range existing {
+		if err := resourceQuotas.Delete(ae.ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		log.Info("deleted tenant resource quota", "resourceQuota", name)
+	}
+	return status, nil
+}
+
+// applyLimitRange creates, updates or deletes the LimitRange of the
+// namespace to match desired.
+func (ae *awsEnv) applyLimitRange(clientset kubernetes.Interface, desired *corev1.LimitRange) error {
+	log := ctrl.LoggerFrom(ae.ctx)
+	limitRanges := clientset.CoreV1().LimitRanges(ae.tenant.Name)
+	name := resources.LimitRangeName(ae.tenant.Name)
+
+	current, err := limitRanges.Get(ae.ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if desired == nil {
+			return nil
+		}
+		if _, err := limitRanges.Create(ae.ctx, desired, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		log.Info("created tenant limit range", "limitRange", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if desired == nil {
+		if err := limitRanges.Delete(ae.ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		log.Info("deleted tenant limit range", "limitRange", name)
+		return nil
+	}
+	if equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+		return nil
+	}
+	current.Spec = desired.Spec
+	if _, err := limitRanges.Update(ae.ctx, current, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	log.Info("updated tenant limit range", "limitRange", name)
+	return nil
+}
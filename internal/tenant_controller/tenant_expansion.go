@@ -1,64 +1,503 @@
 package tenant_controller
 
-// func (c *cloud) tenant_expansion() error {
-// 	currentTenantObj := &v1.Tenants{}
-// 	err := c.ae.client.Get(c.ae.ctx, types.NamespacedName{
-// 		Namespace: c.ae.tenant.Namespace,
-// 		Name:      c.ae.tenant.Name,
-// 	}, currentTenantObj)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	// for _, curentTenantSize := range currentTenantObj.Spec.TenantSizes {
-// 	// 	for _, desiredTenantSize := range c.ae.tenant.Spec.TenantSizes {
-// 	// 		for _, currentNodeSpec := range curentTenantSize.Spec {
-// 	// 			for _, desiredNodeSpec := range desiredTenantSize.Spec {
-// 	// 				if currentNodeSpec.Size != desiredNodeSpec.Size {
-// 	// 					klog.Infof(
-// 	// 						"Tenant Expansion: Tenant [%s], Node Name [%s], Current Size [%s], Desired Size [%s]",
-// 	// 						currentTenantObj.Name,
-// 	// 						currentNodeSpec.Name,
-// 	// 						currentNodeSpec.Size,
-// 	// 						desiredNodeSpec.Size,
-// 	// 					)
-
-// 	// 					var nodeName string
-// 	// 					for _, tenantConfig := range c.ae.tenant.Spec.TenantConfig {
-// 	// 						if tenantConfig.Size == desiredNodeSpec.Name {
-// 	// 							nodeName = makeNodeName(desiredNodeSpec.Name, string(tenantConfig.AppType), tenantConfig.Size)
-
-// 	// 						}
-// 	// 					}
-
-// 	// 					describeNodegroupOutput, found, _ := c.ae.eksIC.DescribeNodegroup(nodeName)
-// 	// 					if !found {
-// 	// 						nodeRole, err := c.ae.eksIC.CreateNodeIamRole(nodeName)
-// 	// 						if err != nil {
-// 	// 							return err
-// 	// 						}
-// 	// 						if nodeRole.Role == nil {
-// 	// 							return errors.New("node role is nil")
-// 	// 						}
-
-// 	// 						createNodeGroupOutput, err := c.ae.eksIC.CreateNodegroup(c.ae.getNodegroupInput(nodeName, *nodeRole.Role.Arn, &nodeSpec))
-// 	// 						if err != nil {
-// 	// 							return err
-// 	// 						}
-// 	// 						if createNodeGroupOutput != nil && createNodeGroupOutput.Nodegroup != nil {
-// 	// 							klog.Infof("Initated NodeGroup Launch [%s]", *createNodeGroupOutput.Nodegroup.ClusterName)
-// 	// 							if err := ae.patchStatus(*createNodeGroupOutput.Nodegroup.NodegroupName, string(createNodeGroupOutput.Nodegroup.Status)); err != nil {
-// 	// 								return err
-// 	// 							}
-// 	// 						}
-// 	// 					}
-
-// 	// 					c.ae.eksIC.CreateNodegroup()
-// 	// 				}
-
-// 	// 			}
-// 	// 		}
-// 	// 	}
-// 	// }
-
-// }
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/drain"
+	"github.com/baazhq/baaz/pkg/gitops"
+	"github.com/baazhq/baaz/pkg/placement"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+const (
+	// helmReleaseAnnotation and helmManagedByLabel are set by helm on the
+	// objects of its releases.
+	helmReleaseAnnotation = "meta.helm.sh/release-name"
+	helmManagedByLabel    = "app.kubernetes.io/managed-by"
+)
+
+// reconcileExpansions moves the app types of the tenant whose size changed
+// to the machine pools of their new size, one step per reconcile: the new
+// size is provisioned, the workloads of the tenant are moved, then the old
+// size is retired once no tenant runs on it.
+func (ae *awsEnv) reconcileExpansions(clientset kubernetes.Interface) error {
+	for _, config := range ae.tenant.Spec.TenantConfig {
+		if err := ae.reconcileExpansion(clientset, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ae *awsEnv) reconcileExpansion(clientset kubernetes.Interface, config v1.TenantApplicationConfig) error {
+	log := ctrl.LoggerFrom(ae.ctx).WithValues("appType", config.AppType)
+	applied := ae.tenant.Status.Sizes[config.AppType]
+	migration, migrating := ae.tenant.Status.Migrations[config.AppType]
+
+	switch {
+	case !migrating && applied == config.Size:
+		return nil
+	case !migrating && applied == "":
+		return ae.patchExpansion(config.AppType, config.Size, nil)
+	case !migrating:
+		log.Info("migrating tenant to new size", "from", applied, "to", config.Size)
+		return ae.patchExpansion(config.AppType, applied, &v1.SizeMigration{
+			From:      applied,
+			To:        config.Size,
+			Phase:     v1.MigrationProvisioning,
+			StartedAt: metav1.Now(),
+		})
+	}
+
+	if config.Size != migration.To {
+		switch {
+		case migration.Phase == v1.MigrationFailed:
+			// nothing was moved, the next reconcile migrates from the
+			// applied size again
+			log.Info("cancelling failed tenant migration", "to", migration.To)
+			return ae.patchExpansion(config.AppType, applied, nil)
+		case config.Size == migration.From && migration.Phase != v1.MigrationRetiring:
+			log.Info("rolling back tenant migration", "from", migration.To, "to", migration.From)
+			return ae.patchExpansion(config.AppType, applied, &v1.SizeMigration{
+				From:      migration.To,
+				To:        migration.From,
+				Phase:     v1.MigrationProvisioning,
+				Rollback:  !migration.Rollback,
+				StartedAt: metav1.Now(),
+			})
+		default:
+			message := fmt.Sprintf("size %s is applied once the migration to %s completes", config.Size, migration.To)
+			if migration.Message != message {
+				migration.Message = message
+				if err := ae.patchExpansion(config.AppType, applied, &migration); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	switch migration.Phase {
+	case v1.MigrationProvisioning:
+		return ae.provisionSize(config.AppType, applied, migration)
+	case v1.MigrationMoving:
+		return ae.moveToSize(clientset, config.AppType, applied, migration)
+	case v1.MigrationRetiring:
+		return ae.retireSize(config.AppType, migration)
+	}
+	return nil
+}
+
+// provisionSize waits for the machine pools of the new size to be ready.
+func (ae *awsEnv) provisionSize(appType v1.ApplicationType, applied string, migration v1.SizeMigration) error {
+	tenantsInfra, err := ae.tenantsInfra()
+	if err != nil {
+		return err
+	}
+	if _, ok := tenantsInfra.Spec.TenantSizes[migration.To]; !ok {
+		migration.Phase = v1.MigrationFailed
+		migration.Message = fmt.Sprintf("size %s is not in the tenants infra of dataplane %s", migration.To, ae.dp.Name)
+		ctrl.LoggerFrom(ae.ctx).Info("tenant migration failed", "appType", appType, "reason", migration.Message)
+		return ae.patchExpansion(appType, applied, &migration)
+	}
+
+	if message := ae.sizeNotReady(tenantsInfra, migration.To); message != "" {
+		if migration.Message == message {
+			return nil
+		}
+		migration.Message = message
+		return ae.patchExpansion(appType, applied, &migration)
+	}
+
+	ctrl.LoggerFrom(ae.ctx).Info("tenant size ready, moving workloads", "appType", appType, "size", migration.To)
+	migration.Phase = v1.MigrationMoving
+	migration.Message = ""
+	return ae.patchExpansion(appType, applied, &migration)
+}
+
+// sizeNotReady explains why the machine pools of size are not ready, it is
+// empty once they are.
+func (ae *awsEnv) sizeNotReady(tenantsInfra *v1.TenantsInfra, size string) string {
+	usesKarpenter := ae.dp.UsesKarpenter()
+	for _, name := range placement.ForSize(size, tenantsInfra.Spec.TenantSizes[size], usesKarpenter).Names {
+		if usesKarpenter {
+			if !tenantsInfra.Status.NodePoolStatus[name].Ready {
+				return fmt.Sprintf("waiting for nodepool %s to be ready", name)
+			}
+			continue
+		}
+		if tenantsInfra.Status.NodegroupStatus[name].Status != string(types.NodegroupStatusActive) {
+			return fmt.Sprintf("waiting for nodegroup %s to be active", name)
+		}
+	}
+	return ""
+}
+
+// moveToSize points the workloads of the tenant on the old size to the new
+// size, which rolls their pods, and evicts the pods no rollout replaces.
+// Evictions honor PodDisruptionBudgets. The workloads of helm releases move
+// through the chart values of their app.
+func (ae *awsEnv) moveToSize(clientset kubernetes.Interface, appType v1.ApplicationType, applied string, migration v1.SizeMigration) error {
+	tenantsInfra, err := ae.tenantsInfra()
+	if err != nil {
+		return err
+	}
+	usesKarpenter := ae.dp.UsesKarpenter()
+	from := placement.ForSize(migration.From, tenantsInfra.Spec.TenantSizes[migration.From], usesKarpenter)
+	to := placement.ForSize(migration.To, tenantsInfra.Spec.TenantSizes[migration.To], usesKarpenter)
+
+	onDelete, releases, err := ae.moveWorkloads(clientset, from, to)
+	if err != nil {
+		return err
+	}
+	unmoved, err := ae.moveReleases(releases, from, to)
+	if err != nil {
+		return err
+	}
+	pending, blocked, err := ae.evictFromSize(clientset, from, onDelete)
+	if err != nil {
+		return err
+	}
+	message := ""
+	if len(unmoved) > 0 {
+		message = fmt.Sprintf("releases %s pin size %s outside of the valuesObject of their app, or their app is managed by git", strings.Join(unmoved, ", "), migration.From)
+	}
+
+	if pending == 0 {
+		ctrl.LoggerFrom(ae.ctx).Info("tenant workloads moved", "appType", appType, "size", migration.To)
+		migration.Phase = v1.MigrationRetiring
+		migration.PendingPods = 0
+		migration.BlockedPods = nil
+		migration.Message = ""
+		return ae.patchExpansion(appType, applied, &migration)
+	}
+	if migration.PendingPods == pending && len(migration.BlockedPods) == len(blocked) && migration.Message == message {
+		return nil
+	}
+	migration.PendingPods = pending
+	migration.BlockedPods = blocked
+	migration.Message = message
+	return ae.patchExpansion(appType, applied, &migration)
+}
+
+// moveWorkloads moves the pod templates of the workloads of the tenant from
+// a size to another. It returns the StatefulSets updated on delete, whose
+// pods are only replaced once evicted, and the helm releases of the
+// workloads targeting the size, which are left to helm.
+func (ae *awsEnv) moveWorkloads(clientset kubernetes.Interface, from, to placement.Placement) (map[string]bool, map[string]bool, error) {
+	log := ctrl.LoggerFrom(ae.ctx)
+	namespace := ae.tenant.Name
+	releases := make(map[string]bool)
+	// move tells if the workload is moved here, helm releases are not
+	move := func(obj metav1.Object, spec *corev1.PodSpec) bool {
+		if !from.Targets(spec) {
+			return false
+		}
+		if release, ok := helmRelease(obj); ok {
+			releases[release] = true
+			return false
+		}
+		return placement.Move(spec, from, to)
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ae.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if !move(deployment, &deployment.Spec.Template.Spec) {
+			continue
+		}
+		log.Info("moving workload to new size", "kind", "Deployment", "name", deployment.Name)
+		if _, err := clientset.AppsV1().Deployments(namespace).Update(ae.ctx, deployment, metav1.UpdateOptions{}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	onDelete := make(map[string]bool)
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(ae.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range statefulSets.Items {
+		statefulSet := &statefulSets.Items[i]
+		if statefulSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+			onDelete[statefulSet.Name] = true
+		}
+		if !move(statefulSet, &statefulSet.Spec.Template.Spec) {
+			continue
+		}
+		log.Info("moving workload to new size", "kind", "StatefulSet", "name", statefulSet.Name)
+		if _, err := clientset.AppsV1().StatefulSets(namespace).Update(ae.ctx, statefulSet, metav1.UpdateOptions{}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	daemonSets, err := clientset.AppsV1().DaemonSets(namespace).List(ae.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range daemonSets.Items {
+		daemonSet := &daemonSets.Items[i]
+		if !move(daemonSet, &daemonSet.Spec.Template.Spec) {
+			continue
+		}
+		log.Info("moving workload to new size", "kind", "DaemonSet", "name", daemonSet.Name)
+		if _, err := clientset.AppsV1().DaemonSets(namespace).Update(ae.ctx, daemonSet, metav1.UpdateOptions{}); err != nil {
+			return nil, nil, err
+		}
+	}
+	return onDelete, releases, nil
+}
+
+// helmRelease returns the helm release managing a workload.
+func helmRelease(obj metav1.Object) (string, bool) {
+	release := obj.GetAnnotations()[helmReleaseAnnotation]
+	return release, release != "" && obj.GetLabels()[helmManagedByLabel] == "Helm"
+}
+
+// moveReleases moves the helm releases of the tenant whose workloads target
+// a size to another through the valuesObject of their app, helm rolls the
+// workloads on the upgrade that follows. It returns the releases it can not
+// move, whose workloads target the size through other values.
+func (ae *awsEnv) moveReleases(releases map[string]bool, from, to placement.Placement) ([]string, error) {
+	if len(releases) == 0 {
+		return nil, nil
+	}
+	apps := &v1.ApplicationsList{}
+	if err := ae.client.List(ae.ctx, apps); err != nil {
+		return nil, err
+	}
+
+	unmoved := make(map[string]bool, len(releases))
+	for release := range releases {
+		unmoved[release] = true
+	}
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if app.Spec.Tenant != ae.tenant.Name || app.Spec.Dataplane != ae.dp.Name {
+			continue
+		}
+		if source := gitops.SourceOf(app.Labels); source != "" {
+			// the git source would apply the values of its repository back,
+			// its releases are reported unmoved
+			ctrl.LoggerFrom(ae.ctx).Info("not moving the releases of an app managed by git", "app", app.Name, "gitSource", source)
+			continue
+		}
+
+		patch := client.MergeFrom(app.DeepCopy())
+		changed := false
+		for j := range app.Spec.Applications {
+			appSpec := &app.Spec.Applications[j]
+			release := appSpec.ChartName()
+			if !releases[release] || appSpec.Spec.ValuesObject == nil {
+				continue
+			}
+			values := map[string]interface{}{}
+			if err := json.Unmarshal(appSpec.Spec.ValuesObject.Raw, &values); err != nil {
+				continue
+			}
+			if placement.MoveValues(values, from, to) {
+				raw, err := json.Marshal(values)
+				if err != nil {
+					return nil, err
+				}
+				ctrl.LoggerFrom(ae.ctx).Info("moving release to new size through its values", "release", release, "app", app.Name)
+				appSpec.Spec.ValuesObject = &apiextensionsv1.JSON{Raw: raw}
+				changed = true
+			}
+			if placement.ValuesTarget(values, to) {
+				delete(unmoved, release)
+			}
+		}
+		if changed {
+			if err := ae.client.Patch(ae.ctx, app, patch); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	names := make([]string, 0, len(unmoved))
+	for release := range unmoved {
+		names = append(names, release)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// evictFromSize counts the pods of the tenant still running on the nodes of
+// a size, and evicts the ones left behind by the rollouts: pods without a
+// controller, of jobs or of StatefulSets updated on delete. It returns the
+// pending pods and the ones a PodDisruptionBudget blocks.
+func (ae *awsEnv) evictFromSize(clientset kubernetes.Interface, from placement.Placement, onDelete map[string]bool) (int, []string, error) {
+	if len(from.Names) == 0 {
+		return 0, nil, nil
+	}
+	selector, err := from.Selector()
+	if err != nil {
+		return 0, nil, err
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(ae.ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return 0, nil, err
+	}
+	onSize := make(map[string]bool, len(nodes.Items))
+	for _, node := range nodes.Items {
+		onSize[node.Name] = true
+	}
+
+	pods, err := clientset.CoreV1().Pods(ae.tenant.Name).List(ae.ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	pending := 0
+	var evictable []corev1.Pod
+	for _, pod := range pods.Items {
+		if !onSize[pod.Spec.NodeName] ||
+			pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		pending++
+
+		owner := metav1.GetControllerOf(&pod)
+		switch {
+		case owner == nil:
+		case owner.Kind == "ReplicaSet", owner.Kind == "DaemonSet":
+			continue
+		case owner.Kind == "StatefulSet" && !onDelete[owner.Name]:
+			continue
+		}
+		evictable = append(evictable, pod)
+	}
+	if len(evictable) == 0 {
+		return pending, nil, nil
+	}
+
+	result, err := drain.New(ae.ctx, clientset, drain.Options{}).EvictPods(evictable)
+	if err != nil {
+		return 0, nil, err
+	}
+	return pending, result.Blocked, nil
+}
+
+// retireSize completes the migration. With RetireUnusedSize, it first removes
+// the size moved out of from the tenants infra when no tenant of the
+// dataplane runs on it, which drains and deletes its machine pools.
+func (ae *awsEnv) retireSize(appType v1.ApplicationType, migration v1.SizeMigration) error {
+	log := ctrl.LoggerFrom(ae.ctx).WithValues("appType", appType)
+
+	if ae.tenant.Spec.Migration.RetireUnusedSize {
+		used, err := ae.sizeUsed(appType, migration.From)
+		if err != nil {
+			return err
+		}
+		if !used {
+			tenantsInfra, err := ae.tenantsInfra()
+			if err != nil {
+				return err
+			}
+			_, ok := tenantsInfra.Spec.TenantSizes[migration.From]
+			switch source := gitops.SourceOf(tenantsInfra.Labels); {
+			case ok && source != "":
+				// the git source would apply the size back, it is retired
+				// in its repository
+				log.Info("not retiring the unused tenant size of a tenantsinfra managed by git", "size", migration.From, "gitSource", source)
+			case ok:
+				log.Info("retiring unused tenant size", "size", migration.From)
+				patch := client.MergeFrom(tenantsInfra.DeepCopy())
+				delete(tenantsInfra.Spec.TenantSizes, migration.From)
+				if err := ae.client.Patch(ae.ctx, tenantsInfra, patch); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	log.Info("tenant migrated", "from", migration.From, "to", migration.To, "rollback", migration.Rollback)
+	return ae.patchExpansion(appType, migration.To, nil)
+}
+
+// sizeUsed tells if a tenant of the dataplane, other than the app type of
+// the tenant moving out of it, runs on size or migrates from or to it.
+func (ae *awsEnv) sizeUsed(appType v1.ApplicationType, size string) (bool, error) {
+	tenants := &v1.TenantsList{}
+	if err := ae.client.List(ae.ctx, tenants); err != nil {
+		return false, err
+	}
+
+	for _, tenant := range tenants.Items {
+		if tenant.Spec.DataplaneName != ae.dp.Name {
+			continue
+		}
+		self := tenant.Namespace == ae.tenant.Namespace && tenant.Name == ae.tenant.Name
+		for _, config := range tenant.Spec.TenantConfig {
+			if config.Size == size {
+				return true, nil
+			}
+		}
+		for app, applied := range tenant.Status.Sizes {
+			if applied == size && !(self && app == appType) {
+				return true, nil
+			}
+		}
+		for app, migration := range tenant.Status.Migrations {
+			if self && app == appType {
+				continue
+			}
+			if migration.From == size || migration.To == size {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// tenantsInfra returns the TenantsInfra of the dataplane of the tenant.
+func (ae *awsEnv) tenantsInfra() (*v1.TenantsInfra, error) {
+	list := &v1.TenantsInfraList{}
+	if err := ae.client.List(ae.ctx, list, client.InNamespace(ae.dp.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		if list.Items[i].Spec.Dataplane == ae.dp.Name {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no tenants infra for dataplane %s", ae.dp.Name)
+}
+
+// patchExpansion records the size an app type runs on and its migration, a
+// nil migration ends it.
+func (ae *awsEnv) patchExpansion(appType v1.ApplicationType, size string, migration *v1.SizeMigration) error {
+	_, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.tenant, func(obj client.Object) client.Object {
+		in := obj.(*v1.Tenants)
+		if in.Status.Sizes == nil {
+			in.Status.Sizes = make(map[v1.ApplicationType]string)
+		}
+		in.Status.Sizes[appType] = size
+		if migration == nil {
+			delete(in.Status.Migrations, appType)
+			return in
+		}
+		if in.Status.Migrations == nil {
+			in.Status.Migrations = make(map[v1.ApplicationType]v1.SizeMigration)
+		}
+		in.Status.Migrations[appType] = *migration
+		return in
+	})
+	return err
+}
@@ -8,12 +8,13 @@ import (
 	v1 "github.com/baazhq/baaz/api/v1/types"
 	"github.com/baazhq/baaz/internal/predicates"
 	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/logging"
 	"github.com/baazhq/baaz/pkg/store"
+	"github.com/baazhq/baaz/pkg/tracing"
 	"github.com/baazhq/baaz/pkg/utils"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -37,7 +38,7 @@ type TenantsReconciler struct {
 }
 
 func NewTenantsReconciler(mgr ctrl.Manager, enablePrivate bool, customerName string) *TenantsReconciler {
-	initLogger := ctrl.Log.WithName("controllers").WithName("tenant")
+	initLogger := logging.Logger(logging.Tenant)
 	return &TenantsReconciler{
 		Client:        mgr.GetClient(),
 		Log:           initLogger,
@@ -52,6 +53,7 @@ func NewTenantsReconciler(mgr ctrl.Manager, enablePrivate bool, customerName str
 //+kubebuilder:rbac:groups=baaz.dev,resources=tenants,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=baaz.dev,resources=tenants/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=baaz.dev,resources=tenants/finalizers,verbs=update
+//+kubebuilder:rbac:groups=baaz.dev,resources=tenantsinfras,verbs=get;list;watch;patch
 
 func (r *TenantsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	tenantObj := &v1.Tenants{}
@@ -60,6 +62,15 @@ func (r *TenantsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	ctx, span := tracing.StartReconcile(ctx, "TenantsReconciler", tenantObj)
+	defer span.End()
+
+	ctx, log := logging.WithValues(ctx,
+		logging.KeyCustomer, tenantObj.Namespace,
+		logging.KeyDataplane, tenantObj.Spec.DataplaneName,
+		logging.KeyTenant, tenantObj.Name,
+	)
+
 	dpObj := &v1.DataPlanesList{}
 	err = r.List(ctx, dpObj, &client.ListOptions{})
 	if err != nil {
@@ -73,7 +84,7 @@ func (r *TenantsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
-	klog.Infof("Reconciling Tenants: %s/%s", tenantObj.Namespace, tenantObj.Name)
+	log.V(1).Info("reconciling tenant")
 
 	if tenantObj.DeletionTimestamp != nil {
 		// object is going to be deleted
@@ -114,7 +125,8 @@ func (r *TenantsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}); patchErr != nil {
 			return ctrl.Result{}, patchErr
 		}
-		klog.Errorf("failed to reconcile tenant: reason: %s", err.Error())
+		span.RecordError(err)
+		logging.Error(log, err, "failed to reconcile tenant")
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 	} else {
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
@@ -127,6 +139,7 @@ func (r *TenantsReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1.Tenants{}).
 		WithEventFilter(r.Predicates).
+		WithLogConstructor(logging.RequestLogger(r.Log)).
 		Complete(r)
 }
 
@@ -182,14 +195,18 @@ func (r *TenantsReconciler) reconcileDelete(ae *awsEnv) (ctrl.Result, error) {
 			return ctrl.Result{}, err
 		}
 		if found {
-			klog.Infof("waiting for nodegroup %s to be deleted", ng)
+			ctrl.LoggerFrom(ae.ctx).Info("waiting for nodegroup to be deleted", logging.KeyNodegroup, ng)
 			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 		}
 	}
 
+	if err := ae.eksIC.DeleteTenantRole(ae.ctx, ae.tenant.Name); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// remove our finalizer from the list and update it.
 	controllerutil.RemoveFinalizer(ae.tenant, tenantsFinalizer)
-	klog.Infof("Deleted Tenant [%s]", ae.tenant.GetName())
+	ctrl.LoggerFrom(ae.ctx).Info("deleted tenant")
 	if err := ae.client.Update(ae.ctx, ae.tenant.DeepCopyObject().(*v1.Tenants)); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -2,26 +2,23 @@ package tenantinfra_controller
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"math/rand"
-	"strings"
+	"sort"
 
 	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/aws/aws-sdk-go-v2/service/eks/types"
 	"github.com/aws/aws-sdk-go/aws"
 	v1 "github.com/baazhq/baaz/api/v1/types"
 	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/drain"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metrics"
 	"github.com/baazhq/baaz/pkg/store"
 	"github.com/baazhq/baaz/pkg/utils"
-	core "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/klog/v2"
-	"k8s.io/utils/strings/slices"
-	"sigs.k8s.io/aws-iam-authenticator/pkg/token"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -32,6 +29,13 @@ const (
 	system nodeGroupType = "system"
 )
 
+const (
+	// drainingTaintKey taints the nodegroups being drained before deletion.
+	drainingTaintKey = "baaz.dev/draining"
+	// nodegroupDraining is the nodegroup status while its nodes are drained.
+	nodegroupDraining = "DRAINING"
+)
+
 type awsEnv struct {
 	ctx          context.Context
 	dp           *v1.DataPlanes
@@ -39,13 +43,22 @@ type awsEnv struct {
 	eksIC        eks.Eks
 	client       client.Client
 	store        store.Store
+	// clusterSecurityGroup is looked up once per reconcile for the launch
+	// templates.
+	clusterSecurityGroup string
 }
 
-func getRandomSubnet(dp *v1.DataPlanes) string {
-	subnets := dp.Spec.CloudInfra.AwsCloudInfraConfig.Eks.SubnetIds
+// getSubnets returns the subnets of the dataplane, provisioned by baaz or
+// given in the spec.
+func getSubnets(dp *v1.DataPlanes) []string {
 	if dp.Spec.CloudInfra.ProvisionNetwork {
-		subnets = dp.Status.CloudInfraStatus.SubnetIds
+		return dp.Status.CloudInfraStatus.SubnetIds
 	}
+	return dp.Spec.CloudInfra.AwsCloudInfraConfig.Eks.SubnetIds
+}
+
+func getRandomSubnet(dp *v1.DataPlanes) string {
+	subnets := getSubnets(dp)
 
 	random := rand.Intn(100)
 	return subnets[random%len(subnets)]
@@ -79,13 +92,16 @@ func getNodeGroupSubnet(tenants *v1.TenantsInfra, dp *v1.DataPlanes) string {
 }
 
 func getNodeName(tenantName string, machineSpec v1.MachineSpec) string {
-	nodeName := fmt.Sprintf("%s-%s-%s", tenantName, machineSpec.Name, machineSpec.Size)
-	nodeName = strings.ReplaceAll(nodeName, ".", "-")
+	nodeName, _ := eks.MakeTenantNodegroupNames(tenantName, machineSpec)
 	return nodeName
 }
 
 func (ae *awsEnv) ReconcileInfraTenants() error {
-	klog.Info("Reconciling tenant infra node groups")
+	if ae.dp.UsesKarpenter() {
+		return ae.reconcileNodePools()
+	}
+
+	ctrl.LoggerFrom(ae.ctx).V(1).Info("reconciling tenants infra nodegroups")
 
 	for tenantName, machineSpecs := range ae.tenantsInfra.Spec.TenantSizes {
 
@@ -108,15 +124,21 @@ func (ae *awsEnv) ReconcileInfraTenants() error {
 
 					subnet := getNodeGroupSubnet(ae.tenantsInfra, ae.dp)
 
-					createNodeGroupOutput, err := ae.eksIC.CreateNodegroup(ae.getNodegroupInput(nodeName, *nodeRole.Role.Arn, subnet, &machineSpec))
+					input := ae.getNodegroupInput(nodeName, *nodeRole.Role.Arn, subnet, &machineSpec)
+					if input.LaunchTemplate, err = ae.launchTemplate(nodeName, machineSpec); err != nil {
+						return err
+					}
+
+					createNodeGroupOutput, err := ae.eksIC.CreateNodegroup(input)
 					if err != nil {
 						return err
 					}
 					if createNodeGroupOutput != nil && createNodeGroupOutput.Nodegroup != nil {
-						klog.Infof("Initated NodeGroup Launch [%s]", *createNodeGroupOutput.Nodegroup.NodegroupName)
+						ctrl.LoggerFrom(ae.ctx).Info("initiated nodegroup launch", logging.KeyNodegroup, *createNodeGroupOutput.Nodegroup.NodegroupName)
 						if err := ae.patchStatus(*createNodeGroupOutput.Nodegroup.NodegroupName, &v1.NodegroupStatus{
-							Status: string(createNodeGroupOutput.Nodegroup.Status),
-							Subnet: subnet,
+							Status:      string(createNodeGroupOutput.Nodegroup.Status),
+							Subnet:      subnet,
+							MachinePool: machinePoolKey(tenantName, machineSpec),
 						}); err != nil {
 							return err
 						}
@@ -145,16 +167,20 @@ func (ae *awsEnv) ReconcileInfraTenants() error {
 						input.CapacityType = ""
 						input.ScalingConfig.MinSize = aws.Int32(0)
 						input.ScalingConfig.DesiredSize = aws.Int32(0)
+						if input.LaunchTemplate, err = ae.launchTemplate(dedicatedNodeName, machineSpec); err != nil {
+							return err
+						}
 
 						createNodeGroupOutput, err := ae.eksIC.CreateNodegroup(input)
 						if err != nil {
 							return err
 						}
 						if createNodeGroupOutput != nil && createNodeGroupOutput.Nodegroup != nil {
-							klog.Infof("Initated NodeGroup Launch [%s]", *createNodeGroupOutput.Nodegroup.NodegroupName)
+							ctrl.LoggerFrom(ae.ctx).Info("initiated nodegroup launch", logging.KeyNodegroup, *createNodeGroupOutput.Nodegroup.NodegroupName)
 							if err := ae.patchStatus(*createNodeGroupOutput.Nodegroup.NodegroupName, &v1.NodegroupStatus{
-								Status: string(createNodeGroupOutput.Nodegroup.Status),
-								Subnet: subnet,
+								Status:      string(createNodeGroupOutput.Nodegroup.Status),
+								Subnet:      subnet,
+								MachinePool: machinePoolKey(tenantName, machineSpec),
 							}); err != nil {
 								return err
 							}
@@ -163,24 +189,34 @@ func (ae *awsEnv) ReconcileInfraTenants() error {
 					if describeNodegroupOutput != nil &&
 						describeNodegroupOutput.Nodegroup != nil &&
 						len(describeNodegroupOutput.Nodegroup.Subnets) > 0 {
+						ae.observeNodegroupProvisioning(describeNodegroupOutput.Nodegroup)
 						if err := ae.patchStatus(*describeNodegroupOutput.Nodegroup.NodegroupName, &v1.NodegroupStatus{
-							Status: string(describeNodegroupOutput.Nodegroup.Status),
-							Subnet: describeNodegroupOutput.Nodegroup.Subnets[0],
+							Status:      string(describeNodegroupOutput.Nodegroup.Status),
+							Subnet:      describeNodegroupOutput.Nodegroup.Subnets[0],
+							MachinePool: machinePoolKey(tenantName, machineSpec),
 						}); err != nil {
 							return err
 						}
+						if err := ae.reconcileNodegroup(describeNodegroupOutput.Nodegroup, machineSpec, true); err != nil {
+							return err
+						}
 					}
 				}
 
 				if describeNodegroupOutput != nil &&
 					describeNodegroupOutput.Nodegroup != nil &&
 					len(describeNodegroupOutput.Nodegroup.Subnets) > 0 {
+					ae.observeNodegroupProvisioning(describeNodegroupOutput.Nodegroup)
 					if err := ae.patchStatus(*describeNodegroupOutput.Nodegroup.NodegroupName, &v1.NodegroupStatus{
-						Status: string(describeNodegroupOutput.Nodegroup.Status),
-						Subnet: describeNodegroupOutput.Nodegroup.Subnets[0],
+						Status:      string(describeNodegroupOutput.Nodegroup.Status),
+						Subnet:      describeNodegroupOutput.Nodegroup.Subnets[0],
+						MachinePool: machinePoolKey(tenantName, machineSpec),
 					}); err != nil {
 						return err
 					}
+					if err := ae.reconcileNodegroup(describeNodegroupOutput.Nodegroup, machineSpec, false); err != nil {
+						return err
+					}
 				}
 			}
 		}
@@ -190,153 +226,231 @@ func (ae *awsEnv) ReconcileInfraTenants() error {
 	return ae.cleanUpUnusedNodeGroup()
 }
 
-func (ae *awsEnv) cleanUpUnusedNodeGroup() error {
-	cleanupNodes := make(map[string]bool)
-	for node, status := range ae.tenantsInfra.Status.NodegroupStatus {
-		if status.Status != "ACTIVE" {
-			return errors.New("nodegroups are not in ready state, clean up will happen later")
-		}
-		cleanupNodes[node] = true
+// observeNodegroupProvisioning records the provisioning duration of a
+// nodegroup the first time it is seen active.
+func (ae *awsEnv) observeNodegroupProvisioning(ng *types.Nodegroup) {
+	if ng.Status == types.NodegroupStatusActive &&
+		ae.tenantsInfra.Status.NodegroupStatus[*ng.NodegroupName].Status != string(types.NodegroupStatusActive) &&
+		ng.CreatedAt != nil {
+		metrics.ObserveProvisioning(metrics.ResourceNodegroup, string(ae.dp.Spec.CloudInfra.CloudType), *ng.CreatedAt)
 	}
+}
 
+// cleanUpUnusedNodeGroup removes the nodegroups no longer in the spec once
+// the nodegroups of the spec are active, so the drained pods have somewhere
+// to go. The nodegroups of a machine pool whose instance type changed are
+// replaced blue/green: they are only removed once the nodes of the new
+// nodegroup are ready.
+func (ae *awsEnv) cleanUpUnusedNodeGroup() error {
+	desired := make(map[string]bool)
+	pools := make(map[string]machinePoolNodegroups)
 	for tenantName, machineSpecs := range ae.tenantsInfra.Spec.TenantSizes {
 		for _, machineSpec := range machineSpecs.MachineSpec {
-			nodeName := fmt.Sprintf("%s-%s-%s", tenantName, machineSpec.Name, machineSpec.Size)
-			nodeName = strings.ReplaceAll(nodeName, ".", "-")
-			dedicatedNodeName := fmt.Sprintf("%s-dedicated", nodeName)
-			cleanupNodes[nodeName] = false
-			cleanupNodes[dedicatedNodeName] = false
+			nodeName, dedicatedNodeName := eks.MakeTenantNodegroupNames(tenantName, machineSpec)
+			desired[nodeName] = true
+			desired[dedicatedNodeName] = true
+			pools[machinePoolKey(tenantName, machineSpec)] = machinePoolNodegroups{
+				nodegroup: nodeName,
+				dedicated: dedicatedNodeName,
+				min:       machineSpec.Min,
+			}
 		}
 	}
 
-	for node, cleanup := range cleanupNodes {
-		if cleanup {
-			klog.Infof("going to cleanup & delete nodegroup: %s", node)
-			ngOutput, found, err := ae.eksIC.DescribeNodegroup(node)
-			if err != nil {
-				return err
+	var unused []string
+	active := true
+	for node, status := range ae.tenantsInfra.Status.NodegroupStatus {
+		if desired[node] {
+			if status.Status != string(types.NodegroupStatusActive) {
+				active = false
 			}
+			continue
+		}
+		unused = append(unused, node)
+	}
+	sort.Strings(unused)
 
-			if !found {
-				if err := ae.patchStatus(node, nil); err != nil {
-					return err
-				}
+	if !active && len(unused) > 0 {
+		ctrl.LoggerFrom(ae.ctx).V(1).Info("waiting for nodegroups to be active before removing unused nodegroups")
+	}
+	for _, node := range unused {
+		to, minReady, replaced := ae.replacementOf(node, pools)
+		if !replaced {
+			if !active {
 				continue
 			}
-
-			taintFound := false
-
-			for _, tn := range ngOutput.Nodegroup.Taints {
-				if tn.Effect == types.TaintEffectNoSchedule {
-					taintFound = true
-					break
-				}
-			}
-
-			if !taintFound {
-				taintKey := "key"
-				taintValue := "value"
-				taintEffect := types.TaintEffectNoSchedule
-
-				newTaints := append(ngOutput.Nodegroup.Taints,
-					types.Taint{Key: aws.String(taintKey), Value: aws.String(taintValue), Effect: taintEffect},
-				)
-
-				updateNodegroupInput := &awseks.UpdateNodegroupConfigInput{
-					ClusterName:   aws.String(ae.dp.Spec.CloudInfra.Eks.Name),
-					NodegroupName: aws.String(node),
-					ScalingConfig: ngOutput.Nodegroup.ScalingConfig,
-					Taints: &types.UpdateTaintsPayload{
-						AddOrUpdateTaints: newTaints,
-					},
-				}
-
-				_, err = ae.eksIC.UpdateNodegroup(updateNodegroupInput)
-				if err != nil {
-					return err
-				}
-			}
-
-			clusterOutput, err := ae.eksIC.DescribeEks()
-			if err != nil {
-				return err
-			}
-
-			// Create a Kubernetes client
-			clientset, err := newClientset(clusterOutput.Cluster)
-			if err != nil {
+			if err := ae.removeNodegroup(node); err != nil {
 				return err
 			}
+			continue
+		}
 
-			nodeList, err := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
-			if err != nil {
+		ready := false
+		if active {
+			var err error
+			if ready, err = ae.nodegroupReady(to, minReady); err != nil {
 				return err
 			}
+		}
+		phase := replacementPhase(ae.tenantsInfra.Status.NodegroupStatus[node].Status, ready)
+		if err := ae.patchReplacement(node, to, phase); err != nil {
+			return err
+		}
+		if !ready {
+			continue
+		}
+		if err := ae.removeNodegroup(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			k8sNodeNames := make([]string, 0)
-
-			for _, n := range nodeList.Items {
-				if n.Labels["eks.amazonaws.com/nodegroup"] == node {
-					k8sNodeNames = append(k8sNodeNames, n.Name)
-				}
-			}
+// removeNodegroup taints and drains the nodes of a nodegroup, then deletes
+// it. It runs one step per reconcile and drops the nodegroup from the status
+// once eks has deleted it.
+func (ae *awsEnv) removeNodegroup(name string) error {
+	log := ctrl.LoggerFrom(ae.ctx).WithValues(logging.KeyNodegroup, name)
 
-			// Now you can use 'clientset' to interact with the Kubernetes API
-			// For example, you can list pods in the cluster
-			pods, err := clientset.CoreV1().Pods(core.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
-			if err != nil {
-				return err
-			}
+	ngOutput, found, err := ae.eksIC.DescribeNodegroup(name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		log.Info("nodegroup deleted")
+		if err := ae.eksIC.DeleteLaunchTemplate(ae.ctx, name); err != nil {
+			return err
+		}
+		return ae.patchStatus(name, nil)
+	}
 
-			for _, pod := range pods.Items {
-				if slices.Contains(k8sNodeNames, pod.Spec.NodeName) {
-					if err := clientset.CoreV1().Pods(pod.Namespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{}); err != nil {
-						return err
-					}
-				}
-			}
+	ng := ngOutput.Nodegroup
+	if ng.Status == types.NodegroupStatusDeleting {
+		log.V(1).Info("waiting for nodegroup to be deleted")
+		return nil
+	}
 
-			_, err = ae.eksIC.DeleteNodeGroup(node)
-			if err != nil {
-				return err
-			}
-			if err := ae.patchStatus(node, nil); err != nil {
-				return err
-			}
+	// keep the pods off the nodes the nodegroup launches while it drains
+	if !hasTaint(ng.Taints, drainingTaintKey) {
+		if ng.Status != types.NodegroupStatusActive {
+			log.V(1).Info("waiting for nodegroup to be active", "status", ng.Status)
+			return nil
+		}
+		log.Info("tainting nodegroup before drain")
+		if _, err := ae.eksIC.UpdateNodegroup(&awseks.UpdateNodegroupConfigInput{
+			ClusterName:   aws.String(ae.dp.Spec.CloudInfra.Eks.Name),
+			NodegroupName: aws.String(name),
+			Taints: &types.UpdateTaintsPayload{
+				AddOrUpdateTaints: []types.Taint{{
+					Key:    aws.String(drainingTaintKey),
+					Value:  aws.String("true"),
+					Effect: types.TaintEffectNoSchedule,
+				}},
+			},
+		}); err != nil {
+			return err
 		}
 	}
-	return nil
-}
 
-func newClientset(cluster *types.Cluster) (*kubernetes.Clientset, error) {
-	gen, err := token.NewGenerator(true, false)
+	drained, err := ae.drainNodegroup(name)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	opts := &token.GetTokenOptions{
-		ClusterID: aws.StringValue(cluster.Name),
+	// eks rejects the deletion while the taint is applied
+	if !drained || ng.Status != types.NodegroupStatusActive {
+		return nil
 	}
-	tok, err := gen.GetWithOptions(opts)
-	if err != nil {
-		return nil, err
+
+	log.Info("deleting drained nodegroup")
+	if _, err := ae.eksIC.DeleteNodeGroup(name); err != nil {
+		return err
 	}
-	ca, err := base64.StdEncoding.DecodeString(aws.StringValue(cluster.CertificateAuthority.Data))
+	return ae.patchNodegroupPhase(name, string(types.NodegroupStatusDeleting))
+}
+
+// drainNodegroup runs a drain pass on the nodes of a nodegroup and reports
+// the progress of each node in the status. It tells if every node is drained
+// or ran past the drain timeout.
+func (ae *awsEnv) drainNodegroup(name string) (bool, error) {
+	log := ctrl.LoggerFrom(ae.ctx).WithValues(logging.KeyNodegroup, name)
+
+	clientset, err := ae.eksIC.GetEksClientSet()
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	clientset, err := kubernetes.NewForConfig(
-		&rest.Config{
-			Host:        aws.StringValue(cluster.Endpoint),
-			BearerToken: tok.Token,
-			TLSClientConfig: rest.TLSClientConfig{
-				CAData: ca,
-			},
-		},
-	)
+	drainer := drain.New(ae.ctx, clientset, drainOptions(ae.tenantsInfra.Spec.Drain))
+
+	nodes, err := drainer.NodegroupNodes(ae.ctx, name)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	return clientset, nil
+
+	previous := ae.tenantsInfra.Status.NodegroupStatus[name].Nodes
+	progress := make(map[string]v1.NodeDrainStatus, len(nodes))
+	done := true
+	for _, node := range nodes {
+		status := previous[node]
+		if status.Phase == v1.NodeDrained || status.Phase == v1.NodeTimedOut {
+			progress[node] = status
+			continue
+		}
+		if status.StartedAt.IsZero() {
+			log.Info("draining node", logging.KeyNode, node)
+			status.StartedAt = metav1.Now()
+		}
+
+		result, err := drainer.DrainNode(ae.ctx, node)
+		if err != nil {
+			return false, err
+		}
+		status.PendingPods = result.Pending
+		status.BlockedPods = result.Blocked
+
+		switch {
+		case result.Drained():
+			log.Info("drained node", logging.KeyNode, node)
+			status.Phase = v1.NodeDrained
+		case drainer.TimedOut(status.StartedAt.Time):
+			log.Info("node drain timed out, remaining pods are terminated with the nodegroup",
+				logging.KeyNode, node, "pending", result.Pending, "blocked", result.Blocked)
+			status.Phase = v1.NodeTimedOut
+		default:
+			log.V(1).Info("node draining", logging.KeyNode, node, "pending", result.Pending, "blocked", result.Blocked)
+			status.Phase = v1.NodeDraining
+			done = false
+		}
+		progress[node] = status
+	}
+
+	_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.tenantsInfra, func(obj client.Object) client.Object {
+		in := obj.(*v1.TenantsInfra)
+		if in.Status.NodegroupStatus == nil {
+			in.Status.NodegroupStatus = make(map[string]v1.NodegroupStatus)
+		}
+		status := in.Status.NodegroupStatus[name]
+		status.Status = nodegroupDraining
+		status.Nodes = progress
+		in.Status.NodegroupStatus[name] = status
+		return in
+	})
+	return done, err
+}
+
+func drainOptions(policy v1.DrainPolicy) drain.Options {
+	opts := drain.Options{GracePeriodSeconds: policy.GracePeriodSeconds}
+	if policy.Timeout != nil {
+		opts.Timeout = policy.Timeout.Duration
+	}
+	return opts
+}
+
+func hasTaint(taints []types.Taint, key string) bool {
+	for _, taint := range taints {
+		if aws.StringValue(taint.Key) == key {
+			return true
+		}
+	}
+	return false
 }
 
 // func (ae *awsEnv) getNodeSpecForTenantSize(tenantConfig v1.TenantApplicationConfig) (*[]v1.MachineSpec, error) {
@@ -384,7 +498,7 @@ func (ae *awsEnv) getNodegroupInput(nodeName, roleArn, subnet string, machineSpe
 		NodeRole:           aws.String(roleArn),
 		NodegroupName:      aws.String(nodeName),
 		Subnets:            []string{subnet},
-		AmiType:            "",
+		AmiType:            eks.AmiType(*machineSpec),
 		CapacityType:       capacityType,
 		ClientRequestToken: nil,
 		DiskSize:           nil,
@@ -426,6 +540,7 @@ func (ae *awsEnv) patchStatus(name string, status *v1.NodegroupStatus) error {
 		}
 		if status == nil {
 			delete(in.Status.NodegroupStatus, name)
+			delete(in.Status.Replacements, name)
 			return in
 		}
 		in.Status.NodegroupStatus[name] = *status
@@ -433,3 +548,19 @@ func (ae *awsEnv) patchStatus(name string, status *v1.NodegroupStatus) error {
 	})
 	return err
 }
+
+// patchNodegroupPhase sets the status of a nodegroup, keeping its subnet and
+// drain progress.
+func (ae *awsEnv) patchNodegroupPhase(name, phase string) error {
+	_, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.tenantsInfra, func(obj client.Object) client.Object {
+		in := obj.(*v1.TenantsInfra)
+		if in.Status.NodegroupStatus == nil {
+			in.Status.NodegroupStatus = make(map[string]v1.NodegroupStatus)
+		}
+		status := in.Status.NodegroupStatus[name]
+		status.Status = phase
+		in.Status.NodegroupStatus[name] = status
+		return in
+	})
+	return err
+}
@@ -0,0 +1,152 @@
+package tenantinfra_controller
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/karpenter"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+const fieldManager = "baaz"
+
+// reconcileNodePools applies a karpenter NodePool and EC2NodeClass per
+// machine pool, deletes the ones removed from the spec and reports the
+// readiness of the NodePools. Karpenter drains the nodes of the deleted
+// NodePools honoring PodDisruptionBudgets.
+func (ae *awsEnv) reconcileNodePools() error {
+	log := ctrl.LoggerFrom(ae.ctx)
+	log.V(1).Info("reconciling tenants infra nodepools")
+
+	karpenterStatus := ae.dp.Status.Karpenter
+	if karpenterStatus == nil || karpenterStatus.Version == "" {
+		log.Info("waiting for karpenter to be installed")
+		return nil
+	}
+
+	dynamicClient, err := ae.dynamicClient()
+	if err != nil {
+		return err
+	}
+	cluster, err := ae.eksIC.DescribeEks()
+	if err != nil {
+		return err
+	}
+	nodeClass := karpenter.NodeClass{
+		InstanceProfile:  karpenterStatus.InstanceProfile,
+		SubnetIDs:        getSubnets(ae.dp),
+		SecurityGroupIDs: []string{aws.StringValue(cluster.Cluster.ResourcesVpcConfig.ClusterSecurityGroupId)},
+	}
+
+	desired := make(map[string]bool)
+	status := make(map[string]v1.NodePoolStatus)
+	for tenantName, machineSpecs := range ae.tenantsInfra.Spec.TenantSizes {
+		for _, machineSpec := range machineSpecs.MachineSpec {
+			name := eks.MakeTenantMachinePoolName(tenantName, machineSpec)
+			desired[name] = true
+
+			if _, err := ae.apply(dynamicClient, karpenter.EC2NodeClassGVR,
+				karpenter.EC2NodeClass(name, ae.tenantsInfra.Name, nodeClass, machineSpec)); err != nil {
+				return err
+			}
+			nodePool, err := ae.apply(dynamicClient, karpenter.NodePoolGVR,
+				karpenter.NodePool(name, ae.tenantsInfra.Name, machineSpec, nodePoolTaints(name)))
+			if err != nil {
+				return err
+			}
+
+			ready, message := karpenter.Readiness(nodePool)
+			if ready != ae.tenantsInfra.Status.NodePoolStatus[name].Ready {
+				log.Info("nodepool readiness changed", "nodePool", name, "ready", ready, "message", message)
+			}
+			status[name] = v1.NodePoolStatus{
+				Ready:   ready,
+				Nodes:   karpenter.Nodes(nodePool),
+				Message: message,
+			}
+		}
+	}
+
+	if _, err := ae.deleteNodePools(dynamicClient, desired); err != nil {
+		return err
+	}
+
+	_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.tenantsInfra, func(obj client.Object) client.Object {
+		in := obj.(*v1.TenantsInfra)
+		in.Status.NodePoolStatus = status
+		return in
+	})
+	return err
+}
+
+// deleteNodePools deletes the NodePools and EC2NodeClasses of the tenants
+// infra which are not desired. It returns the number of NodePools left,
+// including the ones karpenter is still draining.
+func (ae *awsEnv) deleteNodePools(dynamicClient dynamic.Interface, desired map[string]bool) (int, error) {
+	selector := metav1.ListOptions{LabelSelector: karpenter.TenantsInfraLabel + "=" + ae.tenantsInfra.Name}
+
+	remaining := 0
+	for _, gvr := range []schema.GroupVersionResource{karpenter.NodePoolGVR, karpenter.EC2NodeClassGVR} {
+		list, err := dynamicClient.Resource(gvr).List(ae.ctx, selector)
+		if err != nil {
+			return 0, err
+		}
+		for _, item := range list.Items {
+			if desired[item.GetName()] {
+				continue
+			}
+			if gvr == karpenter.NodePoolGVR {
+				remaining++
+			}
+			if item.GetDeletionTimestamp() != nil {
+				continue
+			}
+			ctrl.LoggerFrom(ae.ctx).Info("deleting karpenter resource", "kind", item.GetKind(), "name", item.GetName())
+			if err := dynamicClient.Resource(gvr).Delete(ae.ctx, item.GetName(), metav1.DeleteOptions{}); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return remaining, nil
+}
+
+// apply server side applies obj and returns the object stored by the
+// cluster, with its status.
+func (ae *awsEnv) apply(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	applied, err := dynamicClient.Resource(gvr).Apply(ae.ctx, obj.GetName(), obj, metav1.ApplyOptions{
+		FieldManager: fieldManager,
+		Force:        true,
+	})
+	if err != nil {
+		logging.Error(ctrl.LoggerFrom(ae.ctx), err, "failed to apply karpenter resource", "kind", obj.GetKind(), "name", obj.GetName())
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (ae *awsEnv) dynamicClient() (dynamic.Interface, error) {
+	restConfig, err := ae.eksIC.GetRestConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(restConfig)
+}
+
+// nodePoolTaints keeps the workloads of other machine pools off the nodes,
+// like the taints of the managed nodegroups.
+func nodePoolTaints(name string) []corev1.Taint {
+	return []corev1.Taint{{
+		Key:    "application",
+		Value:  name,
+		Effect: corev1.TaintEffectNoSchedule,
+	}}
+}
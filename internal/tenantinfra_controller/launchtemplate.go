@@ -0,0 +1,77 @@
+package tenantinfra_controller
+
+import (
+	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go/aws"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/logging"
+)
+
+// launchTemplate ensures the launch template of the nodegroup name is
+// rendered from the machine pool and returns the version to run. Machine
+// pools without launch template settings return nil.
+func (ae *awsEnv) launchTemplate(name string, machineSpec v1.MachineSpec) (*types.LaunchTemplateSpecification, error) {
+	if !machineSpec.UsesLaunchTemplate() {
+		return nil, nil
+	}
+
+	if ae.clusterSecurityGroup == "" {
+		cluster, err := ae.eksIC.DescribeEks()
+		if err != nil {
+			return nil, err
+		}
+		ae.clusterSecurityGroup = aws.StringValue(cluster.Cluster.ResourcesVpcConfig.ClusterSecurityGroupId)
+	}
+
+	data, hash, err := eks.LaunchTemplateData(machineSpec, ae.clusterSecurityGroup)
+	if err != nil {
+		return nil, err
+	}
+	return ae.eksIC.EnsureLaunchTemplate(ae.ctx, name, data, hash)
+}
+
+// rollLaunchTemplate moves a nodegroup to the latest version of its launch
+// template once the machine pool changed. Eks replaces the nodes honoring
+// PodDisruptionBudgets. The roll waits for cluster upgrades, which update
+// the same nodegroups.
+func (ae *awsEnv) rollLaunchTemplate(ng *types.Nodegroup, machineSpec v1.MachineSpec) error {
+	if !machineSpec.UsesLaunchTemplate() {
+		return nil
+	}
+	name := aws.StringValue(ng.NodegroupName)
+	log := ctrl.LoggerFrom(ae.ctx).WithValues(logging.KeyNodegroup, name)
+
+	// eks does not add a launch template to an existing nodegroup, the
+	// nodegroups of pools using one are named apart and replace the others
+	if ng.LaunchTemplate == nil {
+		log.Info("nodegroup was created without launch template, waiting for its replacement")
+		return nil
+	}
+
+	spec, err := ae.launchTemplate(name, machineSpec)
+	if err != nil {
+		return err
+	}
+	if aws.StringValue(ng.LaunchTemplate.Version) == aws.StringValue(spec.Version) {
+		return nil
+	}
+	if ng.Status != types.NodegroupStatusActive || ae.dp.Status.Upgrade != nil {
+		log.V(1).Info("waiting to roll nodegroup to new launch template version", "status", ng.Status)
+		return nil
+	}
+
+	log.Info("rolling nodegroup to new launch template version",
+		"from", aws.StringValue(ng.LaunchTemplate.Version), "to", aws.StringValue(spec.Version))
+	if _, err := ae.eksIC.UpdateNodegroupVersion(&awseks.UpdateNodegroupVersionInput{
+		ClusterName:    aws.String(ae.dp.Spec.CloudInfra.Eks.Name),
+		NodegroupName:  aws.String(name),
+		LaunchTemplate: spec,
+	}); err != nil {
+		return err
+	}
+	return ae.patchNodegroupPhase(name, string(types.NodegroupStatusUpdating))
+}
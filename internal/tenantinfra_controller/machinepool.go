@@ -0,0 +1,239 @@
+package tenantinfra_controller
+
+import (
+	"sort"
+	"strings"
+
+	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go/aws"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/drain"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/utils"
+)
+
+const dedicatedSuffix = "-dedicated"
+
+// machinePoolNodegroups are the nodegroups of a machine pool of the spec.
+type machinePoolNodegroups struct {
+	nodegroup string
+	dedicated string
+	min       int32
+}
+
+// machinePoolKey identifies a machine pool across changes of its instance
+// type, which names its nodegroups.
+func machinePoolKey(tenantName string, machineSpec v1.MachineSpec) string {
+	return tenantName + "/" + machineSpec.Name
+}
+
+// reconcileNodegroup applies the changes of a machine pool to its active
+// nodegroup in place, one update at a time as eks rejects concurrent
+// updates of a nodegroup. The changes wait for the upgrade of the dataplane,
+// which updates the nodegroups itself.
+func (ae *awsEnv) reconcileNodegroup(ng *types.Nodegroup, machineSpec v1.MachineSpec, dedicated bool) error {
+	if ng.Status != types.NodegroupStatusActive {
+		return nil
+	}
+	if ae.dp.Status.Upgrade != nil {
+		ctrl.LoggerFrom(ae.ctx).V(1).Info("waiting for the dataplane upgrade to update nodegroup", logging.KeyNodegroup, aws.StringValue(ng.NodegroupName))
+		return nil
+	}
+
+	input := nodegroupConfigUpdate(ng, machineSpec, dedicated)
+	if input == nil {
+		return ae.rollLaunchTemplate(ng, machineSpec)
+	}
+
+	name := aws.StringValue(ng.NodegroupName)
+	log := ctrl.LoggerFrom(ae.ctx).WithValues(logging.KeyNodegroup, name)
+	if input.ScalingConfig != nil {
+		log.Info("scaling nodegroup",
+			"min", aws.Int32Value(input.ScalingConfig.MinSize),
+			"max", aws.Int32Value(input.ScalingConfig.MaxSize),
+			"desired", aws.Int32Value(input.ScalingConfig.DesiredSize))
+	}
+	if input.Labels != nil || input.Taints != nil {
+		log.Info("updating nodegroup labels and taints")
+	}
+
+	input.ClusterName = aws.String(ae.dp.Spec.CloudInfra.Eks.Name)
+	input.NodegroupName = ng.NodegroupName
+	if _, err := ae.eksIC.UpdateNodegroup(input); err != nil {
+		return err
+	}
+	return ae.patchNodegroupPhase(name, string(types.NodegroupStatusUpdating))
+}
+
+// nodegroupConfigUpdate returns the update moving the scaling config, labels
+// and taints of a nodegroup to its machine pool, nil when they match. The
+// dedicated on-demand nodegroup of a spot pool scales from zero.
+func nodegroupConfigUpdate(ng *types.Nodegroup, machineSpec v1.MachineSpec, dedicated bool) *awseks.UpdateNodegroupConfigInput {
+	input := &awseks.UpdateNodegroupConfigInput{}
+
+	minSize, maxSize := machineSpec.Min, machineSpec.Max
+	if dedicated {
+		minSize = 0
+	}
+	if sc := ng.ScalingConfig; sc != nil &&
+		(aws.Int32Value(sc.MinSize) != minSize || aws.Int32Value(sc.MaxSize) != maxSize) {
+		desiredSize := aws.Int32Value(sc.DesiredSize)
+		if desiredSize < minSize {
+			desiredSize = minSize
+		}
+		if desiredSize > maxSize {
+			desiredSize = maxSize
+		}
+		input.ScalingConfig = &types.NodegroupScalingConfig{
+			MinSize:     aws.Int32(minSize),
+			MaxSize:     aws.Int32(maxSize),
+			DesiredSize: aws.Int32(desiredSize),
+		}
+	}
+
+	labels := &types.UpdateLabelsPayload{}
+	for key, value := range machineSpec.NodeLabels {
+		if current, ok := ng.Labels[key]; !ok || current != value {
+			if labels.AddOrUpdateLabels == nil {
+				labels.AddOrUpdateLabels = make(map[string]string)
+			}
+			labels.AddOrUpdateLabels[key] = value
+		}
+	}
+	for key := range ng.Labels {
+		if _, ok := machineSpec.NodeLabels[key]; !ok {
+			labels.RemoveLabels = append(labels.RemoveLabels, key)
+		}
+	}
+	sort.Strings(labels.RemoveLabels)
+	if len(labels.AddOrUpdateLabels) > 0 || len(labels.RemoveLabels) > 0 {
+		input.Labels = labels
+	}
+
+	// the draining taint belongs to the removal of the nodegroup
+	taints := &types.UpdateTaintsPayload{}
+	desired := *makeTaints(aws.StringValue(ng.NodegroupName))
+	for _, taint := range desired {
+		if !containsTaint(ng.Taints, taint) {
+			taints.AddOrUpdateTaints = append(taints.AddOrUpdateTaints, taint)
+		}
+	}
+	for _, taint := range ng.Taints {
+		if aws.StringValue(taint.Key) != drainingTaintKey && !containsTaint(desired, taint) {
+			taints.RemoveTaints = append(taints.RemoveTaints, taint)
+		}
+	}
+	if len(taints.AddOrUpdateTaints) > 0 || len(taints.RemoveTaints) > 0 {
+		input.Taints = taints
+	}
+
+	if input.ScalingConfig == nil && input.Labels == nil && input.Taints == nil {
+		return nil
+	}
+	return input
+}
+
+func containsTaint(taints []types.Taint, taint types.Taint) bool {
+	for _, t := range taints {
+		if aws.StringValue(t.Key) == aws.StringValue(taint.Key) &&
+			aws.StringValue(t.Value) == aws.StringValue(taint.Value) &&
+			t.Effect == taint.Effect {
+			return true
+		}
+	}
+	return false
+}
+
+// replacementOf returns the nodegroup replacing an unused nodegroup whose
+// machine pool is still in the spec, and the number of its nodes to wait for.
+func (ae *awsEnv) replacementOf(node string, pools map[string]machinePoolNodegroups) (string, int32, bool) {
+	pool, ok := pools[ae.tenantsInfra.Status.NodegroupStatus[node].MachinePool]
+	if !ok {
+		return "", 0, false
+	}
+	to, minReady := pool.nodegroup, pool.min
+	if strings.HasSuffix(node, dedicatedSuffix) {
+		to, minReady = pool.dedicated, 0
+	}
+	if _, ok := ae.tenantsInfra.Status.NodegroupStatus[to]; !ok {
+		return "", 0, false
+	}
+	return to, minReady, true
+}
+
+// nodegroupReady tells if a nodegroup is active with at least minReady
+// ready nodes.
+func (ae *awsEnv) nodegroupReady(name string, minReady int32) (bool, error) {
+	if ae.tenantsInfra.Status.NodegroupStatus[name].Status != string(types.NodegroupStatusActive) {
+		return false, nil
+	}
+	if minReady == 0 {
+		return true, nil
+	}
+
+	clientset, err := ae.eksIC.GetEksClientSet()
+	if err != nil {
+		return false, err
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(ae.ctx, metav1.ListOptions{
+		LabelSelector: drain.NodegroupLabel + "=" + name,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var ready int32
+	for _, node := range nodes.Items {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+				ready++
+			}
+		}
+	}
+	return ready >= minReady, nil
+}
+
+func replacementPhase(status string, ready bool) v1.ReplacementPhase {
+	switch {
+	case !ready:
+		return v1.ReplacementProvisioning
+	case status == string(types.NodegroupStatusDeleting):
+		return v1.ReplacementDeleting
+	default:
+		return v1.ReplacementDraining
+	}
+}
+
+// patchReplacement reports the progress of the replacement of the nodegroup
+// from. The entry is dropped with the status of the nodegroup once deleted.
+func (ae *awsEnv) patchReplacement(from, to string, phase v1.ReplacementPhase) error {
+	current, ok := ae.tenantsInfra.Status.Replacements[from]
+	if ok && current.To == to && current.Phase == phase {
+		return nil
+	}
+	ctrl.LoggerFrom(ae.ctx).Info("replacing nodegroup", logging.KeyNodegroup, from, "to", to, "phase", phase)
+
+	startedAt := metav1.Now()
+	if ok && current.To == to {
+		startedAt = current.StartedAt
+	}
+	_, _, err := utils.PatchStatus(ae.ctx, ae.client, ae.tenantsInfra, func(obj client.Object) client.Object {
+		in := obj.(*v1.TenantsInfra)
+		if in.Status.Replacements == nil {
+			in.Status.Replacements = make(map[string]v1.NodegroupReplacement)
+		}
+		in.Status.Replacements[from] = v1.NodegroupReplacement{
+			To:        to,
+			Phase:     phase,
+			StartedAt: startedAt,
+		}
+		return in
+	})
+	return err
+}
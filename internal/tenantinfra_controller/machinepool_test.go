@@ -0,0 +1,130 @@
+package tenantinfra_controller
+
+import (
+	"reflect"
+	"testing"
+
+	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go/aws"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func taint(key, value string) types.Taint {
+	return types.Taint{Key: aws.String(key), Value: aws.String(value), Effect: types.TaintEffectNoSchedule}
+}
+
+func TestNodegroupConfigUpdate(t *testing.T) {
+	const name = "small-app-m5-large"
+	nodegroup := func(min, max, desired int32, labels map[string]string, taints ...types.Taint) *types.Nodegroup {
+		return &types.Nodegroup{
+			NodegroupName: aws.String(name),
+			ScalingConfig: &types.NodegroupScalingConfig{
+				MinSize:     aws.Int32(min),
+				MaxSize:     aws.Int32(max),
+				DesiredSize: aws.Int32(desired),
+			},
+			Labels: labels,
+			Taints: taints,
+		}
+	}
+	machineSpec := v1.MachineSpec{Name: "app", Size: "m5.large", Min: 1, Max: 3, NodeLabels: map[string]string{"tier": "app"}}
+	applicationTaint := taint("application", name)
+
+	for _, tc := range []struct {
+		name      string
+		nodegroup *types.Nodegroup
+		dedicated bool
+		want      *awseks.UpdateNodegroupConfigInput
+	}{
+		{
+			name:      "in sync",
+			nodegroup: nodegroup(1, 3, 2, map[string]string{"tier": "app"}, applicationTaint),
+		},
+		{
+			name:      "scaled down below desired",
+			nodegroup: nodegroup(1, 5, 4, map[string]string{"tier": "app"}, applicationTaint),
+			want: &awseks.UpdateNodegroupConfigInput{ScalingConfig: &types.NodegroupScalingConfig{
+				MinSize: aws.Int32(1), MaxSize: aws.Int32(3), DesiredSize: aws.Int32(3),
+			}},
+		},
+		{
+			name:      "scaled up above desired",
+			nodegroup: nodegroup(0, 3, 0, map[string]string{"tier": "app"}, applicationTaint),
+			want: &awseks.UpdateNodegroupConfigInput{ScalingConfig: &types.NodegroupScalingConfig{
+				MinSize: aws.Int32(1), MaxSize: aws.Int32(3), DesiredSize: aws.Int32(1),
+			}},
+		},
+		{
+			name:      "dedicated scales from zero",
+			nodegroup: nodegroup(0, 3, 0, map[string]string{"tier": "app"}, applicationTaint),
+			dedicated: true,
+		},
+		{
+			name:      "labels changed",
+			nodegroup: nodegroup(1, 3, 1, map[string]string{"tier": "web", "old": "true"}, applicationTaint),
+			want: &awseks.UpdateNodegroupConfigInput{Labels: &types.UpdateLabelsPayload{
+				AddOrUpdateLabels: map[string]string{"tier": "app"},
+				RemoveLabels:      []string{"old"},
+			}},
+		},
+		{
+			name:      "foreign taint removed, draining taint kept",
+			nodegroup: nodegroup(1, 3, 1, map[string]string{"tier": "app"}, applicationTaint, taint("team", "a"), taint(drainingTaintKey, "true")),
+			want: &awseks.UpdateNodegroupConfigInput{Taints: &types.UpdateTaintsPayload{
+				RemoveTaints: []types.Taint{taint("team", "a")},
+			}},
+		},
+		{
+			name:      "application taint missing",
+			nodegroup: nodegroup(1, 3, 1, map[string]string{"tier": "app"}),
+			want: &awseks.UpdateNodegroupConfigInput{Taints: &types.UpdateTaintsPayload{
+				AddOrUpdateTaints: []types.Taint{applicationTaint},
+			}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := nodegroupConfigUpdate(tc.nodegroup, machineSpec, tc.dedicated)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, expected %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReplacementPhase(t *testing.T) {
+	for _, tc := range []struct {
+		status string
+		ready  bool
+		want   v1.ReplacementPhase
+	}{
+		{string(types.NodegroupStatusActive), false, v1.ReplacementProvisioning},
+		{string(types.NodegroupStatusDeleting), false, v1.ReplacementProvisioning},
+		{string(types.NodegroupStatusActive), true, v1.ReplacementDraining},
+		{nodegroupDraining, true, v1.ReplacementDraining},
+		{string(types.NodegroupStatusDeleting), true, v1.ReplacementDeleting},
+	} {
+		if got := replacementPhase(tc.status, tc.ready); got != tc.want {
+			t.Errorf("replacementPhase(%s, %v) = %s, expected %s", tc.status, tc.ready, got, tc.want)
+		}
+	}
+}
+
+func TestContainsTaint(t *testing.T) {
+	taints := []types.Taint{taint("application", "small"), taint(drainingTaintKey, "true")}
+	for _, tc := range []struct {
+		taint types.Taint
+		want  bool
+	}{
+		{taint("application", "small"), true},
+		{taint("application", "large"), false},
+		{taint("team", "small"), false},
+		{types.Taint{Key: aws.String("application"), Value: aws.String("small"), Effect: types.TaintEffectNoExecute}, false},
+	} {
+		if got := containsTaint(taints, tc.taint); got != tc.want {
+			t.Errorf("containsTaint(%s=%s:%s) = %v, expected %v",
+				aws.StringValue(tc.taint.Key), aws.StringValue(tc.taint.Value), tc.taint.Effect, got, tc.want)
+		}
+	}
+}
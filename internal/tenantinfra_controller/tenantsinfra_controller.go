@@ -8,13 +8,14 @@ import (
 	v1 "github.com/baazhq/baaz/api/v1/types"
 	"github.com/baazhq/baaz/internal/predicates"
 	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/logging"
 	"github.com/baazhq/baaz/pkg/store"
+	"github.com/baazhq/baaz/pkg/tracing"
 	"github.com/baazhq/baaz/pkg/utils"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -38,7 +39,7 @@ type TenantsInfraReconciler struct {
 }
 
 func NewTenantsInfraReconciler(mgr ctrl.Manager, enablePrivate bool, customerName string) *TenantsInfraReconciler {
-	initLogger := ctrl.Log.WithName("controllers").WithName("tenant_infra")
+	initLogger := logging.Logger(logging.TenantsInfra)
 	return &TenantsInfraReconciler{
 		Client:        mgr.GetClient(),
 		Log:           initLogger,
@@ -62,13 +63,22 @@ func (r *TenantsInfraReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	ctx, span := tracing.StartReconcile(ctx, "TenantsInfraReconciler", tenantInfraObj)
+	defer span.End()
+
+	ctx, log := logging.WithValues(ctx,
+		logging.KeyCustomer, tenantInfraObj.Namespace,
+		logging.KeyDataplane, tenantInfraObj.Spec.Dataplane,
+		logging.KeyTenantsInfra, tenantInfraObj.Name,
+	)
+
 	dataplane := &v1.DataPlanes{}
 	err = r.Get(ctx, k8stypes.NamespacedName{Name: tenantInfraObj.Spec.Dataplane, Namespace: req.Namespace}, dataplane)
 	if err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	klog.Infof("Reconciling Tenants Infra Objects: %s/%s", tenantInfraObj.Namespace, tenantInfraObj.Name)
+	log.V(1).Info("reconciling tenants infra")
 
 	if tenantInfraObj.DeletionTimestamp != nil {
 		// object is going to be deleted
@@ -110,7 +120,8 @@ func (r *TenantsInfraReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}); patchErr != nil {
 			return ctrl.Result{}, patchErr
 		}
-		klog.Errorf("failed to reconcile tenant: reason: %s", err.Error())
+		span.RecordError(err)
+		logging.Error(log, err, "failed to reconcile tenants infra")
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 	} else {
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
@@ -123,6 +134,7 @@ func (r *TenantsInfraReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1.TenantsInfra{}).
 		WithEventFilter(r.Predicates).
+		WithLogConstructor(logging.RequestLogger(r.Log)).
 		Complete(r)
 }
 
@@ -152,25 +164,41 @@ func (r *TenantsInfraReconciler) reconcileDelete(ae *awsEnv) (ctrl.Result, error
 		return ctrl.Result{}, err
 	}
 
+	if ae.dp.UsesKarpenter() {
+		dynamicClient, err := ae.dynamicClient()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		remaining, err := ae.deleteNodePools(dynamicClient, nil)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if remaining > 0 {
+			ctrl.LoggerFrom(ae.ctx).Info("waiting for karpenter nodepools to be deleted", "nodePools", remaining)
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+	}
+
 	for ng, ngStatus := range ae.tenantsInfra.Status.NodegroupStatus {
 
 		if ngStatus.Status != "DELETING" {
-			_, err := ae.eksIC.DeleteNodeGroup(ng)
+			drained, err := ae.drainNodegroup(ng)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
-			// update status with current nodegroup status
-			_, _, err = utils.PatchStatus(ae.ctx, ae.client, ae.tenantsInfra, func(obj client.Object) client.Object {
-				in := obj.(*v1.TenantsInfra)
-				if in.Status.NodegroupStatus == nil {
-					in.Status.NodegroupStatus = make(map[string]v1.NodegroupStatus)
-				}
-				in.Status.NodegroupStatus[ng] = v1.NodegroupStatus{Status: "DELETING"}
-				return in
-			})
+			if !drained {
+				ctrl.LoggerFrom(ae.ctx).Info("waiting for nodegroup to be drained", logging.KeyNodegroup, ng)
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+
+			_, err = ae.eksIC.DeleteNodeGroup(ng)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
+			// update status with current nodegroup status
+			if err := ae.patchNodegroupPhase(ng, "DELETING"); err != nil {
+				return ctrl.Result{}, err
+			}
 		}
 
 		_, found, err := ae.eksIC.DescribeNodegroup(ng)
@@ -178,14 +206,17 @@ func (r *TenantsInfraReconciler) reconcileDelete(ae *awsEnv) (ctrl.Result, error
 			return ctrl.Result{}, err
 		}
 		if found {
-			klog.Infof("waiting for nodegroup %s to be deleted", ng)
+			ctrl.LoggerFrom(ae.ctx).Info("waiting for nodegroup to be deleted", logging.KeyNodegroup, ng)
 			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 		}
+		if err := ae.eksIC.DeleteLaunchTemplate(ae.ctx, ng); err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
 	// remove our finalizer from the list and update it.
 	controllerutil.RemoveFinalizer(ae.tenantsInfra, tenantsFinalizer)
-	klog.Infof("Deleted Tenant Infra [%s]", ae.tenantsInfra.GetName())
+	ctrl.LoggerFrom(ae.ctx).Info("deleted tenants infra")
 	if err := ae.client.Update(ae.ctx, ae.tenantsInfra.DeepCopyObject().(*v1.TenantsInfra)); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -0,0 +1,300 @@
+package admission
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Namespace runs the webhook in the dataplanes, under Name.
+	Namespace = "baaz-system"
+	Name      = "baaz-admission"
+	// SecretName holds the certificates of the webhook.
+	SecretName = Name + "-tls"
+
+	// Flag runs the baaz image as the webhook server.
+	Flag = "admission-webhook"
+
+	// HealthProbeAddress serves the probes of the pod of the webhook.
+	HealthProbeAddress = ":8081"
+
+	healthPort       = 8081
+	certValidity     = 10 * 365 * 24 * time.Hour
+	webhookTimeout   = 5
+	serviceHTTPSPort = 443
+
+	// replicas of the webhook, the api server refuses the pods of tenants
+	// while none is available
+	replicas = 2
+)
+
+// Certificates serve the webhook, the api server trusts CA.
+type Certificates struct {
+	CA   []byte
+	Cert []byte
+	Key  []byte
+}
+
+// NewCertificates issues a self signed CA and the serving certificate of the
+// service of the webhook.
+func NewCertificates() (*Certificates, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: Name + "-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	service := Name + "." + Namespace + ".svc"
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: service},
+		DNSNames:     []string{Name, Name + "." + Namespace, service, service + ".cluster.local"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Certificates{
+		CA:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+// CertificatesFrom reads the certificates of the secret of the webhook, it
+// is nil when the secret misses one.
+func CertificatesFrom(secret *corev1.Secret) *Certificates {
+	certs := &Certificates{
+		CA:   secret.Data["ca.crt"],
+		Cert: secret.Data[corev1.TLSCertKey],
+		Key:  secret.Data[corev1.TLSPrivateKeyKey],
+	}
+	if len(certs.CA) == 0 || len(certs.Cert) == 0 || len(certs.Key) == 0 {
+		return nil
+	}
+	return certs
+}
+
+// Manifests returns the objects running the webhook from image in a
+// dataplane, in the order they are applied.
+func Manifests(image string, certs *Certificates) []client.Object {
+	labels := map[string]string{"app.kubernetes.io/name": Name}
+	meta := metav1.ObjectMeta{Name: Name, Namespace: Namespace, Labels: labels}
+
+	return []client.Object{
+		&corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: Namespace},
+		},
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta,
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: Name, Labels: labels},
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups: []string{""},
+				Resources: []string{"namespaces", "nodes"},
+				Verbs:     []string{"get", "list", "watch"},
+			}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: Name, Labels: labels},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     Name,
+			},
+			Subjects: []rbacv1.Subject{{Kind: "ServiceAccount", Name: Name, Namespace: Namespace}},
+		},
+		&corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace, Labels: labels},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				"ca.crt":                certs.CA,
+				corev1.TLSCertKey:       certs.Cert,
+				corev1.TLSPrivateKeyKey: certs.Key,
+			},
+		},
+		&schedulingv1.PriorityClass{
+			TypeMeta:    metav1.TypeMeta{APIVersion: "scheduling.k8s.io/v1", Kind: "PriorityClass"},
+			ObjectMeta:  metav1.ObjectMeta{Name: DefaultPriorityClass, Labels: labels},
+			Value:       DefaultPriority,
+			Description: "Pods of tenants running on on-demand machine pools.",
+		},
+		&schedulingv1.PriorityClass{
+			TypeMeta:    metav1.TypeMeta{APIVersion: "scheduling.k8s.io/v1", Kind: "PriorityClass"},
+			ObjectMeta:  metav1.ObjectMeta{Name: LowPriorityClass, Labels: labels},
+			Value:       LowPriority,
+			Description: "Pods of tenants running on spot machine pools.",
+		},
+		deployment(meta, image),
+		&policyv1.PodDisruptionBudget{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "policy/v1", Kind: "PodDisruptionBudget"},
+			ObjectMeta: meta,
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				MinAvailable: ptr.To(intstr.FromInt32(1)),
+				Selector:     &metav1.LabelSelector{MatchLabels: labels},
+			},
+		},
+		&corev1.Service{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+			ObjectMeta: meta,
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports: []corev1.ServicePort{{
+					Name:       "webhook",
+					Port:       serviceHTTPSPort,
+					TargetPort: intstr.FromInt32(Port),
+				}},
+			},
+		},
+		// the configuration goes last, so the api server only calls the
+		// webhook once it is deployed
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "admissionregistration.k8s.io/v1", Kind: "MutatingWebhookConfiguration"},
+			ObjectMeta: metav1.ObjectMeta{Name: Name, Labels: labels},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{{
+				Name: "pods.baaz.dev",
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{
+						Namespace: Namespace,
+						Name:      Name,
+						Path:      ptr.To(MutatePodPath),
+						Port:      ptr.To[int32](serviceHTTPSPort),
+					},
+					CABundle: certs.CA,
+				},
+				Rules: []admissionregistrationv1.RuleWithOperations{{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+					Rule: admissionregistrationv1.Rule{
+						APIGroups:   []string{""},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"pods"},
+						Scope:       ptr.To(admissionregistrationv1.NamespacedScope),
+					},
+				}},
+				NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      TenantLabel,
+					Operator: metav1.LabelSelectorOpExists,
+				}}},
+				// isolation is only enforced when no pod of a tenant goes
+				// around the webhook
+				FailurePolicy:           ptr.To(admissionregistrationv1.Fail),
+				SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+				TimeoutSeconds:          ptr.To[int32](webhookTimeout),
+				AdmissionReviewVersions: []string{"v1"},
+			}},
+		},
+	}
+}
+
+func deployment(meta metav1.ObjectMeta, image string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: meta,
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: meta.Labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: meta.Labels},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: Name,
+					NodeSelector:       map[string]string{"nodeType": "system"},
+					// spread the replicas so a node going away leaves one
+					Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+						PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+							Weight: 100,
+							PodAffinityTerm: corev1.PodAffinityTerm{
+								LabelSelector: &metav1.LabelSelector{MatchLabels: meta.Labels},
+								TopologyKey:   corev1.LabelHostname,
+							},
+						}},
+					}},
+					Containers: []corev1.Container{{
+						Name:  "webhook",
+						Image: image,
+						Args:  []string{"-" + Flag},
+						Ports: []corev1.ContainerPort{
+							{Name: "webhook", ContainerPort: Port},
+							{Name: "health", ContainerPort: healthPort},
+						},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+								Path: "/readyz",
+								Port: intstr.FromString("health"),
+							}},
+						},
+						LivenessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+								Path: "/healthz",
+								Port: intstr.FromString("health"),
+							}},
+						},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "certs",
+							MountPath: CertDir,
+							ReadOnly:  true,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "certs",
+						VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+							SecretName: SecretName,
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,222 @@
+// Package admission schedules the pods of tenants on the machine pools of
+// their size. The tenant controller records the placement of each tenant on
+// its namespace, and the webhook installed in the dataplanes injects the
+// tolerations, node affinity and priority class of the placement into the
+// pods of the namespace, rejecting the pods of isolated tenants which target
+// the machine pools of other tenants.
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/drain"
+	"github.com/baazhq/baaz/pkg/karpenter"
+	"github.com/baazhq/baaz/pkg/placement"
+)
+
+const (
+	// TenantLabel marks the namespaces of tenants with their name, the
+	// webhook only receives the pods of these namespaces.
+	TenantLabel = "baaz.dev/tenant"
+	// SchedulingAnnotation holds the Scheduling of the tenant of a
+	// namespace, as json.
+	SchedulingAnnotation = "baaz.dev/scheduling"
+	// AppTypeLabel picks the app type whose machine pools run a pod of a
+	// tenant with several app types.
+	AppTypeLabel = "baaz.dev/app-type"
+)
+
+// The priority classes of the pods of tenants. Pods of sizes running on spot
+// machine pools only get the low priority.
+const (
+	DefaultPriorityClass = "baaz-default-priority"
+	LowPriorityClass     = "baaz-low-priority"
+
+	DefaultPriority int32 = 1000
+	LowPriority     int32 = 100
+)
+
+// Scheduling tells where the pods of a tenant run.
+type Scheduling struct {
+	Tenant string `json:"tenant"`
+	// Placements holds the machine pools of the size each app type runs
+	// on.
+	Placements map[v1.ApplicationType]placement.Placement `json:"placements,omitempty"`
+	// PriorityClasses holds the priority class of the pods of each app
+	// type.
+	PriorityClasses map[v1.ApplicationType]string `json:"priorityClasses,omitempty"`
+	// DefaultAppType places the pods without the AppTypeLabel.
+	DefaultAppType v1.ApplicationType `json:"defaultAppType,omitempty"`
+	// Allowed lists the machine pools the pods of the tenant may target,
+	// the ones of the sizes it migrates between included.
+	Allowed []string `json:"allowed,omitempty"`
+	// Isolated rejects the pods targeting machine pools out of Allowed.
+	Isolated bool `json:"isolated,omitempty"`
+}
+
+// PriorityClassFor returns the priority class of the pods of a size.
+func PriorityClassFor(tenantSizes v1.TenantSizes) string {
+	if len(tenantSizes.MachineSpec) == 0 {
+		return DefaultPriorityClass
+	}
+	for _, machineSpec := range tenantSizes.MachineSpec {
+		if machineSpec.Type != v1.MachineTypeLowPriority {
+			return DefaultPriorityClass
+		}
+	}
+	return LowPriorityClass
+}
+
+func priorityOf(priorityClass string) int32 {
+	if priorityClass == LowPriorityClass {
+		return LowPriority
+	}
+	return DefaultPriority
+}
+
+// SchedulingOf reads the scheduling of the tenant of a namespace. It tells
+// if the namespace has one.
+func SchedulingOf(namespace *corev1.Namespace) (*Scheduling, bool, error) {
+	raw, ok := namespace.Annotations[SchedulingAnnotation]
+	if !ok || namespace.Labels[TenantLabel] == "" {
+		return nil, false, nil
+	}
+	s := &Scheduling{}
+	if err := json.Unmarshal([]byte(raw), s); err != nil {
+		return nil, false, fmt.Errorf("invalid %s annotation on namespace %s: %w", SchedulingAnnotation, namespace.Name, err)
+	}
+	return s, true, nil
+}
+
+// Annotate records s on the namespace of the tenant. It tells if the
+// namespace changed.
+func (s *Scheduling) Annotate(namespace *corev1.Namespace) (bool, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return false, err
+	}
+	if namespace.Labels[TenantLabel] == s.Tenant && namespace.Annotations[SchedulingAnnotation] == string(raw) {
+		return false, nil
+	}
+	if namespace.Labels == nil {
+		namespace.Labels = make(map[string]string)
+	}
+	if namespace.Annotations == nil {
+		namespace.Annotations = make(map[string]string)
+	}
+	namespace.Labels[TenantLabel] = s.Tenant
+	namespace.Annotations[SchedulingAnnotation] = string(raw)
+	return true, nil
+}
+
+// Allow adds the machine pools of p to the ones the tenant may target.
+func (s *Scheduling) Allow(p placement.Placement) {
+	for _, name := range p.Names {
+		if !s.allows(name) {
+			s.Allowed = append(s.Allowed, name)
+			sort.Strings(s.Allowed)
+		}
+	}
+}
+
+func (s *Scheduling) allows(name string) bool {
+	i := sort.SearchStrings(s.Allowed, name)
+	return i < len(s.Allowed) && s.Allowed[i] == name
+}
+
+// Validate rejects the pods of isolated tenants which tolerate the taint of,
+// select or run on the machine pools of other tenants. node is the node the
+// pod is bound to, if any.
+func (s *Scheduling) Validate(pod *corev1.Pod, node *corev1.Node) error {
+	if !s.Isolated {
+		return nil
+	}
+
+	for _, toleration := range pod.Spec.Tolerations {
+		// an empty key with Exists tolerates every taint
+		if toleration.Key == "" && toleration.Operator == corev1.TolerationOpExists {
+			return fmt.Errorf("tenant %s is isolated, its pods cannot tolerate every taint", s.Tenant)
+		}
+		if toleration.Key != placement.TaintKey {
+			continue
+		}
+		if toleration.Operator == corev1.TolerationOpExists {
+			return fmt.Errorf("tenant %s is isolated, its pods cannot tolerate every machine pool", s.Tenant)
+		}
+		if !s.allows(toleration.Value) {
+			return fmt.Errorf("tenant %s is isolated, its pods cannot tolerate machine pool %s", s.Tenant, toleration.Value)
+		}
+	}
+
+	for _, key := range []string{drain.NodegroupLabel, karpenter.NodePoolLabel} {
+		if value, ok := pod.Spec.NodeSelector[key]; ok && !s.allows(value) {
+			return fmt.Errorf("tenant %s is isolated, its pods cannot select machine pool %s", s.Tenant, value)
+		}
+		if node != nil {
+			if value, ok := node.Labels[key]; ok && !s.allows(value) {
+				return fmt.Errorf("tenant %s is isolated, its pods cannot run on node %s of machine pool %s", s.Tenant, node.Name, value)
+			}
+		}
+	}
+
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil ||
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+	for _, term := range pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, expression := range term.MatchExpressions {
+			if expression.Key != drain.NodegroupLabel && expression.Key != karpenter.NodePoolLabel ||
+				expression.Operator != corev1.NodeSelectorOpIn {
+				continue
+			}
+			for _, value := range expression.Values {
+				if !s.allows(value) {
+					return fmt.Errorf("tenant %s is isolated, its pods cannot select machine pool %s", s.Tenant, value)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Mutate schedules pod on the machine pools of its app type and gives it
+// the priority class of the size, unless it has one. Pods of DaemonSets are
+// left alone, their controller creates a pod per node. It tells if pod
+// changed.
+func (s *Scheduling) Mutate(pod *corev1.Pod) bool {
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return false
+	}
+
+	appType := s.DefaultAppType
+	if label, ok := pod.Labels[AppTypeLabel]; ok {
+		if _, known := s.Placements[v1.ApplicationType(label)]; known {
+			appType = v1.ApplicationType(label)
+		}
+	}
+	p, ok := s.Placements[appType]
+	if !ok || len(p.Names) == 0 {
+		return false
+	}
+
+	changed := placement.Pin(&pod.Spec, p)
+	if pod.Spec.PriorityClassName == "" {
+		priorityClass := s.PriorityClasses[appType]
+		if priorityClass == "" {
+			priorityClass = DefaultPriorityClass
+		}
+		// the priority was resolved by the api server before the webhooks
+		// run, so it is set along with the class
+		priority := priorityOf(priorityClass)
+		pod.Spec.PriorityClassName = priorityClass
+		pod.Spec.Priority = &priority
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,181 @@
+package admission
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/drain"
+	"github.com/baazhq/baaz/pkg/placement"
+)
+
+var (
+	small = v1.TenantSizes{MachineSpec: []v1.MachineSpec{{
+		Name: "app",
+		Size: "t3.medium",
+		Type: v1.MachineTypeLowPriority,
+	}}}
+	large = v1.TenantSizes{MachineSpec: []v1.MachineSpec{{
+		Name: "app",
+		Size: "m5.xlarge",
+		Type: v1.MachineTypeDefaultPriority,
+	}}}
+)
+
+func newScheduling(isolated bool) *Scheduling {
+	s := &Scheduling{
+		Tenant: "acme",
+		Placements: map[v1.ApplicationType]placement.Placement{
+			v1.ApplicationType("druid"): placement.ForSize("small", small, false),
+			v1.ApplicationType("kafka"): placement.ForSize("large", large, false),
+		},
+		PriorityClasses: map[v1.ApplicationType]string{
+			v1.ApplicationType("druid"): PriorityClassFor(small),
+			v1.ApplicationType("kafka"): PriorityClassFor(large),
+		},
+		DefaultAppType: v1.ApplicationType("druid"),
+		Isolated:       isolated,
+	}
+	for _, p := range s.Placements {
+		s.Allow(p)
+	}
+	return s
+}
+
+func TestMutate(t *testing.T) {
+	s := newScheduling(false)
+
+	pod := &corev1.Pod{}
+	if !s.Mutate(pod) {
+		t.Fatal("expected the pod to change")
+	}
+	if !s.Placements["druid"].Targets(&pod.Spec) {
+		t.Fatal("expected the pod to target the default app type")
+	}
+	if pod.Spec.PriorityClassName != LowPriorityClass || *pod.Spec.Priority != LowPriority {
+		t.Fatalf("expected the low priority of spot pools, got %s", pod.Spec.PriorityClassName)
+	}
+	if s.Mutate(pod) {
+		t.Fatal("expected a second mutation to be a no-op")
+	}
+
+	pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{AppTypeLabel: "kafka"}}}
+	pod.Spec.PriorityClassName = "critical"
+	s.Mutate(pod)
+	if !s.Placements["kafka"].Targets(&pod.Spec) || s.Placements["druid"].Targets(&pod.Spec) {
+		t.Fatal("expected the pod to target its app type only")
+	}
+	if pod.Spec.PriorityClassName != "critical" || pod.Spec.Priority != nil {
+		t.Fatal("expected the priority class of the pod to be kept")
+	}
+
+	controller := true
+	pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{
+		Kind: "DaemonSet", Name: "agent", Controller: &controller,
+	}}}}
+	if s.Mutate(pod) {
+		t.Fatal("expected the pods of DaemonSets to be left alone")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	foreign := corev1.Toleration{
+		Key:      placement.TaintKey,
+		Operator: corev1.TolerationOpEqual,
+		Value:    "medium-app-c5-large",
+		Effect:   corev1.TaintEffectNoSchedule,
+	}
+	own := foreign
+	own.Value = "small-app-t3-medium"
+
+	tests := []struct {
+		name  string
+		pod   corev1.Pod
+		node  *corev1.Node
+		valid bool
+	}{
+		{
+			name:  "own machine pool",
+			pod:   corev1.Pod{Spec: corev1.PodSpec{Tolerations: []corev1.Toleration{own}}},
+			valid: true,
+		},
+		{
+			name: "foreign toleration",
+			pod:  corev1.Pod{Spec: corev1.PodSpec{Tolerations: []corev1.Toleration{foreign}}},
+		},
+		{
+			name: "tolerates every machine pool",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Tolerations: []corev1.Toleration{{
+				Key: placement.TaintKey, Operator: corev1.TolerationOpExists,
+			}}}},
+		},
+		{
+			name: "tolerates every taint",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Tolerations: []corev1.Toleration{{
+				Operator: corev1.TolerationOpExists,
+			}}}},
+		},
+		{
+			name: "foreign node selector",
+			pod: corev1.Pod{Spec: corev1.PodSpec{NodeSelector: map[string]string{
+				drain.NodegroupLabel: "medium-app-c5-large",
+			}}},
+		},
+		{
+			name: "foreign node affinity",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key: drain.NodegroupLabel, Operator: corev1.NodeSelectorOpIn, Values: []string{"medium-app-c5-large"},
+					}}}},
+				},
+			}}}},
+		},
+		{
+			name: "bound to a foreign node",
+			pod:  corev1.Pod{Spec: corev1.PodSpec{NodeName: "node-1"}},
+			node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Name:   "node-1",
+				Labels: map[string]string{drain.NodegroupLabel: "medium-app-c5-large"},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := newScheduling(true).Validate(&test.pod, test.node)
+			if test.valid && err != nil {
+				t.Fatalf("expected the pod to be admitted, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected the pod to be rejected")
+			}
+			if err := newScheduling(false).Validate(&test.pod, test.node); err != nil {
+				t.Fatalf("expected the pods of tenants without isolation to be admitted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	s := newScheduling(true)
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "acme"}}
+
+	changed, err := s.Annotate(namespace)
+	if err != nil || !changed {
+		t.Fatalf("expected the namespace to be annotated, got %v", err)
+	}
+	if changed, _ := s.Annotate(namespace); changed {
+		t.Fatal("expected a second annotation to be a no-op")
+	}
+
+	read, ok, err := SchedulingOf(namespace)
+	if err != nil || !ok {
+		t.Fatalf("expected the scheduling to be read back, got %v", err)
+	}
+	if !read.Isolated || len(read.Allowed) != len(s.Allowed) || read.Placements["kafka"].LabelKey != drain.NodegroupLabel {
+		t.Fatalf("unexpected scheduling %+v", read)
+	}
+}
@@ -0,0 +1,81 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	ctrladmission "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// MutatePodPath is the path the webhook serves the pods on.
+	MutatePodPath = "/mutate-v1-pod"
+	// Port is the port of the webhook server, and CertDir holds its
+	// serving certificate.
+	Port    = 9443
+	CertDir = "/tmp/k8s-webhook-server/serving-certs"
+)
+
+// PodPlacer places the pods of the namespaces of tenants.
+type PodPlacer struct {
+	Client  client.Reader
+	decoder *ctrladmission.Decoder
+}
+
+// SetupWithManager serves the PodPlacer on the webhook server of mgr.
+func SetupWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register(MutatePodPath, &webhook.Admission{Handler: &PodPlacer{
+		Client:  mgr.GetClient(),
+		decoder: ctrladmission.NewDecoder(mgr.GetScheme()),
+	}})
+}
+
+// Handle mutates the pod of req to run on the machine pools of its tenant,
+// or denies it when it targets the machine pools of other tenants.
+func (p *PodPlacer) Handle(ctx context.Context, req ctrladmission.Request) ctrladmission.Response {
+	pod := &corev1.Pod{}
+	if err := p.decoder.Decode(req, pod); err != nil {
+		return ctrladmission.Errored(http.StatusBadRequest, err)
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := p.Client.Get(ctx, client.ObjectKey{Name: req.Namespace}, namespace); err != nil {
+		return ctrladmission.Errored(http.StatusInternalServerError, err)
+	}
+	s, ok, err := SchedulingOf(namespace)
+	if err != nil {
+		return ctrladmission.Errored(http.StatusInternalServerError, err)
+	}
+	if !ok {
+		return ctrladmission.Allowed("namespace has no tenant")
+	}
+
+	var node *corev1.Node
+	if pod.Spec.NodeName != "" {
+		node = &corev1.Node{}
+		err := p.Client.Get(ctx, client.ObjectKey{Name: pod.Spec.NodeName}, node)
+		if apierrors.IsNotFound(err) {
+			node = nil
+		} else if err != nil {
+			return ctrladmission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+	if err := s.Validate(pod, node); err != nil {
+		return ctrladmission.Denied(err.Error())
+	}
+
+	if !s.Mutate(pod) {
+		return ctrladmission.Allowed("")
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return ctrladmission.Errored(http.StatusInternalServerError, err)
+	}
+	return ctrladmission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
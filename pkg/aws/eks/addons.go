@@ -2,9 +2,14 @@ package eks
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
 )
 
 func (ec *eks) DescribeAddon(addonName string) (*awseks.DescribeAddonOutput, error) {
@@ -33,3 +38,86 @@ func (ec *eks) CreateAddon(ctx context.Context, params *awseks.CreateAddonInput)
 	}
 	return result, nil
 }
+
+func (ec *eks) UpdateAddon(ctx context.Context, params *awseks.UpdateAddonInput) (*awseks.UpdateAddonOutput, error) {
+	return ec.awsClient.UpdateAddon(ctx, params)
+}
+
+func (ec *eks) DeleteAddon(ctx context.Context, params *awseks.DeleteAddonInput) (*awseks.DeleteAddonOutput, error) {
+	return ec.awsClient.DeleteAddon(ctx, params)
+}
+
+// ResolveAddonVersion returns the addon version to install on a cluster of
+// kubernetes version k8sVersion, resolving latest-compatible to the latest
+// version of the addon.
+func (ec *eks) ResolveAddonVersion(ctx context.Context, addonName, version, k8sVersion string) (string, error) {
+	if version != v1.AddonVersionLatestCompatible {
+		return version, nil
+	}
+
+	versions, _, err := ec.CompatibleAddonVersions(ctx, addonName, k8sVersion)
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	for _, v := range versions {
+		if latest == "" || compareAddonVersions(v, latest) > 0 {
+			latest = v
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no version of addon %s found for kubernetes %s", addonName, k8sVersion)
+	}
+	return latest, nil
+}
+
+// CompatibleAddonVersions lists the addon versions compatible with kubernetes
+// version k8sVersion and the default one.
+func (ec *eks) CompatibleAddonVersions(ctx context.Context, addonName, k8sVersion string) (versions []string, defaultVersion string, err error) {
+	paginator := awseks.NewDescribeAddonVersionsPaginator(ec.awsClient, &awseks.DescribeAddonVersionsInput{
+		AddonName:         aws.String(addonName),
+		KubernetesVersion: aws.String(k8sVersion),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, addon := range page.Addons {
+			for _, addonVersion := range addon.AddonVersions {
+				versions = append(versions, aws.ToString(addonVersion.AddonVersion))
+				for _, compatibility := range addonVersion.Compatibilities {
+					if compatibility.DefaultVersion && aws.ToString(compatibility.ClusterVersion) == k8sVersion {
+						defaultVersion = aws.ToString(addonVersion.AddonVersion)
+					}
+				}
+			}
+		}
+	}
+	return versions, defaultVersion, nil
+}
+
+// compareAddonVersions compares addon versions like v1.15.0-eksbuild.2
+// number by number.
+func compareAddonVersions(a, b string) int {
+	split := func(v string) []int {
+		var nums []int
+		for _, f := range strings.FieldsFunc(v, func(r rune) bool { return r < '0' || r > '9' }) {
+			n, _ := strconv.Atoi(f)
+			nums = append(nums, n)
+		}
+		return nums
+	}
+	na, nb := split(a), split(b)
+	for i := 0; i < len(na) && i < len(nb); i++ {
+		if na[i] != nb[i] {
+			if na[i] > nb[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	return len(na) - len(nb)
+}
@@ -0,0 +1,49 @@
+package eks
+
+import "testing"
+
+func TestCompareAddonVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"v1.15.0-eksbuild.2", "v1.15.0-eksbuild.2", 0},
+		{"v1.15.0-eksbuild.2", "v1.15.0-eksbuild.1", 1},
+		{"v1.15.0-eksbuild.1", "v1.15.0-eksbuild.2", -1},
+		{"v1.15.1-eksbuild.1", "v1.15.0-eksbuild.9", 1},
+		{"v1.9.0-eksbuild.1", "v1.15.0-eksbuild.1", -1},
+		{"v1.10.0-eksbuild.1", "v1.9.3-eksbuild.7", 1},
+		{"v2.0.0-eksbuild.1", "v1.18.3-eksbuild.2", 1},
+		{"v1.29.0-minimal-eksbuild.1", "v1.29.0-eksbuild.1", 0},
+		{"v1.15.0", "v1.15.0-eksbuild.1", -1},
+		{"v1.15.0-eksbuild.1", "v1.15.0", 1},
+	} {
+		if got := compareAddonVersions(tc.a, tc.b); sign(got) != tc.want {
+			t.Errorf("compareAddonVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestSamePolicyDocument(t *testing.T) {
+	desired := `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity"}]}`
+	for current, want := range map[string]bool{
+		`%7B%22Version%22%3A%222012-10-17%22%2C%22Statement%22%3A%5B%7B%22Effect%22%3A%22Allow%22%2C%22Action%22%3A%22sts%3AAssumeRoleWithWebIdentity%22%7D%5D%7D`: true,
+		`{"Statement":[{"Action":"sts:AssumeRoleWithWebIdentity","Effect":"Allow"}],"Version":"2012-10-17"}`:                                                       true,
+		`%7B%22Version%22%3A%222012-10-17%22%2C%22Statement%22%3A%5B%7B%22Effect%22%3A%22Deny%22%7D%5D%7D`:                                                         false,
+		`not json`: false,
+	} {
+		if got := samePolicyDocument(current, desired); got != want {
+			t.Errorf("samePolicyDocument(%q) = %v, want %v", current, got, want)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
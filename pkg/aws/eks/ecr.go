@@ -0,0 +1,111 @@
+package eks
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+// ecrRegistry matches the hosts of the ECR registries, capturing their
+// account and region.
+var ecrRegistry = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// ECRAllowedAccountsEnv are the comma separated accounts, besides the one of
+// a dataplane, whose ECR registries the charts of the dataplane may be
+// pulled from with the AWS credentials of baaz.
+const ECRAllowedAccountsEnv = "BAAZ_ECR_ALLOWED_ACCOUNTS"
+
+// ecrTokenRefresh is how long before their expiry the ECR tokens, valid for
+// 12 hours, are refreshed.
+const ecrTokenRefresh = time.Hour
+
+// RegistryCredentials are the credentials of an ECR registry.
+type RegistryCredentials struct {
+	Username  string
+	Password  string
+	ExpiresAt time.Time
+}
+
+// ecrTokens caches the credentials of the ECR registries, shared by the
+// dataplanes as they log in with the same AWS credentials.
+var ecrTokens = struct {
+	sync.Mutex
+	credentials map[string]*RegistryCredentials
+}{credentials: make(map[string]*RegistryCredentials)}
+
+// IsECRRegistry reports whether host is the host of an ECR registry.
+func IsECRRegistry(host string) bool {
+	return ecrRegistry.MatchString(host)
+}
+
+// GetRegistryCredentials returns the credentials of the ECR registry host,
+// like 123456789012.dkr.ecr.us-east-1.amazonaws.com, from an authorization
+// token of the AWS credentials, cached until it is about to expire. Only the
+// registries of the account of the dataplane and of the accounts the
+// operator allows are logged in, so the tenants can not pull through baaz
+// from the registries of other accounts.
+func (ec *eks) GetRegistryCredentials(ctx context.Context, host string) (*RegistryCredentials, error) {
+	match := ecrRegistry.FindStringSubmatch(host)
+	if match == nil {
+		return nil, fmt.Errorf("%s is not an ecr registry", host)
+	}
+	account, region := match[1], match[2]
+
+	if !slices.Contains(splitList(os.Getenv(ECRAllowedAccountsEnv)), account) {
+		own, err := ec.getAccountID()
+		if err != nil {
+			return nil, err
+		}
+		if account != own {
+			return nil, fmt.Errorf("ecr registry %s is not of the account of the dataplane, nor allowed by %s", host, ECRAllowedAccountsEnv)
+		}
+	}
+
+	ecrTokens.Lock()
+	defer ecrTokens.Unlock()
+	if credentials, ok := ecrTokens.credentials[host]; ok && time.Until(credentials.ExpiresAt) > ecrTokenRefresh {
+		return credentials, nil
+	}
+
+	output, err := newAwsEcrClient(ctx, region).GetAuthorizationToken(ctx, &awsecr.GetAuthorizationTokenInput{
+		RegistryIds: []string{account},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("no authorization token for ecr registry %s", host)
+	}
+	data := output.AuthorizationData[0]
+
+	credentials, err := parseAuthorizationToken(aws.ToString(data.AuthorizationToken))
+	if err != nil {
+		return nil, fmt.Errorf("ecr registry %s: %w", host, err)
+	}
+	credentials.ExpiresAt = aws.ToTime(data.ExpiresAt)
+	ecrTokens.credentials[host] = credentials
+	return credentials, nil
+}
+
+// parseAuthorizationToken returns the credentials of an ECR authorization
+// token, the base64 of username:password.
+func parseAuthorizationToken(token string) (*RegistryCredentials, error) {
+	decoded, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization token: %w", err)
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid authorization token")
+	}
+	return &RegistryCredentials{Username: username, Password: password}, nil
+}
@@ -0,0 +1,30 @@
+package eks
+
+import "testing"
+
+func TestIsECRRegistry(t *testing.T) {
+	for host, want := range map[string]bool{
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com":          true,
+		"123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com": true,
+		"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn":      true,
+		"public.ecr.aws":       false,
+		"registry.example.com": false,
+	} {
+		if got := IsECRRegistry(host); got != want {
+			t.Errorf("IsECRRegistry(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestParseAuthorizationToken(t *testing.T) {
+	credentials, err := parseAuthorizationToken("QVdTOnRva2Vu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.Username != "AWS" || credentials.Password != "token" {
+		t.Fatalf("unexpected credentials %+v", credentials)
+	}
+	if _, err := parseAuthorizationToken("bm8tc2VwYXJhdG9y"); err == nil {
+		t.Fatal("expected a token without separator to fail")
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/aws/aws-sdk-go-v2/service/eks/types"
-	"k8s.io/klog/v2"
+
+	"github.com/baazhq/baaz/pkg/logging"
 )
 
 type DataPlaneControllerReason string
@@ -19,6 +20,13 @@ const (
 	EksControlPlaneProvisioningReason     DataPlaneControllerReason = "EksControlPlaneProvisioning"
 	EksControlPlaneCreationUpgradedReason DataPlaneControllerReason = "EksControlUpgradeInitatedReason"
 	EksControlPlaneUpgradedReason         DataPlaneControllerReason = "EksControlPlaneUpgradeReason"
+	EksUpgradeInProgressReason            DataPlaneControllerReason = "EksUpgradeInProgress"
+	EksUpgradePreflightFailedReason       DataPlaneControllerReason = "EksUpgradePreflightFailed"
+	EksUpgradePreflightPassedReason       DataPlaneControllerReason = "EksUpgradePreflightPassed"
+	EksUpgradeStepCompletedReason         DataPlaneControllerReason = "EksUpgradeStepCompleted"
+	EksUpgradeCompletedReason             DataPlaneControllerReason = "EksUpgradeCompleted"
+	EksUpgradePausedReason                DataPlaneControllerReason = "EksUpgradePaused"
+	EksUpgradeResumedReason               DataPlaneControllerReason = "EksUpgradeResumed"
 )
 
 type DataPlaneControllerMsg string
@@ -88,9 +96,11 @@ func (ec *eks) createEks() error {
 	return err
 }
 
-func (ec *eks) UpdateEks() *EksInternalOutput {
+// UpdateEks upgrades the control plane to version, which must be the next
+// minor version of the cluster.
+func (ec *eks) UpdateEks(version string) *EksInternalOutput {
 
-	err := ec.updateEks()
+	err := ec.updateEks(version)
 	if err != nil {
 		return &EksInternalOutput{Result: err.Error()}
 	}
@@ -101,11 +111,11 @@ func (ec *eks) UpdateEks() *EksInternalOutput {
 	}
 }
 
-func (ec *eks) updateEks() error {
+func (ec *eks) updateEks(version string) error {
 
 	_, err := ec.awsClient.UpdateClusterVersion(ec.ctx, &awseks.UpdateClusterVersionInput{
 		Name:    &ec.dp.Spec.CloudInfra.AwsCloudInfraConfig.Eks.Name,
-		Version: aws.String(ec.dp.Spec.CloudInfra.Eks.Version),
+		Version: aws.String(version),
 	})
 
 	if err != nil {
@@ -117,7 +127,7 @@ func (ec *eks) updateEks() error {
 
 func (ec *eks) UpdateAwsEksDataPlane(clusterResult *awseks.DescribeClusterOutput) types.ClusterStatus {
 
-	klog.Infof("Syncing dp: %s/%s", ec.dp.Namespace, ec.dp.Name)
+	logging.FromContext(ec.ctx, logging.AWS).V(1).Info("syncing eks cluster status", logging.KeyCluster, ec.dp.Spec.CloudInfra.Eks.Name)
 
 	switch clusterResult.Cluster.Status {
 
@@ -146,7 +156,7 @@ func (ec *eks) DeleteEKS() (*awseks.DeleteClusterOutput, error) {
 		return nil, errors.New("cluster in deleting state")
 	}
 
-	klog.Infof("Deleting EKS Control Plane [%s]", ec.dp.Spec.CloudInfra.Eks.Name)
+	logging.FromContext(ec.ctx, logging.AWS).Info("deleting eks control plane", logging.KeyCluster, ec.dp.Spec.CloudInfra.Eks.Name)
 	out, err := ec.awsClient.DeleteCluster(ec.ctx, &awseks.DeleteClusterInput{
 		Name: &ec.dp.Spec.CloudInfra.AwsCloudInfraConfig.Eks.Name,
 	})
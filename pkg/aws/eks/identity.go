@@ -0,0 +1,130 @@
+package eks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// TenantPermissionsBoundaryEnv is the managed policy bounding the
+	// permissions of the roles of tenants, whatever policies they declare.
+	TenantPermissionsBoundaryEnv = "BAAZ_TENANT_PERMISSIONS_BOUNDARY"
+	// TenantAllowedActionsEnv and TenantAllowedResourcesEnv are the comma
+	// separated actions and resources the iam policies of tenants may
+	// allow, with * wildcards.
+	TenantAllowedActionsEnv   = "BAAZ_TENANT_ALLOWED_ACTIONS"
+	TenantAllowedResourcesEnv = "BAAZ_TENANT_ALLOWED_RESOURCES"
+)
+
+// tenantPermissionsBoundary returns the permissions boundary of the roles of
+// tenants, roles are not created without one.
+func tenantPermissionsBoundary() (string, error) {
+	boundary := os.Getenv(TenantPermissionsBoundaryEnv)
+	if boundary == "" {
+		return "", fmt.Errorf("tenant roles need a permissions boundary, %s is not set", TenantPermissionsBoundaryEnv)
+	}
+	return boundary, nil
+}
+
+// policyDocument is the part of an iam policy document validated.
+type policyDocument struct {
+	Statement statements `json:"Statement"`
+}
+
+type policyStatement struct {
+	Effect      string      `json:"Effect"`
+	Action      stringOrSet `json:"Action"`
+	NotAction   stringOrSet `json:"NotAction"`
+	Resource    stringOrSet `json:"Resource"`
+	NotResource stringOrSet `json:"NotResource"`
+}
+
+// statements and stringOrSet read the single values iam accepts in place of
+// lists.
+type statements []policyStatement
+
+func (s *statements) UnmarshalJSON(data []byte) error {
+	var statement policyStatement
+	if err := json.Unmarshal(data, &statement); err == nil {
+		*s = statements{statement}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]policyStatement)(s))
+}
+
+type stringOrSet []string
+
+func (s *stringOrSet) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*s = stringOrSet{value}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(s))
+}
+
+// ValidateTenantPolicy checks that the statements of the iam policy of a
+// tenant only allow the actions and resources of the allowlists of the
+// operator. Denies are always valid.
+func ValidateTenantPolicy(document string) error {
+	return validateTenantPolicy(document, splitList(os.Getenv(TenantAllowedActionsEnv)), splitList(os.Getenv(TenantAllowedResourcesEnv)))
+}
+
+func validateTenantPolicy(document string, allowedActions, allowedResources []string) error {
+	var policy policyDocument
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return fmt.Errorf("invalid iam policy: %w", err)
+	}
+	for _, statement := range policy.Statement {
+		if statement.Effect == "Deny" {
+			continue
+		}
+		if len(statement.NotAction) > 0 || len(statement.NotResource) > 0 {
+			return fmt.Errorf("iam policy of tenants can not allow NotAction or NotResource")
+		}
+		if len(statement.Action) == 0 || len(statement.Resource) == 0 {
+			return fmt.Errorf("iam policy of tenants must list the actions and resources they allow")
+		}
+		for _, action := range statement.Action {
+			if !matchesAny(strings.ToLower(action), allowedActions, true) {
+				return fmt.Errorf("iam policy of tenants can not allow action %s", action)
+			}
+		}
+		for _, resource := range statement.Resource {
+			if !matchesAny(resource, allowedResources, false) {
+				return fmt.Errorf("iam policy of tenants can not allow resource %s", resource)
+			}
+		}
+	}
+	return nil
+}
+
+// matchesAny tells if value matches one of the patterns. The wildcards of
+// value are matched as is, so s3:* only matches the patterns covering every
+// s3 action.
+func matchesAny(value string, patterns []string, lower bool) bool {
+	for _, pattern := range patterns {
+		if lower {
+			pattern = strings.ToLower(pattern)
+		}
+		// path.Match treats / as a separator, which arns do not have
+		pattern = strings.ReplaceAll(pattern, "/", "\x00")
+		if ok, _ := path.Match(pattern, strings.ReplaceAll(value, "/", "\x00")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
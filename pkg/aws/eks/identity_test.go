@@ -0,0 +1,89 @@
+package eks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTenantPolicy(t *testing.T) {
+	allowedActions := []string{"s3:GetObject", "s3:PutObject", "sqs:*"}
+	allowedResources := []string{"arn:aws:s3:::acme-*", "arn:aws:sqs:us-east-1:123456789012:acme-*"}
+
+	for _, tc := range []struct {
+		name     string
+		document string
+		valid    bool
+	}{
+		{
+			name:     "allowed",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:PutObject"],"Resource":"arn:aws:s3:::acme-data/*"}]}`,
+			valid:    true,
+		},
+		{
+			name:     "single statement",
+			document: `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"sqs:SendMessage","Resource":"arn:aws:sqs:us-east-1:123456789012:acme-jobs"}}`,
+			valid:    true,
+		},
+		{
+			name:     "deny anything",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":"*","Resource":"*"}]}`,
+			valid:    true,
+		},
+		{
+			name:     "action not allowed",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"iam:PassRole","Resource":"arn:aws:s3:::acme-data"}]}`,
+		},
+		{
+			name:     "wildcard action wider than allowed",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:s3:::acme-data"}]}`,
+		},
+		{
+			name:     "resource not allowed",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::other-data/*"}]}`,
+		},
+		{
+			name:     "every resource",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`,
+		},
+		{
+			name:     "not action",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","NotAction":"iam:*","Resource":"arn:aws:s3:::acme-data"}]}`,
+		},
+		{
+			name:     "not json",
+			document: `not json`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTenantPolicy(tc.document, allowedActions, allowedResources)
+			if tc.valid && err != nil {
+				t.Fatalf("expected the policy to be valid, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected the policy to be rejected")
+			}
+		})
+	}
+
+	if err := validateTenantPolicy(`{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::acme-data"}]}`, nil, nil); err == nil {
+		t.Fatal("expected every policy to be rejected without allowlists")
+	}
+}
+
+func TestMakeTenantRoleName(t *testing.T) {
+	if got, want := MakeTenantRoleName("us-east-1", "prod", "acme"), "us-east-1-prod-acme-tenant-role"; got != want {
+		t.Fatalf("got %s, expected %s", got, want)
+	}
+
+	long := MakeTenantRoleName("ap-southeast-2", "production-analytics", "a-tenant-with-a-rather-long-name")
+	if len(long) != maxRoleNameLength {
+		t.Fatalf("got %s of %d characters, expected %d", long, len(long), maxRoleNameLength)
+	}
+	if !strings.HasPrefix(long, "ap-southeast-2-production-analytics-a-tenant") {
+		t.Fatalf("got %s, expected the name to be truncated", long)
+	}
+	other := MakeTenantRoleName("ap-southeast-2", "production-analytics", "a-tenant-with-a-rather-long-name-2")
+	if other == long {
+		t.Fatalf("expected tenants sharing a truncated name to get different roles, got %s", long)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 
 	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	awseventbridge "github.com/aws/aws-sdk-go-v2/service/eventbridge"
 	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
 	awssts "github.com/aws/aws-sdk-go-v2/service/sts"
 	v1 "github.com/baazhq/baaz/api/v1/types"
 	"k8s.io/client-go/kubernetes"
@@ -16,7 +20,7 @@ type Eks interface {
 	// eks control plane
 	DescribeEks() (*awseks.DescribeClusterOutput, error)
 	CreateEks() *EksInternalOutput
-	UpdateEks() *EksInternalOutput
+	UpdateEks(version string) *EksInternalOutput
 	DeleteEKS() (*awseks.DeleteClusterOutput, error)
 	// oidc
 	ListOIDCProvider() (*awsiam.ListOpenIDConnectProvidersOutput, error)
@@ -28,6 +32,7 @@ type Eks interface {
 	DescribeNodegroup(nodeGroupName string) (output *awseks.DescribeNodegroupOutput, found bool, err error)
 	CreateNodegroup(createNodegroupInput *awseks.CreateNodegroupInput) (output *awseks.CreateNodegroupOutput, err error)
 	UpdateNodegroup(updateNodeGroupConfig *awseks.UpdateNodegroupConfigInput) (output *awseks.UpdateNodegroupConfigOutput, err error)
+	UpdateNodegroupVersion(updateNodegroupVersion *awseks.UpdateNodegroupVersionInput) (output *awseks.UpdateNodegroupVersionOutput, err error)
 	// iam role
 	CreateNodeIamRole(name string) (*awsiam.GetRoleOutput, error)
 	CreateClusterIamRole() (*awsiam.GetRoleOutput, error)
@@ -35,15 +40,30 @@ type Eks interface {
 	// addons
 	CreateAddon(ctx context.Context, params *awseks.CreateAddonInput) (*awseks.CreateAddonOutput, error)
 	DescribeAddon(addonName string) (*awseks.DescribeAddonOutput, error)
+	UpdateAddon(ctx context.Context, params *awseks.UpdateAddonInput) (*awseks.UpdateAddonOutput, error)
+	DeleteAddon(ctx context.Context, params *awseks.DeleteAddonInput) (*awseks.DeleteAddonOutput, error)
+	ResolveAddonVersion(ctx context.Context, addonName, version, k8sVersion string) (string, error)
+	CompatibleAddonVersions(ctx context.Context, addonName, k8sVersion string) (versions []string, defaultVersion string, err error)
 	// auth
 	GetEksClientSet() (*kubernetes.Clientset, error)
 	GetRestConfig() (*rest.Config, error)
+	GetRegistryCredentials(ctx context.Context, host string) (*RegistryCredentials, error)
 	// roles
-	CreateEbsCSIRole(ctx context.Context) (*awsiam.CreateRoleOutput, error)
-	CreateVpcCniRole(ctx context.Context) (roleOutput *awsiam.CreateRoleOutput, arn string, err error)
+	EnsureAddonRole(ctx context.Context, addonName string, role *v1.AddonServiceAccountRole) (string, error)
+	EnsureTenantRole(ctx context.Context, tenant, serviceAccount string, identity *v1.TenantIdentity) (string, error)
+	DeleteTenantRole(ctx context.Context, tenant string) error
 	DescribeInstances(ctx context.Context, input *awsec2.DescribeInstancesInput) (*awsec2.DescribeInstancesOutput, error)
 	CreateIAMPolicy(ctx context.Context, input *awsiam.CreatePolicyInput) (*awsiam.CreatePolicyOutput, error)
 	AttachRolePolicy(ctx context.Context, input *awsiam.AttachRolePolicyInput) (*awsiam.AttachRolePolicyOutput, error)
+	// karpenter
+	EnsureKarpenterNodeRole(ctx context.Context) (*KarpenterNodeRole, error)
+	EnsureKarpenterControllerRole(ctx context.Context, nodeRoleArn, queueArn string) (string, error)
+	EnsureInterruptionQueue(ctx context.Context) (queueName, queueArn string, err error)
+	DeleteInterruptionQueue(ctx context.Context) error
+	MapNodeRole(ctx context.Context, roleArn string) error
+	// launch templates
+	EnsureLaunchTemplate(ctx context.Context, name string, data *ec2types.RequestLaunchTemplateData, hash string) (*types.LaunchTemplateSpecification, error)
+	DeleteLaunchTemplate(ctx context.Context, name string) error
 }
 
 type eks struct {
@@ -52,6 +72,8 @@ type eks struct {
 	awsIamClient *awsiam.Client
 	awsStsClient *awssts.Client
 	awsec2Client *awsec2.Client
+	awsSqsClient *awssqs.Client
+	awsEvClient  *awseventbridge.Client
 	dp           *v1.DataPlanes
 }
 
@@ -59,12 +81,18 @@ func NewEks(
 	ctx context.Context,
 	dp *v1.DataPlanes,
 ) Eks {
-	return &eks{
-		awsClient:    newAwsClient(ctx, dp.Spec.CloudInfra.Region),
-		awsIamClient: newAwsIamClient(ctx, dp.Spec.CloudInfra.Region),
-		awsStsClient: newAwsStsClient(ctx, dp.Spec.CloudInfra.Region),
-		awsec2Client: newAwsEc2Client(ctx, dp.Spec.CloudInfra.Region),
-		ctx:          ctx,
-		dp:           dp,
+	return &tracedEks{
+		ctx:     ctx,
+		cluster: dp.Spec.CloudInfra.Eks.Name,
+		next: &eks{
+			awsClient:    newAwsClient(ctx, dp.Spec.CloudInfra.Region),
+			awsIamClient: newAwsIamClient(ctx, dp.Spec.CloudInfra.Region),
+			awsStsClient: newAwsStsClient(ctx, dp.Spec.CloudInfra.Region),
+			awsec2Client: newAwsEc2Client(ctx, dp.Spec.CloudInfra.Region),
+			awsSqsClient: newAwsSqsClient(ctx, dp.Spec.CloudInfra.Region),
+			awsEvClient:  newAwsEventBridgeClient(ctx, dp.Spec.CloudInfra.Region),
+			ctx:          ctx,
+			dp:           dp,
+		},
 	}
 }
@@ -0,0 +1,455 @@
+package eks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awseventbridge "github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	evtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// KarpenterNamespace and KarpenterServiceAccount run the karpenter
+	// controller.
+	KarpenterNamespace      = "kube-system"
+	KarpenterServiceAccount = "karpenter"
+
+	karpenterControllerPolicyName = "karpenter-controller"
+	interruptionTargetID          = "KarpenterInterruptionQueueTarget"
+)
+
+var karpenterNodeRolePolicyArns = append([]string{
+	"arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore",
+}, nodeRolePolicyArns...)
+
+// interruptionRules forward the events karpenter handles to its queue, keyed
+// by the suffix of the rule name.
+var interruptionRules = map[string]string{
+	"scheduled-change":      `{"source":["aws.health"],"detail-type":["AWS Health Event"]}`,
+	"spot-interruption":     `{"source":["aws.ec2"],"detail-type":["EC2 Spot Instance Interruption Warning"]}`,
+	"rebalance":             `{"source":["aws.ec2"],"detail-type":["EC2 Instance Rebalance Recommendation"]}`,
+	"instance-state-change": `{"source":["aws.ec2"],"detail-type":["EC2 Instance State-change Notification"]}`,
+}
+
+type karpenterPolicyInput struct {
+	Region      string
+	AccountID   string
+	ClusterName string
+	NodeRoleArn string
+	QueueArn    string
+}
+
+var interruptionQueuePolicy = `
+{
+	"Version": "2012-10-17",
+	"Statement": [
+		{
+			"Effect": "Allow",
+			"Principal": {
+				"Service": ["events.amazonaws.com", "sqs.amazonaws.com"]
+			},
+			"Action": "sqs:SendMessage",
+			"Resource": "{{.QueueArn}}"
+		}
+	]
+}`
+
+// karpenterControllerPolicy scopes the launch and termination of instances
+// to the nodes of the NodePools of the cluster.
+var karpenterControllerPolicy = `
+{
+	"Version": "2012-10-17",
+	"Statement": [
+		{
+			"Effect": "Allow",
+			"Action": ["ec2:RunInstances", "ec2:CreateFleet"],
+			"Resource": [
+				"arn:aws:ec2:{{.Region}}::image/*",
+				"arn:aws:ec2:{{.Region}}::snapshot/*",
+				"arn:aws:ec2:{{.Region}}:*:security-group/*",
+				"arn:aws:ec2:{{.Region}}:*:subnet/*"
+			]
+		},
+		{
+			"Effect": "Allow",
+			"Action": ["ec2:RunInstances", "ec2:CreateFleet"],
+			"Resource": "arn:aws:ec2:{{.Region}}:*:launch-template/*",
+			"Condition": {
+				"StringEquals": {"aws:ResourceTag/kubernetes.io/cluster/{{.ClusterName}}": "owned"},
+				"StringLike": {"aws:ResourceTag/karpenter.sh/nodepool": "*"}
+			}
+		},
+		{
+			"Effect": "Allow",
+			"Action": ["ec2:RunInstances", "ec2:CreateFleet", "ec2:CreateLaunchTemplate"],
+			"Resource": [
+				"arn:aws:ec2:{{.Region}}:*:fleet/*",
+				"arn:aws:ec2:{{.Region}}:*:instance/*",
+				"arn:aws:ec2:{{.Region}}:*:volume/*",
+				"arn:aws:ec2:{{.Region}}:*:network-interface/*",
+				"arn:aws:ec2:{{.Region}}:*:launch-template/*",
+				"arn:aws:ec2:{{.Region}}:*:spot-instances-request/*"
+			],
+			"Condition": {
+				"StringEquals": {"aws:RequestTag/kubernetes.io/cluster/{{.ClusterName}}": "owned"},
+				"StringLike": {"aws:RequestTag/karpenter.sh/nodepool": "*"}
+			}
+		},
+		{
+			"Effect": "Allow",
+			"Action": "ec2:CreateTags",
+			"Resource": [
+				"arn:aws:ec2:{{.Region}}:*:fleet/*",
+				"arn:aws:ec2:{{.Region}}:*:instance/*",
+				"arn:aws:ec2:{{.Region}}:*:volume/*",
+				"arn:aws:ec2:{{.Region}}:*:network-interface/*",
+				"arn:aws:ec2:{{.Region}}:*:launch-template/*",
+				"arn:aws:ec2:{{.Region}}:*:spot-instances-request/*"
+			],
+			"Condition": {
+				"StringEquals": {
+					"aws:RequestTag/kubernetes.io/cluster/{{.ClusterName}}": "owned",
+					"ec2:CreateAction": ["RunInstances", "CreateFleet", "CreateLaunchTemplate"]
+				},
+				"StringLike": {"aws:RequestTag/karpenter.sh/nodepool": "*"}
+			}
+		},
+		{
+			"Effect": "Allow",
+			"Action": "ec2:CreateTags",
+			"Resource": "arn:aws:ec2:{{.Region}}:*:instance/*",
+			"Condition": {
+				"StringEquals": {"aws:ResourceTag/kubernetes.io/cluster/{{.ClusterName}}": "owned"},
+				"StringLike": {"aws:ResourceTag/karpenter.sh/nodepool": "*"}
+			}
+		},
+		{
+			"Effect": "Allow",
+			"Action": ["ec2:TerminateInstances", "ec2:DeleteLaunchTemplate"],
+			"Resource": [
+				"arn:aws:ec2:{{.Region}}:*:instance/*",
+				"arn:aws:ec2:{{.Region}}:*:launch-template/*"
+			],
+			"Condition": {
+				"StringEquals": {"aws:ResourceTag/kubernetes.io/cluster/{{.ClusterName}}": "owned"},
+				"StringLike": {"aws:ResourceTag/karpenter.sh/nodepool": "*"}
+			}
+		},
+		{
+			"Effect": "Allow",
+			"Action": [
+				"ec2:DescribeAvailabilityZones",
+				"ec2:DescribeImages",
+				"ec2:DescribeInstances",
+				"ec2:DescribeInstanceTypeOfferings",
+				"ec2:DescribeInstanceTypes",
+				"ec2:DescribeLaunchTemplates",
+				"ec2:DescribeSecurityGroups",
+				"ec2:DescribeSpotPriceHistory",
+				"ec2:DescribeSubnets"
+			],
+			"Resource": "*",
+			"Condition": {
+				"StringEquals": {"aws:RequestedRegion": "{{.Region}}"}
+			}
+		},
+		{
+			"Effect": "Allow",
+			"Action": "ssm:GetParameter",
+			"Resource": "arn:aws:ssm:{{.Region}}::parameter/aws/service/*"
+		},
+		{
+			"Effect": "Allow",
+			"Action": "pricing:GetProducts",
+			"Resource": "*"
+		},
+		{
+			"Effect": "Allow",
+			"Action": ["sqs:DeleteMessage", "sqs:GetQueueUrl", "sqs:ReceiveMessage"],
+			"Resource": "{{.QueueArn}}"
+		},
+		{
+			"Effect": "Allow",
+			"Action": "iam:PassRole",
+			"Resource": "{{.NodeRoleArn}}",
+			"Condition": {
+				"StringEquals": {"iam:PassedToService": "ec2.amazonaws.com"}
+			}
+		},
+		{
+			"Effect": "Allow",
+			"Action": "iam:GetInstanceProfile",
+			"Resource": "*"
+		},
+		{
+			"Effect": "Allow",
+			"Action": "eks:DescribeCluster",
+			"Resource": "arn:aws:eks:{{.Region}}:{{.AccountID}}:cluster/{{.ClusterName}}"
+		}
+	]
+}`
+
+// KarpenterNodeRole is the role of the nodes launched by karpenter.
+type KarpenterNodeRole struct {
+	RoleName        string
+	RoleArn         string
+	InstanceProfile string
+}
+
+// EnsureKarpenterNodeRole creates the role of the nodes launched by
+// karpenter and its instance profile.
+func (ec *eks) EnsureKarpenterNodeRole(ctx context.Context) (*KarpenterNodeRole, error) {
+	roleName := MakeKarpenterNodeRoleName(ec.dp.Spec.CloudInfra.Eks.Name)
+	nodeRole := &KarpenterNodeRole{RoleName: roleName, InstanceProfile: roleName}
+
+	getRoleOutput, err := ec.awsIamClient.GetRole(ctx, &awsiam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		var notFoundErr *iamtypes.NoSuchEntityException
+		if !errors.As(err, &notFoundErr) {
+			return nil, err
+		}
+		roleOutput, err := ec.awsIamClient.CreateRole(ctx, &awsiam.CreateRoleInput{
+			RoleName:                 aws.String(roleName),
+			AssumeRolePolicyDocument: aws.String(strings.TrimSpace(assumeNodeRolePolicy)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, policyArn := range karpenterNodeRolePolicyArns {
+			if _, err := ec.awsIamClient.AttachRolePolicy(ctx, &awsiam.AttachRolePolicyInput{
+				RoleName:  aws.String(roleName),
+				PolicyArn: aws.String(policyArn),
+			}); err != nil {
+				return nil, err
+			}
+		}
+		nodeRole.RoleArn = aws.ToString(roleOutput.Role.Arn)
+	} else {
+		nodeRole.RoleArn = aws.ToString(getRoleOutput.Role.Arn)
+	}
+
+	profileOutput, err := ec.awsIamClient.GetInstanceProfile(ctx, &awsiam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(nodeRole.InstanceProfile),
+	})
+	if err != nil {
+		var notFoundErr *iamtypes.NoSuchEntityException
+		if !errors.As(err, &notFoundErr) {
+			return nil, err
+		}
+		createOutput, err := ec.awsIamClient.CreateInstanceProfile(ctx, &awsiam.CreateInstanceProfileInput{
+			InstanceProfileName: aws.String(nodeRole.InstanceProfile),
+		})
+		if err != nil {
+			return nil, err
+		}
+		profileOutput = &awsiam.GetInstanceProfileOutput{InstanceProfile: createOutput.InstanceProfile}
+	}
+	if len(profileOutput.InstanceProfile.Roles) == 0 {
+		if _, err := ec.awsIamClient.AddRoleToInstanceProfile(ctx, &awsiam.AddRoleToInstanceProfileInput{
+			InstanceProfileName: aws.String(nodeRole.InstanceProfile),
+			RoleName:            aws.String(roleName),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return nodeRole, nil
+}
+
+// EnsureKarpenterControllerRole creates the role assumed by the karpenter
+// service account and keeps its inline policy in sync. It returns the role
+// arn.
+func (ec *eks) EnsureKarpenterControllerRole(ctx context.Context, nodeRoleArn, queueArn string) (string, error) {
+	roleName := MakeKarpenterControllerRoleName(ec.dp.Spec.CloudInfra.Region, ec.dp.Spec.CloudInfra.Eks.Name)
+
+	roleArn, err := ec.ensureServiceAccountRole(ctx, roleName, KarpenterNamespace, KarpenterServiceAccount, "")
+	if err != nil {
+		return "", err
+	}
+
+	policy, err := ec.karpenterTemplate(karpenterControllerPolicy, nodeRoleArn, queueArn)
+	if err != nil {
+		return "", err
+	}
+	if _, err := ec.awsIamClient.PutRolePolicy(ctx, &awsiam.PutRolePolicyInput{
+		RoleName:       aws.String(roleName),
+		PolicyName:     aws.String(karpenterControllerPolicyName),
+		PolicyDocument: aws.String(policy),
+	}); err != nil {
+		return "", err
+	}
+
+	return roleArn, nil
+}
+
+// EnsureInterruptionQueue creates the sqs queue of karpenter and the
+// eventbridge rules forwarding the spot interruptions, rebalance
+// recommendations, instance state changes and scheduled maintenances to it.
+func (ec *eks) EnsureInterruptionQueue(ctx context.Context) (string, string, error) {
+	queueName := MakeInterruptionQueueName(ec.dp.Spec.CloudInfra.Eks.Name)
+
+	accountID, err := ec.getAccountID()
+	if err != nil {
+		return "", "", err
+	}
+	queueArn := fmt.Sprintf("arn:aws:sqs:%s:%s:%s", ec.dp.Spec.CloudInfra.Region, accountID, queueName)
+
+	policy, err := ec.karpenterTemplate(interruptionQueuePolicy, "", queueArn)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := ec.awsSqsClient.CreateQueue(ctx, &awssqs.CreateQueueInput{
+		QueueName: aws.String(queueName),
+		Attributes: map[string]string{
+			string(sqstypes.QueueAttributeNameMessageRetentionPeriod): "300",
+			string(sqstypes.QueueAttributeNameSqsManagedSseEnabled):   "true",
+			string(sqstypes.QueueAttributeNamePolicy):                 policy,
+		},
+	}); err != nil {
+		return "", "", err
+	}
+
+	for suffix, pattern := range interruptionRules {
+		ruleName := queueName + "-" + suffix
+		if _, err := ec.awsEvClient.PutRule(ctx, &awseventbridge.PutRuleInput{
+			Name:         aws.String(ruleName),
+			EventPattern: aws.String(pattern),
+			State:        evtypes.RuleStateEnabled,
+		}); err != nil {
+			return "", "", err
+		}
+		if _, err := ec.awsEvClient.PutTargets(ctx, &awseventbridge.PutTargetsInput{
+			Rule: aws.String(ruleName),
+			Targets: []evtypes.Target{{
+				Id:  aws.String(interruptionTargetID),
+				Arn: aws.String(queueArn),
+			}},
+		}); err != nil {
+			return "", "", err
+		}
+	}
+
+	return queueName, queueArn, nil
+}
+
+// DeleteInterruptionQueue deletes the eventbridge rules and the sqs queue
+// of karpenter, missing ones are ignored.
+func (ec *eks) DeleteInterruptionQueue(ctx context.Context) error {
+	queueName := MakeInterruptionQueueName(ec.dp.Spec.CloudInfra.Eks.Name)
+
+	for suffix := range interruptionRules {
+		ruleName := queueName + "-" + suffix
+		var notFoundErr *evtypes.ResourceNotFoundException
+		if _, err := ec.awsEvClient.RemoveTargets(ctx, &awseventbridge.RemoveTargetsInput{
+			Rule: aws.String(ruleName),
+			Ids:  []string{interruptionTargetID},
+		}); err != nil && !errors.As(err, &notFoundErr) {
+			return err
+		}
+		if _, err := ec.awsEvClient.DeleteRule(ctx, &awseventbridge.DeleteRuleInput{
+			Name: aws.String(ruleName),
+		}); err != nil && !errors.As(err, &notFoundErr) {
+			return err
+		}
+	}
+
+	urlOutput, err := ec.awsSqsClient.GetQueueUrl(ctx, &awssqs.GetQueueUrlInput{
+		QueueName: aws.String(queueName),
+	})
+	if err != nil {
+		var notFoundErr *sqstypes.QueueDoesNotExist
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+		return err
+	}
+	_, err = ec.awsSqsClient.DeleteQueue(ctx, &awssqs.DeleteQueueInput{
+		QueueUrl: urlOutput.QueueUrl,
+	})
+	return err
+}
+
+type awsAuthRole struct {
+	RoleArn  string   `json:"rolearn"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// MapNodeRole lets the nodes assuming roleArn join the cluster through the
+// aws-auth configmap. EKS maps the roles of managed nodegroups itself.
+func (ec *eks) MapNodeRole(ctx context.Context, roleArn string) error {
+	clientset, err := ec.GetEksClientSet()
+	if err != nil {
+		return err
+	}
+
+	cm, err := clientset.CoreV1().ConfigMaps("kube-system").Get(ctx, "aws-auth", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return errors.New("aws-auth configmap not found, waiting for the first nodegroup")
+	}
+	if err != nil {
+		return err
+	}
+
+	var roles []awsAuthRole
+	if err := yaml.Unmarshal([]byte(cm.Data["mapRoles"]), &roles); err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role.RoleArn == roleArn {
+			return nil
+		}
+	}
+	roles = append(roles, awsAuthRole{
+		RoleArn:  roleArn,
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		Groups:   []string{"system:bootstrappers", "system:nodes"},
+	})
+
+	mapRoles, err := yaml.Marshal(roles)
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data["mapRoles"] = string(mapRoles)
+	_, err = clientset.CoreV1().ConfigMaps("kube-system").Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+func (ec *eks) karpenterTemplate(text, nodeRoleArn, queueArn string) (string, error) {
+	accountID, err := ec.getAccountID()
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("karpenter-template").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var tmplOutput bytes.Buffer
+	if err := tmpl.Execute(&tmplOutput, karpenterPolicyInput{
+		Region:      ec.dp.Spec.CloudInfra.Region,
+		AccountID:   accountID,
+		ClusterName: ec.dp.Spec.CloudInfra.Eks.Name,
+		NodeRoleArn: nodeRoleArn,
+		QueueArn:    queueArn,
+	}); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tmplOutput.String()), nil
+}
@@ -0,0 +1,233 @@
+package eks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/smithy-go"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+const (
+	launchTemplateNameNotFound = "InvalidLaunchTemplateName.NotFoundException"
+	launchTemplateIDNotFound   = "InvalidLaunchTemplateId.NotFound"
+	latestVersion              = "$Latest"
+
+	mimeBoundary = "//"
+)
+
+// the ami types of al2023 are missing from the eks sdk in use.
+const (
+	amiTypeAl2023X8664Standard types.AMITypes = "AL2023_x86_64_STANDARD"
+	amiTypeAl2023Arm64Standard types.AMITypes = "AL2023_ARM_64_STANDARD"
+)
+
+// graviton matches the instance families of arm64 instance types, like m6g,
+// c7gn or t4g, with a g after the generation, and the first generation a1.
+var graviton = regexp.MustCompile(`^(a1|[a-z]+[0-9]+[a-z]*g[a-z]*)$`)
+
+func isArm64(instanceType string) bool {
+	family, _, _ := strings.Cut(instanceType, ".")
+	return graviton.MatchString(family)
+}
+
+// AmiType returns the ami type of the nodegroup of a machine pool. It is
+// empty when eks picks the default one, and for custom amis which are set
+// by the launch template.
+func AmiType(machineSpec v1.MachineSpec) types.AMITypes {
+	arm64 := isArm64(machineSpec.Size)
+	switch machineSpec.AmiFamily {
+	case v1.AmiFamilyAL2:
+		if arm64 {
+			return types.AMITypesAl2Arm64
+		}
+		return types.AMITypesAl2X8664
+	case v1.AmiFamilyAL2023:
+		if arm64 {
+			return amiTypeAl2023Arm64Standard
+		}
+		return amiTypeAl2023X8664Standard
+	case v1.AmiFamilyBottlerocket:
+		if arm64 {
+			return types.AMITypesBottlerocketArm64
+		}
+		return types.AMITypesBottlerocketX8664
+	}
+	return ""
+}
+
+// LaunchTemplateData renders the launch template of the nodes of a machine
+// pool and the hash of its data, which tells apart the versions of the
+// template. The nodes get IMDSv2 with a hop limit of 2 and an encrypted gp3
+// volume unless the machine pool says otherwise. The instance types and the
+// remote access stay on the nodegroup.
+func LaunchTemplateData(machineSpec v1.MachineSpec, clusterSecurityGroupID string) (*ec2types.RequestLaunchTemplateData, string, error) {
+	data := &ec2types.RequestLaunchTemplateData{
+		BlockDeviceMappings: []ec2types.LaunchTemplateBlockDeviceMappingRequest{blockDevice(machineSpec)},
+		MetadataOptions:     metadataOptions(machineSpec),
+	}
+	if machineSpec.AmiFamily == v1.AmiFamilyCustom {
+		data.ImageId = aws.String(machineSpec.AmiID)
+	}
+	// the cluster security group is only attached by eks when the
+	// template has no security groups
+	if len(machineSpec.SecurityGroupIds) > 0 {
+		data.SecurityGroupIds = append([]string{clusterSecurityGroupID}, machineSpec.SecurityGroupIds...)
+	}
+	if machineSpec.SSHKeyName != "" {
+		data.KeyName = aws.String(machineSpec.SSHKeyName)
+	}
+	if machineSpec.UserData != "" {
+		data.UserData = aws.String(base64.StdEncoding.EncodeToString([]byte(userData(machineSpec))))
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(raw)
+	return data, hex.EncodeToString(sum[:])[:16], nil
+}
+
+func blockDevice(machineSpec v1.MachineSpec) ec2types.LaunchTemplateBlockDeviceMappingRequest {
+	disk := machineSpec.DiskWithDefaults()
+	ebs := &ec2types.LaunchTemplateEbsBlockDeviceRequest{
+		VolumeSize:          aws.Int32(disk.SizeGiB),
+		VolumeType:          ec2types.VolumeType(disk.Type),
+		Encrypted:           aws.Bool(true),
+		DeleteOnTermination: aws.Bool(true),
+	}
+	if disk.Iops > 0 {
+		ebs.Iops = aws.Int32(disk.Iops)
+	}
+	if disk.Throughput > 0 {
+		ebs.Throughput = aws.Int32(disk.Throughput)
+	}
+	if disk.KmsKeyID != "" {
+		ebs.KmsKeyId = aws.String(disk.KmsKeyID)
+	}
+	return ec2types.LaunchTemplateBlockDeviceMappingRequest{
+		DeviceName: aws.String(machineSpec.DiskDeviceName()),
+		Ebs:        ebs,
+	}
+}
+
+func metadataOptions(machineSpec v1.MachineSpec) *ec2types.LaunchTemplateInstanceMetadataOptionsRequest {
+	metadata := machineSpec.MetadataWithDefaults()
+	return &ec2types.LaunchTemplateInstanceMetadataOptionsRequest{
+		HttpEndpoint:            ec2types.LaunchTemplateInstanceMetadataEndpointStateEnabled,
+		HttpTokens:              ec2types.LaunchTemplateHttpTokensState(metadata.HttpTokens),
+		HttpPutResponseHopLimit: aws.Int32(metadata.HopLimit),
+	}
+}
+
+// userData returns the user data of the nodes. Eks merges the user data of
+// the eks optimized amis with its bootstrap, which takes a mime multipart
+// document, so plain scripts are wrapped. Bottlerocket settings and the user
+// data of custom amis are used as is.
+func userData(machineSpec v1.MachineSpec) string {
+	if machineSpec.AmiFamily == v1.AmiFamilyBottlerocket || machineSpec.AmiFamily == v1.AmiFamilyCustom {
+		return machineSpec.UserData
+	}
+	if strings.HasPrefix(machineSpec.UserData, "MIME-Version:") {
+		return machineSpec.UserData
+	}
+
+	var b strings.Builder
+	b.WriteString("MIME-Version: 1.0\n")
+	b.WriteString("Content-Type: multipart/mixed; boundary=\"" + mimeBoundary + "\"\n\n")
+	b.WriteString("--" + mimeBoundary + "\n")
+	b.WriteString("Content-Type: text/x-shellscript; charset=\"us-ascii\"\n\n")
+	b.WriteString(strings.TrimSuffix(machineSpec.UserData, "\n") + "\n\n")
+	b.WriteString("--" + mimeBoundary + "--\n")
+	return b.String()
+}
+
+// EnsureLaunchTemplate creates the launch template name, or a new version
+// of it when the latest version was not rendered from the same data. The
+// hash of the data is kept as the description of the versions. It returns
+// the version the nodegroup should run.
+func (ec *eks) EnsureLaunchTemplate(ctx context.Context, name string, data *ec2types.RequestLaunchTemplateData, hash string) (*types.LaunchTemplateSpecification, error) {
+	latest, err := ec.awsec2Client.DescribeLaunchTemplateVersions(ctx, &awsec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateName: aws.String(name),
+		Versions:           []string{latestVersion},
+	})
+	if err != nil && !isLaunchTemplateNotFound(err) {
+		return nil, err
+	}
+
+	if err != nil {
+		created, err := ec.awsec2Client.CreateLaunchTemplate(ctx, &awsec2.CreateLaunchTemplateInput{
+			LaunchTemplateName: aws.String(name),
+			LaunchTemplateData: data,
+			VersionDescription: aws.String(hash),
+			TagSpecifications: []ec2types.TagSpecification{{
+				ResourceType: ec2types.ResourceTypeLaunchTemplate,
+				Tags: []ec2types.Tag{{
+					Key:   aws.String("kubernetes.io/cluster/" + ec.dp.Spec.CloudInfra.Eks.Name),
+					Value: aws.String("owned"),
+				}},
+			}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return launchTemplateSpec(created.LaunchTemplate.LaunchTemplateId, created.LaunchTemplate.LatestVersionNumber), nil
+	}
+
+	if len(latest.LaunchTemplateVersions) > 0 {
+		version := latest.LaunchTemplateVersions[0]
+		if aws.ToString(version.VersionDescription) == hash {
+			return launchTemplateSpec(version.LaunchTemplateId, version.VersionNumber), nil
+		}
+	}
+
+	created, err := ec.awsec2Client.CreateLaunchTemplateVersion(ctx, &awsec2.CreateLaunchTemplateVersionInput{
+		LaunchTemplateName: aws.String(name),
+		LaunchTemplateData: data,
+		VersionDescription: aws.String(hash),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return launchTemplateSpec(created.LaunchTemplateVersion.LaunchTemplateId, created.LaunchTemplateVersion.VersionNumber), nil
+}
+
+// DeleteLaunchTemplate deletes the launch template name, if any. Eks runs a
+// copy of the template, so it can go once the nodegroup is deleted.
+func (ec *eks) DeleteLaunchTemplate(ctx context.Context, name string) error {
+	_, err := ec.awsec2Client.DeleteLaunchTemplate(ctx, &awsec2.DeleteLaunchTemplateInput{
+		LaunchTemplateName: aws.String(name),
+	})
+	if err != nil && !isLaunchTemplateNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func launchTemplateSpec(id *string, version *int64) *types.LaunchTemplateSpecification {
+	return &types.LaunchTemplateSpecification{
+		Id:      id,
+		Version: aws.String(strconv.FormatInt(aws.ToInt64(version), 10)),
+	}
+}
+
+func isLaunchTemplateNotFound(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == launchTemplateNameNotFound || apiErr.ErrorCode() == launchTemplateIDNotFound
+}
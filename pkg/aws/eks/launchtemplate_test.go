@@ -0,0 +1,122 @@
+package eks
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func TestAmiType(t *testing.T) {
+	tests := []struct {
+		machineSpec v1.MachineSpec
+		want        types.AMITypes
+	}{
+		{v1.MachineSpec{Size: "t3.medium"}, ""},
+		{v1.MachineSpec{Size: "t3.medium", AmiFamily: v1.AmiFamilyAL2}, types.AMITypesAl2X8664},
+		{v1.MachineSpec{Size: "m6gd.large", AmiFamily: v1.AmiFamilyAL2}, types.AMITypesAl2Arm64},
+		{v1.MachineSpec{Size: "c7gn.xlarge", AmiFamily: v1.AmiFamilyAL2023}, amiTypeAl2023Arm64Standard},
+		{v1.MachineSpec{Size: "a1.large", AmiFamily: v1.AmiFamilyAL2}, types.AMITypesAl2Arm64},
+		{v1.MachineSpec{Size: "a1.metal", AmiFamily: v1.AmiFamilyBottlerocket}, types.AMITypesBottlerocketArm64},
+		{v1.MachineSpec{Size: "g5.xlarge", AmiFamily: v1.AmiFamilyBottlerocket}, types.AMITypesBottlerocketX8664},
+		{v1.MachineSpec{Size: "m5.large", AmiFamily: v1.AmiFamilyCustom, AmiID: "ami-123"}, ""},
+	}
+	for _, tt := range tests {
+		if got := AmiType(tt.machineSpec); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.machineSpec.AmiFamily, tt.machineSpec.Size, tt.want, got)
+		}
+	}
+}
+
+func TestLaunchTemplateData(t *testing.T) {
+	machineSpec := v1.MachineSpec{
+		Size:             "m5.large",
+		Disk:             &v1.DiskSpec{SizeGiB: 50, Type: "io2", Iops: 3000},
+		SecurityGroupIds: []string{"sg-extra"},
+		SSHKeyName:       "ops",
+		UserData:         "#!/bin/bash\necho hello\n",
+	}
+
+	data, hash, err := LaunchTemplateData(machineSpec, "sg-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ebs := data.BlockDeviceMappings[0].Ebs
+	if aws.ToString(data.BlockDeviceMappings[0].DeviceName) != "/dev/xvda" ||
+		aws.ToInt32(ebs.VolumeSize) != 50 || ebs.VolumeType != ec2types.VolumeTypeIo2 ||
+		aws.ToInt32(ebs.Iops) != 3000 || !aws.ToBool(ebs.Encrypted) {
+		t.Fatalf("unexpected block device %+v", ebs)
+	}
+	if data.MetadataOptions.HttpTokens != ec2types.LaunchTemplateHttpTokensStateRequired ||
+		aws.ToInt32(data.MetadataOptions.HttpPutResponseHopLimit) != 2 {
+		t.Fatalf("expected IMDSv2 with a hop limit of 2, got %+v", data.MetadataOptions)
+	}
+	if len(data.SecurityGroupIds) != 2 || data.SecurityGroupIds[0] != "sg-cluster" {
+		t.Fatalf("expected the cluster security group first, got %v", data.SecurityGroupIds)
+	}
+	if aws.ToString(data.KeyName) != "ops" || data.ImageId != nil {
+		t.Fatalf("unexpected key %v or image %v", data.KeyName, data.ImageId)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(aws.ToString(data.UserData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(raw), "MIME-Version: 1.0") || !strings.Contains(string(raw), "echo hello") {
+		t.Fatalf("expected the script wrapped in a mime document, got %q", raw)
+	}
+
+	_, again, _ := LaunchTemplateData(machineSpec, "sg-cluster")
+	machineSpec.Disk.SizeGiB = 100
+	_, changed, _ := LaunchTemplateData(machineSpec, "sg-cluster")
+	if hash != again || hash == changed {
+		t.Fatalf("expected the hash to follow the data, got %s %s %s", hash, again, changed)
+	}
+}
+
+func TestLaunchTemplateDataAmiFamilies(t *testing.T) {
+	userData := "[settings.kubernetes]\ncluster-name = \"c\""
+	data, _, err := LaunchTemplateData(v1.MachineSpec{
+		AmiFamily: v1.AmiFamilyBottlerocket,
+		UserData:  userData,
+	}, "sg-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(aws.ToString(data.UserData))
+	if string(raw) != userData {
+		t.Fatalf("expected the bottlerocket settings as is, got %q", raw)
+	}
+	if aws.ToString(data.BlockDeviceMappings[0].DeviceName) != "/dev/xvdb" {
+		t.Fatalf("expected the bottlerocket data volume, got %s", aws.ToString(data.BlockDeviceMappings[0].DeviceName))
+	}
+	if data.SecurityGroupIds != nil {
+		t.Fatalf("expected eks to attach the cluster security group, got %v", data.SecurityGroupIds)
+	}
+
+	data, _, _ = LaunchTemplateData(v1.MachineSpec{AmiFamily: v1.AmiFamilyCustom, AmiID: "ami-123"}, "sg-cluster")
+	if aws.ToString(data.ImageId) != "ami-123" {
+		t.Fatalf("expected the custom ami, got %v", data.ImageId)
+	}
+}
+
+func TestMakeTenantNodegroupNames(t *testing.T) {
+	machineSpec := v1.MachineSpec{Name: "app", Size: "m5.large"}
+	if nodegroup, dedicated := MakeTenantNodegroupNames("small", machineSpec); nodegroup != "small-app-m5-large" || dedicated != "small-app-m5-large-dedicated" {
+		t.Fatalf("unexpected nodegroups %q %q", nodegroup, dedicated)
+	}
+
+	machineSpec.Metadata = &v1.MetadataSpec{HopLimit: 1}
+	if nodegroup, _ := MakeTenantNodegroupNames("small", machineSpec); nodegroup != "small-app-m5-large-lt" {
+		t.Fatalf("expected the nodegroup of a launch template apart, got %q", nodegroup)
+	}
+	if name := MakeTenantMachinePoolName("small", machineSpec); name != "small-app-m5-large" {
+		t.Fatalf("unexpected machine pool name %q", name)
+	}
+}
@@ -6,7 +6,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
-	"k8s.io/klog/v2"
+
+	"github.com/baazhq/baaz/pkg/logging"
 )
 
 const (
@@ -43,7 +44,7 @@ func (ec *eks) CreateOIDCProvider(param *CreateOIDCProviderInput) (*awsiam.Creat
 }
 
 func (ec *eks) DeleteOIDCProvider(providerArn string) (*awsiam.DeleteOpenIDConnectProviderOutput, error) {
-	klog.Infof("Deleting Oidc Provider [%s]", providerArn)
+	logging.FromContext(ec.ctx, logging.AWS).Info("deleting oidc provider", "providerArn", providerArn)
 	var notFoundErr *types.NoSuchEntityException
 
 	output, err := ec.awsIamClient.DeleteOpenIDConnectProvider(ec.ctx, &awsiam.DeleteOpenIDConnectProviderInput{
@@ -3,14 +3,20 @@ package eks
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"html/template"
+	"net/url"
+	"reflect"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
 )
 
 var clusterRolePolicyArns = []string{
@@ -52,42 +58,25 @@ var assumeNodeRolePolicy string = `
     ]
 }
 `
-var ebsCSIRoleTrustJsonTemplate = `
-{
-    "Version": "2012-10-17",
-    "Statement": [
-        {
-            "Effect": "Allow",
-            "Principal": {
-                "Federated": "arn:aws:iam::{{.AccountID}}:oidc-provider/{{.OIDCProvider}}"
-            },
-            "Action": "sts:AssumeRoleWithWebIdentity",
-            "Condition": {
-                "StringEquals": {
-                    "{{.OIDCProvider}}:sub": "system:serviceaccount:kube-system:ebs-csi-controller-sa", 
-                    "{{.OIDCProvider}}:aud": "sts.amazonaws.com"
-                }
-            }
-        }
-    ]
-}
-`
 
-var (
-	ebsCSIPolicyARN = "arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy"
-)
+// tenantPolicyName is the inline policy of the role of a tenant, the iam
+// policy the tenant declares.
+const tenantPolicyName = "tenant-policy"
 
 var (
-	vpcCNIPolicyARN = "arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy"
+	EBSCSIPolicyARN = "arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy"
+	VPCCNIPolicyARN = "arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy"
 )
 
-type genericRoleTemplateInput struct {
-	AccountID    string
-	OIDCProvider string
+type addonRoleTemplateInput struct {
+	AccountID      string
+	OIDCProvider   string
+	Namespace      string
+	ServiceAccount string
 }
 
 var (
-	vpcCniTrustPolicy = `
+	addonRoleTrustPolicy = `
 	{
 		"Version": "2012-10-17",
 		"Statement": [
@@ -100,7 +89,7 @@ var (
 				"Condition": {
 					"StringEquals": {
 						"{{.OIDCProvider}}:aud": "sts.amazonaws.com",
-						"{{.OIDCProvider}}:sub": "system:serviceaccount:kube-system:aws-node"
+						"{{.OIDCProvider}}:sub": "system:serviceaccount:{{.Namespace}}:{{.ServiceAccount}}"
 					}
 				}
 			}
@@ -171,121 +160,258 @@ func (ec *eks) CreateNodeIamRole(name string) (*awsiam.GetRoleOutput, error) {
 	return result, nil
 }
 
-func (ec *eks) CreateEbsCSIRole(ctx context.Context) (*awsiam.CreateRoleOutput, error) {
-	oidcProvider := ec.dp.Status.CloudInfraStatus.AwsCloudInfraConfigStatus.EksStatus.OIDCProviderArn
-
-	roleName := MakeEBSCSIRoleName(ec.dp.Spec.CloudInfra.Region, ec.dp.Spec.CloudInfra.Eks.Name)
+// EnsureAddonRole creates the iam role assumed by the service account of an
+// addon and makes its attached policies match role.PolicyArns. It returns
+// the role arn.
+func (ec *eks) EnsureAddonRole(ctx context.Context, addonName string, role *v1.AddonServiceAccountRole) (string, error) {
+	roleName := MakeAddonRoleName(ec.dp.Spec.CloudInfra.Region, ec.dp.Spec.CloudInfra.Eks.Name, addonName)
 
-	_, err := ec.awsIamClient.GetRole(ec.ctx, &awsiam.GetRoleInput{
-		RoleName: aws.String(roleName),
-	})
+	namespace := role.Namespace
+	if namespace == "" {
+		namespace = "kube-system"
+	}
+	roleArn, err := ec.ensureServiceAccountRole(ctx, roleName, namespace, role.ServiceAccount, "")
+	if err != nil {
+		return "", err
+	}
+	if err := ec.syncAttachedPolicies(ctx, roleName, role.PolicyArns); err != nil {
+		return "", err
+	}
+	return roleArn, nil
+}
 
+// syncAttachedPolicies makes the managed policies attached to a role match
+// policyArns.
+func (ec *eks) syncAttachedPolicies(ctx context.Context, roleName string, policyArns []string) error {
+	attached, err := ec.attachedPolicies(ctx, roleName)
 	if err != nil {
-		_, oidcProviderURL, found := strings.Cut(oidcProvider, "oidc-provider/")
-		if !found {
-			return nil, errors.New("invalid oidc provider arn")
-		}
-		accountID, err := ec.getAccountID()
-		if err != nil {
-			return nil, err
-		}
+		return err
+	}
 
-		tmpl, err := template.New("ebs-template").Parse(ebsCSIRoleTrustJsonTemplate)
-		if err != nil {
-			return nil, err
+	desired := make(map[string]bool, len(policyArns))
+	for _, policyArn := range policyArns {
+		desired[policyArn] = true
+		if attached[policyArn] {
+			continue
 		}
-		var tmplOutput bytes.Buffer
-
-		if err := tmpl.Execute(&tmplOutput, genericRoleTemplateInput{
-			AccountID:    accountID,
-			OIDCProvider: oidcProviderURL,
+		if _, err := ec.awsIamClient.AttachRolePolicy(ctx, &awsiam.AttachRolePolicyInput{
+			PolicyArn: aws.String(policyArn),
+			RoleName:  aws.String(roleName),
 		}); err != nil {
-			return nil, err
+			return err
 		}
-
-		trustPolicy := tmplOutput.String()
-
-		roleInput := awsiam.CreateRoleInput{
-			AssumeRolePolicyDocument: aws.String(strings.TrimSpace(trustPolicy)),
-			RoleName:                 &roleName,
+	}
+	for policyArn := range attached {
+		if desired[policyArn] {
+			continue
+		}
+		if _, err := ec.awsIamClient.DetachRolePolicy(ctx, &awsiam.DetachRolePolicyInput{
+			PolicyArn: aws.String(policyArn),
+			RoleName:  aws.String(roleName),
+		}); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		roleOutput, err := ec.awsIamClient.CreateRole(ctx, &roleInput)
+func (ec *eks) attachedPolicies(ctx context.Context, roleName string) (map[string]bool, error) {
+	attached := make(map[string]bool)
+	paginator := awsiam.NewListAttachedRolePoliciesPaginator(ec.awsIamClient, &awsiam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
 			return nil, err
 		}
-
-		attachPolicyInput := awsiam.AttachRolePolicyInput{
-			PolicyArn: &ebsCSIPolicyARN,
-			RoleName:  roleOutput.Role.RoleName,
+		for _, policy := range page.AttachedPolicies {
+			attached[aws.ToString(policy.PolicyArn)] = true
 		}
+	}
+	return attached, nil
+}
 
-		if _, err := ec.awsIamClient.AttachRolePolicy(ctx, &attachPolicyInput); err != nil {
-			return nil, err
+// EnsureTenantRole creates the iam role assumed by the service account of a
+// tenant in its namespace, bounded by the tenant permissions boundary,
+// inlines the iam policy of the tenant in it and makes its attached policies
+// match policyArns. The iam policy must pass ValidateTenantPolicy. It returns
+// the role arn.
+func (ec *eks) EnsureTenantRole(ctx context.Context, tenant, serviceAccount string, identity *v1.TenantIdentity) (string, error) {
+	roleName := MakeTenantRoleName(ec.dp.Spec.CloudInfra.Region, ec.dp.Spec.CloudInfra.Eks.Name, tenant)
+
+	boundary, err := tenantPermissionsBoundary()
+	if err != nil {
+		return "", err
+	}
+	if identity.IAMPolicy != "" {
+		if err := ValidateTenantPolicy(identity.IAMPolicy); err != nil {
+			return "", err
 		}
-		return roleOutput, nil
 	}
 
-	return &awsiam.CreateRoleOutput{}, nil
-}
+	roleArn, err := ec.ensureServiceAccountRole(ctx, roleName, tenant, serviceAccount, boundary)
+	if err != nil {
+		return "", err
+	}
 
-func (ec *eks) CreateVpcCniRole(ctx context.Context) (roleOutput *awsiam.CreateRoleOutput, arn string, err error) {
-	oidcProvider := ec.dp.Status.CloudInfraStatus.AwsCloudInfraConfigStatus.EksStatus.OIDCProviderArn
+	if identity.IAMPolicy != "" {
+		if err := ec.putRolePolicy(ctx, roleName, tenantPolicyName, identity.IAMPolicy); err != nil {
+			return "", err
+		}
+	} else if err := ec.deleteRolePolicy(ctx, roleName, tenantPolicyName); err != nil {
+		return "", err
+	}
 
-	roleName := MakeVpcCniRoleName(ec.dp.Spec.CloudInfra.Region, ec.dp.Spec.CloudInfra.Eks.Name)
+	if err := ec.syncAttachedPolicies(ctx, roleName, identity.PolicyArns); err != nil {
+		return "", err
+	}
+	return roleArn, nil
+}
 
-	_, err = ec.awsIamClient.GetRole(ec.ctx, &awsiam.GetRoleInput{
-		RoleName: aws.String(roleName),
-	})
+// DeleteTenantRole deletes the iam role of a tenant and its policies. A
+// missing role is not an error.
+func (ec *eks) DeleteTenantRole(ctx context.Context, tenant string) error {
+	roleName := MakeTenantRoleName(ec.dp.Spec.CloudInfra.Region, ec.dp.Spec.CloudInfra.Eks.Name, tenant)
 
-	if err != nil {
-		_, oidcProviderURL, found := strings.Cut(oidcProvider, "oidc-provider/")
-		if !found {
-			return nil, "", errors.New("invalid oidc provider arn")
-		}
-		accountID, err := ec.getAccountID()
-		if err != nil {
-			return nil, "", err
+	var notFoundErr *iamtypes.NoSuchEntityException
+	if _, err := ec.awsIamClient.GetRole(ctx, &awsiam.GetRoleInput{RoleName: aws.String(roleName)}); err != nil {
+		if errors.As(err, &notFoundErr) {
+			return nil
 		}
+		return err
+	}
 
-		tmpl, err := template.New("vpcni-template").Parse(vpcCniTrustPolicy)
-		if err != nil {
-			return nil, "", err
-		}
-		var tmplOutput bytes.Buffer
+	// a role is only deleted once it has no policies left
+	if err := ec.syncAttachedPolicies(ctx, roleName, nil); err != nil {
+		return err
+	}
+	if err := ec.deleteRolePolicy(ctx, roleName, tenantPolicyName); err != nil {
+		return err
+	}
+	if _, err := ec.awsIamClient.DeleteRole(ctx, &awsiam.DeleteRoleInput{RoleName: aws.String(roleName)}); err != nil && !errors.As(err, &notFoundErr) {
+		return err
+	}
+	return nil
+}
 
-		if err := tmpl.Execute(&tmplOutput, genericRoleTemplateInput{
-			AccountID:    accountID,
-			OIDCProvider: oidcProviderURL,
-		}); err != nil {
-			return nil, "", err
-		}
+// putRolePolicy inlines a policy in a role, unless it is already.
+func (ec *eks) putRolePolicy(ctx context.Context, roleName, policyName, document string) error {
+	current, err := ec.awsIamClient.GetRolePolicy(ctx, &awsiam.GetRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(policyName),
+	})
+	var notFoundErr *iamtypes.NoSuchEntityException
+	if err != nil && !errors.As(err, &notFoundErr) {
+		return err
+	}
+	if err == nil && samePolicyDocument(aws.ToString(current.PolicyDocument), document) {
+		return nil
+	}
+	_, err = ec.awsIamClient.PutRolePolicy(ctx, &awsiam.PutRolePolicyInput{
+		RoleName:       aws.String(roleName),
+		PolicyName:     aws.String(policyName),
+		PolicyDocument: aws.String(document),
+	})
+	return err
+}
 
-		trustPolicy := tmplOutput.String()
+func (ec *eks) deleteRolePolicy(ctx context.Context, roleName, policyName string) error {
+	_, err := ec.awsIamClient.DeleteRolePolicy(ctx, &awsiam.DeleteRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(policyName),
+	})
+	var notFoundErr *iamtypes.NoSuchEntityException
+	if err != nil && !errors.As(err, &notFoundErr) {
+		return err
+	}
+	return nil
+}
 
-		roleInput := awsiam.CreateRoleInput{
-			AssumeRolePolicyDocument: aws.String(strings.TrimSpace(trustPolicy)),
-			RoleName:                 &roleName,
-		}
+// ensureServiceAccountRole creates the iam role assumed through the oidc
+// provider of the cluster by a service account, or updates the trust policy
+// and the permissions boundary, when there is one, of an existing role that
+// does not match. It returns the role arn.
+func (ec *eks) ensureServiceAccountRole(ctx context.Context, roleName, namespace, serviceAccount, boundary string) (string, error) {
+	oidcProvider := ec.dp.Status.CloudInfraStatus.AwsCloudInfraConfigStatus.EksStatus.OIDCProviderArn
+	providerArn, err := arn.Parse(oidcProvider)
+	if err != nil {
+		return "", errors.New("invalid oidc provider arn")
+	}
+	_, oidcProviderURL, found := strings.Cut(providerArn.Resource, "oidc-provider/")
+	if !found {
+		return "", errors.New("invalid oidc provider arn")
+	}
 
-		roleOutput, err := ec.awsIamClient.CreateRole(ctx, &roleInput)
-		if err != nil {
-			return nil, "", err
-		}
+	tmpl, err := template.New("addon-role-template").Parse(addonRoleTrustPolicy)
+	if err != nil {
+		return "", err
+	}
+	var tmplOutput bytes.Buffer
+	if err := tmpl.Execute(&tmplOutput, addonRoleTemplateInput{
+		AccountID:      providerArn.AccountID,
+		OIDCProvider:   oidcProviderURL,
+		Namespace:      namespace,
+		ServiceAccount: serviceAccount,
+	}); err != nil {
+		return "", err
+	}
+	trustPolicy := strings.TrimSpace(tmplOutput.String())
 
-		attachPolicyInput := awsiam.AttachRolePolicyInput{
-			PolicyArn: &vpcCNIPolicyARN,
-			RoleName:  roleOutput.Role.RoleName,
+	getRoleOutput, err := ec.awsIamClient.GetRole(ctx, &awsiam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err == nil {
+		// the role outlives the cluster or service account it was made for
+		if !samePolicyDocument(aws.ToString(getRoleOutput.Role.AssumeRolePolicyDocument), trustPolicy) {
+			if _, err := ec.awsIamClient.UpdateAssumeRolePolicy(ctx, &awsiam.UpdateAssumeRolePolicyInput{
+				RoleName:       aws.String(roleName),
+				PolicyDocument: aws.String(trustPolicy),
+			}); err != nil {
+				return "", err
+			}
 		}
-
-		if _, err := ec.awsIamClient.AttachRolePolicy(ctx, &attachPolicyInput); err != nil {
-			return nil, "", err
+		current := getRoleOutput.Role.PermissionsBoundary
+		if boundary != "" && (current == nil || aws.ToString(current.PermissionsBoundaryArn) != boundary) {
+			if _, err := ec.awsIamClient.PutRolePermissionsBoundary(ctx, &awsiam.PutRolePermissionsBoundaryInput{
+				RoleName:            aws.String(roleName),
+				PermissionsBoundary: aws.String(boundary),
+			}); err != nil {
+				return "", err
+			}
 		}
+		return aws.ToString(getRoleOutput.Role.Arn), nil
+	}
+	var notFoundErr *iamtypes.NoSuchEntityException
+	if !errors.As(err, &notFoundErr) {
+		return "", err
+	}
 
-		return roleOutput, fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, roleName), nil
+	input := &awsiam.CreateRoleInput{
+		AssumeRolePolicyDocument: aws.String(trustPolicy),
+		RoleName:                 aws.String(roleName),
 	}
+	if boundary != "" {
+		input.PermissionsBoundary = aws.String(boundary)
+	}
+	roleOutput, err := ec.awsIamClient.CreateRole(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(roleOutput.Role.Arn), nil
+}
 
-	return &awsiam.CreateRoleOutput{}, "", nil
+// samePolicyDocument compares a policy document returned by iam, url
+// encoded, with a json one.
+func samePolicyDocument(current, desired string) bool {
+	if decoded, err := url.QueryUnescape(current); err == nil {
+		current = decoded
+	}
+	var a, b interface{}
+	if json.Unmarshal([]byte(current), &a) != nil || json.Unmarshal([]byte(desired), &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
 }
 
 func (ec *eks) CreateIAMPolicy(ctx context.Context, input *iam.CreatePolicyInput) (*iam.CreatePolicyOutput, error) {
@@ -57,6 +57,15 @@ func (ec *eks) UpdateNodegroup(updateNodeGroupConfig *awseks.UpdateNodegroupConf
 	return updateNodeGroupOutput, nil
 }
 
+func (ec *eks) UpdateNodegroupVersion(updateNodegroupVersion *awseks.UpdateNodegroupVersionInput) (output *awseks.UpdateNodegroupVersionOutput, err error) {
+
+	updateNodegroupVersionOutput, err := ec.awsClient.UpdateNodegroupVersion(ec.ctx, updateNodegroupVersion)
+	if err != nil {
+		return nil, err
+	}
+	return updateNodegroupVersionOutput, nil
+}
+
 func (ec *eks) DeleteNodeGroup(nodeGroupName string) (*awseks.DeleteNodegroupOutput, error) {
 
 	result, err := ec.awsClient.DeleteNodegroup(ec.ctx, &awseks.DeleteNodegroupInput{
@@ -0,0 +1,321 @@
+package eks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/tracing"
+)
+
+// tracedEks wraps every Eks method in a child span of ctx.
+type tracedEks struct {
+	ctx     context.Context
+	cluster string
+	next    Eks
+}
+
+func (t *tracedEks) start(ctx context.Context, name string) trace.Span {
+	_, span := tracing.Start(ctx, "eks."+name, attribute.String("eks.cluster", t.cluster))
+	return span
+}
+
+func (t *tracedEks) DescribeEks() (*awseks.DescribeClusterOutput, error) {
+	span := t.start(t.ctx, "DescribeEks")
+	out, err := t.next.DescribeEks()
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) CreateEks() *EksInternalOutput {
+	span := t.start(t.ctx, "CreateEks")
+	out := t.next.CreateEks()
+	tracing.End(span, internalOutputErr(out))
+	return out
+}
+
+func (t *tracedEks) UpdateEks(version string) *EksInternalOutput {
+	span := t.start(t.ctx, "UpdateEks")
+	span.SetAttributes(attribute.String("eks.version", version))
+	out := t.next.UpdateEks(version)
+	tracing.End(span, internalOutputErr(out))
+	return out
+}
+
+func (t *tracedEks) DeleteEKS() (*awseks.DeleteClusterOutput, error) {
+	span := t.start(t.ctx, "DeleteEKS")
+	out, err := t.next.DeleteEKS()
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) ListOIDCProvider() (*awsiam.ListOpenIDConnectProvidersOutput, error) {
+	span := t.start(t.ctx, "ListOIDCProvider")
+	out, err := t.next.ListOIDCProvider()
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) CreateOIDCProvider(param *CreateOIDCProviderInput) (*awsiam.CreateOpenIDConnectProviderOutput, error) {
+	span := t.start(t.ctx, "CreateOIDCProvider")
+	out, err := t.next.CreateOIDCProvider(param)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) DeleteOIDCProvider(providerArn string) (*awsiam.DeleteOpenIDConnectProviderOutput, error) {
+	span := t.start(t.ctx, "DeleteOIDCProvider")
+	out, err := t.next.DeleteOIDCProvider(providerArn)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) CreateSystemNodeGroup(nodeGroupInput awseks.CreateNodegroupInput) (*awseks.CreateNodegroupOutput, error) {
+	span := t.start(t.ctx, "CreateSystemNodeGroup")
+	out, err := t.next.CreateSystemNodeGroup(nodeGroupInput)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) DeleteNodeGroup(nodeGroupName string) (*awseks.DeleteNodegroupOutput, error) {
+	span := t.start(t.ctx, "DeleteNodeGroup")
+	span.SetAttributes(attribute.String("eks.nodegroup", nodeGroupName))
+	out, err := t.next.DeleteNodeGroup(nodeGroupName)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) DescribeNodegroup(nodeGroupName string) (*awseks.DescribeNodegroupOutput, bool, error) {
+	span := t.start(t.ctx, "DescribeNodegroup")
+	span.SetAttributes(attribute.String("eks.nodegroup", nodeGroupName))
+	out, found, err := t.next.DescribeNodegroup(nodeGroupName)
+	tracing.End(span, err)
+	return out, found, err
+}
+
+func (t *tracedEks) CreateNodegroup(createNodegroupInput *awseks.CreateNodegroupInput) (*awseks.CreateNodegroupOutput, error) {
+	span := t.start(t.ctx, "CreateNodegroup")
+	out, err := t.next.CreateNodegroup(createNodegroupInput)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) UpdateNodegroup(updateNodeGroupConfig *awseks.UpdateNodegroupConfigInput) (*awseks.UpdateNodegroupConfigOutput, error) {
+	span := t.start(t.ctx, "UpdateNodegroup")
+	out, err := t.next.UpdateNodegroup(updateNodeGroupConfig)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) UpdateNodegroupVersion(updateNodegroupVersion *awseks.UpdateNodegroupVersionInput) (*awseks.UpdateNodegroupVersionOutput, error) {
+	span := t.start(t.ctx, "UpdateNodegroupVersion")
+	span.SetAttributes(attribute.String("eks.nodegroup", aws.ToString(updateNodegroupVersion.NodegroupName)))
+	out, err := t.next.UpdateNodegroupVersion(updateNodegroupVersion)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) CreateNodeIamRole(name string) (*awsiam.GetRoleOutput, error) {
+	span := t.start(t.ctx, "CreateNodeIamRole")
+	out, err := t.next.CreateNodeIamRole(name)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) CreateClusterIamRole() (*awsiam.GetRoleOutput, error) {
+	span := t.start(t.ctx, "CreateClusterIamRole")
+	out, err := t.next.CreateClusterIamRole()
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) GetClusterNodeRoles() ([]string, error) {
+	span := t.start(t.ctx, "GetClusterNodeRoles")
+	out, err := t.next.GetClusterNodeRoles()
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) CreateAddon(ctx context.Context, params *awseks.CreateAddonInput) (*awseks.CreateAddonOutput, error) {
+	span := t.start(ctx, "CreateAddon")
+	out, err := t.next.CreateAddon(ctx, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) DescribeAddon(addonName string) (*awseks.DescribeAddonOutput, error) {
+	span := t.start(t.ctx, "DescribeAddon")
+	span.SetAttributes(attribute.String("eks.addon", addonName))
+	out, err := t.next.DescribeAddon(addonName)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) UpdateAddon(ctx context.Context, params *awseks.UpdateAddonInput) (*awseks.UpdateAddonOutput, error) {
+	span := t.start(ctx, "UpdateAddon")
+	span.SetAttributes(attribute.String("eks.addon", aws.ToString(params.AddonName)))
+	out, err := t.next.UpdateAddon(ctx, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) DeleteAddon(ctx context.Context, params *awseks.DeleteAddonInput) (*awseks.DeleteAddonOutput, error) {
+	span := t.start(ctx, "DeleteAddon")
+	span.SetAttributes(attribute.String("eks.addon", aws.ToString(params.AddonName)))
+	out, err := t.next.DeleteAddon(ctx, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) ResolveAddonVersion(ctx context.Context, addonName, version, k8sVersion string) (string, error) {
+	span := t.start(ctx, "ResolveAddonVersion")
+	span.SetAttributes(attribute.String("eks.addon", addonName))
+	out, err := t.next.ResolveAddonVersion(ctx, addonName, version, k8sVersion)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) CompatibleAddonVersions(ctx context.Context, addonName, k8sVersion string) ([]string, string, error) {
+	span := t.start(ctx, "CompatibleAddonVersions")
+	span.SetAttributes(attribute.String("eks.addon", addonName))
+	versions, defaultVersion, err := t.next.CompatibleAddonVersions(ctx, addonName, k8sVersion)
+	tracing.End(span, err)
+	return versions, defaultVersion, err
+}
+
+func (t *tracedEks) GetEksClientSet() (*kubernetes.Clientset, error) {
+	span := t.start(t.ctx, "GetEksClientSet")
+	out, err := t.next.GetEksClientSet()
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) GetRestConfig() (*rest.Config, error) {
+	span := t.start(t.ctx, "GetRestConfig")
+	out, err := t.next.GetRestConfig()
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) EnsureAddonRole(ctx context.Context, addonName string, role *v1.AddonServiceAccountRole) (string, error) {
+	span := t.start(ctx, "EnsureAddonRole")
+	span.SetAttributes(attribute.String("eks.addon", addonName))
+	arn, err := t.next.EnsureAddonRole(ctx, addonName, role)
+	tracing.End(span, err)
+	return arn, err
+}
+
+func (t *tracedEks) GetRegistryCredentials(ctx context.Context, host string) (*RegistryCredentials, error) {
+	span := t.start(ctx, "GetRegistryCredentials")
+	span.SetAttributes(attribute.String("eks.registry", host))
+	credentials, err := t.next.GetRegistryCredentials(ctx, host)
+	tracing.End(span, err)
+	return credentials, err
+}
+
+func (t *tracedEks) EnsureTenantRole(ctx context.Context, tenant, serviceAccount string, identity *v1.TenantIdentity) (string, error) {
+	span := t.start(ctx, "EnsureTenantRole")
+	span.SetAttributes(attribute.String("eks.tenant", tenant))
+	arn, err := t.next.EnsureTenantRole(ctx, tenant, serviceAccount, identity)
+	tracing.End(span, err)
+	return arn, err
+}
+
+func (t *tracedEks) DeleteTenantRole(ctx context.Context, tenant string) error {
+	span := t.start(ctx, "DeleteTenantRole")
+	span.SetAttributes(attribute.String("eks.tenant", tenant))
+	err := t.next.DeleteTenantRole(ctx, tenant)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedEks) DescribeInstances(ctx context.Context, input *awsec2.DescribeInstancesInput) (*awsec2.DescribeInstancesOutput, error) {
+	span := t.start(ctx, "DescribeInstances")
+	out, err := t.next.DescribeInstances(ctx, input)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) CreateIAMPolicy(ctx context.Context, input *awsiam.CreatePolicyInput) (*awsiam.CreatePolicyOutput, error) {
+	span := t.start(ctx, "CreateIAMPolicy")
+	out, err := t.next.CreateIAMPolicy(ctx, input)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) AttachRolePolicy(ctx context.Context, input *awsiam.AttachRolePolicyInput) (*awsiam.AttachRolePolicyOutput, error) {
+	span := t.start(ctx, "AttachRolePolicy")
+	out, err := t.next.AttachRolePolicy(ctx, input)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) EnsureKarpenterNodeRole(ctx context.Context) (*KarpenterNodeRole, error) {
+	span := t.start(ctx, "EnsureKarpenterNodeRole")
+	out, err := t.next.EnsureKarpenterNodeRole(ctx)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) EnsureKarpenterControllerRole(ctx context.Context, nodeRoleArn, queueArn string) (string, error) {
+	span := t.start(ctx, "EnsureKarpenterControllerRole")
+	arn, err := t.next.EnsureKarpenterControllerRole(ctx, nodeRoleArn, queueArn)
+	tracing.End(span, err)
+	return arn, err
+}
+
+func (t *tracedEks) EnsureInterruptionQueue(ctx context.Context) (string, string, error) {
+	span := t.start(ctx, "EnsureInterruptionQueue")
+	queueName, queueArn, err := t.next.EnsureInterruptionQueue(ctx)
+	tracing.End(span, err)
+	return queueName, queueArn, err
+}
+
+func (t *tracedEks) DeleteInterruptionQueue(ctx context.Context) error {
+	span := t.start(ctx, "DeleteInterruptionQueue")
+	err := t.next.DeleteInterruptionQueue(ctx)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedEks) MapNodeRole(ctx context.Context, roleArn string) error {
+	span := t.start(ctx, "MapNodeRole")
+	err := t.next.MapNodeRole(ctx, roleArn)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedEks) EnsureLaunchTemplate(ctx context.Context, name string, data *ec2types.RequestLaunchTemplateData, hash string) (*types.LaunchTemplateSpecification, error) {
+	span := t.start(ctx, "EnsureLaunchTemplate")
+	span.SetAttributes(attribute.String("eks.launch_template", name))
+	out, err := t.next.EnsureLaunchTemplate(ctx, name, data, hash)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedEks) DeleteLaunchTemplate(ctx context.Context, name string) error {
+	span := t.start(ctx, "DeleteLaunchTemplate")
+	span.SetAttributes(attribute.String("eks.launch_template", name))
+	err := t.next.DeleteLaunchTemplate(ctx, name)
+	tracing.End(span, err)
+	return err
+}
+
+// internalOutputErr turns a failed EksInternalOutput into an error for the span.
+func internalOutputErr(out *EksInternalOutput) error {
+	if out == nil || out.Success {
+		return nil
+	}
+	return errors.New(out.Result)
+}
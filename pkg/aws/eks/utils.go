@@ -2,13 +2,24 @@ package eks
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
 	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	awseventbridge "github.com/aws/aws-sdk-go-v2/service/eventbridge"
 	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
 	awssts "github.com/aws/aws-sdk-go-v2/service/sts"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metrics"
 )
 
 func MakeEksClusterRoleName(clusterName string) string { return clusterName + "-" + "cluster-role" }
@@ -21,8 +32,69 @@ func MakeVpcCniRoleName(region, clusterName string) string {
 	return region + "-" + clusterName + "-" + "vpccni-role"
 }
 
+// MakeAddonRoleName keeps the role names of the addons baaz installed
+// before addons were configurable.
+func MakeAddonRoleName(region, clusterName, addonName string) string {
+	switch addonName {
+	case "aws-ebs-csi-driver":
+		return MakeEBSCSIRoleName(region, clusterName)
+	case "vpc-cni":
+		return MakeVpcCniRoleName(region, clusterName)
+	}
+	return region + "-" + clusterName + "-" + addonName + "-role"
+}
+
+// maxRoleNameLength is the longest name iam accepts for a role.
+const maxRoleNameLength = 64
+
+// MakeTenantRoleName returns the role assumed by the service account of a
+// tenant. The names longer than iam accepts are truncated and end with a
+// hash of the full name, which keeps them unique.
+func MakeTenantRoleName(region, clusterName, tenant string) string {
+	name := region + "-" + clusterName + "-" + tenant + "-tenant-role"
+	if len(name) <= maxRoleNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:8]
+	return name[:maxRoleNameLength-len(hash)-1] + "-" + hash
+}
+
+func MakeKarpenterNodeRoleName(clusterName string) string {
+	return clusterName + "-" + "karpenter-node-role"
+}
+
+func MakeKarpenterControllerRoleName(region, clusterName string) string {
+	return region + "-" + clusterName + "-" + "karpenter-role"
+}
+
+// MakeInterruptionQueueName returns the sqs queue karpenter reads the
+// interruption events of the nodes from.
+func MakeInterruptionQueueName(clusterName string) string {
+	return clusterName + "-" + "karpenter"
+}
+
+// MakeTenantMachinePoolName names a tenant machine pool after its size, name
+// and instance type. It names the karpenter NodePool of the pool.
+func MakeTenantMachinePoolName(tenantName string, machineSpec v1.MachineSpec) string {
+	return strings.ReplaceAll(fmt.Sprintf("%s-%s-%s", tenantName, machineSpec.Name, machineSpec.Size), ".", "-")
+}
+
+// MakeTenantNodegroupNames returns the nodegroup of a tenant machine pool
+// and its dedicated on-demand nodegroup, used by spot pools with strict
+// scheduling. eks can not add or remove the launch template of a nodegroup,
+// so the pools using one get nodegroups of their own, which replace the
+// nodegroups created without.
+func MakeTenantNodegroupNames(tenantName string, machineSpec v1.MachineSpec) (nodegroup, dedicated string) {
+	nodegroup = MakeTenantMachinePoolName(tenantName, machineSpec)
+	if machineSpec.UsesLaunchTemplate() {
+		nodegroup += "-lt"
+	}
+	return nodegroup, nodegroup + "-dedicated"
+}
+
 func newAwsClient(ctx context.Context, region string) *awseks.Client {
-	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), metrics.WithAwsAPIMetrics(), logging.WithAwsAPILogging())
 	if err != nil {
 		log.Fatalf("unable to build AWS client, %v", err)
 	}
@@ -30,8 +102,17 @@ func newAwsClient(ctx context.Context, region string) *awseks.Client {
 	return awseks.NewFromConfig(config)
 }
 
+func newAwsEcrClient(ctx context.Context, region string) *awsecr.Client {
+	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), metrics.WithAwsAPIMetrics(), logging.WithAwsAPILogging())
+	if err != nil {
+		log.Fatalf("unable to build AWS client, %v", err)
+	}
+
+	return awsecr.NewFromConfig(config)
+}
+
 func newAwsIamClient(ctx context.Context, region string) *awsiam.Client {
-	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), metrics.WithAwsAPIMetrics(), logging.WithAwsAPILogging())
 	if err != nil {
 		log.Fatalf("unable to build AWS client, %v", err)
 	}
@@ -40,7 +121,7 @@ func newAwsIamClient(ctx context.Context, region string) *awsiam.Client {
 }
 
 func newAwsStsClient(ctx context.Context, region string) *awssts.Client {
-	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), metrics.WithAwsAPIMetrics(), logging.WithAwsAPILogging())
 	if err != nil {
 		log.Fatalf("unable to build AWS client, %v", err)
 	}
@@ -49,10 +130,28 @@ func newAwsStsClient(ctx context.Context, region string) *awssts.Client {
 }
 
 func newAwsEc2Client(ctx context.Context, region string) *awsec2.Client {
-	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), metrics.WithAwsAPIMetrics(), logging.WithAwsAPILogging())
 	if err != nil {
 		log.Fatalf("unable to build AWS client, %v", err)
 	}
 
 	return awsec2.NewFromConfig(config)
 }
+
+func newAwsSqsClient(ctx context.Context, region string) *awssqs.Client {
+	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), metrics.WithAwsAPIMetrics(), logging.WithAwsAPILogging())
+	if err != nil {
+		log.Fatalf("unable to build AWS client, %v", err)
+	}
+
+	return awssqs.NewFromConfig(config)
+}
+
+func newAwsEventBridgeClient(ctx context.Context, region string) *awseventbridge.Client {
+	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), metrics.WithAwsAPIMetrics(), logging.WithAwsAPILogging())
+	if err != nil {
+		log.Fatalf("unable to build AWS client, %v", err)
+	}
+
+	return awseventbridge.NewFromConfig(config)
+}
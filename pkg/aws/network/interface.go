@@ -7,6 +7,8 @@ import (
 	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
 	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
 	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/logging"
+	"github.com/baazhq/baaz/pkg/metrics"
 )
 
 type Network interface {
@@ -34,13 +36,15 @@ type Network interface {
 }
 
 func NewProvisioner(ctx context.Context, region string) (Network, error) {
-	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), metrics.WithAwsAPIMetrics(), logging.WithAwsAPILogging())
 	if err != nil {
 		return nil, err
 	}
 
-	return &provisioner{
-		awsec2Client: awsec2.NewFromConfig(config),
-		elbv2Client:  elbv2.NewFromConfig(config),
+	return &tracedNetwork{
+		next: &provisioner{
+			awsec2Client: awsec2.NewFromConfig(config),
+			elbv2Client:  elbv2.NewFromConfig(config),
+		},
 	}, nil
 }
@@ -0,0 +1,163 @@
+package network
+
+import (
+	"context"
+
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/tracing"
+)
+
+// tracedNetwork wraps every Network method in a child span of the ctx
+// passed to the method.
+type tracedNetwork struct {
+	next Network
+}
+
+func (t *tracedNetwork) CreateVPC(ctx context.Context, params *awsec2.CreateVpcInput) (*awsec2.CreateVpcOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.CreateVPC")
+	out, err := t.next.CreateVPC(ctx, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) CreateSubnet(ctx context.Context, params *awsec2.CreateSubnetInput) (*awsec2.CreateSubnetOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.CreateSubnet")
+	out, err := t.next.CreateSubnet(ctx, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) CreateSG(ctx context.Context, params *awsec2.CreateSecurityGroupInput) (*awsec2.CreateSecurityGroupOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.CreateSG")
+	out, err := t.next.CreateSG(ctx, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) CreateNAT(ctx context.Context, dp *v1.DataPlanes) (*awsec2.CreateNatGatewayOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.CreateNAT")
+	out, err := t.next.CreateNAT(ctx, dp)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) CreateElasticIP(ctx context.Context, params *awsec2.AllocateAddressInput) (*awsec2.AllocateAddressOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.CreateElasticIP")
+	out, err := t.next.CreateElasticIP(ctx, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) AssociateNATWithRT(ctx context.Context, dp *v1.DataPlanes) error {
+	ctx, span := tracing.Start(ctx, "network.AssociateNATWithRT")
+	err := t.next.AssociateNATWithRT(ctx, dp)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) CreateInternetGateway(ctx context.Context, params *awsec2.CreateInternetGatewayInput) (*awsec2.CreateInternetGatewayOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.CreateInternetGateway")
+	out, err := t.next.CreateInternetGateway(ctx, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) AttachInternetGateway(ctx context.Context, igId, vpcId string) (*awsec2.AttachInternetGatewayOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.AttachInternetGateway")
+	out, err := t.next.AttachInternetGateway(ctx, igId, vpcId)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) AddSGInboundRule(ctx context.Context, sgGroupId, vpcId string) (*awsec2.AuthorizeSecurityGroupIngressOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.AddSGInboundRule")
+	out, err := t.next.AddSGInboundRule(ctx, sgGroupId, vpcId)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) SubnetAutoAssignPublicIP(ctx context.Context, subnetId string) (*awsec2.ModifySubnetAttributeOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.SubnetAutoAssignPublicIP")
+	out, err := t.next.SubnetAutoAssignPublicIP(ctx, subnetId)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) CreateRouteTable(ctx context.Context, vpcId string, params *awsec2.CreateRouteTableInput) (*awsec2.CreateRouteTableOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.CreateRouteTable")
+	out, err := t.next.CreateRouteTable(ctx, vpcId, params)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) CreateRoute(ctx context.Context, input *awsec2.CreateRouteInput) (*awsec2.CreateRouteOutput, error) {
+	ctx, span := tracing.Start(ctx, "network.CreateRoute")
+	out, err := t.next.CreateRoute(ctx, input)
+	tracing.End(span, err)
+	return out, err
+}
+
+func (t *tracedNetwork) AssociateRTWithSubnet(ctx context.Context, rtId, subnetId string) error {
+	ctx, span := tracing.Start(ctx, "network.AssociateRTWithSubnet")
+	err := t.next.AssociateRTWithSubnet(ctx, rtId, subnetId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) DeleteNatGateway(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "network.DeleteNatGateway")
+	err := t.next.DeleteNatGateway(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) DetachInternetGateway(ctx context.Context, id, vpcId string) error {
+	ctx, span := tracing.Start(ctx, "network.DetachInternetGateway")
+	err := t.next.DetachInternetGateway(ctx, id, vpcId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) DeleteInternetGateway(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "network.DeleteInternetGateway")
+	err := t.next.DeleteInternetGateway(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) DeleteLBs(ctx context.Context, names []string) error {
+	ctx, span := tracing.Start(ctx, "network.DeleteLBs")
+	err := t.next.DeleteLBs(ctx, names)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) DeleteVPC(ctx context.Context, vpcId string) error {
+	ctx, span := tracing.Start(ctx, "network.DeleteVPC")
+	err := t.next.DeleteVPC(ctx, vpcId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) DeleteSubnets(ctx context.Context, subnetIds []string) error {
+	ctx, span := tracing.Start(ctx, "network.DeleteSubnets")
+	err := t.next.DeleteSubnets(ctx, subnetIds)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) DeleteSGs(ctx context.Context, vpcId string) error {
+	ctx, span := tracing.Start(ctx, "network.DeleteSGs")
+	err := t.next.DeleteSGs(ctx, vpcId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedNetwork) DeleteRouteTables(ctx context.Context, vpcId string) error {
+	ctx, span := tracing.Start(ctx, "network.DeleteRouteTables")
+	err := t.next.DeleteRouteTables(ctx, vpcId)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,144 @@
+// Package blueprint instantiates the application blueprints of the catalog.
+// The parameters of an instance are merged over the defaults of the version
+// and of the size of its tenant, validated against the schema of the
+// version and set in the values of its charts.
+package blueprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/helm"
+)
+
+// Instance is a blueprint instantiated for a tenant.
+type Instance struct {
+	// Version is the version of the blueprint instantiated.
+	Version      string
+	Applications []v1.AppSpec
+}
+
+// Render instantiates ref, an instance of bp for a tenant of size. It fails
+// when the parameters do not match the schema.
+func Render(bp *v1.ApplicationBlueprint, ref v1.BlueprintRef, size string) (*Instance, error) {
+	version := ref.Version
+	if version == "" {
+		version = bp.Spec.Version
+	}
+	var blueprint *v1.BlueprintVersion
+	for i := range bp.Spec.Versions {
+		if bp.Spec.Versions[i].Version == version {
+			blueprint = &bp.Spec.Versions[i]
+		}
+	}
+	if blueprint == nil {
+		return nil, fmt.Errorf("blueprint %s has no version %q", bp.Name, version)
+	}
+
+	schema, defaults := blueprint.Schema, []*apiextensionsv1.JSON{blueprint.Defaults}
+	if override, ok := blueprint.Sizes[size]; ok {
+		if override.Schema != nil {
+			schema = override.Schema
+		}
+		defaults = append(defaults, override.Defaults)
+	}
+
+	parameters := map[string]interface{}{}
+	for _, raw := range append(defaults, ref.Parameters) {
+		values, err := decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		parameters = helm.MergeValues(parameters, values)
+	}
+	if err := Validate(schema, parameters); err != nil {
+		return nil, err
+	}
+
+	instance := &Instance{Version: version}
+	for _, app := range blueprint.Applications {
+		spec := *app.AppSpec.DeepCopy()
+		if len(app.Parameters) > 0 {
+			values, err := decode(spec.Spec.ValuesObject)
+			if err != nil {
+				return nil, fmt.Errorf("app %s: %w", app.Name, err)
+			}
+			for path, name := range app.Parameters {
+				if value, ok := parameters[name]; ok {
+					if err := setPath(values, path, value); err != nil {
+						return nil, fmt.Errorf("app %s: %w", app.Name, err)
+					}
+				}
+			}
+			raw, err := json.Marshal(values)
+			if err != nil {
+				return nil, err
+			}
+			spec.Spec.ValuesObject = &apiextensionsv1.JSON{Raw: raw}
+		}
+		instance.Applications = append(instance.Applications, spec)
+	}
+	return instance, nil
+}
+
+// TenantSize returns the size of tenant selecting the sizes of the versions
+// of bp, empty when the tenant runs none of its app type.
+func TenantSize(bp *v1.ApplicationBlueprint, tenant *v1.Tenants) string {
+	for _, config := range tenant.Spec.TenantConfig {
+		if bp.Spec.AppType == "" || config.AppType == bp.Spec.AppType {
+			return config.Size
+		}
+	}
+	return ""
+}
+
+// Validate validates parameters against schema, an OpenAPI v3 schema. The
+// parameters are valid without any.
+func Validate(schema *apiextensionsv1.JSON, parameters map[string]interface{}) error {
+	if schema == nil || len(schema.Raw) == 0 {
+		return nil
+	}
+	s := &spec.Schema{}
+	if err := json.Unmarshal(schema.Raw, s); err != nil {
+		return fmt.Errorf("invalid parameters schema: %w", err)
+	}
+	return validate.NewSchemaValidator(s, nil, "parameters", strfmt.Default).Validate(parameters).AsError()
+}
+
+func decode(raw *apiextensionsv1.JSON) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if raw == nil || len(raw.Raw) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(raw.Raw, &values); err != nil {
+		return nil, fmt.Errorf("invalid values: %w", err)
+	}
+	return values, nil
+}
+
+// setPath sets value at the dotted path of values.
+func setPath(values map[string]interface{}, path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+	for _, key := range keys {
+		if key == "" {
+			return fmt.Errorf("invalid values path %q", path)
+		}
+	}
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			values[key] = next
+		}
+		values = next
+	}
+	values[keys[len(keys)-1]] = value
+	return nil
+}
@@ -0,0 +1,149 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func raw(t *testing.T, value interface{}) *apiextensionsv1.JSON {
+	t.Helper()
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apiextensionsv1.JSON{Raw: b}
+}
+
+func testBlueprint(t *testing.T) *v1.ApplicationBlueprint {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"replicas"},
+		"properties": map[string]interface{}{
+			"replicas": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 3},
+			"storage":  map[string]interface{}{"type": "string"},
+		},
+	}
+	return &v1.ApplicationBlueprint{
+		ObjectMeta: metav1.ObjectMeta{Name: "analytics"},
+		Spec: v1.ApplicationBlueprintSpec{
+			Version: "1.1.0",
+			AppType: v1.ApplicationType("druid"),
+			Versions: []v1.BlueprintVersion{
+				{
+					Version: "1.0.0",
+					Applications: []v1.BlueprintApplication{
+						{AppSpec: v1.AppSpec{Name: "druid", Spec: v1.ChartSpec{ChartName: "druid", Version: "0.1.0"}}},
+					},
+				},
+				{
+					Version:  "1.1.0",
+					Schema:   raw(t, schema),
+					Defaults: raw(t, map[string]interface{}{"replicas": 1, "storage": "10Gi"}),
+					Sizes: map[string]v1.BlueprintSize{
+						"large": {
+							Schema:   raw(t, map[string]interface{}{"type": "object"}),
+							Defaults: raw(t, map[string]interface{}{"replicas": 5}),
+						},
+					},
+					Applications: []v1.BlueprintApplication{
+						{
+							AppSpec: v1.AppSpec{
+								Name: "druid",
+								Spec: v1.ChartSpec{
+									ChartName:    "druid",
+									Version:      "0.2.0",
+									ValuesObject: raw(t, map[string]interface{}{"historical": map[string]interface{}{"image": "druid"}}),
+								},
+							},
+							Parameters: map[string]string{
+								"historical.replicaCount":     "replicas",
+								"historical.persistence.size": "storage",
+							},
+						},
+						{AppSpec: v1.AppSpec{Name: "ui", DependsOn: []string{"druid"}}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func historical(t *testing.T, app v1.AppSpec) map[string]interface{} {
+	t.Helper()
+	values, err := decode(app.Spec.ValuesObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values["historical"].(map[string]interface{})
+}
+
+func TestRender(t *testing.T) {
+	bp := testBlueprint(t)
+
+	instance, err := Render(bp, v1.BlueprintRef{Name: "analytics", Parameters: raw(t, map[string]interface{}{"replicas": 2})}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Version != "1.1.0" || len(instance.Applications) != 2 || instance.Applications[1].Name != "ui" {
+		t.Fatalf("expected the current version to be rendered, got %+v", instance)
+	}
+	values := historical(t, instance.Applications[0])
+	if values["replicaCount"] != float64(2) || values["image"] != "druid" ||
+		values["persistence"].(map[string]interface{})["size"] != "10Gi" {
+		t.Fatalf("expected the parameters to be set over the values, got %v", values)
+	}
+	if _, ok := historical(t, bp.Spec.Versions[1].Applications[0].AppSpec)["replicaCount"]; ok {
+		t.Fatal("expected the values of the blueprint to be left untouched")
+	}
+
+	instance, err = Render(bp, v1.BlueprintRef{Name: "analytics"}, "large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if historical(t, instance.Applications[0])["replicaCount"] != float64(5) {
+		t.Fatal("expected the defaults and the schema of the size to apply")
+	}
+
+	instance, err = Render(bp, v1.BlueprintRef{Name: "analytics", Version: "1.0.0"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Version != "1.0.0" || instance.Applications[0].Spec.Version != "0.1.0" {
+		t.Fatalf("expected the pinned version to be rendered, got %+v", instance)
+	}
+
+	for _, ref := range []v1.BlueprintRef{
+		{Name: "analytics", Parameters: raw(t, map[string]interface{}{"replicas": 5})},
+		{Name: "analytics", Parameters: raw(t, map[string]interface{}{"replicas": "two"})},
+		{Name: "analytics", Version: "2.0.0"},
+	} {
+		if _, err := Render(bp, ref, "small"); err == nil {
+			t.Fatalf("expected %s %s to be rejected", ref.Version, ref.Parameters)
+		}
+	}
+}
+
+func TestTenantSize(t *testing.T) {
+	bp := testBlueprint(t)
+	tenant := &v1.Tenants{Spec: v1.TenantsSpec{TenantConfig: []v1.TenantApplicationConfig{
+		{AppType: "kafka", Size: "small"},
+		{AppType: "druid", Size: "large"},
+	}}}
+
+	if size := TenantSize(bp, tenant); size != "large" {
+		t.Fatalf("expected the size of the app type, got %q", size)
+	}
+	bp.Spec.AppType = ""
+	if size := TenantSize(bp, tenant); size != "small" {
+		t.Fatalf("expected the first size, got %q", size)
+	}
+	bp.Spec.AppType = "pinot"
+	if size := TenantSize(bp, tenant); size != "" {
+		t.Fatalf("expected no size, got %q", size)
+	}
+}
@@ -0,0 +1,156 @@
+// Package drain cordons nodes and evicts their pods through the eviction
+// api, honoring PodDisruptionBudgets, before their nodegroup is deleted or
+// they are replaced by an upgrade.
+// A drain runs one pass per reconcile so controllers never block while pods
+// terminate.
+package drain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	kubedrain "k8s.io/kubectl/pkg/drain"
+)
+
+const (
+	// DefaultTimeout bounds the drain of a node when no timeout is set.
+	DefaultTimeout = 10 * time.Minute
+	// NodegroupLabel is set by eks on the nodes of a managed nodegroup.
+	NodegroupLabel = "eks.amazonaws.com/nodegroup"
+)
+
+type Options struct {
+	// Timeout bounds the drain of a node, defaults to DefaultTimeout.
+	Timeout time.Duration
+	// GracePeriodSeconds overrides the termination grace period of the
+	// evicted pods, nil keeps the grace period of each pod.
+	GracePeriodSeconds *int64
+}
+
+type Drainer struct {
+	client  kubernetes.Interface
+	helper  *kubedrain.Helper
+	timeout time.Duration
+}
+
+// NodeResult is the outcome of a drain pass on a node.
+type NodeResult struct {
+	// Pending counts the pods still running on the node, DaemonSet and
+	// mirror pods excluded.
+	Pending int
+	// Blocked lists the namespace/name of the pods whose eviction was
+	// refused by a PodDisruptionBudget.
+	Blocked []string
+}
+
+// Drained tells if no pod is left on the node.
+func (r NodeResult) Drained() bool {
+	return r.Pending == 0
+}
+
+func New(ctx context.Context, client kubernetes.Interface, opts Options) *Drainer {
+	gracePeriod := -1
+	if opts.GracePeriodSeconds != nil {
+		gracePeriod = int(*opts.GracePeriodSeconds)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Drainer{
+		client:  client,
+		timeout: timeout,
+		helper: &kubedrain.Helper{
+			Ctx:    ctx,
+			Client: client,
+			// the nodes are deleted with their nodegroup, so unmanaged pods
+			// and emptyDir data are lost either way
+			Force:               true,
+			DeleteEmptyDirData:  true,
+			IgnoreAllDaemonSets: true,
+			GracePeriodSeconds:  gracePeriod,
+			Out:                 io.Discard,
+			ErrOut:              io.Discard,
+		},
+	}
+}
+
+// NodegroupNodes returns the names of the nodes of an eks managed nodegroup.
+func (d *Drainer) NodegroupNodes(ctx context.Context, nodegroup string) ([]string, error) {
+	nodes, err := d.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: NodegroupLabel + "=" + nodegroup,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		names = append(names, node.Name)
+	}
+	return names, nil
+}
+
+// DrainNode cordons the node and requests the eviction of its pods. Pods
+// already terminating are not evicted again. Call it until the result is
+// drained or the drain timed out.
+func (d *Drainer) DrainNode(ctx context.Context, name string) (NodeResult, error) {
+	var result NodeResult
+
+	node, err := d.client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	if err := kubedrain.RunCordonOrUncordon(d.helper, node, true); err != nil {
+		return result, err
+	}
+
+	pods, errs := d.helper.GetPodsForDeletion(name)
+	if len(errs) > 0 {
+		return result, errors.Join(errs...)
+	}
+	return d.EvictPods(pods.Pods())
+}
+
+// EvictPods requests the eviction of pods outside of a node drain, like the
+// pods of a tenant moved to other nodes. The result counts the pods left.
+func (d *Drainer) EvictPods(pods []corev1.Pod) (NodeResult, error) {
+	var result NodeResult
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		result.Pending++
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		err := d.helper.EvictPod(pod, policyv1.SchemeGroupVersion)
+		switch {
+		case err == nil:
+		case apierrors.IsNotFound(err):
+			result.Pending--
+		case apierrors.IsTooManyRequests(err):
+			result.Blocked = append(result.Blocked, pod.Namespace+"/"+pod.Name)
+		default:
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// TimedOut tells if a drain started at startedAt ran past the timeout.
+func (d *Drainer) TimedOut(startedAt time.Time) bool {
+	return !startedAt.IsZero() && time.Since(startedAt) > d.timeout
+}
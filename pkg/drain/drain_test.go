@@ -0,0 +1,119 @@
+package drain
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func makePod(name string, mutate func(*corev1.Pod)) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "tenant",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "ReplicaSet",
+				Name:       "rs",
+				Controller: func() *bool { b := true; return &b }(),
+			}},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if mutate != nil {
+		mutate(pod)
+	}
+	return pod
+}
+
+func TestDrainNode(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-1",
+		Labels: map[string]string{NodegroupLabel: "tenant-ng"},
+	}}
+	client := fake.NewSimpleClientset(
+		node,
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "fluentbit", Namespace: "tenant"}},
+		makePod("web", nil),
+		makePod("db", nil),
+		makePod("done", func(p *corev1.Pod) { p.Status.Phase = corev1.PodSucceeded }),
+		makePod("logs", func(p *corev1.Pod) {
+			p.OwnerReferences[0].Kind = "DaemonSet"
+			p.OwnerReferences[0].Name = "fluentbit"
+		}),
+		makePod("kube-proxy", func(p *corev1.Pod) {
+			p.OwnerReferences = nil
+			p.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "mirror"}
+		}),
+	)
+
+	var evicted []string
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		name := action.(k8stesting.CreateAction).GetObject().(metav1.Object).GetName()
+		if name == "db" {
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 10)
+		}
+		evicted = append(evicted, name)
+		return true, nil, client.Tracker().Delete(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, "tenant", name)
+	})
+
+	d := New(context.TODO(), client, Options{})
+
+	nodes, err := d.NodegroupNodes(context.TODO(), "tenant-ng")
+	if err != nil || !reflect.DeepEqual(nodes, []string{"node-1"}) {
+		t.Fatalf("expected nodegroup nodes [node-1], got %v (%v)", nodes, err)
+	}
+
+	result, err := d.DrainNode(context.TODO(), "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(evicted, []string{"web"}) {
+		t.Fatalf("expected only web to be evicted, got %v", evicted)
+	}
+	if result.Drained() || result.Pending != 2 || !reflect.DeepEqual(result.Blocked, []string{"tenant/db"}) {
+		t.Fatalf("expected db blocked by its pdb, got %+v", result)
+	}
+
+	cordoned, err := client.CoreV1().Nodes().Get(context.TODO(), "node-1", metav1.GetOptions{})
+	if err != nil || !cordoned.Spec.Unschedulable {
+		t.Fatalf("expected node to be cordoned, got %+v (%v)", cordoned.Spec, err)
+	}
+
+	// the pdb allows the eviction on the next pass
+	if err := client.Tracker().Delete(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, "tenant", "db"); err != nil {
+		t.Fatal(err)
+	}
+	result, err = d.DrainNode(context.TODO(), "node-1")
+	if err != nil || !result.Drained() {
+		t.Fatalf("expected node to be drained, got %+v (%v)", result, err)
+	}
+
+	if result, err := d.DrainNode(context.TODO(), "gone"); err != nil || !result.Drained() {
+		t.Fatalf("expected missing node to be drained, got %+v (%v)", result, err)
+	}
+}
+
+func TestTimedOut(t *testing.T) {
+	d := New(context.TODO(), fake.NewSimpleClientset(), Options{Timeout: time.Minute})
+	if d.TimedOut(time.Time{}) || d.TimedOut(time.Now()) {
+		t.Fatal("expected drain not to be timed out")
+	}
+	if !d.TimedOut(time.Now().Add(-2 * time.Minute)) {
+		t.Fatal("expected drain to be timed out")
+	}
+}
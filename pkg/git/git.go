@@ -0,0 +1,172 @@
+// Package git checks out Git repositories. The local repositories, bare or
+// not, are served in process once installed, the images of baaz have no git
+// binary.
+package git
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// LocalFlag lets the git sources check out the local repositories of the
+// file system of baaz, like the ones of its image.
+const LocalFlag = "gitsource-local-repositories"
+
+var localInstalled atomic.Bool
+
+// InstallLocal serves the local repositories in process. The local urls are
+// rejected until it is called, whatever the caller.
+func InstallLocal() {
+	if localInstalled.CompareAndSwap(false, true) {
+		client.InstallProtocol("file", server.NewClient(localLoader{}))
+	}
+}
+
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Auth are the credentials of a repository over https, a token being the
+// password.
+type Auth struct {
+	Username string
+	Password string
+}
+
+// Checkout checks the ref of the repository at url out into dir, and
+// returns its commit. ref is a branch, a tag or a commit, the default branch
+// without any. The local repositories are only checked out with allowLocal,
+// once installed.
+func Checkout(ctx context.Context, url, ref string, auth *Auth, dir string, allowLocal bool) (string, error) {
+	if err := checkLocal(url, allowLocal); err != nil {
+		return "", err
+	}
+	opts := &gogit.CloneOptions{
+		URL:  url,
+		Auth: basicAuth(auth),
+	}
+	commit := commitRegexp.MatchString(ref)
+	if !commit {
+		name, err := Resolve(ctx, url, ref, auth, allowLocal)
+		if err != nil {
+			return "", err
+		}
+		opts.ReferenceName, opts.SingleBranch = name, true
+		if !local(url) {
+			// the local repositories are served without shallow clones
+			opts.Depth = 1
+		}
+	}
+
+	repo, err := gogit.PlainCloneContext(ctx, dir, false, opts)
+	if err != nil {
+		return "", fmt.Errorf("cloning %s: %w", url, err)
+	}
+	if commit {
+		worktree, err := repo.Worktree()
+		if err != nil {
+			return "", err
+		}
+		if err := worktree.Checkout(&gogit.CheckoutOptions{Hash: plumbing.NewHash(ref)}); err != nil {
+			return "", fmt.Errorf("checking %s out: %w", ref, err)
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+// Resolve returns the reference of the branch or tag ref of the repository
+// at url, the default branch without any.
+func Resolve(ctx context.Context, url, ref string, auth *Auth, allowLocal bool) (plumbing.ReferenceName, error) {
+	if err := checkLocal(url, allowLocal); err != nil {
+		return "", err
+	}
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: gogit.DefaultRemoteName,
+		URLs: []string{url},
+	})
+	refs, err := remote.ListContext(ctx, &gogit.ListOptions{Auth: basicAuth(auth)})
+	if err != nil {
+		return "", fmt.Errorf("listing the refs of %s: %w", url, err)
+	}
+
+	candidates := []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	if ref == "" {
+		candidates = []plumbing.ReferenceName{plumbing.HEAD}
+	}
+	for _, name := range candidates {
+		for _, r := range refs {
+			if r.Name() != name {
+				continue
+			}
+			if r.Type() == plumbing.SymbolicReference {
+				return r.Target(), nil
+			}
+			return r.Name(), nil
+		}
+	}
+	if ref == "" {
+		return "", fmt.Errorf("%s has no default branch", url)
+	}
+	return "", fmt.Errorf("%s has no branch or tag %s", url, ref)
+}
+
+func basicAuth(auth *Auth) transport.AuthMethod {
+	if auth == nil || (auth.Username == "" && auth.Password == "") {
+		return nil
+	}
+	username := auth.Username
+	if username == "" {
+		// the token of most hosts is accepted with any username
+		username = "git"
+	}
+	return &githttp.BasicAuth{Username: username, Password: auth.Password}
+}
+
+func checkLocal(url string, allowLocal bool) error {
+	if !local(url) {
+		return nil
+	}
+	if !allowLocal || !localInstalled.Load() {
+		return fmt.Errorf("local repository %s is not allowed", url)
+	}
+	return nil
+}
+
+func local(url string) bool {
+	endpoint, err := transport.NewEndpoint(url)
+	return err == nil && endpoint.Protocol == "file"
+}
+
+// localLoader loads the bare repositories and the .git directory of the
+// others.
+type localLoader struct{}
+
+func (localLoader) Load(endpoint *transport.Endpoint) (storer.Storer, error) {
+	path := strings.TrimSuffix(endpoint.Path, "/")
+	for _, dir := range []string{path, path + "/.git"} {
+		fs := osfs.New(dir)
+		if _, err := fs.Stat("config"); err == nil {
+			return filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil
+		}
+	}
+	return nil, transport.ErrRepositoryNotFound
+}
@@ -0,0 +1,52 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baazhq/baaz/pkg/git/gittest"
+)
+
+func TestCheckout(t *testing.T) {
+	InstallLocal()
+	repo := gittest.New(t)
+	first := repo.Commit(map[string]string{"app/version": "1"})
+	repo.Tag("v1")
+	second := repo.Commit(map[string]string{"app/version": "2"})
+	repo.Push()
+	bare := repo.Bare
+
+	for _, tc := range []struct {
+		url, ref, commit, version string
+	}{
+		{"file://" + bare, "", second, "2"},
+		{bare, "master", second, "2"},
+		{"file://" + bare, "v1", first, "1"},
+		{"file://" + bare, first, first, "1"},
+	} {
+		dir := t.TempDir()
+		commit, err := Checkout(context.Background(), tc.url, tc.ref, nil, dir, true)
+		if err != nil {
+			t.Fatalf("checking %s out: %v", tc.ref, err)
+		}
+		if commit != tc.commit {
+			t.Fatalf("expected %s to check %s out, got %s", tc.ref, tc.commit, commit)
+		}
+		version, err := os.ReadFile(filepath.Join(dir, "app", "version"))
+		if err != nil || string(version) != tc.version {
+			t.Fatalf("expected %s to check version %s out, got %q %v", tc.ref, tc.version, version, err)
+		}
+	}
+
+	if _, err := Checkout(context.Background(), "file://"+bare, "missing", nil, t.TempDir(), true); err == nil {
+		t.Fatal("expected a missing ref to fail")
+	}
+	if _, err := Checkout(context.Background(), "file://"+filepath.Join(t.TempDir(), "missing"), "", nil, t.TempDir(), true); err == nil {
+		t.Fatal("expected a missing repository to fail")
+	}
+	if _, err := Checkout(context.Background(), "file://"+bare, "", nil, t.TempDir(), false); err == nil {
+		t.Fatal("expected a local repository not allowed to fail")
+	}
+}
@@ -0,0 +1,103 @@
+// Package gittest builds local git repositories for the tests of the
+// packages checking repositories out. The tests serve them with
+// git.InstallLocal.
+package gittest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Repository is a local repository pushing to a bare one.
+type Repository struct {
+	t    testing.TB
+	repo *gogit.Repository
+
+	// Bare is the path of the bare repository.
+	Bare string
+}
+
+// New returns an empty repository and its bare remote.
+func New(t testing.TB) *Repository {
+	t.Helper()
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	if _, err := gogit.PlainInit(bare, true); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := gogit.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"file://" + bare}}); err != nil {
+		t.Fatal(err)
+	}
+	return &Repository{t: t, repo: repo, Bare: bare}
+}
+
+// URL returns the url of the bare repository.
+func (r *Repository) URL() string {
+	return "file://" + r.Bare
+}
+
+// Commit writes files into the worktree and commits them.
+func (r *Repository) Commit(files map[string]string) string {
+	r.t.Helper()
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	WriteFiles(r.t, worktree.Filesystem.Root(), files)
+	if err := worktree.AddGlob("."); err != nil {
+		r.t.Fatal(err)
+	}
+	hash, err := worktree.Commit("update", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "baaz", Email: "baaz@example.com", When: time.Now()},
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return hash.String()
+}
+
+// Tag tags the last commit.
+func (r *Repository) Tag(name string) {
+	r.t.Helper()
+	head, err := r.repo.Head()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := r.repo.CreateTag(name, head.Hash(), nil); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// Push pushes the branches and tags to the bare repository.
+func (r *Repository) Push() {
+	r.t.Helper()
+	err := r.repo.Push(&gogit.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"}})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		r.t.Fatal(err)
+	}
+}
+
+// WriteFiles writes files, by their slash separated path, under dir.
+func WriteFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Package gitops renders the entities a repository declares into the objects
+// of baaz, like khota creates them.
+//
+// Every .yaml or .yml file of the repository, its dot directories aside,
+// holds documents in the YAML of bz create -f. The names bz create takes as
+// flags are keys of the documents:
+//
+//	customer:                # bz create customer
+//	  name: foo
+//	  saas_type: dedicated
+//	  cloud_type: aws
+//	---
+//	dataplane:               # bz create dataplane
+//	  name: foo-aws          # <customer>-<cloud>-<region> by default
+//	  customerName: foo
+//	  cloudAuth:
+//	    secretName: foo-aws  # credentials are kept out of the repository
+//	  ...
+//	---
+//	dataplaneName: foo-aws   # bz create tenantsinfra --dataplane
+//	tenantsInfra:
+//	  druid-small: ...
+//	---
+//	customerName: foo        # bz create tenants --customer --tenant
+//	tenantName: analytics
+//	tenants: ...
+//	---
+//	customerName: foo        # bz create applications --customer --tenant
+//	tenantName: analytics
+//	application: [...]
+//
+// A customer joins a shared dataplane with its dataplane key, like bz add
+// dataplane.
+package gitops
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// document is a document of the repository, one of the entities set.
+type document struct {
+	Customer     *customer                 `json:"customer,omitempty"`
+	Dataplane    *dataplane                `json:"dataplane,omitempty"`
+	TenantsInfra map[string]v1.TenantSizes `json:"tenantsInfra,omitempty"`
+	Tenants      *tenant                   `json:"tenants,omitempty"`
+	Application  []application             `json:"application,omitempty"`
+
+	DataplaneName string `json:"dataplaneName,omitempty"`
+	CustomerName  string `json:"customerName,omitempty"`
+	TenantName    string `json:"tenantName,omitempty"`
+}
+
+type customer struct {
+	Name      string            `json:"name"`
+	SaaSType  v1.SaaSTypes      `json:"saas_type"`
+	CloudType v1.CloudType      `json:"cloud_type"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// Dataplane names the shared dataplane of the customer.
+	Dataplane string `json:"dataplane,omitempty"`
+}
+
+type dataplane struct {
+	Name         string       `json:"name,omitempty"`
+	CloudType    v1.CloudType `json:"cloudType"`
+	CloudRegion  string       `json:"cloudRegion"`
+	CustomerName string       `json:"customerName,omitempty"`
+	// SaasType is the saas type of the customer, read from the customer.
+	SaasType          string           `json:"saasType,omitempty"`
+	CloudAuth         cloudAuth        `json:"cloudAuth,omitempty"`
+	ProvisionNetwork  bool             `json:"provisionNetwork,omitempty"`
+	VpcCidr           string           `json:"vpcCidr,omitempty"`
+	KubernetesConfig  kubernetesConfig `json:"kubernetesConfig,omitempty"`
+	ApplicationConfig []application    `json:"applicationConfig,omitempty"`
+}
+
+type cloudAuth struct {
+	// AwsAuth is rejected, the credentials are kept out of the repository.
+	AwsAuth *awsAuth `json:"awsAuth,omitempty"`
+	// SecretName names a Secret of the namespace of the dataplane holding
+	// the accessKey and secretKey keys.
+	SecretName string `json:"secretName,omitempty"`
+}
+
+type awsAuth struct {
+	AwsAccessKey string `json:"awsAccessKey"`
+	AwsSecretKey string `json:"awsSecretKey"`
+}
+
+type kubernetesConfig struct {
+	Eks v1.EksConfig `json:"eks"`
+}
+
+type tenant struct {
+	NetworkSecurity v1.NetworkConfig   `json:"networkSecurity,omitempty"`
+	Application     tenantApplication  `json:"application"`
+	Quota           *v1.QuotaSpec      `json:"quota,omitempty"`
+	Identity        *v1.TenantIdentity `json:"identity,omitempty"`
+}
+
+type tenantApplication struct {
+	Name    string `json:"name"`
+	AppSize string `json:"appSize"`
+}
+
+type application struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace,omitempty"`
+	v1.ChartSpec `json:",inline"`
+	DependsOn    []string            `json:"dependsOn,omitempty"`
+	Readiness    []v1.ReadinessCheck `json:"readiness,omitempty"`
+	Source       *v1.ManifestSource  `json:"source,omitempty"`
+}
+
+// entities are the entities of a repository, by name.
+type entities struct {
+	customers    map[string]*customer
+	dataplanes   map[string]*dataplane
+	tenantsInfra map[string]map[string]v1.TenantSizes
+	tenants      map[[2]string]*tenant
+	applications map[[2]string][]application
+}
+
+// parse reads the entities of the files of dir.
+func parse(dir string) (*entities, error) {
+	e := &entities{
+		customers:    make(map[string]*customer),
+		dataplanes:   make(map[string]*dataplane),
+		tenantsInfra: make(map[string]map[string]v1.TenantSizes),
+		tenants:      make(map[[2]string]*tenant),
+		applications: make(map[[2]string][]application),
+	}
+
+	var names []string
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml":
+			names = append(names, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := e.parseFile(name); err != nil {
+			rel, _ := filepath.Rel(dir, name)
+			errs = append(errs, fmt.Errorf("%s: %w", rel, err))
+		}
+	}
+	return e, errors.Join(errs...)
+}
+
+func (e *entities) parseFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		data, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var doc document
+		if err := yaml.UnmarshalStrict(data, &doc); err != nil {
+			return err
+		}
+		if err := e.add(doc); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *entities) add(doc document) error {
+	switch {
+	case doc.Customer != nil:
+		if doc.Customer.Name == "" {
+			return errors.New("customer has no name")
+		}
+		if _, exists := e.customers[doc.Customer.Name]; exists {
+			return fmt.Errorf("customer %s is declared twice", doc.Customer.Name)
+		}
+		e.customers[doc.Customer.Name] = doc.Customer
+	case doc.Dataplane != nil:
+		name := dataplaneName(doc.Dataplane)
+		if _, exists := e.dataplanes[name]; exists {
+			return fmt.Errorf("dataplane %s is declared twice", name)
+		}
+		e.dataplanes[name] = doc.Dataplane
+	case doc.TenantsInfra != nil:
+		if doc.DataplaneName == "" {
+			return errors.New("tenantsInfra has no dataplaneName")
+		}
+		if _, exists := e.tenantsInfra[doc.DataplaneName]; exists {
+			return fmt.Errorf("tenantsInfra of %s is declared twice", doc.DataplaneName)
+		}
+		e.tenantsInfra[doc.DataplaneName] = doc.TenantsInfra
+	case doc.Tenants != nil:
+		if doc.CustomerName == "" || doc.TenantName == "" {
+			return errors.New("tenants has no customerName or tenantName")
+		}
+		key := [2]string{doc.CustomerName, doc.TenantName}
+		if _, exists := e.tenants[key]; exists {
+			return fmt.Errorf("tenant %s of %s is declared twice", doc.TenantName, doc.CustomerName)
+		}
+		e.tenants[key] = doc.Tenants
+	case doc.Application != nil:
+		if doc.CustomerName == "" || doc.TenantName == "" {
+			return errors.New("application has no customerName or tenantName")
+		}
+		key := [2]string{doc.CustomerName, doc.TenantName}
+		if _, exists := e.applications[key]; exists {
+			return fmt.Errorf("applications of tenant %s of %s are declared twice", doc.TenantName, doc.CustomerName)
+		}
+		e.applications[key] = doc.Application
+	default:
+		return errors.New("document declares no customer, dataplane, tenantsInfra, tenants or application")
+	}
+	return nil
+}
+
+// dataplaneName returns the name of a dataplane, like khota names them
+// without their random suffix.
+func dataplaneName(dp *dataplane) string {
+	if dp.Name != "" {
+		return dp.Name
+	}
+	if dp.CustomerName == "" {
+		return string(dp.CloudType) + "-" + dp.CloudRegion
+	}
+	return dp.CustomerName + "-" + string(dp.CloudType) + "-" + dp.CloudRegion
+}
@@ -0,0 +1,485 @@
+package gitops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+const (
+	// SourceLabel names the GitSource of the objects rendered, they are only
+	// pruned with it. Its value is set by SourceLabelValue.
+	SourceLabel = "baaz.dev/gitsource"
+	// HashAnnotation is the hash of the object rendered, it changes with its
+	// entity.
+	HashAnnotation = "baaz.dev/gitops-hash"
+)
+
+// ClusterKinds are the cluster-scoped kinds rendered, the namespaces of the
+// customers and the roles of their service accounts.
+var ClusterKinds = []schema.GroupKind{
+	{Kind: "Namespace"},
+	{Group: rbacv1.GroupName, Kind: "ClusterRole"},
+	{Group: rbacv1.GroupName, Kind: "ClusterRoleBinding"},
+}
+
+const (
+	sharedNamespace      = "shared"
+	dataplaneUnavailable = "unavailable"
+	labelPrefix          = "baaz_"
+	accessKey            = "accessKey"
+	secretKey            = "secretKey"
+)
+
+// Render renders the entities declared in the files of dir into objects.
+// Nothing is rendered when an entity is invalid, so that the objects of
+// the others are not pruned.
+func Render(dir string) ([]*unstructured.Unstructured, error) {
+	e, err := parse(dir)
+	if err != nil {
+		return nil, err
+	}
+	return e.render()
+}
+
+// resolved are the relations of the entities.
+type resolved struct {
+	// dataplanes of the customers, unavailable without any
+	customerDataplane map[string]string
+	// customers and tenants of the dataplanes
+	dataplaneCustomers map[string][]string
+	dataplaneTenants   map[string][]string
+}
+
+func (e *entities) resolve() (*resolved, error) {
+	r := &resolved{
+		customerDataplane:  make(map[string]string),
+		dataplaneCustomers: make(map[string][]string),
+		dataplaneTenants:   make(map[string][]string),
+	}
+	var errs []error
+
+	for _, name := range sortedKeys(e.dataplanes) {
+		dp := e.dataplanes[name]
+		switch {
+		case dp.CloudAuth.AwsAuth != nil:
+			errs = append(errs, fmt.Errorf("dataplane %s: awsAuth puts the credentials in the repository, cloudAuth.secretName names their Secret", name))
+			continue
+		case dp.CloudAuth.SecretName == "":
+			errs = append(errs, fmt.Errorf("dataplane %s: cloudAuth has no secretName", name))
+			continue
+		}
+		if dp.CustomerName == "" {
+			continue
+		}
+		if _, ok := e.customers[dp.CustomerName]; !ok {
+			errs = append(errs, fmt.Errorf("dataplane %s: customer %s is not declared", name, dp.CustomerName))
+			continue
+		}
+		if other, ok := r.customerDataplane[dp.CustomerName]; ok {
+			errs = append(errs, fmt.Errorf("customer %s has dataplanes %s and %s", dp.CustomerName, other, name))
+			continue
+		}
+		r.customerDataplane[dp.CustomerName] = name
+		r.dataplaneCustomers[name] = append(r.dataplaneCustomers[name], dp.CustomerName)
+	}
+
+	for _, name := range sortedKeys(e.customers) {
+		c := e.customers[name]
+		if c.Dataplane == "" {
+			continue
+		}
+		dp, ok := e.dataplanes[c.Dataplane]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("customer %s: dataplane %s is not declared", name, c.Dataplane))
+		case dp.CustomerName != "" && dp.CustomerName != name:
+			errs = append(errs, fmt.Errorf("customer %s: dataplane %s is the dataplane of %s", name, c.Dataplane, dp.CustomerName))
+		case dp.CustomerName == "":
+			r.customerDataplane[name] = c.Dataplane
+			r.dataplaneCustomers[c.Dataplane] = append(r.dataplaneCustomers[c.Dataplane], name)
+		}
+	}
+
+	for _, name := range sortedKeys(e.tenantsInfra) {
+		if _, ok := e.dataplanes[name]; !ok {
+			errs = append(errs, fmt.Errorf("tenantsInfra: dataplane %s is not declared", name))
+		}
+	}
+
+	for _, key := range sortedPairs(e.tenants) {
+		dp, err := r.dataplaneOf(e, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", key[1], err))
+			continue
+		}
+		r.dataplaneTenants[dp] = append(r.dataplaneTenants[dp], key[1])
+	}
+	for _, key := range sortedPairs(e.applications) {
+		if _, err := r.dataplaneOf(e, key); err != nil {
+			errs = append(errs, fmt.Errorf("applications of tenant %s: %w", key[1], err))
+		}
+	}
+	return r, errors.Join(errs...)
+}
+
+// dataplaneOf returns the dataplane of the customer of a tenant.
+func (r *resolved) dataplaneOf(e *entities, key [2]string) (string, error) {
+	if _, ok := e.customers[key[0]]; !ok {
+		return "", fmt.Errorf("customer %s is not declared", key[0])
+	}
+	dp, ok := r.customerDataplane[key[0]]
+	if !ok {
+		return "", fmt.Errorf("customer %s has no dataplane", key[0])
+	}
+	return dp, nil
+}
+
+func (e *entities) render() ([]*unstructured.Unstructured, error) {
+	r, err := e.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	for _, name := range sortedKeys(e.customers) {
+		objects = append(objects, e.renderCustomer(r, name)...)
+	}
+	for _, name := range sortedKeys(e.dataplanes) {
+		objects = append(objects, e.renderDataplane(r, name)...)
+	}
+	for _, name := range sortedKeys(e.tenantsInfra) {
+		objects = append(objects, e.renderTenantsInfra(name))
+	}
+	for _, key := range sortedPairs(e.tenants) {
+		objects = append(objects, e.renderTenant(r, key))
+	}
+	for _, key := range sortedPairs(e.applications) {
+		objects = append(objects, e.renderApplications(r, key))
+	}
+
+	var rendered []*unstructured.Unstructured
+	for _, object := range objects {
+		u, err := toUnstructured(object)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, u)
+	}
+	return rendered, nil
+}
+
+// saasType returns the saas type of the customers of a dataplane, shared
+// without any.
+func (e *entities) saasType(dpName string) v1.SaaSTypes {
+	if dp := e.dataplanes[dpName]; dp.CustomerName != "" {
+		return e.customers[dp.CustomerName].SaaSType
+	}
+	return v1.SharedSaaS
+}
+
+// namespace returns the namespace of the objects of a dataplane.
+func (e *entities) namespace(dpName string) string {
+	if dp := e.dataplanes[dpName]; dp.CustomerName != "" {
+		return dp.CustomerName
+	}
+	return sharedNamespace
+}
+
+func (e *entities) renderCustomer(r *resolved, name string) []runtime.Object {
+	c := e.customers[name]
+	dp, ok := r.customerDataplane[name]
+	if !ok {
+		dp = dataplaneUnavailable
+	}
+
+	chartLabels := map[string]string{
+		"saas_type":     string(c.SaaSType),
+		"cloud_type":    string(c.CloudType),
+		"customer_name": name,
+		"controlplane":  "baaz",
+	}
+	labels := merge(chartLabels, map[string]string{"dataplane": dp})
+	if c.Labels[v1.PrivateModeNSLabelKey] == "true" {
+		labels[v1.PrivateModeNSLabelKey] = "true"
+	}
+	for key, value := range c.Labels {
+		labels[labelPrefix+key] = value
+	}
+
+	// the objects of the customer chart, its service account logs the
+	// dataplanes of private customers in
+	meta := metav1.ObjectMeta{Name: name, Namespace: name, Labels: chartLabels}
+	return []runtime.Object{
+		&corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		},
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta,
+		},
+		&corev1.Secret{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   name,
+				Labels:      chartLabels,
+				Annotations: map[string]string{corev1.ServiceAccountNameKey: name},
+			},
+			Type: corev1.SecretTypeServiceAccountToken,
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: chartLabels},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"baaz.dev"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+				{APIGroups: []string{"baaz.dev"}, Resources: []string{"dataplanes/status"}, Verbs: []string{"get", "patch", "update"}},
+				{
+					APIGroups: []string{""},
+					Resources: []string{"secrets", "pods", "configmaps", "namespaces", "serviceaccounts", "clusterrolebindings"},
+					Verbs:     []string{"*"},
+				},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: chartLabels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: name}},
+		},
+	}
+}
+
+func (e *entities) renderDataplane(r *resolved, name string) []runtime.Object {
+	dp := e.dataplanes[name]
+	namespace := e.namespace(name)
+	saasType := e.saasType(name)
+
+	labels := map[string]string{
+		"version":        dp.KubernetesConfig.Eks.Version,
+		"cloud_type":     string(dp.CloudType),
+		"cloud_region":   dp.CloudRegion,
+		"dataplane_type": string(saasType),
+	}
+	if saasType == v1.PrivateSaaS {
+		labels[v1.PrivateObjectLabelKey] = "true"
+	}
+	for _, customer := range r.dataplaneCustomers[name] {
+		labels["customer_"+customer] = customer
+	}
+	for _, tenant := range r.dataplaneTenants[name] {
+		labels["tenant_"+tenant] = tenant
+	}
+
+	eks := dp.KubernetesConfig.Eks
+	eks.Name = name
+	var apps []v1.AppSpec
+	for _, app := range dp.ApplicationConfig {
+		apps = append(apps, appSpec(app))
+	}
+
+	return []runtime.Object{&v1.DataPlanes{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1.GroupVersion.String(), Kind: "DataPlanes"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: v1.DataPlaneSpec{
+			CloudInfra: v1.CloudInfraConfig{
+				CloudType: dp.CloudType,
+				Region:    dp.CloudRegion,
+				AwsCloudInfraConfig: v1.AwsCloudInfraConfig{
+					AuthSecretRef: v1.AWSAuthSecretRef{
+						SecretName:    dp.CloudAuth.SecretName,
+						AccessKeyName: accessKey,
+						SecretKeyName: secretKey,
+					},
+					ProvisionNetwork: dp.ProvisionNetwork,
+					VpcCidr:          dp.VpcCidr,
+					Eks:              eks,
+				},
+			},
+			Applications: apps,
+		},
+	}}
+}
+
+func (e *entities) renderTenantsInfra(dpName string) runtime.Object {
+	labels := map[string]string{"dataplane_name": dpName}
+	if e.saasType(dpName) == v1.PrivateSaaS {
+		labels[v1.PrivateObjectLabelKey] = "true"
+	}
+	return &v1.TenantsInfra{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1.GroupVersion.String(), Kind: "TenantsInfra"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dpName + "-tenantinfra",
+			Namespace: e.namespace(dpName),
+			Labels:    labels,
+		},
+		Spec: v1.TenantsInfraSpec{
+			Dataplane:   dpName,
+			TenantSizes: e.tenantsInfra[dpName],
+		},
+	}
+}
+
+func (e *entities) renderTenant(r *resolved, key [2]string) runtime.Object {
+	customer, name := key[0], key[1]
+	t := e.tenants[key]
+	dp := r.customerDataplane[customer]
+
+	labels := map[string]string{
+		"dataplane":            dp,
+		"customer_" + customer: customer,
+		"application":          t.Application.Name,
+		"size":                 t.Application.AppSize,
+	}
+	if e.saasType(dp) == v1.PrivateSaaS {
+		labels[v1.PrivateObjectLabelKey] = "true"
+	}
+
+	network := t.NetworkSecurity
+	network.Enabled = network.InterNamespaceTraffic == v1.Deny
+	return &v1.Tenants{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1.GroupVersion.String(), Kind: "Tenants"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: customer, Labels: labels},
+		Spec: v1.TenantsSpec{
+			DataplaneName: dp,
+			TenantConfig: []v1.TenantApplicationConfig{{
+				AppType: v1.ApplicationType(t.Application.Name),
+				Size:    t.Application.AppSize,
+			}},
+			Isolation: v1.IsolationConfig{Network: network},
+			Quota:     t.Quota,
+			Identity:  t.Identity,
+		},
+	}
+}
+
+func (e *entities) renderApplications(r *resolved, key [2]string) runtime.Object {
+	customer, tenant := key[0], key[1]
+
+	var labels map[string]string
+	if e.customers[customer].SaaSType == v1.PrivateSaaS {
+		labels = map[string]string{
+			"customer_" + customer:   customer,
+			v1.PrivateObjectLabelKey: "true",
+			"tenant":                 tenant,
+		}
+	}
+
+	var apps []v1.AppSpec
+	for _, app := range e.applications[key] {
+		apps = append(apps, appSpec(app))
+	}
+	return &v1.Applications{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1.GroupVersion.String(), Kind: "Applications"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      customer + "-" + tenant + "-apps",
+			Namespace: customer,
+			Labels:    labels,
+		},
+		Spec: v1.ApplicationSpec{
+			Dataplane:    r.customerDataplane[customer],
+			Tenant:       tenant,
+			Applications: apps,
+		},
+	}
+}
+
+func appSpec(app application) v1.AppSpec {
+	spec := v1.AppSpec{
+		Name:      app.Name,
+		Namespace: app.Namespace,
+		DependsOn: app.DependsOn,
+		Readiness: app.Readiness,
+	}
+	if app.Source != nil {
+		spec.Source = app.Source
+	} else {
+		spec.Spec = app.ChartSpec
+	}
+	return spec
+}
+
+// toUnstructured converts object without its status, set by the
+// controllers, and annotates it with its hash.
+func toUnstructured(object runtime.Object) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	unstructured.RemoveNestedField(u.Object, "status")
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+
+	data, err := json.Marshal(u.Object)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	u.SetAnnotations(merge(u.GetAnnotations(), map[string]string{HashAnnotation: hex.EncodeToString(sum[:])}))
+	return u, nil
+}
+
+// SourceLabelValue is the value of SourceLabel for the GitSource name of
+// namespace. The GitSources of different namespaces may have the same name
+// and the namespaces have no dots.
+func SourceLabelValue(namespace, name string) string {
+	return namespace + "." + name
+}
+
+// SourceOf returns the namespace/name of the GitSource managing an object
+// from its labels, empty when none does.
+func SourceOf(labels map[string]string) string {
+	value := labels[SourceLabel]
+	if namespace, name, ok := strings.Cut(value, "."); ok {
+		return namespace + "/" + name
+	}
+	return value
+}
+
+func merge(m1, m2 map[string]string) map[string]string {
+	merged := make(map[string]string, len(m1)+len(m2))
+	for key, value := range m1 {
+		merged[key] = value
+	}
+	for key, value := range m2 {
+		merged[key] = value
+	}
+	return merged
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedPairs[T any](m map[[2]string]T) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
@@ -0,0 +1,249 @@
+package gitops
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/baazhq/baaz/pkg/git"
+	"github.com/baazhq/baaz/pkg/git/gittest"
+)
+
+const fleet = `
+customer:
+  name: foo
+  saas_type: dedicated
+  cloud_type: aws
+  labels:
+    tier: gold
+---
+dataplane:
+  customerName: foo
+  cloudType: aws
+  cloudRegion: us-east-1
+  cloudAuth:
+    secretName: foo-aws
+  kubernetesConfig:
+    eks:
+      version: "1.29"
+---
+customer:
+  name: bar
+  saas_type: shared
+  cloud_type: aws
+  dataplane: aws-us-west-2
+---
+dataplane:
+  cloudType: aws
+  cloudRegion: us-west-2
+  cloudAuth:
+    secretName: aws-us-west-2
+`
+
+const tenants = `
+dataplaneName: foo-aws-us-east-1
+tenantsInfra:
+  druid-small:
+    machinePool:
+    - name: small
+      size: t3.medium
+      min: 1
+      max: 3
+---
+customerName: foo
+tenantName: analytics
+tenants:
+  application:
+    name: druid
+    appSize: druid-small
+  networkSecurity:
+    interNamespaceTraffic: Deny
+---
+customerName: foo
+tenantName: analytics
+application:
+- name: druid
+  chartName: druid
+  repoUrl: https://charts.example.com
+  version: 1.0.0
+`
+
+func find(objects []*unstructured.Unstructured, kind, name string) *unstructured.Unstructured {
+	for _, object := range objects {
+		if object.GetKind() == kind && object.GetName() == name {
+			return object
+		}
+	}
+	return nil
+}
+
+func TestRender(t *testing.T) {
+	git.InstallLocal()
+	repo := gittest.New(t)
+	repo.Commit(map[string]string{
+		"fleet/customers.yaml":  fleet,
+		"fleet/foo/tenants.yml": tenants,
+		"fleet/.github/ci.yaml": "on: push",
+		"fleet/README.md":       "# fleet",
+		"other/unrelated.yaml":  "kind: ConfigMap",
+	})
+	repo.Push()
+	dir := t.TempDir()
+	if _, err := git.Checkout(context.Background(), repo.URL(), "", nil, dir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := Render(filepath.Join(dir, "fleet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, object := range objects {
+		got = append(got, object.GetKind()+" "+object.GetNamespace()+"/"+object.GetName())
+		if object.GetAnnotations()[HashAnnotation] == "" {
+			t.Errorf("%s %s has no hash", object.GetKind(), object.GetName())
+		}
+		if _, ok := object.Object["status"]; ok {
+			t.Errorf("%s %s has a status", object.GetKind(), object.GetName())
+		}
+	}
+	want := []string{
+		"Namespace /bar", "ServiceAccount bar/bar", "Secret bar/bar", "ClusterRole /bar", "ClusterRoleBinding /bar",
+		"Namespace /foo", "ServiceAccount foo/foo", "Secret foo/foo", "ClusterRole /foo", "ClusterRoleBinding /foo",
+		"DataPlanes shared/aws-us-west-2",
+		"DataPlanes foo/foo-aws-us-east-1",
+		"TenantsInfra foo/foo-aws-us-east-1-tenantinfra",
+		"Tenants foo/analytics",
+		"Applications foo/foo-analytics-apps",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got objects\n%v\nwant\n%v", got, want)
+	}
+
+	labels := find(objects, "Namespace", "foo").GetLabels()
+	if labels["dataplane"] != "foo-aws-us-east-1" || labels["baaz_tier"] != "gold" {
+		t.Errorf("got namespace labels %v", labels)
+	}
+	if dp := find(objects, "Namespace", "bar").GetLabels()["dataplane"]; dp != "aws-us-west-2" {
+		t.Errorf("got dataplane %q of the shared customer", dp)
+	}
+	labels = find(objects, "DataPlanes", "foo-aws-us-east-1").GetLabels()
+	if labels["customer_foo"] != "foo" || labels["tenant_analytics"] != "analytics" || labels["dataplane_type"] != "dedicated" {
+		t.Errorf("got dataplane labels %v", labels)
+	}
+	secret, _, _ := unstructured.NestedString(find(objects, "DataPlanes", "foo-aws-us-east-1").Object,
+		"spec", "cloudInfra", "authSecretRef", "secretName")
+	if secret != "foo-aws" {
+		t.Errorf("got auth secret %q", secret)
+	}
+	enabled, _, _ := unstructured.NestedBool(find(objects, "Tenants", "analytics").Object,
+		"spec", "isolation", "network", "enabled")
+	if !enabled {
+		t.Error("network isolation of the tenant is not enabled")
+	}
+}
+
+func TestRenderHash(t *testing.T) {
+	render := func(version string) string {
+		dir := t.TempDir()
+		gittest.WriteFiles(t, dir, map[string]string{"fleet.yaml": strings.Replace(fleet, `"1.29"`, version, 1)})
+		objects, err := Render(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return find(objects, "DataPlanes", "foo-aws-us-east-1").GetAnnotations()[HashAnnotation]
+	}
+	if render(`"1.29"`) != render(`"1.29"`) {
+		t.Error("hash changed without the dataplane")
+	}
+	if render(`"1.29"`) == render(`"1.30"`) {
+		t.Error("hash did not change with the dataplane")
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name:  "unknown field",
+			files: map[string]string{"a.yaml": "customer:\n  name: foo\n  saas_typ: dedicated\n"},
+			err:   `unknown field "saas_typ"`,
+		},
+		{
+			name:  "inline credentials",
+			files: map[string]string{"a.yaml": "dataplane:\n  name: aws\n  cloudAuth:\n    awsAuth:\n      awsAccessKey: access\n      awsSecretKey: secret\n"},
+			err:   "dataplane aws: awsAuth puts the credentials in the repository",
+		},
+		{
+			name:  "no credentials",
+			files: map[string]string{"a.yaml": "dataplane:\n  name: aws\n"},
+			err:   "dataplane aws: cloudAuth has no secretName",
+		},
+		{
+			name:  "no entity",
+			files: map[string]string{"a.yaml": "customerName: foo\n"},
+			err:   "declares no customer",
+		},
+		{
+			name: "duplicate",
+			files: map[string]string{
+				"a.yaml": "customer:\n  name: foo\n",
+				"b.yaml": "customer:\n  name: foo\n",
+			},
+			err: "b.yaml: customer foo is declared twice",
+		},
+		{
+			name:  "undeclared customer",
+			files: map[string]string{"a.yaml": tenants + "---\n" + fleet[strings.Index(fleet, "dataplane:\n  customerName"):]},
+			err:   "dataplane foo-aws-us-east-1: customer foo is not declared",
+		},
+		{
+			name:  "undeclared dataplane",
+			files: map[string]string{"a.yaml": "customer:\n  name: foo\n  dataplane: aws-us-west-2\n"},
+			err:   "customer foo: dataplane aws-us-west-2 is not declared",
+		},
+		{
+			name: "no dataplane",
+			files: map[string]string{
+				"a.yaml": "customer:\n  name: foo\n---\ncustomerName: foo\ntenantName: analytics\napplication: []\n",
+			},
+			err: "applications of tenant analytics: customer foo has no dataplane",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			gittest.WriteFiles(t, dir, tc.files)
+			objects, err := Render(dir)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("got error %v, want %q", err, tc.err)
+			}
+			if objects != nil {
+				t.Errorf("got objects with an error")
+			}
+		})
+	}
+}
+
+func TestSourceOf(t *testing.T) {
+	for _, tc := range []struct {
+		labels map[string]string
+		want   string
+	}{
+		{nil, ""},
+		{map[string]string{SourceLabel: SourceLabelValue("ops", "fleet.eu")}, "ops/fleet.eu"},
+		// labelled before the namespace was part of the value
+		{map[string]string{SourceLabel: "fleet"}, "fleet"},
+	} {
+		if got := SourceOf(tc.labels); got != tc.want {
+			t.Errorf("got %q, expected %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+// Package health tells the health of the resources of an app on its
+// dataplane, from the status of its workloads, jobs and claims and from
+// the pods they run.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/manifests"
+)
+
+// PendingTimeout is the time a claim stays pending before its resource is
+// degraded, the provisioning of a volume taking a while.
+const PendingTimeout = 5 * time.Minute
+
+// inspected are the kinds having a health, the others are not reported.
+var inspected = map[schema.GroupKind]bool{
+	{Group: "apps", Kind: "Deployment"}:        true,
+	{Group: "apps", Kind: "StatefulSet"}:       true,
+	{Group: "apps", Kind: "DaemonSet"}:         true,
+	{Group: "batch", Kind: "Job"}:              true,
+	{Group: "", Kind: "PersistentVolumeClaim"}: true,
+	{Group: "", Kind: "Pod"}:                   true,
+}
+
+// failing are the reasons of the containers waiting to be restarted or
+// never started.
+var failing = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// Object is a resource of an app having a health.
+type Object struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Resource is the health of an object, with its pods.
+type Resource struct {
+	Object
+	Health  v1.HealthStatus `json:"health"`
+	Message string          `json:"message,omitempty"`
+	Pods    []Pod           `json:"pods,omitempty"`
+}
+
+type Pod struct {
+	Name     string          `json:"name"`
+	Phase    corev1.PodPhase `json:"phase"`
+	Ready    bool            `json:"ready"`
+	Restarts int32           `json:"restarts"`
+	// Reason is why the pod is not ready, like CrashLoopBackOff or
+	// Unschedulable.
+	Reason string          `json:"reason,omitempty"`
+	Health v1.HealthStatus `json:"health"`
+	// Events are the latest events of the pod, newest first, with
+	// WithEvents only.
+	Events []Event `json:"events,omitempty"`
+}
+
+type Event struct {
+	Type     string    `json:"type"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// ManifestObjects returns the objects of the manifest of a release having a
+// health, in namespace unless they set theirs.
+func ManifestObjects(manifest, namespace string) ([]Object, error) {
+	decoded, err := manifests.Decode([]byte(manifest))
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, object := range decoded {
+		gvk := object.GroupVersionKind()
+		if !inspected[gvk.GroupKind()] {
+			continue
+		}
+		ns := object.GetNamespace()
+		if ns == "" {
+			ns = namespace
+		}
+		objects = append(objects, Object{Kind: gvk.Kind, Namespace: ns, Name: object.GetName()})
+	}
+	return objects, nil
+}
+
+// AppliedObjects returns the objects applied for an app with a source
+// having a health.
+func AppliedObjects(resources []v1.ResourceStatus) []Object {
+	var objects []Object
+	for _, resource := range resources {
+		gv, err := schema.ParseGroupVersion(resource.APIVersion)
+		if err != nil || resource.Phase != v1.AppliedRS || !inspected[gv.WithKind(resource.Kind).GroupKind()] {
+			continue
+		}
+		objects = append(objects, Object{Kind: resource.Kind, Namespace: resource.Namespace, Name: resource.Name})
+	}
+	return objects
+}
+
+// Inspect returns the health of objects on the cluster of clientset, the
+// missing ones being degraded. now ages the pending claims.
+func Inspect(ctx context.Context, clientset kubernetes.Interface, objects []Object, now time.Time) ([]Resource, error) {
+	resources := make([]Resource, 0, len(objects))
+	for _, object := range objects {
+		resource, err := inspect(ctx, clientset, object, now)
+		if apierrors.IsNotFound(err) {
+			resource = Resource{Object: object, Health: v1.DegradedH, Message: "not found"}
+		} else if err != nil {
+			return nil, fmt.Errorf("%s %s/%s: %w", object.Kind, object.Namespace, object.Name, err)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+func inspect(ctx context.Context, clientset kubernetes.Interface, object Object, now time.Time) (Resource, error) {
+	r := Resource{Object: object, Health: v1.HealthyH}
+	var selector *metav1.LabelSelector
+
+	switch object.Kind {
+	case "Deployment":
+		deployment, err := clientset.AppsV1().Deployments(object.Namespace).Get(ctx, object.Name, metav1.GetOptions{})
+		if err != nil {
+			return r, err
+		}
+		r.Health, r.Message = deploymentHealth(deployment)
+		selector = deployment.Spec.Selector
+	case "StatefulSet":
+		statefulSet, err := clientset.AppsV1().StatefulSets(object.Namespace).Get(ctx, object.Name, metav1.GetOptions{})
+		if err != nil {
+			return r, err
+		}
+		r.Health, r.Message = statefulSetHealth(statefulSet)
+		selector = statefulSet.Spec.Selector
+		// the claims of the replicas, named like <template>-<statefulset>-<ordinal>
+		for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+			for i := int32(0); i < replicas(statefulSet.Spec.Replicas); i++ {
+				name := fmt.Sprintf("%s-%s-%d", template.Name, statefulSet.Name, i)
+				claim, err := clientset.CoreV1().PersistentVolumeClaims(object.Namespace).Get(ctx, name, metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				if err != nil {
+					return r, err
+				}
+				health, message := claimHealth(claim, now)
+				worsen(&r, health, "claim "+name+" "+message)
+			}
+		}
+	case "DaemonSet":
+		daemonSet, err := clientset.AppsV1().DaemonSets(object.Namespace).Get(ctx, object.Name, metav1.GetOptions{})
+		if err != nil {
+			return r, err
+		}
+		r.Health, r.Message = daemonSetHealth(daemonSet)
+		selector = daemonSet.Spec.Selector
+	case "Job":
+		job, err := clientset.BatchV1().Jobs(object.Namespace).Get(ctx, object.Name, metav1.GetOptions{})
+		if err != nil {
+			return r, err
+		}
+		r.Health, r.Message = jobHealth(job)
+		selector = job.Spec.Selector
+	case "PersistentVolumeClaim":
+		claim, err := clientset.CoreV1().PersistentVolumeClaims(object.Namespace).Get(ctx, object.Name, metav1.GetOptions{})
+		if err != nil {
+			return r, err
+		}
+		r.Health, r.Message = claimHealth(claim, now)
+	case "Pod":
+		pod, err := clientset.CoreV1().Pods(object.Namespace).Get(ctx, object.Name, metav1.GetOptions{})
+		if err != nil {
+			return r, err
+		}
+		p := podHealth(pod)
+		r.Health, r.Message, r.Pods = p.Health, p.Reason, []Pod{p}
+	}
+
+	if selector == nil {
+		return r, nil
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return r, err
+	}
+	pods, err := clientset.CoreV1().Pods(object.Namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		return r, err
+	}
+	for i := range pods.Items {
+		p := podHealth(&pods.Items[i])
+		r.Pods = append(r.Pods, p)
+		// the pods failed or evicted are replaced, the crashing ones are not
+		if failing[p.Reason] {
+			worsen(&r, v1.DegradedH, "pod "+p.Name+" "+p.Reason)
+		}
+	}
+	sort.Slice(r.Pods, func(i, j int) bool { return r.Pods[i].Name < r.Pods[j].Name })
+	return r, nil
+}
+
+func replicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+func deploymentHealth(deployment *appsv1.Deployment) (v1.HealthStatus, string) {
+	want := replicas(deployment.Spec.Replicas)
+	status := deployment.Status
+	for _, condition := range status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == "ProgressDeadlineExceeded" {
+			return v1.DegradedH, "progress deadline exceeded"
+		}
+	}
+	switch {
+	case status.ObservedGeneration < deployment.Generation:
+		return v1.ProgressingH, "rollout pending"
+	case status.UpdatedReplicas < want:
+		return v1.ProgressingH, fmt.Sprintf("%d/%d replicas updated", status.UpdatedReplicas, want)
+	case status.AvailableReplicas < want:
+		return v1.ProgressingH, fmt.Sprintf("%d/%d replicas available", status.AvailableReplicas, want)
+	case status.Replicas > want:
+		return v1.ProgressingH, fmt.Sprintf("%d old replicas terminating", status.Replicas-want)
+	}
+	return v1.HealthyH, ""
+}
+
+func statefulSetHealth(statefulSet *appsv1.StatefulSet) (v1.HealthStatus, string) {
+	want := replicas(statefulSet.Spec.Replicas)
+	status := statefulSet.Status
+	switch {
+	case status.ObservedGeneration < statefulSet.Generation:
+		return v1.ProgressingH, "rollout pending"
+	case statefulSet.Spec.UpdateStrategy.Type != appsv1.OnDeleteStatefulSetStrategyType &&
+		status.UpdateRevision != "" && status.CurrentRevision != status.UpdateRevision:
+		return v1.ProgressingH, fmt.Sprintf("%d/%d replicas updated", status.UpdatedReplicas, want)
+	case status.ReadyReplicas < want:
+		return v1.ProgressingH, fmt.Sprintf("%d/%d replicas ready", status.ReadyReplicas, want)
+	}
+	return v1.HealthyH, ""
+}
+
+func daemonSetHealth(daemonSet *appsv1.DaemonSet) (v1.HealthStatus, string) {
+	status := daemonSet.Status
+	switch {
+	case status.ObservedGeneration < daemonSet.Generation:
+		return v1.ProgressingH, "rollout pending"
+	case status.UpdatedNumberScheduled < status.DesiredNumberScheduled:
+		return v1.ProgressingH, fmt.Sprintf("%d/%d pods updated", status.UpdatedNumberScheduled, status.DesiredNumberScheduled)
+	case status.NumberReady < status.DesiredNumberScheduled:
+		return v1.ProgressingH, fmt.Sprintf("%d/%d pods ready", status.NumberReady, status.DesiredNumberScheduled)
+	}
+	return v1.HealthyH, ""
+}
+
+func jobHealth(job *batchv1.Job) (v1.HealthStatus, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobFailed:
+			return v1.DegradedH, "failed: " + condition.Reason
+		case batchv1.JobComplete:
+			return v1.HealthyH, ""
+		}
+	}
+	return v1.ProgressingH, fmt.Sprintf("%d pods running", job.Status.Active)
+}
+
+func claimHealth(claim *corev1.PersistentVolumeClaim, now time.Time) (v1.HealthStatus, string) {
+	switch claim.Status.Phase {
+	case corev1.ClaimLost:
+		return v1.DegradedH, "lost"
+	case corev1.ClaimPending:
+		pending := now.Sub(claim.CreationTimestamp.Time)
+		if pending > PendingTimeout {
+			return v1.DegradedH, "pending for " + pending.Round(time.Minute).String()
+		}
+		return v1.ProgressingH, "pending"
+	}
+	return v1.HealthyH, ""
+}
+
+func podHealth(pod *corev1.Pod) Pod {
+	p := Pod{Name: pod.Name, Phase: pod.Status.Phase}
+	for _, condition := range pod.Status.Conditions {
+		switch {
+		case condition.Type == corev1.PodReady:
+			p.Ready = condition.Status == corev1.ConditionTrue
+		case condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse:
+			p.Reason = condition.Reason
+		}
+	}
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			p.Restarts += status.RestartCount
+			if waiting := status.State.Waiting; waiting != nil && failing[waiting.Reason] {
+				p.Reason = waiting.Reason
+			}
+		}
+	}
+
+	switch {
+	case failing[p.Reason]:
+		p.Health = v1.DegradedH
+	case pod.Status.Phase == corev1.PodFailed:
+		p.Health, p.Reason = v1.DegradedH, pod.Status.Reason
+	case pod.Status.Phase == corev1.PodSucceeded || p.Ready:
+		p.Health = v1.HealthyH
+	default:
+		p.Health = v1.ProgressingH
+	}
+	return p
+}
+
+var rank = map[v1.HealthStatus]int{
+	v1.HealthyH:     0,
+	v1.ProgressingH: 1,
+	v1.DegradedH:    2,
+}
+
+// worsen sets the health of r to health when it is worse, and adds message
+// to its message.
+func worsen(r *Resource, health v1.HealthStatus, message string) {
+	if health == v1.HealthyH {
+		return
+	}
+	if rank[health] > rank[r.Health] {
+		r.Health = health
+	}
+	if r.Message != "" {
+		message = r.Message + ", " + message
+	}
+	r.Message = message
+}
+
+// Report returns the health of an app from the health of its resources,
+// the worst one.
+func Report(resources []Resource, now time.Time) v1.AppHealth {
+	report := v1.AppHealth{Status: v1.HealthyH, LastCheckTime: metav1.NewTime(now)}
+	var messages []string
+	for _, resource := range resources {
+		report.Resources = append(report.Resources, v1.ResourceHealth{
+			Kind:      resource.Kind,
+			Namespace: resource.Namespace,
+			Name:      resource.Name,
+			Health:    resource.Health,
+			Message:   resource.Message,
+		})
+		if rank[resource.Health] > rank[report.Status] {
+			report.Status = resource.Health
+		}
+		if resource.Health != v1.HealthyH {
+			messages = append(messages, fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, resource.Message))
+		}
+	}
+	report.Message = strings.Join(messages, "; ")
+	return report
+}
+
+// Unknown returns the health of an app whose resources could not be
+// inspected for err.
+func Unknown(err error, now time.Time) v1.AppHealth {
+	return v1.AppHealth{Status: v1.UnknownH, Message: err.Error(), LastCheckTime: metav1.NewTime(now)}
+}
+
+// WithEvents adds the latest events of their pods to resources, at most
+// limit events by pod.
+func WithEvents(ctx context.Context, clientset kubernetes.Interface, resources []Resource, limit int) error {
+	for i := range resources {
+		for j := range resources[i].Pods {
+			pod := &resources[i].Pods[j]
+			events, err := podEvents(ctx, clientset, resources[i].Namespace, pod.Name, limit)
+			if err != nil {
+				return err
+			}
+			pod.Events = events
+		}
+	}
+	return nil
+}
+
+func podEvents(ctx context.Context, clientset kubernetes.Interface, namespace, name string, limit int) ([]Event, error) {
+	list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": "Pod", "involvedObject.name": name}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, event := range list.Items {
+		if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != name {
+			continue
+		}
+		lastSeen := event.LastTimestamp.Time
+		if lastSeen.IsZero() {
+			lastSeen = event.EventTime.Time
+		}
+		events = append(events, Event{
+			Type:     event.Type,
+			Reason:   event.Reason,
+			Message:  event.Message,
+			Count:    event.Count,
+			LastSeen: lastSeen,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].LastSeen.After(events[j].LastSeen) })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
@@ -0,0 +1,267 @@
+package health
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+const namespace = "tenant"
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func selector(app string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}}
+}
+
+func makePod(name, app string, ready bool, waiting string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": app}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}
+	container := corev1.ContainerStatus{Name: "main", RestartCount: 0}
+	if waiting != "" {
+		container.RestartCount = 4
+		container.State.Waiting = &corev1.ContainerStateWaiting{Reason: waiting}
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{container}
+	return pod
+}
+
+func makeDeployment(name string, want, available int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(want), Selector: selector(name)},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           want,
+			UpdatedReplicas:    want,
+			ReadyReplicas:      available,
+			AvailableReplicas:  available,
+		},
+	}
+}
+
+func makeClaim(name string, phase corev1.PersistentVolumeClaimPhase, age time.Duration, now time.Time) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, CreationTimestamp: metav1.NewTime(now.Add(-age))},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func TestInspect(t *testing.T) {
+	now := time.Now()
+	db := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: namespace},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:             int32Ptr(2),
+			Selector:             selector("db"),
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+		},
+		Status: appsv1.StatefulSetStatus{ReadyReplicas: 2, CurrentRevision: "1", UpdateRevision: "1"},
+	}
+	failedJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: namespace},
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+		}},
+	}
+	runningJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "seed", Namespace: namespace},
+		Status:     batchv1.JobStatus{Active: 1},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		objects []runtime.Object
+		object  Object
+		health  v1.HealthStatus
+		message string
+		pods    int
+	}{
+		{
+			name:    "deployment ready",
+			objects: []runtime.Object{makeDeployment("web", 2, 2), makePod("web-1", "web", true, ""), makePod("web-2", "web", true, "")},
+			object:  Object{Kind: "Deployment", Namespace: namespace, Name: "web"},
+			health:  v1.HealthyH,
+			pods:    2,
+		},
+		{
+			name:    "deployment scaling",
+			objects: []runtime.Object{makeDeployment("web", 3, 2)},
+			object:  Object{Kind: "Deployment", Namespace: namespace, Name: "web"},
+			health:  v1.ProgressingH,
+			message: "2/3 replicas available",
+		},
+		{
+			name:    "deployment crashing",
+			objects: []runtime.Object{makeDeployment("web", 2, 1), makePod("web-1", "web", true, ""), makePod("web-2", "web", false, "CrashLoopBackOff")},
+			object:  Object{Kind: "Deployment", Namespace: namespace, Name: "web"},
+			health:  v1.DegradedH,
+			message: "1/2 replicas available, pod web-2 CrashLoopBackOff",
+			pods:    2,
+		},
+		{
+			name:    "statefulset claim pending",
+			objects: []runtime.Object{db, makeClaim("data-db-0", corev1.ClaimBound, time.Hour, now), makeClaim("data-db-1", corev1.ClaimPending, time.Minute, now)},
+			object:  Object{Kind: "StatefulSet", Namespace: namespace, Name: "db"},
+			health:  v1.ProgressingH,
+			message: "claim data-db-1 pending",
+		},
+		{
+			name:    "statefulset claim pending for long",
+			objects: []runtime.Object{db, makeClaim("data-db-0", corev1.ClaimPending, 10*time.Minute, now)},
+			object:  Object{Kind: "StatefulSet", Namespace: namespace, Name: "db"},
+			health:  v1.DegradedH,
+			message: "claim data-db-0 pending for 10m0s",
+		},
+		{
+			name:    "job failed",
+			objects: []runtime.Object{failedJob},
+			object:  Object{Kind: "Job", Namespace: namespace, Name: "migrate"},
+			health:  v1.DegradedH,
+			message: "failed: BackoffLimitExceeded",
+		},
+		{
+			name:    "job running",
+			objects: []runtime.Object{runningJob},
+			object:  Object{Kind: "Job", Namespace: namespace, Name: "seed"},
+			health:  v1.ProgressingH,
+			message: "1 pods running",
+		},
+		{
+			name:    "missing",
+			object:  Object{Kind: "Deployment", Namespace: namespace, Name: "web"},
+			health:  v1.DegradedH,
+			message: "not found",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tc.objects...)
+			resources, err := Inspect(context.Background(), clientset, []Object{tc.object}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := resources[0]
+			if got.Health != tc.health || got.Message != tc.message {
+				t.Fatalf("got %s %q, expected %s %q", got.Health, got.Message, tc.health, tc.message)
+			}
+			if len(got.Pods) != tc.pods {
+				t.Fatalf("got %d pods, expected %d", len(got.Pods), tc.pods)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	now := time.Now()
+	report := Report([]Resource{
+		{Object: Object{Kind: "Deployment", Name: "web"}, Health: v1.HealthyH},
+		{Object: Object{Kind: "Job", Name: "seed"}, Health: v1.ProgressingH, Message: "1 pods running"},
+	}, now)
+	if report.Status != v1.ProgressingH || report.Message != "Job seed: 1 pods running" || len(report.Resources) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	report = Report([]Resource{
+		{Object: Object{Kind: "Job", Name: "seed"}, Health: v1.ProgressingH, Message: "1 pods running"},
+		{Object: Object{Kind: "PersistentVolumeClaim", Name: "data"}, Health: v1.DegradedH, Message: "lost"},
+	}, now)
+	if report.Status != v1.DegradedH {
+		t.Fatalf("expected the worst health, got %s", report.Status)
+	}
+
+	if report := Report(nil, now); report.Status != v1.HealthyH {
+		t.Fatalf("expected an app without resources to be healthy, got %s", report.Status)
+	}
+}
+
+func TestManifestObjects(t *testing.T) {
+	manifest := `---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  namespace: jobs
+`
+	objects, err := ManifestObjects(manifest, namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Object{
+		{Kind: "Deployment", Namespace: namespace, Name: "web"},
+		{Kind: "Job", Namespace: "jobs", Name: "migrate"},
+	}
+	if !reflect.DeepEqual(objects, want) {
+		t.Fatalf("got %v, expected %v", objects, want)
+	}
+
+	applied := AppliedObjects([]v1.ResourceStatus{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: namespace, Name: "web", Phase: v1.AppliedRS},
+		{APIVersion: "apps/v1", Kind: "StatefulSet", Namespace: namespace, Name: "db", Phase: v1.FailedRS},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: namespace, Name: "config", Phase: v1.AppliedRS},
+	})
+	if !reflect.DeepEqual(applied, want[:1]) {
+		t.Fatalf("got %v, expected %v", applied, want[:1])
+	}
+}
+
+func TestWithEvents(t *testing.T) {
+	now := time.Now()
+	event := func(name, pod, reason string, age time.Duration) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: namespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: pod},
+			Type:           corev1.EventTypeWarning,
+			Reason:         reason,
+			LastTimestamp:  metav1.NewTime(now.Add(-age)),
+		}
+	}
+	clientset := fake.NewSimpleClientset(
+		event("e1", "web-1", "Pulled", 3*time.Minute),
+		event("e2", "web-1", "BackOff", time.Minute),
+		event("e3", "web-1", "Started", 2*time.Minute),
+		event("e4", "web-2", "Scheduled", time.Minute),
+	)
+	resources := []Resource{{
+		Object: Object{Kind: "Deployment", Namespace: namespace, Name: "web"},
+		Pods:   []Pod{{Name: "web-1"}},
+	}}
+	if err := WithEvents(context.Background(), clientset, resources, 2); err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for _, event := range resources[0].Pods[0].Events {
+		reasons = append(reasons, event.Reason)
+	}
+	if want := []string{"BackOff", "Started"}; !reflect.DeepEqual(reasons, want) {
+		t.Fatalf("got events %v, expected the latest %v", reasons, want)
+	}
+}
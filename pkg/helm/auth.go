@@ -0,0 +1,126 @@
+package helm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/registry"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// The keys of the Secret of a ChartAuth.
+const (
+	UsernameKey = "username"
+	PasswordKey = "password"
+	CAKey       = "ca.crt"
+	CertKey     = "tls.crt"
+	KeyKey      = "tls.key"
+)
+
+// Credentials are the credentials of a chart repo or registry.
+type Credentials struct {
+	Username string
+	Password string
+	CAData   []byte
+	CertData []byte
+	KeyData  []byte
+}
+
+// ECRTokenFunc returns the username and password of an ECR registry.
+type ECRTokenFunc func(registry string) (username, password string, err error)
+
+// ResolveCredentials returns the credentials of the repo or registry of a
+// chart read from the Secret of its auth in namespace, and the token of its
+// ECR registry from ecrToken. It is nil for the charts without any auth.
+func ResolveCredentials(
+	ctx context.Context,
+	c client.Reader,
+	namespace string,
+	spec v1.ChartSpec,
+	ecrToken ECRTokenFunc,
+) (*Credentials, error) {
+	if spec.Auth == nil {
+		return nil, nil
+	}
+	credentials := &Credentials{}
+
+	if spec.Auth.SecretName != "" {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: spec.Auth.SecretName}, secret); err != nil {
+			return nil, err
+		}
+		credentials.Username = string(secret.Data[UsernameKey])
+		credentials.Password = string(secret.Data[PasswordKey])
+		credentials.CAData = secret.Data[CAKey]
+		credentials.CertData = secret.Data[CertKey]
+		credentials.KeyData = secret.Data[KeyKey]
+	}
+
+	if spec.Auth.ECR {
+		host := RegistryHost(spec)
+		if host == "" {
+			return nil, fmt.Errorf("chart %s is not in an oci registry, ecr auth needs one", spec.ChartName)
+		}
+		username, password, err := ecrToken(host)
+		if err != nil {
+			return nil, err
+		}
+		credentials.Username, credentials.Password = username, password
+	}
+	return credentials, nil
+}
+
+// ChartRef returns the oci reference of the chart of spec, empty when it is
+// in a classic chart repo.
+func ChartRef(spec v1.ChartSpec) string {
+	if registry.IsOCI(spec.ChartName) {
+		return spec.ChartName
+	}
+	if registry.IsOCI(spec.RepoUrl) {
+		return strings.TrimSuffix(spec.RepoUrl, "/") + "/" + spec.ChartName
+	}
+	return ""
+}
+
+// RegistryHost returns the host of the oci registry of the chart of spec,
+// empty when it is in a classic chart repo.
+func RegistryHost(spec v1.ChartSpec) string {
+	ref := strings.TrimPrefix(ChartRef(spec), registry.OCIScheme+"://")
+	host, _, _ := strings.Cut(ref, "/")
+	return host
+}
+
+// registryHTTPClient returns the http client of an oci registry verifying
+// its certificate with the system roots and the CA bundle of credentials.
+func registryHTTPClient(credentials *Credentials, insecureSkipTLSVerify bool) (*http.Client, error) {
+	config := &tls.Config{InsecureSkipVerify: insecureSkipTLSVerify}
+
+	if len(credentials.CAData) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(credentials.CAData) {
+			return nil, fmt.Errorf("invalid %s, no certificate found", CAKey)
+		}
+		config.RootCAs = pool
+	}
+	if len(credentials.CertData) > 0 || len(credentials.KeyData) > 0 {
+		cert, err := tls.X509KeyPair(credentials.CertData, credentials.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,69 @@
+package helm
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func TestRegistryHost(t *testing.T) {
+	for _, test := range []struct {
+		spec v1.ChartSpec
+		want string
+	}{
+		{v1.ChartSpec{ChartName: "app", RepoUrl: "oci://123456789012.dkr.ecr.us-east-1.amazonaws.com/charts/"}, "123456789012.dkr.ecr.us-east-1.amazonaws.com"},
+		{v1.ChartSpec{ChartName: "oci://registry.example.com:5000/charts/app"}, "registry.example.com:5000"},
+		{v1.ChartSpec{ChartName: "app", RepoName: "museum", RepoUrl: "https://charts.example.com"}, ""},
+	} {
+		if got := RegistryHost(test.spec); got != test.want {
+			t.Errorf("RegistryHost(%+v) = %q, want %q", test.spec, got, test.want)
+		}
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "acme"},
+		Data: map[string][]byte{
+			UsernameKey: []byte("deployer"),
+			PasswordKey: []byte("s3cr3t"),
+			CAKey:       []byte("ca bundle"),
+		},
+	}).Build()
+	ecrToken := func(registry string) (string, string, error) {
+		return "AWS", "token-of-" + registry, nil
+	}
+
+	spec := v1.ChartSpec{ChartName: "app", RepoUrl: "https://charts.example.com"}
+	if credentials, err := ResolveCredentials(context.Background(), c, "acme", spec, ecrToken); err != nil || credentials != nil {
+		t.Fatalf("expected no credentials without auth, got %v, %v", credentials, err)
+	}
+
+	spec.Auth = &v1.ChartAuth{SecretName: "registry"}
+	credentials, err := ResolveCredentials(context.Background(), c, "acme", spec, ecrToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.Username != "deployer" || credentials.Password != "s3cr3t" || string(credentials.CAData) != "ca bundle" {
+		t.Fatalf("unexpected credentials %+v", credentials)
+	}
+
+	spec.Auth.ECR = true
+	if _, err := ResolveCredentials(context.Background(), c, "acme", spec, ecrToken); err == nil {
+		t.Fatal("expected ecr auth to need an oci registry")
+	}
+	spec.RepoUrl = "oci://123456789012.dkr.ecr.us-east-1.amazonaws.com/charts"
+	credentials, err = ResolveCredentials(context.Background(), c, "acme", spec, ecrToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.Username != "AWS" || credentials.Password != "token-of-123456789012.dkr.ecr.us-east-1.amazonaws.com" ||
+		string(credentials.CAData) != "ca bundle" {
+		t.Fatalf("expected the ecr token with the CA bundle of the secret, got %+v", credentials)
+	}
+}
@@ -3,57 +3,99 @@ package helm
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
-	"sync"
+	"sort"
 	"time"
 
-	"github.com/gofrs/flock"
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/rest"
-	"k8s.io/klog/v2"
-)
 
-var settings *cli.EnvSettings
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/logging"
+)
 
 type HelmAct interface {
 	Apply(rest *rest.Config) error
 	Uninstall(rest *rest.Config) error
 	Upgrade(rest *rest.Config) error
 	List(rest *rest.Config) (status string, exists bool)
+	Rollback(rest *rest.Config, revision int) error
+	History(rest *rest.Config) ([]*release.Release, error)
 }
 
+// MaxHistory is the number of revisions kept for a release.
+const MaxHistory = 10
+
 type Helm struct {
-	Action       *action.Configuration
-	ReleaseName  string
-	Namespace    string
-	Values       []string
-	RepoName     string
-	ChartName    string
-	RepoUrl      string
-	Version      string
-	clientGetter genericclioptions.RESTClientGetter
+	Action      *action.Configuration
+	ReleaseName string
+	Namespace   string
+	Values      []string
+	// BaseValues are the values the --set style Values are applied on.
+	BaseValues map[string]interface{}
+	RepoName   string
+	ChartName  string
+	RepoUrl    string
+	Version    string
+	// Credentials log in the repo or registry of a private chart.
+	Credentials           *Credentials
+	InsecureSkipTLSVerify bool
+	clientGetter          genericclioptions.RESTClientGetter
+	cache                 *RepoCache
+	log                   logr.Logger
 }
 
+// NewHelm returns the helm of a release. The releases share the index cache
+// of the repositories and nothing else, each action working in a workspace
+// of its own. Without cache, the indexes are downloaded by every action.
 func NewHelm(
+	ctx context.Context,
+	cache *RepoCache,
 	releaseName, namespace, chartName, repoName, repoUrl, version string,
 	rest *rest.Config,
 	values []string) HelmAct {
-	insecure := true
-	clientGetter := genericclioptions.NewConfigFlags(false)
-	clientGetter.APIServer = &rest.Host
-	clientGetter.BearerToken = &rest.BearerToken
-	clientGetter.Insecure = &insecure
+	return &tracedHelm{
+		ctx:  ctx,
+		next: newHelm(ctx, cache, releaseName, namespace, chartName, repoName, repoUrl, version, rest, values),
+	}
+}
+
+// NewChartHelm returns the helm of the chart of spec, with the structured
+// values the --set style values are applied on, see ResolveValues, and the
+// credentials of its repo or registry, see ResolveCredentials.
+func NewChartHelm(
+	ctx context.Context,
+	cache *RepoCache,
+	releaseName, namespace string,
+	spec v1.ChartSpec,
+	rest *rest.Config,
+	baseValues map[string]interface{},
+	credentials *Credentials) HelmAct {
+	h := newHelm(ctx, cache, releaseName, namespace, spec.ChartName, spec.RepoName, spec.RepoUrl, spec.Version, rest, spec.Values)
+	h.BaseValues = baseValues
+	h.Credentials = credentials
+	h.InsecureSkipTLSVerify = spec.InsecureSkipTLSVerify
+	return &tracedHelm{ctx: ctx, next: h}
+}
+
+func newHelm(
+	ctx context.Context,
+	cache *RepoCache,
+	releaseName, namespace, chartName, repoName, repoUrl, version string,
+	rest *rest.Config,
+	values []string) *Helm {
 	return &Helm{
 		Action:       new(action.Configuration),
 		ReleaseName:  releaseName,
@@ -63,7 +105,13 @@ func NewHelm(
 		ChartName:    chartName,
 		Values:       values,
 		Version:      version,
-		clientGetter: clientGetter,
+		clientGetter: newRESTClientGetter(rest, namespace),
+		cache:        cache,
+		log: logging.FromContext(ctx, logging.Helm).WithValues(
+			logging.KeyRelease, releaseName,
+			logging.KeyChart, chartName,
+			"namespace", namespace,
+		),
 	}
 }
 
@@ -71,9 +119,7 @@ func NewHelm(
 // https://helm.sh/docs/topics/advanced/#simple-example
 func (h *Helm) List(rest *rest.Config) (status string, exists bool) {
 
-	settings = cli.New()
-
-	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), klog.Infof); err != nil {
+	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
 		return "", false
 	}
 
@@ -96,16 +142,12 @@ func (h *Helm) List(rest *rest.Config) (status string, exists bool) {
 
 func (h *Helm) Uninstall(rest *rest.Config) error {
 
-	settings = cli.New()
-
-	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), klog.Infof); err != nil {
+	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
 		return err
 	}
 
 	client := action.NewUninstall(h.Action)
 
-	settings.EnvVars()
-
 	client.Wait = true
 	client.Timeout = 5 * time.Minute
 
@@ -114,7 +156,7 @@ func (h *Helm) Uninstall(rest *rest.Config) error {
 		return err
 	}
 
-	klog.Infof("Uninstalling Release Name: [%s] Namespace [%s] Status [%s]", release.Release.Name, release.Release.Namespace, release.Release.Info.Status)
+	h.log.Info("uninstalled release", "status", release.Release.Info.Status)
 
 	return nil
 }
@@ -123,9 +165,17 @@ func (h *Helm) Uninstall(rest *rest.Config) error {
 // ref: https://github.com/PrasadG193/helm-clientgo-example/tree/master
 func (h *Helm) Apply(rest *rest.Config) error {
 
-	settings = cli.New()
+	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
+		return err
+	}
+
+	ws, err := h.newWorkspace()
+	if err != nil {
+		return err
+	}
+	defer ws.remove()
 
-	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), klog.Infof); err != nil {
+	if err := h.initRegistryClient(ws); err != nil {
 		return err
 	}
 
@@ -137,16 +187,7 @@ func (h *Helm) Apply(rest *rest.Config) error {
 	client.Timeout = 10 * time.Minute
 	client.WaitForJobs = true
 
-	settings.EnvVars()
-
-	repoAdd(h.RepoName, h.RepoUrl)
-
-	cp, err := client.ChartPathOptions.LocateChart(fmt.Sprintf("%s/%s", h.RepoName, h.ChartName), settings)
-	if err != nil {
-		return err
-	}
-
-	err = h.RepoUpdate()
+	cp, err := h.locateChart(&client.ChartPathOptions, ws)
 	if err != nil {
 		return err
 	}
@@ -156,23 +197,17 @@ func (h *Helm) Apply(rest *rest.Config) error {
 		return err
 	}
 
-	values := values.Options{
-		Values: h.Values,
-	}
-
-	vals, err := values.MergeValues(getter.All(settings))
+	vals, err := h.mergeValues()
 	if err != nil {
 		return err
 	}
 
-	client.ChartPathOptions.Version = h.Version
-
 	release, err := client.Run(chartRequested, vals)
 	if err != nil {
 		return err
 	}
 
-	klog.Infof("Release Name: [%s] Namespace [%s] Status [%s]", release.Name, release.Namespace, release.Info.Status)
+	h.log.Info("applied release", "version", release.Chart.Metadata.Version, "status", release.Info.Status)
 
 	return nil
 }
@@ -181,9 +216,17 @@ func (h *Helm) Apply(rest *rest.Config) error {
 // ref: https://github.com/PrasadG193/helm-clientgo-example/tree/master
 func (h *Helm) Upgrade(rest *rest.Config) error {
 
-	settings = cli.New()
+	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
+		return err
+	}
+
+	ws, err := h.newWorkspace()
+	if err != nil {
+		return err
+	}
+	defer ws.remove()
 
-	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), klog.Infof); err != nil {
+	if err := h.initRegistryClient(ws); err != nil {
 		return err
 	}
 
@@ -191,20 +234,11 @@ func (h *Helm) Upgrade(rest *rest.Config) error {
 	client.Namespace = h.Namespace
 	client.Wait = true
 	client.Timeout = 5 * time.Minute
-	client.ChartPathOptions.Version = h.Version
+	client.MaxHistory = MaxHistory
 
 	client.WaitForJobs = true
 
-	settings.EnvVars()
-
-	repoAdd(h.RepoName, h.RepoUrl)
-
-	cp, err := client.ChartPathOptions.LocateChart(fmt.Sprintf("%s/%s", h.RepoName, h.ChartName), settings)
-	if err != nil {
-		return err
-	}
-
-	err = h.RepoUpdate()
+	cp, err := h.locateChart(&client.ChartPathOptions, ws)
 	if err != nil {
 		return err
 	}
@@ -216,11 +250,7 @@ func (h *Helm) Upgrade(rest *rest.Config) error {
 
 	//client.IncludeCRDs = true
 
-	values := values.Options{
-		Values: h.Values,
-	}
-
-	vals, err := values.MergeValues(getter.All(settings))
+	vals, err := h.mergeValues()
 	if err != nil {
 		return err
 	}
@@ -230,105 +260,147 @@ func (h *Helm) Upgrade(rest *rest.Config) error {
 		return err
 	}
 
-	klog.Infof("Release Name: [%s] Namespace [%s] Status [%s]", release.Name, release.Namespace, release.Info.Status)
+	h.log.Info("applied release", "version", release.Chart.Metadata.Version, "status", release.Info.Status)
 
 	return nil
 }
 
-// ref: https://github.com/PrasadG193/helm-clientgo-example/tree/master
-// RepoUpdate updates charts for all helm repos
-func (helm *Helm) RepoUpdate() error {
-	settings = cli.New()
-
-	repoFile := settings.RepositoryConfig
+// Rollback rolls the release back to revision, to the previous revision
+// when it is 0.
+func (h *Helm) Rollback(rest *rest.Config, revision int) error {
 
-	f, err := repo.LoadFile(repoFile)
-	if os.IsNotExist(errors.Cause(err)) || len(f.Repositories) == 0 {
-		klog.Error(errors.New("no repositories found. You must add one before updating"))
+	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
 		return err
 	}
-	var repos []*repo.ChartRepository
-	for _, cfg := range f.Repositories {
-		r, err := repo.NewChartRepository(cfg, getter.All(settings))
-		if err != nil {
-			return err
-		}
-		repos = append(repos, r)
-	}
 
-	var wg sync.WaitGroup
-	for _, re := range repos {
-		wg.Add(1)
-		go func(re *repo.ChartRepository) {
-			defer wg.Done()
-			if _, err := re.DownloadIndexFile(); err != nil {
-				klog.Errorf("...Unable to get an update from the %q chart repository (%s):\n\t%s\n", re.Config.Name, re.Config.URL, err)
-				return
-			}
-		}(re)
+	client := action.NewRollback(h.Action)
+	client.Version = revision
+	client.Wait = true
+	client.WaitForJobs = true
+	client.Timeout = 5 * time.Minute
+	client.CleanupOnFail = true
+	client.MaxHistory = MaxHistory
+
+	if err := client.Run(h.ReleaseName); err != nil {
+		return err
 	}
-	wg.Wait()
+
+	h.log.Info("rolled back release", "revision", revision)
 
 	return nil
 }
 
-// Ref: https://github.com/PrasadG193/helm-clientgo-example/tree/master
-// RepoAdd adds repo with given name and url
-func repoAdd(name, url string) {
-	settings := cli.New()
+// History returns the revisions of the release, the newest first. It is
+// empty when the release is not installed.
+func (h *Helm) History(rest *rest.Config) ([]*release.Release, error) {
 
-	repoFile := settings.RepositoryConfig
-
-	//Ensure the file directory exists as it is required for file locking
-	err := os.MkdirAll(filepath.Dir(repoFile), os.ModePerm)
-	if err != nil && !os.IsExist(err) {
-		klog.Error(err)
+	if err := h.Action.Init(h.clientGetter, h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
+		return nil, err
 	}
 
-	// Acquire a file lock for process synchronization
-	fileLock := flock.New(strings.Replace(repoFile, filepath.Ext(repoFile), ".lock", 1))
-	lockCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	locked, err := fileLock.TryLockContext(lockCtx, time.Second)
-	if err == nil && locked {
-		defer fileLock.Unlock()
+	client := action.NewHistory(h.Action)
+	client.Max = MaxHistory
+
+	releases, err := client.Run(h.ReleaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil
 	}
 	if err != nil {
-		klog.Error(err)
+		return nil, err
 	}
 
-	b, err := ioutil.ReadFile(repoFile)
-	if err != nil && !os.IsNotExist(err) {
-		klog.Error(err)
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Version > releases[j].Version
+	})
+	return releases, nil
+}
+
+// locateChart downloads the chart at the configured version into the
+// workspace. Charts of oci registries, like oci://public.ecr.aws/karpenter,
+// are pulled directly and the charts of private repos are looked up by their
+// url, so their credentials are not written to any repository file, while
+// the other repos are read from the index cache.
+func (h *Helm) locateChart(opts *action.ChartPathOptions, ws *workspace) (string, error) {
+	opts.Version = h.Version
+	opts.InsecureSkipTLSverify = h.InsecureSkipTLSVerify
+	opts.CaFile, opts.CertFile, opts.KeyFile = ws.caFile, ws.certFile, ws.keyFile
+
+	if ref := h.chartRef(); ref != "" {
+		return opts.LocateChart(ref, ws.settings)
 	}
 
-	var f repo.File
-	if err := yaml.Unmarshal(b, &f); err != nil {
-		klog.Error(err)
+	if h.Credentials != nil {
+		opts.RepoURL = h.RepoUrl
+		opts.Username, opts.Password = h.Credentials.Username, h.Credentials.Password
+		return opts.LocateChart(h.ChartName, ws.settings)
 	}
 
-	if f.Has(name) {
-		return
+	cache := h.cache
+	if cache == nil {
+		cache = NewRepoCache(filepath.Join(ws.dir, "index"), 0)
 	}
+	entry, err := cache.LinkIndex(h.log, repo.Entry{URL: h.RepoUrl, InsecureSkipTLSverify: h.InsecureSkipTLSVerify},
+		getter.All(ws.settings), ws.settings.RepositoryCache)
+	if err != nil {
+		return "", err
+	}
+	if err := ws.addRepo(entry); err != nil {
+		return "", err
+	}
+	return opts.LocateChart(entry.Name+"/"+h.ChartName, ws.settings)
+}
+
+func (h *Helm) chartRef() string {
+	return ChartRef(v1.ChartSpec{ChartName: h.ChartName, RepoUrl: h.RepoUrl})
+}
+
+// newWorkspace creates the workspace of an action, with the files of the
+// credentials of the chart.
+func (h *Helm) newWorkspace() (*workspace, error) {
+	return newWorkspace(h.Credentials, RegistryHost(v1.ChartSpec{ChartName: h.ChartName, RepoUrl: h.RepoUrl}))
+}
 
-	c := repo.Entry{
-		Name: name,
-		URL:  url,
+// initRegistryClient sets the registry client used to pull oci charts,
+// logged in with the registry config of the workspace when the chart has
+// credentials.
+func (h *Helm) initRegistryClient(ws *workspace) error {
+	if h.chartRef() == "" {
+		return nil
 	}
 
-	r, err := repo.NewChartRepository(&c, getter.All(settings))
+	credentials := h.Credentials
+	if credentials == nil {
+		credentials = &Credentials{}
+	}
+	httpClient, err := registryHTTPClient(credentials, h.InsecureSkipTLSVerify)
 	if err != nil {
-		klog.Error(err)
+		return err
 	}
 
-	if _, err := r.DownloadIndexFile(); err != nil {
-		err := errors.Wrapf(err, "looks like %q is not a valid chart repository or cannot be reached", url)
-		klog.Error(err)
+	registryClient, err := registry.NewClient(
+		registry.ClientOptCredentialsFile(ws.settings.RegistryConfig),
+		registry.ClientOptHTTPClient(httpClient),
+	)
+	if err != nil {
+		return err
 	}
+	h.Action.RegistryClient = registryClient
+	return nil
+}
 
-	f.Update(&c)
-
-	if err := f.WriteFile(repoFile, 0644); err != nil {
-		klog.Error(err)
+// mergeValues applies the --set style values over the base values.
+func (h *Helm) mergeValues() (map[string]interface{}, error) {
+	options := values.Options{
+		Values: h.Values,
+	}
+	vals, err := options.MergeValues(getter.All(cli.New()))
+	if err != nil {
+		return nil, err
 	}
+	return MergeValues(MergeValues(nil, h.BaseValues), vals), nil
+}
+
+// debug forwards the helm action logs at V(1).
+func (h *Helm) debug(format string, v ...interface{}) {
+	h.log.V(1).Info(fmt.Sprintf(format, v...))
 }
@@ -0,0 +1,144 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/helmpath"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// DefaultIndexTTL is how long the index of a repository is used before it
+// is downloaded again.
+const DefaultIndexTTL = 10 * time.Minute
+
+// RepoCache caches the index files of the chart repositories for the
+// releases installed concurrently. An index is downloaded at most once per
+// ttl, under the lock of its repository, and replaced atomically so the
+// releases reading it never see a partial file.
+type RepoCache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	indexes map[string]*cachedIndex
+}
+
+type cachedIndex struct {
+	sync.Mutex
+	downloaded time.Time
+}
+
+// NewRepoCache returns an index cache in dir, created when missing.
+func NewRepoCache(dir string, ttl time.Duration) *RepoCache {
+	return &RepoCache{
+		dir:     dir,
+		ttl:     ttl,
+		now:     time.Now,
+		indexes: make(map[string]*cachedIndex),
+	}
+}
+
+// repoKey names a repository in the cache after its url, so the releases
+// naming a repository differently share its index, and the ones naming
+// different repositories alike don't collide.
+func repoKey(entry repo.Entry) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%t", entry.URL, entry.InsecureSkipTLSverify)))
+	return "repo-" + hex.EncodeToString(sum[:8])
+}
+
+func (c *RepoCache) index(key string) *cachedIndex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, ok := c.indexes[key]
+	if !ok {
+		index = &cachedIndex{}
+		c.indexes[key] = index
+	}
+	return index
+}
+
+// LinkIndex makes the index of the repository of entry available in dir,
+// the repository cache of the settings of an action, downloading it when
+// older than the ttl. A stale index is used while the repository cannot be
+// reached. It returns entry named as in the cache.
+func (c *RepoCache) LinkIndex(log logr.Logger, entry repo.Entry, getters getter.Providers, dir string) (repo.Entry, error) {
+	entry.Name = repoKey(entry)
+	index := c.index(entry.Name)
+	index.Lock()
+	defer index.Unlock()
+
+	if index.downloaded.IsZero() || c.now().Sub(index.downloaded) >= c.ttl {
+		if err := c.download(entry, getters); err != nil {
+			if index.downloaded.IsZero() {
+				return entry, errors.Wrapf(err, "looks like %q is not a valid chart repository or cannot be reached", entry.URL)
+			}
+			log.Error(err, "unable to update the chart repository, using its cached index", "url", entry.URL)
+		} else {
+			index.downloaded = c.now()
+		}
+	}
+
+	name := helmpath.CacheIndexFile(entry.Name)
+	return entry, linkFile(filepath.Join(c.dir, name), filepath.Join(dir, name))
+}
+
+// download downloads the index of entry to a staging directory, then moves
+// it to the cache.
+func (c *RepoCache) download(entry repo.Entry, getters getter.Providers) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(c.dir, "download-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	chartRepo, err := repo.NewChartRepository(&entry, getters)
+	if err != nil {
+		return err
+	}
+	chartRepo.CachePath = staging
+	downloaded, err := chartRepo.DownloadIndexFile()
+	if err != nil {
+		return err
+	}
+	return os.Rename(downloaded, filepath.Join(c.dir, helmpath.CacheIndexFile(entry.Name)))
+}
+
+// linkFile hard links src at dst, the link keeping the content of src even
+// once it is replaced, and copies it when they are on different devices.
+func linkFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,176 @@
+package helm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+	"k8s.io/client-go/rest"
+)
+
+// The tests of this file install charts concurrently, run them with -race.
+
+// chartRepo serves a repository holding the chart app at 1.0.0 and counts
+// the downloads of its index. It fails them while down is set.
+type chartRepo struct {
+	*httptest.Server
+	downloads atomic.Int32
+	down      atomic.Bool
+}
+
+func newChartRepo(t *testing.T) *chartRepo {
+	dir := t.TempDir()
+	if _, err := chartutil.Save(&chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "app", Version: "1.0.0"},
+	}, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &chartRepo{}
+	files := http.FileServer(http.Dir(dir))
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/index.yaml" {
+			if r.down.Load() {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			r.downloads.Add(1)
+		}
+		files.ServeHTTP(w, req)
+	}))
+	t.Cleanup(r.Close)
+
+	index, err := repo.IndexDirectory(dir, r.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.WriteFile(filepath.Join(dir, "index.yaml"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRepoCacheLinkIndexConcurrently(t *testing.T) {
+	r := newChartRepo(t)
+	cache := NewRepoCache(t.TempDir(), time.Hour)
+	getters := getter.All(cli.New())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dir := t.TempDir()
+			entry, err := cache.LinkIndex(logr.Discard(), repo.Entry{URL: r.URL}, getters, dir)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			index, err := repo.LoadIndexFile(filepath.Join(dir, entry.Name+"-index.yaml"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !index.Has("app", "1.0.0") {
+				t.Error("expected the linked index to hold the chart")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if downloads := r.downloads.Load(); downloads != 1 {
+		t.Fatalf("expected the index to be downloaded once, got %d downloads", downloads)
+	}
+}
+
+func TestRepoCacheTTL(t *testing.T) {
+	r := newChartRepo(t)
+	cache := NewRepoCache(t.TempDir(), time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	getters := getter.All(cli.New())
+
+	link := func() error {
+		_, err := cache.LinkIndex(logr.Discard(), repo.Entry{URL: r.URL}, getters, t.TempDir())
+		return err
+	}
+	if err := link(); err != nil {
+		t.Fatal(err)
+	}
+	if err := link(); err != nil || r.downloads.Load() != 1 {
+		t.Fatalf("expected the index to be cached, got %d downloads, %v", r.downloads.Load(), err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := link(); err != nil || r.downloads.Load() != 2 {
+		t.Fatalf("expected the expired index to be downloaded again, got %d downloads, %v", r.downloads.Load(), err)
+	}
+
+	now = now.Add(time.Minute)
+	r.down.Store(true)
+	if err := link(); err != nil {
+		t.Fatalf("expected the stale index to be used while the repository is down, got %v", err)
+	}
+	if _, err := NewRepoCache(t.TempDir(), time.Minute).LinkIndex(logr.Discard(), repo.Entry{URL: r.URL}, getters, t.TempDir()); err == nil {
+		t.Fatal("expected a repository never reached to fail")
+	}
+}
+
+func TestLocateChartConcurrently(t *testing.T) {
+	r := newChartRepo(t)
+	cache := NewRepoCache(t.TempDir(), time.Hour)
+	repositoryConfig := filepath.Join(t.TempDir(), "repositories.yaml")
+	t.Setenv("HELM_REPOSITORY_CONFIG", repositoryConfig)
+
+	var wg sync.WaitGroup
+	for _, repoName := range []string{"app", "apps", "charts", "app", "apps", "charts", "app", "apps"} {
+		wg.Add(1)
+		go func(repoName string) {
+			defer wg.Done()
+			h := newHelm(context.Background(), cache, "release", "tenant", "app", repoName, r.URL, "1.0.0",
+				&rest.Config{Host: "https://127.0.0.1"}, nil)
+			ws, err := h.newWorkspace()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer ws.remove()
+
+			cp, err := h.locateChart(&action.ChartPathOptions{}, ws)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			loaded, err := loader.Load(cp)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if loaded.Metadata.Version != "1.0.0" {
+				t.Errorf("unexpected chart version %s", loaded.Metadata.Version)
+			}
+		}(repoName)
+	}
+	wg.Wait()
+
+	if downloads := r.downloads.Load(); downloads != 1 {
+		t.Fatalf("expected the releases to share the index, got %d downloads", downloads)
+	}
+	if _, err := os.Stat(repositoryConfig); !os.IsNotExist(err) {
+		t.Fatal("expected the process-wide repositories file to be left alone")
+	}
+}
@@ -0,0 +1,72 @@
+package helm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/client-go/rest"
+
+	"github.com/baazhq/baaz/pkg/tracing"
+)
+
+// tracedHelm wraps every HelmAct method in a child span of ctx.
+type tracedHelm struct {
+	ctx  context.Context
+	next *Helm
+}
+
+func (t *tracedHelm) start(name string) trace.Span {
+	_, span := tracing.Start(t.ctx, "helm."+name,
+		attribute.String("helm.release", t.next.ReleaseName),
+		attribute.String("helm.namespace", t.next.Namespace),
+		attribute.String("helm.chart", t.next.ChartName),
+		attribute.String("helm.version", t.next.Version),
+	)
+	return span
+}
+
+func (t *tracedHelm) Apply(rest *rest.Config) error {
+	span := t.start("Apply")
+	err := t.next.Apply(rest)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedHelm) Uninstall(rest *rest.Config) error {
+	span := t.start("Uninstall")
+	err := t.next.Uninstall(rest)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedHelm) Upgrade(rest *rest.Config) error {
+	span := t.start("Upgrade")
+	err := t.next.Upgrade(rest)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedHelm) List(rest *rest.Config) (string, bool) {
+	span := t.start("List")
+	status, exists := t.next.List(rest)
+	span.SetAttributes(attribute.Bool("helm.exists", exists))
+	span.End()
+	return status, exists
+}
+
+func (t *tracedHelm) Rollback(rest *rest.Config, revision int) error {
+	span := t.start("Rollback")
+	span.SetAttributes(attribute.Int("helm.revision", revision))
+	err := t.next.Rollback(rest, revision)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedHelm) History(rest *rest.Config) ([]*release.Release, error) {
+	span := t.start("History")
+	releases, err := t.next.History(rest)
+	tracing.End(span, err)
+	return releases, err
+}
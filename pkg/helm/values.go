@@ -0,0 +1,187 @@
+package helm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// DefaultValuesKey is the key of the values of a Secret or ConfigMap
+// referenced without any.
+const DefaultValuesKey = "values.yaml"
+
+// ResolveValues returns the values of a chart read from its valuesFrom
+// references in namespace, merged in their order, and its valuesObject on
+// top, and the versions of the Secrets and ConfigMaps read, see SpecHash.
+// The --set style values of the chart are applied over them by the install
+// and upgrade. The errors never carry the content of the values.
+func ResolveValues(ctx context.Context, c client.Reader, namespace string, spec v1.ChartSpec) (map[string]interface{}, []string, error) {
+	values := map[string]interface{}{}
+	var versions []string
+
+	for _, ref := range spec.ValuesFrom {
+		key := ref.ValuesKey
+		if key == "" {
+			key = DefaultValuesKey
+		}
+
+		data, version, found, err := readValues(ctx, c, namespace, ref, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if version != "" {
+			versions = append(versions, version)
+		}
+		if !found {
+			if ref.Optional {
+				continue
+			}
+			return nil, nil, fmt.Errorf("%s %s/%s has no key %s", ref.Kind, namespace, ref.Name, key)
+		}
+
+		if ref.TargetPath != "" {
+			if err := setPath(values, ref.TargetPath, string(data)); err != nil {
+				return nil, nil, fmt.Errorf("%s %s/%s: %w", ref.Kind, namespace, ref.Name, err)
+			}
+			continue
+		}
+		from := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &from); err != nil {
+			return nil, nil, fmt.Errorf("key %s of %s %s/%s is not a values file", key, ref.Kind, namespace, ref.Name)
+		}
+		values = MergeValues(values, from)
+	}
+
+	if spec.ValuesObject != nil && len(spec.ValuesObject.Raw) > 0 {
+		object := map[string]interface{}{}
+		if err := json.Unmarshal(spec.ValuesObject.Raw, &object); err != nil {
+			return nil, nil, fmt.Errorf("invalid valuesObject: %w", err)
+		}
+		values = MergeValues(values, object)
+	}
+	return values, versions, nil
+}
+
+// readValues returns the data of key in the Secret or ConfigMap of ref and
+// the version of the object read, its kind, name, uid and resource version.
+func readValues(ctx context.Context, c client.Reader, namespace string, ref v1.ValuesReference, key string) ([]byte, string, bool, error) {
+	name := client.ObjectKey{Namespace: namespace, Name: ref.Name}
+	switch ref.Kind {
+	case v1.SecretValues:
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, name, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, "", false, ignoreOptional(ref, err)
+			}
+			return nil, "", false, err
+		}
+		data, ok := secret.Data[key]
+		return data, objectVersion(ref, secret), ok, nil
+	case v1.ConfigMapValues:
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, name, configMap); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, "", false, ignoreOptional(ref, err)
+			}
+			return nil, "", false, err
+		}
+		version := objectVersion(ref, configMap)
+		if data, ok := configMap.Data[key]; ok {
+			return []byte(data), version, true, nil
+		}
+		data, ok := configMap.BinaryData[key]
+		return data, version, ok, nil
+	}
+	return nil, "", false, fmt.Errorf("unsupported values kind %q", ref.Kind)
+}
+
+func objectVersion(ref v1.ValuesReference, obj client.Object) string {
+	return fmt.Sprintf("%s/%s/%s@%s", ref.Kind, obj.GetName(), obj.GetUID(), obj.GetResourceVersion())
+}
+
+func ignoreOptional(ref v1.ValuesReference, err error) error {
+	if ref.Optional {
+		return nil
+	}
+	return err
+}
+
+// setPath sets value at the dotted path of values.
+func setPath(values map[string]interface{}, path, value string) error {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		if key == "" {
+			return fmt.Errorf("invalid target path %q", path)
+		}
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			values[key] = next
+		}
+		values = next
+	}
+	last := keys[len(keys)-1]
+	if last == "" {
+		return fmt.Errorf("invalid target path %q", path)
+	}
+	values[last] = value
+	return nil
+}
+
+// MergeValues merges src into dst, the values of src win and the maps of
+// both are merged recursively into copies, so only dst itself is modified.
+// It returns dst.
+func MergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for key, value := range src {
+		if srcMap, ok := value.(map[string]interface{}); ok {
+			if dstMap, ok := dst[key].(map[string]interface{}); ok {
+				dst[key] = MergeValues(MergeValues(nil, dstMap), srcMap)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+	return dst
+}
+
+// SpecHash returns the hash of the chart, version and values a release is
+// deployed with, versions being the versions of the Secrets and ConfigMaps
+// ResolveValues read for spec. The values read from them are not hashed,
+// their versions change with them, so the hash finds the changes of the
+// values without exposing a digest of the secrets.
+func SpecHash(spec v1.ChartSpec, versions []string) (string, error) {
+	var valuesObject interface{}
+	if spec.ValuesObject != nil && len(spec.ValuesObject.Raw) > 0 {
+		if err := json.Unmarshal(spec.ValuesObject.Raw, &valuesObject); err != nil {
+			return "", fmt.Errorf("invalid valuesObject: %w", err)
+		}
+	}
+	// maps are marshalled with sorted keys, so the hash is stable
+	data, err := json.Marshal(struct {
+		Chart        string               `json:"chart"`
+		RepoUrl      string               `json:"repoUrl"`
+		Version      string               `json:"version"`
+		ValuesFrom   []v1.ValuesReference `json:"valuesFrom,omitempty"`
+		Versions     []string             `json:"versions,omitempty"`
+		ValuesObject interface{}          `json:"valuesObject,omitempty"`
+		Set          []string             `json:"set,omitempty"`
+	}{spec.ChartName, spec.RepoUrl, spec.Version, spec.ValuesFrom, versions, valuesObject, spec.Values})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,96 @@
+package helm
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func TestResolveValues(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "acme"},
+			Data:       map[string]string{"values.yaml": "replicas: 1\ndb:\n  host: db.local\n  port: 5432\n"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "acme"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t,with=commas")},
+		},
+	).Build()
+
+	spec := v1.ChartSpec{
+		ValuesFrom: []v1.ValuesReference{
+			{Kind: v1.ConfigMapValues, Name: "defaults"},
+			{Kind: v1.SecretValues, Name: "db", ValuesKey: "password", TargetPath: "db.password"},
+			{Kind: v1.SecretValues, Name: "license", Optional: true},
+		},
+		ValuesObject: &apiextensionsv1.JSON{Raw: []byte(`{"replicas": 3, "db": {"port": 6432}}`)},
+	}
+	values, versions, err := ResolveValues(context.Background(), c, "acme", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected the versions of the objects read, got %v", versions)
+	}
+
+	if values["replicas"] != float64(3) {
+		t.Fatalf("expected valuesObject to override the values files, got %v", values["replicas"])
+	}
+	db := values["db"].(map[string]interface{})
+	if db["host"] != "db.local" || db["port"] != float64(6432) || db["password"] != "s3cr3t,with=commas" {
+		t.Fatalf("unexpected db values %v", db)
+	}
+
+	spec.ValuesFrom[2].Optional = false
+	if _, _, err := ResolveValues(context.Background(), c, "acme", spec); err == nil {
+		t.Fatal("expected a missing secret to fail")
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	base := map[string]interface{}{"image": map[string]interface{}{"repository": "app", "tag": "1.0"}}
+	merged := MergeValues(MergeValues(nil, base), map[string]interface{}{
+		"image": map[string]interface{}{"tag": "1.1"},
+	})
+
+	if merged["image"].(map[string]interface{})["tag"] != "1.1" {
+		t.Fatalf("expected the tag to be overridden, got %v", merged)
+	}
+	if base["image"].(map[string]interface{})["tag"] != "1.0" {
+		t.Fatal("expected the base values to be left alone")
+	}
+}
+
+func TestSpecHash(t *testing.T) {
+	spec := v1.ChartSpec{
+		ChartName:    "app",
+		Version:      "1.0.0",
+		Values:       []string{"c=3"},
+		ValuesObject: &apiextensionsv1.JSON{Raw: []byte(`{"a": "1", "b": "2"}`)},
+	}
+	versions := []string{"Secret/db/uid@1"}
+	first, _ := SpecHash(spec, versions)
+	spec.ValuesObject = &apiextensionsv1.JSON{Raw: []byte(`{"b": "2", "a": "1"}`)}
+	if second, _ := SpecHash(spec, versions); first != second {
+		t.Fatal("expected the hash not to depend on the order of the keys")
+	}
+	spec.ValuesObject = &apiextensionsv1.JSON{Raw: []byte(`{"a": "1", "b": "changed"}`)}
+	if changed, _ := SpecHash(spec, versions); first == changed {
+		t.Fatal("expected the hash to change with the values")
+	}
+	spec.ValuesObject = &apiextensionsv1.JSON{Raw: []byte(`{"a": "1", "b": "2"}`)}
+	if changed, _ := SpecHash(spec, []string{"Secret/db/uid@2"}); first == changed {
+		t.Fatal("expected the hash to change with the secrets read")
+	}
+	spec.Version = "1.1.0"
+	if upgraded, _ := SpecHash(spec, versions); first == upgraded {
+		t.Fatal("expected the hash to change with the version")
+	}
+}
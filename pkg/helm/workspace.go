@@ -0,0 +1,101 @@
+package helm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// workspace is the private directory of an action, holding its helm
+// settings, repositories file, chart cache and credentials. It is removed
+// once the action is done, so the actions share no file but the index cache
+// and no credentials are left on disk.
+type workspace struct {
+	dir      string
+	settings *cli.EnvSettings
+	caFile   string
+	certFile string
+	keyFile  string
+}
+
+// newWorkspace creates a workspace with the files of credentials, logging
+// in the registry host when it is set.
+func newWorkspace(credentials *Credentials, host string) (*workspace, error) {
+	dir, err := os.MkdirTemp("", "helm-")
+	if err != nil {
+		return nil, err
+	}
+	settings := cli.New()
+	settings.RepositoryConfig = filepath.Join(dir, "repositories.yaml")
+	settings.RepositoryCache = filepath.Join(dir, "cache")
+	ws := &workspace{dir: dir, settings: settings}
+
+	if err := ws.writeCredentials(credentials, host); err != nil {
+		ws.remove()
+		return nil, err
+	}
+	return ws, nil
+}
+
+func (ws *workspace) writeCredentials(credentials *Credentials, host string) error {
+	if credentials == nil {
+		return nil
+	}
+
+	var err error
+	if ws.caFile, err = ws.write(CAKey, credentials.CAData); err != nil {
+		return err
+	}
+	if ws.certFile, err = ws.write(CertKey, credentials.CertData); err != nil {
+		return err
+	}
+	if ws.keyFile, err = ws.write(KeyKey, credentials.KeyData); err != nil {
+		return err
+	}
+
+	if host == "" || credentials.Username == "" {
+		return nil
+	}
+	// the docker config format the registry client reads
+	config, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			host: map[string]string{
+				"auth": base64.StdEncoding.EncodeToString([]byte(credentials.Username + ":" + credentials.Password)),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	registryConfig, err := ws.write("config.json", config)
+	if err != nil {
+		return err
+	}
+	ws.settings.RegistryConfig = registryConfig
+	return nil
+}
+
+// write writes data to the file name of the workspace, readable by its
+// owner only. It returns the path of the file, empty without data.
+func (ws *workspace) write(name string, data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	path := filepath.Join(ws.dir, name)
+	return path, os.WriteFile(path, data, 0600)
+}
+
+// addRepo writes entry to the repositories file of the workspace.
+func (ws *workspace) addRepo(entry repo.Entry) error {
+	f := repo.NewFile()
+	f.Update(&entry)
+	return f.WriteFile(ws.settings.RepositoryConfig, 0600)
+}
+
+func (ws *workspace) remove() {
+	os.RemoveAll(ws.dir)
+}
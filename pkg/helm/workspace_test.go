@@ -0,0 +1,43 @@
+package helm
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestNewWorkspace(t *testing.T) {
+	ws, err := newWorkspace(&Credentials{Username: "AWS", Password: "token", CAData: []byte("ca bundle")}, "registry.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.remove()
+
+	if ws.certFile != "" || ws.keyFile != "" {
+		t.Fatalf("expected no client certificate files, got %+v", ws)
+	}
+	if ca, err := os.ReadFile(ws.caFile); err != nil || string(ca) != "ca bundle" {
+		t.Fatalf("unexpected CA file %q, %v", ca, err)
+	}
+
+	data, err := os.ReadFile(ws.settings.RegistryConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Auths["registry.example.com"].Auth != "QVdTOnRva2Vu" {
+		t.Fatalf("unexpected registry config %s", data)
+	}
+
+	ws.remove()
+	if _, err := os.Stat(ws.dir); !os.IsNotExist(err) {
+		t.Fatal("expected the workspace to be removed")
+	}
+}
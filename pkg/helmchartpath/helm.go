@@ -1,27 +1,19 @@
 package helmchartpath
 
 import (
-	"context"
-	"io/ioutil"
+	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
-	"sync"
 	"time"
 
-	"github.com/gofrs/flock"
-	"github.com/pkg/errors"
-	"gopkg.in/yaml.v3"
+	"github.com/go-logr/logr"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/getter"
-	"helm.sh/helm/v3/pkg/repo"
-	"k8s.io/klog/v2"
-)
 
-var settings *cli.EnvSettings
+	"github.com/baazhq/baaz/pkg/logging"
+)
 
 type HelmAct interface {
 	Apply() error
@@ -38,6 +30,7 @@ type Helm struct {
 	//RepoName    string
 	ChartPath string
 	//RepoUrl     string
+	log logr.Logger
 }
 
 func NewHelm(
@@ -52,6 +45,11 @@ func NewHelm(
 		ChartPath:    chartPath,
 		Values:       values,
 		StringValues: stringValues,
+		log: logging.Logger(logging.Helm).WithValues(
+			logging.KeyRelease, releaseName,
+			logging.KeyChart, chartPath,
+			"namespace", namespace,
+		),
 	}
 }
 
@@ -61,7 +59,7 @@ func (h *Helm) List() (status string, exists bool) {
 
 	settings := cli.New()
 
-	if err := h.Action.Init(settings.RESTClientGetter(), h.Namespace, os.Getenv("HELM_DRIVER"), klog.Infof); err != nil {
+	if err := h.Action.Init(settings.RESTClientGetter(), h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
 		return "", false
 	}
 
@@ -88,7 +86,7 @@ func (h *Helm) Uninstall() error {
 
 	settings := cli.New()
 
-	if err := h.Action.Init(settings.RESTClientGetter(), h.Namespace, os.Getenv("HELM_DRIVER"), klog.Infof); err != nil {
+	if err := h.Action.Init(settings.RESTClientGetter(), h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
 		return err
 	}
 
@@ -104,7 +102,7 @@ func (h *Helm) Uninstall() error {
 		return err
 	}
 
-	klog.Infof("Uninstalling Release Name: [%s] Namespace [%s] Status [%s]", release.Release.Name, release.Release.Namespace, release.Release.Info.Status)
+	h.log.Info("uninstalled release", "status", release.Release.Info.Status)
 
 	return nil
 }
@@ -115,7 +113,7 @@ func (h *Helm) Apply() error {
 
 	settings := cli.New()
 
-	if err := h.Action.Init(settings.RESTClientGetter(), h.Namespace, os.Getenv("HELM_DRIVER"), klog.Infof); err != nil {
+	if err := h.Action.Init(settings.RESTClientGetter(), h.Namespace, os.Getenv("HELM_DRIVER"), h.debug); err != nil {
 		return err
 	}
 
@@ -128,11 +126,6 @@ func (h *Helm) Apply() error {
 		return err
 	}
 
-	err = h.RepoUpdate()
-	if err != nil {
-		//	return err
-	}
-
 	chartRequested, err := loader.Load(cp)
 	if err != nil {
 		return err
@@ -161,105 +154,12 @@ func (h *Helm) Apply() error {
 		return err
 	}
 
-	klog.Infof("Release Name: [%s] Namespace [%s] Status [%s]", release.Name, release.Namespace, release.Info.Status)
+	h.log.Info("applied release", "status", release.Info.Status)
 
 	return nil
 }
 
-// ref: https://github.com/PrasadG193/helm-clientgo-example/tree/master
-// RepoUpdate updates charts for all helm repos
-func (helm *Helm) RepoUpdate() error {
-	settings = cli.New()
-
-	repoFile := settings.RepositoryConfig
-
-	f, err := repo.LoadFile(repoFile)
-	if os.IsNotExist(errors.Cause(err)) || len(f.Repositories) == 0 {
-		klog.Error(errors.New("no repositories found. You must add one before updating"))
-		return err
-	}
-	var repos []*repo.ChartRepository
-	for _, cfg := range f.Repositories {
-		r, err := repo.NewChartRepository(cfg, getter.All(settings))
-		if err != nil {
-			return err
-		}
-		repos = append(repos, r)
-	}
-
-	var wg sync.WaitGroup
-	for _, re := range repos {
-		wg.Add(1)
-		go func(re *repo.ChartRepository) {
-			defer wg.Done()
-			if _, err := re.DownloadIndexFile(); err != nil {
-				klog.Errorf("...Unable to get an update from the %q chart repository (%s):\n\t%s\n", re.Config.Name, re.Config.URL, err)
-				return
-			}
-		}(re)
-	}
-	wg.Wait()
-
-	return nil
-}
-
-// Ref: https://github.com/PrasadG193/helm-clientgo-example/tree/master
-// RepoAdd adds repo with given name and url
-func repoAdd(name, url string) {
-	settings := cli.New()
-
-	repoFile := settings.RepositoryConfig
-
-	//Ensure the file directory exists as it is required for file locking
-	err := os.MkdirAll(filepath.Dir(repoFile), os.ModePerm)
-	if err != nil && !os.IsExist(err) {
-		klog.Error(err)
-	}
-
-	// Acquire a file lock for process synchronization
-	fileLock := flock.New(strings.Replace(repoFile, filepath.Ext(repoFile), ".lock", 1))
-	lockCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	locked, err := fileLock.TryLockContext(lockCtx, time.Second)
-	if err == nil && locked {
-		defer fileLock.Unlock()
-	}
-	if err != nil {
-		klog.Error(err)
-	}
-
-	b, err := ioutil.ReadFile(repoFile)
-	if err != nil && !os.IsNotExist(err) {
-		klog.Error(err)
-	}
-
-	var f repo.File
-	if err := yaml.Unmarshal(b, &f); err != nil {
-		klog.Error(err)
-	}
-
-	if f.Has(name) {
-		return
-	}
-
-	c := repo.Entry{
-		Name: name,
-		URL:  url,
-	}
-
-	r, err := repo.NewChartRepository(&c, getter.All(settings))
-	if err != nil {
-		klog.Error(err)
-	}
-
-	if _, err := r.DownloadIndexFile(); err != nil {
-		err := errors.Wrapf(err, "looks like %q is not a valid chart repository or cannot be reached", url)
-		klog.Error(err)
-	}
-
-	f.Update(&c)
-
-	if err := f.WriteFile(repoFile, 0644); err != nil {
-		klog.Error(err)
-	}
+// debug forwards the helm action logs at V(1).
+func (h *Helm) debug(format string, v ...interface{}) {
+	h.log.V(1).Info(fmt.Sprintf(format, v...))
 }
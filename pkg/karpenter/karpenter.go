@@ -0,0 +1,266 @@
+// Package karpenter renders the karpenter NodePools and EC2NodeClasses of
+// the tenant machine pools when karpenter provisions the nodes of a
+// dataplane instead of managed nodegroups.
+package karpenter
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+const (
+	// ChartRepoURL, ChartName and DefaultVersion locate the karpenter chart.
+	ChartRepoURL   = "oci://public.ecr.aws/karpenter"
+	ChartName      = "karpenter"
+	DefaultVersion = "1.0.6"
+
+	// NodePoolLabel and CapacityTypeLabel are set by karpenter on its nodes.
+	NodePoolLabel     = "karpenter.sh/nodepool"
+	CapacityTypeLabel = "karpenter.sh/capacity-type"
+	// TenantsInfraLabel is set on the NodePools and EC2NodeClasses of a
+	// TenantsInfra to find the removed ones.
+	TenantsInfraLabel = "baaz.dev/tenants-infra"
+
+	CapacityTypeSpot     = "spot"
+	CapacityTypeOnDemand = "on-demand"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "baaz"
+	// amiAlias tracks the latest eks optimized ami of the cluster version, so
+	// nodes drift to the new ami after a control plane upgrade.
+	amiAlias = "al2023@latest"
+)
+
+var amiAliases = map[v1.AmiFamily]string{
+	v1.AmiFamilyAL2:          "al2@latest",
+	v1.AmiFamilyAL2023:       amiAlias,
+	v1.AmiFamilyBottlerocket: "bottlerocket@latest",
+}
+
+var (
+	NodePoolGVR     = schema.GroupVersionResource{Group: "karpenter.sh", Version: "v1", Resource: "nodepools"}
+	NodeClaimGVR    = schema.GroupVersionResource{Group: "karpenter.sh", Version: "v1", Resource: "nodeclaims"}
+	EC2NodeClassGVR = schema.GroupVersionResource{Group: "karpenter.k8s.aws", Version: "v1", Resource: "ec2nodeclasses"}
+)
+
+// NodeClass holds the cluster settings of the nodes of an EC2NodeClass.
+type NodeClass struct {
+	InstanceProfile  string
+	SubnetIDs        []string
+	SecurityGroupIDs []string
+}
+
+// CapacityTypes returns the capacity types a machine pool may launch. Low
+// priority pools with strict scheduling fall back to on-demand nodes when
+// no spot capacity is available, like their dedicated nodegroup.
+func CapacityTypes(machineSpec v1.MachineSpec) []string {
+	if machineSpec.Type != v1.MachineTypeLowPriority {
+		return []string{CapacityTypeOnDemand}
+	}
+	if machineSpec.StrictScheduling == v1.StrictSchedulingStatusEnable {
+		return []string{CapacityTypeSpot, CapacityTypeOnDemand}
+	}
+	return []string{CapacityTypeSpot}
+}
+
+// NodePool renders the NodePool of a machine pool, named like its managed
+// nodegroup. Max bounds the number of nodes, karpenter has no minimum.
+func NodePool(name, owner string, machineSpec v1.MachineSpec, taints []corev1.Taint) *unstructured.Unstructured {
+	var taintList []interface{}
+	for _, taint := range taints {
+		taintList = append(taintList, map[string]interface{}{
+			"key":    taint.Key,
+			"value":  taint.Value,
+			"effect": string(taint.Effect),
+		})
+	}
+
+	template := map[string]interface{}{
+		"nodeClassRef": map[string]interface{}{
+			"group": EC2NodeClassGVR.Group,
+			"kind":  "EC2NodeClass",
+			"name":  name,
+		},
+		"requirements": []interface{}{
+			map[string]interface{}{
+				"key":      corev1.LabelInstanceTypeStable,
+				"operator": "In",
+				"values":   toInterfaces([]string{machineSpec.Size}),
+			},
+			map[string]interface{}{
+				"key":      CapacityTypeLabel,
+				"operator": "In",
+				"values":   toInterfaces(CapacityTypes(machineSpec)),
+			},
+		},
+	}
+	if len(taintList) > 0 {
+		template["taints"] = taintList
+	}
+
+	spec := map[string]interface{}{
+		"template": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels": toInterfaceMap(machineSpec.NodeLabels),
+			},
+			"spec": template,
+		},
+		"disruption": map[string]interface{}{
+			"consolidationPolicy": "WhenEmptyOrUnderutilized",
+			"consolidateAfter":    "1m",
+		},
+	}
+	if machineSpec.Max > 0 {
+		spec["limits"] = map[string]interface{}{
+			"nodes": strconv.Itoa(int(machineSpec.Max)),
+		}
+	}
+
+	return newObject(NodePoolGVR, "NodePool", name, owner, spec)
+}
+
+// EC2NodeClass renders the EC2NodeClass of the NodePool name with the node
+// settings of its machine pool. Karpenter has no ssh key setting, ssh keys
+// only apply to nodegroups. The cluster tag of the nodes is added by
+// Karpenter, which rejects node classes setting it.
+func EC2NodeClass(name, owner string, nodeClass NodeClass, machineSpec v1.MachineSpec) *unstructured.Unstructured {
+	var subnets, securityGroups []interface{}
+	for _, id := range nodeClass.SubnetIDs {
+		subnets = append(subnets, map[string]interface{}{"id": id})
+	}
+	for _, id := range nodeClass.SecurityGroupIDs {
+		securityGroups = append(securityGroups, map[string]interface{}{"id": id})
+	}
+	for _, id := range machineSpec.SecurityGroupIds {
+		securityGroups = append(securityGroups, map[string]interface{}{"id": id})
+	}
+
+	spec := map[string]interface{}{
+		"amiSelectorTerms":           amiSelectorTerms(machineSpec),
+		"instanceProfile":            nodeClass.InstanceProfile,
+		"subnetSelectorTerms":        subnets,
+		"securityGroupSelectorTerms": securityGroups,
+	}
+	if machineSpec.AmiFamily == v1.AmiFamilyCustom {
+		spec["amiFamily"] = string(v1.AmiFamilyCustom)
+	}
+	spec["blockDeviceMappings"] = []interface{}{blockDeviceMapping(machineSpec)}
+	spec["metadataOptions"] = metadataOptions(machineSpec)
+	if machineSpec.UserData != "" {
+		spec["userData"] = machineSpec.UserData
+	}
+
+	return newObject(EC2NodeClassGVR, "EC2NodeClass", name, owner, spec)
+}
+
+func amiSelectorTerms(machineSpec v1.MachineSpec) []interface{} {
+	if machineSpec.AmiFamily == v1.AmiFamilyCustom {
+		return []interface{}{map[string]interface{}{"id": machineSpec.AmiID}}
+	}
+	alias, ok := amiAliases[machineSpec.AmiFamily]
+	if !ok {
+		alias = amiAlias
+	}
+	return []interface{}{map[string]interface{}{"alias": alias}}
+}
+
+// blockDeviceMapping sets the volume of the nodes like the launch templates
+// of the nodegroups: the data volume on Bottlerocket, encrypted gp3 volumes
+// by default.
+func blockDeviceMapping(machineSpec v1.MachineSpec) map[string]interface{} {
+	disk := machineSpec.DiskWithDefaults()
+	ebs := map[string]interface{}{
+		"volumeSize":          strconv.Itoa(int(disk.SizeGiB)) + "Gi",
+		"volumeType":          disk.Type,
+		"encrypted":           true,
+		"deleteOnTermination": true,
+	}
+	if disk.Iops > 0 {
+		ebs["iops"] = int64(disk.Iops)
+	}
+	if disk.Throughput > 0 {
+		ebs["throughput"] = int64(disk.Throughput)
+	}
+	if disk.KmsKeyID != "" {
+		ebs["kmsKeyID"] = disk.KmsKeyID
+	}
+	return map[string]interface{}{
+		"deviceName": machineSpec.DiskDeviceName(),
+		"ebs":        ebs,
+	}
+}
+
+// metadataOptions sets the instance metadata of the nodes like the launch
+// templates of the nodegroups, karpenter defaults to a hop limit of 1.
+func metadataOptions(machineSpec v1.MachineSpec) map[string]interface{} {
+	metadata := machineSpec.MetadataWithDefaults()
+	return map[string]interface{}{
+		"httpEndpoint":            "enabled",
+		"httpTokens":              metadata.HttpTokens,
+		"httpPutResponseHopLimit": int64(metadata.HopLimit),
+	}
+}
+
+// Readiness reads the Ready condition of a NodePool or EC2NodeClass.
+func Readiness(obj *unstructured.Unstructured) (bool, string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		if condition["status"] == string(corev1.ConditionTrue) {
+			return true, ""
+		}
+		message, _ := condition["message"].(string)
+		return false, message
+	}
+	return false, "waiting for karpenter to report readiness"
+}
+
+// Nodes returns the number of nodes launched for a NodePool.
+func Nodes(nodePool *unstructured.Unstructured) int64 {
+	nodes, found, _ := unstructured.NestedString(nodePool.Object, "status", "resources", "nodes")
+	if !found {
+		return 0
+	}
+	quantity, err := resource.ParseQuantity(nodes)
+	if err != nil {
+		return 0
+	}
+	return quantity.Value()
+}
+
+func newObject(gvr schema.GroupVersionResource, kind, name, owner string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetAPIVersion(gvr.GroupVersion().String())
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetLabels(map[string]string{
+		managedByLabel:    managedBy,
+		TenantsInfraLabel: owner,
+	})
+	return obj
+}
+
+func toInterfaces(values []string) []interface{} {
+	out := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		out = append(out, v)
+	}
+	return out
+}
+
+func toInterfaceMap(values map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package karpenter
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func TestCapacityTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		machineSpec v1.MachineSpec
+		want        []string
+	}{
+		{
+			name:        "default priority",
+			machineSpec: v1.MachineSpec{Type: v1.MachineTypeDefaultPriority},
+			want:        []string{CapacityTypeOnDemand},
+		},
+		{
+			name:        "low priority",
+			machineSpec: v1.MachineSpec{Type: v1.MachineTypeLowPriority},
+			want:        []string{CapacityTypeSpot},
+		},
+		{
+			name:        "low priority with strict scheduling",
+			machineSpec: v1.MachineSpec{Type: v1.MachineTypeLowPriority, StrictScheduling: v1.StrictSchedulingStatusEnable},
+			want:        []string{CapacityTypeSpot, CapacityTypeOnDemand},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CapacityTypes(tt.machineSpec); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNodePool(t *testing.T) {
+	machineSpec := v1.MachineSpec{
+		Name:       "app",
+		Size:       "t3.medium",
+		Max:        5,
+		NodeLabels: map[string]string{"tier": "app"},
+		Type:       v1.MachineTypeLowPriority,
+	}
+	taints := []corev1.Taint{{Key: "application", Value: "small-app-t3-medium", Effect: corev1.TaintEffectNoSchedule}}
+
+	nodePool := NodePool("small-app-t3-medium", "infra", machineSpec, taints)
+
+	if nodePool.GetAPIVersion() != "karpenter.sh/v1" || nodePool.GetKind() != "NodePool" {
+		t.Fatalf("unexpected type %s %s", nodePool.GetAPIVersion(), nodePool.GetKind())
+	}
+	if nodePool.GetLabels()[TenantsInfraLabel] != "infra" {
+		t.Fatalf("expected the owner label, got %v", nodePool.GetLabels())
+	}
+	nodeClass, _, _ := unstructured.NestedString(nodePool.Object, "spec", "template", "spec", "nodeClassRef", "name")
+	if nodeClass != "small-app-t3-medium" {
+		t.Fatalf("expected the node class of the same name, got %q", nodeClass)
+	}
+	requirements, _, _ := unstructured.NestedSlice(nodePool.Object, "spec", "template", "spec", "requirements")
+	wantRequirements := []interface{}{
+		map[string]interface{}{"key": corev1.LabelInstanceTypeStable, "operator": "In", "values": []interface{}{"t3.medium"}},
+		map[string]interface{}{"key": CapacityTypeLabel, "operator": "In", "values": []interface{}{CapacityTypeSpot}},
+	}
+	if !reflect.DeepEqual(requirements, wantRequirements) {
+		t.Fatalf("unexpected requirements %v", requirements)
+	}
+	renderedTaints, _, _ := unstructured.NestedSlice(nodePool.Object, "spec", "template", "spec", "taints")
+	if len(renderedTaints) != 1 || renderedTaints[0].(map[string]interface{})["value"] != "small-app-t3-medium" {
+		t.Fatalf("unexpected taints %v", renderedTaints)
+	}
+	if limit, _, _ := unstructured.NestedString(nodePool.Object, "spec", "limits", "nodes"); limit != "5" {
+		t.Fatalf("expected a limit of 5 nodes, got %q", limit)
+	}
+}
+
+func TestEC2NodeClass(t *testing.T) {
+	nodeClass := EC2NodeClass("pool", "infra", NodeClass{
+		InstanceProfile:  "cluster-karpenter-node-role",
+		SubnetIDs:        []string{"subnet-a", "subnet-b"},
+		SecurityGroupIDs: []string{"sg-a"},
+	}, v1.MachineSpec{})
+
+	subnets, _, _ := unstructured.NestedSlice(nodeClass.Object, "spec", "subnetSelectorTerms")
+	if len(subnets) != 2 {
+		t.Fatalf("expected a subnet term per subnet, got %v", subnets)
+	}
+	if profile, _, _ := unstructured.NestedString(nodeClass.Object, "spec", "instanceProfile"); profile != "cluster-karpenter-node-role" {
+		t.Fatalf("unexpected instance profile %q", profile)
+	}
+	if tags, found, _ := unstructured.NestedMap(nodeClass.Object, "spec", "tags"); found {
+		t.Fatalf("expected no tags, Karpenter tags the nodes with their cluster, got %v", tags)
+	}
+	mappings, _, _ := unstructured.NestedSlice(nodeClass.Object, "spec", "blockDeviceMappings")
+	if len(mappings) != 1 {
+		t.Fatalf("expected the volume of the nodegroups without disk settings, got %v", mappings)
+	}
+	ebs := mappings[0].(map[string]interface{})["ebs"].(map[string]interface{})
+	if ebs["volumeSize"] != "20Gi" || ebs["volumeType"] != "gp3" || ebs["encrypted"] != true {
+		t.Fatalf("expected an encrypted 20Gi gp3 volume, got %v", ebs)
+	}
+	if hopLimit, _, _ := unstructured.NestedInt64(nodeClass.Object, "spec", "metadataOptions", "httpPutResponseHopLimit"); hopLimit != 2 {
+		t.Fatalf("expected the hop limit of the nodegroups, got %d", hopLimit)
+	}
+}
+
+func TestEC2NodeClassMachineSettings(t *testing.T) {
+	nodeClass := EC2NodeClass("pool", "infra", NodeClass{SecurityGroupIDs: []string{"sg-cluster"}}, v1.MachineSpec{
+		AmiFamily:        v1.AmiFamilyBottlerocket,
+		Disk:             &v1.DiskSpec{SizeGiB: 100, KmsKeyID: "key"},
+		Metadata:         &v1.MetadataSpec{HopLimit: 1},
+		SecurityGroupIds: []string{"sg-extra"},
+		UserData:         "[settings.kubernetes]\nmax-pods = 110",
+	})
+
+	terms, _, _ := unstructured.NestedSlice(nodeClass.Object, "spec", "amiSelectorTerms")
+	if !reflect.DeepEqual(terms, []interface{}{map[string]interface{}{"alias": "bottlerocket@latest"}}) {
+		t.Fatalf("unexpected ami selector terms %v", terms)
+	}
+	securityGroups, _, _ := unstructured.NestedSlice(nodeClass.Object, "spec", "securityGroupSelectorTerms")
+	if len(securityGroups) != 2 {
+		t.Fatalf("expected the cluster and extra security groups, got %v", securityGroups)
+	}
+	mappings, _, _ := unstructured.NestedSlice(nodeClass.Object, "spec", "blockDeviceMappings")
+	mapping := mappings[0].(map[string]interface{})
+	ebs := mapping["ebs"].(map[string]interface{})
+	if mapping["deviceName"] != "/dev/xvdb" || ebs["volumeSize"] != "100Gi" || ebs["kmsKeyID"] != "key" {
+		t.Fatalf("unexpected block device mapping %v", mapping)
+	}
+	if hopLimit, _, _ := unstructured.NestedInt64(nodeClass.Object, "spec", "metadataOptions", "httpPutResponseHopLimit"); hopLimit != 1 {
+		t.Fatalf("expected a hop limit of 1, got %d", hopLimit)
+	}
+
+	custom := EC2NodeClass("pool", "infra", NodeClass{}, v1.MachineSpec{AmiFamily: v1.AmiFamilyCustom, AmiID: "ami-123"})
+	if family, _, _ := unstructured.NestedString(custom.Object, "spec", "amiFamily"); family != "Custom" {
+		t.Fatalf("expected the Custom ami family, got %q", family)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	if ready, _ := Readiness(obj); ready {
+		t.Fatal("expected a NodePool without status not to be ready")
+	}
+
+	_ = unstructured.SetNestedSlice(obj.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "False", "message": "NodeClassReady=False"},
+	}, "status", "conditions")
+	if ready, message := Readiness(obj); ready || message != "NodeClassReady=False" {
+		t.Fatalf("expected not ready with the condition message, got %v %q", ready, message)
+	}
+
+	_ = unstructured.SetNestedSlice(obj.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "True"},
+	}, "status", "conditions")
+	_ = unstructured.SetNestedField(obj.Object, "3", "status", "resources", "nodes")
+	if ready, _ := Readiness(obj); !ready || Nodes(obj) != 3 {
+		t.Fatalf("expected ready with 3 nodes, got %v %d", ready, Nodes(obj))
+	}
+}
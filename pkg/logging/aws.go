@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// AWSValues returns the service, operation, request ID and error code of an
+// aws sdk error as log key values. It returns nil for other errors.
+func AWSValues(err error) []interface{} {
+	var kv []interface{}
+
+	var opErr *smithy.OperationError
+	if errors.As(err, &opErr) {
+		kv = append(kv, KeyAWSService, opErr.ServiceID, KeyAWSOperation, opErr.OperationName)
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		kv = append(kv, KeyAWSRequestID, respErr.ServiceRequestID())
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		kv = append(kv, KeyAWSErrorCode, apiErr.ErrorCode())
+	}
+	return kv
+}
+
+// WithAwsAPILogging is a config.LoadOptions func which logs every failed
+// call of the aws clients built from the config with its request ID, using
+// the logger of the call context. Successful calls are logged at V(1).
+func WithAwsAPILogging() config.LoadOptionsFunc {
+	return config.WithAPIOptions([]func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			// added to the initialize step so retries are logged once
+			return stack.Initialize.Add(awsAPILogging{}, middleware.After)
+		},
+	})
+}
+
+type awsAPILogging struct{}
+
+func (awsAPILogging) ID() string {
+	return "BaazAPILogging"
+}
+
+func (awsAPILogging) HandleInitialize(
+	ctx context.Context,
+	in middleware.InitializeInput,
+	next middleware.InitializeHandler,
+) (middleware.InitializeOutput, middleware.Metadata, error) {
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	log := FromContext(ctx, AWS).WithValues(
+		KeyAWSService, awsmiddleware.GetServiceID(ctx),
+		KeyAWSOperation, awsmiddleware.GetOperationName(ctx),
+	)
+	if err == nil {
+		requestID, _ := awsmiddleware.GetRequestIDMetadata(metadata)
+		log.V(1).Info("aws api call succeeded", KeyAWSRequestID, requestID)
+		return out, metadata, err
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+		// not found is how the controllers check for existence
+		log.V(1).Info("aws api call found no resource", KeyAWSRequestID, respErr.ServiceRequestID())
+		return out, metadata, err
+	}
+
+	kv := []interface{}{}
+	if respErr != nil {
+		kv = append(kv, KeyAWSRequestID, respErr.ServiceRequestID())
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		kv = append(kv, KeyAWSErrorCode, apiErr.ErrorCode())
+	}
+	log.Error(err, "aws api call failed", kv...)
+	return out, metadata, err
+}
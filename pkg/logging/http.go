@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/hashicorp/go-uuid"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// requestIDHeader is echoed back so callers can quote it to on-call.
+const requestIDHeader = "X-Request-Id"
+
+// pathKeys maps the path params of the api routes to log keys.
+var pathKeys = map[string]string{
+	"customer_name":     KeyCustomer,
+	"dataplane_name":    KeyDataplane,
+	"tenant_name":       KeyTenant,
+	"tenantinfra_name":  KeyTenantsInfra,
+	"tenantsinfra_name": KeyTenantsInfra,
+	"application_name":  KeyApplication,
+}
+
+// InstrumentHandler stores a logger carrying the route, request ID and the
+// objects named in the path in the request context.
+func InstrumentHandler(route string, handler http.Handler) http.Handler {
+	base := Logger(API)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestID := req.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID, _ = uuid.GenerateUUID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		kv := []interface{}{KeyRoute, route, KeyMethod, req.Method, KeyRequestID, requestID}
+		for param, value := range mux.Vars(req) {
+			if key, found := pathKeys[param]; found {
+				kv = append(kv, key, value)
+			}
+		}
+		log := base.WithValues(kv...)
+
+		handler.ServeHTTP(w, req.WithContext(ctrl.LoggerInto(req.Context(), log)))
+	})
+}
@@ -0,0 +1,184 @@
+// Package logging builds the structured loggers of the baaz controllers and
+// http api. Every subsystem has its own verbosity, set with the
+// -log-level-<subsystem> flags, and logs with the keys defined below so the
+// logs of a single customer, dataplane or tenant can be filtered.
+package logging
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Keys used by every baaz log line.
+const (
+	KeyCustomer     = "customer"
+	KeyDataplane    = "dataplane"
+	KeyTenant       = "tenant"
+	KeyTenantsInfra = "tenantsInfra"
+	KeyApplication  = "application"
+	KeyNodegroup    = "nodegroup"
+	KeyNode         = "node"
+	KeyAddon        = "addon"
+	KeyChart        = "chart"
+	KeyRollout      = "rollout"
+	KeyGitSource    = "gitSource"
+	KeyRelease      = "release"
+	KeyCluster      = "cluster"
+	KeyPhase        = "phase"
+	// KeyReconcileID is set by controller-runtime on the reconcile logger.
+	KeyReconcileID = "reconcileID"
+	KeyRequestID   = "requestID"
+	KeyRoute       = "route"
+	KeyMethod      = "method"
+	KeyStatusCode  = "statusCode"
+
+	KeyAWSService   = "awsService"
+	KeyAWSOperation = "awsOperation"
+	KeyAWSRequestID = "awsRequestID"
+	KeyAWSErrorCode = "awsErrorCode"
+)
+
+// Subsystems with their own verbosity.
+const (
+	Dataplane    = "dataplane"
+	TenantsInfra = "tenantsinfra"
+	Tenant       = "tenant"
+	Application  = "application"
+	Metering     = "metering"
+	Predicates   = "predicates"
+	API          = "api"
+	AWS          = "aws"
+	Helm         = "helm"
+	Rollout      = "rollout"
+	GitSource    = "gitsource"
+)
+
+// Subsystems lists every subsystem in the order their flags are registered.
+var Subsystems = []string{Dataplane, TenantsInfra, Tenant, Application, Metering, Predicates, API, AWS, Helm, Rollout, GitSource}
+
+var levels = make(map[string]*int, len(Subsystems))
+
+func init() {
+	for _, s := range Subsystems {
+		levels[s] = new(int)
+	}
+}
+
+// BindFlags registers a -log-level-<subsystem> flag for every subsystem.
+// Level 0 logs info and errors, higher levels enable the V(n) debug logs.
+func BindFlags(fs *flag.FlagSet) {
+	for _, s := range Subsystems {
+		fs.IntVar(levels[s], "log-level-"+s, 0, fmt.Sprintf("Verbosity of the %s logs, higher is more verbose.", s))
+	}
+}
+
+// ZapLevel returns the zap level enabling the most verbose subsystem, so the
+// filtering is left to the subsystem loggers.
+func ZapLevel() zapcore.Level {
+	max := 0
+	for _, l := range levels {
+		if *l > max {
+			max = *l
+		}
+	}
+	return zapcore.Level(-max)
+}
+
+// Logger returns the root logger of subsystem.
+func Logger(subsystem string) logr.Logger {
+	return ForSubsystem(ctrl.Log, subsystem)
+}
+
+// ForSubsystem returns l, keeping its name and values, filtered at the
+// verbosity of subsystem.
+func ForSubsystem(l logr.Logger, subsystem string) logr.Logger {
+	level, found := levels[subsystem]
+	if !found {
+		level = new(int)
+	}
+
+	sink := l.GetSink()
+	if sink == nil {
+		return l
+	}
+	if ls, ok := sink.(*levelSink); ok {
+		sink = ls.sink
+	} else if cd, ok := sink.(logr.CallDepthLogSink); ok {
+		// levelSink adds a frame between the caller and the sink
+		sink = cd.WithCallDepth(1)
+	}
+	return l.WithSink(&levelSink{sink: sink, level: level}).WithName(subsystem)
+}
+
+// FromContext returns the logger of ctx filtered at the verbosity of
+// subsystem. Reconcile contexts carry the object and reconcile ID.
+func FromContext(ctx context.Context, subsystem string) logr.Logger {
+	return ForSubsystem(ctrl.LoggerFrom(ctx), subsystem)
+}
+
+// RequestLogger is a controller LogConstructor which adds the namespace and
+// name of the reconciled object to log.
+func RequestLogger(log logr.Logger) func(*reconcile.Request) logr.Logger {
+	return func(req *reconcile.Request) logr.Logger {
+		if req == nil {
+			return log
+		}
+		return log.WithValues("namespace", req.Namespace, "name", req.Name)
+	}
+}
+
+// WithValues adds keysAndValues to the logger of ctx and returns both.
+func WithValues(ctx context.Context, keysAndValues ...interface{}) (context.Context, logr.Logger) {
+	log := ctrl.LoggerFrom(ctx).WithValues(keysAndValues...)
+	return ctrl.LoggerInto(ctx, log), log
+}
+
+// Error logs err on l, adding the aws request ID and error code when err
+// comes from the aws sdk.
+func Error(l logr.Logger, err error, msg string, keysAndValues ...interface{}) {
+	keysAndValues = append(keysAndValues, AWSValues(err)...)
+	l.WithCallDepth(1).Error(err, msg, keysAndValues...)
+}
+
+// levelSink drops the info logs above the verbosity of its subsystem.
+type levelSink struct {
+	sink  logr.LogSink
+	level *int
+}
+
+func (s *levelSink) Init(info logr.RuntimeInfo) {
+	s.sink.Init(info)
+}
+
+func (s *levelSink) Enabled(level int) bool {
+	return level <= *s.level && s.sink.Enabled(level)
+}
+
+func (s *levelSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.sink.Info(level, msg, keysAndValues...)
+}
+
+func (s *levelSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.sink.Error(err, msg, keysAndValues...)
+}
+
+func (s *levelSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &levelSink{sink: s.sink.WithValues(keysAndValues...), level: s.level}
+}
+
+func (s *levelSink) WithName(name string) logr.LogSink {
+	return &levelSink{sink: s.sink.WithName(name), level: s.level}
+}
+
+func (s *levelSink) WithCallDepth(depth int) logr.LogSink {
+	if cd, ok := s.sink.(logr.CallDepthLogSink); ok {
+		return &levelSink{sink: cd.WithCallDepth(depth), level: s.level}
+	}
+	return s
+}
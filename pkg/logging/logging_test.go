@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+func newTestLogger(lines *[]string) logr.Logger {
+	return funcr.New(func(prefix, args string) {
+		*lines = append(*lines, prefix+" "+args)
+	}, funcr.Options{Verbosity: 10})
+}
+
+func TestForSubsystemLevels(t *testing.T) {
+	var lines []string
+	base := newTestLogger(&lines)
+
+	*levels[Helm] = 1
+	*levels[AWS] = 0
+	defer func() { *levels[Helm] = 0 }()
+
+	helmLog := ForSubsystem(base, Helm)
+	helmLog.V(1).Info("debug")
+	helmLog.V(2).Info("trace")
+
+	// switching subsystem keeps the values but not the helm verbosity
+	awsLog := ForSubsystem(helmLog.WithValues(KeyCustomer, "acme"), AWS)
+	awsLog.V(1).Info("dropped")
+	awsLog.Info("kept")
+	awsLog.Error(errors.New("boom"), "failed")
+
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 log lines, got %d: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], `"debug"`) {
+		t.Errorf("expected helm debug line, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"kept"`) || !strings.Contains(lines[1], `"customer"="acme"`) {
+		t.Errorf("expected aws info line with customer, got %s", lines[1])
+	}
+	if !strings.HasPrefix(lines[1], "helm/aws") {
+		t.Errorf("expected helm/aws logger name, got %s", lines[1])
+	}
+}
+
+func TestAWSValues(t *testing.T) {
+	if kv := AWSValues(errors.New("plain")); kv != nil {
+		t.Fatalf("expected no values for a plain error, got %v", kv)
+	}
+
+	err := &smithy.OperationError{
+		ServiceID:     "EKS",
+		OperationName: "DescribeCluster",
+		Err: &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusBadRequest}},
+				Err:      &smithy.GenericAPIError{Code: "InvalidParameterException"},
+			},
+			RequestID: "req-1234",
+		},
+	}
+
+	got := map[string]interface{}{}
+	kv := AWSValues(err)
+	for i := 0; i+1 < len(kv); i += 2 {
+		got[kv[i].(string)] = kv[i+1]
+	}
+	want := map[string]string{
+		KeyAWSService:   "EKS",
+		KeyAWSOperation: "DescribeCluster",
+		KeyAWSRequestID: "req-1234",
+		KeyAWSErrorCode: "InvalidParameterException",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("expected %s=%s, got %v", k, v, got[k])
+		}
+	}
+}
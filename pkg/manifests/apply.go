@@ -0,0 +1,263 @@
+package manifests
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+const (
+	// FieldManager owns the fields baaz applies.
+	FieldManager = "baaz"
+	// ApplicationLabel and AppLabel are set on the objects applied, to the
+	// names of their Applications and of their app. The objects are only
+	// pruned with them.
+	ApplicationLabel = "baaz.dev/application"
+	AppLabel         = "baaz.dev/app"
+)
+
+const pruning = "pruning: "
+
+// applyOrder applies the namespaces and the definitions of the custom
+// resources allowed before the objects they hold or define.
+var applyOrder = map[string]int{
+	"Namespace":                0,
+	"CustomResourceDefinition": 1,
+}
+
+// Applier applies manifests to a cluster with server-side apply.
+type Applier struct {
+	Dynamic dynamic.Interface
+	Mapper  meta.ResettableRESTMapper
+	// ClusterKinds are the cluster-scoped kinds applied, the objects of the
+	// others are rejected. None are by default, the manifests of the apps
+	// only hold objects of the namespaces of their tenants.
+	ClusterKinds []schema.GroupKind
+	// OwnNamespaces applies the namespaced objects into their own
+	// namespaces, whatever they are, like the GitSources declaring the
+	// customers do. They are applied into the namespace of Apply by default.
+	OwnNamespaces bool
+}
+
+func NewApplier(restConfig *rest.Config) (*Applier, error) {
+	dc, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &Applier{
+		Dynamic: dc,
+		Mapper:  restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+	}, nil
+}
+
+// Apply applies objects with labels, the namespaced ones into namespace, or
+// into their own namespace with OwnNamespaces. It returns the status of each
+// object, an object failing to apply does not stop the others.
+func (a *Applier) Apply(
+	ctx context.Context,
+	namespace string,
+	labels map[string]string,
+	objects []*unstructured.Unstructured,
+) []v1.ResourceStatus {
+	objects = append([]*unstructured.Unstructured(nil), objects...)
+	sort.SliceStable(objects, func(i, j int) bool {
+		return order(objects[i]) < order(objects[j])
+	})
+
+	var resources []v1.ResourceStatus
+	for i, object := range objects {
+		resources = append(resources, a.apply(ctx, namespace, labels, object.DeepCopy()))
+		if object.GetKind() == "CustomResourceDefinition" && (i+1 == len(objects) || objects[i+1].GetKind() != object.GetKind()) {
+			// the kinds defined are discovered again
+			a.Mapper.Reset()
+		}
+	}
+	return resources
+}
+
+func (a *Applier) apply(ctx context.Context, namespace string, labels map[string]string, object *unstructured.Unstructured) v1.ResourceStatus {
+	status := v1.ResourceStatus{
+		APIVersion: object.GetAPIVersion(),
+		Kind:       object.GetKind(),
+		Namespace:  object.GetNamespace(),
+		Name:       object.GetName(),
+		Phase:      v1.FailedRS,
+	}
+
+	mapping, err := a.mapping(status)
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	resource := a.Dynamic.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		switch {
+		case a.OwnNamespaces && status.Namespace == "":
+			status.Message = "namespaced object has no namespace"
+			return status
+		case a.OwnNamespaces:
+		case namespace == "":
+			status.Message = "namespaced object has no namespace to be applied into"
+			return status
+		case status.Namespace == namespace:
+		case status.Namespace == "":
+			status.Namespace = namespace
+		default:
+			status.Message = fmt.Sprintf("namespace %s is not the namespace of the tenant %s", status.Namespace, namespace)
+			return status
+		}
+		object.SetNamespace(status.Namespace)
+	} else {
+		if gk := mapping.GroupVersionKind.GroupKind(); !slices.Contains(a.ClusterKinds, gk) {
+			status.Message = fmt.Sprintf("cluster-scoped kind %s is not allowed", gk)
+			return status
+		}
+		status.Namespace = ""
+		object.SetNamespace("")
+	}
+
+	objectLabels := object.GetLabels()
+	if objectLabels == nil {
+		objectLabels = make(map[string]string, len(labels))
+	}
+	for key, value := range labels {
+		objectLabels[key] = value
+	}
+	object.SetLabels(objectLabels)
+
+	var client dynamic.ResourceInterface = resource
+	if status.Namespace != "" {
+		client = resource.Namespace(status.Namespace)
+	}
+	if _, err := client.Apply(ctx, status.Name, object, metav1.ApplyOptions{FieldManager: FieldManager, Force: true}); err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	status.Phase = v1.AppliedRS
+	return status
+}
+
+// Prune deletes the objects of resources labelled with labels, like when
+// they were applied. It returns the status of the objects failing to be
+// deleted, to be pruned again.
+func (a *Applier) Prune(ctx context.Context, labels map[string]string, resources []v1.ResourceStatus) []v1.ResourceStatus {
+	var failed []v1.ResourceStatus
+	for _, status := range resources {
+		if err := a.prune(ctx, labels, status); err != nil {
+			status.Phase, status.Message = v1.FailedRS, pruning+err.Error()
+			failed = append(failed, status)
+		}
+	}
+	return failed
+}
+
+func (a *Applier) prune(ctx context.Context, labels map[string]string, status v1.ResourceStatus) error {
+	mapping, err := a.mapping(status)
+	if meta.IsNoMatchError(err) {
+		// the kind is gone with its objects
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var client dynamic.ResourceInterface = a.Dynamic.Resource(mapping.Resource)
+	if status.Namespace != "" {
+		client = a.Dynamic.Resource(mapping.Resource).Namespace(status.Namespace)
+	}
+
+	object, err := client.Get(ctx, status.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for key, value := range labels {
+		if object.GetLabels()[key] != value {
+			// the object was taken over
+			return nil
+		}
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	err = client.Delete(ctx, status.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Live returns the object of the cluster object was applied to, nil when
+// there is none.
+func (a *Applier) Live(ctx context.Context, object *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	mapping, err := a.Mapper.RESTMapping(object.GroupVersionKind().GroupKind(), object.GroupVersionKind().Version)
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var client dynamic.ResourceInterface = a.Dynamic.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		client = a.Dynamic.Resource(mapping.Resource).Namespace(object.GetNamespace())
+	}
+	live, err := client.Get(ctx, object.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return live, err
+}
+
+func (a *Applier) mapping(status v1.ResourceStatus) (*meta.RESTMapping, error) {
+	gvk := schema.FromAPIVersionAndKind(status.APIVersion, status.Kind)
+	return a.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// Stale returns the objects of previous applied but not in resources,
+// whatever their version.
+func Stale(previous, resources []v1.ResourceStatus) []v1.ResourceStatus {
+	current := make(map[string]bool, len(resources))
+	for _, status := range resources {
+		current[key(status)] = true
+	}
+	var stale []v1.ResourceStatus
+	for _, status := range previous {
+		// the objects failing to be pruned are pruned again
+		applied := status.Phase == v1.AppliedRS || strings.HasPrefix(status.Message, pruning)
+		if applied && !current[key(status)] {
+			stale = append(stale, status)
+		}
+	}
+	return stale
+}
+
+func key(status v1.ResourceStatus) string {
+	gk := schema.FromAPIVersionAndKind(status.APIVersion, status.Kind).GroupKind()
+	return gk.String() + "/" + status.Namespace + "/" + status.Name
+}
+
+func order(object *unstructured.Unstructured) int {
+	if order, ok := applyOrder[object.GetKind()]; ok {
+		return order
+	}
+	return len(applyOrder)
+}
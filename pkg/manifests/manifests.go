@@ -0,0 +1,210 @@
+// Package manifests renders the manifest sources of the apps, inline, from
+// a ConfigMap or from Git and optionally built with kustomize, and applies
+// them to the dataplanes with server-side apply.
+package manifests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/git"
+	"github.com/baazhq/baaz/pkg/helm"
+)
+
+// Manifests are the objects of a source at a revision.
+type Manifests struct {
+	// Revision is the commit of a Git source, the hash of the objects of
+	// the others.
+	Revision string
+	Objects  []*unstructured.Unstructured
+}
+
+// Render reads the manifests of source. Its ConfigMap and the Secret of its
+// Git repository are read from namespace.
+func Render(ctx context.Context, c client.Reader, namespace string, source v1.ManifestSource) (*Manifests, error) {
+	switch {
+	case source.Git != nil:
+		return renderGit(ctx, c, namespace, source)
+	case source.ConfigMap != "":
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: source.ConfigMap}, configMap); err != nil {
+			return nil, err
+		}
+		files := filesys.MakeFsInMemory()
+		for name, data := range configMap.Data {
+			if err := files.WriteFile(filepath.Join("/", name), []byte(data)); err != nil {
+				return nil, err
+			}
+		}
+		return render(files, "/", source.Kustomize, "")
+	case source.Kustomize:
+		return nil, errors.New("kustomize builds a ConfigMap or a Git directory")
+	}
+	objects, err := Decode([]byte(source.Inline))
+	if err != nil {
+		return nil, err
+	}
+	return withHash(objects)
+}
+
+func renderGit(ctx context.Context, c client.Reader, namespace string, source v1.ManifestSource) (*Manifests, error) {
+	var auth *git.Auth
+	if source.Git.SecretName != "" {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: source.Git.SecretName}, secret); err != nil {
+			return nil, err
+		}
+		auth = &git.Auth{
+			Username: string(secret.Data[helm.UsernameKey]),
+			Password: string(secret.Data[helm.PasswordKey]),
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "baaz-git-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// the apps of tenants do not read the file system of baaz
+	commit, err := git.Checkout(ctx, source.Git.URL, source.Git.Ref, auth, dir, false)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, filepath.Clean("/"+source.Git.Path))
+	return render(filesys.MakeFsOnDisk(), path, source.Kustomize, commit)
+}
+
+// render reads the manifests of the directory dir of files, or builds it
+// with kustomize. An empty revision is the hash of the objects.
+func render(files filesys.FileSystem, dir string, kustomize bool, revision string) (*Manifests, error) {
+	var objects []*unstructured.Unstructured
+	if kustomize {
+		resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(files, dir)
+		if err != nil {
+			return nil, fmt.Errorf("building %s with kustomize: %w", dir, err)
+		}
+		for _, resource := range resources.Resources() {
+			object, err := resource.Map()
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, &unstructured.Unstructured{Object: object})
+		}
+	} else {
+		var names []string
+		err := files.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !info.Mode().IsRegular() {
+				// the symlinks are not followed out of the repository
+				return nil
+			}
+			switch filepath.Ext(path) {
+			case ".yaml", ".yml", ".json":
+				names = append(names, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			data, err := files.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			decoded, err := Decode(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", strings.TrimPrefix(name, dir+"/"), err)
+			}
+			objects = append(objects, decoded...)
+		}
+	}
+
+	if revision == "" {
+		return withHash(objects)
+	}
+	return &Manifests{Revision: revision, Objects: objects}, nil
+}
+
+// Decode decodes YAML or JSON manifests, the items of the lists included.
+func Decode(data []byte) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		object := map[string]interface{}{}
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+		if len(object) == 0 {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: object}
+		if u.IsList() {
+			if err := u.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item.(*unstructured.Unstructured))
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if u.GetAPIVersion() == "" || u.GetKind() == "" || u.GetName() == "" {
+			return nil, fmt.Errorf("object %s %s has no apiVersion, kind or name", u.GetKind(), u.GetName())
+		}
+		objects = append(objects, u)
+	}
+}
+
+// SourceHash returns the hash of source, it changes with its spec.
+func SourceHash(source v1.ManifestSource) (string, error) {
+	// the interval does not change what is applied
+	source.Interval = nil
+	data, err := json.Marshal(source)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func withHash(objects []*unstructured.Unstructured) (*Manifests, error) {
+	hash := sha256.New()
+	for _, object := range objects {
+		data, err := json.Marshal(object.Object)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(data)
+	}
+	return &Manifests{Revision: hex.EncodeToString(hash.Sum(nil)), Objects: objects}, nil
+}
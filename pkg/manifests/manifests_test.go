@@ -0,0 +1,210 @@
+package manifests
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/git"
+	"github.com/baazhq/baaz/pkg/git/gittest"
+)
+
+const configMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  level: debug
+`
+
+const service = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+`
+
+func names(objects []*unstructured.Unstructured) []string {
+	var names []string
+	for _, object := range objects {
+		names = append(names, object.GetKind()+"/"+object.GetName())
+	}
+	return names
+}
+
+func TestDecode(t *testing.T) {
+	list := `{"apiVersion": "v1", "kind": "List", "items": [
+		{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "token"}}
+	]}`
+	objects, err := Decode([]byte(configMap + "---\n---\n" + service + "---\n" + list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"ConfigMap/settings", "Service/web", "Secret/token"}; !reflect.DeepEqual(names(objects), expected) {
+		t.Fatalf("expected %v, got %v", expected, names(objects))
+	}
+
+	if _, err := Decode([]byte("apiVersion: v1\nkind: ConfigMap\n")); err == nil {
+		t.Fatal("expected an object without a name to fail")
+	}
+}
+
+func TestRenderInline(t *testing.T) {
+	source := v1.ManifestSource{Inline: configMap + "---\n" + service}
+	rendered, err := Render(context.Background(), fake.NewClientBuilder().Build(), "customer", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"ConfigMap/settings", "Service/web"}; !reflect.DeepEqual(names(rendered.Objects), expected) {
+		t.Fatalf("expected %v, got %v", expected, names(rendered.Objects))
+	}
+
+	again, err := Render(context.Background(), fake.NewClientBuilder().Build(), "customer", source)
+	if err != nil || again.Revision != rendered.Revision {
+		t.Fatalf("expected the revision to be stable, got %s and %s %v", rendered.Revision, again.Revision, err)
+	}
+	changed, err := Render(context.Background(), fake.NewClientBuilder().Build(), "customer", v1.ManifestSource{Inline: service})
+	if err != nil || changed.Revision == rendered.Revision {
+		t.Fatalf("expected the revision to change with the manifests, got %s %v", changed.Revision, err)
+	}
+}
+
+func TestRenderConfigMap(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "customer", Name: "plain"},
+			Data:       map[string]string{"b.yaml": service, "a.yaml": configMap, "README": "skipped"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "customer", Name: "kustomized"},
+			Data: map[string]string{
+				"kustomization.yaml": "namePrefix: tenant-\nresources:\n- service.yaml\n",
+				"service.yaml":       service,
+			},
+		},
+	).Build()
+
+	rendered, err := Render(context.Background(), c, "customer", v1.ManifestSource{ConfigMap: "plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"ConfigMap/settings", "Service/web"}; !reflect.DeepEqual(names(rendered.Objects), expected) {
+		t.Fatalf("expected %v, got %v", expected, names(rendered.Objects))
+	}
+
+	rendered, err = Render(context.Background(), c, "customer", v1.ManifestSource{ConfigMap: "kustomized", Kustomize: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Service/tenant-web"}; !reflect.DeepEqual(names(rendered.Objects), expected) {
+		t.Fatalf("expected %v, got %v", expected, names(rendered.Objects))
+	}
+
+	if _, err := Render(context.Background(), c, "customer", v1.ManifestSource{ConfigMap: "missing"}); err == nil {
+		t.Fatal("expected a missing ConfigMap to fail")
+	}
+	if _, err := Render(context.Background(), c, "customer", v1.ManifestSource{Inline: service, Kustomize: true}); err == nil {
+		t.Fatal("expected kustomize without a directory to fail")
+	}
+}
+
+func TestRenderGit(t *testing.T) {
+	git.InstallLocal()
+	repo := gittest.New(t)
+	commit := repo.Commit(map[string]string{
+		"addons/monitoring/settings.yaml":   configMap,
+		"addons/monitoring/web/svc.yml":     service,
+		"addons/monitoring/.hidden/x.yaml":  "not: decoded",
+		"addons/overlay/kustomization.yaml": "namePrefix: tenant-\nresources:\n- svc.yml\n",
+		"addons/overlay/svc.yml":            service,
+		"other/ignored.yaml":                "not: decoded",
+	})
+	repo.Push()
+
+	// the apps can not check local repositories out, the checkout is
+	// rendered as they render the others
+	if _, err := Render(context.Background(), fake.NewClientBuilder().Build(), "customer", v1.ManifestSource{
+		Git: &v1.GitManifestSource{URL: repo.URL()},
+	}); err == nil {
+		t.Fatal("expected a local repository to fail")
+	}
+	dir := t.TempDir()
+	if _, err := git.Checkout(context.Background(), repo.URL(), "", nil, dir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := render(filesys.MakeFsOnDisk(), filepath.Join(dir, "addons/monitoring"), false, commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Revision != commit {
+		t.Fatalf("expected revision %s, got %s", commit, rendered.Revision)
+	}
+	if expected := []string{"ConfigMap/settings", "Service/web"}; !reflect.DeepEqual(names(rendered.Objects), expected) {
+		t.Fatalf("expected %v, got %v", expected, names(rendered.Objects))
+	}
+
+	rendered, err = render(filesys.MakeFsOnDisk(), filepath.Join(dir, "addons/overlay"), true, commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Service/tenant-web"}; !reflect.DeepEqual(names(rendered.Objects), expected) {
+		t.Fatalf("expected %v, got %v", expected, names(rendered.Objects))
+	}
+
+	if _, err := Render(context.Background(), fake.NewClientBuilder().Build(), "customer", v1.ManifestSource{
+		Git: &v1.GitManifestSource{URL: repo.URL(), SecretName: "missing"},
+	}); err == nil {
+		t.Fatal("expected a missing secret to fail")
+	}
+}
+
+func TestSourceHash(t *testing.T) {
+	source := v1.ManifestSource{ConfigMap: "addons"}
+	hash, err := SourceHash(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.Interval = &metav1.Duration{Duration: time.Minute}
+	if again, _ := SourceHash(source); again != hash {
+		t.Fatal("expected the interval not to change the hash")
+	}
+	source.Kustomize = true
+	if changed, _ := SourceHash(source); changed == hash {
+		t.Fatal("expected kustomize to change the hash")
+	}
+}
+
+func TestStale(t *testing.T) {
+	previous := []v1.ResourceStatus{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "tenant", Name: "settings", Phase: v1.AppliedRS},
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "tenant", Name: "web", Phase: v1.AppliedRS},
+		{APIVersion: "v1", Kind: "Service", Namespace: "tenant", Name: "web", Phase: v1.AppliedRS},
+		{APIVersion: "v1", Kind: "Secret", Namespace: "other", Name: "token", Phase: v1.FailedRS, Message: "namespace other"},
+		{APIVersion: "v1", Kind: "Secret", Namespace: "tenant", Name: "old", Phase: v1.FailedRS, Message: pruning + "forbidden"},
+	}
+	current := []v1.ResourceStatus{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "tenant", Name: "settings", Phase: v1.AppliedRS},
+		// a new version of the same kind is the same object
+		{APIVersion: "apps/v1beta2", Kind: "Deployment", Namespace: "tenant", Name: "web", Phase: v1.AppliedRS},
+	}
+
+	var stale []string
+	for _, status := range Stale(previous, current) {
+		stale = append(stale, status.Kind+"/"+status.Name)
+	}
+	if expected := []string{"Service/web", "Secret/old"}; !reflect.DeepEqual(stale, expected) {
+		t.Fatalf("expected %v to be pruned, got %v", expected, stale)
+	}
+}
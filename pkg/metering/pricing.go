@@ -0,0 +1,100 @@
+package metering
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// InstancePrice is the hourly price of a single instance type.
+type InstancePrice struct {
+	OnDemand float64 `json:"on_demand"`
+	Spot     float64 `json:"spot"`
+}
+
+// PriceTable maps instance types to their hourly price. It is kept local
+// and offline so billing never depends on the cloud pricing APIs.
+type PriceTable struct {
+	Currency  string                   `json:"currency"`
+	Instances map[string]InstancePrice `json:"instances"`
+}
+
+// DefaultPriceTable is used when no price table file is configured.
+// Prices are aws us-east-1 linux hourly rates.
+var DefaultPriceTable = PriceTable{
+	Currency: "USD",
+	Instances: map[string]InstancePrice{
+		"t2.small":   {OnDemand: 0.023, Spot: 0.0069},
+		"t2.medium":  {OnDemand: 0.0464, Spot: 0.0139},
+		"t2.large":   {OnDemand: 0.0928, Spot: 0.0278},
+		"t2.xlarge":  {OnDemand: 0.1856, Spot: 0.0557},
+		"t3.small":   {OnDemand: 0.0208, Spot: 0.0062},
+		"t3.medium":  {OnDemand: 0.0416, Spot: 0.0125},
+		"t3.large":   {OnDemand: 0.0832, Spot: 0.025},
+		"t3.xlarge":  {OnDemand: 0.1664, Spot: 0.0499},
+		"t3.2xlarge": {OnDemand: 0.3328, Spot: 0.0998},
+		"m5.large":   {OnDemand: 0.096, Spot: 0.0346},
+		"m5.xlarge":  {OnDemand: 0.192, Spot: 0.0691},
+		"m5.2xlarge": {OnDemand: 0.384, Spot: 0.1382},
+		"m5.4xlarge": {OnDemand: 0.768, Spot: 0.2765},
+		"c5.large":   {OnDemand: 0.085, Spot: 0.0306},
+		"c5.xlarge":  {OnDemand: 0.17, Spot: 0.0612},
+		"c5.2xlarge": {OnDemand: 0.34, Spot: 0.1224},
+		"r5.large":   {OnDemand: 0.126, Spot: 0.0454},
+		"r5.xlarge":  {OnDemand: 0.252, Spot: 0.0907},
+		"r5.2xlarge": {OnDemand: 0.504, Spot: 0.1814},
+	},
+}
+
+// LoadPriceTable reads a json price table from path. An empty path
+// returns the DefaultPriceTable.
+func LoadPriceTable(path string) (PriceTable, error) {
+	if path == "" {
+		return DefaultPriceTable, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return PriceTable{}, err
+	}
+
+	var pt PriceTable
+	if err := json.Unmarshal(b, &pt); err != nil {
+		return PriceTable{}, err
+	}
+	if pt.Currency == "" {
+		pt.Currency = DefaultPriceTable.Currency
+	}
+	return pt, nil
+}
+
+// HourlyPrice returns the price of one hour of instanceType. Low priority
+// machines run on spot capacity, everything else is on-demand.
+func (pt PriceTable) HourlyPrice(instanceType string, machineType v1.MachineType) (float64, error) {
+	price, found := pt.Instances[instanceType]
+	if !found {
+		return 0, fmt.Errorf("no price found for instance type %s", instanceType)
+	}
+	if machineType == v1.MachineTypeLowPriority {
+		return price.Spot, nil
+	}
+	return price.OnDemand, nil
+}
+
+// SharedShare returns the fraction of a node consumed by the given pod
+// requests. Cpu and memory are weighted equally and the result is capped at 1.
+func SharedShare(allocatableCPU, allocatableMemory, requestCPU, requestMemory float64) float64 {
+	var share float64
+	if allocatableCPU > 0 {
+		share += requestCPU / allocatableCPU / 2
+	}
+	if allocatableMemory > 0 {
+		share += requestMemory / allocatableMemory / 2
+	}
+	if share > 1 {
+		return 1
+	}
+	return share
+}
@@ -0,0 +1,121 @@
+package metering
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestHourlyPrice(t *testing.T) {
+	pt := PriceTable{
+		Currency: "USD",
+		Instances: map[string]InstancePrice{
+			"t3.medium": {OnDemand: 0.04, Spot: 0.01},
+		},
+	}
+
+	price, err := pt.HourlyPrice("t3.medium", v1.MachineTypeDefaultPriority)
+	if err != nil || !almostEqual(price, 0.04) {
+		t.Fatalf("expected on-demand price 0.04, got %v (%v)", price, err)
+	}
+
+	price, err = pt.HourlyPrice("t3.medium", v1.MachineTypeLowPriority)
+	if err != nil || !almostEqual(price, 0.01) {
+		t.Fatalf("expected spot price 0.01, got %v (%v)", price, err)
+	}
+
+	if _, err := pt.HourlyPrice("x9.huge", v1.MachineTypeDefaultPriority); err == nil {
+		t.Fatal("expected error for unknown instance type")
+	}
+}
+
+func TestLoadPriceTable(t *testing.T) {
+	pt, err := LoadPriceTable("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Currency != "USD" || len(pt.Instances) == 0 {
+		t.Fatalf("expected default price table, got %+v", pt)
+	}
+
+	path := filepath.Join(t.TempDir(), "prices.json")
+	if err := os.WriteFile(path, []byte(`{"instances": {"m5.large": {"on_demand": 0.1, "spot": 0.03}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pt, err = LoadPriceTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Currency != "USD" {
+		t.Fatalf("expected currency to default to USD, got %s", pt.Currency)
+	}
+	price, err := pt.HourlyPrice("m5.large", v1.MachineTypeLowPriority)
+	if err != nil || !almostEqual(price, 0.03) {
+		t.Fatalf("expected spot price 0.03, got %v (%v)", price, err)
+	}
+}
+
+func TestSharedShare(t *testing.T) {
+	tests := []struct {
+		name                  string
+		allocCPU, allocMemory float64
+		reqCPU, reqMemory     float64
+		want                  float64
+	}{
+		{"half cpu no memory", 2, 4, 1, 0, 0.25},
+		{"half cpu half memory", 2, 4, 1, 2, 0.5},
+		{"over requested", 2, 4, 4, 8, 1},
+		{"no allocatable", 0, 0, 1, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := SharedShare(tt.allocCPU, tt.allocMemory, tt.reqCPU, tt.reqMemory); !almostEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tr := TenantRecord{Dataplane: "dp", Billing: BillingNodeHours}
+	tr.AddSample(day.Add(10*time.Minute), Usage{NodeHours: 1, Cost: 0.5})
+	tr.AddSample(day.Add(20*time.Minute), Usage{NodeHours: 1, Cost: 0.5})
+	tr.AddSample(day.Add(5*time.Hour), Usage{NodeHours: 2, Cost: 1})
+
+	records := []map[string]TenantRecord{{"tenant-a": tr}}
+
+	all := Summarize(records, day, day.Add(24*time.Hour))
+	if len(all) != 1 || !almostEqual(all[0].NodeHours, 4) || !almostEqual(all[0].Cost, 2) {
+		t.Fatalf("unexpected summary %+v", all)
+	}
+
+	firstHours := Summarize(records, day, day.Add(5*time.Hour))
+	if len(firstHours) != 1 || !almostEqual(firstHours[0].Cost, 1) {
+		t.Fatalf("unexpected summary %+v", firstHours)
+	}
+
+	if none := Summarize(records, day.Add(6*time.Hour), day.Add(24*time.Hour)); len(none) != 0 {
+		t.Fatalf("expected empty summary, got %+v", none)
+	}
+}
+
+func TestConfigMapDay(t *testing.T) {
+	day := time.Date(2024, 3, 9, 17, 30, 0, 0, time.UTC)
+	got, ok := ConfigMapDay(ConfigMapName(day))
+	if !ok || !got.Equal(day.Truncate(24*time.Hour)) {
+		t.Fatalf("got %v %v, expected %v", got, ok, day.Truncate(24*time.Hour))
+	}
+	for _, name := range []string{"kube-root-ca.crt", "usage-latest", "usage-2024-03"} {
+		if _, ok := ConfigMapDay(name); ok {
+			t.Errorf("expected %s not to be a usage configmap", name)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package metering
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BillingMode tells how the cost of a tenant was computed.
+type BillingMode string
+
+const (
+	// BillingNodeHours bills every node of the tenant nodegroups, used for
+	// dedicated and private dataplanes.
+	BillingNodeHours BillingMode = "node_hours"
+	// BillingRequests bills the share of the node requested by the tenant
+	// pods, used for shared dataplanes.
+	BillingRequests BillingMode = "requests"
+)
+
+const (
+	// UsageLabelKey marks the configmaps holding usage records.
+	UsageLabelKey = "baaz.dev/usage"
+	// hourLayout is the key format of the hourly buckets.
+	hourLayout = time.RFC3339
+	// dayLayout is the format of the day in the names of the configmaps.
+	dayLayout       = "2006-01-02"
+	configMapPrefix = "usage-"
+)
+
+// Usage is the metered usage of a tenant over a period.
+type Usage struct {
+	NodeHours      float64 `json:"node_hours"`
+	CPUCoreHours   float64 `json:"cpu_core_hours"`
+	MemoryGiBHours float64 `json:"memory_gib_hours"`
+	Cost           float64 `json:"cost"`
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+	u.NodeHours += o.NodeHours
+	u.CPUCoreHours += o.CPUCoreHours
+	u.MemoryGiBHours += o.MemoryGiBHours
+	u.Cost += o.Cost
+}
+
+// TenantRecord holds the hourly usage buckets of a tenant for one day.
+type TenantRecord struct {
+	Dataplane string           `json:"dataplane"`
+	Billing   BillingMode      `json:"billing"`
+	Hourly    map[string]Usage `json:"hourly"`
+}
+
+// AddSample adds a usage sample taken at t to its hourly bucket.
+func (tr *TenantRecord) AddSample(t time.Time, u Usage) {
+	if tr.Hourly == nil {
+		tr.Hourly = make(map[string]Usage)
+	}
+	key := t.UTC().Truncate(time.Hour).Format(hourLayout)
+	bucket := tr.Hourly[key]
+	bucket.Add(u)
+	tr.Hourly[key] = bucket
+}
+
+// TenantUsage is the summarized usage of a tenant.
+type TenantUsage struct {
+	Tenant    string      `json:"tenant"`
+	Dataplane string      `json:"dataplane"`
+	Billing   BillingMode `json:"billing"`
+	Usage
+}
+
+// ConfigMapName returns the name of the configmap holding the usage of day t.
+func ConfigMapName(t time.Time) string {
+	return configMapPrefix + t.UTC().Format(dayLayout)
+}
+
+// ConfigMapDay returns the day of the usage configmap name, false when name
+// is not the name of a usage configmap.
+func ConfigMapDay(name string) (time.Time, bool) {
+	day, ok := strings.CutPrefix(name, configMapPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(dayLayout, day)
+	return t, err == nil
+}
+
+// MakeConfigMap returns an empty usage configmap for day t.
+func MakeConfigMap(namespace string, t time.Time) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName(t),
+			Namespace: namespace,
+			Labels: map[string]string{
+				UsageLabelKey: "true",
+			},
+		},
+		Data: map[string]string{},
+	}
+}
+
+// DecodeConfigMap returns the tenant records stored in a usage configmap,
+// keyed by tenant name.
+func DecodeConfigMap(cm *corev1.ConfigMap) (map[string]TenantRecord, error) {
+	records := make(map[string]TenantRecord, len(cm.Data))
+	for tenant, raw := range cm.Data {
+		var tr TenantRecord
+		if err := json.Unmarshal([]byte(raw), &tr); err != nil {
+			return nil, err
+		}
+		records[tenant] = tr
+	}
+	return records, nil
+}
+
+// EncodeConfigMap writes records into the data of a usage configmap.
+func EncodeConfigMap(cm *corev1.ConfigMap, records map[string]TenantRecord) error {
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, len(records))
+	}
+	for tenant, tr := range records {
+		b, err := json.Marshal(tr)
+		if err != nil {
+			return err
+		}
+		cm.Data[tenant] = string(b)
+	}
+	return nil
+}
+
+// Summarize sums the hourly buckets of every tenant falling in [from, to).
+func Summarize(records []map[string]TenantRecord, from, to time.Time) []TenantUsage {
+	from = from.UTC().Truncate(time.Hour)
+	summary := make(map[string]*TenantUsage)
+
+	for _, dayRecords := range records {
+		for tenant, tr := range dayRecords {
+			for hour, u := range tr.Hourly {
+				t, err := time.Parse(hourLayout, hour)
+				if err != nil || t.Before(from) || !t.Before(to) {
+					continue
+				}
+				tu, found := summary[tenant]
+				if !found {
+					tu = &TenantUsage{Tenant: tenant, Dataplane: tr.Dataplane, Billing: tr.Billing}
+					summary[tenant] = tu
+				}
+				tu.Add(u)
+			}
+		}
+	}
+
+	result := make([]TenantUsage, 0, len(summary))
+	for _, tu := range summary {
+		result = append(result, *tu)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Tenant < result[j].Tenant
+	})
+	return result
+}
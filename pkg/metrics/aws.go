@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const cloudAWS = "aws"
+
+// WithAwsAPIMetrics is a config.LoadOptions func which counts every call,
+// error and throttle of the aws clients built from the config.
+func WithAwsAPIMetrics() config.LoadOptionsFunc {
+	return config.WithAPIOptions([]func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			// added to the initialize step so retries are counted once
+			return stack.Initialize.Add(awsAPIMetrics{}, middleware.After)
+		},
+	})
+}
+
+type awsAPIMetrics struct{}
+
+func (awsAPIMetrics) ID() string {
+	return "BaazAPIMetrics"
+}
+
+func (awsAPIMetrics) HandleInitialize(
+	ctx context.Context,
+	in middleware.InitializeInput,
+	next middleware.InitializeHandler,
+) (middleware.InitializeOutput, middleware.Metadata, error) {
+	service := awsmiddleware.GetServiceID(ctx)
+	operation := awsmiddleware.GetOperationName(ctx)
+
+	CloudAPICalls.WithLabelValues(cloudAWS, service, operation).Inc()
+
+	out, metadata, err := next.HandleInitialize(ctx, in)
+	if err != nil {
+		code := "Unknown"
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) {
+			code = apiErr.ErrorCode()
+			if _, throttled := retry.DefaultThrottleErrorCodes[code]; throttled {
+				CloudAPIThrottles.WithLabelValues(cloudAWS, service, operation).Inc()
+			}
+		}
+		CloudAPIErrors.WithLabelValues(cloudAWS, service, operation, code).Inc()
+	}
+	return out, metadata, err
+}
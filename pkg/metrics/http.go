@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// InstrumentHandler records request count and latency of handler under
+// the route name, so path params do not blow up the label cardinality.
+func InstrumentHandler(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		handler.ServeHTTP(rec, req)
+
+		HTTPRequests.WithLabelValues(route, req.Method, strconv.Itoa(rec.status)).Inc()
+		HTTPRequestDuration.WithLabelValues(route, req.Method).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "baaz"
+
+// Resource names used by ProvisioningDuration
+const (
+	ResourceEks         = "eks"
+	ResourceNodegroup   = "nodegroup"
+	ResourceAddon       = "addon"
+	ResourceHelmRelease = "helm_release"
+)
+
+var (
+	// ProvisioningDuration is the time a resource takes from creation to active.
+	ProvisioningDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "provisioning_duration_seconds",
+			Help:      "Time taken by a resource to become active after its creation.",
+			Buckets:   []float64{30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 7200},
+		},
+		[]string{"resource", "cloud"},
+	)
+
+	// CloudAPICalls counts the calls made to the cloud provider apis.
+	CloudAPICalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cloud_api_calls_total",
+			Help:      "Number of cloud provider api calls by service and operation.",
+		},
+		[]string{"cloud", "service", "operation"},
+	)
+
+	// CloudAPIErrors counts the failed calls to the cloud provider apis.
+	CloudAPIErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cloud_api_errors_total",
+			Help:      "Number of failed cloud provider api calls by service, operation and error code.",
+		},
+		[]string{"cloud", "service", "operation", "code"},
+	)
+
+	// CloudAPIThrottles counts the cloud provider api calls rejected by throttling.
+	CloudAPIThrottles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cloud_api_throttles_total",
+			Help:      "Number of throttled cloud provider api calls by service and operation.",
+		},
+		[]string{"cloud", "service", "operation"},
+	)
+
+	// HTTPRequests counts the requests served by the baaz http api.
+	HTTPRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of http requests by route, method and status code.",
+		},
+		[]string{"route", "method", "code"},
+	)
+
+	// HTTPRequestDuration is the latency of the baaz http api.
+	HTTPRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of http requests by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method"},
+	)
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		ProvisioningDuration,
+		CloudAPICalls,
+		CloudAPIErrors,
+		CloudAPIThrottles,
+		HTTPRequests,
+		HTTPRequestDuration,
+	)
+}
+
+// ObserveProvisioning records the provisioning duration of a resource
+// created at createdAt.
+func ObserveProvisioning(resource, cloud string, createdAt time.Time) {
+	if createdAt.IsZero() {
+		return
+	}
+	ProvisioningDuration.WithLabelValues(resource, cloud).Observe(time.Since(createdAt).Seconds())
+}
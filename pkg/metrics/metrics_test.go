@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func TestRegistration(t *testing.T) {
+	for _, collector := range []prometheus.Collector{
+		ProvisioningDuration,
+		CloudAPICalls,
+		CloudAPIErrors,
+		CloudAPIThrottles,
+		HTTPRequests,
+		HTTPRequestDuration,
+	} {
+		err := ctrlmetrics.Registry.Register(collector)
+		var registered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &registered) {
+			t.Fatalf("expected the collector to be registered, got %v", err)
+		}
+	}
+
+	// the names and labels are linted like prometheus does
+	problems, err := testutil.CollectAndLint(HTTPRequests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Fatalf("unexpected lint problems %v", problems)
+	}
+}
+
+func TestInstrumentHandlerCardinality(t *testing.T) {
+	HTTPRequests.Reset()
+	HTTPRequestDuration.Reset()
+
+	handler := InstrumentHandler("GET TENANT", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	for _, path := range []string{
+		"/api/v1/customer/a/tenant/one",
+		"/api/v1/customer/b/tenant/two",
+		"/api/v1/customer/c/tenant/missing",
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// the path params are not labels, only the route and the status code
+	if n := testutil.CollectAndCount(HTTPRequests); n != 2 {
+		t.Fatalf("expected 2 request series, got %d", n)
+	}
+	if n := testutil.CollectAndCount(HTTPRequestDuration); n != 1 {
+		t.Fatalf("expected 1 duration series, got %d", n)
+	}
+	if v := testutil.ToFloat64(HTTPRequests.WithLabelValues("GET TENANT", http.MethodGet, "200")); v != 2 {
+		t.Fatalf("expected 2 successful requests, got %v", v)
+	}
+}
+
+func TestObserveProvisioning(t *testing.T) {
+	ProvisioningDuration.Reset()
+	ObserveProvisioning(ResourceEks, "aws", time.Time{})
+	if n := testutil.CollectAndCount(ProvisioningDuration); n != 0 {
+		t.Fatalf("expected the unknown creation time to be ignored, got %d series", n)
+	}
+	ObserveProvisioning(ResourceEks, "aws", time.Now().Add(-time.Minute))
+	ObserveProvisioning(ResourceNodegroup, "aws", time.Now().Add(-time.Minute))
+	if n := testutil.CollectAndCount(ProvisioningDuration); n != 2 {
+		t.Fatalf("expected 2 series, got %d", n)
+	}
+}
+
+func TestPhaseCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	dataplane := func(name string, phase v1.DataPlanePhase) *v1.DataPlanes {
+		return &v1.DataPlanes{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shared"},
+			Spec:       v1.DataPlaneSpec{CloudInfra: v1.CloudInfraConfig{CloudType: v1.AWS}},
+			Status:     v1.DataPlaneStatus{Phase: phase},
+		}
+	}
+	application := func(name string, phase v1.ApplicationPhase) *v1.Applications {
+		return &v1.Applications{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "customer"},
+			Spec:       v1.ApplicationSpec{Dataplane: "one"},
+			Status:     v1.ApplicationStatus{Phase: phase},
+		}
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		dataplane("one", v1.ActiveD),
+		dataplane("two", v1.ActiveD),
+		application("a", v1.DeployedA),
+		application("b", v1.DeployedA),
+		application("c", v1.FailedA),
+	).Build()
+
+	expected := `
+# HELP baaz_applications Number of applications by phase and cloud.
+# TYPE baaz_applications gauge
+baaz_applications{cloud="aws",phase="Deployed"} 2
+baaz_applications{cloud="aws",phase="Failed"} 1
+# HELP baaz_dataplanes Number of dataplanes by phase and cloud.
+# TYPE baaz_dataplanes gauge
+baaz_dataplanes{cloud="aws",phase="Active"} 2
+`
+	if err := testutil.CollectAndCompare(NewPhaseCollector(reader), strings.NewReader(expected),
+		"baaz_applications", "baaz_dataplanes"); err != nil {
+		t.Fatal(err)
+	}
+}
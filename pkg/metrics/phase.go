@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+var (
+	log = ctrl.Log.WithName("metrics")
+
+	dataplanesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "dataplanes"),
+		"Number of dataplanes by phase and cloud.",
+		[]string{"phase", "cloud"}, nil,
+	)
+	tenantsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "tenants"),
+		"Number of tenants by phase and cloud.",
+		[]string{"phase", "cloud"}, nil,
+	)
+	applicationsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "applications"),
+		"Number of applications by phase and cloud.",
+		[]string{"phase", "cloud"}, nil,
+	)
+)
+
+// phaseCollector counts the baaz objects by phase at scrape time, so the
+// gauges never drift from the objects stored in the cluster.
+type phaseCollector struct {
+	reader client.Reader
+}
+
+// NewPhaseCollector returns a collector of the dataplanes, tenants and
+// applications gauges read through reader.
+func NewPhaseCollector(reader client.Reader) prometheus.Collector {
+	return &phaseCollector{reader: reader}
+}
+
+func (pc *phaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dataplanesDesc
+	ch <- tenantsDesc
+	ch <- applicationsDesc
+}
+
+type phaseKey struct {
+	phase string
+	cloud string
+}
+
+func (pc *phaseCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dpList := &v1.DataPlanesList{}
+	if err := pc.reader.List(ctx, dpList); err != nil {
+		log.Error(err, "failed to list dataplanes")
+		return
+	}
+
+	dpCloud := make(map[string]string, len(dpList.Items))
+	dpCount := make(map[phaseKey]int)
+	for _, dp := range dpList.Items {
+		cloud := string(dp.Spec.CloudInfra.CloudType)
+		dpCloud[dp.Name] = cloud
+		dpCount[phaseKey{string(dp.Status.Phase), cloud}]++
+	}
+	emit(ch, dataplanesDesc, dpCount)
+
+	tenantList := &v1.TenantsList{}
+	if err := pc.reader.List(ctx, tenantList); err != nil {
+		log.Error(err, "failed to list tenants")
+	} else {
+		tenantCount := make(map[phaseKey]int)
+		for _, tenant := range tenantList.Items {
+			tenantCount[phaseKey{string(tenant.Status.Phase), dpCloud[tenant.Spec.DataplaneName]}]++
+		}
+		emit(ch, tenantsDesc, tenantCount)
+	}
+
+	appList := &v1.ApplicationsList{}
+	if err := pc.reader.List(ctx, appList); err != nil {
+		log.Error(err, "failed to list applications")
+	} else {
+		appCount := make(map[phaseKey]int)
+		for _, app := range appList.Items {
+			appCount[phaseKey{string(app.Status.Phase), dpCloud[app.Spec.Dataplane]}]++
+		}
+		emit(ch, applicationsDesc, appCount)
+	}
+}
+
+func emit(ch chan<- prometheus.Metric, desc *prometheus.Desc, count map[phaseKey]int) {
+	for key, n := range count {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(n), key.phase, key.cloud)
+	}
+}
@@ -0,0 +1,189 @@
+// Package placement tells which tenant size the pods of a workload run on
+// and moves them to the machine pools of another size, by rewriting the
+// tolerations and node affinity of their pod template.
+package placement
+
+import (
+	"reflect"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/aws/eks"
+	"github.com/baazhq/baaz/pkg/drain"
+	"github.com/baazhq/baaz/pkg/karpenter"
+)
+
+// TaintKey taints the nodes of a machine pool with the name of its
+// nodegroup or NodePool.
+const TaintKey = "application"
+
+// Placement holds the nodes of the machine pools of a tenant size. They
+// carry LabelKey with one of Names, and are tainted TaintKey=name.
+type Placement struct {
+	LabelKey string   `json:"labelKey"`
+	Names    []string `json:"names"`
+	// Labels are the node labels of the machine pools.
+	Labels []map[string]string `json:"labels,omitempty"`
+}
+
+// ForSize returns the placement of a tenant size, on the NodePools of
+// karpenter or on managed nodegroups with the dedicated on-demand
+// nodegroups of spot pools.
+func ForSize(size string, tenantSizes v1.TenantSizes, usesKarpenter bool) Placement {
+	p := Placement{LabelKey: drain.NodegroupLabel}
+	if usesKarpenter {
+		p.LabelKey = karpenter.NodePoolLabel
+	}
+
+	for _, machineSpec := range tenantSizes.MachineSpec {
+		nodegroup, dedicated := eks.MakeTenantNodegroupNames(size, machineSpec)
+		if usesKarpenter {
+			nodegroup = eks.MakeTenantMachinePoolName(size, machineSpec)
+		}
+		p.Names = append(p.Names, nodegroup)
+		if !usesKarpenter &&
+			machineSpec.StrictScheduling == v1.StrictSchedulingStatusEnable &&
+			machineSpec.Type == v1.MachineTypeLowPriority {
+			p.Names = append(p.Names, dedicated)
+		}
+		if len(machineSpec.NodeLabels) > 0 {
+			p.Labels = append(p.Labels, machineSpec.NodeLabels)
+		}
+	}
+	sort.Strings(p.Names)
+	return p
+}
+
+// Selector selects the nodes of the placement.
+func (p Placement) Selector() (labels.Selector, error) {
+	requirement, err := labels.NewRequirement(p.LabelKey, selection.In, p.Names)
+	if err != nil {
+		return nil, err
+	}
+	return labels.NewSelector().Add(*requirement), nil
+}
+
+func (p Placement) has(name string) bool {
+	for _, n := range p.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Targets tells if pods of spec may run on the placement, by tolerating
+// the taint of one of its machine pools or requiring one of them.
+func (p Placement) Targets(spec *corev1.PodSpec) bool {
+	for _, toleration := range spec.Tolerations {
+		if toleration.Key == TaintKey && p.has(toleration.Value) {
+			return true
+		}
+	}
+	for _, term := range requiredTerms(spec) {
+		for _, expression := range term.MatchExpressions {
+			if expression.Key != p.LabelKey || expression.Operator != corev1.NodeSelectorOpIn {
+				continue
+			}
+			for _, value := range expression.Values {
+				if p.has(value) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Move rewrites spec to schedule its pods on to instead of from. The
+// tolerations of the machine pools of from are replaced by the ones of to,
+// the required node affinity pins the pods to the machine pools of to, and
+// the node selectors only matching the labels of from are dropped. It tells
+// if spec changed.
+func Move(spec *corev1.PodSpec, from, to Placement) bool {
+	before := spec.DeepCopy()
+
+	var tolerations []corev1.Toleration
+	for _, toleration := range spec.Tolerations {
+		if toleration.Key == TaintKey && (from.has(toleration.Value) || to.has(toleration.Value)) {
+			continue
+		}
+		tolerations = append(tolerations, toleration)
+	}
+	for _, name := range to.Names {
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:      TaintKey,
+			Operator: corev1.TolerationOpEqual,
+			Value:    name,
+			Effect:   corev1.TaintEffectNoSchedule,
+		})
+	}
+	spec.Tolerations = tolerations
+
+	for key, value := range spec.NodeSelector {
+		if matchesLabel(from.Labels, key, value) && !matchesLabel(to.Labels, key, value) {
+			delete(spec.NodeSelector, key)
+		}
+	}
+
+	pin := corev1.NodeSelectorRequirement{
+		Key:      to.LabelKey,
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   append([]string(nil), to.Names...),
+	}
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	if spec.Affinity.NodeAffinity == nil {
+		spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	required := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if required == nil || len(required.NodeSelectorTerms) == 0 {
+		required = &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{}}}
+	}
+	for i, term := range required.NodeSelectorTerms {
+		var expressions []corev1.NodeSelectorRequirement
+		for _, expression := range term.MatchExpressions {
+			if expression.Key == from.LabelKey || expression.Key == to.LabelKey {
+				continue
+			}
+			expressions = append(expressions, expression)
+		}
+		required.NodeSelectorTerms[i].MatchExpressions = append(expressions, pin)
+	}
+	spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = required
+
+	return !reflect.DeepEqual(before, spec)
+}
+
+// Pin schedules the pods of spec on p, whatever machine pools of the same
+// kind they targeted. It tells if spec changed.
+func Pin(spec *corev1.PodSpec, p Placement) bool {
+	changed := Move(spec, Placement{LabelKey: p.LabelKey}, p)
+	if value, ok := spec.NodeSelector[p.LabelKey]; ok && !p.has(value) {
+		delete(spec.NodeSelector, p.LabelKey)
+		changed = true
+	}
+	return changed
+}
+
+func requiredTerms(spec *corev1.PodSpec) []corev1.NodeSelectorTerm {
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil ||
+		spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+	return spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+}
+
+func matchesLabel(nodeLabels []map[string]string, key, value string) bool {
+	for _, l := range nodeLabels {
+		if v, ok := l[key]; ok && v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+	"github.com/baazhq/baaz/pkg/drain"
+	"github.com/baazhq/baaz/pkg/karpenter"
+)
+
+var (
+	small = v1.TenantSizes{MachineSpec: []v1.MachineSpec{{
+		Name:             "app",
+		Size:             "t3.medium",
+		NodeLabels:       map[string]string{"size": "small"},
+		Type:             v1.MachineTypeLowPriority,
+		StrictScheduling: v1.StrictSchedulingStatusEnable,
+	}}}
+	large = v1.TenantSizes{MachineSpec: []v1.MachineSpec{{
+		Name:       "app",
+		Size:       "m5.xlarge",
+		NodeLabels: map[string]string{"size": "large"},
+		Type:       v1.MachineTypeDefaultPriority,
+	}}}
+)
+
+func TestForSize(t *testing.T) {
+	p := ForSize("small", small, false)
+	if p.LabelKey != drain.NodegroupLabel {
+		t.Fatalf("unexpected label key %q", p.LabelKey)
+	}
+	if want := []string{"small-app-t3-medium", "small-app-t3-medium-dedicated"}; !reflect.DeepEqual(p.Names, want) {
+		t.Fatalf("expected %v, got %v", want, p.Names)
+	}
+
+	p = ForSize("small", small, true)
+	if p.LabelKey != karpenter.NodePoolLabel || !reflect.DeepEqual(p.Names, []string{"small-app-t3-medium"}) {
+		t.Fatalf("expected the NodePool alone, got %s %v", p.LabelKey, p.Names)
+	}
+}
+
+func TestMove(t *testing.T) {
+	from := ForSize("small", small, false)
+	to := ForSize("large", large, false)
+
+	spec := &corev1.PodSpec{
+		NodeSelector: map[string]string{"size": "small", "disk": "ssd"},
+		Tolerations: []corev1.Toleration{
+			{Key: TaintKey, Operator: corev1.TolerationOpEqual, Value: "small-app-t3-medium", Effect: corev1.TaintEffectNoSchedule},
+			{Key: TaintKey, Operator: corev1.TolerationOpEqual, Value: "small-app-t3-medium-dedicated", Effect: corev1.TaintEffectNoSchedule},
+			{Key: "gpu", Operator: corev1.TolerationOpExists},
+		},
+		Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: drain.NodegroupLabel, Operator: corev1.NodeSelectorOpIn, Values: []string{"small-app-t3-medium"}},
+					{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}},
+				}}},
+			},
+		}},
+	}
+
+	if !from.Targets(spec) || to.Targets(spec) {
+		t.Fatal("expected the pods to target the small size only")
+	}
+	if !Move(spec, from, to) {
+		t.Fatal("expected the pod spec to change")
+	}
+	if from.Targets(spec) || !to.Targets(spec) {
+		t.Fatal("expected the pods to target the large size only")
+	}
+
+	wantTolerations := []corev1.Toleration{
+		{Key: "gpu", Operator: corev1.TolerationOpExists},
+		{Key: TaintKey, Operator: corev1.TolerationOpEqual, Value: "large-app-m5-xlarge", Effect: corev1.TaintEffectNoSchedule},
+	}
+	if !reflect.DeepEqual(spec.Tolerations, wantTolerations) {
+		t.Fatalf("unexpected tolerations %v", spec.Tolerations)
+	}
+	if !reflect.DeepEqual(spec.NodeSelector, map[string]string{"disk": "ssd"}) {
+		t.Fatalf("expected the small size selector to be dropped, got %v", spec.NodeSelector)
+	}
+	wantExpressions := []corev1.NodeSelectorRequirement{
+		{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}},
+		{Key: drain.NodegroupLabel, Operator: corev1.NodeSelectorOpIn, Values: []string{"large-app-m5-xlarge"}},
+	}
+	got := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+	if !reflect.DeepEqual(got, wantExpressions) {
+		t.Fatalf("unexpected node affinity %v", got)
+	}
+
+	if Move(spec, from, to) {
+		t.Fatal("expected a second move to be a no-op")
+	}
+}
+
+func TestMoveWithoutAffinity(t *testing.T) {
+	spec := &corev1.PodSpec{}
+	Move(spec, ForSize("small", small, true), ForSize("large", large, true))
+
+	terms := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || terms[0].MatchExpressions[0].Key != karpenter.NodePoolLabel {
+		t.Fatalf("expected a term pinning the NodePools, got %v", terms)
+	}
+}
+
+func TestPin(t *testing.T) {
+	p := ForSize("large", large, false)
+	spec := &corev1.PodSpec{
+		NodeSelector: map[string]string{drain.NodegroupLabel: "small-app-t3-medium"},
+	}
+	if !Pin(spec, p) {
+		t.Fatal("expected the pod spec to change")
+	}
+	if len(spec.NodeSelector) != 0 {
+		t.Fatalf("expected the selector of another machine pool to be dropped, got %v", spec.NodeSelector)
+	}
+	if !p.Targets(spec) {
+		t.Fatal("expected the pods to target the large size")
+	}
+	if Pin(spec, p) {
+		t.Fatal("expected a second pin to be a no-op")
+	}
+}
+
+func TestMoveValues(t *testing.T) {
+	from, to := ForSize("small", small, false), ForSize("large", large, false)
+	values := map[string]interface{}{
+		"replicas":     float64(2),
+		"nodeSelector": map[string]interface{}{drain.NodegroupLabel: "small-app-t3-medium"},
+		"tolerations": []interface{}{
+			map[string]interface{}{"key": TaintKey, "operator": "Equal", "value": "small-app-t3-medium", "effect": "NoSchedule"},
+			map[string]interface{}{"key": TaintKey, "operator": "Equal", "value": "small-app-t3-medium-dedicated", "effect": "NoSchedule"},
+			map[string]interface{}{"key": "dedicated", "operator": "Exists"},
+		},
+		"worker": map[string]interface{}{
+			"affinity": map[string]interface{}{"values": []interface{}{"small-app-t3-medium", "small-app-t3-medium-dedicated"}},
+		},
+	}
+
+	if !MoveValues(values, from, to) {
+		t.Fatal("expected the values to change")
+	}
+	want := map[string]interface{}{
+		"replicas":     float64(2),
+		"nodeSelector": map[string]interface{}{drain.NodegroupLabel: "large-app-m5-xlarge"},
+		"tolerations": []interface{}{
+			map[string]interface{}{"key": "dedicated", "operator": "Exists"},
+			map[string]interface{}{"key": TaintKey, "operator": "Equal", "value": "large-app-m5-xlarge", "effect": "NoSchedule"},
+		},
+		"worker": map[string]interface{}{
+			"affinity": map[string]interface{}{"values": []interface{}{"large-app-m5-xlarge"}},
+		},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("expected %v, got %v", want, values)
+	}
+	if MoveValues(values, from, to) {
+		t.Fatal("expected moved values to stay")
+	}
+	if ValuesTarget(values, from) || !ValuesTarget(values, to) {
+		t.Fatal("expected the values to target the new size only")
+	}
+}
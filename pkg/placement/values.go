@@ -0,0 +1,113 @@
+package placement
+
+import (
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// MoveValues rewrites the chart values of a helm release whose workloads
+// target from to target to instead, so the workloads move with the next
+// upgrade of the release rather than by editing the objects helm owns. The
+// tolerations of the machine pools of from are replaced by the ones of to,
+// the lists naming machine pools of from, like the values of a node
+// affinity, name the ones of to, and the single names, like the value of a
+// node selector, the first one of to. It tells if values changed.
+func MoveValues(values map[string]interface{}, from, to Placement) bool {
+	if len(to.Names) == 0 {
+		return false
+	}
+	before := runtime.DeepCopyJSON(values)
+	for key, value := range values {
+		values[key] = moveValue(value, from, to)
+	}
+	return !reflect.DeepEqual(before, values)
+}
+
+// ValuesTarget tells if chart values name a machine pool of p.
+func ValuesTarget(values interface{}, p Placement) bool {
+	switch v := values.(type) {
+	case string:
+		return p.has(v)
+	case map[string]interface{}:
+		for _, value := range v {
+			if ValuesTarget(value, p) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if ValuesTarget(value, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func moveValue(value interface{}, from, to Placement) interface{} {
+	switch v := value.(type) {
+	case string:
+		if from.has(v) {
+			return to.Names[0]
+		}
+		return v
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = moveValue(item, from, to)
+		}
+		return v
+	case []interface{}:
+		return moveList(v, from, to)
+	}
+	return value
+}
+
+// moveList moves a list of tolerations or of machine pool names, and the
+// values nested in the other lists.
+func moveList(list []interface{}, from, to Placement) []interface{} {
+	poolOf := func(item interface{}) (string, bool) {
+		if toleration, ok := item.(map[string]interface{}); ok && toleration["key"] == TaintKey {
+			name, ok := toleration["value"].(string)
+			return name, ok
+		}
+		name, ok := item.(string)
+		return name, ok
+	}
+
+	targetsFrom, tolerations := false, false
+	for _, item := range list {
+		if name, ok := poolOf(item); ok && from.has(name) {
+			targetsFrom = true
+			_, tolerations = item.(map[string]interface{})
+		}
+	}
+	if !targetsFrom {
+		for i, item := range list {
+			list[i] = moveValue(item, from, to)
+		}
+		return list
+	}
+
+	var moved []interface{}
+	for _, item := range list {
+		if name, ok := poolOf(item); ok && (from.has(name) || to.has(name)) {
+			continue
+		}
+		moved = append(moved, moveValue(item, from, to))
+	}
+	for _, name := range to.Names {
+		if !tolerations {
+			moved = append(moved, name)
+			continue
+		}
+		moved = append(moved, map[string]interface{}{
+			"key":      TaintKey,
+			"operator": string(corev1.TolerationOpEqual),
+			"value":    name,
+			"effect":   string(corev1.TaintEffectNoSchedule),
+		})
+	}
+	return moved
+}
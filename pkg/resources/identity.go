@@ -0,0 +1,128 @@
+package resources
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RoleArnAnnotation is the iam role the pods running as a service account
+// assume through IRSA.
+const RoleArnAnnotation = "eks.amazonaws.com/role-arn"
+
+// TenantServiceAccountName returns the service account a tenant deploys
+// with, in the namespace of the tenant.
+func TenantServiceAccountName(tenant string) string {
+	return tenant + "-deployer"
+}
+
+// tenantRules grant the workloads of a tenant in its namespace. The quotas,
+// limit ranges, network policies and rbac of the namespace are managed by
+// baaz and only readable, so the tenant can't lift its isolation.
+var tenantRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{
+			"pods", "pods/log", "pods/exec", "pods/portforward", "services", "endpoints",
+			"configmaps", "secrets", "persistentvolumeclaims",
+		},
+		Verbs: []string{rbacv1.VerbAll},
+	},
+	{
+		APIGroups: []string{"apps"},
+		Resources: []string{"deployments", "statefulsets", "daemonsets", "replicasets"},
+		Verbs:     []string{rbacv1.VerbAll},
+	},
+	{
+		APIGroups: []string{"batch"},
+		Resources: []string{"jobs", "cronjobs"},
+		Verbs:     []string{rbacv1.VerbAll},
+	},
+	{
+		APIGroups: []string{"autoscaling"},
+		Resources: []string{"horizontalpodautoscalers"},
+		Verbs:     []string{rbacv1.VerbAll},
+	},
+	{
+		APIGroups: []string{"networking.k8s.io"},
+		Resources: []string{"ingresses"},
+		Verbs:     []string{rbacv1.VerbAll},
+	},
+	{
+		APIGroups: []string{"policy"},
+		Resources: []string{"poddisruptionbudgets"},
+		Verbs:     []string{rbacv1.VerbAll},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"events", "serviceaccounts", "resourcequotas", "limitranges"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"networking.k8s.io"},
+		Resources: []string{"networkpolicies"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+}
+
+// MakeTenantServiceAccount returns the service account of a tenant, which
+// assumes roleArn when it is set.
+func MakeTenantServiceAccount(tenant, namespace, roleArn string, ownerRef *metav1.OwnerReference) *corev1.ServiceAccount {
+	serviceAccount := &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ServiceAccount",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            TenantServiceAccountName(tenant),
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+	}
+	if roleArn != "" {
+		serviceAccount.Annotations = map[string]string{RoleArnAnnotation: roleArn}
+	}
+	return serviceAccount
+}
+
+// MakeTenantRole returns the role granting a tenant its namespace.
+func MakeTenantRole(tenant, namespace string, ownerRef *metav1.OwnerReference) *rbacv1.Role {
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "Role",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            TenantServiceAccountName(tenant),
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		Rules: tenantRules,
+	}
+}
+
+// MakeTenantRoleBinding binds the role of a tenant to its service account.
+func MakeTenantRoleBinding(tenant, namespace string, ownerRef *metav1.OwnerReference) *rbacv1.RoleBinding {
+	name := TenantServiceAccountName(tenant)
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "RoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      name,
+			Namespace: namespace,
+		}},
+	}
+}
@@ -0,0 +1,36 @@
+package resources
+
+import (
+	"testing"
+)
+
+func TestMakeTenantServiceAccount(t *testing.T) {
+	if sa := MakeTenantServiceAccount("acme", "acme", "", owner); sa.Annotations != nil {
+		t.Fatalf("expected no role without IRSA, got %v", sa.Annotations)
+	}
+
+	arn := "arn:aws:iam::123456789012:role/acme"
+	if sa := MakeTenantServiceAccount("acme", "acme", arn, owner); sa.Annotations[RoleArnAnnotation] != arn {
+		t.Fatalf("expected the service account to assume the role, got %v", sa.Annotations)
+	}
+}
+
+func TestMakeTenantRole(t *testing.T) {
+	role := MakeTenantRole("acme", "acme", owner)
+	for _, rule := range role.Rules {
+		for _, resource := range rule.Resources {
+			switch resource {
+			case "resourcequotas", "limitranges", "networkpolicies", "roles", "rolebindings":
+				if len(rule.Verbs) != 3 || rule.Verbs[0] != "get" {
+					t.Fatalf("expected %s to be read only, got %v", resource, rule.Verbs)
+				}
+			}
+		}
+	}
+
+	binding := MakeTenantRoleBinding("acme", "acme", owner)
+	if binding.RoleRef.Name != role.Name || binding.Subjects[0].Name != TenantServiceAccountName("acme") ||
+		binding.Subjects[0].Namespace != "acme" {
+		t.Fatalf("expected the role to be bound to the service account of the tenant, got %v", binding)
+	}
+}
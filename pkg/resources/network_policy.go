@@ -1,28 +1,32 @@
 package resources
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+const (
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+	dnsNamespace       = "kube-system"
+	dnsPort            = 53
 )
 
+// MakeNetworkPolicy returns the network policy isolating the namespace of a
+// tenant, or nil when its config restricts no traffic. Ingress from other
+// namespaces is denied when the config denies inter namespace traffic, and
+// egress when it denies egress by default. fqdnAddresses are the addresses
+// the allowed FQDNs of the egress resolved to.
 func MakeNetworkPolicy(
 	name, namespace string,
-	allowNamespaces []string,
+	config v1.NetworkConfig,
+	fqdnAddresses []string,
 	ownerRef *metav1.OwnerReference,
 ) *networkingv1.NetworkPolicy {
 
-	var peerPolicy []networkingv1.NetworkPolicyPeer
-
-	for _, allowNamespace := range allowNamespaces {
-		peerPolicy = append(peerPolicy, networkingv1.NetworkPolicyPeer{
-			NamespaceSelector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"kubernetes.io/metadata.name": allowNamespace,
-				},
-			},
-		})
-	}
-
 	networkPolicy := &networkingv1.NetworkPolicy{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "networking.k8s.io/v1",
@@ -35,13 +39,80 @@ func MakeNetworkPolicy(
 		},
 		Spec: networkingv1.NetworkPolicySpec{
 			PodSelector: metav1.LabelSelector{},
-			Ingress: []networkingv1.NetworkPolicyIngressRule{
-				{
-					From: peerPolicy,
-				},
-			},
 		},
 	}
 
+	if config.DeniesInterNamespaceTraffic() {
+		// an empty pod selector selects the pods of the tenant
+		peers := []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}
+		for _, names := range [][]string{
+			config.AllowedNamespaces,
+			config.IngressControllerNamespaces,
+			config.MonitoringNamespaces,
+		} {
+			for _, allowNamespace := range names {
+				peers = append(peers, namespacePeer(allowNamespace))
+			}
+		}
+		networkPolicy.Spec.PolicyTypes = append(networkPolicy.Spec.PolicyTypes, networkingv1.PolicyTypeIngress)
+		networkPolicy.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{From: peers}}
+	}
+
+	if egress := config.Egress; egress != nil && egress.DefaultDeny {
+		rules := []networkingv1.NetworkPolicyEgressRule{{
+			To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+		}}
+		if !egress.DenyDNS {
+			rules = append(rules, dnsRule())
+		}
+
+		var blocks []networkingv1.NetworkPolicyPeer
+		for _, cidr := range egress.AllowedCIDRs {
+			blocks = append(blocks, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		for _, address := range fqdnAddresses {
+			blocks = append(blocks, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: address}})
+		}
+		if len(blocks) > 0 {
+			rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: blocks})
+		}
+
+		networkPolicy.Spec.PolicyTypes = append(networkPolicy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		networkPolicy.Spec.Egress = rules
+	}
+
+	if len(networkPolicy.Spec.PolicyTypes) == 0 {
+		return nil
+	}
 	return networkPolicy
 }
+
+func namespacePeer(name string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				namespaceNameLabel: name,
+			},
+		},
+	}
+}
+
+// dnsRule allows the queries to the dns of the cluster.
+func dnsRule() networkingv1.NetworkPolicyEgressRule {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	port := intstr.FromInt32(dnsPort)
+	return networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{namespaceNameLabel: dnsNamespace},
+			},
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"k8s-app": "kube-dns"},
+			},
+		}},
+		Ports: []networkingv1.NetworkPolicyPort{
+			{Protocol: &udp, Port: &port},
+			{Protocol: &tcp, Port: &port},
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package resources
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+var owner = &metav1.OwnerReference{APIVersion: "baaz.dev/v1", Kind: "Tenants", Name: "acme"}
+
+func TestMakeNetworkPolicyAllow(t *testing.T) {
+	if policy := MakeNetworkPolicy("acme-network-policy", "acme", v1.NetworkConfig{}, nil, owner); policy != nil {
+		t.Fatalf("expected no policy when no traffic is restricted, got %v", policy.Spec)
+	}
+
+	config := v1.NetworkConfig{Enabled: true, InterNamespaceTraffic: v1.Allow}
+	if policy := MakeNetworkPolicy("acme-network-policy", "acme", config, nil, owner); policy != nil {
+		t.Fatal("expected Allow to take precedence over enabled")
+	}
+}
+
+func TestMakeNetworkPolicyDeny(t *testing.T) {
+	config := v1.NetworkConfig{
+		InterNamespaceTraffic:       v1.Deny,
+		AllowedNamespaces:           []string{"shared"},
+		IngressControllerNamespaces: []string{"ingress-nginx"},
+		MonitoringNamespaces:        []string{"monitoring"},
+	}
+	policy := MakeNetworkPolicy("acme-network-policy", "acme", config, nil, owner)
+
+	if len(policy.Spec.PolicyTypes) != 1 || policy.Spec.PolicyTypes[0] != networkingv1.PolicyTypeIngress {
+		t.Fatalf("expected an ingress policy, got %v", policy.Spec.PolicyTypes)
+	}
+	peers := policy.Spec.Ingress[0].From
+	if len(peers) != 4 || peers[0].PodSelector == nil || peers[0].NamespaceSelector != nil {
+		t.Fatalf("expected the tenant and three namespaces to be allowed, got %v", peers)
+	}
+	for i, namespace := range []string{"shared", "ingress-nginx", "monitoring"} {
+		if got := peers[i+1].NamespaceSelector.MatchLabels[namespaceNameLabel]; got != namespace {
+			t.Fatalf("expected namespace %s to be allowed, got %s", namespace, got)
+		}
+	}
+}
+
+func TestMakeNetworkPolicyEgress(t *testing.T) {
+	config := v1.NetworkConfig{Egress: &v1.EgressConfig{
+		DefaultDeny:  true,
+		AllowedCIDRs: []string{"10.0.0.0/16"},
+		AllowedFQDNs: []string{"api.example.com"},
+	}}
+	policy := MakeNetworkPolicy("acme-network-policy", "acme", config, []string{"192.0.2.10/32"}, owner)
+
+	if len(policy.Spec.PolicyTypes) != 1 || policy.Spec.PolicyTypes[0] != networkingv1.PolicyTypeEgress {
+		t.Fatalf("expected an egress policy, got %v", policy.Spec.PolicyTypes)
+	}
+	rules := policy.Spec.Egress
+	if len(rules) != 3 {
+		t.Fatalf("expected the tenant, dns and the allowed addresses rules, got %v", rules)
+	}
+	if len(rules[1].Ports) != 2 || rules[1].Ports[0].Port.IntValue() != dnsPort {
+		t.Fatalf("expected dns to be allowed, got %v", rules[1])
+	}
+	blocks := rules[2].To
+	if len(blocks) != 2 || blocks[0].IPBlock.CIDR != "10.0.0.0/16" || blocks[1].IPBlock.CIDR != "192.0.2.10/32" {
+		t.Fatalf("unexpected allowed addresses %v", blocks)
+	}
+
+	config.Egress.DenyDNS = true
+	config.Egress.AllowedCIDRs = nil
+	policy = MakeNetworkPolicy("acme-network-policy", "acme", config, nil, owner)
+	if len(policy.Spec.Egress) != 1 {
+		t.Fatalf("expected only the tenant to be reachable, got %v", policy.Spec.Egress)
+	}
+}
@@ -0,0 +1,189 @@
+package resources
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+// QuotaLabel marks the quotas and limit ranges baaz manages in the namespace
+// of a tenant with the name of the tenant.
+const QuotaLabel = "baaz.dev/quota"
+
+// MergeQuota returns the quota of a tenant. The quotas of its sizes add up,
+// the container limits are the ones of the first size setting them, and the
+// entries of the quota of the tenant override them. It is nil when neither
+// sets a quota.
+func MergeQuota(sizes []*v1.QuotaSpec, tenant *v1.QuotaSpec) *v1.QuotaSpec {
+	var merged *v1.QuotaSpec
+	for _, size := range sizes {
+		if size == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &v1.QuotaSpec{}
+		}
+		merged.Hard = addResources(merged.Hard, size.Hard)
+		for class, hard := range size.PriorityClasses {
+			if merged.PriorityClasses == nil {
+				merged.PriorityClasses = make(map[string]corev1.ResourceList)
+			}
+			merged.PriorityClasses[class] = addResources(merged.PriorityClasses[class], hard)
+		}
+		if merged.Containers == nil && size.Containers != nil {
+			merged.Containers = size.Containers.DeepCopy()
+		}
+	}
+
+	if tenant == nil {
+		return merged
+	}
+	if merged == nil {
+		return tenant.DeepCopy()
+	}
+	merged.Hard = overrideResources(merged.Hard, tenant.Hard)
+	for class, hard := range tenant.PriorityClasses {
+		if merged.PriorityClasses == nil {
+			merged.PriorityClasses = make(map[string]corev1.ResourceList)
+		}
+		merged.PriorityClasses[class] = overrideResources(merged.PriorityClasses[class], hard)
+	}
+	if tenant.Containers != nil {
+		if merged.Containers == nil {
+			merged.Containers = &v1.ContainerLimits{}
+		}
+		merged.Containers.Default = overrideResources(merged.Containers.Default, tenant.Containers.Default)
+		merged.Containers.DefaultRequest = overrideResources(merged.Containers.DefaultRequest, tenant.Containers.DefaultRequest)
+		merged.Containers.Max = overrideResources(merged.Containers.Max, tenant.Containers.Max)
+	}
+	return merged
+}
+
+func addResources(into, from corev1.ResourceList) corev1.ResourceList {
+	if len(from) == 0 {
+		return into
+	}
+	if into == nil {
+		into = corev1.ResourceList{}
+	}
+	for name, quantity := range from {
+		sum := into[name]
+		sum.Add(quantity)
+		into[name] = sum
+	}
+	return into
+}
+
+func overrideResources(into, from corev1.ResourceList) corev1.ResourceList {
+	if len(from) == 0 {
+		return into
+	}
+	if into == nil {
+		into = corev1.ResourceList{}
+	}
+	for name, quantity := range from {
+		into[name] = quantity.DeepCopy()
+	}
+	return into
+}
+
+// QuotaName returns the name of the ResourceQuota of the namespace of a
+// tenant, or of the pods of a priority class when it is set.
+func QuotaName(tenant, priorityClass string) string {
+	if priorityClass == "" {
+		return tenant + "-quota"
+	}
+	return tenant + "-quota-" + priorityClass
+}
+
+// MakeResourceQuotas returns the ResourceQuotas of the namespace of a
+// tenant: one for the namespace and one per priority class, scoped to its
+// pods.
+func MakeResourceQuotas(tenant, namespace string, quota *v1.QuotaSpec, ownerRef *metav1.OwnerReference) []*corev1.ResourceQuota {
+	var quotas []*corev1.ResourceQuota
+	if len(quota.Hard) > 0 {
+		quotas = append(quotas, makeResourceQuota(tenant, namespace, "", quota.Hard, ownerRef))
+	}
+
+	classes := make([]string, 0, len(quota.PriorityClasses))
+	for class := range quota.PriorityClasses {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		resourceQuota := makeResourceQuota(tenant, namespace, class, quota.PriorityClasses[class], ownerRef)
+		resourceQuota.Spec.ScopeSelector = &corev1.ScopeSelector{
+			MatchExpressions: []corev1.ScopedResourceSelectorRequirement{{
+				ScopeName: corev1.ResourceQuotaScopePriorityClass,
+				Operator:  corev1.ScopeSelectorOpIn,
+				Values:    []string{class},
+			}},
+		}
+		quotas = append(quotas, resourceQuota)
+	}
+	return quotas
+}
+
+func makeResourceQuota(tenant, namespace, priorityClass string, hard corev1.ResourceList, ownerRef *metav1.OwnerReference) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ResourceQuota",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            QuotaName(tenant, priorityClass),
+			Namespace:       namespace,
+			Labels:          map[string]string{QuotaLabel: tenant},
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: hard,
+		},
+	}
+}
+
+// LimitRangeName returns the name of the LimitRange of the namespace of a
+// tenant.
+func LimitRangeName(tenant string) string {
+	return tenant + "-limits"
+}
+
+// MakeLimitRange returns the LimitRange setting the default and maximum
+// resources of the containers of a tenant, or nil when the quota sets none.
+func MakeLimitRange(tenant, namespace string, quota *v1.QuotaSpec, ownerRef *metav1.OwnerReference) *corev1.LimitRange {
+	containers := quota.Containers
+	if containers == nil ||
+		len(containers.Default) == 0 && len(containers.DefaultRequest) == 0 && len(containers.Max) == 0 {
+		return nil
+	}
+
+	// the api server defaults the limits of the containers to the maximum
+	// and their requests to the limits, the same is done here so the
+	// applied LimitRange matches
+	defaults := overrideResources(containers.Max.DeepCopy(), containers.Default)
+	defaultRequests := overrideResources(defaults.DeepCopy(), containers.DefaultRequest)
+
+	return &corev1.LimitRange{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "LimitRange",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            LimitRangeName(tenant),
+			Namespace:       namespace,
+			Labels:          map[string]string{QuotaLabel: tenant},
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				Default:        defaults,
+				DefaultRequest: defaultRequests,
+				Max:            containers.Max,
+			}},
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package resources
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func resources(pairs ...string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	for i := 0; i < len(pairs); i += 2 {
+		list[corev1.ResourceName(pairs[i])] = resource.MustParse(pairs[i+1])
+	}
+	return list
+}
+
+func TestMergeQuota(t *testing.T) {
+	if MergeQuota([]*v1.QuotaSpec{nil}, nil) != nil {
+		t.Fatal("expected no quota when neither the sizes nor the tenant set one")
+	}
+
+	small := &v1.QuotaSpec{
+		Hard:            resources("requests.cpu", "2", "pods", "10"),
+		PriorityClasses: map[string]corev1.ResourceList{"baaz-low-priority": resources("pods", "5")},
+		Containers:      &v1.ContainerLimits{Default: resources("cpu", "500m")},
+	}
+	large := &v1.QuotaSpec{
+		Hard:       resources("requests.cpu", "8"),
+		Containers: &v1.ContainerLimits{Default: resources("cpu", "1")},
+	}
+	tenant := &v1.QuotaSpec{Hard: resources("pods", "50")}
+
+	merged := MergeQuota([]*v1.QuotaSpec{small, large}, tenant)
+
+	if cpu := merged.Hard["requests.cpu"]; cpu.Cmp(resource.MustParse("10")) != 0 {
+		t.Fatalf("expected the cpu of the sizes to add up, got %s", cpu.String())
+	}
+	if pods := merged.Hard["pods"]; pods.Cmp(resource.MustParse("50")) != 0 {
+		t.Fatalf("expected the tenant to override the pods, got %s", pods.String())
+	}
+	if pods := merged.PriorityClasses["baaz-low-priority"]["pods"]; pods.Cmp(resource.MustParse("5")) != 0 {
+		t.Fatalf("unexpected priority class quota %s", pods.String())
+	}
+	if cpu := merged.Containers.Default["cpu"]; cpu.Cmp(resource.MustParse("500m")) != 0 {
+		t.Fatalf("expected the container limits of the first size, got %s", cpu.String())
+	}
+	if cpu := small.Hard["requests.cpu"]; cpu.Cmp(resource.MustParse("2")) != 0 {
+		t.Fatal("expected the quota of the size to be left alone")
+	}
+}
+
+func TestMakeResourceQuotas(t *testing.T) {
+	quota := &v1.QuotaSpec{
+		Hard:            resources("requests.cpu", "2"),
+		PriorityClasses: map[string]corev1.ResourceList{"baaz-low-priority": resources("pods", "5")},
+	}
+	quotas := MakeResourceQuotas("acme", "acme", quota, owner)
+	if len(quotas) != 2 || quotas[0].Name != "acme-quota" || quotas[1].Name != "acme-quota-baaz-low-priority" {
+		t.Fatalf("unexpected quotas %v", quotas)
+	}
+	if quotas[0].Spec.ScopeSelector != nil {
+		t.Fatal("expected the namespace quota to be unscoped")
+	}
+	if scope := quotas[1].Spec.ScopeSelector.MatchExpressions[0]; scope.ScopeName != corev1.ResourceQuotaScopePriorityClass ||
+		scope.Values[0] != "baaz-low-priority" {
+		t.Fatalf("expected the quota to be scoped to its priority class, got %v", scope)
+	}
+}
+
+func TestMakeLimitRange(t *testing.T) {
+	if MakeLimitRange("acme", "acme", &v1.QuotaSpec{}, owner) != nil {
+		t.Fatal("expected no limit range without container limits")
+	}
+
+	quota := &v1.QuotaSpec{Containers: &v1.ContainerLimits{
+		Default: resources("cpu", "500m"),
+		Max:     resources("cpu", "2", "memory", "4Gi"),
+	}}
+	item := MakeLimitRange("acme", "acme", quota, owner).Spec.Limits[0]
+
+	if cpu := item.Default["cpu"]; cpu.Cmp(resource.MustParse("500m")) != 0 {
+		t.Fatalf("unexpected default cpu %s", cpu.String())
+	}
+	if memory := item.Default["memory"]; memory.Cmp(resource.MustParse("4Gi")) != 0 {
+		t.Fatalf("expected the default memory to be the maximum, got %s", memory.String())
+	}
+	if cpu := item.DefaultRequest["cpu"]; cpu.Cmp(resource.MustParse("500m")) != 0 {
+		t.Fatalf("expected the default request to be the default limit, got %s", cpu.String())
+	}
+}
@@ -0,0 +1,24 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// InstrumentHandler starts a span named after route for every request,
+// continuing the trace of the caller when it sends a traceparent header.
+func InstrumentHandler(route, pattern string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := Start(ctx, route,
+			attribute.String("http.method", req.Method),
+			attribute.String("http.route", pattern),
+		)
+		defer span.End()
+
+		handler.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	tracerName = "github.com/baazhq/baaz"
+	// AnnotationPrefix prefixes the w3c trace context keys stored as
+	// annotations on the custom resources created by the http api.
+	AnnotationPrefix = "baaz.dev/"
+	// GenerationAnnotation is the generation of the object written by the
+	// request whose trace context the annotations hold.
+	GenerationAnnotation = AnnotationPrefix + "trace-generation"
+)
+
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// Setup installs a tracer provider exporting spans over otlp grpc. The
+// exporter is configured with the standard OTEL_EXPORTER_OTLP_* env vars;
+// tracing stays a no-op when no endpoint is set.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := NewTracerProvider(serviceName, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewTracerProvider returns a tracer provider for serviceName with opts.
+func NewTracerProvider(serviceName string, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+		)),
+	}, opts...)
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// Start starts a span named name as a child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// annotationCarrier adapts object annotations to a propagation.TextMapCarrier.
+type annotationCarrier map[string]string
+
+func (ac annotationCarrier) Get(key string) string {
+	return ac[AnnotationPrefix+key]
+}
+
+func (ac annotationCarrier) Set(key, value string) {
+	ac[AnnotationPrefix+key] = value
+}
+
+func (ac annotationCarrier) Keys() []string {
+	keys := make([]string, 0, len(ac))
+	for k := range ac {
+		if strings.HasPrefix(k, AnnotationPrefix) {
+			keys = append(keys, strings.TrimPrefix(k, AnnotationPrefix))
+		}
+	}
+	return keys
+}
+
+// InjectAnnotations returns annotations with the trace context of ctx added,
+// so the reconciles of the annotated object continue the trace.
+func InjectAnnotations(ctx context.Context, annotations map[string]string) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, annotationCarrier(annotations))
+	return annotations
+}
+
+// ExtractAnnotations returns ctx carrying the trace context stored in annotations.
+func ExtractAnnotations(ctx context.Context, annotations map[string]string) context.Context {
+	if annotations == nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, annotationCarrier(annotations))
+}
+
+// InjectObject annotates obj, about to be written by a request, with the
+// trace context of ctx and the generation the write produces.
+func InjectObject(ctx context.Context, obj client.Object) {
+	annotations := InjectAnnotations(ctx, obj.GetAnnotations())
+	annotations[GenerationAnnotation] = strconv.FormatInt(obj.GetGeneration()+1, 10)
+	obj.SetAnnotations(annotations)
+}
+
+// StartReconcile starts the span of a reconcile of obj by controller. The
+// reconciles of the generation written by a request link to its trace, they
+// are not its children as they go on long after it, and the reconciles of
+// the later generations do not.
+func StartReconcile(ctx context.Context, controller string, obj client.Object) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{trace.WithAttributes(
+		attribute.String("k8s.namespace", obj.GetNamespace()),
+		attribute.String("k8s.name", obj.GetName()),
+		attribute.Int64("k8s.generation", obj.GetGeneration()),
+	)}
+	annotations := obj.GetAnnotations()
+	if annotations[GenerationAnnotation] == strconv.FormatInt(obj.GetGeneration(), 10) {
+		request := trace.SpanContextFromContext(ExtractAnnotations(context.Background(), annotations))
+		if request.IsValid() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: request}))
+		}
+	}
+	return otel.Tracer(tracerName).Start(ctx, controller+".Reconcile", opts...)
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/baazhq/baaz/api/v1/types"
+)
+
+func setupInMemory(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := NewTracerProvider("baaz-test", sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prev)
+	})
+	return exporter
+}
+
+func TestReconcileLinksToRequestTrace(t *testing.T) {
+	exporter := setupInMemory(t)
+
+	tenant := &v1.Tenants{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-a", Namespace: "customer-a"},
+	}
+
+	handler := InstrumentHandler("CREATE TENANT", "/api/v1/customer/{customer_name}/tenant/{tenant_name}",
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			InjectObject(req.Context(), tenant)
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/customer/customer-a/tenant/tenant-a", nil))
+
+	if tenant.GetAnnotations()[AnnotationPrefix+"traceparent"] == "" {
+		t.Fatalf("expected traceparent annotation, got %v", tenant.GetAnnotations())
+	}
+	// the api server bumps the generation on create
+	tenant.Generation = 1
+
+	ctx, span := StartReconcile(context.Background(), "TenantsReconciler", tenant)
+	_, child := Start(ctx, "eks.DescribeNodegroup")
+	End(child, nil)
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	byName := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	httpSpan, reconcileSpan, eksSpan := byName["CREATE TENANT"], byName["TenantsReconciler.Reconcile"], byName["eks.DescribeNodegroup"]
+
+	if reconcileSpan.Parent.IsValid() || reconcileSpan.SpanContext.TraceID() == httpSpan.SpanContext.TraceID() {
+		t.Fatal("expected reconcile span to start its own trace")
+	}
+	if len(reconcileSpan.Links) != 1 || reconcileSpan.Links[0].SpanContext.SpanID() != httpSpan.SpanContext.SpanID() {
+		t.Fatalf("expected reconcile span to link to the http span, got %v", reconcileSpan.Links)
+	}
+	if eksSpan.Parent.SpanID() != reconcileSpan.SpanContext.SpanID() {
+		t.Fatal("expected eks span to be a child of the reconcile span")
+	}
+
+	// a later generation is not written by the request
+	tenant.Generation = 2
+	_, span = StartReconcile(context.Background(), "TenantsReconciler", tenant)
+	span.End()
+	spans = exporter.GetSpans()
+	if links := spans[len(spans)-1].Links; len(links) != 0 {
+		t.Fatalf("expected no link for a later generation, got %v", links)
+	}
+}
+
+func TestStartReconcileWithoutAnnotationsStartsNewTrace(t *testing.T) {
+	exporter := setupInMemory(t)
+
+	_, span := StartReconcile(context.Background(), "DataPlaneReconciler", &v1.DataPlanes{})
+	End(span, context.DeadlineExceeded)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Parent.IsValid() {
+		t.Fatal("expected a root span")
+	}
+	if len(spans[0].Events) == 0 {
+		t.Fatal("expected the error to be recorded on the span")
+	}
+}
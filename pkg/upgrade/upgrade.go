@@ -0,0 +1,203 @@
+// Package upgrade holds the version arithmetic, pre-flight checks and
+// nodegroup ordering of the eks upgrade workflow run by the dataplane
+// controller.
+package upgrade
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxKubeletSkew is the number of minor versions nodes may lag behind the
+// control plane.
+const MaxKubeletSkew = 2
+
+// Version is a kubernetes minor version like 1.28.
+type Version struct {
+	Major int
+	Minor int
+}
+
+// ParseVersion parses versions like 1.28, v1.28.3 or v1.28.3-eks-8ccc7ba.
+func ParseVersion(v string) (Version, error) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 {
+		return Version{}, fmt.Errorf("invalid kubernetes version %q", v)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid kubernetes version %q", v)
+	}
+	minor, err := strconv.Atoi(strings.TrimRight(parts[1], "+"))
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid kubernetes version %q", v)
+	}
+	return Version{Major: major, Minor: minor}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Compare returns -1, 0 or 1 when v is older, equal or newer than o.
+func (v Version) Compare(o Version) int {
+	if v.Major != o.Major {
+		return compareInt(v.Major, o.Major)
+	}
+	return compareInt(v.Minor, o.Minor)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// NextStep returns the minor version following current on the way to
+// target. Control planes can only move one minor version at a time.
+func NextStep(current, target string) (string, error) {
+	cur, err := ParseVersion(current)
+	if err != nil {
+		return "", err
+	}
+	tgt, err := ParseVersion(target)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case cur.Major != tgt.Major:
+		return "", fmt.Errorf("upgrade from %s to %s crosses a major version", cur, tgt)
+	case tgt.Compare(cur) < 0:
+		return "", fmt.Errorf("downgrade from %s to %s is not supported", cur, tgt)
+	case tgt.Compare(cur) == 0:
+		return cur.String(), nil
+	}
+	return Version{Major: cur.Major, Minor: cur.Minor + 1}.String(), nil
+}
+
+// CheckSkew returns a failure for every nodegroup which would lag more than
+// MaxKubeletSkew minor versions behind a control plane moved to step.
+func CheckSkew(step string, nodegroups map[string]string) []string {
+	stepVersion, err := ParseVersion(step)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var failures []string
+	for _, name := range sortedKeys(nodegroups) {
+		ngVersion, err := ParseVersion(nodegroups[name])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("nodegroup %s: %s", name, err))
+			continue
+		}
+		if ngVersion.Major != stepVersion.Major || stepVersion.Minor-ngVersion.Minor > MaxKubeletSkew {
+			failures = append(failures, fmt.Sprintf("nodegroup %s at %s is too old for control plane %s", name, ngVersion, stepVersion))
+		}
+	}
+	return failures
+}
+
+// DeprecatedAPI is an api version still requested from the cluster.
+type DeprecatedAPI struct {
+	Group          string
+	Version        string
+	Resource       string
+	RemovedRelease string
+}
+
+func (d DeprecatedAPI) String() string {
+	gv := d.Version
+	if d.Group != "" {
+		gv = d.Group + "/" + d.Version
+	}
+	return fmt.Sprintf("%s %s is removed in %s and still in use", gv, d.Resource, d.RemovedRelease)
+}
+
+var labelRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+const deprecatedAPIMetric = "apiserver_requested_deprecated_apis"
+
+// RemovedAPIs reads the apiserver /metrics output and returns the deprecated
+// apis requested since the apiserver started which are removed in release
+// or earlier.
+func RemovedAPIs(metrics io.Reader, release string) ([]DeprecatedAPI, error) {
+	releaseVersion, err := ParseVersion(release)
+	if err != nil {
+		return nil, err
+	}
+
+	var apis []DeprecatedAPI
+	scanner := bufio.NewScanner(metrics)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, deprecatedAPIMetric+"{") {
+			continue
+		}
+		end := strings.LastIndex(line, "}")
+		if end < 0 || strings.TrimSpace(line[end+1:]) != "1" {
+			continue
+		}
+		labels := map[string]string{}
+		for _, m := range labelRegexp.FindAllStringSubmatch(line[:end], -1) {
+			labels[m[1]] = m[2]
+		}
+		removed, err := ParseVersion(labels["removed_release"])
+		if err != nil || removed.Compare(releaseVersion) > 0 {
+			continue
+		}
+		apis = append(apis, DeprecatedAPI{
+			Group:          labels["group"],
+			Version:        labels["version"],
+			Resource:       labels["resource"],
+			RemovedRelease: labels["removed_release"],
+		})
+	}
+	return apis, scanner.Err()
+}
+
+// OrderNodegroups sorts nodegroups for upgrade: the nodegroups of the
+// tenant sizes in sizeOrder first, in that order, then the others by name.
+// sizeOf maps a nodegroup to its tenant size.
+func OrderNodegroups(nodegroups []string, sizeOf map[string]string, sizeOrder []string) []string {
+	rank := make(map[string]int, len(sizeOrder))
+	for i, size := range sizeOrder {
+		if _, found := rank[size]; !found {
+			rank[size] = i
+		}
+	}
+	rankOf := func(nodegroup string) int {
+		if r, found := rank[sizeOf[nodegroup]]; found {
+			return r
+		}
+		return len(sizeOrder)
+	}
+
+	ordered := append([]string(nil), nodegroups...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, rj := rankOf(ordered[i]), rankOf(ordered[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return ordered[i] < ordered[j]
+	})
+	return ordered
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,77 @@
+package upgrade
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNextStep(t *testing.T) {
+	tests := []struct {
+		current, target string
+		want            string
+		wantErr         bool
+	}{
+		{"1.27", "1.27", "1.27", false},
+		{"1.27", "1.28", "1.28", false},
+		{"1.26", "1.29", "1.27", false},
+		{"v1.27.8-eks-8ccc7ba", "1.29", "1.28", false},
+		{"1.28", "1.27", "", true},
+		{"1.28", "2.0", "", true},
+		{"1.28", "latest", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NextStep(tt.current, tt.target)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NextStep(%s, %s) = %q, %v; want %q, error %v", tt.current, tt.target, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckSkew(t *testing.T) {
+	failures := CheckSkew("1.29", map[string]string{
+		"system":   "1.28",
+		"tenant-a": "1.27",
+		"tenant-b": "1.26",
+	})
+	if len(failures) != 1 || !strings.Contains(failures[0], "tenant-b") {
+		t.Fatalf("expected tenant-b to fail the skew check, got %v", failures)
+	}
+}
+
+func TestRemovedAPIs(t *testing.T) {
+	metrics := `# HELP apiserver_requested_deprecated_apis [STABLE] Gauge of deprecated APIs that have been requested
+# TYPE apiserver_requested_deprecated_apis gauge
+apiserver_requested_deprecated_apis{group="flowcontrol.apiserver.k8s.io",removed_release="1.29",resource="prioritylevelconfigurations",subresource="",version="v1beta2"} 1
+apiserver_requested_deprecated_apis{group="flowcontrol.apiserver.k8s.io",removed_release="1.32",resource="flowschemas",subresource="",version="v1beta3"} 1
+apiserver_requested_deprecated_apis{group="policy",removed_release="1.25",resource="podsecuritypolicies",subresource="",version="v1beta1"} 0
+apiserver_request_total{code="200",verb="GET"} 42
+`
+	apis, err := RemovedAPIs(strings.NewReader(metrics), "1.29")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DeprecatedAPI{{
+		Group:          "flowcontrol.apiserver.k8s.io",
+		Version:        "v1beta2",
+		Resource:       "prioritylevelconfigurations",
+		RemovedRelease: "1.29",
+	}}
+	if !reflect.DeepEqual(apis, want) {
+		t.Fatalf("expected %v, got %v", want, apis)
+	}
+}
+
+func TestOrderNodegroups(t *testing.T) {
+	sizeOf := map[string]string{
+		"acme-m5":    "acme",
+		"beta-t3":    "beta",
+		"beta-t3-ng": "beta",
+		"zeta-c5":    "zeta",
+	}
+	got := OrderNodegroups([]string{"zeta-c5", "acme-m5", "beta-t3-ng", "beta-t3"}, sizeOf, []string{"beta"})
+	want := []string{"beta-t3", "beta-t3-ng", "acme-m5", "zeta-c5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}